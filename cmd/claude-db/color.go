@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/executor"
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+)
+
+// color is set when results and errors are shown in color: when stdout
+// is a terminal, unless -no-color is given or $NO_COLOR is set (see
+// https://no-color.org). Output to a file or a pipe is never colored.
+var color = false
+
+// ANSI escape sequences for errors.
+const (
+	ansiReset   = "\033[0m"
+	ansiError   = "\033[1;31m" // bold red
+	ansiKeyword = "\033[1;36m" // bold cyan
+)
+
+// words finds the words of an error message that may be keywords.
+var words = regexp.MustCompile(`\b[A-Z]+\b`)
+
+// colorError colors an error message for the terminal: its "Parse error:"
+// in red, and the SQL keywords it mentions, as in "expected FROM", in
+// cyan, so that what the parser wanted stands out.
+func colorError(message string) string {
+	prefix, rest, found := strings.Cut(message, ": ")
+	if !found {
+		return ansiError + message + ansiReset
+	}
+	rest = words.ReplaceAllStringFunc(rest, func(word string) string {
+		if lexer.IsKeyword(word) {
+			return ansiKeyword + word + ansiReset
+		}
+		return word
+	})
+	return ansiError + prefix + ":" + ansiReset + " " + rest
+}
+
+// formatter returns the formatter for a result written to w: the output
+// mode's, drawing tables in color on the terminal.
+func formatter(w io.Writer) executor.Formatter {
+	if mode == "table" && color && w == os.Stdout {
+		return executor.TableFormatter{Color: true}
+	}
+	return executor.Formatters[mode]
+}
@@ -18,10 +18,16 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/cabewaldrop/claude-db/internal/catalog"
 	"github.com/cabewaldrop/claude-db/internal/sql/executor"
@@ -47,33 +53,127 @@ const (
 
 // dotCommands are special commands starting with '.'
 var dotCommands = map[string]string{
-	".help":   "Show this help message",
-	".quit":   "Exit the program",
-	".exit":   "Exit the program (alias for .quit)",
-	".tables": "List all tables",
-	".schema": "Show schema for all tables or a specific table",
-	".clear":  "Clear the screen",
+	".help":    "Show this help message",
+	".quit":    "Exit the program",
+	".exit":    "Exit the program (alias for .quit)",
+	".tables":  "List all tables",
+	".schema":  "Show schema for all tables or a specific table",
+	".clear":   "Clear the screen",
+	".vacuum":  "Compact the database file (same as VACUUM)",
+	".stats":   "Show buffer pool, disk I/O and table size statistics",
+	".mode":    "Set the output format: table, csv, json or vertical",
+	".output":  "Send results to a file (.output FILE), or back to the screen (.output)",
+	".once":    "Send the next result to a file (.once FILE)",
+	".dump":    "Write the SQL that recreates the database to a file (.dump [FILE])",
+	".read":    "Execute the SQL statements in a file (.read FILE)",
+	".bail":    "Stop .read at the first error (.bail on), or go on (.bail off)",
+	".timer":   "Show parse, plan and execution times after each statement (.timer on|off)",
+	".backup":  "Copy the database to a file while it stays open (.backup FILE)",
+	".restore": "Replace the database with a backup (.restore FILE)",
+	".pager":   "Show results longer than the screen in $PAGER (.pager on|off)",
 }
 
+// mode is the output format results are shown in (see .mode).
+var mode = "table"
+
+// bail stops a script at its first failing statement (see .bail).
+var bail = false
+
+// timer shows how long each statement spent parsing, planning and
+// executing (see .timer).
+var timer = false
+
+// interactive is true when statements are typed at a terminal, rather
+// than given with -c or piped in.
+var interactive = true
+
+// failed is set when a statement fails, to exit with an error.
+var failed = false
+
 func main() {
-	// Parse command line flags
+	// claude-db migrate ... runs the migrations and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	os.Exit(run())
+}
+
+// run opens the database and runs the statements given with -c, or piped
+// in, or typed at the REPL, returning the process's exit code: 1 if a
+// statement failed.
+func run() int {
+	// Parse command line flags; a database file may also be named after
+	// them, as in claude-db mydata.db
 	dbPath := flag.String("db", "claude.db", "Path to database file")
 	showVersion := flag.Bool("version", false, "Show version and exit")
+	useMmap := flag.Bool("mmap", false, "Read the database file through a memory mapping")
+	eviction := flag.String("eviction", "2q", "Page cache eviction policy: 2q, lru, clock or lfu")
+	compress := flag.Bool("compress", false, "Compress the pages of a new database")
+	readOnly := flag.Bool("readonly", false, "Open the database for reading only, alongside other readers")
+	queryMemory := flag.Int("query-memory", 256, "Megabytes of rows a statement may hold in memory (0 for no limit)")
+	key := flag.String("key", os.Getenv("CLAUDE_DB_KEY"), "Hex-encoded AES key to encrypt the database with (default $CLAUDE_DB_KEY)")
+	command := flag.String("c", "", "Run the given statements (or dot command) and exit")
+	flag.StringVar(&mode, "mode", mode, "Output format: table, csv, json or vertical")
+	flag.BoolVar(&bail, "bail", bail, "Stop at the first failing statement of a script")
+	noColor := flag.Bool("no-color", false, "Don't color errors and results, even at a terminal")
 	flag.Parse()
+	if flag.NArg() > 0 {
+		*dbPath = flag.Arg(0)
+	}
+	if _, ok := executor.Formatters[mode]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown mode %s (use %s)\n", mode, strings.Join(executor.FormatterNames(), ", "))
+		return 2
+	}
+
+	// Statements given with -c or piped in are run without the banner,
+	// prompts and metrics, leaving only their results on stdout
+	interactive = *command == "" && isTerminal(os.Stdin)
+	color = !*noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
 
 	if *showVersion {
 		fmt.Printf("claude-db version %s\n", version)
-		return
+		return 0
 	}
 
 	// Print banner
-	fmt.Printf(banner, version)
+	if interactive {
+		fmt.Printf(banner, version)
+	}
+
+	policy, err := storage.ParseEvictionPolicy(*eviction)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
 
 	// Initialize pager (storage layer)
-	pager, err := storage.NewPager(*dbPath)
+	opts := []storage.PagerOption{storage.WithWAL(), storage.WithEvictionPolicy(policy)}
+	if *useMmap {
+		opts = append(opts, storage.WithMmap())
+	}
+	if *compress {
+		opts = append(opts, storage.WithCompression())
+	}
+	if *readOnly {
+		opts = append(opts, storage.WithReadOnly())
+	}
+	if *key != "" {
+		codec, err := encryptionCodec(*key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		opts = append(opts, storage.WithCodec(codec))
+	}
+	pager, err := storage.NewPager(*dbPath, opts...)
+	if errors.Is(err, storage.ErrLocked) {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Only one process may open a database for writing; close the other, or open both with -readonly.")
+		return 1
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 	defer pager.Close()
 
@@ -81,36 +181,104 @@ func main() {
 	cat, err := catalog.NewCatalog(pager)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing catalog: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	// Initialize executor with catalog
 	exec, err := executor.NewWithCatalog(pager, cat)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading database: %v\n", err)
-		os.Exit(1)
+		return 1
+	}
+	defer exec.DetachAll()
+	defer exec.DropTempTables()
+	exec.SetQueryMemory(*queryMemory << 20)
+
+	if *command != "" {
+		runCommand(*command, exec)
+		return exitCode()
 	}
 
 	// Show loaded tables
 	tables := exec.GetTables()
-	if len(tables) > 0 {
+	if interactive && len(tables) > 0 {
 		fmt.Printf("Loaded %d table(s): %s\n\n", len(tables), strings.Join(tables, ", "))
 	}
 
 	// Start REPL
 	repl(exec)
+	return exitCode()
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// exitCode is the process's exit code: 1 if a statement failed.
+func exitCode() int {
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// runCommand runs the statements given with -c, stopping at the first
+// that fails, or the dot command.
+func runCommand(command string, exec *executor.Executor) {
+	if strings.HasPrefix(strings.TrimSpace(command), ".") {
+		handleDotCommand(strings.TrimSpace(command), exec)
+		return
+	}
+	for _, sql := range parser.SplitStatements(command) {
+		if !executeSQL(sql, exec) {
+			return
+		}
+	}
 }
 
+// reportError prints the error of a statement or command, on stderr
+// unless at the REPL, and records the failure for the exit code.
+func reportError(format string, args ...any) {
+	failed = true
+	message := fmt.Sprintf(format, args...)
+	if interactive {
+		if color {
+			message = colorError(message)
+		}
+		fmt.Println(message)
+		return
+	}
+	if color && isTerminal(os.Stderr) {
+		message = colorError(message)
+	}
+	fmt.Fprintln(os.Stderr, message)
+}
+
+// encryptionCodec creates the page codec for a hex-encoded AES key.
+func encryptionCodec(hexKey string) (*storage.EncryptionCodec, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be hex-encoded: %w", err)
+	}
+	return storage.NewEncryptionCodec(key)
+}
+
+// stdin is where the REPL reads statements, and COPY ... FROM STDIN the
+// lines that follow them.
+var stdin = bufio.NewReader(os.Stdin)
+
 // repl implements the Read-Eval-Print Loop.
 func repl(exec *executor.Executor) {
-	reader := bufio.NewReader(os.Stdin)
+	reader := stdin
 	var inputBuffer strings.Builder
 
 	for {
-		// Print prompt
-		if inputBuffer.Len() == 0 {
+		// Print prompt, unless the input is piped in
+		if interactive && inputBuffer.Len() == 0 {
 			fmt.Print("claude-db> ")
-		} else {
+		} else if interactive {
 			fmt.Print("       ...> ")
 		}
 
@@ -120,7 +288,10 @@ func repl(exec *executor.Executor) {
 			if err.Error() == "EOF" {
 				// Flush changes before exit
 				exec.Flush()
-				fmt.Println("\nGoodbye!")
+				closeOutput()
+				if interactive {
+					fmt.Println("\nGoodbye!")
+				}
 				return
 			}
 			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
@@ -144,18 +315,24 @@ func repl(exec *executor.Executor) {
 		inputBuffer.WriteString(line)
 
 		// Check if statement is complete (ends with semicolon)
+		// (a CREATE TRIGGER is complete at the semicolon after its END)
 		input := strings.TrimSpace(inputBuffer.String())
-		if !strings.HasSuffix(input, ";") {
+		if !strings.HasSuffix(input, ";") || parser.UnfinishedTrigger(input) {
 			inputBuffer.WriteString(" ")
 			continue
 		}
 
-		// Remove trailing semicolon for parsing
-		input = strings.TrimSuffix(input, ";")
 		inputBuffer.Reset()
 
-		// Execute the statement
-		executeSQL(input, exec)
+		// Execute each statement on the line(s) in turn; a piped script
+		// stops at a failure with .bail on
+		for _, sql := range parser.SplitStatements(input) {
+			if !executeSQL(sql, exec) && bail && !interactive {
+				exec.Flush()
+				closeOutput()
+				return
+			}
+		}
 	}
 }
 
@@ -174,21 +351,44 @@ func handleDotCommand(cmd string, exec *executor.Executor) {
 		}
 		fmt.Println("\nSQL Commands:")
 		fmt.Println("  CREATE TABLE name (column definitions)")
+		fmt.Println("  CREATE TEMP TABLE name (column definitions)")
+		fmt.Println("  CREATE TABLE name (...) PARTITION BY RANGE (col) (PARTITION p VALUES LESS THAN (v|MAXVALUE), ...)")
+		fmt.Println("  CREATE TABLE name (...) PARTITION BY HASH (col) PARTITIONS n")
+		fmt.Println("  CREATE TABLE name (...) USING columnar")
+		fmt.Println("  CREATE VIRTUAL TABLE name USING csv('file.csv')")
+		fmt.Println("  CREATE VIRTUAL TABLE name USING remote('http://host:port', 'table')")
 		fmt.Println("  DROP TABLE name")
 		fmt.Println("  INSERT INTO table (columns) VALUES (values)")
-		fmt.Println("  SELECT columns FROM table [WHERE condition] [ORDER BY ...] [LIMIT n]")
+		fmt.Println("  COPY table [(columns)] FROM 'file.csv'|STDIN [WITH HEADER]")
+		fmt.Println("  SELECT columns FROM table [WHERE condition] [ORDER BY ...] [LIMIT n] [FOR UPDATE]")
+		fmt.Println("  SELECT columns FROM generate_series(start, stop[, step]) [AS name]")
 		fmt.Println("  UPDATE table SET column = value [WHERE condition]")
 		fmt.Println("  DELETE FROM table [WHERE condition]")
+		fmt.Println("  CREATE TRIGGER name BEFORE|AFTER INSERT|UPDATE|DELETE ON table BEGIN statements; END")
+		fmt.Println("  DROP TRIGGER name")
+		fmt.Println("  CREATE SEQUENCE name [START WITH n] [INCREMENT BY n] / DROP SEQUENCE name")
+		fmt.Println("  CREATE MATERIALIZED VIEW name AS SELECT ... / REFRESH MATERIALIZED VIEW name")
+		fmt.Println("  DROP MATERIALIZED VIEW name")
+		fmt.Println("  BEGIN / COMMIT / ROLLBACK")
+		fmt.Println("  SAVEPOINT name / ROLLBACK TO name / RELEASE name")
+		fmt.Println("  VACUUM")
+		fmt.Println("  PRAGMA integrity_check")
+		fmt.Println("  SET setting = value / PRAGMA setting")
+		fmt.Println("  SHOW TABLES / SHOW INDEXES [FROM table] / DESCRIBE table")
+		fmt.Println("  ATTACH DATABASE 'file' AS alias / DETACH DATABASE alias")
 		fmt.Println()
 
 	case ".quit", ".exit":
 		// Flush changes before exit
 		exec.Flush()
-		fmt.Println("Goodbye!")
-		os.Exit(0)
+		closeOutput()
+		if interactive {
+			fmt.Println("Goodbye!")
+		}
+		os.Exit(exitCode())
 
 	case ".tables":
-		tables := exec.GetTables()
+		tables := append(exec.GetTables(), exec.VirtualTables()...)
 		if len(tables) == 0 {
 			fmt.Println("No tables found.")
 		} else {
@@ -204,8 +404,8 @@ func handleDotCommand(cmd string, exec *executor.Executor) {
 			tableName := parts[1]
 			showTableSchema(tableName, exec)
 		} else {
-			// Show schema for all tables
-			tables := exec.GetTables()
+			// Show schema for all tables, the virtual ones last
+			tables := append(exec.GetTables(), exec.VirtualTables()...)
 			for _, name := range tables {
 				showTableSchema(name, exec)
 			}
@@ -215,65 +415,253 @@ func handleDotCommand(cmd string, exec *executor.Executor) {
 		// ANSI escape code to clear screen
 		fmt.Print("\033[H\033[2J")
 
+	case ".vacuum":
+		executeSQL("VACUUM", exec)
+
+	case ".stats":
+		showStats(exec)
+
+	case ".mode":
+		if len(parts) == 1 {
+			fmt.Printf("Output mode: %s\n", mode)
+			return
+		}
+		if _, ok := executor.Formatters[parts[1]]; !ok {
+			fmt.Printf("Unknown mode: %s (use %s)\n", parts[1], strings.Join(executor.FormatterNames(), ", "))
+			return
+		}
+		mode = parts[1]
+
+	case ".dump":
+		if err := dump(strings.TrimSpace(strings.TrimPrefix(cmd, ".dump")), exec); err != nil {
+			reportError("Error: %v", err)
+		}
+
+	case ".backup", ".restore":
+		path := strings.TrimSpace(strings.TrimPrefix(cmd, parts[0]))
+		if path == "" {
+			fmt.Printf("Usage: %s FILE\n", parts[0])
+			return
+		}
+		backup := exec.Backup
+		if parts[0] == ".restore" {
+			backup = exec.Restore
+		}
+		if err := backup(path); err != nil {
+			reportError("Error: %v", err)
+		} else if parts[0] == ".backup" {
+			fmt.Printf("Backed up the database to %s\n", path)
+		} else {
+			fmt.Printf("Restored the database from %s\n", path)
+		}
+
+	case ".read":
+		path := strings.TrimSpace(strings.TrimPrefix(cmd, ".read"))
+		if path == "" {
+			fmt.Println("Usage: .read FILE")
+			return
+		}
+		if err := readScript(path, exec); err != nil {
+			reportError("Error: %v", err)
+		}
+
+	case ".bail", ".timer", ".pager":
+		if len(parts) != 2 || parts[1] != "on" && parts[1] != "off" {
+			fmt.Printf("Usage: %s on|off\n", parts[0])
+			return
+		}
+		switch parts[0] {
+		case ".bail":
+			bail = parts[1] == "on"
+		case ".timer":
+			timer = parts[1] == "on"
+		default:
+			paging = parts[1] == "on"
+		}
+
+	case ".output", ".once":
+		path := strings.TrimSpace(strings.TrimPrefix(cmd, parts[0]))
+		if path == "" && parts[0] == ".once" {
+			fmt.Println("Usage: .once FILE")
+			return
+		}
+		if err := setOutput(path, parts[0] == ".once"); err != nil {
+			reportError("Error: %v", err)
+		}
+
 	default:
 		fmt.Printf("Unknown command: %s\n", parts[0])
 		fmt.Println("Type '.help' for available commands.")
 	}
 }
 
-// showTableSchema displays the schema for a table.
+// readScript executes the statements of the SQL script at path, in order.
+// With .bail on, it stops at the first that fails, returning an error.
+func readScript(path string, exec *executor.Executor) error {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read script: %w", err)
+	}
+	stmts := parser.SplitStatements(string(script))
+	for i, sql := range stmts {
+		if !executeSQL(sql, exec) && bail {
+			return fmt.Errorf("%s: stopped at statement %d of %d", path, i+1, len(stmts))
+		}
+	}
+	return nil
+}
+
+// dump writes the statements recreating the database to the file at
+// path, or where results go when path is empty.
+func dump(path string, exec *executor.Executor) error {
+	if path == "" {
+		defer resultWritten()
+		return exec.Dump(resultWriter())
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot create dump file: %w", err)
+	}
+	if err := exec.Dump(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Dumped the database to %s\n", path)
+	return nil
+}
+
+// showStats displays the buffer pool statistics and the size of each
+// table.
+func showStats(exec *executor.Executor) {
+	stats := exec.PagerStats()
+	fmt.Println("Buffer pool:")
+	fmt.Printf("  Cached pages:  %d / %d (%d dirty, %d pinned)\n", stats.CachedPages, stats.MaxCacheSize, stats.DirtyPages, stats.PinnedPages)
+	fmt.Printf("  Hits:          %d\n", stats.Hits)
+	fmt.Printf("  Misses:        %d\n", stats.Misses)
+	fmt.Printf("  Hit rate:      %.1f%%\n", stats.HitRate()*100)
+	fmt.Printf("  Evictions:     %d (%d dirty)\n", stats.Evictions, stats.DirtyEvictions)
+	fmt.Println("Disk I/O:")
+	fmt.Printf("  Reads:         %d\n", stats.DiskReads)
+	fmt.Printf("  Writes:        %d\n", stats.DiskWrites)
+	fmt.Printf("  Syncs:         %d\n", stats.Syncs)
+
+	names := exec.GetTables()
+	if len(names) == 0 {
+		return
+	}
+	fmt.Println("Tables:")
+	for _, name := range names {
+		tbl, _ := exec.GetTable(name)
+		tableStats := tbl.Stats()
+		fmt.Printf("  %-14s %d rows, %d bytes\n", name+":", tableStats.RowCount, tableStats.BytesStored)
+	}
+}
+
+// showTableSchema displays the statements creating a table and its
+// indexes.
 func showTableSchema(name string, exec *executor.Executor) {
-	tbl, ok := exec.GetTable(name)
+	ddl, ok := exec.TableDDL(name)
 	if !ok {
 		fmt.Printf("Table '%s' not found.\n", name)
 		return
 	}
-
-	fmt.Printf("CREATE TABLE %s (\n", name)
-	for i, col := range tbl.Schema.Columns {
-		suffix := ""
-		if col.PrimaryKey {
-			suffix = " PRIMARY KEY"
-		} else if col.NotNull {
-			suffix = " NOT NULL"
-		}
-		comma := ","
-		if i == len(tbl.Schema.Columns)-1 {
-			comma = ""
-		}
-		fmt.Printf("  %s %s%s%s\n", col.Name, col.Type, suffix, comma)
+	for _, sql := range ddl {
+		fmt.Println(sql + ";")
 	}
-	fmt.Println(");")
 }
 
-// executeSQL parses and executes a SQL statement.
-func executeSQL(input string, exec *executor.Executor) {
+// executeSQL parses and executes a SQL statement, printing its result or
+// error. It returns whether the statement succeeded.
+func executeSQL(input string, exec *executor.Executor) bool {
 	// Lexer
+	start := time.Now()
 	lex := lexer.New(input)
 
 	// Parser
 	p := parser.New(lex)
 	stmt, err := p.Parse()
 	if err != nil {
-		fmt.Printf("Parse error: %v\n", err)
-		return
+		reportError("Parse error: %v", err)
+		return false
 	}
 
 	if stmt == nil {
-		fmt.Println("Error: Could not parse statement")
-		return
+		reportError("Error: Could not parse statement")
+		return false
 	}
-
-	// Execute
-	result, err := exec.Execute(stmt)
+	parsing := time.Since(start)
+
+	// Execute; Ctrl-C cancels the statement rather than ending the program.
+	// COPY ... FROM STDIN reads the lines typed or piped after it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	var result *executor.Result
+	if copyStmt, ok := stmt.(*parser.CopyStatement); ok && copyStmt.Stdin {
+		if interactive {
+			fmt.Println("Enter CSV lines, then \\. on a line by itself (or end of input).")
+		}
+		result, err = exec.CopyFrom(ctx, copyStmt, &copyReader{r: stdin})
+	} else {
+		result, err = exec.ExecuteContext(ctx, stmt)
+	}
+	stop()
 	if err != nil {
-		fmt.Printf("Execution error: %v\n", err)
-		return
+		reportError("Execution error: %v", err)
+		return false
 	}
 
-	// Print result
-	fmt.Print(result.String())
+	// Print result in the output mode, a row at a time, and how the
+	// statement ran. Rows go to the output file if there is one, and
+	// through the pager if there are more than fit on the screen.
+	w := io.Writer(os.Stdout)
+	if result.Message == "" {
+		w = resultWriter()
+		defer resultWritten()
+	}
+	format := formatter(w)
+	w, paged := paginate(w)
+	if err := format.Format(w, result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing result: %v\n", err)
+	}
+	paged()
+	if interactive {
+		fmt.Println(result.Metrics())
+	}
+	if timer {
+		fmt.Println(result.Timing(parsing))
+	}
 
 	// Flush after modifying operations
 	exec.Flush()
+	return true
+}
+
+// copyReader reads the lines of a COPY ... FROM STDIN up to a line holding
+// only \., the end marker psql uses, or the end of input.
+type copyReader struct {
+	r    *bufio.Reader
+	line []byte
+	done bool
+}
+
+func (c *copyReader) Read(p []byte) (int, error) {
+	for len(c.line) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.done = true
+		}
+		if strings.TrimRight(line, "\r\n") == `\.` {
+			c.done = true
+			continue
+		}
+		c.line = []byte(line)
+	}
+	n := copy(p, c.line)
+	c.line = c.line[n:]
+	return n, nil
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/migrate"
+	"github.com/cabewaldrop/claude-db/internal/sql/executor"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+const migrateUsage = `Usage: claude-db migrate [flags] [up | down [n] | status]
+
+  up        apply every pending migration (the default)
+  down [n]  revert the last n applied migrations (default 1)
+  status    list the migrations and whether each is applied
+
+Flags:
+`
+
+// runMigrate runs the migrate subcommand with its arguments, returning the
+// process's exit code.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	dbPath := flags.String("db", "claude.db", "Path to database file")
+	dir := flags.String("dir", "migrations", "Directory of the .up.sql and .down.sql migration files")
+	key := flags.String("key", os.Getenv("CLAUDE_DB_KEY"), "Hex-encoded AES key the database is encrypted with (default $CLAUDE_DB_KEY)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	command, steps := "up", 1
+	if flags.NArg() > 0 {
+		command = flags.Arg(0)
+	}
+	switch {
+	case command == "down" && flags.NArg() == 2:
+		n, err := strconv.Atoi(flags.Arg(1))
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Error: invalid number of migrations to revert: %s\n", flags.Arg(1))
+			return 2
+		}
+		steps = n
+	case (command == "up" || command == "down" || command == "status") && flags.NArg() <= 1:
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	migrations, err := migrate.Load(os.DirFS(*dir))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	opts := []storage.PagerOption{storage.WithWAL()}
+	if *key != "" {
+		codec, err := encryptionCodec(*key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		opts = append(opts, storage.WithCodec(codec))
+	}
+	pager, err := storage.NewPager(*dbPath, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		return 1
+	}
+	defer pager.Close()
+	cat, err := catalog.NewCatalog(pager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing catalog: %v\n", err)
+		return 1
+	}
+	exec, err := executor.NewWithCatalog(pager, cat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading database: %v\n", err)
+		return 1
+	}
+
+	m := migrate.New(exec, migrations)
+	switch command {
+	case "status":
+		applied, err := m.Applied()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		done := make(map[int64]bool, len(applied))
+		for _, v := range applied {
+			done[v] = true
+		}
+		for _, mig := range migrations {
+			state := "pending"
+			if done[mig.Version] {
+				state = "applied"
+			}
+			fmt.Printf("%-8s %d_%s\n", state, mig.Version, mig.Name)
+		}
+		return 0
+
+	case "up":
+		done, err := m.Up()
+		for _, mig := range done {
+			fmt.Printf("applied  %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("No migrations to apply.")
+		}
+		return 0
+
+	default: // down
+		done, err := m.Down(steps)
+		for _, mig := range done {
+			fmt.Printf("reverted %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("No migrations to revert.")
+		}
+		return 0
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// output is where query results go: the terminal, or the file named by
+// .output (until the next .output) or .once (for the next result only).
+// With .mode csv, this exports a query's rows to a file:
+//
+//	.mode csv
+//	.once users.csv
+//	SELECT * FROM users;
+//
+// Messages, errors and metrics stay on the terminal, so the file holds
+// nothing but rows.
+var output struct {
+	file *os.File // nil for stdout
+	once bool
+}
+
+// setOutput sends results to the file at path, created or truncated, or
+// back to stdout when path is empty.
+func setOutput(path string, once bool) error {
+	closeOutput()
+	if path == "" {
+		return nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot open output file: %w", err)
+	}
+	output.file, output.once = file, once
+	return nil
+}
+
+// closeOutput closes the output file, if there is one, returning results
+// to stdout.
+func closeOutput() {
+	if output.file != nil {
+		if err := output.file.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error closing %s: %v\n", output.file.Name(), err)
+		}
+	}
+	output.file, output.once = nil, false
+}
+
+// resultWriter returns where the rows of a result go.
+func resultWriter() io.Writer {
+	if output.file != nil {
+		return output.file
+	}
+	return os.Stdout
+}
+
+// resultWritten ends the output of a .once after its result.
+func resultWritten() {
+	if output.once {
+		closeOutput()
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+// paging sends results too long for the screen through a pager, as psql
+// does (see .pager). Without one, a thousand-row result scrolls past
+// faster than it can be read, leaving its last screenful and no way back
+// to its header. The pager is $PAGER, or less when it isn't set; setting
+// PAGER to nothing turns paging off.
+var paging = true
+
+// paginate returns the writer a result written to w goes through, and a
+// function to call when it has been written. At the REPL, a result for
+// the screen is held back until it is longer than the screen, then
+// handed to the pager; a shorter one is printed as usual.
+func paginate(w io.Writer) (io.Writer, func()) {
+	command, set := os.LookupEnv("PAGER")
+	if !set {
+		command = "less"
+	}
+	lines := screenHeight()
+	if !paging || !interactive || w != os.Stdout || strings.TrimSpace(command) == "" || lines <= 0 {
+		return w, func() {}
+	}
+	// Leave room for the metrics and the next prompt
+	p := &pagedWriter{command: command, room: max(lines-2, 1)}
+	return p, p.close
+}
+
+// screenHeight returns the number of lines of the terminal, from the
+// terminal itself or from $LINES, or 0 if it isn't known.
+func screenHeight() int {
+	if lines := terminalHeight(os.Stdout); lines > 0 {
+		return lines
+	}
+	lines, _ := strconv.Atoi(os.Getenv("LINES"))
+	return lines
+}
+
+// pagedWriter holds back a result until it has more lines than there is
+// room for on the screen, then starts the pager and writes through it.
+type pagedWriter struct {
+	command string
+	room    int // lines that fit on the screen
+	held    bytes.Buffer
+	lines   int
+
+	pager      *exec.Cmd
+	pipe       io.WriteCloser
+	quit       bool // the pager was closed before the end of the result
+	interrupts chan os.Signal
+}
+
+func (p *pagedWriter) Write(b []byte) (int, error) {
+	if p.pipe != nil {
+		if !p.quit {
+			if _, err := p.pipe.Write(b); err != nil {
+				// Quitting the pager early leaves the rest unread
+				p.quit = true
+			}
+		}
+		return len(b), nil
+	}
+	p.held.Write(b)
+	p.lines += bytes.Count(b, []byte("\n"))
+	if p.lines > p.room && p.start() != nil {
+		// No pager to be had: print the result after all
+		p.room = math.MaxInt
+	}
+	return len(b), nil
+}
+
+// start starts the pager, and writes it what has been held back. Ctrl-C
+// belongs to the pager until it exits.
+func (p *pagedWriter) start() error {
+	fields := strings.Fields(p.command)
+	p.pager = exec.Command(fields[0], fields[1:]...)
+	p.pager.Stdout, p.pager.Stderr = os.Stdout, os.Stderr
+	if _, set := os.LookupEnv("LESS"); !set {
+		// Pass the colors through (R), chop rows wider than the screen
+		// rather than wrapping them (S), and quit at once if it all fits
+		// after all (F), leaving it on the screen (X)
+		p.pager.Env = append(os.Environ(), "LESS=FRSX")
+	}
+	pipe, err := p.pager.StdinPipe()
+	if err != nil {
+		return err
+	}
+	p.interrupts = make(chan os.Signal, 1)
+	signal.Notify(p.interrupts, os.Interrupt)
+	if err := p.pager.Start(); err != nil {
+		signal.Stop(p.interrupts)
+		return err
+	}
+	p.pipe = pipe
+	p.Write(p.held.Bytes())
+	p.held.Reset()
+	return nil
+}
+
+// close prints a result that fit on the screen, or waits for the reader
+// to quit the pager.
+func (p *pagedWriter) close() {
+	if p.pipe == nil {
+		os.Stdout.Write(p.held.Bytes())
+		return
+	}
+	defer signal.Stop(p.interrupts)
+	p.pipe.Close()
+	p.pager.Wait()
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// terminalHeight returns 0: the terminal's size is only asked for where
+// the TIOCGWINSZ ioctl is available, and $LINES is used elsewhere.
+func terminalHeight(f *os.File) int {
+	return 0
+}
@@ -15,6 +15,22 @@
 // Our catalog uses page 0 as a special "catalog page" that stores:
 // - Number of tables
 // - For each table: name, schema, root page ID
+// - The statistics ANALYZE gathered, for the tables it has analyzed
+// - Where to find the tables' and indexes' CREATE statements
+// - The first page of the free list (see storage.FreePage)
+//
+// The binary schema is what the database runs on, but it only holds what
+// the code reading it knows about. The CREATE statements, normalized from
+// their parse tree (CREATE TABLE users (id INTEGER PRIMARY KEY, ...)),
+// are kept beside it, so that .schema can show a table as it was created
+// and anything the binary format doesn't capture yet isn't lost. SQLite
+// goes further: sqlite_master holds only the SQL text, which it parses
+// again each time the database is opened. Here the secondary indexes do
+// that: only their root page is binary, and their name, columns and
+// uniqueness are read back from their CREATE INDEX statement. Triggers go
+// all the way: they are kept only as their CREATE TRIGGER statement, and
+// so are materialized views' queries, beside the table holding their
+// rows.
 
 package catalog
 
@@ -22,7 +38,13 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
 	"github.com/cabewaldrop/claude-db/internal/storage"
 	"github.com/cabewaldrop/claude-db/internal/table"
@@ -33,7 +55,61 @@ const (
 	CatalogPageID = 0
 
 	// Magic number to identify a valid catalog
-	CatalogMagic = 0xCDB0 // "CDB" for Claude DB
+	CatalogMagic = 0xCDB1 // "CDB" for Claude DB
+
+	// catalogMagicLittleEndianKeys identifies a catalog written before
+	// B-tree keys were order-preserving; its tables' primary keys are
+	// rebuilt when they're loaded.
+	catalogMagicLittleEndianKeys = 0xCDB0
+
+	// statsMagic starts the statistics that follow the tables. Catalogs
+	// written before statistics were saved don't have it, and may have
+	// anything left after their tables, since page data isn't cleared.
+	statsMagic = 0x5354 // "ST"
+
+	// ddlMagic starts the location of the CREATE statements, after the
+	// statistics. Catalogs written before they were saved don't have it.
+	ddlMagic = 0x4444 // "DD"
+
+	// freeListMagic starts the free list's first page and page count,
+	// after the location of the CREATE statements.
+	freeListMagic = 0x464C // "FL"
+
+	// countsMagic starts the row counts of the tables, after the free
+	// list. Catalogs written before they were saved don't have it, and
+	// their tables are counted when they're loaded.
+	countsMagic = 0x5243 // "RC"
+
+	// sequencesMagic starts the sequences, after the row counts. Catalogs
+	// written before sequences existed end before it.
+	sequencesMagic = 0x5351 // "SQ"
+
+	// layoutsMagic starts the layouts of the altered tables' schema
+	// versions (see table.Schema.Layouts), after the CREATE statements in
+	// their overflow chain. Chains written before ALTER TABLE existed, or
+	// with no table altered, end before it.
+	layoutsMagic = 0x4C59 // "LY"
+
+	// triggersMagic starts the CREATE TRIGGER statements of the tables
+	// that have triggers, after the layouts (if any). Chains written
+	// before triggers existed, or with none, end before it.
+	triggersMagic = 0x5447 // "TG"
+
+	// viewsMagic starts the CREATE MATERIALIZED VIEW statements of the
+	// tables that are materialized views, after the triggers (if any).
+	// Chains written before materialized views existed, or with none, end
+	// before it.
+	viewsMagic = 0x4D56 // "MV"
+
+	// rowGroupsMagic starts the row groups of the columnar tables (see
+	// table/columnar.go), after the materialized views (if any). Chains
+	// written before columnar tables existed, or with none, end before it.
+	rowGroupsMagic = 0x4347 // "CG"
+
+	// virtualTablesMagic starts the CREATE VIRTUAL TABLE statements, after
+	// the row groups (if any). Chains written before virtual tables
+	// existed, or with none, end before it.
+	virtualTablesMagic = 0x5654 // "VT"
 )
 
 // TableInfo stores metadata about a table for persistence.
@@ -44,6 +120,67 @@ type TableInfo struct {
 	DataPageIDs []uint32
 	Columns     []ColumnInfo
 	PrimaryKey  int
+	Stats       *StatsInfo  // nil if the table hasn't been analyzed
+	Counts      *CountsInfo // nil if read from a catalog without them
+	SQL         string      // The normalized CREATE TABLE statement
+	Indexes     []IndexInfo
+	Layouts     [][]int // Column IDs of each schema version; nil if never altered
+	Triggers    []TriggerInfo
+	ViewSQL     string // The CREATE MATERIALIZED VIEW statement; "" for a table
+	RowGroups   []byte // The row groups of a columnar table; nil for rows
+}
+
+// TriggerInfo stores a trigger of a table for persistence.
+type TriggerInfo struct {
+	Name string
+	SQL  string // The CREATE TRIGGER statement
+}
+
+// SequenceInfo stores a sequence (see CREATE SEQUENCE) for persistence.
+type SequenceInfo struct {
+	Name      string
+	Start     int64
+	Increment int64
+	Last      int64 // The last value NEXTVAL returned, if Called
+	Called    bool  // Whether NEXTVAL has been called yet
+}
+
+// VirtualTableInfo stores a virtual table (see CREATE VIRTUAL TABLE) for
+// persistence. It has no pages: its rows are kept outside the database.
+type VirtualTableInfo struct {
+	Name string
+	SQL  string // The CREATE VIRTUAL TABLE statement
+}
+
+// IndexInfo stores a secondary index of a table for persistence.
+type IndexInfo struct {
+	Name     string
+	RootPage uint32
+	SQL      string // The normalized CREATE INDEX statement
+}
+
+// StatsInfo stores a table's ANALYZE statistics.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// The counts fit in the catalog page, but the per-column statistics, with
+// a histogram per column, could fill it on their own, so they go in an
+// overflow chain (see storage.WriteOverflow) that this points to. A new
+// chain is written only when ANALYZE runs again, and the old one is then
+// freed.
+type StatsInfo struct {
+	RowCount      int64
+	PageCount     uint32
+	LastAnalyzed  int64  // Unix nanoseconds
+	ColumnsPage   uint32 // First page of the column statistics' chain
+	ColumnsLength uint32 // Length of the column statistics in bytes
+}
+
+// CountsInfo stores a table's row count and bytes stored, which every
+// insert, update and delete keeps up to date (see table.TableStats).
+type CountsInfo struct {
+	RowCount    int64
+	BytesStored int64
 }
 
 // ColumnInfo stores column metadata.
@@ -52,12 +189,55 @@ type ColumnInfo struct {
 	Type       parser.DataType
 	PrimaryKey bool
 	NotNull    bool
+	Precision  int // DECIMAL(precision, scale); 0 if unspecified
+	Scale      int
+	Length     int    // VARCHAR(length); 0 if unspecified
+	Collation  string // COLLATE NOCASE; "" for BINARY
 }
 
+// Column flag bits stored after the type byte.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// colFlagModifiers says that two uint16s (precision, scale) follow the
+// flags byte. Columns without type modifiers don't set it and keep the old
+// layout, so catalogs written before DECIMAL existed still load.
+// colFlagLength likewise says that a uint32, a VARCHAR's length, follows,
+// and colFlagCollation that a collation's name does (a length byte, then
+// the name).
+const (
+	colFlagPrimaryKey = 0x01
+	colFlagNotNull    = 0x02
+	colFlagModifiers  = 0x04
+	colFlagLength     = 0x08
+	colFlagCollation  = 0x10
+)
+
 // Catalog manages database metadata.
 type Catalog struct {
 	pager  *storage.Pager
 	tables map[string]*TableInfo
+
+	// rebuildKeys is set when the catalog was read from a database with
+	// keys in the old encoding
+	rebuildKeys bool
+
+	// ddl is the last encoding of the CREATE statements written, and
+	// ddlPage the first page of its overflow chain; see saveDDL
+	ddl     []byte
+	ddlPage uint32
+
+	// freeList is the free list's first page and page count as read from
+	// the catalog page, which the pager takes over when the database is
+	// opened
+	freeList [2]uint32
+
+	// sequences are the database's sequences, which aren't tied to any
+	// table
+	sequences []SequenceInfo
+
+	// virtualTables are the database's virtual tables, by name
+	virtualTables []VirtualTableInfo
 }
 
 // NewCatalog creates or loads a catalog from the pager.
@@ -78,6 +258,7 @@ func NewCatalog(pager *storage.Pager) (*Catalog, error) {
 		if err := c.loadCatalog(); err != nil {
 			return nil, err
 		}
+		pager.SetFreeList(c.freeList[0], c.freeList[1])
 	}
 
 	return c, nil
@@ -113,7 +294,12 @@ func (c *Catalog) loadCatalog() error {
 		return fmt.Errorf("failed to read catalog magic: %w", err)
 	}
 
-	if magic != CatalogMagic {
+	switch magic {
+	case CatalogMagic:
+		c.rebuildKeys = false
+	case catalogMagicLittleEndianKeys:
+		c.rebuildKeys = true
+	default:
 		// Not a valid catalog - might be a new or corrupted database
 		// Initialize fresh catalog
 		return c.saveCatalog()
@@ -134,6 +320,81 @@ func (c *Catalog) loadCatalog() error {
 		c.tables[info.Name] = info
 	}
 
+	// Statistics follow the tables
+	c.ddl, c.ddlPage = nil, 0
+	c.virtualTables = nil
+	var statsHeader [2]uint16
+	if err := binary.Read(buf, binary.LittleEndian, &statsHeader); err != nil || statsHeader[0] != statsMagic {
+		return nil
+	}
+	numStats := statsHeader[1]
+	for i := uint16(0); i < numStats; i++ {
+		name, stats, err := readStatsInfo(buf)
+		if err != nil {
+			return fmt.Errorf("failed to read statistics %d: %w", i, err)
+		}
+		if info, ok := c.tables[name]; ok {
+			info.Stats = stats
+		}
+	}
+
+	// Then the CREATE statements
+	var ddlMark uint16
+	var ddlHeader [2]uint32 // first page, length
+	if err := binary.Read(buf, binary.LittleEndian, &ddlMark); err != nil || ddlMark != ddlMagic {
+		return nil
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &ddlHeader); err != nil {
+		return fmt.Errorf("failed to read CREATE statements: %w", err)
+	}
+
+	// Then the free list
+	var freeMark uint16
+	c.freeList = [2]uint32{}
+	c.sequences = nil
+	if err := binary.Read(buf, binary.LittleEndian, &freeMark); err == nil && freeMark == freeListMagic {
+		if err := binary.Read(buf, binary.LittleEndian, &c.freeList); err != nil {
+			return fmt.Errorf("failed to read free list: %w", err)
+		}
+
+		// Then the row counts
+		var countsHeader [2]uint16
+		if err := binary.Read(buf, binary.LittleEndian, &countsHeader); err == nil && countsHeader[0] == countsMagic {
+			for i := uint16(0); i < countsHeader[1]; i++ {
+				name, counts, err := readCountsInfo(buf)
+				if err != nil {
+					return fmt.Errorf("failed to read row counts %d: %w", i, err)
+				}
+				if info, ok := c.tables[name]; ok {
+					info.Counts = counts
+				}
+			}
+
+			// Then the sequences
+			var seqHeader [2]uint16
+			if err := binary.Read(buf, binary.LittleEndian, &seqHeader); err == nil && seqHeader[0] == sequencesMagic {
+				for i := uint16(0); i < seqHeader[1]; i++ {
+					seq, err := readSequenceInfo(buf)
+					if err != nil {
+						return fmt.Errorf("failed to read sequence %d: %w", i, err)
+					}
+					c.sequences = append(c.sequences, seq)
+				}
+			}
+		}
+	}
+
+	if ddlHeader[1] == 0 {
+		return nil
+	}
+	ddl, err := c.pager.ReadOverflow(ddlHeader[0], int(ddlHeader[1]))
+	if err != nil {
+		return fmt.Errorf("failed to read CREATE statements: %w", err)
+	}
+	if err := c.decodeDDL(ddl); err != nil {
+		return fmt.Errorf("failed to read CREATE statements: %w", err)
+	}
+	c.ddl, c.ddlPage = ddl, ddlHeader[0]
 	return nil
 }
 
@@ -153,10 +414,54 @@ func (c *Catalog) saveCatalog() error {
 	binary.Write(buf, binary.LittleEndian, uint16(len(c.tables)))
 
 	// Write each table's metadata
+	var analyzed []*TableInfo
 	for _, info := range c.tables {
 		if err := c.writeTableInfo(buf, info); err != nil {
 			return err
 		}
+		if info.Stats != nil {
+			analyzed = append(analyzed, info)
+		}
+	}
+
+	// Then the statistics of the analyzed tables
+	binary.Write(buf, binary.LittleEndian, uint16(statsMagic))
+	binary.Write(buf, binary.LittleEndian, uint16(len(analyzed)))
+	for _, info := range analyzed {
+		writeStatsInfo(buf, info.Name, info.Stats)
+	}
+
+	// Then where to find the CREATE statements
+	if err := c.saveDDL(); err != nil {
+		return err
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(ddlMagic))
+	binary.Write(buf, binary.LittleEndian, [2]uint32{c.ddlPage, uint32(len(c.ddl))})
+
+	// And the free list, after everything that may have taken pages from
+	// it
+	head, count := c.pager.FreeList()
+	binary.Write(buf, binary.LittleEndian, uint16(freeListMagic))
+	binary.Write(buf, binary.LittleEndian, [2]uint32{head, count})
+
+	// Then the row counts of the tables that have them
+	var counted []*TableInfo
+	for _, info := range c.tables {
+		if info.Counts != nil {
+			counted = append(counted, info)
+		}
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(countsMagic))
+	binary.Write(buf, binary.LittleEndian, uint16(len(counted)))
+	for _, info := range counted {
+		writeCountsInfo(buf, info.Name, info.Counts)
+	}
+
+	// Then the sequences
+	binary.Write(buf, binary.LittleEndian, uint16(sequencesMagic))
+	binary.Write(buf, binary.LittleEndian, uint16(len(c.sequences)))
+	for _, seq := range c.sequences {
+		writeSequenceInfo(buf, seq)
 	}
 
 	if buf.Len() > storage.MaxDataSize {
@@ -256,6 +561,472 @@ func (c *Catalog) writeTableInfo(buf *bytes.Buffer, info *TableInfo) error {
 	return nil
 }
 
+// readStatsInfo reads a table name and its StatsInfo from the buffer.
+func readStatsInfo(buf *bytes.Reader) (string, *StatsInfo, error) {
+	var nameLen uint16
+	if err := binary.Read(buf, binary.LittleEndian, &nameLen); err != nil {
+		return "", nil, err
+	}
+	nameBytes := make([]byte, nameLen)
+	if _, err := buf.Read(nameBytes); err != nil {
+		return "", nil, err
+	}
+
+	stats := &StatsInfo{}
+	if err := binary.Read(buf, binary.LittleEndian, stats); err != nil {
+		return "", nil, err
+	}
+	return string(nameBytes), stats, nil
+}
+
+// writeStatsInfo writes a table name and its StatsInfo to the buffer.
+func writeStatsInfo(buf *bytes.Buffer, name string, stats *StatsInfo) {
+	binary.Write(buf, binary.LittleEndian, uint16(len(name)))
+	buf.WriteString(name)
+	binary.Write(buf, binary.LittleEndian, stats)
+}
+
+// readCountsInfo reads a table name and its CountsInfo from the buffer.
+func readCountsInfo(buf *bytes.Reader) (string, *CountsInfo, error) {
+	var nameLen uint16
+	if err := binary.Read(buf, binary.LittleEndian, &nameLen); err != nil {
+		return "", nil, err
+	}
+	nameBytes := make([]byte, nameLen)
+	if _, err := buf.Read(nameBytes); err != nil {
+		return "", nil, err
+	}
+
+	counts := &CountsInfo{}
+	if err := binary.Read(buf, binary.LittleEndian, counts); err != nil {
+		return "", nil, err
+	}
+	return string(nameBytes), counts, nil
+}
+
+// writeCountsInfo writes a table name and its CountsInfo to the buffer.
+func writeCountsInfo(buf *bytes.Buffer, name string, counts *CountsInfo) {
+	binary.Write(buf, binary.LittleEndian, uint16(len(name)))
+	buf.WriteString(name)
+	binary.Write(buf, binary.LittleEndian, counts)
+}
+
+// sequenceValues is the part of a SequenceInfo after its name, as stored.
+type sequenceValues struct {
+	Start     int64
+	Increment int64
+	Last      int64
+	Called    bool
+}
+
+// readSequenceInfo reads a SequenceInfo from the buffer.
+func readSequenceInfo(buf *bytes.Reader) (SequenceInfo, error) {
+	var nameLen uint16
+	if err := binary.Read(buf, binary.LittleEndian, &nameLen); err != nil {
+		return SequenceInfo{}, err
+	}
+	nameBytes := make([]byte, nameLen)
+	if _, err := buf.Read(nameBytes); err != nil {
+		return SequenceInfo{}, err
+	}
+
+	var v sequenceValues
+	if err := binary.Read(buf, binary.LittleEndian, &v); err != nil {
+		return SequenceInfo{}, err
+	}
+	return SequenceInfo{Name: string(nameBytes), Start: v.Start, Increment: v.Increment, Last: v.Last, Called: v.Called}, nil
+}
+
+// writeSequenceInfo writes a SequenceInfo to the buffer.
+func writeSequenceInfo(buf *bytes.Buffer, seq SequenceInfo) {
+	binary.Write(buf, binary.LittleEndian, uint16(len(seq.Name)))
+	buf.WriteString(seq.Name)
+	binary.Write(buf, binary.LittleEndian, sequenceValues{seq.Start, seq.Increment, seq.Last, seq.Called})
+}
+
+// TableSQL returns the normalized CREATE TABLE statement of a table with
+// the given schema.
+func TableSQL(name string, schema *table.Schema) string {
+	stmt := &parser.CreateTableStatement{Table: name, Columns: make([]parser.ColumnDefinition, len(schema.Columns))}
+	if len(schema.PrimaryKeyColumns) > 1 {
+		stmt.PrimaryKeyColumns = schema.PrimaryKeyNames()
+	}
+	for i, col := range schema.Columns {
+		stmt.Columns[i] = parser.ColumnDefinition{
+			Name:       col.Name,
+			Type:       col.Type,
+			PrimaryKey: col.PrimaryKey,
+			NotNull:    col.NotNull,
+			Precision:  col.Precision,
+			Scale:      col.Scale,
+			Length:     col.Length,
+			Collation:  string(col.Collation),
+			Default:    col.Default,
+		}
+	}
+	return stmt.String()
+}
+
+// IndexSQL returns the normalized CREATE INDEX statement of an index.
+func IndexSQL(idx *storage.Index) string {
+	stmt := &parser.CreateIndexStatement{IndexName: idx.Name, Table: idx.Table, Columns: idx.Columns, Unique: idx.Unique}
+	return stmt.String()
+}
+
+// saveDDL writes the CREATE statements of the tables and their indexes to
+// a new overflow chain, if they have changed since they were last written,
+// and frees the old chain.
+func (c *Catalog) saveDDL() error {
+	oldPage, oldLength := c.ddlPage, len(c.ddl)
+	if len(c.tables) == 0 && len(c.virtualTables) == 0 {
+		c.ddl, c.ddlPage = nil, 0
+	} else {
+		data := c.encodeDDL()
+		if c.ddlPage != 0 && bytes.Equal(data, c.ddl) {
+			return nil
+		}
+		page, err := c.pager.WriteOverflow(data)
+		if err != nil {
+			return fmt.Errorf("failed to save CREATE statements: %w", err)
+		}
+		c.ddl, c.ddlPage = data, page
+	}
+
+	// The new chain is written first, so it can't reuse the pages of the
+	// old one, which the catalog page on disk still points to
+	if oldPage != 0 {
+		return c.freeChain(oldPage, oldLength, "CREATE statements")
+	}
+	return nil
+}
+
+// freeChain puts the pages of an overflow chain holding length bytes on
+// the free list.
+func (c *Catalog) freeChain(pageID uint32, length int, what string) error {
+	pages, err := c.pager.OverflowPages(pageID, length)
+	if err != nil {
+		return fmt.Errorf("failed to find the pages of the %s: %w", what, err)
+	}
+	for _, pageID := range pages {
+		if err := c.pager.FreePage(pageID); err != nil {
+			return fmt.Errorf("failed to free the pages of the %s: %w", what, err)
+		}
+	}
+	return nil
+}
+
+// encodeDDL encodes the CREATE statements of the tables, by name, each
+// followed by those of its indexes with their root pages, and then the
+// schema layouts of the tables that have been altered, the triggers of
+// those that have any, the queries of the materialized views, the row
+// groups of the columnar tables and the virtual tables.
+func (c *Catalog) encodeDDL() []byte {
+	names := c.ListTables()
+	sort.Strings(names)
+
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, uint16(len(names)))
+	for _, name := range names {
+		info := c.tables[name]
+		writeString(buf, name)
+		writeString(buf, info.SQL)
+		binary.Write(buf, binary.LittleEndian, uint16(len(info.Indexes)))
+		for _, idx := range info.Indexes {
+			writeString(buf, idx.Name)
+			binary.Write(buf, binary.LittleEndian, idx.RootPage)
+			writeString(buf, idx.SQL)
+		}
+	}
+
+	var altered []string
+	for _, name := range names {
+		if len(c.tables[name].Layouts) > 0 {
+			altered = append(altered, name)
+		}
+	}
+	if len(altered) > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(layoutsMagic))
+		binary.Write(buf, binary.LittleEndian, uint16(len(altered)))
+		for _, name := range altered {
+			layouts := c.tables[name].Layouts
+			writeString(buf, name)
+			binary.Write(buf, binary.LittleEndian, uint16(len(layouts)))
+			for _, layout := range layouts {
+				binary.Write(buf, binary.LittleEndian, uint16(len(layout)))
+				for _, id := range layout {
+					binary.Write(buf, binary.LittleEndian, uint16(id))
+				}
+			}
+		}
+	}
+
+	var triggered []string
+	for _, name := range names {
+		if len(c.tables[name].Triggers) > 0 {
+			triggered = append(triggered, name)
+		}
+	}
+	if len(triggered) > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(triggersMagic))
+		binary.Write(buf, binary.LittleEndian, uint16(len(triggered)))
+		for _, name := range triggered {
+			triggers := c.tables[name].Triggers
+			writeString(buf, name)
+			binary.Write(buf, binary.LittleEndian, uint16(len(triggers)))
+			for _, trigger := range triggers {
+				writeString(buf, trigger.Name)
+				writeString(buf, trigger.SQL)
+			}
+		}
+	}
+
+	var views []string
+	for _, name := range names {
+		if c.tables[name].ViewSQL != "" {
+			views = append(views, name)
+		}
+	}
+	if len(views) > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(viewsMagic))
+		binary.Write(buf, binary.LittleEndian, uint16(len(views)))
+		for _, name := range views {
+			writeString(buf, name)
+			writeString(buf, c.tables[name].ViewSQL)
+		}
+	}
+
+	var columnar []string
+	for _, name := range names {
+		if c.tables[name].RowGroups != nil {
+			columnar = append(columnar, name)
+		}
+	}
+	if len(columnar) > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(rowGroupsMagic))
+		binary.Write(buf, binary.LittleEndian, uint16(len(columnar)))
+		for _, name := range columnar {
+			writeString(buf, name)
+			writeString(buf, string(c.tables[name].RowGroups))
+		}
+	}
+
+	if len(c.virtualTables) > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(virtualTablesMagic))
+		binary.Write(buf, binary.LittleEndian, uint16(len(c.virtualTables)))
+		for _, vt := range c.virtualTables {
+			writeString(buf, vt.Name)
+			writeString(buf, vt.SQL)
+		}
+	}
+	return buf.Bytes()
+}
+
+// decodeDDL reads the CREATE statements encoded by encodeDDL into the
+// tables' metadata.
+func (c *Catalog) decodeDDL(data []byte) error {
+	buf := bytes.NewReader(data)
+	var numTables uint16
+	if err := binary.Read(buf, binary.LittleEndian, &numTables); err != nil {
+		return err
+	}
+	for i := uint16(0); i < numTables; i++ {
+		name, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		sql, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		var numIndexes uint16
+		if err := binary.Read(buf, binary.LittleEndian, &numIndexes); err != nil {
+			return err
+		}
+		indexes := make([]IndexInfo, numIndexes)
+		for j := range indexes {
+			if indexes[j].Name, err = readString(buf); err != nil {
+				return err
+			}
+			if err := binary.Read(buf, binary.LittleEndian, &indexes[j].RootPage); err != nil {
+				return err
+			}
+			if indexes[j].SQL, err = readString(buf); err != nil {
+				return err
+			}
+		}
+		if info, ok := c.tables[name]; ok {
+			info.SQL, info.Indexes = sql, indexes
+		}
+	}
+
+	// Then the layouts, if any table has been altered, the triggers, if
+	// any table has some, the materialized views' queries, the columnar
+	// tables' row groups and the virtual tables, if there are any
+	for buf.Len() > 0 {
+		var header [2]uint16
+		if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+			return err
+		}
+		var err error
+		switch header[0] {
+		case layoutsMagic:
+			err = c.readLayouts(buf, int(header[1]))
+		case triggersMagic:
+			err = c.readTriggers(buf, int(header[1]))
+		case viewsMagic:
+			err = c.readViews(buf, int(header[1]))
+		case rowGroupsMagic:
+			err = c.readRowGroups(buf, int(header[1]))
+		case virtualTablesMagic:
+			err = c.readVirtualTables(buf, int(header[1]))
+		default:
+			err = fmt.Errorf("unexpected data after CREATE statements")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLayouts reads the schema layouts of count tables, as encodeDDL
+// writes them.
+func (c *Catalog) readLayouts(buf *bytes.Reader, count int) error {
+	for i := 0; i < count; i++ {
+		name, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		var numVersions uint16
+		if err := binary.Read(buf, binary.LittleEndian, &numVersions); err != nil {
+			return err
+		}
+		layouts := make([][]int, numVersions)
+		for v := range layouts {
+			var numColumns uint16
+			if err := binary.Read(buf, binary.LittleEndian, &numColumns); err != nil {
+				return err
+			}
+			ids := make([]uint16, numColumns)
+			if err := binary.Read(buf, binary.LittleEndian, ids); err != nil {
+				return err
+			}
+			layouts[v] = make([]int, numColumns)
+			for j, id := range ids {
+				layouts[v][j] = int(id)
+			}
+		}
+		if info, ok := c.tables[name]; ok {
+			info.Layouts = layouts
+		}
+	}
+	return nil
+}
+
+// readTriggers reads the triggers of count tables, as encodeDDL writes
+// them.
+func (c *Catalog) readTriggers(buf *bytes.Reader, count int) error {
+	for i := 0; i < count; i++ {
+		name, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		var numTriggers uint16
+		if err := binary.Read(buf, binary.LittleEndian, &numTriggers); err != nil {
+			return err
+		}
+		triggers := make([]TriggerInfo, numTriggers)
+		for j := range triggers {
+			if triggers[j].Name, err = readString(buf); err != nil {
+				return err
+			}
+			if triggers[j].SQL, err = readString(buf); err != nil {
+				return err
+			}
+		}
+		if info, ok := c.tables[name]; ok {
+			info.Triggers = triggers
+		}
+	}
+	return nil
+}
+
+// readViews reads the queries of count materialized views, as encodeDDL
+// writes them.
+func (c *Catalog) readViews(buf *bytes.Reader, count int) error {
+	for i := 0; i < count; i++ {
+		name, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		sql, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		if info, ok := c.tables[name]; ok {
+			info.ViewSQL = sql
+		}
+	}
+	return nil
+}
+
+// readRowGroups reads the row groups of count columnar tables, as
+// encodeDDL writes them.
+func (c *Catalog) readRowGroups(buf *bytes.Reader, count int) error {
+	for i := 0; i < count; i++ {
+		name, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		groups, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		if info, ok := c.tables[name]; ok {
+			info.RowGroups = []byte(groups)
+		}
+	}
+	return nil
+}
+
+// readVirtualTables reads count virtual tables, as encodeDDL writes them.
+func (c *Catalog) readVirtualTables(buf *bytes.Reader, count int) error {
+	for i := 0; i < count; i++ {
+		name, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		sql, err := readString(buf)
+		if err != nil {
+			return err
+		}
+		c.virtualTables = append(c.virtualTables, VirtualTableInfo{Name: name, SQL: sql})
+	}
+	return nil
+}
+
+// writeString writes a string preceded by its length.
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+// readString reads a string written by writeString.
+func readString(buf *bytes.Reader) (string, error) {
+	var length uint32
+	if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	if int64(length) > int64(buf.Len()) {
+		return "", fmt.Errorf("string of %d bytes runs past the end of the data", length)
+	}
+	s := make([]byte, length)
+	if _, err := io.ReadFull(buf, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
 // readColumnInfo reads a ColumnInfo from the buffer.
 func (c *Catalog) readColumnInfo(buf *bytes.Reader) (ColumnInfo, error) {
 	col := ColumnInfo{}
@@ -283,8 +1054,38 @@ func (c *Catalog) readColumnInfo(buf *bytes.Reader) (ColumnInfo, error) {
 	if err := binary.Read(buf, binary.LittleEndian, &flags); err != nil {
 		return col, err
 	}
-	col.PrimaryKey = (flags & 0x01) != 0
-	col.NotNull = (flags & 0x02) != 0
+	col.PrimaryKey = (flags & colFlagPrimaryKey) != 0
+	col.NotNull = (flags & colFlagNotNull) != 0
+
+	if flags&colFlagModifiers != 0 {
+		var precision, scale uint16
+		if err := binary.Read(buf, binary.LittleEndian, &precision); err != nil {
+			return col, err
+		}
+		if err := binary.Read(buf, binary.LittleEndian, &scale); err != nil {
+			return col, err
+		}
+		col.Precision = int(precision)
+		col.Scale = int(scale)
+	}
+	if flags&colFlagLength != 0 {
+		var length uint32
+		if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+			return col, err
+		}
+		col.Length = int(length)
+	}
+	if flags&colFlagCollation != 0 {
+		var nameLen uint8
+		if err := binary.Read(buf, binary.LittleEndian, &nameLen); err != nil {
+			return col, err
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(buf, name); err != nil {
+			return col, err
+		}
+		col.Collation = string(name)
+	}
 
 	return col, nil
 }
@@ -301,18 +1102,82 @@ func (c *Catalog) writeColumnInfo(buf *bytes.Buffer, col ColumnInfo) error {
 	// Write flags
 	var flags uint8
 	if col.PrimaryKey {
-		flags |= 0x01
+		flags |= colFlagPrimaryKey
 	}
 	if col.NotNull {
-		flags |= 0x02
+		flags |= colFlagNotNull
+	}
+	if col.Precision > 0 {
+		flags |= colFlagModifiers
+	}
+	if col.Length > 0 {
+		flags |= colFlagLength
+	}
+	if col.Collation != "" {
+		flags |= colFlagCollation
 	}
 	binary.Write(buf, binary.LittleEndian, flags)
 
+	if col.Precision > 0 {
+		binary.Write(buf, binary.LittleEndian, uint16(col.Precision))
+		binary.Write(buf, binary.LittleEndian, uint16(col.Scale))
+	}
+	if col.Length > 0 {
+		binary.Write(buf, binary.LittleEndian, uint32(col.Length))
+	}
+	if col.Collation != "" {
+		buf.WriteByte(uint8(len(col.Collation)))
+		buf.WriteString(col.Collation)
+	}
+
 	return nil
 }
 
-// AddTable registers a new table in the catalog.
-func (c *Catalog) AddTable(name string, tbl *table.Table) error {
+// AddTable registers a new table in the catalog, with the CREATE TABLE
+// statement that created it; an empty sql is made up from its schema.
+func (c *Catalog) AddTable(name string, tbl *table.Table, sql string) error {
+	info, err := c.tableInfo(name, tbl)
+	if err != nil {
+		return err
+	}
+	if sql != "" {
+		info.SQL = sql
+	}
+	c.tables[name] = info
+	return c.saveCatalog()
+}
+
+// UpdateTables refreshes the stored metadata of tables already in the
+// catalog and saves it.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A table's root page, next row ID and data pages change as rows are
+// inserted, but the catalog only learns of them here. If the catalog
+// weren't updated before the pages are flushed, reopening the database
+// would find the new rows' pages but not know they belong to the table.
+func (c *Catalog) UpdateTables(tables map[string]*table.Table) error {
+	for name, tbl := range tables {
+		if _, ok := c.tables[name]; ok {
+			info, err := c.tableInfo(name, tbl)
+			if err != nil {
+				return err
+			}
+			c.tables[name] = info
+		}
+	}
+	return c.saveCatalog()
+}
+
+// Reload discards the in-memory catalog and reads it from the catalog
+// page again, as after a rollback has restored that page.
+func (c *Catalog) Reload() error {
+	c.tables = make(map[string]*TableInfo)
+	return c.loadCatalog()
+}
+
+// tableInfo describes a table's current state for the catalog.
+func (c *Catalog) tableInfo(name string, tbl *table.Table) (*TableInfo, error) {
 	info := &TableInfo{
 		Name:        name,
 		RootPage:    tbl.GetRootPage(),
@@ -320,7 +1185,10 @@ func (c *Catalog) AddTable(name string, tbl *table.Table) error {
 		DataPageIDs: tbl.GetDataPageIDs(),
 		PrimaryKey:  tbl.Schema.PrimaryKey,
 		Columns:     make([]ColumnInfo, len(tbl.Schema.Columns)),
+		Layouts:     tbl.Schema.Layouts,
 	}
+	counts := tbl.Stats()
+	info.Counts = &CountsInfo{RowCount: counts.RowCount, BytesStored: counts.BytesStored}
 
 	for i, col := range tbl.Schema.Columns {
 		info.Columns[i] = ColumnInfo{
@@ -328,19 +1196,157 @@ func (c *Catalog) AddTable(name string, tbl *table.Table) error {
 			Type:       col.Type,
 			PrimaryKey: col.PrimaryKey,
 			NotNull:    col.NotNull,
+			Precision:  col.Precision,
+			Scale:      col.Scale,
+			Length:     col.Length,
+			Collation:  string(col.Collation),
 		}
 	}
 
-	c.tables[name] = info
-	return c.saveCatalog()
+	stats, err := c.statsInfo(name, tbl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save statistics of table %s: %w", name, err)
+	}
+	info.Stats = stats
+	if info.RowGroups, err = tbl.EncodeRowGroups(); err != nil {
+		return nil, fmt.Errorf("failed to save row groups of table %s: %w", name, err)
+	}
+
+	// The statements already recorded are kept; those of new tables and
+	// indexes are made up from their definitions
+	prev, known := c.tables[name]
+	info.SQL = TableSQL(name, tbl.Schema)
+	if known && prev.SQL != "" {
+		info.SQL = prev.SQL
+	}
+	if known {
+		info.Triggers, info.ViewSQL = prev.Triggers, prev.ViewSQL
+	}
+	indexSQL := make(map[string]string)
+	if known {
+		for _, idx := range prev.Indexes {
+			indexSQL[idx.Name] = idx.SQL
+		}
+	}
+	indexes := tbl.ListIndexes()
+	sort.Strings(indexes)
+	for _, indexName := range indexes {
+		idx, ok := tbl.GetIndex(indexName)
+		if !ok {
+			continue
+		}
+		sql, ok := indexSQL[indexName]
+		if !ok {
+			sql = IndexSQL(idx)
+		}
+		info.Indexes = append(info.Indexes, IndexInfo{Name: indexName, RootPage: idx.RootPage(), SQL: sql})
+	}
+	return info, nil
 }
 
-// RemoveTable removes a table from the catalog.
+// statsInfo describes a table's statistics for the catalog, writing the
+// column statistics to a new overflow chain if ANALYZE has run since they
+// were last saved. It returns nil if the table hasn't been analyzed.
+func (c *Catalog) statsInfo(name string, tbl *table.Table) (*StatsInfo, error) {
+	stats := tbl.Stats()
+	if !stats.Analyzed() {
+		return nil, nil
+	}
+	info := &StatsInfo{
+		RowCount:     stats.RowCount,
+		PageCount:    uint32(stats.PageCount),
+		LastAnalyzed: stats.LastAnalyzed.UnixNano(),
+	}
+
+	prev, ok := c.tables[name]
+	if ok && prev.Stats != nil && prev.Stats.LastAnalyzed == info.LastAnalyzed {
+		info.ColumnsPage, info.ColumnsLength = prev.Stats.ColumnsPage, prev.Stats.ColumnsLength
+		return info, nil
+	}
+	data, err := tbl.EncodeColumnStats(stats.Columns)
+	if err != nil {
+		return nil, err
+	}
+	if info.ColumnsPage, err = c.pager.WriteOverflow(data); err != nil {
+		return nil, err
+	}
+	info.ColumnsLength = uint32(len(data))
+	if ok && prev.Stats != nil {
+		if err := c.freeChain(prev.Stats.ColumnsPage, int(prev.Stats.ColumnsLength), "statistics of table "+name); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// RemoveTable removes a table from the catalog, freeing the pages of its
+// statistics.
 func (c *Catalog) RemoveTable(name string) error {
+	if info, ok := c.tables[name]; ok && info.Stats != nil {
+		if err := c.freeChain(info.Stats.ColumnsPage, int(info.Stats.ColumnsLength), "statistics of table "+name); err != nil {
+			return err
+		}
+	}
 	delete(c.tables, name)
 	return c.saveCatalog()
 }
 
+// AddTrigger records a trigger of a table, with the CREATE TRIGGER
+// statement that created it.
+func (c *Catalog) AddTrigger(tableName, name, sql string) error {
+	info, ok := c.tables[tableName]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", tableName)
+	}
+	info.Triggers = append(slices.Clone(info.Triggers), TriggerInfo{Name: name, SQL: sql})
+	return c.saveCatalog()
+}
+
+// SetView records that a table holds the rows of a materialized view,
+// with the CREATE MATERIALIZED VIEW statement that created it.
+func (c *Catalog) SetView(tableName, sql string) error {
+	info, ok := c.tables[tableName]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", tableName)
+	}
+	info.ViewSQL = sql
+	return c.saveCatalog()
+}
+
+// RemoveTrigger removes a trigger of a table from the catalog.
+func (c *Catalog) RemoveTrigger(tableName, name string) error {
+	info, ok := c.tables[tableName]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", tableName)
+	}
+	info.Triggers = slices.DeleteFunc(slices.Clone(info.Triggers), func(t TriggerInfo) bool {
+		return t.Name == name
+	})
+	return c.saveCatalog()
+}
+
+// Sequences returns the sequences as last read or set.
+func (c *Catalog) Sequences() []SequenceInfo {
+	return slices.Clone(c.sequences)
+}
+
+// SetSequences replaces the sequences, which are written with the rest of
+// the catalog the next time it is saved.
+func (c *Catalog) SetSequences(seqs []SequenceInfo) {
+	c.sequences = slices.Clone(seqs)
+}
+
+// VirtualTables returns the virtual tables recorded in the catalog.
+func (c *Catalog) VirtualTables() []VirtualTableInfo {
+	return slices.Clone(c.virtualTables)
+}
+
+// SetVirtualTables replaces the virtual tables, which are written with the
+// rest of the catalog the next time it is saved.
+func (c *Catalog) SetVirtualTables(vts []VirtualTableInfo) {
+	c.virtualTables = slices.Clone(vts)
+}
+
 // GetTableInfo returns info about a table.
 func (c *Catalog) GetTableInfo(name string) (*TableInfo, bool) {
 	info, ok := c.tables[name]
@@ -371,11 +1377,118 @@ func (c *Catalog) LoadTable(name string, pager *storage.Pager) (*table.Table, er
 			Type:       col.Type,
 			PrimaryKey: col.PrimaryKey,
 			NotNull:    col.NotNull,
+			Precision:  col.Precision,
+			Scale:      col.Scale,
+			Length:     col.Length,
+			Collation:  col.Collation,
 		}
 	}
 
 	schema := table.NewSchema(columns)
-	return table.LoadTable(name, schema, pager, info.RootPage, info.NextRowID, info.DataPageIDs), nil
+	if len(schema.PrimaryKeyColumns) > 1 {
+		if err := c.loadPrimaryKey(schema, info); err != nil {
+			return nil, fmt.Errorf("failed to load table %s: %w", name, err)
+		}
+	}
+	if strings.Contains(info.SQL, " DEFAULT ") {
+		if err := c.loadDefaults(schema, info); err != nil {
+			return nil, fmt.Errorf("failed to load table %s: %w", name, err)
+		}
+	}
+	if err := schema.SetLayouts(info.Layouts); err != nil {
+		return nil, fmt.Errorf("failed to load table %s: %w", name, err)
+	}
+	tbl := table.LoadTable(name, schema, pager, info.RootPage, info.NextRowID, info.DataPageIDs)
+	if info.RowGroups != nil {
+		if err := tbl.LoadRowGroups(info.RowGroups); err != nil {
+			return nil, fmt.Errorf("failed to load table %s: %w", name, err)
+		}
+	}
+	if c.rebuildKeys {
+		if err := tbl.RebuildPrimaryKey(); err != nil {
+			return nil, fmt.Errorf("failed to rebuild primary key: %w", err)
+		}
+		info.RootPage = tbl.GetRootPage()
+	}
+	if err := c.loadStats(tbl, info.Stats); err != nil {
+		return nil, fmt.Errorf("failed to load statistics of table %s: %w", name, err)
+	}
+	if info.Counts != nil {
+		tbl.SetCounts(info.Counts.RowCount, info.Counts.BytesStored)
+	} else if err := tbl.Recount(); err != nil {
+		return nil, fmt.Errorf("failed to count rows of table %s: %w", name, err)
+	}
+
+	// An index's definition is read back from its CREATE INDEX statement
+	for _, idx := range info.Indexes {
+		stmt, err := parser.New(lexer.New(idx.SQL)).Parse()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load index %s: %w", idx.Name, err)
+		}
+		create, ok := stmt.(*parser.CreateIndexStatement)
+		if !ok {
+			return nil, fmt.Errorf("failed to load index %s: not a CREATE INDEX statement: %s", idx.Name, idx.SQL)
+		}
+		tbl.AddIndex(storage.LoadIndex(create.IndexName, name, create.Columns, create.Unique, pager, idx.RootPage))
+	}
+	return tbl, nil
+}
+
+// loadPrimaryKey puts the columns of a composite primary key in the order
+// of the PRIMARY KEY constraint of the table's CREATE statement. The column
+// flags only say which columns are in the key, and the order of the key's
+// columns is the order of its B-tree.
+func (c *Catalog) loadPrimaryKey(schema *table.Schema, info *TableInfo) error {
+	stmt, err := parser.New(lexer.New(info.SQL)).Parse()
+	if err != nil {
+		return fmt.Errorf("failed to read primary key: %w", err)
+	}
+	create, ok := stmt.(*parser.CreateTableStatement)
+	if !ok || create.PrimaryKeyColumns == nil {
+		return fmt.Errorf("no composite primary key in CREATE statement: %s", info.SQL)
+	}
+	return schema.SetPrimaryKey(create.PrimaryKeyColumns)
+}
+
+// loadDefaults reads the columns' DEFAULT expressions back from the
+// table's CREATE TABLE statement, the only place they are kept.
+func (c *Catalog) loadDefaults(schema *table.Schema, info *TableInfo) error {
+	stmt, err := parser.New(lexer.New(info.SQL)).Parse()
+	if err != nil {
+		return fmt.Errorf("failed to read column defaults: %w", err)
+	}
+	create, ok := stmt.(*parser.CreateTableStatement)
+	if !ok {
+		return fmt.Errorf("not a CREATE TABLE statement: %s", info.SQL)
+	}
+	for _, col := range create.Columns {
+		if idx, ok := schema.ColumnLookup[col.Name]; ok {
+			schema.Columns[idx].Default = col.Default
+		}
+	}
+	return nil
+}
+
+// loadStats restores a table's statistics from the catalog.
+func (c *Catalog) loadStats(tbl *table.Table, info *StatsInfo) error {
+	if info == nil {
+		return nil
+	}
+	data, err := c.pager.ReadOverflow(info.ColumnsPage, int(info.ColumnsLength))
+	if err != nil {
+		return err
+	}
+	columns, err := tbl.DecodeColumnStats(data)
+	if err != nil {
+		return err
+	}
+	tbl.SetStats(table.TableStats{
+		RowCount:     info.RowCount,
+		PageCount:    int(info.PageCount),
+		LastAnalyzed: time.Unix(0, info.LastAnalyzed),
+		Columns:      columns,
+	})
+	return nil
 }
 
 // Flush ensures all catalog changes are written to disk.
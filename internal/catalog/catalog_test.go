@@ -58,7 +58,7 @@ func TestCatalogAddTable(t *testing.T) {
 	}
 
 	// Add to catalog
-	if err := cat.AddTable("users", tbl); err != nil {
+	if err := cat.AddTable("users", tbl, ""); err != nil {
 		t.Fatalf("Failed to add table: %v", err)
 	}
 
@@ -109,7 +109,7 @@ func TestCatalogPersistence(t *testing.T) {
 			t.Fatalf("Failed to create table: %v", err)
 		}
 
-		if err := cat.AddTable("accounts", tbl); err != nil {
+		if err := cat.AddTable("accounts", tbl, ""); err != nil {
 			t.Fatalf("Failed to add table: %v", err)
 		}
 
@@ -184,7 +184,7 @@ func TestCatalogRemoveTable(t *testing.T) {
 	})
 
 	tbl, _ := table.NewTable("temp", schema, pager)
-	cat.AddTable("temp", tbl)
+	cat.AddTable("temp", tbl, "")
 
 	if len(cat.ListTables()) != 1 {
 		t.Error("Table should exist")
@@ -227,7 +227,7 @@ func TestCatalogLoadTable(t *testing.T) {
 	})
 
 	tbl, _ := table.NewTable("data", schema, pager)
-	cat.AddTable("data", tbl)
+	cat.AddTable("data", tbl, "")
 
 	// Load table from catalog
 	loaded, err := cat.LoadTable("data", pager)
@@ -289,7 +289,7 @@ func TestCatalogDataPageIDsPersistence(t *testing.T) {
 		}
 
 		// Add to catalog
-		if err := cat.AddTable("items", tbl); err != nil {
+		if err := cat.AddTable("items", tbl, ""); err != nil {
 			t.Fatalf("Failed to add table: %v", err)
 		}
 
@@ -378,7 +378,7 @@ func TestCatalogNextRowIDPersistence(t *testing.T) {
 		}
 
 		// Add to catalog (should save nextRowID=6)
-		if err := cat.AddTable("users", tbl); err != nil {
+		if err := cat.AddTable("users", tbl, ""); err != nil {
 			t.Fatalf("Failed to add table: %v", err)
 		}
 
@@ -430,3 +430,350 @@ func TestCatalogNextRowIDPersistence(t *testing.T) {
 		t.Errorf("Expected new row to get rowID 6, got %d", rowID)
 	}
 }
+
+func TestCatalogDecimalModifiersPersistence(t *testing.T) {
+	testFile := "test_catalog_decimal.db"
+	defer os.Remove(testFile)
+
+	func() {
+		pager, err := storage.NewPager(testFile)
+		if err != nil {
+			t.Fatalf("Failed to create pager: %v", err)
+		}
+		defer pager.Close()
+
+		cat, err := NewCatalog(pager)
+		if err != nil {
+			t.Fatalf("Failed to create catalog: %v", err)
+		}
+
+		schema := table.NewSchema([]parser.ColumnDefinition{
+			{Name: "id", Type: parser.TypeInteger, PrimaryKey: true},
+			{Name: "total", Type: parser.TypeDecimal, Precision: 10, Scale: 2, NotNull: true},
+			{Name: "rate", Type: parser.TypeDecimal},
+		})
+
+		tbl, err := table.NewTable("orders", schema, pager)
+		if err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		if err := cat.AddTable("orders", tbl, ""); err != nil {
+			t.Fatalf("Failed to add table: %v", err)
+		}
+		cat.Flush()
+	}()
+
+	pager, err := storage.NewPager(testFile)
+	if err != nil {
+		t.Fatalf("Failed to reopen pager: %v", err)
+	}
+	defer pager.Close()
+
+	cat, err := NewCatalog(pager)
+	if err != nil {
+		t.Fatalf("Failed to reload catalog: %v", err)
+	}
+
+	tbl, err := cat.LoadTable("orders", pager)
+	if err != nil {
+		t.Fatalf("Failed to load table: %v", err)
+	}
+
+	total := tbl.Schema.Columns[1]
+	if total.Type != parser.TypeDecimal || total.Precision != 10 || total.Scale != 2 || !total.NotNull {
+		t.Errorf("Expected total DECIMAL(10,2) NOT NULL, got %+v", total)
+	}
+
+	rate := tbl.Schema.Columns[2]
+	if rate.Type != parser.TypeDecimal || rate.Precision != 0 || rate.Scale != 0 {
+		t.Errorf("Expected rate plain DECIMAL, got %+v", rate)
+	}
+}
+
+func TestCatalogStatsPersistence(t *testing.T) {
+	testFile := "test_catalog_stats.db"
+	defer os.Remove(testFile)
+
+	var want table.TableStats
+	func() {
+		pager, err := storage.NewPager(testFile)
+		if err != nil {
+			t.Fatalf("Failed to create pager: %v", err)
+		}
+		defer pager.Close()
+
+		cat, err := NewCatalog(pager)
+		if err != nil {
+			t.Fatalf("Failed to create catalog: %v", err)
+		}
+
+		schema := table.NewSchema([]parser.ColumnDefinition{
+			{Name: "id", Type: parser.TypeInteger, PrimaryKey: true},
+			{Name: "city", Type: parser.TypeText},
+		})
+		tbl, err := table.NewTable("users", schema, pager)
+		if err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		cities := []string{"Austin", "Boston", "Chicago"}
+		for i := 0; i < 90; i++ {
+			tbl.Insert([]table.Value{
+				{Type: parser.TypeInteger, Integer: int64(i)},
+				{Type: parser.TypeText, Text: cities[i%len(cities)]},
+			})
+		}
+		if err := tbl.Analyze(); err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+		want = tbl.Stats()
+
+		if err := cat.AddTable("users", tbl, ""); err != nil {
+			t.Fatalf("Failed to add table: %v", err)
+		}
+		cat.Flush()
+	}()
+
+	pager, err := storage.NewPager(testFile)
+	if err != nil {
+		t.Fatalf("Failed to reopen pager: %v", err)
+	}
+	defer pager.Close()
+
+	cat, err := NewCatalog(pager)
+	if err != nil {
+		t.Fatalf("Failed to reload catalog: %v", err)
+	}
+	tbl, err := cat.LoadTable("users", pager)
+	if err != nil {
+		t.Fatalf("Failed to load table: %v", err)
+	}
+
+	got := tbl.Stats()
+	if !got.Analyzed() || got.LastAnalyzed.Unix() != want.LastAnalyzed.Unix() || got.RowCount != 90 {
+		t.Errorf("expected analyzed stats for 90 rows, got %+v", got)
+	}
+	city := got.Columns["city"]
+	if city.DistinctValues != 3 || city.Min.Text != "Austin" || city.Max.Text != "Chicago" {
+		t.Errorf("unexpected stats for city: %+v", city)
+	}
+	if len(city.Histogram) != len(want.Columns["city"].Histogram) {
+		t.Errorf("expected %d histogram bounds for city, got %d",
+			len(want.Columns["city"].Histogram), len(city.Histogram))
+	}
+	if id := got.Columns["id"]; id.Max.Integer != 89 || len(id.Histogram) == 0 {
+		t.Errorf("unexpected stats for id: %+v", id)
+	}
+}
+
+func TestCatalogCountsPersistence(t *testing.T) {
+	testFile := "test_catalog_counts.db"
+	defer os.Remove(testFile)
+
+	var want table.TableStats
+	func() {
+		pager, err := storage.NewPager(testFile)
+		if err != nil {
+			t.Fatalf("Failed to create pager: %v", err)
+		}
+		defer pager.Close()
+
+		cat, err := NewCatalog(pager)
+		if err != nil {
+			t.Fatalf("Failed to create catalog: %v", err)
+		}
+
+		schema := table.NewSchema([]parser.ColumnDefinition{
+			{Name: "id", Type: parser.TypeInteger, PrimaryKey: true},
+			{Name: "name", Type: parser.TypeText},
+		})
+		tbl, err := table.NewTable("users", schema, pager)
+		if err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		if err := cat.AddTable("users", tbl, ""); err != nil {
+			t.Fatalf("Failed to add table: %v", err)
+		}
+		for i := 0; i < 40; i++ {
+			tbl.Insert([]table.Value{
+				{Type: parser.TypeInteger, Integer: int64(i)},
+				{Type: parser.TypeText, Text: "user"},
+			})
+		}
+		want = tbl.Stats()
+		if err := cat.UpdateTables(map[string]*table.Table{"users": tbl}); err != nil {
+			t.Fatalf("Failed to update tables: %v", err)
+		}
+		cat.Flush()
+	}()
+
+	pager, err := storage.NewPager(testFile)
+	if err != nil {
+		t.Fatalf("Failed to reopen pager: %v", err)
+	}
+	defer pager.Close()
+
+	cat, err := NewCatalog(pager)
+	if err != nil {
+		t.Fatalf("Failed to reload catalog: %v", err)
+	}
+	tbl, err := cat.LoadTable("users", pager)
+	if err != nil {
+		t.Fatalf("Failed to load table: %v", err)
+	}
+
+	// The counts come back without ANALYZE having run
+	got := tbl.Stats()
+	if got.Analyzed() || got.RowCount != 40 || got.BytesStored != want.BytesStored {
+		t.Errorf("expected 40 rows of %d bytes, got %+v", want.BytesStored, got)
+	}
+}
+
+func TestCatalogDDLPersistence(t *testing.T) {
+	testFile := "test_catalog_ddl.db"
+	defer os.Remove(testFile)
+
+	const createTable = "CREATE TABLE users (id INTEGER PRIMARY KEY, city TEXT NOT NULL)"
+	func() {
+		pager, err := storage.NewPager(testFile)
+		if err != nil {
+			t.Fatalf("Failed to create pager: %v", err)
+		}
+		defer pager.Close()
+
+		cat, err := NewCatalog(pager)
+		if err != nil {
+			t.Fatalf("Failed to create catalog: %v", err)
+		}
+
+		schema := table.NewSchema([]parser.ColumnDefinition{
+			{Name: "id", Type: parser.TypeInteger, PrimaryKey: true},
+			{Name: "city", Type: parser.TypeText, NotNull: true},
+		})
+		tbl, err := table.NewTable("users", schema, pager)
+		if err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		if err := cat.AddTable("users", tbl, createTable); err != nil {
+			t.Fatalf("Failed to add table: %v", err)
+		}
+		cities := []string{"Austin", "Boston", "Chicago"}
+		for i := 0; i < 30; i++ {
+			tbl.Insert([]table.Value{
+				{Type: parser.TypeInteger, Integer: int64(i)},
+				{Type: parser.TypeText, Text: cities[i%len(cities)]},
+			})
+		}
+		if err := tbl.CreateIndex("idx_city", []string{"city"}, false); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		if err := cat.UpdateTables(map[string]*table.Table{"users": tbl}); err != nil {
+			t.Fatalf("Failed to update tables: %v", err)
+		}
+		cat.Flush()
+	}()
+
+	pager, err := storage.NewPager(testFile)
+	if err != nil {
+		t.Fatalf("Failed to reopen pager: %v", err)
+	}
+	defer pager.Close()
+
+	cat, err := NewCatalog(pager)
+	if err != nil {
+		t.Fatalf("Failed to reload catalog: %v", err)
+	}
+	info, _ := cat.GetTableInfo("users")
+	if info.SQL != createTable {
+		t.Errorf("expected %q, got %q", createTable, info.SQL)
+	}
+	if len(info.Indexes) != 1 || info.Indexes[0].SQL != "CREATE INDEX idx_city ON users (city)" {
+		t.Errorf("unexpected indexes %+v", info.Indexes)
+	}
+
+	// The index is loaded with the table, from its statement
+	tbl, err := cat.LoadTable("users", pager)
+	if err != nil {
+		t.Fatalf("Failed to load table: %v", err)
+	}
+	rows, err := tbl.LookupIndex("idx_city", table.Value{Type: parser.TypeText, Text: "Boston"},
+		func(table.Row) bool { return true }, 0)
+	if err != nil {
+		t.Fatalf("Index lookup failed: %v", err)
+	}
+	if len(rows) != 10 {
+		t.Errorf("expected 10 rows in Boston, got %d", len(rows))
+	}
+
+	// A table added without its statement gets one made up from its schema
+	if got := TableSQL("users", tbl.Schema); got != createTable {
+		t.Errorf("expected %q, got %q", createTable, got)
+	}
+}
+
+func TestCatalogRebuildsLittleEndianKeys(t *testing.T) {
+	testFile := "test_catalog_keys.db"
+	defer os.Remove(testFile)
+
+	var oldRoot uint32
+	func() {
+		pager, err := storage.NewPager(testFile)
+		if err != nil {
+			t.Fatalf("Failed to create pager: %v", err)
+		}
+		defer pager.Close()
+
+		cat, err := NewCatalog(pager)
+		if err != nil {
+			t.Fatalf("Failed to create catalog: %v", err)
+		}
+		schema := table.NewSchema([]parser.ColumnDefinition{
+			{Name: "id", Type: parser.TypeInteger, PrimaryKey: true},
+		})
+		tbl, err := table.NewTable("items", schema, pager)
+		if err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		for i := -5; i <= 5; i++ {
+			if _, err := tbl.Insert([]table.Value{{Type: parser.TypeInteger, Integer: int64(i)}}); err != nil {
+				t.Fatalf("Failed to insert row: %v", err)
+			}
+		}
+		if err := cat.AddTable("items", tbl, ""); err != nil {
+			t.Fatalf("Failed to add table: %v", err)
+		}
+		oldRoot = tbl.GetRootPage()
+
+		// Mark the catalog as written before order-preserving keys
+		page, _ := pager.GetPage(CatalogPageID)
+		data := append([]byte(nil), page.GetData()...)
+		data[0], data[1] = 0xB0, 0xCD
+		page.SetData(data)
+		cat.Flush()
+	}()
+
+	pager, err := storage.NewPager(testFile)
+	if err != nil {
+		t.Fatalf("Failed to reopen pager: %v", err)
+	}
+	defer pager.Close()
+
+	cat, err := NewCatalog(pager)
+	if err != nil {
+		t.Fatalf("Failed to reload catalog: %v", err)
+	}
+	tbl, err := cat.LoadTable("items", pager)
+	if err != nil {
+		t.Fatalf("Failed to load table: %v", err)
+	}
+	if tbl.GetRootPage() == oldRoot {
+		t.Error("expected the primary key to be rebuilt")
+	}
+	if info, _ := cat.GetTableInfo("items"); info.RootPage != tbl.GetRootPage() {
+		t.Errorf("catalog root page = %d, expected %d", info.RootPage, tbl.GetRootPage())
+	}
+	for i := -5; i <= 5; i++ {
+		if _, found, err := tbl.GetRowByPrimaryKey(table.Value{Type: parser.TypeInteger, Integer: int64(i)}); err != nil || !found {
+			t.Errorf("row %d not found after rebuild (err=%v)", i, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// tryAcquire attempts a lock without waiting more than a moment.
+func tryAcquire(m *Manager, owner Owner, row Row, mode Mode) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	return m.Acquire(ctx, owner, row, mode)
+}
+
+func TestLockCompatibility(t *testing.T) {
+	row := Row{Table: "users", RowID: 1}
+
+	tests := []struct {
+		name       string
+		held       Mode
+		want       Mode
+		compatible bool
+	}{
+		{"shared with shared", Shared, Shared, true},
+		{"shared with exclusive", Shared, Exclusive, false},
+		{"exclusive with shared", Exclusive, Shared, false},
+		{"exclusive with exclusive", Exclusive, Exclusive, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager()
+			a, b := m.NewOwner(), m.NewOwner()
+			if err := tryAcquire(m, a, row, tt.held); err != nil {
+				t.Fatalf("first lock failed: %v", err)
+			}
+			err := tryAcquire(m, b, row, tt.want)
+			if tt.compatible && err != nil {
+				t.Errorf("expected lock to be granted, got %v", err)
+			}
+			if !tt.compatible && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected lock to wait, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLockDifferentRowsDoNotConflict(t *testing.T) {
+	m := NewManager()
+	a, b := m.NewOwner(), m.NewOwner()
+
+	if err := tryAcquire(m, a, Row{Table: "users", RowID: 1}, Exclusive); err != nil {
+		t.Fatal(err)
+	}
+	if err := tryAcquire(m, b, Row{Table: "users", RowID: 2}, Exclusive); err != nil {
+		t.Errorf("lock on another row should not wait: %v", err)
+	}
+	if err := tryAcquire(m, b, Row{Table: "orders", RowID: 1}, Exclusive); err != nil {
+		t.Errorf("lock on another table's row should not wait: %v", err)
+	}
+}
+
+func TestLockReacquireAndUpgrade(t *testing.T) {
+	m := NewManager()
+	a, b := m.NewOwner(), m.NewOwner()
+	row := Row{Table: "users", RowID: 1}
+
+	for _, mode := range []Mode{Shared, Shared, Exclusive, Shared} {
+		if err := tryAcquire(m, a, row, mode); err != nil {
+			t.Fatalf("re-acquiring %s failed: %v", mode, err)
+		}
+	}
+	if got := m.Held(a)[row]; got != Exclusive {
+		t.Errorf("expected upgraded lock to stay exclusive, got %s", got)
+	}
+
+	// An upgrade must wait while another owner shares the row
+	shared := Row{Table: "users", RowID: 2}
+	tryAcquire(m, a, shared, Shared)
+	tryAcquire(m, b, shared, Shared)
+	if err := tryAcquire(m, a, shared, Exclusive); err == nil {
+		t.Error("expected upgrade to wait for the other shared holder")
+	}
+}
+
+func TestReleaseAllWakesWaiter(t *testing.T) {
+	m := NewManager()
+	a, b := m.NewOwner(), m.NewOwner()
+	row := Row{Table: "users", RowID: 1}
+
+	if err := tryAcquire(m, a, row, Exclusive); err != nil {
+		t.Fatal(err)
+	}
+
+	granted := make(chan error, 1)
+	go func() {
+		granted <- m.Acquire(context.Background(), b, row, Exclusive)
+	}()
+
+	select {
+	case err := <-granted:
+		t.Fatalf("lock granted while still held: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	m.ReleaseAll(a)
+	select {
+	case err := <-granted:
+		if err != nil {
+			t.Fatalf("waiter failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken by ReleaseAll")
+	}
+
+	if len(m.Held(a)) != 0 {
+		t.Errorf("expected no locks after ReleaseAll, got %v", m.Held(a))
+	}
+	if got := m.Held(b)[row]; got != Exclusive {
+		t.Errorf("expected waiter to hold the lock, got %v", m.Held(b))
+	}
+}
+
+func TestDeadlockDetected(t *testing.T) {
+	m := NewManager()
+	a, b := m.NewOwner(), m.NewOwner()
+	row1 := Row{Table: "accounts", RowID: 1}
+	row2 := Row{Table: "accounts", RowID: 2}
+
+	tryAcquire(m, a, row1, Exclusive)
+	tryAcquire(m, b, row2, Exclusive)
+
+	// b waits for a
+	granted := make(chan error, 1)
+	go func() {
+		granted <- m.Acquire(context.Background(), b, row1, Exclusive)
+	}()
+	waitUntilWaiting(t, m, b)
+
+	// a waiting for b would close the cycle
+	err := m.Acquire(context.Background(), a, row2, Exclusive)
+	if !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("expected ErrDeadlock, got %v", err)
+	}
+
+	// Aborting the victim lets the other transaction finish
+	m.ReleaseAll(a)
+	select {
+	case err := <-granted:
+		if err != nil {
+			t.Fatalf("surviving transaction failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("surviving transaction still waiting after victim released its locks")
+	}
+}
+
+func TestDeadlockThreeWayAndSharedUpgrade(t *testing.T) {
+	t.Run("three transactions", func(t *testing.T) {
+		m := NewManager()
+		a, b, c := m.NewOwner(), m.NewOwner(), m.NewOwner()
+		rows := []Row{{Table: "t", RowID: 1}, {Table: "t", RowID: 2}, {Table: "t", RowID: 3}}
+		tryAcquire(m, a, rows[0], Exclusive)
+		tryAcquire(m, b, rows[1], Exclusive)
+		tryAcquire(m, c, rows[2], Exclusive)
+
+		go m.Acquire(context.Background(), a, rows[1], Exclusive) // a -> b
+		waitUntilWaiting(t, m, a)
+		go m.Acquire(context.Background(), b, rows[2], Exclusive) // b -> c
+		waitUntilWaiting(t, m, b)
+
+		if err := tryAcquire(m, c, rows[0], Exclusive); !errors.Is(err, ErrDeadlock) {
+			t.Errorf("expected c -> a to close the cycle, got %v", err)
+		}
+		m.ReleaseAll(c)
+		m.ReleaseAll(b)
+		m.ReleaseAll(a)
+	})
+
+	t.Run("two shared holders upgrading", func(t *testing.T) {
+		m := NewManager()
+		a, b := m.NewOwner(), m.NewOwner()
+		row := Row{Table: "t", RowID: 1}
+		tryAcquire(m, a, row, Shared)
+		tryAcquire(m, b, row, Shared)
+
+		go m.Acquire(context.Background(), a, row, Exclusive)
+		waitUntilWaiting(t, m, a)
+		if err := tryAcquire(m, b, row, Exclusive); !errors.Is(err, ErrDeadlock) {
+			t.Errorf("expected upgrade deadlock, got %v", err)
+		}
+		m.ReleaseAll(b)
+		m.ReleaseAll(a)
+	})
+}
+
+func TestNoDeadlockForPlainWait(t *testing.T) {
+	m := NewManager()
+	a, b := m.NewOwner(), m.NewOwner()
+	row := Row{Table: "t", RowID: 1}
+	tryAcquire(m, a, row, Exclusive)
+
+	if err := tryAcquire(m, b, row, Exclusive); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a plain wait to time out, got %v", err)
+	}
+}
+
+// waitUntilWaiting blocks until owner is registered as waiting for a lock.
+func waitUntilWaiting(t *testing.T, m *Manager, owner Owner) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, waiting := m.Waiting(owner); waiting {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("owner %d never started waiting", owner)
+}
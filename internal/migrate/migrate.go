@@ -0,0 +1,277 @@
+// Package migrate applies schema migrations: numbered SQL scripts that
+// take a database's schema from one version to the next.
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// An application's schema changes as the application does, and every copy
+// of its database (each developer's, the test server's, production) has to
+// go through the same changes in the same order. Rather than remembering
+// which ALTERs were run where, the changes are written down as files,
+// numbered in the order they were written:
+//
+//   migrations/
+//     0001_create_users.up.sql      CREATE TABLE users (...);
+//     0001_create_users.down.sql    DROP TABLE users;
+//     0002_index_emails.up.sql      CREATE INDEX idx_email ON users (email);
+//     0002_index_emails.down.sql    DROP INDEX idx_email;
+//
+// and the database itself remembers which it has been through, in a
+// table of its own:
+//
+//   schema_migrations (version INTEGER PRIMARY KEY, name TEXT,
+//                      applied_at TIMESTAMP)
+//
+// Up applies, in order, every migration not in that table; Down reverts
+// the latest ones with their .down.sql scripts. Each migration runs in a
+// transaction along with the row recording it, so a failing migration
+// leaves neither half its changes nor a record claiming it ran; the
+// scripts must therefore not use BEGIN or COMMIT themselves.
+//
+// This is the scheme of Rails' migrations, Flyway and golang-migrate,
+// whose file names this package follows.
+
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/executor"
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// Table is the table recording the migrations applied to a database.
+const Table = "schema_migrations"
+
+// Migration is one step of a schema's history.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string // SQL applying the migration
+	Down    string // SQL reverting it; empty if it has no .down.sql file
+}
+
+// fileName matches the name of a migration file: the version, a name and
+// the direction, such as 0001_create_users.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the top directory of fsys, in the order of
+// their versions. Files not named like a migration are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil || entry.IsDir() {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		sql, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration: %w", err)
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrations %s and %s have the same version %d", mig.Name, m[2], version)
+		}
+		if m[3] == "up" {
+			mig.Up = string(sql)
+		} else {
+			mig.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no .up.sql file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to the database of an executor.
+type Migrator struct {
+	exec       *executor.Executor
+	migrations []Migration
+}
+
+// New creates a Migrator applying migrations, as returned by Load, with
+// exec.
+func New(exec *executor.Executor, migrations []Migration) *Migrator {
+	return &Migrator{exec: exec, migrations: migrations}
+}
+
+// Applied returns the versions of the migrations applied to the database,
+// in order.
+func (m *Migrator) Applied() ([]int64, error) {
+	if _, ok := m.exec.GetTable(Table); !ok {
+		return nil, nil
+	}
+	result, err := m.run("SELECT version FROM " + Table + " ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, len(result.Rows))
+	for i, row := range result.Rows {
+		versions[i] = row[0].Integer
+	}
+	return versions, nil
+}
+
+// Pending returns the migrations not applied to the database yet, in the
+// order Up would apply them.
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.appliedSet()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration, in order, and returns those it
+// applied. It stops at the first that fails, which is rolled back.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		if err := m.ensureTable(); err != nil {
+			return nil, err
+		}
+	}
+
+	var done []Migration
+	for _, mig := range pending {
+		err := m.apply(mig, mig.Up, "INSERT INTO "+Table+" VALUES (?, ?, NOW())",
+			table.Value{Type: parser.TypeInteger, Integer: mig.Version},
+			table.Value{Type: parser.TypeText, Text: mig.Name})
+		if err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps migrations applied, latest first, and
+// returns those it reverted. It stops at the first that fails, which is
+// rolled back.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+
+	var done []Migration
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		mig, ok := byVersion[applied[i]]
+		if !ok {
+			return done, fmt.Errorf("migration %d is applied but has no file", applied[i])
+		}
+		if mig.Down == "" {
+			return done, fmt.Errorf("migration %d_%s has no .down.sql file", mig.Version, mig.Name)
+		}
+		err := m.apply(mig, mig.Down, "DELETE FROM "+Table+" WHERE version = ?",
+			table.Value{Type: parser.TypeInteger, Integer: mig.Version})
+		if err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// appliedSet returns the versions of the applied migrations as a set.
+func (m *Migrator) appliedSet() (map[int64]bool, error) {
+	versions, err := m.Applied()
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		set[v] = true
+	}
+	return set, nil
+}
+
+// ensureTable creates the table recording the migrations, if it doesn't
+// exist yet.
+func (m *Migrator) ensureTable() error {
+	if _, ok := m.exec.GetTable(Table); ok {
+		return nil
+	}
+	_, err := m.run("CREATE TABLE " + Table + " (version INTEGER PRIMARY KEY, name TEXT, applied_at TIMESTAMP)")
+	return err
+}
+
+// apply runs script and the statement record in one transaction, then
+// writes the changes to disk.
+func (m *Migrator) apply(mig Migration, script, record string, args ...table.Value) error {
+	if m.exec.InTransaction() {
+		return fmt.Errorf("cannot migrate inside a transaction")
+	}
+	if _, err := m.run("BEGIN"); err != nil {
+		return err
+	}
+	err := func() error {
+		for _, sql := range parser.SplitStatements(script) {
+			if _, err := m.run(sql); err != nil {
+				return err
+			}
+		}
+		_, err := m.run(record, args...)
+		return err
+	}()
+	if err != nil {
+		if _, rbErr := m.run("ROLLBACK"); rbErr != nil {
+			err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := m.run("COMMIT"); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+	return m.exec.Flush()
+}
+
+// run parses and executes one statement.
+func (m *Migrator) run(sql string, args ...table.Value) (*executor.Result, error) {
+	stmt, err := parser.New(lexer.New(sql)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return nil, fmt.Errorf("could not parse statement: %s", sql)
+	}
+	return m.exec.ExecuteWithParams(stmt, args)
+}
@@ -0,0 +1,142 @@
+package migrate
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/executor"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+func newTestExecutor(t *testing.T) *executor.Executor {
+	pager, err := storage.NewPager(filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatalf("Failed to create pager: %v", err)
+	}
+	t.Cleanup(func() { pager.Close() })
+	return executor.New(pager)
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_users.up.sql": {Data: []byte(`
+			-- The users; a semicolon in a comment doesn't end a statement
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			INSERT INTO users VALUES (1, 'it''s; fine');
+		`)},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"0002_add_orders.up.sql":     {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER)")},
+		"0002_add_orders.down.sql":   {Data: []byte("DROP TABLE orders")},
+		"0010_index_orders.up.sql":   {Data: []byte("CREATE INDEX idx_user ON orders (user_id);")},
+		"README.md":                  {Data: []byte("not a migration")},
+	}
+}
+
+func versions(migrations []Migration) []int64 {
+	var v []int64
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testMigrations())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := versions(migrations); !reflect.DeepEqual(got, []int64{1, 2, 10}) {
+		t.Fatalf("expected versions 1, 2, 10, got %v", got)
+	}
+	if migrations[0].Name != "create_users" || migrations[1].Down != "DROP TABLE orders" || migrations[2].Down != "" {
+		t.Errorf("unexpected migrations %+v", migrations)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"down without up":   {"0001_a.down.sql": {Data: []byte("DROP TABLE a")}},
+		"duplicate version": {"0001_a.up.sql": {Data: []byte("x")}, "0001_b.up.sql": {Data: []byte("y")}},
+	} {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	exec := newTestExecutor(t)
+	migrations, err := Load(testMigrations())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	m := New(exec, migrations)
+
+	done, err := m.Up()
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1, 2, 10}) {
+		t.Fatalf("expected to apply 1, 2, 10, got %v", got)
+	}
+	if applied, _ := m.Applied(); !reflect.DeepEqual(applied, []int64{1, 2, 10}) {
+		t.Errorf("expected 1, 2, 10 applied, got %v", applied)
+	}
+	tbl, ok := exec.GetTable("users")
+	if !ok || tbl.Stats().RowCount != 1 {
+		t.Fatalf("expected users with one row")
+	}
+
+	// Nothing is left to apply
+	if done, err := m.Up(); err != nil || len(done) != 0 {
+		t.Errorf("expected nothing to apply, got %v, %v", versions(done), err)
+	}
+
+	// 10 has no down migration, so reverting stops there
+	if _, err := m.Down(1); err == nil || !strings.Contains(err.Error(), "no .down.sql") {
+		t.Errorf("expected an error for a missing down migration, got %v", err)
+	}
+
+	// Without it, the two others can be reverted, latest first
+	m = New(exec, append(migrations[:2:2], Migration{Version: 10, Name: "index_orders",
+		Up: migrations[2].Up, Down: "DROP INDEX idx_user"}))
+	done, err = m.Down(2)
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{10, 2}) {
+		t.Errorf("expected to revert 10, 2, got %v", got)
+	}
+	if _, ok := exec.GetTable("orders"); ok {
+		t.Error("expected orders to be dropped")
+	}
+	if pending, _ := m.Pending(); !reflect.DeepEqual(versions(pending), []int64{2, 10}) {
+		t.Errorf("expected 2 and 10 pending, got %v", versions(pending))
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	exec := newTestExecutor(t)
+	m := New(exec, []Migration{
+		{Version: 1, Name: "ok", Up: "CREATE TABLE a (id INTEGER PRIMARY KEY)"},
+		{Version: 2, Name: "broken", Up: "CREATE TABLE b (id INTEGER PRIMARY KEY); INSERT INTO missing VALUES (1)"},
+	})
+
+	done, err := m.Up()
+	if err == nil || !strings.Contains(err.Error(), "migration 2_broken failed") {
+		t.Fatalf("expected migration 2 to fail, got %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("expected only 1 applied, got %v", got)
+	}
+	if _, ok := exec.GetTable("b"); ok {
+		t.Error("expected the failed migration's table to be rolled back")
+	}
+	if applied, _ := m.Applied(); !reflect.DeepEqual(applied, []int64{1}) {
+		t.Errorf("expected 1 recorded, got %v", applied)
+	}
+	if exec.InTransaction() {
+		t.Error("expected no transaction left open")
+	}
+}
@@ -0,0 +1,598 @@
+// Package executor - Aggregation
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// An aggregate function computes one value from many rows:
+//
+//   SELECT city, COUNT(*), AVG(age) FROM users GROUP BY city
+//
+// GROUP BY splits the rows matching WHERE into groups with equal values in
+// the listed columns, and the select list is computed once per group.
+// Without GROUP BY, an aggregate makes the whole table one group. After
+// grouping, a row is a group, so the select list may only use the GROUP
+// BY columns and aggregates: "name" above would have to pick one name out
+// of the city's rows.
+//
+// There are two ways to find the groups:
+//
+//   - Hash aggregate: keep a hash table from group key to the group's
+//     running totals, and add each row to its group's. Any input works,
+//     but the table holds every group until the input ends.
+//
+//   - Streaming aggregate: if the rows arrive ordered by the group key,
+//     each group's rows come together. Add rows to the current group, and
+//     when the key changes, return it and start the next one. Only one
+//     group is held, and each is returned as soon as it is complete.
+//
+// Rows come ordered for free when they are read through an index on the
+// grouped column: a range of the primary key, or of a secondary index. So
+// the executor streams when the access path already orders the rows, and
+// otherwise hashes, unless there are so many groups (say, grouping by the
+// primary key) that reading the table in primary key order to stream them
+// costs less than the hash table would. PostgreSQL chooses between its
+// HashAggregate and GroupAggregate the same way, and can also sort the
+// input to stream it.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/sql/planner"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// aggregateFunctions are the functions computed over the rows of a group.
+var aggregateFunctions = map[string]bool{
+	"COUNT": true,
+	"SUM":   true,
+	"AVG":   true,
+	"MIN":   true,
+	"MAX":   true,
+}
+
+// AggregateMethod is how the rows of each group are found.
+type AggregateMethod int
+
+const (
+	// HashAggregate keeps the groups in a hash table by key.
+	HashAggregate AggregateMethod = iota
+	// StreamAggregate reads rows ordered by group, one group at a time.
+	StreamAggregate
+)
+
+func (m AggregateMethod) String() string {
+	if m == StreamAggregate {
+		return "Stream Aggregate"
+	}
+	return "Hash Aggregate"
+}
+
+// aggregatePlan is how a SELECT with GROUP BY or aggregates runs.
+type aggregatePlan struct {
+	groupBy []int                  // the group columns, as positions in the input
+	calls   []*parser.FunctionCall // the aggregates computed for each group
+	input   *table.Schema
+	schema  *table.Schema // the group columns, then the aggregates
+	method  AggregateMethod
+	groups  float64 // estimated number of groups
+}
+
+// needsAggregate reports whether a SELECT groups its rows.
+func needsAggregate(stmt *parser.SelectStatement) bool {
+	if len(stmt.GroupBy) > 0 {
+		return true
+	}
+	found := false
+	for _, expr := range stmt.Columns {
+		parser.WalkExpression(expr, func(ex parser.Expression) bool {
+			if call, ok := ex.(*parser.FunctionCall); ok && aggregateFunctions[call.Name] {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// planAggregate resolves the GROUP BY columns and aggregates of a SELECT
+// reading rows of the input schema, and checks that the select list only
+// uses them.
+func planAggregate(stmt *parser.SelectStatement, input *table.Schema) (*aggregatePlan, error) {
+	if stmt.ForUpdate {
+		return nil, fmt.Errorf("FOR UPDATE is not allowed with GROUP BY or aggregate functions")
+	}
+
+	agg := &aggregatePlan{
+		input:  input,
+		schema: &table.Schema{PrimaryKey: -1, ColumnLookup: make(map[string]int)},
+		method: HashAggregate,
+	}
+	for _, name := range stmt.GroupBy {
+		idx, found := input.GetColumnIndex(name)
+		if !found {
+			return nil, fmt.Errorf("unknown column in GROUP BY: %s", name)
+		}
+		agg.groupBy = append(agg.groupBy, idx)
+		col := input.Columns[idx]
+		agg.schema.ColumnLookup[name] = len(agg.schema.Columns)
+		// A qualified column can also be named alone, users.city -> city
+		agg.schema.ColumnLookup[name[strings.LastIndex(name, ".")+1:]] = len(agg.schema.Columns)
+		agg.schema.Columns = append(agg.schema.Columns, col)
+	}
+
+	for _, expr := range stmt.Columns {
+		if _, ok := expr.(*parser.StarExpression); ok {
+			return nil, fmt.Errorf("SELECT * is not allowed with GROUP BY or aggregate functions")
+		}
+		if err := agg.addCalls(expr, input); err != nil {
+			return nil, err
+		}
+	}
+
+	// An alias of a group column or aggregate can name it in ORDER BY
+	for _, expr := range stmt.Columns {
+		if alias, ok := expr.(*parser.AliasExpression); ok {
+			if idx, found := agg.schema.GetColumnIndex(alias.Expr.String()); found {
+				agg.schema.ColumnLookup[alias.Alias] = idx
+			}
+		}
+	}
+	for _, clause := range stmt.OrderBy {
+		if _, found := agg.schema.GetColumnIndex(clause.Column); !found {
+			return nil, fmt.Errorf("column %s must appear in GROUP BY or be used in an aggregate function", clause.Column)
+		}
+	}
+	return agg, nil
+}
+
+// addCalls adds the aggregates used in expr, checking that any column it
+// uses outside of them is a group column.
+func (agg *aggregatePlan) addCalls(expr parser.Expression, input *table.Schema) error {
+	var err error
+	parser.WalkExpression(expr, func(ex parser.Expression) bool {
+		if err != nil {
+			return false
+		}
+		switch ex := ex.(type) {
+		case *parser.Identifier:
+			if _, found := agg.schema.GetColumnIndex(ex.Name); !found {
+				err = fmt.Errorf("column %s must appear in GROUP BY or be used in an aggregate function", ex.Name)
+			}
+		case *parser.FunctionCall:
+			if !aggregateFunctions[ex.Name] {
+				return true
+			}
+			err = agg.addCall(ex, input)
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// addCall checks an aggregate call and adds it to the plan, once however
+// often it appears.
+func (agg *aggregatePlan) addCall(call *parser.FunctionCall, input *table.Schema) error {
+	if len(call.Args) != 1 {
+		return fmt.Errorf("%s requires 1 argument, got %d", call.Name, len(call.Args))
+	}
+	if _, star := call.Args[0].(*parser.StarExpression); star && call.Name != "COUNT" {
+		return fmt.Errorf("%s(*) is not allowed; only COUNT(*)", call.Name)
+	}
+
+	var err error
+	parser.WalkExpression(call.Args[0], func(ex parser.Expression) bool {
+		switch ex := ex.(type) {
+		case *parser.FunctionCall:
+			if aggregateFunctions[ex.Name] && err == nil {
+				err = fmt.Errorf("aggregate function calls cannot be nested")
+			}
+		case *parser.Identifier:
+			if _, found := input.GetColumnIndex(ex.Name); !found && err == nil {
+				err = fmt.Errorf("unknown column: %s", ex.Name)
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	name := call.String()
+	if _, exists := agg.schema.ColumnLookup[name]; !exists {
+		agg.schema.ColumnLookup[name] = len(agg.schema.Columns)
+		agg.schema.Columns = append(agg.schema.Columns, table.Column{Name: name})
+		agg.calls = append(agg.calls, call)
+	}
+	return nil
+}
+
+// detail describes the aggregation for plan output.
+func (agg *aggregatePlan) detail() string {
+	var parts []string
+	if len(agg.groupBy) > 0 {
+		names := make([]string, len(agg.groupBy))
+		for i := range agg.groupBy {
+			names[i] = agg.schema.Columns[i].Name
+		}
+		parts = append(parts, "by "+strings.Join(names, ", "))
+	}
+	for _, call := range agg.calls {
+		parts = append(parts, call.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// chooseAggregate decides how to aggregate the rows of tbl that the access
+// plan reads, from how they are ordered and how many groups there are
+// likely to be. It returns true if the table should be read in primary
+// key order instead, to stream the groups.
+func (agg *aggregatePlan) chooseAggregate(tbl *table.Table, plan *QueryPlan) bool {
+	stats := tbl.Stats()
+	names := make([]string, len(agg.groupBy))
+	for i, idx := range agg.groupBy {
+		names[i] = tbl.Schema.Columns[idx].Name
+	}
+	agg.groups = planner.EstimateGroups(&stats, names)
+
+	// Without GROUP BY there is only one group
+	if len(agg.groupBy) == 0 {
+		agg.groups, agg.method = 1, StreamAggregate
+		return false
+	}
+
+	// Rows ordered by the only group column come grouped; so do rows
+	// ordered by a primary key among the group columns, each its own group
+	pk := tbl.Schema.PrimaryKey
+	groupedBy := func(col int) bool {
+		if len(agg.groupBy) == 1 {
+			return agg.groupBy[0] == col
+		}
+		for _, idx := range agg.groupBy {
+			if idx == col && col == pk {
+				return true
+			}
+		}
+		return false
+	}
+	if col, ok := orderedColumn(plan, tbl); ok && groupedBy(col) {
+		agg.method = StreamAggregate
+		return false
+	}
+
+	// Reading the whole table in primary key order costs a descent of the
+	// B-tree more than a scan; it pays off when the hash table would hold
+	// many groups
+	if plan.Type != PlanTableScan || plan.FullScan || pk < 0 || !groupedBy(pk) {
+		return false
+	}
+	rows := float64(stats.RowCount)
+	hashCost := planner.TableScanCost(&stats) + planner.AggregateCost(rows, agg.groups, true)
+	streamCost := planner.IndexScanCost(&stats, rows, false) + planner.AggregateCost(rows, agg.groups, false)
+	if streamCost < hashCost {
+		agg.method = StreamAggregate
+		return true
+	}
+	return false
+}
+
+// chooseJoined decides how to aggregate the rows of a join, which come in
+// no useful order: by hashing, unless there is just one group.
+func (agg *aggregatePlan) chooseJoined(rows float64) {
+	if len(agg.groupBy) == 0 {
+		agg.groups, agg.method = 1, StreamAggregate
+		return
+	}
+	names := make([]string, len(agg.groupBy))
+	for i := range agg.groupBy {
+		names[i] = agg.schema.Columns[i].Name
+	}
+	// Without statistics of the joined rows, the estimate is a guess
+	stats := table.TableStats{RowCount: int64(rows)}
+	agg.groups, agg.method = planner.EstimateGroups(&stats, names), HashAggregate
+}
+
+// orderedColumn returns the column the rows an access plan reads are
+// ordered by, if any: the column of the index it reads.
+func orderedColumn(plan *QueryPlan, tbl *table.Table) (int, bool) {
+	switch plan.Type {
+	case PlanIndexScan, PlanIndexRangeScan:
+		if plan.Index == nil {
+			return tbl.Schema.PrimaryKey, tbl.Schema.PrimaryKey >= 0
+		}
+		if len(plan.Index.Columns) == 1 {
+			return tbl.Schema.GetColumnIndex(plan.Index.Columns[0])
+		}
+	}
+	return 0, false
+}
+
+// aggregateAccessNode is the plan's read of tbl when it is read in
+// primary key order to stream the groups.
+func aggregateAccessNode(tbl *table.Table) *PlanNode {
+	stats := tbl.Stats()
+	rows := float64(stats.RowCount)
+	return &PlanNode{
+		Operator: "Index Scan",
+		Detail:   tbl.Name + " using primary key",
+		Estimate: &PlanEstimate{Rows: rows, Cost: planner.IndexScanCost(&stats, rows, false)},
+	}
+}
+
+// aggregateNode adds the aggregation to an estimated plan.
+func aggregateNode(input *PlanNode, agg *aggregatePlan) *PlanNode {
+	node := pipe(input, agg.method.String(), agg.detail(), agg.groups)
+	node.Estimate.Cost += planner.AggregateCost(input.Estimate.Rows, agg.groups, agg.method == HashAggregate)
+	return node
+}
+
+// accumulator computes one aggregate over the rows of a group.
+type accumulator struct {
+	call  *parser.FunctionCall
+	count int64       // rows counted: all of them for COUNT(*), else non-NULL values
+	value table.Value // the running SUM, MIN or MAX
+}
+
+// add adds a row of the group.
+func (e *Executor) accumulate(acc *accumulator, row table.Row, schema *table.Schema) error {
+	if _, star := acc.call.Args[0].(*parser.StarExpression); star {
+		acc.count++
+		return nil
+	}
+	val, err := e.evaluateExpression(acc.call.Args[0], row, schema)
+	if err != nil {
+		return err
+	}
+	if val.IsNull {
+		return nil // aggregates skip NULLs
+	}
+	acc.count++
+
+	switch acc.call.Name {
+	case "SUM", "AVG":
+		switch val.Type {
+		case parser.TypeInteger, parser.TypeReal, parser.TypeDecimal:
+		default:
+			return fmt.Errorf("%s requires numbers, got %s", acc.call.Name, val.Type)
+		}
+		if acc.count == 1 {
+			acc.value = val
+		} else if acc.value, err = e.evaluateBinaryOp(parser.OpAdd, acc.value, val); err != nil {
+			return fmt.Errorf("%s: %w", acc.call.Name, err)
+		}
+	case "MIN":
+		if acc.count == 1 || val.Compare(acc.value) < 0 {
+			acc.value = val
+		}
+	case "MAX":
+		if acc.count == 1 || val.Compare(acc.value) > 0 {
+			acc.value = val
+		}
+	}
+	return nil
+}
+
+// result returns the aggregate's value. Over no values, COUNT is 0 and the
+// others are NULL.
+func (acc *accumulator) result() (table.Value, error) {
+	switch {
+	case acc.call.Name == "COUNT":
+		return table.Value{Type: parser.TypeInteger, Integer: acc.count}, nil
+	case acc.count == 0:
+		return table.Value{IsNull: true}, nil
+	case acc.call.Name == "AVG":
+		sum, err := table.Cast(acc.value, parser.TypeReal)
+		if err != nil {
+			return table.Value{}, err
+		}
+		return table.Value{Type: parser.TypeReal, Real: sum.Real / float64(acc.count)}, nil
+	default:
+		return acc.value, nil
+	}
+}
+
+// group is the running state of one group: its key columns and aggregates.
+type group struct {
+	key  []table.Value
+	accs []accumulator
+}
+
+// newGroup starts a group for the row's key.
+func (agg *aggregatePlan) newGroup(row table.Row) *group {
+	g := &group{key: make([]table.Value, len(agg.groupBy)), accs: make([]accumulator, len(agg.calls))}
+	for i, idx := range agg.groupBy {
+		g.key[i] = row.Values[idx]
+	}
+	for i, call := range agg.calls {
+		g.accs[i].call = call
+	}
+	return g
+}
+
+// memory estimates the memory the group takes in a hash table by key.
+func (g *group) memory(key string) int {
+	return hashEntryMemory + len(key) + rowMemory(table.Row{Values: g.key}) + len(g.accs)*valueMemory
+}
+
+// add adds a row to the group.
+func (e *Executor) addToGroup(g *group, row table.Row, schema *table.Schema) error {
+	for i := range g.accs {
+		if err := e.accumulate(&g.accs[i], row, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// row returns the group's output row: its key, then its aggregates.
+func (g *group) row() (table.Row, error) {
+	values := append([]table.Value(nil), g.key...)
+	for i := range g.accs {
+		val, err := g.accs[i].result()
+		if err != nil {
+			return table.Row{}, err
+		}
+		values = append(values, val)
+	}
+	return table.Row{Values: values}, nil
+}
+
+// groupKey returns a string equal for rows of the same group. Unlike in a
+// join, NULLs are equal here: they form one group.
+func (agg *aggregatePlan) groupKey(row table.Row) string {
+	var sb strings.Builder
+	for _, idx := range agg.groupBy {
+		if k, ok := hashKey(row.Values[idx]); ok {
+			fmt.Fprintf(&sb, "%d:%d:%s", row.Values[idx].Type, len(k), k)
+		} else {
+			sb.WriteString("NULL")
+		}
+	}
+	return sb.String()
+}
+
+// hashAggregateIterator groups its input in a hash table, then returns the
+// groups in the order their first rows arrived.
+type hashAggregateIterator struct {
+	e     *Executor
+	input rowIterator
+	agg   *aggregatePlan
+
+	groups []*group
+	done   bool
+	row    table.Row
+	err    error
+}
+
+func (it *hashAggregateIterator) Next() bool {
+	if !it.done {
+		it.done = true
+		if it.err = it.build(); it.err != nil {
+			return false
+		}
+	}
+	if it.err != nil || len(it.groups) == 0 {
+		return false
+	}
+	it.row, it.err = it.groups[0].row()
+	it.groups = it.groups[1:]
+	return it.err == nil
+}
+
+// build reads the whole input into the hash table.
+func (it *hashAggregateIterator) build() error {
+	byKey := make(map[string]*group)
+	for it.input.Next() {
+		row := it.input.Row()
+		key := it.agg.groupKey(row)
+		g, exists := byKey[key]
+		if !exists {
+			g = it.agg.newGroup(row)
+			if err := it.e.reserve(g.memory(key), "hash aggregate"); err != nil {
+				return err
+			}
+			byKey[key] = g
+			it.groups = append(it.groups, g)
+		}
+		if err := it.e.addToGroup(g, row, it.agg.input); err != nil {
+			return err
+		}
+	}
+	if len(it.agg.groupBy) == 0 && len(it.groups) == 0 {
+		// Aggregates without GROUP BY return a row even for no input
+		it.groups = append(it.groups, it.agg.newGroup(table.Row{}))
+	}
+	return it.input.Err()
+}
+
+func (it *hashAggregateIterator) Row() table.Row { return it.row }
+
+func (it *hashAggregateIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.input.Err()
+}
+
+func (it *hashAggregateIterator) Close() error { it.groups = nil; return it.input.Close() }
+
+// streamAggregateIterator groups input ordered by group, returning each
+// group once the first row of the next one arrives.
+type streamAggregateIterator struct {
+	e     *Executor
+	input rowIterator
+	agg   *aggregatePlan
+
+	current    *group // the group being added to, nil before the first row
+	currentKey string
+	started    bool
+	done       bool
+	row        table.Row
+	err        error
+}
+
+func (it *streamAggregateIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	for it.input.Next() {
+		row := it.input.Row()
+		key := it.agg.groupKey(row)
+		it.started = true
+
+		var finished *group
+		if it.current == nil || key != it.currentKey {
+			finished = it.current
+			it.current, it.currentKey = it.agg.newGroup(row), key
+		}
+		if it.err = it.e.addToGroup(it.current, row, it.agg.input); it.err != nil {
+			return false
+		}
+		if finished != nil {
+			return it.emit(finished)
+		}
+	}
+	if it.input.Err() != nil {
+		return false
+	}
+
+	// The input has ended: return the last group, or for aggregates
+	// without GROUP BY, the one group even if no row arrived
+	it.done = true
+	if it.current == nil && !it.started && len(it.agg.groupBy) == 0 {
+		it.current = it.agg.newGroup(table.Row{})
+	}
+	if it.current == nil {
+		return false
+	}
+	return it.emit(it.current)
+}
+
+// emit makes a finished group the current output row.
+func (it *streamAggregateIterator) emit(g *group) bool {
+	it.row, it.err = g.row()
+	return it.err == nil
+}
+
+func (it *streamAggregateIterator) Row() table.Row { return it.row }
+
+func (it *streamAggregateIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.input.Err()
+}
+
+func (it *streamAggregateIterator) Close() error { return it.input.Close() }
+
+// aggregateIterator returns the operator running agg on input.
+func (e *Executor) aggregateIterator(input rowIterator, agg *aggregatePlan) rowIterator {
+	var it rowIterator = &hashAggregateIterator{e: e, input: input, agg: agg}
+	if agg.method == StreamAggregate {
+		it = &streamAggregateIterator{e: e, input: input, agg: agg}
+	}
+	return e.traced(it, agg.method.String(), agg.detail())
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 30)
+	executeSQL(t, exec, "INSERT INTO items VALUES (31, 'free', NULL)")
+	executeSQL(t, exec, "INSERT INTO items VALUES (32, 'free', NULL)")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT COUNT(*), COUNT(price), SUM(price), MIN(price), MAX(price) FROM items",
+			"32 30 135 0 9"},
+		{"SELECT AVG(price) FROM items WHERE id <= 4", "2.5"},
+		{"SELECT COUNT(*), SUM(price) FROM items WHERE id > 100", "0 NULL"},
+		{"SELECT price, COUNT(*) AS n, SUM(id) FROM items WHERE id > 20 GROUP BY price ORDER BY price",
+			"NULL 2 63, 0 1 30, 1 1 21, 2 1 22, 3 1 23, 4 1 24, 5 1 25, 6 1 26, 7 1 27, 8 1 28, 9 1 29"},
+		{"SELECT price, COUNT(*) AS n FROM items GROUP BY price ORDER BY n, price LIMIT 2",
+			"NULL 2, 0 3"},
+		{"SELECT label, MAX(id) - MIN(id) FROM items GROUP BY label ORDER BY label",
+			"an item with a fairly long label 29, free 1"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT label, COUNT(*) FROM items GROUP BY price",
+		"SELECT * FROM items GROUP BY price",
+		"SELECT SUM(MAX(price)) FROM items",
+		"SELECT SUM(*) FROM items",
+		"SELECT SUM(label) FROM items",
+		"SELECT id FROM items WHERE COUNT(*) > 1",
+		"SELECT price FROM items GROUP BY price ORDER BY id",
+		"SELECT COUNT(*) FROM items FOR UPDATE",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestAggregateMethod(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 1000)
+	executeSQL(t, exec, "ANALYZE items")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		// Few groups in no useful order: hash them
+		{"SELECT price, COUNT(*) FROM items GROUP BY price", "-> Hash Aggregate (by price; COUNT(*))"},
+		// A range of the primary key comes in key order
+		{"SELECT id, COUNT(*) FROM items WHERE id <= 20 GROUP BY id", "-> Stream Aggregate (by id; COUNT(*))"},
+		// A group per row costs less to stream in key order than to hash
+		{"SELECT id, price, MAX(label) FROM items GROUP BY id, price", "-> Index Scan (items using primary key)"},
+		// One group needs no ordering
+		{"SELECT SUM(price) FROM items", "-> Stream Aggregate (SUM(price))"},
+	}
+	for _, tt := range tests {
+		want := resultText(executeSQL(t, exec, tt.sql))
+		plan := executeSQL(t, exec, "EXPLAIN ANALYZE "+tt.sql).String()
+		if !strings.Contains(plan, tt.want) {
+			t.Errorf("%s: expected %q in plan:\n%s", tt.sql, tt.want, plan)
+		}
+
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != want {
+			t.Errorf("%s: EXPLAIN ANALYZE changed the results", tt.sql)
+		}
+	}
+
+	// The streamed groups come out in key order
+	result := executeSQL(t, exec, "SELECT id, price, MAX(label) FROM items GROUP BY id, price LIMIT 3")
+	if got := resultText(result); !strings.HasPrefix(got, "1 1 ") || result.RowCount != 3 {
+		t.Errorf("expected the first 3 ids, got %s", got)
+	}
+}
+
+func TestAggregateJoin(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupJoinTables(t, exec)
+
+	result := executeSQL(t, exec, "EXPLAIN SELECT COUNT(*) FROM users JOIN orders ON users.id = orders.user_id")
+	if plan := result.String(); !strings.Contains(plan, "Stream Aggregate") {
+		t.Errorf("expected an aggregate over the join:\n%s", plan)
+	}
+}
@@ -0,0 +1,216 @@
+// Package executor - ATTACH DATABASE
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A database is one file, but a query sometimes needs two: copying rows
+// out of an archive, comparing a backup with the live data, or keeping
+// large, rarely used tables apart. ATTACH opens another database file
+// next to the executor's own, under an alias:
+//
+//   ATTACH DATABASE 'archive.db' AS archive;
+//   SELECT COUNT(*) FROM archive.orders;
+//   DELETE FROM archive.orders WHERE id < 1000;
+//   DETACH DATABASE archive;
+//
+// Each attached file keeps its own pager and catalog, exactly as if it
+// had been opened alone; the executor keeps a registry of them by alias.
+// Their tables join the executor's table map under qualified names such
+// as archive.orders (the lexer reads a dotted name as one identifier), so
+// SELECT, INSERT, UPDATE, DELETE, joins and the information_schema views
+// find them like any other table. Only what touches a file as a whole
+// needs to know which database a table belongs to: CREATE and DROP TABLE,
+// which change that file's catalog, and flushing, which saves each
+// catalog with its own tables. VACUUM and PRAGMA integrity_check only
+// look at the executor's own file.
+//
+// A transaction covers the attached files too: BEGIN starts one in every
+// pager and ROLLBACK undoes them all. COMMIT, however, commits the files
+// one after the other, so a crash in between can leave one committed and
+// the other not. SQLite closes that gap with a "super-journal" naming
+// every file of the transaction; PostgreSQL has no ATTACH at all, and
+// reaches other databases through foreign data wrappers instead.
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// attachedDatabase is a database file attached under an alias.
+type attachedDatabase struct {
+	alias   string
+	path    string
+	pager   *storage.Pager
+	catalog *catalog.Catalog
+}
+
+// executeAttach opens a database file and adds its tables under the
+// alias.
+func (e *Executor) executeAttach(stmt *parser.AttachStatement) (*Result, error) {
+	if e.tx != nil {
+		return nil, errors.New("cannot attach a database inside a transaction")
+	}
+	alias := strings.ToLower(stmt.Alias)
+	if alias == "main" || alias == "information_schema" || strings.Contains(alias, ".") {
+		return nil, fmt.Errorf("cannot attach a database as %s", alias)
+	}
+	if _, exists := e.attached[alias]; exists {
+		return nil, fmt.Errorf("database %s is already attached", alias)
+	}
+
+	// The attached file is opened like the executor's own, encrypted with
+	// the same key if there is one
+	pager, err := storage.NewPager(stmt.Path, storage.WithCodec(e.pager.Codec()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", stmt.Path, err)
+	}
+	cat, err := catalog.NewCatalog(pager)
+	if err != nil {
+		pager.Close()
+		return nil, fmt.Errorf("failed to read catalog of %s: %w", stmt.Path, err)
+	}
+	tables := make(map[string]*table.Table)
+	for _, name := range cat.ListTables() {
+		tbl, err := cat.LoadTable(name, pager)
+		if err != nil {
+			pager.Close()
+			return nil, fmt.Errorf("failed to load table %s.%s: %w", alias, name, err)
+		}
+		tables[alias+"."+name] = tbl
+	}
+
+	if e.attached == nil {
+		e.attached = make(map[string]*attachedDatabase)
+	}
+	e.attached[alias] = &attachedDatabase{alias: alias, path: stmt.Path, pager: pager, catalog: cat}
+	for name, tbl := range tables {
+		e.tables[name] = tbl
+	}
+
+	return &Result{
+		Message: fmt.Sprintf("Attached '%s' as %s (%d table(s))", stmt.Path, alias, len(tables)),
+	}, nil
+}
+
+// executeDetach saves and closes an attached database.
+func (e *Executor) executeDetach(stmt *parser.DetachStatement) (*Result, error) {
+	if e.tx != nil {
+		return nil, errors.New("cannot detach a database inside a transaction")
+	}
+	alias := strings.ToLower(stmt.Alias)
+	db, ok := e.attached[alias]
+	if !ok {
+		return nil, fmt.Errorf("database %s is not attached", alias)
+	}
+	if err := e.detach(db); err != nil {
+		return nil, err
+	}
+	return &Result{Message: fmt.Sprintf("Detached %s", alias)}, nil
+}
+
+// detach writes an attached database's changes, closes its file and
+// forgets its tables.
+func (e *Executor) detach(db *attachedDatabase) error {
+	if err := db.catalog.UpdateTables(e.tablesOf(db)); err != nil {
+		return fmt.Errorf("failed to save table metadata of %s: %w", db.alias, err)
+	}
+	if err := db.catalog.Flush(); err != nil {
+		return fmt.Errorf("failed to flush %s: %w", db.alias, err)
+	}
+	if err := db.pager.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", db.alias, err)
+	}
+	for name := range e.tablesOf(db) {
+		delete(e.tables, db.alias+"."+name)
+	}
+	delete(e.attached, db.alias)
+	return nil
+}
+
+// DetachAll detaches every attached database, saving their changes. The
+// executor's own pager is left to its owner to close.
+func (e *Executor) DetachAll() error {
+	leave, err := e.enterAccess(true)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	var errs []error
+	for _, alias := range e.AttachedDatabases() {
+		if err := e.detach(e.attached[alias]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AttachedDatabases returns the aliases of the attached databases, in
+// order.
+func (e *Executor) AttachedDatabases() []string {
+	leave, _ := e.enterAccess(false)
+	defer leave()
+	aliases := make([]string, 0, len(e.attached))
+	for alias := range e.attached {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// databaseOf returns the attached database a qualified table name such as
+// archive.orders refers to, with the table's name within it. It returns
+// nil for a table of the executor's own database.
+func (e *Executor) databaseOf(tableName string) (*attachedDatabase, string) {
+	alias, name, ok := strings.Cut(tableName, ".")
+	if !ok {
+		return nil, tableName
+	}
+	return e.attached[alias], name
+}
+
+// tablesOf returns the tables of an attached database by their names
+// within it.
+func (e *Executor) tablesOf(db *attachedDatabase) map[string]*table.Table {
+	prefix := db.alias + "."
+	tables := make(map[string]*table.Table)
+	for name, tbl := range e.tables {
+		if strings.HasPrefix(name, prefix) {
+			tables[strings.TrimPrefix(name, prefix)] = tbl
+		}
+	}
+	return tables
+}
+
+// ownTables returns the tables of the executor's own database, leaving
+// out those of the attached databases.
+func (e *Executor) ownTables() map[string]*table.Table {
+	tables := make(map[string]*table.Table, len(e.tables))
+	for name, tbl := range e.tables {
+		if db, _ := e.databaseOf(name); db == nil {
+			tables[name] = tbl
+		}
+	}
+	return tables
+}
+
+// pagers returns the executor's pager followed by those of the attached
+// databases, in order of their aliases, and that of the temporary tables.
+func (e *Executor) pagers() []*storage.Pager {
+	pagers := []*storage.Pager{e.pager}
+	for _, alias := range e.AttachedDatabases() {
+		pagers = append(pagers, e.attached[alias].pager)
+	}
+	if e.temp != nil {
+		pagers = append(pagers, e.temp)
+	}
+	return pagers
+}
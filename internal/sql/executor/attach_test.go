@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttachDatabase(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "archive.db")
+
+	// The archive is an ordinary database of its own
+	archive, archivePager := openCatalogExecutor(t, archivePath)
+	executeSQL(t, archive, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, total INTEGER)")
+	executeSQL(t, archive, "INSERT INTO orders VALUES (1, 1, 30)")
+	executeSQL(t, archive, "INSERT INTO orders VALUES (2, 2, 15)")
+	if err := archive.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	archivePager.Close()
+
+	exec, pager := openCatalogExecutor(t, filepath.Join(dir, "main.db"))
+	defer pager.Close()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'alice')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'bob')")
+
+	executeSQL(t, exec, "ATTACH DATABASE '"+archivePath+"' AS arc")
+	executeSQL(t, exec, "INSERT INTO arc.orders VALUES (3, 1, 5)")
+	executeSQL(t, exec, "CREATE TABLE arc.notes (id INTEGER PRIMARY KEY, body TEXT)")
+	executeSQL(t, exec, "INSERT INTO arc.notes VALUES (1, 'kept')")
+	executeSQL(t, exec, "CREATE INDEX idx_user ON arc.orders (user_id)")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT id, total FROM arc.orders WHERE user_id = 1", "1 30, 3 5"},
+		{"SELECT name, total FROM users JOIN arc.orders ON users.id = arc.orders.user_id WHERE total > 10",
+			"alice 30, bob 15"},
+		{"SHOW TABLES", "arc.notes, arc.orders, users"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.want, got)
+		}
+	}
+
+	if got := resultText(executeSQL(t, exec, "PRAGMA integrity_check")); got != "ok" {
+		t.Errorf("expected the main database to check ok, got %s", got)
+	}
+
+	// A rollback undoes the changes to the attached file too
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "DELETE FROM arc.orders")
+	executeSQL(t, exec, "DELETE FROM users")
+	executeSQL(t, exec, "ROLLBACK")
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM arc.orders")); got != "3" {
+		t.Errorf("expected 3 orders after ROLLBACK, got %s", got)
+	}
+
+	for _, sql := range []string{
+		"ATTACH DATABASE '" + archivePath + "' AS arc",
+		"ATTACH '" + filepath.Join(dir, "other.db") + "' AS main",
+		"DETACH DATABASE nothing",
+		"CREATE TABLE nothing.t (id INTEGER)",
+		"SELECT * FROM nothing.t",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	executeSQL(t, exec, "BEGIN")
+	if _, err := exec.Execute(parseSQL(t, "DETACH arc")); err == nil || !strings.Contains(err.Error(), "transaction") {
+		t.Errorf("expected DETACH to be refused inside a transaction, got %v", err)
+	}
+	executeSQL(t, exec, "COMMIT")
+
+	executeSQL(t, exec, "DETACH DATABASE arc")
+	if _, ok := exec.GetTable("arc.orders"); ok {
+		t.Error("expected the attached tables to be gone after DETACH")
+	}
+	if got := exec.GetTables(); len(got) != 1 || got[0] != "users" {
+		t.Errorf("expected only users left, got %v", got)
+	}
+
+	// The changes were written to the archive's own file, indexes included
+	archive, archivePager = openCatalogExecutor(t, archivePath)
+	defer archivePager.Close()
+	if got := resultText(executeSQL(t, archive, "SELECT body FROM notes")); got != "kept" {
+		t.Errorf("expected the note in the archive, got %s", got)
+	}
+	if got := resultText(executeSQL(t, archive, "SELECT COUNT(*) FROM orders")); got != "3" {
+		t.Errorf("expected 3 orders in the archive, got %s", got)
+	}
+	if tbl, _ := archive.GetTable("orders"); len(tbl.ListIndexes()) != 1 {
+		t.Errorf("expected idx_user in the archive, got %v", tbl.ListIndexes())
+	}
+	if _, ok := exec.catalog.GetTableInfo("notes"); ok {
+		t.Error("expected the main catalog not to record the archive's tables")
+	}
+}
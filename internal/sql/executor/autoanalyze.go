@@ -0,0 +1,57 @@
+// Package executor - Automatic ANALYZE
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The planner chooses between a scan and an index by the statistics
+// ANALYZE gathered, and those only describe the table as it was then. A
+// table analyzed when it held 10 rows, and which now holds a million,
+// gets planned as if it still held 10. Rather than relying on someone to
+// remember to run ANALYZE, the executor runs it by itself once enough of
+// a table has changed: each table counts the rows inserted, updated and
+// deleted since it was last analyzed (TableStats.Modified), and after a
+// statement changes rows, the table is analyzed if
+//
+//   modified > threshold + scale * rows
+//
+// The fraction keeps large tables from being analyzed all the time (a
+// million-row table isn't much different after a thousand changes, and
+// ANALYZE reads every row), and the fixed part keeps small ones from being
+// analyzed after every statement. These are PostgreSQL's autovacuum rules
+// and defaults, which its autovacuum daemon checks in the background; here
+// the statement that crosses the line pays for the ANALYZE.
+
+package executor
+
+import (
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+const (
+	// defaultAnalyzeThreshold and defaultAnalyzeScale are the defaults of
+	// SetAutoAnalyze, PostgreSQL's autovacuum_analyze_threshold and
+	// autovacuum_analyze_scale_factor.
+	defaultAnalyzeThreshold = 50
+	defaultAnalyzeScale     = 0.1
+)
+
+// SetAutoAnalyze sets how many rows of a table must change before it is
+// analyzed automatically: more than threshold plus scale times the rows
+// it has. A negative threshold turns automatic analysis off.
+func (e *Executor) SetAutoAnalyze(threshold int, scale float64) {
+	e.analyzeThreshold, e.analyzeScale = threshold, scale
+}
+
+// autoAnalyze analyzes tbl if enough of its rows have changed since it
+// was last analyzed.
+func (e *Executor) autoAnalyze(tbl *table.Table) {
+	if e.analyzeThreshold < 0 {
+		return
+	}
+	stats := tbl.Stats()
+	if float64(stats.Modified) <= float64(e.analyzeThreshold)+e.analyzeScale*float64(stats.RowCount) {
+		return
+	}
+	// The statement itself has succeeded, so failing to analyze only
+	// leaves the old statistics, and it's tried again after the next one
+	_ = tbl.Analyze()
+}
@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAutoAnalyze(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	exec.SetAutoAnalyze(10, 0.5)
+
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, price INTEGER)")
+	tbl := exec.tables["items"]
+	insert := func(from, to int) {
+		for i := from; i <= to; i++ {
+			executeSQL(t, exec, fmt.Sprintf("INSERT INTO items VALUES (%d, %d)", i, i%3))
+		}
+	}
+
+	// 20 rows changed isn't more than 10 + 0.5 * 20; 21 is
+	insert(1, 20)
+	if stats := tbl.Stats(); stats.Analyzed() || stats.Modified != 20 {
+		t.Fatalf("expected 20 modified rows and no ANALYZE yet, got %+v", stats)
+	}
+	insert(21, 21)
+	stats := tbl.Stats()
+	if !stats.Analyzed() || stats.Modified != 0 || stats.Columns["price"].DistinctValues != 3 {
+		t.Fatalf("expected the 21st insert to analyze the table, got %+v", stats)
+	}
+
+	// Deletes and updates count too: 5 + 16 rows changed is more than
+	// 10 + 0.5 * 16
+	analyzed := stats.LastAnalyzed
+	executeSQL(t, exec, "DELETE FROM items WHERE id <= 5")
+	if stats := tbl.Stats(); !stats.LastAnalyzed.Equal(analyzed) || stats.Modified != 5 {
+		t.Errorf("expected 5 modified rows and no ANALYZE, got %+v", stats)
+	}
+	executeSQL(t, exec, "UPDATE items SET price = 7")
+	if stats := tbl.Stats(); stats.LastAnalyzed.Equal(analyzed) || stats.Columns["price"].DistinctValues != 1 {
+		t.Errorf("expected the UPDATE to analyze the table again, got %+v", stats)
+	}
+
+	// Turned off, the table is only analyzed on request
+	exec.SetAutoAnalyze(-1, 0)
+	analyzed = tbl.Stats().LastAnalyzed
+	insert(100, 200)
+	if stats := tbl.Stats(); !stats.LastAnalyzed.Equal(analyzed) || stats.Modified != 101 {
+		t.Errorf("expected no ANALYZE with automatic analysis off, got %+v", stats)
+	}
+}
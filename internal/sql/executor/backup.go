@@ -0,0 +1,123 @@
+// Package executor - Online backup and restore
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Copying the database file with cp while the database is open can go
+// wrong in several ways: a write can land half way through the copy,
+// leaving a file whose tables and catalog disagree; committed pages may
+// still be in the write-ahead log, not yet in the file; and changes may
+// still be in the page cache, not yet on disk at all.
+//
+// Backup copies the database from inside instead, like SQLite's backup
+// API. It waits for the gate, as a statement that writes would, so no
+// session (another REPL's, or the web server's) is in the middle of a
+// change; brings the catalog and the pages up to date; and then copies
+// every page, through the cache and the log, to a new file:
+//
+//   .backup nightly.db          -- a database file of its own
+//   .restore nightly.db         -- put the backup's tables back
+//
+// The copy goes to a scratch file that is renamed over the target only
+// when complete, so a backup cut short never looks like a good one.
+// Sessions wait while the pages are copied; SQLite's backup API copies a
+// few pages at a time, starting again if a write gets in between, to
+// avoid that. An encrypted database's backup is encrypted with the same
+// key, and a compressed one's is compressed.
+//
+// Restore is the copy the other way: the backup's pages replace the
+// database's, as VACUUM replaces them with compacted ones, and the tables
+// are read again from the restored catalog. Unlike a dump (see dump.go),
+// a backup is a copy of the pages, so it is quick to take and to restore,
+// but only opens in a build that reads the same file format.
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// Backup copies the database, as of the last statement to finish, to a
+// database file at path, replacing any file there.
+func (e *Executor) Backup(path string) error {
+	if e.tx != nil {
+		return errors.New("cannot back up the database inside a transaction")
+	}
+	leave, err := e.enterAccess(true)
+	if err != nil {
+		return err
+	}
+	defer leave()
+	if err := e.Flush(); err != nil {
+		return fmt.Errorf("failed to flush the database before backing it up: %w", err)
+	}
+
+	scratchFile, err := os.CreateTemp(filepath.Dir(path), ".claude-db-backup-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	scratchPath := scratchFile.Name()
+	scratchFile.Close()
+	defer os.Remove(scratchPath)
+
+	backup, err := storage.NewPager(scratchPath, e.backupOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	if err := backup.CopyFrom(e.pager); err != nil {
+		backup.Close()
+		return fmt.Errorf("failed to copy the database: %w", err)
+	}
+	if err := backup.Close(); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	return os.Rename(scratchPath, path)
+}
+
+// Restore replaces the database with the backup at path, as Backup wrote
+// it. The attached databases and temporary tables are left alone.
+func (e *Executor) Restore(path string) error {
+	if e.tx != nil {
+		return errors.New("cannot restore the database inside a transaction")
+	}
+	if e.catalog == nil {
+		return errors.New("cannot restore a database without a catalog")
+	}
+	leave, err := e.enterAccess(true)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	backup, err := storage.NewPager(path, append(e.backupOptions(), storage.WithReadOnly())...)
+	if err != nil {
+		return fmt.Errorf("cannot open backup: %w", err)
+	}
+	defer backup.Close()
+	if backup.PageCount() == 0 {
+		return fmt.Errorf("cannot restore %s: it is empty", path)
+	}
+	// Check that the backup is a database before overwriting this one
+	if _, err := catalog.NewCatalog(backup); err != nil {
+		return fmt.Errorf("cannot restore %s: %w", path, err)
+	}
+
+	if err := e.pager.CopyFrom(backup); err != nil {
+		return fmt.Errorf("failed to restore the database: %w", err)
+	}
+	return e.reload()
+}
+
+// backupOptions returns the options of a pager reading or writing the
+// database's backups: its key, or its compression.
+func (e *Executor) backupOptions() []storage.PagerOption {
+	if e.pager.Compressed() {
+		return []storage.PagerOption{storage.WithCompression()}
+	}
+	return []storage.PagerOption{storage.WithCodec(e.pager.Codec())}
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	exec, pager := openCatalogExecutor(t, filepath.Join(dir, "live.db"), storage.WithWAL())
+	defer pager.Close()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "CREATE INDEX idx_users_name ON users (name)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+
+	// The backup is a database of its own, with the rows as they were
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := exec.Backup(backupPath); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	copied, copiedPager := openCatalogExecutor(t, backupPath)
+	if got := resultText(executeSQL(t, copied, "SELECT name FROM users WHERE name = 'Bob'")); got != "Bob" {
+		t.Errorf("expected the backup to hold Bob, got %q", got)
+	}
+	copiedPager.Close()
+
+	// Restoring it undoes what came after, tables and indexes included
+	executeSQL(t, exec, "DELETE FROM users WHERE id = 1")
+	executeSQL(t, exec, "CREATE TABLE later (id INTEGER PRIMARY KEY)")
+	if err := exec.Restore(backupPath); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users ORDER BY id")); got != "Alice, Bob" {
+		t.Errorf("expected the restored rows, got %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM users WHERE name = 'Alice'")); got != "1" {
+		t.Errorf("expected the restored index to find Alice, got %q", got)
+	}
+	if _, ok := exec.GetTable("later"); ok {
+		t.Error("expected the table created after the backup to be gone")
+	}
+
+	// And the restored database is what is on disk
+	executeSQL(t, exec, "INSERT INTO users VALUES (3, 'Carol')")
+	if err := exec.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, filepath.Join(dir, "live.db"), storage.WithWAL())
+	defer pager.Close()
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users ORDER BY id")); got != "Alice, Bob, Carol" {
+		t.Errorf("expected the restored rows after reopening, got %q", got)
+	}
+}
+
+func TestBackupErrors(t *testing.T) {
+	dir := t.TempDir()
+	exec, pager := openCatalogExecutor(t, filepath.Join(dir, "live.db"))
+	defer pager.Close()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY)")
+
+	notDatabase := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notDatabase, []byte("not a database"), 0644)
+	for _, path := range []string{notDatabase, filepath.Join(dir, "missing.db")} {
+		if err := exec.Restore(path); err == nil {
+			t.Errorf("expected restoring %s to fail", path)
+		}
+	}
+	if _, ok := exec.GetTable("users"); !ok {
+		t.Error("expected a failed restore to leave the database alone")
+	}
+
+	executeSQL(t, exec, "BEGIN")
+	if err := exec.Backup(filepath.Join(dir, "backup.db")); err == nil {
+		t.Error("expected a backup inside a transaction to fail")
+	}
+	executeSQL(t, exec, "ROLLBACK")
+}
@@ -0,0 +1,101 @@
+// Package executor - Batches
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A client loading many rows (an import, a benchmark, a test fixture)
+// would run them one statement at a time and flush after each:
+//
+//   for _, stmt := range stmts {
+//       exec.Execute(stmt)
+//       exec.Flush()    // the catalog saved, every dirty page written
+//   }
+//
+// Each flush saves the catalog and writes out every page the statement
+// dirtied, with an fsync; the leaf page rows are inserted into is written
+// once per row. ExecuteBatch runs the statements and then flushes once,
+// so that page is written once per batch:
+//
+//   results, err := exec.ExecuteBatch(stmts)
+//
+// The whole batch also runs as a single lock owner: outside a transaction
+// the row locks its statements take are kept until the batch ends,
+// rather than released statement by statement, so another session's
+// UPDATE or DELETE of those rows waits for the whole batch instead of
+// slipping in between its statements.
+//
+// A batch stops at the first statement that fails. The statements before
+// it have taken effect, as they would have one at a time, and are
+// flushed. For all or nothing, put BEGIN and COMMIT around them; a batch
+// may hold any statement, those included. JDBC's executeBatch and the
+// pipelining of PostgreSQL's protocol exist for the same reason: the cost
+// of a bulk load is in the round trips and syncs between statements, not
+// in the statements.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// ExecuteBatch runs statements in order, then flushes once. It returns
+// the result of each statement run; on error, those of the statements
+// before the one that failed.
+func (e *Executor) ExecuteBatch(stmts []parser.Statement) ([]*Result, error) {
+	return e.ExecuteBatchContext(context.Background(), stmts, nil)
+}
+
+// ExecuteBatchContext runs a batch like ExecuteBatch, binding args[i] to
+// the ? placeholders of stmts[i] (args may be nil when no statement has
+// any), and stopping with an error wrapping ctx.Err() if ctx is canceled
+// first.
+func (e *Executor) ExecuteBatchContext(ctx context.Context, stmts []parser.Statement, args [][]table.Value) ([]*Result, error) {
+	if args != nil && len(args) != len(stmts) {
+		return nil, fmt.Errorf("batch has %d statements but %d sets of parameters", len(stmts), len(args))
+	}
+	defer e.withContext(ctx)()
+
+	results, err := e.executeBatch(stmts, args)
+	if flushErr := e.Flush(); flushErr != nil && err == nil {
+		err = fmt.Errorf("failed to flush batch: %w", flushErr)
+	}
+	return results, err
+}
+
+// executeBatch runs the statements of a batch, holding the row locks of
+// autocommit statements until the last has run.
+func (e *Executor) executeBatch(stmts []parser.Statement, args [][]table.Value) ([]*Result, error) {
+	leave, err := e.enter(stmts...)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	e.inBatch = true
+	defer func() {
+		e.inBatch = false
+		e.releaseStatementLocks()
+	}()
+
+	results := make([]*Result, 0, len(stmts))
+	for i, stmt := range stmts {
+		if err := e.canceled(); err != nil {
+			return results, fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		var result *Result
+		var err error
+		if args != nil && len(args[i]) > 0 {
+			result, err = e.ExecuteWithParams(stmt, args[i])
+		} else {
+			result, err = e.Execute(stmt)
+		}
+		if err != nil {
+			return results, fmt.Errorf("statement %d: %w", i+1, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
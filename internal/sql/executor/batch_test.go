@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestExecuteBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	// HELD() counts the row locks of the batch while it runs
+	err := exec.RegisterFunction("HELD", ScalarFunction{MaxArgs: 0,
+		Fn: func([]table.Value) (table.Value, error) {
+			return intValue(int64(len(exec.locks.Held(exec.stmtOwner)))), nil
+		}})
+	if err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+
+	stmts := []parser.Statement{parseSQL(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")}
+	for i := 1; i <= 100; i++ {
+		stmts = append(stmts, parseSQL(t, fmt.Sprintf("INSERT INTO items VALUES (%d, 'item %d')", i, i)))
+	}
+	stmts = append(stmts, parseSQL(t, "SELECT HELD()"))
+	results, err := exec.ExecuteBatch(stmts)
+	if err != nil {
+		t.Fatalf("ExecuteBatch failed: %v", err)
+	}
+	if len(results) != 102 || resultText(results[101]) != "100" {
+		t.Fatalf("expected 102 results and 100 locks held at the end, got %d and %q", len(results), resultText(results[len(results)-1]))
+	}
+	if exec.stmtOwner != 0 {
+		t.Error("expected the batch's locks to be released")
+	}
+
+	// Parameters are bound per statement, and the first failure stops the
+	// batch after those before it have taken effect
+	insert := parseSQL(t, "INSERT INTO items VALUES (?, ?)")
+	results, err = exec.ExecuteBatchContext(t.Context(),
+		[]parser.Statement{insert, insert, insert},
+		[][]table.Value{{intValue(101), textValue("a")}, {intValue(1), textValue("duplicate")}, {intValue(102), textValue("b")}})
+	if err == nil || !strings.HasPrefix(err.Error(), "statement 2: ") || len(results) != 1 {
+		t.Errorf("expected statement 2 to fail after 1 result, got %d results and %v", len(results), err)
+	}
+	if _, err := exec.ExecuteBatchContext(t.Context(), []parser.Statement{insert}, nil); err == nil {
+		t.Error("expected a statement without its parameters to fail")
+	}
+
+	// The batch was flushed: a new executor sees its rows
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*), MAX(id) FROM items")); got != "101 101" {
+		t.Errorf("unexpected rows after reopening %q", got)
+	}
+}
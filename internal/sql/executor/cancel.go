@@ -0,0 +1,126 @@
+// Package executor - Cancelling statements
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A query scanning a million rows can't be stopped from outside once
+// Execute has been called: the caller has to wait for it to finish, even
+// if the web client that asked for it has gone away. ExecuteContext takes
+// a context.Context, the standard Go way to tell running work to stop:
+//
+//   ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//   defer cancel()
+//   result, err := exec.ExecuteContext(ctx, stmt)
+//   if errors.Is(err, context.DeadlineExceeded) { ... }
+//
+// Go can't stop a goroutine from outside, so the executor has to notice
+// by itself. It checks the context as each row is read from a table and
+// as each pair of rows is joined; everything else (filtering, sorting,
+// aggregating) pulls its rows through those, so a canceled query stops
+// within a row of noticing. PostgreSQL works the same way: a cancel
+// request sets a flag that the executor checks at many points
+// (CHECK_FOR_INTERRUPTS).
+//
+// UPDATE and DELETE can be canceled while they look for their rows, but
+// not once they start changing them, so a statement is never left half
+// done.
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// ExecuteContext runs a statement like Execute, stopping with an error
+// wrapping ctx.Err() if ctx is canceled first.
+func (e *Executor) ExecuteContext(ctx context.Context, stmt parser.Statement) (*Result, error) {
+	defer e.withContext(ctx)()
+	if err := e.canceled(); err != nil {
+		return nil, err
+	}
+	return e.Execute(stmt)
+}
+
+// ExecuteWithParamsContext runs a statement like ExecuteWithParams,
+// stopping with an error wrapping ctx.Err() if ctx is canceled first.
+func (e *Executor) ExecuteWithParamsContext(ctx context.Context, stmt parser.Statement, args []table.Value) (*Result, error) {
+	defer e.withContext(ctx)()
+	if err := e.canceled(); err != nil {
+		return nil, err
+	}
+	return e.ExecuteWithParams(stmt, args)
+}
+
+// QueryContext runs a SELECT like Query. Reading its rows stops with an
+// error wrapping ctx.Err() once ctx is canceled.
+func (e *Executor) QueryContext(ctx context.Context, stmt parser.Statement, args ...table.Value) (*Rows, error) {
+	defer e.withContext(ctx)()
+	if err := e.canceled(); err != nil {
+		return nil, err
+	}
+	return e.Query(stmt, args...)
+}
+
+// withContext makes ctx the context of the statement being executed, and
+// returns a function restoring the one before.
+func (e *Executor) withContext(ctx context.Context) func() {
+	prev := e.ctx
+	e.ctx = ctx
+	return func() { e.ctx = prev }
+}
+
+// canceled returns an error if the statement being executed has been
+// canceled.
+func (e *Executor) canceled() error {
+	if e.ctx == nil {
+		return nil
+	}
+	if err := e.ctx.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("statement timed out: %w", err)
+		}
+		return fmt.Errorf("statement canceled: %w", err)
+	}
+	return nil
+}
+
+// cancelIterator stops its input once the statement is canceled. It sits
+// right above a table's rows, so it also counts them for the statement's
+// metrics (see metrics.go).
+type cancelIterator struct {
+	e     *Executor
+	input rowIterator
+	err   error
+}
+
+// interruptible returns input, stopping when the statement is canceled
+// and counting the rows it reads.
+func (e *Executor) interruptible(input rowIterator) rowIterator {
+	return &cancelIterator{e: e, input: input}
+}
+
+func (it *cancelIterator) Next() bool {
+	if it.err = it.e.canceled(); it.err != nil {
+		return false
+	}
+	if !it.input.Next() {
+		return false
+	}
+	it.e.scanned(1)
+	return true
+}
+
+func (it *cancelIterator) Row() table.Row { return it.input.Row() }
+
+func (it *cancelIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.input.Err()
+}
+
+func (it *cancelIterator) Close() error { return it.input.Close() }
@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// cancelAt registers SEEN(id), which cancels the returned context when it
+// is called with id, and counts the calls.
+func cancelAt(t *testing.T, exec *Executor, id int64) (context.Context, *int) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	calls := 0
+	err := exec.RegisterFunction("SEEN", ScalarFunction{MinArgs: 1, MaxArgs: 1,
+		Fn: func(args []table.Value) (table.Value, error) {
+			calls++
+			if args[0].Integer == id {
+				cancel()
+			}
+			return table.Value{Type: parser.TypeBoolean, Boolean: true}, nil
+		}})
+	if err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	return ctx, &calls
+}
+
+func TestExecuteContextCancel(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 300)
+
+	// A canceled context stops the statement before it starts
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := exec.ExecuteContext(ctx, parseSQL(t, "SELECT * FROM items")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	// Canceling mid-scan stops it at the next row, even under a sort
+	ctx, calls := cancelAt(t, exec, 10)
+	_, err := exec.ExecuteContext(ctx, parseSQL(t, "SELECT id FROM items WHERE SEEN(id) ORDER BY price"))
+	if !errors.Is(err, context.Canceled) || *calls != 10 {
+		t.Errorf("expected the scan to stop after 10 rows, got %d calls and %v", *calls, err)
+	}
+
+	// A canceled DELETE changes nothing
+	exec.UnregisterFunction("SEEN")
+	ctx, _ = cancelAt(t, exec, 100)
+	if _, err := exec.ExecuteContext(ctx, parseSQL(t, "DELETE FROM items WHERE SEEN(id)")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM items")); got != "300" {
+		t.Errorf("expected all 300 rows to remain, got %s", got)
+	}
+
+	// Without a context nothing is canceled afterwards
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM items WHERE SEEN(id)")); got != "300" {
+		t.Errorf("expected 300 rows, got %s", got)
+	}
+
+	// Rows stop once the context of QueryContext is canceled
+	ctx, cancel = context.WithCancel(context.Background())
+	rows, err := exec.QueryContext(ctx, parseSQL(t, "SELECT id FROM items"))
+	if err != nil {
+		t.Fatalf("QueryContext failed: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("expected a row: %v", rows.Err())
+	}
+	cancel()
+	if rows.Next() || !errors.Is(rows.Err(), context.Canceled) {
+		t.Errorf("expected the rows to stop with context.Canceled, got %v", rows.Err())
+	}
+}
+
+func TestJoinCancel(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupJoinTables(t, exec)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exec.ctx = ctx // as though canceled once the join had started
+	defer func() { exec.ctx = nil }()
+	_, err := exec.executeJoin(parseSQL(t, "SELECT * FROM users JOIN orders ON users.id = orders.user_id").(*parser.SelectStatement))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
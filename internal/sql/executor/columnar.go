@@ -0,0 +1,60 @@
+// Package executor - Columnar tables
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// CREATE TABLE ... USING columnar stores a table column by column (see
+// table/columnar.go), the way analytics databases such as ClickHouse,
+// DuckDB or PostgreSQL with Citus do:
+//
+//   CREATE TABLE page_views (day INTEGER, country TEXT, ms INTEGER) USING columnar;
+//   SELECT country, AVG(ms) FROM page_views WHERE day >= 19000 GROUP BY country;
+//
+// The query reads the chunks of day, country and ms, and none of the
+// others, however wide the table; the row groups whose days all lie
+// before 19000 aren't read at all. EXPLAIN shows the scan as a "Columnar
+// Scan" with the columns it reads.
+//
+// Everything else about the table is the same to the executor: inserts
+// go through Table.Insert, and scans through a RowIterator. What the
+// table can't do is find a stored row again, so UPDATE, DELETE, indexes,
+// a primary key and ALTER TABLE are refused up front rather than failing
+// halfway through.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// checkNotColumnar returns an error if a table is columnar, whose rows
+// can only be inserted.
+func checkNotColumnar(tbl *table.Table, tableName, action string) error {
+	if tbl.IsColumnar() {
+		return fmt.Errorf("cannot %s columnar table %s: its rows can only be inserted and read", action, tableName)
+	}
+	return nil
+}
+
+// columnarScanDetail describes a scan of a columnar table: the columns
+// it reads and its filter.
+func columnarScanDetail(stmt *parser.SelectStatement, tbl *table.Table, tableName string) string {
+	used := usedColumns(stmt, tbl.Schema)
+	var names []string
+	for i, col := range tbl.Schema.Columns {
+		if used == nil || used[i] {
+			names = append(names, col.Name)
+		}
+	}
+	return whereDetail(tableName+" reading "+strings.Join(names, ", "), stmt.Where)
+}
+
+// columnarStorage describes how a columnar table's rows are stored, for
+// EXPLAIN.
+func columnarStorage(tbl *table.Table) string {
+	groups, buffered := tbl.RowGroups()
+	return fmt.Sprintf("columnar, %d row groups, %d rows not yet packed", groups, buffered)
+}
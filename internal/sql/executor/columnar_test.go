@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestColumnarTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "columnar.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE views (day INTEGER, country TEXT, ms INTEGER, agent TEXT) USING columnar")
+	countries := []string{"de", "fr", "us"}
+	for i := 0; i < 2500; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO views VALUES (%d, '%s', %d, 'agent %d')",
+			i/100, countries[i%3], i%50, i%7))
+	}
+
+	// 2048 rows are packed in two row groups, the rest wait in data pages
+	const grouped = "SELECT country, COUNT(*), SUM(ms) FROM views WHERE day >= 10 GROUP BY country ORDER BY country"
+	const want = "de 500 12250, fr 500 12250, us 500 12250"
+	if got := resultText(executeSQL(t, exec, grouped)); got != want {
+		t.Errorf("unexpected groups %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*), MIN(day), MAX(day) FROM views")); got != "2500 0 24" {
+		t.Errorf("unexpected totals %q", got)
+	}
+	explained := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) SELECT country, ms FROM views WHERE day = 3"))
+	if !strings.Contains(explained, "Columnar Scan (views reading day, country, ms filter day = 3)") {
+		t.Errorf("expected a columnar scan of the columns read, got %q", explained)
+	}
+	if got := resultText(executeSQL(t, exec, "EXPLAIN SELECT * FROM views")); !strings.Contains(got, "columnar, 2 row groups, 452 rows not yet packed") {
+		t.Errorf("expected the storage in the plan, got %q", got)
+	}
+
+	// The row groups survive reopening, VACUUM and rolled back inserts
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	executeSQL(t, exec, "BEGIN")
+	for i := 0; i < 600; i++ {
+		executeSQL(t, exec, "INSERT INTO views VALUES (99, 'xx', 1, 'rolled back')")
+	}
+	executeSQL(t, exec, "ROLLBACK")
+	executeSQL(t, exec, "VACUUM")
+	if got := resultText(executeSQL(t, exec, grouped)); got != want {
+		t.Errorf("unexpected groups after reopening %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT agent FROM views WHERE day = 24 AND ms = 49")); got != "agent 6, agent 0" {
+		t.Errorf("unexpected agents %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "PRAGMA integrity_check")); got != "ok" {
+		t.Errorf("unexpected integrity check %q", got)
+	}
+	ddl, _ := exec.TableDDL("views")
+	if len(ddl) != 1 || !strings.HasSuffix(ddl[0], ") USING columnar") {
+		t.Errorf("unexpected DDL %q", ddl)
+	}
+	executeSQL(t, exec, "DROP TABLE views")
+}
+
+func TestColumnarTableErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE facts (a INTEGER, b TEXT) USING columnar")
+	executeSQL(t, exec, "INSERT INTO facts VALUES (1, 'x')")
+
+	for sql, want := range map[string]string{
+		"UPDATE facts SET b = 'y'":                                                     "cannot update columnar table facts",
+		"DELETE FROM facts WHERE a = 1":                                                "cannot delete from columnar table facts",
+		"ALTER TABLE facts ADD COLUMN c INTEGER":                                       "cannot alter columnar table facts",
+		"CREATE INDEX idx_a ON facts (a)":                                              "columnar table facts cannot be indexed",
+		"CREATE TABLE t (a INTEGER PRIMARY KEY) USING columnar":                        "cannot have a primary key",
+		"CREATE TABLE t (a INTEGER) PARTITION BY HASH (a) PARTITIONS 2 USING columnar": "cannot be partitioned",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+	if _, exists := exec.tables["t"]; exists {
+		t.Error("expected no table t left by the failed CREATE TABLEs")
+	}
+}
@@ -0,0 +1,213 @@
+// Package executor - COPY
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// COPY loads the lines of a CSV file into a table, one row per line:
+//
+//   COPY users FROM 'users.csv';
+//   COPY users (name, age) FROM 'users.csv' WITH HEADER;
+//   COPY users FROM STDIN;          -- the CLI sends the lines typed or piped
+//
+// The fields go to the columns listed, or to every column in order, and
+// are read as the text of a value of the column's type; an empty field is
+// NULL, and a column not listed takes its DEFAULT. WITH HEADER skips the
+// first line. The file is read from the server's file system, by the
+// server, as in PostgreSQL; FROM STDIN reads the lines the client sends
+// instead (see CopyFrom).
+//
+// Running the same rows as INSERT statements would parse and plan each,
+// and insert it on its own. COPY streams the file, a line at a time,
+// and into an empty table without triggers it bulk loads (see
+// table.BulkLoader): the rows are packed into fresh pages and the
+// B-trees are built once, from the bottom up, at the end. A load into a
+// table that already has rows inserts them one by one, firing triggers
+// and routing rows to partitions as INSERT would.
+//
+// Every copyFlushRows rows the dirty pages are flushed, rather than left
+// to pile up in the cache until the statement ends. The result reports
+// the rate, in rows per second, which is the figure to compare when
+// tuning a load.
+//
+// A bulk load is all or nothing: a line that fails, or a key that turns
+// out to be a duplicate once the keys are sorted, leaves the table empty.
+// A load row by row keeps the rows before the line that failed, as a
+// batch does (see batch.go); put BEGIN and COMMIT around it to keep none.
+
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// copyFlushRows is how many rows COPY loads between flushes.
+const copyFlushRows = 10000
+
+// CopyFrom runs a COPY ... FROM STDIN statement, reading its lines from
+// r, and stopping with an error wrapping ctx.Err() if ctx is canceled
+// first.
+func (e *Executor) CopyFrom(ctx context.Context, stmt *parser.CopyStatement, r io.Reader) (*Result, error) {
+	e.copyInput = r
+	defer func() { e.copyInput = nil }()
+	return e.ExecuteContext(ctx, stmt)
+}
+
+// executeCopy handles COPY ... FROM statements.
+func (e *Executor) executeCopy(stmt *parser.CopyStatement) (*Result, error) {
+	tableName := strings.ToLower(stmt.Table)
+	if err := e.checkNotVirtual(tableName, "copy into"); err != nil {
+		return nil, err
+	}
+	tbl, exists := e.lookupTable(tableName)
+	if !exists {
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	if err := e.checkNotView(tableName, "copy into"); err != nil {
+		return nil, err
+	}
+	if err := e.checkNotPartition(tableName, "copy into"); err != nil {
+		return nil, err
+	}
+
+	input := e.copyInput
+	if !stmt.Stdin {
+		f, err := os.Open(stmt.File)
+		if err != nil {
+			return nil, fmt.Errorf("cannot copy from %s: %w", stmt.File, err)
+		}
+		defer f.Close()
+		input = f
+	} else if input == nil {
+		return nil, fmt.Errorf("COPY FROM STDIN needs a client that sends the rows, such as the CLI; name a file instead")
+	}
+
+	start := time.Now()
+	rows, err := e.copyRows(tableName, tbl, stmt, input)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if elapsed == 0 {
+		elapsed = time.Since(start).Round(time.Microsecond)
+	}
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(rows) / elapsed.Seconds()
+	}
+	return &Result{
+		Message:  fmt.Sprintf("Copied %d rows in %s (%.0f rows/s)", rows, elapsed, rate),
+		RowCount: rows,
+	}, nil
+}
+
+// copyRows loads the CSV lines of input into a table and returns how many
+// rows it loaded.
+func (e *Executor) copyRows(tableName string, tbl *table.Table, stmt *parser.CopyStatement, input io.Reader) (int, error) {
+	columnOrder, err := insertColumns(tbl, stmt.Columns)
+	if err != nil {
+		return 0, err
+	}
+	reader := csv.NewReader(bufio.NewReader(input))
+	reader.FieldsPerRecord = len(columnOrder)
+	reader.ReuseRecord = true
+	if stmt.Header {
+		if _, err := reader.Read(); err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("copy failed: %w", err)
+		}
+	}
+
+	// Bulk load an empty table, unless triggers must see each row or a
+	// partitioned table must route it
+	var loader *table.BulkLoader
+	if _, partitioned := e.partitions[tableName]; !partitioned && len(e.tableTriggers(tableName)) == 0 {
+		if loader, err = tbl.NewBulkLoader(); err != nil {
+			return 0, err
+		}
+	}
+	fail := func(err error) (int, error) {
+		if loader != nil {
+			if abortErr := loader.Abort(); abortErr != nil {
+				return 0, fmt.Errorf("%w; failed to free the load's pages: %v", err, abortErr)
+			}
+		}
+		return 0, err
+	}
+
+	var loaded []table.Row
+	rows := 0
+	for {
+		if err := e.canceled(); err != nil {
+			return fail(err)
+		}
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("copy failed: %w", err))
+		}
+		line, _ := reader.FieldPos(0)
+
+		values, err := e.defaultValues(tbl, columnOrder)
+		if err != nil {
+			return fail(fmt.Errorf("line %d: %w", line, err))
+		}
+		for i, field := range fields {
+			col := tbl.Schema.Columns[columnOrder[i]]
+			if values[columnOrder[i]], err = copyValue(field, col); err != nil {
+				return fail(fmt.Errorf("line %d, column %s: %w", line, col.Name, err))
+			}
+		}
+
+		if loader != nil {
+			var rowID uint64
+			rowID, err = loader.Add(values)
+			loaded = append(loaded, table.Row{ID: rowID})
+		} else {
+			_, err = e.insertRow(tableName, tbl, values)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("line %d: %w", line, err))
+		}
+
+		rows++
+		if rows%copyFlushRows == 0 {
+			if err := e.Flush(); err != nil {
+				return fail(fmt.Errorf("failed to flush: %w", err))
+			}
+		}
+	}
+
+	if loader != nil {
+		if err := loader.Finish(); err != nil {
+			return fail(err)
+		}
+		if err := e.lockRows(tableName, loaded); err != nil {
+			return 0, err
+		}
+		e.autoAnalyze(tbl)
+	}
+	return rows, nil
+}
+
+// copyValue converts a field of a COPY line to a value of a column's
+// type. An empty field is NULL.
+func copyValue(field string, col table.Column) (table.Value, error) {
+	if field == "" {
+		return table.Value{Type: col.Type, IsNull: true}, nil
+	}
+	if col.Type == parser.TypeDecimal {
+		return fitDecimal(textValue(field), col.Precision, col.Scale)
+	}
+	return table.Cast(textValue(field), col.Type)
+}
@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+	var sb strings.Builder
+	sb.WriteString("id,name,score\n")
+	for i := 1; i <= 25000; i++ {
+		fmt.Fprintf(&sb, "%d,user %d,%d.5\n", i, i, i%10)
+	}
+	sb.WriteString("25001,\"Smith, Jo\",\n")
+	csvPath := filepath.Join(dir, "users.csv")
+	if err := os.WriteFile(csvPath, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "copy.db")
+	exec, pager := openCatalogExecutor(t, path)
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, score REAL, active BOOLEAN DEFAULT TRUE)")
+	executeSQL(t, exec, "CREATE INDEX idx_score ON users (score)")
+
+	result := executeSQL(t, exec, "COPY users (id, name, score) FROM '"+csvPath+"' WITH HEADER")
+	if result.RowCount != 25001 || !strings.HasPrefix(result.Message, "Copied 25001 rows in ") || !strings.HasSuffix(result.Message, " rows/s)") {
+		t.Errorf("unexpected result %d %q", result.RowCount, result.Message)
+	}
+	for sql, want := range map[string]string{
+		"SELECT COUNT(*), COUNT(score) FROM users":                                   "25001 25000",
+		"SELECT name, active FROM users WHERE id = 25001":                            "Smith, Jo TRUE",
+		"SELECT COUNT(*) FROM users WHERE score = 3.5":                               "2500",
+		"SELECT id FROM users WHERE id > 24998 ORDER BY id":                          "24999, 25000, 25001",
+		"SELECT row_count FROM information_schema.tables WHERE table_name = 'users'": "25001",
+	} {
+		if got := resultText(executeSQL(t, exec, sql)); got != want {
+			t.Errorf("%s: expected %q, got %q", sql, want, got)
+		}
+	}
+
+	// A table with rows is loaded row by row, from the client
+	input := "25002,late,1\n25003,later,2\n"
+	result, err := exec.CopyFrom(context.Background(), parseSQL(t, "COPY users (id, name, score) FROM STDIN").(*parser.CopyStatement), strings.NewReader(input))
+	if err != nil || result.RowCount != 2 {
+		t.Fatalf("expected 2 rows copied from the client, got %v, %v", result, err)
+	}
+
+	// The loaded rows survive reopening
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM users WHERE id >= 25000")); got != "4" {
+		t.Errorf("expected 4 rows after reopening, got %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users WHERE id = 12345")); got != "user 12345" {
+		t.Errorf("unexpected row after reopening %q", got)
+	}
+}
+
+func TestCopyErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(data), 0o644)
+		return path
+	}
+	good := write("good.csv", "1,a\n")
+	short := write("short.csv", "1,a\n2\n")
+	badInt := write("bad.csv", "1,a\nlots,b\n")
+	duplicate := write("duplicate.csv", "1,a\n2,b\n1,c\n")
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "CREATE VIRTUAL TABLE v USING csv('"+write("v.csv", "a\n1\n")+"')")
+
+	for sql, want := range map[string]string{
+		"COPY missing FROM '" + good + "'":      "table missing does not exist",
+		"COPY v FROM '" + good + "'":            "cannot copy into virtual table v",
+		"COPY t FROM '" + dir + "/missing.csv'": "no such file",
+		"COPY t (id, nope) FROM '" + good + "'": "unknown column: nope",
+		"COPY t FROM '" + short + "'":           "wrong number of fields",
+		"COPY t FROM '" + badInt + "'":          "line 2, column id: cannot cast 'lots' to INTEGER",
+		"COPY t FROM '" + duplicate + "'":       "rows 1 and 3 of the load have the same key",
+		"COPY t FROM STDIN":                     "COPY FROM STDIN needs a client",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+
+	// A failed bulk load leaves the table empty; a load row by row keeps
+	// the rows before the line that failed
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM t")); got != "0" {
+		t.Errorf("expected no rows after failed bulk loads, got %q", got)
+	}
+	executeSQL(t, exec, "INSERT INTO t VALUES (10, 'x')")
+	if _, err := exec.Execute(parseSQL(t, "COPY t FROM '"+duplicate+"'")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected the duplicate on line 3 to fail, got %v", err)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM t ORDER BY id")); got != "1, 2, 10" {
+		t.Errorf("expected the rows before the failure to be kept, got %q", got)
+	}
+}
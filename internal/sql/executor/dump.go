@@ -0,0 +1,177 @@
+// Package executor - Dumping a database as SQL
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A dump is a logical backup: the SQL statements that build the database
+// again, rather than a copy of its pages:
+//
+//   BEGIN;
+//   CREATE SEQUENCE ids START WITH 1020 INCREMENT BY 10;
+//   CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+//   INSERT INTO users (id, name) VALUES (1, 'Alice');
+//   INSERT INTO users (id, name) VALUES (2, 'O''Brien');
+//   CREATE INDEX idx_users_name ON users (name);
+//   COMMIT;
+//
+// A copy of the file only opens in a build that reads the same page
+// format; a dump replays into any version, or (with little editing) into
+// another database, and can be read and diffed. The price is time: every
+// row is inserted and every index built again.
+//
+// The order of the statements matters. Sequences come first, since a
+// column's DEFAULT may call NEXTVAL. Each table's indexes are created
+// after its rows are inserted, building each index once from all the
+// rows rather than growing it an insert at a time, and the triggers come
+// last of all, so that replaying the INSERTs doesn't fire them. A
+// materialized view is created from the tables it reads, so it follows
+// them; it holds what its query returns when the dump is replayed, which
+// is what REFRESH would have given it. Virtual tables are dumped as their
+// CREATE statements only: their rows are in files outside the database.
+//
+// The whole dump is one transaction, so a replay that fails part way
+// leaves nothing behind. Temporary tables and attached databases aren't
+// part of the database, and aren't dumped. sqlite3's .dump and pg_dump
+// work the same way; pg_dump also adds foreign keys only after all the
+// rows are loaded, for the same reason as the indexes.
+
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// Dump writes the SQL statements that recreate the database and its rows
+// to w, each ending with a semicolon and a newline.
+func (e *Executor) Dump(w io.Writer) error {
+	leave, err := e.enterAccess(false)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	out := bufio.NewWriter(w)
+	statement := func(sql string) {
+		out.WriteString(sql)
+		out.WriteString(";\n")
+	}
+	statement("BEGIN")
+
+	for _, name := range slices.Sorted(maps.Keys(e.sequences)) {
+		s := e.sequences[name]
+		start := s.start
+		if s.called {
+			start = s.last + s.increment
+		}
+		statement(fmt.Sprintf("CREATE SEQUENCE %s START WITH %d INCREMENT BY %d", s.name, start, s.increment))
+	}
+
+	own := e.ownTables()
+	names := slices.Sorted(maps.Keys(own))
+	for _, name := range names {
+		if _, isPartition := e.partitionParent(name); isPartition || e.views[name] != nil {
+			continue
+		}
+		tbl := own[name]
+		statement(e.createSQL(name, tbl))
+		sources := []*table.Table{tbl}
+		if pt, ok := e.partitions[name]; ok {
+			sources = sources[:0]
+			for _, part := range pt.parts {
+				sources = append(sources, e.tables[part.table])
+			}
+		}
+		for _, src := range sources {
+			if err := dumpRows(out, name, src); err != nil {
+				return fmt.Errorf("failed to dump table %s: %w", name, err)
+			}
+		}
+		for _, sql := range e.indexDDL(name, tbl) {
+			statement(sql)
+		}
+		if pt, ok := e.partitions[name]; ok {
+			for _, part := range pt.parts {
+				for _, sql := range e.indexDDL(part.table, e.tables[part.table]) {
+					statement(sql)
+				}
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(e.views)) {
+		statement(e.views[name].sql)
+		for _, sql := range e.indexDDL(name, e.tables[name]) {
+			statement(sql)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(e.virtual)) {
+		statement(e.virtual[name].sql)
+	}
+	for _, name := range names {
+		for _, t := range e.tableTriggers(name) {
+			statement(t.sql)
+		}
+	}
+
+	statement("COMMIT")
+	return out.Flush()
+}
+
+// dumpRows writes an INSERT into the table called name for each row of
+// tbl, in primary key order.
+func dumpRows(out *bufio.Writer, name string, tbl *table.Table) error {
+	columns := make([]string, len(tbl.Schema.Columns))
+	for i, col := range tbl.Schema.Columns {
+		columns[i] = col.Name
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES (", name, strings.Join(columns, ", "))
+
+	it := tbl.Iterator()
+	defer it.Close()
+	for it.Next() {
+		out.WriteString(prefix)
+		for i, val := range it.Row().Values {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			out.WriteString(sqlLiteral(val))
+		}
+		if _, err := out.WriteString(");\n"); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// sqlLiteral returns the SQL for a value, which reads back as the same
+// value of the same type.
+func sqlLiteral(v table.Value) string {
+	if v.IsNull {
+		return "NULL"
+	}
+	switch v.Type {
+	case parser.TypeInteger, parser.TypeBoolean:
+		return v.String()
+	case parser.TypeReal:
+		// A REAL needs its decimal point, or it reads back as an INTEGER
+		text := strconv.FormatFloat(v.Real, 'f', -1, 64)
+		if !strings.Contains(text, ".") {
+			text += ".0"
+		}
+		return text
+	case parser.TypeDecimal:
+		// As a number it would be read as a REAL, losing digits
+		return "CAST('" + v.String() + "' AS DECIMAL)"
+	default:
+		// TEXT, and TIMESTAMP and JSON, which are written as text
+		return "'" + strings.ReplaceAll(v.String(), "'", "''") + "'"
+	}
+}
@@ -16,12 +16,18 @@
 package executor
 
 import (
-	"container/heap"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/lock"
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
 	"github.com/cabewaldrop/claude-db/internal/sql/planner"
 	"github.com/cabewaldrop/claude-db/internal/storage"
@@ -34,140 +40,448 @@ type Result struct {
 	Rows     [][]table.Value
 	RowCount int
 	Message  string
+	NullText string // How String shows NULL; "" shows it as NULL
+
+	// How the statement ran (see metrics.go)
+	RowsScanned  int           // Rows read from tables
+	PagesRead    uint64        // Page requests made
+	IndexUsed    string        // Indexes read through, "" for none
+	Duration     time.Duration // Time taken
+	PlanDuration time.Duration // Time taken planning, part of Duration
 }
 
 // String formats the result for display.
 func (r *Result) String() string {
+	var sb strings.Builder
+	r.WriteTo(&sb)
+	return sb.String()
+}
+
+// WriteTo writes the result to w as String formats it, a row at a time,
+// so that a large result isn't held in memory twice: once as rows and
+// again as text.
+func (r *Result) WriteTo(w io.Writer) (int64, error) {
+	return r.writeTable(w, false)
+}
+
+// writeTable writes the result as WriteTo does, with NULLs highlighted
+// and every other row shaded when color is set (see format.go).
+func (r *Result) writeTable(w io.Writer, color bool) (int64, error) {
 	if r.Message != "" {
-		return r.Message
+		n, err := io.WriteString(w, r.Message)
+		return int64(n), err
 	}
-
 	if len(r.Rows) == 0 {
-		return "(no rows)"
+		n, err := io.WriteString(w, "(no rows)")
+		return int64(n), err
 	}
 
-	var sb strings.Builder
-
-	// Calculate column widths
+	// Calculate column widths, in characters as fmt pads them
 	widths := make([]int, len(r.Columns))
 	for i, col := range r.Columns {
-		widths[i] = len(col)
+		widths[i] = utf8.RuneCountInString(col)
 	}
 	for _, row := range r.Rows {
 		for i, val := range row {
-			if len(val.String()) > widths[i] {
-				widths[i] = len(val.String())
-			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(r.format(val)))
 		}
 	}
+	border := "+"
+	for _, width := range widths {
+		border += strings.Repeat("-", width+2) + "+"
+	}
+
+	counter := &countingWriter{w: w}
+	out := bufio.NewWriter(counter)
 
 	// Print header
-	sb.WriteString("+")
-	for _, w := range widths {
-		sb.WriteString(strings.Repeat("-", w+2))
-		sb.WriteString("+")
-	}
-	sb.WriteString("\n|")
+	fmt.Fprintln(out, border)
+	out.WriteString("|")
 	for i, col := range r.Columns {
-		sb.WriteString(fmt.Sprintf(" %-*s |", widths[i], col))
-	}
-	sb.WriteString("\n+")
-	for _, w := range widths {
-		sb.WriteString(strings.Repeat("-", w+2))
-		sb.WriteString("+")
+		fmt.Fprintf(out, " %-*s |", widths[i], col)
 	}
-	sb.WriteString("\n")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, border)
 
 	// Print rows
-	for _, row := range r.Rows {
-		sb.WriteString("|")
+	for n, row := range r.Rows {
+		out.WriteString("|")
 		for i, val := range row {
-			sb.WriteString(fmt.Sprintf(" %-*s |", widths[i], val.String()))
+			cell := fmt.Sprintf(" %-*s ", widths[i], r.format(val))
+			if color {
+				cell = colorCell(cell, val.IsNull, n%2 == 1)
+			}
+			out.WriteString(cell)
+			out.WriteString("|")
+		}
+		if _, err := out.WriteString("\n"); err != nil {
+			return counter.n, err
 		}
-		sb.WriteString("\n")
 	}
 
 	// Print footer
-	sb.WriteString("+")
-	for _, w := range widths {
-		sb.WriteString(strings.Repeat("-", w+2))
-		sb.WriteString("+")
-	}
-	sb.WriteString("\n")
-	sb.WriteString(fmt.Sprintf("(%d rows)\n", len(r.Rows)))
+	fmt.Fprintln(out, border)
+	fmt.Fprintf(out, "(%d rows)\n", len(r.Rows))
+	err := out.Flush()
+	return counter.n, err
+}
 
-	return sb.String()
+// countingWriter counts the bytes written through it, for WriteTo.
+type countingWriter struct {
+	w io.Writer
+	n int64
 }
 
-// Executor executes SQL statements.
-type Executor struct {
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// format returns the text String shows for a value.
+func (r *Result) format(val table.Value) string {
+	if val.IsNull && r.NullText != "" {
+		return r.NullText
+	}
+	return val.String()
+}
+
+// database is the state the sessions of a database share: its tables
+// and everything else CREATE makes (see session.go).
+type database struct {
 	pager   *storage.Pager
 	catalog *catalog.Catalog
 	tables  map[string]*table.Table
 	planner *planner.Planner
+
+	// gate lets one session write, or any number of sessions read, at a
+	// time (see session.go).
+	gate gate
+
+	// functions holds scalar functions added with RegisterFunction.
+	functions map[string]ScalarFunction
+
+	// locks grants row locks (see locking.go).
+	locks *lock.Manager
+
+	// attached holds the databases opened with ATTACH, by alias; their
+	// tables are in tables under qualified names (see attach.go).
+	attached map[string]*attachedDatabase
+
+	// triggers holds the tables' triggers, by name (see trigger.go).
+	triggers map[string]*trigger
+
+	// sequences holds the sequences, by name (see sequence.go).
+	sequences map[string]*sequence
+
+	// views holds the materialized views, by name; their rows are in the
+	// table of the same name (see matview.go).
+	views map[string]*materializedView
+
+	// partitions holds the partitioned tables, by name; their rows are in
+	// the tables of their partitions (see partition.go).
+	partitions map[string]*partitionedTable
+
+	// virtual holds the virtual tables, by name; their rows are read from
+	// files outside the database (see virtual.go).
+	virtual map[string]*virtualTable
+}
+
+// Executor executes SQL statements. Each Executor is a session of its
+// database; NewSession opens another.
+type Executor struct {
+	*database
+
+	// access is what the session may do to the database right now,
+	// which a statement or transaction acquires from the gate (see
+	// session.go).
+	access access
+
+	// params holds the values bound to ? placeholders for the statement
+	// currently being executed (see ExecuteWithParams).
+	params []table.Value
+
+	// copyInput holds the rows the client sends for the COPY ... FROM
+	// STDIN being executed (see CopyFrom).
+	copyInput io.Reader
+
+	// ctx is the context of the statement being executed, nil if it
+	// can't be canceled (see cancel.go).
+	ctx context.Context
+
+	// statementTimeout is how long a statement may run, 0 for no limit
+	// (see settings.go).
+	statementTimeout time.Duration
+
+	// outputNull is how results show NULL, "" for NULL (see settings.go).
+	outputNull string
+
+	// typing is how INSERT and UPDATE store values of another type than
+	// their column's (see typing.go).
+	typing TypingMode
+
+	// prepared is the prepared statement being executed, whose plan can
+	// be reused, or nil (see prepare.go).
+	prepared *Stmt
+
+	// trace collects per-step statistics while EXPLAIN ANALYZE runs a
+	// statement; nil otherwise (see explain.go).
+	trace *executionTrace
+
+	// tx is the open transaction, or nil in autocommit mode
+	// (see transaction.go).
+	tx *transaction
+
+	// temp holds the pages of the session's temporary tables, nil until
+	// one is created; tempTables and tempTriggers hold the tables and
+	// their triggers, by name (see temp.go).
+	temp         *storage.Pager
+	tempTables   map[string]*table.Table
+	tempTriggers map[string]*trigger
+
+	// stmtOwner owns the row locks of the current autocommit statement,
+	// or is 0 (see locking.go). inBatch keeps them until the end of the
+	// batch being executed (see batch.go).
+	stmtOwner lock.Owner
+	inBatch   bool
+
+	// sortMemory is how many bytes of rows a sort may hold before it
+	// spills to disk (see sort.go).
+	sortMemory int
+
+	// queryMemory is how many bytes a statement may hold in memory, and
+	// memory counts those of the statement being executed (see memory.go).
+	queryMemory int
+	memory      *memoryAccount
+
+	// metrics counts what the statement being executed reads, for its
+	// result (see metrics.go).
+	metrics *statementMetrics
+
+	// analyzeThreshold and analyzeScale decide when a table that has
+	// changed is analyzed again (see autoanalyze.go).
+	analyzeThreshold int
+	analyzeScale     float64
+
+	// firing holds the tables whose change is running triggers (see
+	// trigger.go).
+	firing map[string]bool
+
+	// currvals holds the value NEXTVAL last returned for each sequence in
+	// this session (see sequence.go).
+	currvals map[string]int64
 }
 
 // New creates a new Executor.
 func New(pager *storage.Pager) *Executor {
 	return &Executor{
-		pager:   pager,
-		tables:  make(map[string]*table.Table),
-		planner: planner.New(),
+		database: &database{
+			pager:   pager,
+			tables:  make(map[string]*table.Table),
+			planner: planner.New(),
+			locks:   lock.NewManager(),
+		},
+		sortMemory:       defaultSortMemory,
+		queryMemory:      defaultQueryMemory,
+		analyzeThreshold: defaultAnalyzeThreshold,
+		analyzeScale:     defaultAnalyzeScale,
 	}
 }
 
 // NewWithCatalog creates an Executor with catalog support for persistence.
 func NewWithCatalog(pager *storage.Pager, cat *catalog.Catalog) (*Executor, error) {
 	e := &Executor{
-		pager:   pager,
-		catalog: cat,
-		tables:  make(map[string]*table.Table),
-		planner: planner.New(),
+		database: &database{
+			pager:   pager,
+			catalog: cat,
+			tables:  make(map[string]*table.Table),
+			planner: planner.New(),
+			locks:   lock.NewManager(),
+		},
+		sortMemory:       defaultSortMemory,
+		queryMemory:      defaultQueryMemory,
+		analyzeThreshold: defaultAnalyzeThreshold,
+		analyzeScale:     defaultAnalyzeScale,
+	}
+
+	if err := e.load(); err != nil {
+		return nil, err
 	}
+	return e, nil
+}
 
-	// Load existing tables from catalog
-	for _, name := range cat.ListTables() {
-		tbl, err := cat.LoadTable(name, pager)
+// load reads the tables of the catalog, and the triggers, sequences,
+// views and virtual tables saved with them.
+func (e *Executor) load() error {
+	for _, name := range e.catalog.ListTables() {
+		tbl, err := e.catalog.LoadTable(name, e.pager)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load table %s: %w", name, err)
+			return fmt.Errorf("failed to load table %s: %w", name, err)
 		}
 		e.tables[name] = tbl
 	}
-
-	return e, nil
+	if err := e.loadTriggers(); err != nil {
+		return err
+	}
+	e.loadSequences()
+	if err := e.loadViews(); err != nil {
+		return err
+	}
+	if err := e.loadPartitions(); err != nil {
+		return err
+	}
+	return e.loadVirtualTables()
 }
 
-// Flush ensures all changes are written to disk.
+// Flush ensures all changes are written to disk, those of the attached
+// databases included. Inside a transaction nothing is written until COMMIT.
 func (e *Executor) Flush() error {
+	leave, err := e.enterAccess(true)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	if err := e.syncCatalog(); err != nil {
+		return err
+	}
+	for _, alias := range e.AttachedDatabases() {
+		if err := e.attached[alias].catalog.Flush(); err != nil {
+			return fmt.Errorf("failed to flush %s: %w", alias, err)
+		}
+	}
 	if e.catalog != nil {
-		return e.catalog.Flush()
+		err = e.catalog.Flush()
+	} else {
+		err = e.pager.FlushAll()
+	}
+	if errors.Is(err, storage.ErrDiskFull) {
+		return e.discardChanges(err)
+	}
+	return err
+}
+
+// ExecuteWithParams runs a statement containing ? placeholders, binding
+// args to them in order of appearance.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// The values are bound at execution time rather than spliced into the SQL
+// text, so a string like "'; DROP TABLE users; --" is just a string. The
+// AST is never modified, which means the same parsed statement can be
+// executed again with different arguments.
+func (e *Executor) ExecuteWithParams(stmt parser.Statement, args []table.Value) (*Result, error) {
+	if want := parser.CountPlaceholders(stmt); want != len(args) {
+		return nil, fmt.Errorf("statement expects %d parameter(s), got %d", want, len(args))
 	}
-	return e.pager.FlushAll()
+
+	e.params = args
+	defer func() { e.params = nil }()
+
+	return e.Execute(stmt)
 }
 
 // Execute runs a SQL statement and returns the result.
-func (e *Executor) Execute(stmt parser.Statement) (*Result, error) {
+func (e *Executor) Execute(stmt parser.Statement) (result *Result, err error) {
+	if e.params == nil && parser.CountPlaceholders(stmt) > 0 {
+		return nil, fmt.Errorf("statement has unbound parameters; use ExecuteWithParams")
+	}
+	defer func() {
+		if result != nil {
+			result.NullText = e.outputNull
+		}
+	}()
+	defer e.measure(&result)()
+	defer e.releaseStatementLocks()
+	defer e.withMemoryAccount(e.newMemoryAccount())()
+	ctx, cancel := e.statementContext()
+	defer cancel()
+	defer e.withContext(ctx)()
+	autocommit := e.access == noAccess && e.tx == nil
+	leave, err := e.enter(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	if autocommit {
+		defer func() {
+			if errors.Is(err, storage.ErrDiskFull) && e.tx == nil {
+				result, err = nil, e.discardChanges(err)
+			}
+		}()
+	}
+
 	switch s := stmt.(type) {
 	case *parser.CreateTableStatement:
 		return e.executeCreateTable(s)
+	case *parser.CreateVirtualTableStatement:
+		return e.executeCreateVirtualTable(s)
 	case *parser.DropTableStatement:
 		return e.executeDropTable(s)
+	case *parser.AlterTableStatement:
+		return e.executeAlterTable(s)
 	case *parser.CreateIndexStatement:
 		return e.executeCreateIndex(s)
 	case *parser.DropIndexStatement:
 		return e.executeDropIndex(s)
+	case *parser.CreateTriggerStatement:
+		return e.executeCreateTrigger(s)
+	case *parser.DropTriggerStatement:
+		return e.executeDropTrigger(s)
+	case *parser.CreateSequenceStatement:
+		return e.executeCreateSequence(s)
+	case *parser.DropSequenceStatement:
+		return e.executeDropSequence(s)
+	case *parser.CreateMaterializedViewStatement:
+		return e.executeCreateView(s)
+	case *parser.RefreshMaterializedViewStatement:
+		return e.executeRefreshView(s)
+	case *parser.DropMaterializedViewStatement:
+		return e.executeDropView(s)
 	case *parser.InsertStatement:
-		return e.executeInsert(s)
+		return e.atomically(s, func() (*Result, error) { return e.executeInsert(s) })
+	case *parser.CopyStatement:
+		return e.executeCopy(s)
 	case *parser.SelectStatement:
 		return e.executeSelect(s)
 	case *parser.UpdateStatement:
-		return e.executeUpdate(s)
+		return e.atomically(s, func() (*Result, error) { return e.executeUpdate(s) })
 	case *parser.DeleteStatement:
-		return e.executeDelete(s)
+		return e.atomically(s, func() (*Result, error) { return e.executeDelete(s) })
 	case *parser.ExplainStatement:
+		if s.Format != parser.ExplainText {
+			return e.explainFormatted(s)
+		}
+		if s.Analyze {
+			return e.ExplainAnalyze(s.Statement)
+		}
 		return e.Explain(s.Statement)
 	case *parser.AnalyzeStatement:
 		return e.executeAnalyze(s)
+	case *parser.VacuumStatement:
+		return e.executeVacuum()
+	case *parser.PragmaStatement:
+		return e.executePragma(s)
+	case *parser.SetStatement:
+		return e.executeSet(s)
+	case *parser.ShowStatement:
+		return e.executeShow(s)
+	case *parser.DescribeStatement:
+		return e.executeDescribe(s)
+	case *parser.AttachStatement:
+		return e.executeAttach(s)
+	case *parser.DetachStatement:
+		return e.executeDetach(s)
+	case *parser.BeginStatement:
+		return e.executeBegin()
+	case *parser.CommitStatement:
+		return e.executeCommit()
+	case *parser.RollbackStatement:
+		return e.executeRollback(s)
+	case *parser.SavepointStatement:
+		return e.executeSavepoint(s)
+	case *parser.ReleaseStatement:
+		return e.executeRelease(s)
 	default:
 		return nil, fmt.Errorf("unsupported statement type: %T", stmt)
 	}
@@ -181,9 +495,21 @@ func (e *Executor) Execute(stmt parser.Statement) (*Result, error) {
 // execute a query without actually running it. This is invaluable for
 // understanding and optimizing query performance.
 func (e *Executor) Explain(stmt parser.Statement) (*Result, error) {
+	leave, err := e.enterAccess(false)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
 	switch s := stmt.(type) {
 	case *parser.SelectStatement:
 		return e.explainSelect(s)
+	case *parser.InsertStatement:
+		return e.explainInsert(s)
+	case *parser.UpdateStatement:
+		return e.explainUpdate(s)
+	case *parser.DeleteStatement:
+		return e.explainDelete(s)
 	default:
 		return nil, fmt.Errorf("EXPLAIN not supported for statement type: %T", stmt)
 	}
@@ -191,17 +517,90 @@ func (e *Executor) Explain(stmt parser.Statement) (*Result, error) {
 
 // explainSelect returns the query plan for a SELECT statement.
 func (e *Executor) explainSelect(stmt *parser.SelectStatement) (*Result, error) {
+	if stmt.From == "" {
+		return &Result{
+			Columns: []string{"Property", "Value"},
+			Rows: [][]table.Value{
+				{{Type: parser.TypeText, Text: "Query Plan"}, {Type: parser.TypeText, Text: "Result (no table)"}},
+				{{Type: parser.TypeText, Text: "Access Method"}, {Type: parser.TypeText, Text: "None"}},
+				{{Type: parser.TypeText, Text: "Estimated Cost"}, {Type: parser.TypeText, Text: "0.00"}},
+			},
+		}, nil
+	}
+	if stmt.FromFunc != nil {
+		return e.explainTableFunction(stmt)
+	}
+	if len(stmt.Joins) > 0 {
+		return e.explainJoin(stmt)
+	}
+	if e.isVirtualTable(stmt.From) {
+		return e.explainVirtualTable(stmt)
+	}
+
 	tableName := strings.ToLower(stmt.From)
 
-	tbl, exists := e.tables[tableName]
+	tbl, exists := e.lookupTable(tableName)
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
 
-	// Generate query plan
-	plan := e.planner.PlanSelect(stmt, tbl.Schema)
+	if pt, ok := e.partitions[tableName]; ok {
+		return e.explainPartitions(stmt, pt)
+	}
 
-	// Format the plan as a result
+	// Generate query plan, by cost once ANALYZE has gathered statistics
+	if err := e.checkHints(stmt); err != nil {
+		return nil, err
+	}
+	plan := e.selectPlan(stmt, tbl)
+	_, agg, err := selectProjection(stmt, tbl.Schema)
+	if err != nil {
+		return nil, err
+	}
+	execPlan := NewPlannerWithParams(e.params).Plan(stmt, tbl)
+	if agg != nil {
+		agg.chooseAggregate(tbl, execPlan)
+	}
+
+	// A scan read in index order for ORDER BY is reported as the ordered
+	// index scan it runs as
+	ordered, orderScan, orderIndex := indexOrder(stmt, tbl, execPlan, agg)
+	if orderScan {
+		plan.AccessMethod, plan.IndexName = planner.OrderedIndexScan, ""
+		if orderIndex != nil {
+			plan.IndexName = orderIndex.Name
+		}
+		plan.EstimatedCost = orderedScanNode(tbl, orderIndex).Estimate.Cost
+	}
+
+	rows := planRows(plan)
+	if plan.Hint != nil {
+		rows = append(rows, textRow("Hint", plan.Hint.String()))
+	}
+	if tbl.IsColumnar() {
+		rows = append(rows, textRow("Storage", columnarStorage(tbl)))
+	}
+	if agg != nil {
+		rows = append(rows, textRow("Aggregate", agg.method.String()+" "+agg.detail()))
+	}
+	if len(stmt.OrderBy) > 0 {
+		order := "sort"
+		if orderScan {
+			order = "ordered index scan of the " + orderedScanIndex(orderIndex) + ", no sort"
+		} else if ordered {
+			order = "index order, no sort"
+		}
+		rows = append(rows, textRow("Order By", orderByString(stmt.OrderBy)+" ("+order+")"))
+	}
+
+	return &Result{
+		Columns: []string{"Property", "Value"},
+		Rows:    rows,
+	}, nil
+}
+
+// planRows formats a query plan as Property/Value rows.
+func planRows(plan *planner.QueryPlan) [][]table.Value {
 	var rows [][]table.Value
 	rows = append(rows, []table.Value{
 		{Type: parser.TypeText, Text: "Query Plan"},
@@ -223,7 +622,7 @@ func (e *Executor) explainSelect(stmt *parser.SelectStatement) (*Result, error)
 		})
 		for i, pred := range plan.Predicates {
 			indexNote := ""
-			if pred.IsOnPK {
+			if pred.IsOnPK || pred.Index != "" {
 				indexNote = " (indexed)"
 			}
 			rows = append(rows, []table.Value{
@@ -233,10 +632,103 @@ func (e *Executor) explainSelect(stmt *parser.SelectStatement) (*Result, error)
 		}
 	}
 
-	return &Result{
-		Columns: []string{"Property", "Value"},
-		Rows:    rows,
-	}, nil
+	return rows
+}
+
+// explainInsert describes an INSERT: where the row goes and which
+// indexes must be maintained. An INSERT reads no existing rows, so there
+// is no access method to choose.
+func (e *Executor) explainInsert(stmt *parser.InsertStatement) (*Result, error) {
+	tbl, err := e.explainTarget(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]table.Value{
+		textRow("Operation", "INSERT"),
+		textRow("Target Table", tbl.Name),
+		textRow("Rows to Insert", "1"),
+	}
+	rows = append(rows, textRow("Indexes Updated", indexesUpdated(tbl)))
+
+	return &Result{Columns: []string{"Property", "Value"}, Rows: rows}, nil
+}
+
+// explainUpdate describes how an UPDATE finds its rows and what it changes.
+func (e *Executor) explainUpdate(stmt *parser.UpdateStatement) (*Result, error) {
+	tbl, err := e.explainTarget(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]string, len(stmt.Assignments))
+	for i, a := range stmt.Assignments {
+		assignments[i] = fmt.Sprintf("%s = %s", a.Column, expressionName(a.Value))
+	}
+
+	rows := [][]table.Value{
+		textRow("Operation", "UPDATE"),
+		textRow("Target Table", tbl.Name),
+	}
+	rows = append(rows, planRows(e.planner.PlanUpdate(stmt, tbl.Schema, indexInfos(tbl)...))...)
+	rows = append(rows,
+		textRow("Assignments", strings.Join(assignments, ", ")),
+		textRow("Indexes Updated", indexesUpdated(tbl)),
+	)
+
+	return &Result{Columns: []string{"Property", "Value"}, Rows: rows}, nil
+}
+
+// explainDelete describes how a DELETE finds the rows it removes.
+func (e *Executor) explainDelete(stmt *parser.DeleteStatement) (*Result, error) {
+	tbl, err := e.explainTarget(stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]table.Value{
+		textRow("Operation", "DELETE"),
+		textRow("Target Table", tbl.Name),
+	}
+	rows = append(rows, planRows(e.planner.PlanDelete(stmt, tbl.Schema, indexInfos(tbl)...))...)
+	rows = append(rows, textRow("Indexes Updated", indexesUpdated(tbl)))
+
+	return &Result{Columns: []string{"Property", "Value"}, Rows: rows}, nil
+}
+
+// explainTarget looks up the table a DML statement writes to.
+func (e *Executor) explainTarget(name string) (*table.Table, error) {
+	tableName := strings.ToLower(name)
+	tbl, exists := e.lookupTable(tableName)
+	if !exists {
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	return tbl, nil
+}
+
+// indexesUpdated lists the indexes a write to tbl has to maintain.
+func indexesUpdated(tbl *table.Table) string {
+	var names []string
+	if len(tbl.Schema.PrimaryKeyColumns) > 0 {
+		names = append(names, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(tbl.Schema.PrimaryKeyNames(), ", ")))
+	}
+	secondary := tbl.ListIndexes()
+	sort.Strings(secondary)
+	names = append(names, secondary...)
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// indexInfos describes tbl's single-column secondary indexes to the
+// planner, in the order the executor's planner tries them.
+func indexInfos(tbl *table.Table) []planner.IndexInfo {
+	var infos []planner.IndexInfo
+	for _, idx := range singleColumnIndexes(tbl) {
+		infos = append(infos, planner.IndexInfo{Name: idx.Name, Column: idx.Columns[0], Unique: idx.Unique})
+	}
+	return infos
 }
 
 // AnalyzeWhere analyzes a WHERE clause and returns analysis information.
@@ -247,14 +739,26 @@ func (e *Executor) AnalyzeWhere(where parser.Expression, schema *table.Schema) *
 
 // GetQueryPlan generates a query plan for a SELECT statement.
 func (e *Executor) GetQueryPlan(stmt *parser.SelectStatement) (*planner.QueryPlan, error) {
+	leave, err := e.enterAccess(false)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
 	tableName := strings.ToLower(stmt.From)
 
-	tbl, exists := e.tables[tableName]
+	tbl, exists := e.lookupTable(tableName)
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
 
-	return e.planner.PlanSelect(stmt, tbl.Schema), nil
+	return e.selectPlan(stmt, tbl), nil
+}
+
+// selectPlan plans a SELECT from tbl the way executeSelect will run it.
+func (e *Executor) selectPlan(stmt *parser.SelectStatement, tbl *table.Table) *planner.QueryPlan {
+	stats, indexStats := tbl.Stats(), tbl.IndexStats()
+	return e.planner.PlanSelectWithStats(stmt, tbl.Schema, &stats, &indexStats, indexInfos(tbl)...)
 }
 
 // executeCreateTable handles CREATE TABLE statements.
@@ -262,27 +766,87 @@ func (e *Executor) executeCreateTable(stmt *parser.CreateTableStatement) (*Resul
 	tableName := strings.ToLower(stmt.Table)
 
 	// Check if table already exists
-	if _, exists := e.tables[tableName]; exists {
+	if _, exists := e.lookupTable(tableName); exists || isSystemView(tableName) || e.isVirtualTable(tableName) {
 		return nil, fmt.Errorf("table %s already exists", tableName)
 	}
 
+	// A qualified name creates the table in an attached database, and
+	// TEMP in the session's temporary one
+	pager, cat, name := e.pager, e.catalog, tableName
+	if stmt.Temporary {
+		if strings.Contains(tableName, ".") {
+			return nil, fmt.Errorf("cannot create temporary table %s in another database", tableName)
+		}
+		var err error
+		if pager, err = e.tempPager(); err != nil {
+			return nil, err
+		}
+		cat = nil
+	} else if strings.Contains(tableName, ".") {
+		db, inner := e.databaseOf(tableName)
+		if db == nil {
+			return nil, fmt.Errorf("cannot create table %s: no database %s is attached",
+				tableName, tableName[:strings.Index(tableName, ".")])
+		}
+		pager, cat, name = db.pager, db.catalog, inner
+	}
+
 	// Create schema
 	schema := table.NewSchema(stmt.Columns)
+	if stmt.PrimaryKeyColumns != nil {
+		if err := schema.SetPrimaryKey(stmt.PrimaryKeyColumns); err != nil {
+			return nil, fmt.Errorf("cannot create table %s: %w", tableName, err)
+		}
+	}
+
+	// A partitioned table keeps its rows in its partitions, tables of
+	// their own (see partition.go)
+	var pt *partitionedTable
+	if stmt.Partition != nil && stmt.Using == "columnar" {
+		return nil, fmt.Errorf("cannot create table %s: a columnar table cannot be partitioned", tableName)
+	}
+	if stmt.Partition != nil {
+		var err error
+		if pt, err = e.compilePartitions(stmt, schema); err == nil {
+			err = e.checkPartitionNames(pt)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Create table
-	tbl, err := table.NewTable(tableName, schema, e.pager)
+	newTable := table.NewTable
+	if stmt.Using == "columnar" {
+		newTable = table.NewColumnarTable
+	}
+	tbl, err := newTable(name, schema, pager)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
-	e.tables[tableName] = tbl
+	if stmt.Temporary {
+		if e.tempTables == nil {
+			e.tempTables = make(map[string]*table.Table)
+		}
+		e.tempTables[tableName] = tbl
+	} else {
+		e.tables[tableName] = tbl
+	}
 
 	// Persist to catalog if available
-	if e.catalog != nil {
-		if err := e.catalog.AddTable(tableName, tbl); err != nil {
+	if cat != nil {
+		sql := (&parser.CreateTableStatement{Table: name, Columns: stmt.Columns, PrimaryKeyColumns: stmt.PrimaryKeyColumns,
+			Partition: stmt.Partition, Using: stmt.Using}).String()
+		if err := cat.AddTable(name, tbl, sql); err != nil {
 			return nil, fmt.Errorf("failed to save table metadata: %w", err)
 		}
 	}
+	if pt != nil {
+		if err := e.createPartitions(pt, stmt); err != nil {
+			return nil, err
+		}
+	}
 
 	return &Result{
 		Message: fmt.Sprintf("Table '%s' created", tableName),
@@ -292,16 +856,41 @@ func (e *Executor) executeCreateTable(stmt *parser.CreateTableStatement) (*Resul
 // executeDropTable handles DROP TABLE statements.
 func (e *Executor) executeDropTable(stmt *parser.DropTableStatement) (*Result, error) {
 	tableName := strings.ToLower(stmt.Table)
+	if e.isVirtualTable(tableName) {
+		return e.dropVirtualTable(tableName)
+	}
 
-	if _, exists := e.tables[tableName]; !exists {
+	tbl, exists := e.lookupTable(tableName)
+	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
+	if _, ok := e.views[tableName]; ok {
+		return nil, fmt.Errorf("%s is a materialized view: use DROP MATERIALIZED VIEW", tableName)
+	}
+	if parent, ok := e.partitionParent(tableName); ok {
+		return nil, fmt.Errorf("%s is a partition of %s: drop the partitioned table instead", tableName, parent)
+	}
+	if pt, ok := e.partitions[tableName]; ok {
+		if err := e.dropPartitions(pt); err != nil {
+			return nil, err
+		}
+	}
 
-	delete(e.tables, tableName)
+	// Give the table's pages back, for new pages to reuse
+	if err := tbl.Drop(); err != nil {
+		return nil, fmt.Errorf("failed to free table storage: %w", err)
+	}
+	cat, name := e.catalogOf(tableName)
+	e.dropTableTriggers(tableName)
+	if e.isTemp(tableName) {
+		delete(e.tempTables, tableName)
+	} else {
+		delete(e.tables, tableName)
+	}
 
 	// Remove from catalog if available
-	if e.catalog != nil {
-		if err := e.catalog.RemoveTable(tableName); err != nil {
+	if cat != nil {
+		if err := cat.RemoveTable(name); err != nil {
 			return nil, fmt.Errorf("failed to remove table metadata: %w", err)
 		}
 	}
@@ -311,6 +900,62 @@ func (e *Executor) executeDropTable(stmt *parser.DropTableStatement) (*Result, e
 	}, nil
 }
 
+// executeAlterTable handles ALTER TABLE ... ADD/DROP COLUMN statements.
+// Only the schema changes: the rows keep the version they were written
+// with, and are read through it (see table/rowversion.go).
+func (e *Executor) executeAlterTable(stmt *parser.AlterTableStatement) (*Result, error) {
+	tableName := strings.ToLower(stmt.Table)
+	if err := e.checkNotVirtual(tableName, "alter"); err != nil {
+		return nil, err
+	}
+
+	tbl, exists := e.lookupTable(tableName)
+	if !exists {
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	if err := e.checkNotView(tableName, "alter"); err != nil {
+		return nil, err
+	}
+	if err := e.checkNotPartition(tableName, "alter"); err != nil {
+		return nil, err
+	}
+	if _, ok := e.partitions[tableName]; ok {
+		return nil, fmt.Errorf("cannot alter partitioned table %s", tableName)
+	}
+	if err := checkNotColumnar(tbl, tableName, "alter"); err != nil {
+		return nil, err
+	}
+
+	var schema *table.Schema
+	var err error
+	if stmt.AddColumn != nil {
+		schema, err = tbl.Schema.AddColumn(*stmt.AddColumn)
+	} else {
+		schema, err = tbl.Schema.DropColumn(stmt.DropColumn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	altered, err := tbl.Altered(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	// The table is replaced rather than changed, so a transaction's
+	// savepoint still holds the old one to roll back to
+	e.setTable(tableName, altered)
+
+	if cat, name := e.catalogOf(tableName); cat != nil {
+		if err := cat.AddTable(name, altered, catalog.TableSQL(name, altered.Schema)); err != nil {
+			return nil, fmt.Errorf("failed to save table metadata: %w", err)
+		}
+	}
+
+	return &Result{
+		Message: fmt.Sprintf("Table '%s' altered", tableName),
+	}, nil
+}
+
 // executeCreateIndex handles CREATE INDEX statements.
 //
 // EDUCATIONAL NOTE:
@@ -323,12 +968,19 @@ func (e *Executor) executeDropTable(stmt *parser.DropTableStatement) (*Result, e
 // 4. Register the index so future INSERTs/UPDATEs maintain it
 func (e *Executor) executeCreateIndex(stmt *parser.CreateIndexStatement) (*Result, error) {
 	tableName := strings.ToLower(stmt.Table)
+	if err := e.checkNotVirtual(tableName, "index"); err != nil {
+		return nil, err
+	}
 
-	tbl, exists := e.tables[tableName]
+	tbl, exists := e.lookupTable(tableName)
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
 
+	if _, ok := e.partitions[tableName]; ok {
+		return nil, fmt.Errorf("cannot index partitioned table %s: create the index on each of its partitions", tableName)
+	}
+
 	// Convert column names to lowercase for consistency
 	columns := make([]string, len(stmt.Columns))
 	for i, col := range stmt.Columns {
@@ -355,7 +1007,7 @@ func (e *Executor) executeCreateIndex(stmt *parser.CreateIndexStatement) (*Resul
 func (e *Executor) executeDropIndex(stmt *parser.DropIndexStatement) (*Result, error) {
 	// Find which table has this index
 	var foundTable *table.Table
-	for _, tbl := range e.tables {
+	for _, tbl := range e.visibleTables() {
 		if _, exists := tbl.GetIndex(stmt.IndexName); exists {
 			foundTable = tbl
 			break
@@ -378,29 +1030,25 @@ func (e *Executor) executeDropIndex(stmt *parser.DropIndexStatement) (*Result, e
 // executeInsert handles INSERT statements.
 func (e *Executor) executeInsert(stmt *parser.InsertStatement) (*Result, error) {
 	tableName := strings.ToLower(stmt.Table)
+	if err := e.checkNotVirtual(tableName, "insert into"); err != nil {
+		return nil, err
+	}
 
-	tbl, exists := e.tables[tableName]
+	tbl, exists := e.lookupTable(tableName)
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
+	if err := e.checkNotView(tableName, "insert into"); err != nil {
+		return nil, err
+	}
+	if err := e.checkNotPartition(tableName, "insert into"); err != nil {
+		return nil, err
+	}
 
 	// Determine column order
-	var columnOrder []int
-	if len(stmt.Columns) > 0 {
-		columnOrder = make([]int, len(stmt.Columns))
-		for i, colName := range stmt.Columns {
-			idx, ok := tbl.Schema.GetColumnIndex(colName)
-			if !ok {
-				return nil, fmt.Errorf("unknown column: %s", colName)
-			}
-			columnOrder[i] = idx
-		}
-	} else {
-		// Use schema order
-		columnOrder = make([]int, len(tbl.Schema.Columns))
-		for i := range columnOrder {
-			columnOrder[i] = i
-		}
+	columnOrder, err := insertColumns(tbl, stmt.Columns)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check value count
@@ -410,9 +1058,9 @@ func (e *Executor) executeInsert(stmt *parser.InsertStatement) (*Result, error)
 	}
 
 	// Convert expressions to values
-	values := make([]table.Value, len(tbl.Schema.Columns))
-	for i := range values {
-		values[i] = table.Value{IsNull: true}
+	values, err := e.defaultValues(tbl, columnOrder)
+	if err != nil {
+		return nil, err
 	}
 
 	for i, expr := range stmt.Values {
@@ -422,22 +1070,101 @@ func (e *Executor) executeInsert(stmt *parser.InsertStatement) (*Result, error)
 			return nil, fmt.Errorf("error evaluating value: %w", err)
 		}
 		// Coerce type if needed
-		val.Type = tbl.Schema.Columns[colIdx].Type
+		val, err = e.coerceToColumn(val, tbl.Schema.Columns[colIdx])
+		if err != nil {
+			return nil, err
+		}
 		values[colIdx] = val
 	}
 
-	// Insert the row
-	rowID, err := tbl.Insert(values)
+	rowID, err := e.insertRow(tableName, tbl, values)
 	if err != nil {
-		return nil, fmt.Errorf("insert failed: %w", err)
+		return nil, err
 	}
-
 	return &Result{
 		Message:  fmt.Sprintf("Inserted 1 row (id=%d)", rowID),
 		RowCount: 1,
 	}, nil
 }
 
+// insertColumns returns the index of each column an INSERT gives a value
+// for: those listed, or every column in schema order if none are.
+func insertColumns(tbl *table.Table, columns []string) ([]int, error) {
+	if len(columns) == 0 {
+		columnOrder := make([]int, len(tbl.Schema.Columns))
+		for i := range columnOrder {
+			columnOrder[i] = i
+		}
+		return columnOrder, nil
+	}
+	columnOrder := make([]int, len(columns))
+	for i, colName := range columns {
+		idx, ok := tbl.Schema.GetColumnIndex(colName)
+		if !ok {
+			return nil, fmt.Errorf("unknown column: %s", colName)
+		}
+		columnOrder[i] = idx
+	}
+	return columnOrder, nil
+}
+
+// defaultValues returns a row for tbl before an INSERT's values are put
+// in: the columns not in columnOrder take their DEFAULT, if they have
+// one, and the rest are NULL.
+func (e *Executor) defaultValues(tbl *table.Table, columnOrder []int) ([]table.Value, error) {
+	values := make([]table.Value, len(tbl.Schema.Columns))
+	for i := range values {
+		values[i] = table.Value{IsNull: true}
+	}
+
+	given := make([]bool, len(values))
+	for _, colIdx := range columnOrder {
+		given[colIdx] = true
+	}
+	for i, col := range tbl.Schema.Columns {
+		if given[i] || col.Default == nil {
+			continue
+		}
+		val, err := e.evaluateExpression(col.Default, table.Row{}, tbl.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating default of %s: %w", col.Name, err)
+		}
+		if values[i], err = e.coerceToColumn(val, col); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// insertRow inserts a row into a table, firing its triggers, and returns
+// the row's ID.
+func (e *Executor) insertRow(tableName string, tbl *table.Table, values []table.Value) (uint64, error) {
+	if err := e.fireTriggers(tableName, "BEFORE", "INSERT", tbl.Schema, nil, values); err != nil {
+		return 0, err
+	}
+
+	// Insert the row, into the partition its key picks if the table is
+	// partitioned
+	target, targetName, err := e.partitionFor(tableName, tbl, values)
+	if err != nil {
+		return 0, err
+	}
+	step := e.startStep()
+	rowID, err := target.Insert(values)
+	if err != nil {
+		return 0, fmt.Errorf("insert failed: %w", err)
+	}
+	if err := e.lockRows(targetName, []table.Row{{ID: rowID}}); err != nil {
+		return 0, err
+	}
+	e.endStep(step, "Insert", tableName, 1)
+	if err := e.fireTriggers(tableName, "AFTER", "INSERT", tbl.Schema, nil, values); err != nil {
+		return 0, err
+	}
+	e.autoAnalyze(target)
+	return rowID, nil
+}
+
 // executeSelect handles SELECT statements.
 //
 // EDUCATIONAL NOTE:
@@ -446,231 +1173,474 @@ func (e *Executor) executeInsert(stmt *parser.InsertStatement) (*Result, error)
 // 1. Plan the query (decide whether to use index or table scan)
 // 2. Fetch rows using the chosen access method
 // 3. Filter rows based on WHERE clause (if not already filtered by index)
-// 4. Select requested columns (projection)
-// 5. Sort results if ORDER BY specified
-// 6. Apply LIMIT and OFFSET
+// 4. Sort results if ORDER BY specified
+// 5. Apply LIMIT and OFFSET
+// 6. Select requested columns (projection)
+//
+// Each step is an operator pulling rows from the one before it, so rows
+// pass through one at a time (see operators.go).
 func (e *Executor) executeSelect(stmt *parser.SelectStatement) (*Result, error) {
+	if err := e.checkHints(stmt); err != nil {
+		return nil, err
+	}
+	if stmt.From == "" {
+		return e.executeSelectWithoutFrom(stmt)
+	}
+	if stmt.FromFunc != nil {
+		return e.selectTableFunction(stmt)
+	}
+	if len(stmt.Joins) > 0 {
+		return e.executeJoin(stmt)
+	}
+	if isSystemView(stmt.From) {
+		return e.selectSystemView(stmt)
+	}
+	if e.isVirtualTable(stmt.From) {
+		return e.selectVirtualTable(stmt)
+	}
+
 	tableName := strings.ToLower(stmt.From)
 
-	tbl, exists := e.tables[tableName]
+	tbl, exists := e.lookupTable(tableName)
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
 
+	projection, agg, err := selectProjection(stmt, tbl.Schema)
+	if err != nil {
+		return nil, err
+	}
+	it, err := e.selectIterator(stmt, tbl, projection, agg)
+	if err != nil {
+		return nil, err
+	}
+	return e.collectResult(it, projection)
+}
+
+// selectIterator builds the pipeline of operators running a SELECT on one
+// table: the access method the planner chose, filtered by the WHERE
+// clause, under the operators of selectPipeline. agg is the query's
+// aggregation, if it has one.
+func (e *Executor) selectIterator(stmt *parser.SelectStatement, tbl *table.Table, projection []projectedColumn,
+	agg *aggregatePlan) (rowIterator, error) {
+	tableName := strings.ToLower(stmt.From)
+	if pt, ok := e.partitions[tableName]; ok {
+		return e.partitionIterator(stmt, pt, tbl.Schema, projection, agg)
+	}
+
+	access, operator, detail, ordered, err := e.accessIterator(stmt, tbl, tableName, agg)
+	if err != nil {
+		return nil, err
+	}
+	access = e.traced(access, operator, detail)
+	return e.selectPipeline(stmt, access, tbl.Schema, projection, agg, ordered), nil
+}
+
+// accessIterator reads the rows of tbl that a SELECT needs, by the access
+// method the planner chose, filtered by the WHERE clause. It returns the
+// step's operator and detail too, to trace it by, and whether the rows
+// come in ORDER BY order (see order.go).
+func (e *Executor) accessIterator(stmt *parser.SelectStatement, tbl *table.Table, tableName string,
+	agg *aggregatePlan) (access rowIterator, operator, detail string, ordered bool, err error) {
 	// Plan the query
-	planner := NewPlanner()
-	plan := planner.Plan(stmt, tbl.Schema)
+	plan := e.planSelect(stmt, tbl)
+	ordered, orderScan, orderIndex := indexOrder(stmt, tbl, plan, agg)
+
+	// Calculate effective limit for early exit (only when no sort)
+	// When the rows need sorting, we need all matching rows before
+	// sorting, and with aggregation LIMIT counts groups, not rows
+	scanLimit := 0
+	if (len(stmt.OrderBy) == 0 || ordered) && stmt.Limit != nil && agg == nil {
+		scanLimit = *stmt.Limit
+		if stmt.Offset != nil {
+			scanLimit += *stmt.Offset
+		}
+	}
 
-	var rows []table.Row
-	var err error
+	// lookup runs an index lookup when the pipeline first asks for a row,
+	// checking the WHERE clause on the rows it finds; the lookup finds
+	// every row it returns at once, so it stops at scanLimit itself
+	filter, filterErr := e.whereFilter(stmt.Where, tbl.Schema)
+	where := filter
+	filter = func(row table.Row) bool {
+		e.scanned(1)
+		return where(row)
+	}
+	lookup := func(find func() ([]table.Row, error)) rowIterator {
+		return &loadIterator{load: func() ([]table.Row, error) {
+			rows, err := find()
+			if err := filterErr(); err != nil {
+				return nil, err
+			}
+			if err == nil {
+				err = e.reserveRows(rows, "index lookup")
+			}
+			return rows, err
+		}}
+	}
+
+	// Streaming the groups may call for reading the table in primary key
+	// order rather than scanning it
+	pkOrder, parallel := false, false
+	if agg != nil {
+		pkOrder = agg.chooseAggregate(tbl, plan)
+	}
+
+	// The WHERE clause is checked on every row read, streaming through
+	// the rows of a scan; see filterIterator
+	switch {
+	case plan.Type == PlanIndexScan && plan.Index != nil:
+		// Follow the secondary index's entries for the key to their rows
+		access = lookup(func() ([]table.Row, error) {
+			return tbl.LookupIndex(plan.Index.Name, *plan.IndexKey, filter, scanLimit)
+		})
+
+	case plan.Type == PlanIndexScan:
+		// Use B-tree index for primary key lookup. The row still needs to
+		// match all WHERE conditions (there might be additional conditions
+		// beyond the PK equality)
+		keyValues := plan.IndexKeys
+		if keyValues == nil {
+			keyValues = []table.Value{*plan.IndexKey}
+		}
+		access = lookup(func() ([]table.Row, error) {
+			row, found, err := tbl.GetRowByPrimaryKey(keyValues...)
+			if err != nil {
+				return nil, fmt.Errorf("index lookup failed: %w", err)
+			}
+			if !found || !filter(row) {
+				return nil, nil
+			}
+			return []table.Row{row}, nil
+		})
+
+	case plan.Type == PlanIndexRangeScan && plan.Index != nil:
+		access = lookup(func() ([]table.Row, error) {
+			return tbl.ScanIndexRange(plan.Index.Name, plan.RangeLower, plan.RangeUpper,
+				plan.LowerInclusive, plan.UpperInclusive, filter, scanLimit)
+		})
 
-	switch plan.Type {
-	case PlanIndexScan:
-		// Use B-tree index for primary key lookup
-		row, found, lookupErr := tbl.GetRowByPrimaryKey(*plan.IndexKey)
-		if lookupErr != nil {
-			return nil, fmt.Errorf("index lookup failed: %w", lookupErr)
-		}
-		if found {
-			// Still need to verify the row matches all WHERE conditions
-			// (there might be additional conditions beyond the PK equality)
-			match := true
-			if stmt.Where != nil {
-				match, err = e.evaluateCondition(stmt.Where, row, tbl.Schema)
-				if err != nil {
-					return nil, err
-				}
-			}
-			if match {
-				rows = []table.Row{row}
-			}
+	case plan.Type == PlanIndexRangeScan:
+		// Use the B-tree for the range of the primary key; the rest of
+		// the WHERE clause is checked on each row in it
+		rows, err := tbl.NewPrimaryKeyRangeIterator(plan.RangeLower, plan.RangeUpper,
+			plan.LowerInclusive, plan.UpperInclusive)
+		if err != nil {
+			return nil, "", "", false, fmt.Errorf("index range scan failed: %w", err)
 		}
+		rows.DecodeColumns(usedColumns(stmt, tbl.Schema))
+		access = e.filtered(e.interruptible(rows), stmt.Where, tbl.Schema)
 
-	case PlanTableScan:
-		// Calculate effective limit for early exit (only when no ORDER BY)
-		// When ORDER BY is present, we need all matching rows before sorting
-		scanLimit := 0
-		if len(stmt.OrderBy) == 0 && stmt.Limit != nil {
-			scanLimit = *stmt.Limit
-			if stmt.Offset != nil {
-				scanLimit += *stmt.Offset
-			}
+	case orderScan && orderIndex != nil:
+		// Follow every entry of the secondary index to its row
+		rows, err := tbl.IndexIterator(orderIndex.Name)
+		if err != nil {
+			return nil, "", "", false, fmt.Errorf("ordered index scan failed: %w", err)
 		}
+		rows.DecodeColumns(usedColumns(stmt, tbl.Schema))
+		access = e.filtered(e.interruptible(rows), stmt.Where, tbl.Schema)
 
-		if stmt.Where != nil {
-			// Use ScanWithFilter for push-down filtering
-			// This reduces memory by filtering during iteration, not after
-			var filterErr error
-			filter := func(row table.Row) bool {
-				if filterErr != nil {
-					return false // Stop on first error
-				}
-				match, evalErr := e.evaluateCondition(stmt.Where, row, tbl.Schema)
-				if evalErr != nil {
-					filterErr = evalErr
-					return false
-				}
-				return match
-			}
+	case pkOrder || orderScan:
+		rows := tbl.Iterator()
+		rows.DecodeColumns(usedColumns(stmt, tbl.Schema))
+		access = e.filtered(e.interruptible(rows), stmt.Where, tbl.Schema)
 
-			rows, err = tbl.ScanWithFilter(filter, scanLimit)
-			if filterErr != nil {
-				return nil, filterErr
-			}
-		} else if scanLimit > 0 {
-			// No filter but can still use early exit with limit
-			rows, err = tbl.ScanWithFilter(func(row table.Row) bool { return true }, scanLimit)
+	default:
+		// A large table's pages are decoded by a pool of workers, ahead
+		// of the rows being read (see table/parallel.go)
+		rows := tbl.NewScanIterator()
+		rows.DecodeColumns(usedColumns(stmt, tbl.Schema))
+		rows.SkipPages(scanRanges(stmt.Where, tbl.Schema, e.params))
+		parallel = rows.Parallel(0)
+		access = e.filtered(e.interruptible(rows), stmt.Where, tbl.Schema)
+	}
+
+	index := plan.Index
+	if orderScan {
+		index = orderIndex
+	}
+	using := tableName + " using primary key"
+	if index != nil {
+		using = tableName + " using index " + index.Name
+	}
+	if plan.Type == PlanIndexScan || plan.Type == PlanIndexRangeScan || pkOrder || orderScan {
+		if index != nil {
+			e.usedIndex(index.Name)
 		} else {
-			// No filter, no early limit - use regular scan
-			rows, err = tbl.Scan()
-		}
-		if err != nil {
-			return nil, fmt.Errorf("scan failed: %w", err)
+			e.usedIndex("primary key")
 		}
 	}
+	switch {
+	case plan.Type == PlanIndexScan:
+		return access, "Index Lookup", using, ordered, nil
+	case plan.Type == PlanIndexRangeScan:
+		return access, "Index Range Scan", using, ordered, nil
+	case pkOrder:
+		return access, "Index Scan", using, ordered, nil
+	case orderScan:
+		return access, "Ordered Index Scan", using, ordered, nil
+	case tbl.IsColumnar():
+		return access, "Columnar Scan", columnarScanDetail(stmt, tbl, tableName), ordered, nil
+	case parallel:
+		return access, "Parallel Table Scan", whereDetail(tableName, stmt.Where), ordered, nil
+	default:
+		return access, "Table Scan", whereDetail(tableName, stmt.Where), ordered, nil
+	}
+}
 
-	// Determine columns to return
-	var columnNames []string
-	var columnIndices []int
-
-	if len(stmt.Columns) == 1 {
-		if _, ok := stmt.Columns[0].(*parser.StarExpression); ok {
-			// SELECT * - return all columns
-			for i, col := range tbl.Schema.Columns {
-				columnNames = append(columnNames, col.Name)
-				columnIndices = append(columnIndices, i)
-			}
+// whereFilter compiles a WHERE clause into a filter for the table's scans
+// and lookups, which check it on each row as they read it. A scan can't
+// return an error from the filter, so the filter rejects every row after
+// the first error, and err returns it. A nil where matches every row.
+func (e *Executor) whereFilter(where parser.Expression, schema *table.Schema) (filter func(table.Row) bool, err func() error) {
+	var filterErr error
+	filter = func(row table.Row) bool {
+		if filterErr != nil {
+			return false // Stop on first error
+		}
+		if where == nil {
+			return true
 		}
+		match, evalErr := e.evaluateCondition(where, row, schema)
+		if evalErr != nil {
+			filterErr = evalErr
+			return false
+		}
+		return match
 	}
+	return filter, func() error { return filterErr }
+}
 
-	if len(columnIndices) == 0 {
-		// Specific columns requested
-		for _, expr := range stmt.Columns {
-			if ident, ok := expr.(*parser.Identifier); ok {
-				idx, found := tbl.Schema.GetColumnIndex(ident.Name)
-				if !found {
-					return nil, fmt.Errorf("unknown column: %s", ident.Name)
-				}
-				columnNames = append(columnNames, ident.Name)
-				columnIndices = append(columnIndices, idx)
-			} else if _, ok := expr.(*parser.StarExpression); ok {
-				for i, col := range tbl.Schema.Columns {
-					columnNames = append(columnNames, col.Name)
-					columnIndices = append(columnIndices, i)
-				}
-			} else {
-				// Expression - for now, just show as "expr"
-				columnNames = append(columnNames, "expr")
-				columnIndices = append(columnIndices, -1)
-			}
+// usedColumns returns which columns of schema a SELECT reads, so a scan
+// can skip decoding the others, or nil if it reads them all.
+func usedColumns(stmt *parser.SelectStatement, schema *table.Schema) []bool {
+	used := make([]bool, len(schema.Columns))
+	use := func(name string) {
+		if idx, found := schema.GetColumnIndex(name); found {
+			used[idx] = true
 		}
 	}
-
-	// Apply ORDER BY with LIMIT optimization
-	// When LIMIT is present, use heap-based top-K selection for O(N log K) instead of O(N log N)
-	if len(stmt.OrderBy) > 0 {
-		// Calculate effective limit (including offset)
-		effectiveLimit := 0
-		if stmt.Limit != nil {
-			effectiveLimit = *stmt.Limit
-			if stmt.Offset != nil {
-				effectiveLimit += *stmt.Offset
-			}
+	visit := func(ex parser.Expression) bool {
+		if ident, ok := ex.(*parser.Identifier); ok {
+			use(ident.Name)
 		}
+		return true
+	}
 
-		// Try heap-based top-K if limit is set and reasonable
-		if effectiveLimit > 0 && effectiveLimit < len(rows) {
-			topK := selectTopK(rows, effectiveLimit, stmt.OrderBy, tbl.Schema)
-			if topK != nil {
-				rows = topK
-			}
+	for _, expr := range stmt.Columns {
+		if _, ok := expr.(*parser.StarExpression); ok {
+			return nil
 		}
+		parser.WalkExpression(expr, visit) // COUNT(*) reads no column
+	}
+	if stmt.Where != nil {
+		parser.WalkExpression(stmt.Where, visit)
+	}
+	for _, clause := range stmt.OrderBy {
+		use(clause.Column) // an alias of the select list names no column
+	}
+	for _, name := range stmt.GroupBy {
+		use(name)
+	}
+	return used
+}
 
-		// If top-K wasn't used (returned nil) or no limit, fall back to full sort
-		if effectiveLimit == 0 || effectiveLimit >= len(rows) {
-			sort.Slice(rows, func(i, j int) bool {
-				for _, clause := range stmt.OrderBy {
-					colIdx, found := tbl.Schema.GetColumnIndex(clause.Column)
-					if !found {
-						continue
-					}
-					cmp := rows[i].Values[colIdx].Compare(rows[j].Values[colIdx])
-					if cmp != 0 {
-						if clause.Descending {
-							return cmp > 0
-						}
-						return cmp < 0
-					}
-				}
-				return false
-			})
-		}
+// filtered returns the rows of input for which where holds, or all of
+// them if where is nil.
+func (e *Executor) filtered(input rowIterator, where parser.Expression, schema *table.Schema) rowIterator {
+	if where == nil {
+		return input
 	}
+	return &filterIterator{e: e, input: input, cond: where, schema: schema}
+}
 
-	// Apply OFFSET
-	if stmt.Offset != nil {
-		if *stmt.Offset >= len(rows) {
-			rows = nil
-		} else {
-			rows = rows[*stmt.Offset:]
+// executeSelectWithoutFrom handles SELECT with no FROM clause, such as
+// SELECT 1 + 1 or SELECT NOW().
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// With no table there is exactly one (empty) input row, so the select list
+// is evaluated once. WHERE can still filter that row away, and LIMIT/OFFSET
+// still apply. Column references and * have nothing to refer to and fail.
+func (e *Executor) executeSelectWithoutFrom(stmt *parser.SelectStatement) (*Result, error) {
+	schema := table.NewSchema(nil)
+	row := table.Row{}
+
+	for _, expr := range stmt.Columns {
+		if _, ok := expr.(*parser.StarExpression); ok {
+			return nil, fmt.Errorf("SELECT * requires a FROM clause")
 		}
 	}
+	projection, err := buildProjection(stmt.Columns, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Columns: make([]string, len(projection))}
+	for i, col := range projection {
+		result.Columns[i] = col.name
+	}
 
-	// Apply LIMIT
-	if stmt.Limit != nil {
-		if *stmt.Limit < len(rows) {
-			rows = rows[:*stmt.Limit]
+	if stmt.Where != nil {
+		match, err := e.evaluateCondition(stmt.Where, row, schema)
+		if err != nil || !match {
+			return result, err
 		}
 	}
+	if (stmt.Offset != nil && *stmt.Offset > 0) || (stmt.Limit != nil && *stmt.Limit == 0) {
+		return result, nil
+	}
+
+	step := e.startStep()
+	values, err := e.projectRow(projection, row, schema)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = [][]table.Value{values}
+	result.RowCount = 1
+	e.endStep(step, "Result", strings.Join(result.Columns, ", "), 1)
+	return result, nil
+}
 
-	// Build result
-	result := &Result{
-		Columns:  columnNames,
-		RowCount: len(rows),
+// projectedColumn is one output column of a SELECT.
+// Plain column references copy the stored value (index >= 0); anything
+// else is an expression evaluated against each row.
+type projectedColumn struct {
+	name  string
+	index int
+	expr  parser.Expression
+}
+
+// selectProjection resolves the SELECT list of a query reading rows of
+// schema. With GROUP BY or aggregates, it is resolved against the rows of
+// the aggregation, which is returned too.
+func selectProjection(stmt *parser.SelectStatement, schema *table.Schema) ([]projectedColumn, *aggregatePlan, error) {
+	if !needsAggregate(stmt) {
+		projection, err := buildProjection(stmt.Columns, schema)
+		return projection, nil, err
+	}
+	agg, err := planAggregate(stmt, schema)
+	if err != nil {
+		return nil, nil, err
 	}
+	projection, err := buildProjection(stmt.Columns, agg.schema)
+	return projection, agg, err
+}
+
+// buildProjection resolves the SELECT list against a schema, expanding *
+// and naming each output column.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Output names follow SQLite: a column keeps its name, an aliased item
+// uses the alias, and any other expression is named by its own text, so
+// SELECT price * 2 FROM t has a column called "price * 2".
+func buildProjection(columns []parser.Expression, schema *table.Schema) ([]projectedColumn, error) {
+	var projection []projectedColumn
+
+	for _, expr := range columns {
+		name := ""
+		if alias, ok := expr.(*parser.AliasExpression); ok {
+			name, expr = alias.Alias, alias.Expr
+		}
 
-	for _, row := range rows {
-		resultRow := make([]table.Value, len(columnIndices))
-		for i, colIdx := range columnIndices {
-			if colIdx >= 0 && colIdx < len(row.Values) {
-				resultRow[i] = row.Values[colIdx]
+		switch ex := expr.(type) {
+		case *parser.StarExpression:
+			for i, col := range schema.Columns {
+				projection = append(projection, projectedColumn{name: col.Name, index: i})
+			}
+			continue
+		case *parser.Identifier:
+			idx, found := schema.GetColumnIndex(ex.Name)
+			if !found {
+				return nil, fmt.Errorf("unknown column: %s", ex.Name)
+			}
+			if name == "" {
+				// A qualified column keeps just its name, users.id -> id
+				name = ex.Name[strings.LastIndex(ex.Name, ".")+1:]
 			}
+			projection = append(projection, projectedColumn{name: name, index: idx})
+			continue
+		}
+
+		if name == "" {
+			name = expressionName(expr)
 		}
-		result.Rows = append(result.Rows, resultRow)
+		projection = append(projection, projectedColumn{name: name, index: -1, expr: expr})
 	}
 
-	return result, nil
+	return projection, nil
+}
+
+// expressionName derives an output column name from an expression's text.
+func expressionName(expr parser.Expression) string {
+	name := expr.String()
+	if _, ok := expr.(*parser.BinaryExpression); ok {
+		// Binary expressions print fully parenthesized: (a + b)
+		name = strings.TrimSuffix(strings.TrimPrefix(name, "("), ")")
+	}
+	return name
+}
+
+// projectRow computes the output values for one row.
+func (e *Executor) projectRow(projection []projectedColumn, row table.Row, schema *table.Schema) ([]table.Value, error) {
+	values := make([]table.Value, len(projection))
+	for i, col := range projection {
+		if col.index >= 0 {
+			if col.index < len(row.Values) {
+				values[i] = row.Values[col.index]
+			}
+			continue
+		}
+		val, err := e.evaluateExpression(col.expr, row, schema)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+	return values, nil
 }
 
 // executeUpdate handles UPDATE statements.
 func (e *Executor) executeUpdate(stmt *parser.UpdateStatement) (*Result, error) {
 	tableName := strings.ToLower(stmt.Table)
+	if err := e.checkNotVirtual(tableName, "update"); err != nil {
+		return nil, err
+	}
 
-	tbl, exists := e.tables[tableName]
+	tbl, exists := e.lookupTable(tableName)
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
+	if err := e.checkNotView(tableName, "update"); err != nil {
+		return nil, err
+	}
+	if err := e.checkNotPartition(tableName, "update"); err != nil {
+		return nil, err
+	}
+	if err := checkNotColumnar(tbl, tableName, "update"); err != nil {
+		return nil, err
+	}
 
-	// Get all rows
-	rows, err := tbl.Scan()
+	// Find rows to update, and the partition each is in if the table is
+	// partitioned
+	step := e.startStep()
+	rows, sources, err := e.scanSources(tableName, tbl, stmt.Where)
 	if err != nil {
 		return nil, err
 	}
+	e.endStep(step, "Table Scan", whereDetail(tableName, stmt.Where), len(rows))
 
-	// Find rows to update
+	step = e.startStep()
 	updateCount := 0
 	for i := range rows {
-		if stmt.Where != nil {
-			match, err := e.evaluateCondition(stmt.Where, rows[i], tbl.Schema)
-			if err != nil {
-				return nil, err
-			}
-			if !match {
-				continue
-			}
+		if err := e.lockRows(sources[i].name, rows[i:i+1]); err != nil {
+			return nil, err
 		}
 
-		// Apply updates
+		// Apply updates. Every assignment sees the row as it was before
+		// the UPDATE, so SET a = b, b = a swaps the two columns.
+		values := append([]table.Value(nil), rows[i].Values...)
 		for _, assignment := range stmt.Assignments {
 			colIdx, found := tbl.Schema.GetColumnIndex(assignment.Column)
 			if !found {
@@ -681,11 +1651,25 @@ func (e *Executor) executeUpdate(stmt *parser.UpdateStatement) (*Result, error)
 			if err != nil {
 				return nil, err
 			}
-			val.Type = tbl.Schema.Columns[colIdx].Type
-			rows[i].Values[colIdx] = val
+			val, err = e.coerceToColumn(val, tbl.Schema.Columns[colIdx])
+			if err != nil {
+				return nil, err
+			}
+			values[colIdx] = val
+		}
+		if err := e.fireTriggers(tableName, "BEFORE", "UPDATE", tbl.Schema, rows[i].Values, values); err != nil {
+			return nil, err
+		}
+		if err := e.updateRow(tableName, tbl, sources[i], rows[i], values); err != nil {
+			return nil, fmt.Errorf("update failed: %w", err)
+		}
+		if err := e.fireTriggers(tableName, "AFTER", "UPDATE", tbl.Schema, rows[i].Values, values); err != nil {
+			return nil, err
 		}
 		updateCount++
 	}
+	e.endStep(step, "Update", whereDetail(tableName, stmt.Where), updateCount)
+	e.autoAnalyzeSources(tableName, tbl)
 
 	return &Result{
 		Message:  fmt.Sprintf("Updated %d rows", updateCount),
@@ -693,35 +1677,90 @@ func (e *Executor) executeUpdate(stmt *parser.UpdateStatement) (*Result, error)
 	}, nil
 }
 
+// scanWhere returns the rows of tbl matching where, checking it in the
+// scan so only those rows are held. The scan stops if the statement is
+// canceled.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// UPDATE and DELETE find every row they change before changing any. An
+// UPDATE writing rows while still scanning could meet a row it already
+// moved and update it twice (the "Halloween problem", found in 1976 by an
+// UPDATE giving everyone a raise until nobody qualified).
+func (e *Executor) scanWhere(tbl *table.Table, where parser.Expression) ([]table.Row, error) {
+	filter, filterErr := e.whereFilter(where, tbl.Schema)
+	scan := tbl.NewScanIterator()
+	scan.SkipPages(scanRanges(where, tbl.Schema, e.params))
+	it := e.interruptible(scan)
+	defer it.Close()
+
+	var rows []table.Row
+	for it.Next() {
+		if filter(it.Row()) {
+			if err := e.reserve(rowMemory(it.Row()), "rows to change"); err != nil {
+				return nil, err
+			}
+			rows = append(rows, it.Row())
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if err := filterErr(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
 // executeDelete handles DELETE statements.
 func (e *Executor) executeDelete(stmt *parser.DeleteStatement) (*Result, error) {
 	tableName := strings.ToLower(stmt.Table)
+	if err := e.checkNotVirtual(tableName, "delete from"); err != nil {
+		return nil, err
+	}
 
-	tbl, exists := e.tables[tableName]
+	tbl, exists := e.lookupTable(tableName)
 	if !exists {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
 	}
+	if err := e.checkNotView(tableName, "delete from"); err != nil {
+		return nil, err
+	}
+	if err := e.checkNotPartition(tableName, "delete from"); err != nil {
+		return nil, err
+	}
+	if err := checkNotColumnar(tbl, tableName, "delete from"); err != nil {
+		return nil, err
+	}
 
-	// Get all rows
-	rows, err := tbl.Scan()
+	// Find rows to delete, and the partition each is in if the table is
+	// partitioned
+	step := e.startStep()
+	rows, sources, err := e.scanSources(tableName, tbl, stmt.Where)
 	if err != nil {
 		return nil, err
 	}
+	e.endStep(step, "Table Scan", whereDetail(tableName, stmt.Where), len(rows))
 
-	// Find rows to delete (just count for now)
+	step = e.startStep()
 	deleteCount := 0
-	for _, row := range rows {
-		if stmt.Where != nil {
-			match, err := e.evaluateCondition(stmt.Where, row, tbl.Schema)
-			if err != nil {
-				return nil, err
-			}
-			if !match {
-				continue
-			}
+	for i, row := range rows {
+		if err := e.lockRows(sources[i].name, []table.Row{row}); err != nil {
+			return nil, err
+		}
+		if err := e.fireTriggers(tableName, "BEFORE", "DELETE", tbl.Schema, row.Values, nil); err != nil {
+			return nil, err
+		}
+		if err := sources[i].tbl.DeleteRow(row); err != nil {
+			return nil, fmt.Errorf("delete failed: %w", err)
+		}
+		if err := e.fireTriggers(tableName, "AFTER", "DELETE", tbl.Schema, row.Values, nil); err != nil {
+			return nil, err
 		}
 		deleteCount++
 	}
+	e.endStep(step, "Delete", whereDetail(tableName, stmt.Where), deleteCount)
+	e.autoAnalyzeSources(tableName, tbl)
 
 	return &Result{
 		Message:  fmt.Sprintf("Deleted %d rows", deleteCount),
@@ -740,11 +1779,15 @@ func (e *Executor) executeAnalyze(stmt *parser.AnalyzeStatement) (*Result, error
 	if stmt.Table != "" {
 		// Analyze a specific table
 		tableName := strings.ToLower(stmt.Table)
-		tbl, exists := e.tables[tableName]
+		tbl, exists := e.lookupTable(tableName)
 		if !exists {
 			return nil, fmt.Errorf("table %s does not exist", tableName)
 		}
 
+		if pt, ok := e.partitions[tableName]; ok {
+			return e.analyzePartitions(pt)
+		}
+
 		if err := tbl.Analyze(); err != nil {
 			return nil, fmt.Errorf("failed to analyze table %s: %w", tableName, err)
 		}
@@ -758,7 +1801,7 @@ func (e *Executor) executeAnalyze(stmt *parser.AnalyzeStatement) (*Result, error
 
 	// Analyze all tables
 	var analyzed []string
-	for name, tbl := range e.tables {
+	for name, tbl := range e.visibleTables() {
 		if err := tbl.Analyze(); err != nil {
 			return nil, fmt.Errorf("failed to analyze table %s: %w", name, err)
 		}
@@ -770,13 +1813,15 @@ func (e *Executor) executeAnalyze(stmt *parser.AnalyzeStatement) (*Result, error
 	}, nil
 }
 
-// evaluateCondition evaluates a WHERE condition against a row.
+// evaluateCondition evaluates a WHERE condition against a row. A row
+// matches only if the condition is TRUE: FALSE and UNKNOWN (NULL) both
+// filter it out.
 func (e *Executor) evaluateCondition(expr parser.Expression, row table.Row, schema *table.Schema) (bool, error) {
 	val, err := e.evaluateExpression(expr, row, schema)
 	if err != nil {
 		return false, err
 	}
-	return val.Boolean, nil
+	return !val.IsNull && val.Boolean, nil
 }
 
 // evaluateExpression evaluates an expression and returns its value.
@@ -802,6 +1847,12 @@ func (e *Executor) evaluateExpression(expr parser.Expression, row table.Row, sch
 	case *parser.NullLiteral:
 		return table.Value{IsNull: true}, nil
 
+	case *parser.Placeholder:
+		if ex.Index >= len(e.params) {
+			return table.Value{}, fmt.Errorf("no value bound for parameter %d", ex.Index+1)
+		}
+		return e.params[ex.Index], nil
+
 	case *parser.Identifier:
 		if row.Values == nil {
 			// No row context, return null
@@ -825,6 +1876,9 @@ func (e *Executor) evaluateExpression(expr parser.Expression, row table.Row, sch
 		if err != nil {
 			return table.Value{}, err
 		}
+		if coll := comparisonCollation(ex, schema); coll != table.CollateBinary {
+			left, right = coll.Key(left), coll.Key(right)
+		}
 
 		return e.evaluateBinaryOp(ex.Operator, left, right)
 
@@ -836,20 +1890,71 @@ func (e *Executor) evaluateExpression(expr parser.Expression, row table.Row, sch
 
 		return e.evaluateUnaryOp(ex.Operator, operand)
 
+	case *parser.CastExpression:
+		val, err := e.evaluateExpression(ex.Expr, row, schema)
+		if err != nil {
+			return table.Value{}, err
+		}
+		if ex.Type == parser.TypeDecimal && ex.Precision > 0 {
+			return fitDecimal(val, ex.Precision, ex.Scale)
+		}
+		return table.Cast(val, ex.Type)
+
+	case *parser.FunctionCall:
+		return e.evaluateFunctionCall(ex, row, schema)
+
 	default:
 		return table.Value{}, fmt.Errorf("unsupported expression type: %T", expr)
 	}
 }
 
+// comparisonCollation returns the collation a comparison uses: that of
+// the column it compares, left side first (see table/collation.go).
+func comparisonCollation(ex *parser.BinaryExpression, schema *table.Schema) table.Collation {
+	switch ex.Operator {
+	case parser.OpEquals, parser.OpNotEquals, parser.OpLessThan,
+		parser.OpGreaterThan, parser.OpLessOrEqual, parser.OpGreaterOrEqual:
+	default:
+		return table.CollateBinary
+	}
+	if schema == nil {
+		return table.CollateBinary
+	}
+	for _, operand := range []parser.Expression{ex.Left, ex.Right} {
+		if ident, ok := operand.(*parser.Identifier); ok {
+			if idx, found := schema.GetColumnIndex(ident.Name); found && schema.Columns[idx].Collation != table.CollateBinary {
+				return schema.Columns[idx].Collation
+			}
+		}
+	}
+	return table.CollateBinary
+}
+
 // evaluateBinaryOp evaluates a binary operation.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// SQL logic has three truth values: TRUE, FALSE and UNKNOWN, which is a
+// NULL boolean. NULL stands for "some value we don't know", so whether
+// it equals 5 is unknown too, and so is NOT of that. AND and OR only
+// give UNKNOWN when the unknown side could change the answer:
+//
+//   NULL = 5            -> UNKNOWN      NOT (NULL = 5)   -> UNKNOWN
+//   UNKNOWN AND FALSE   -> FALSE        UNKNOWN OR TRUE  -> TRUE
+//   UNKNOWN AND TRUE    -> UNKNOWN      UNKNOWN OR FALSE -> UNKNOWN
+//
+// Treating NULL = 5 as FALSE would look the same in a plain WHERE, but
+// not under NOT: WHERE NOT (age = 5) would then return the rows whose
+// age is NULL. Only at the end, where WHERE, ON or HAVING decide whether
+// to keep a row (evaluateCondition), does UNKNOWN count as not matching.
 func (e *Executor) evaluateBinaryOp(op parser.BinaryOp, left, right table.Value) (table.Value, error) {
 	// Handle NULL - most operations with NULL return NULL
 	if left.IsNull || right.IsNull {
-		// Comparison with NULL returns false (SQL behavior)
+		unknown := table.Value{Type: parser.TypeBoolean, IsNull: true}
 		switch op {
 		case parser.OpEquals, parser.OpNotEquals, parser.OpLessThan,
 			parser.OpGreaterThan, parser.OpLessOrEqual, parser.OpGreaterOrEqual:
-			return table.Value{Type: parser.TypeBoolean, Boolean: false}, nil
+			return unknown, nil
 		case parser.OpAnd:
 			// NULL AND FALSE = FALSE, NULL AND TRUE = NULL
 			if !left.IsNull && !left.Boolean {
@@ -858,7 +1963,7 @@ func (e *Executor) evaluateBinaryOp(op parser.BinaryOp, left, right table.Value)
 			if !right.IsNull && !right.Boolean {
 				return table.Value{Type: parser.TypeBoolean, Boolean: false}, nil
 			}
-			return table.Value{IsNull: true}, nil
+			return unknown, nil
 		case parser.OpOr:
 			// NULL OR TRUE = TRUE, NULL OR FALSE = NULL
 			if !left.IsNull && left.Boolean {
@@ -867,12 +1972,35 @@ func (e *Executor) evaluateBinaryOp(op parser.BinaryOp, left, right table.Value)
 			if !right.IsNull && right.Boolean {
 				return table.Value{Type: parser.TypeBoolean, Boolean: true}, nil
 			}
-			return table.Value{IsNull: true}, nil
+			return unknown, nil
 		default:
 			return table.Value{IsNull: true}, nil
 		}
 	}
 
+	// Let '2024-01-01' stand in for a timestamp in comparisons
+	left, right, err := coerceTimestampOperands(left, right)
+	if err != nil {
+		return table.Value{}, err
+	}
+
+	if left.Type == parser.TypeTimestamp || right.Type == parser.TypeTimestamp {
+		if result, ok, err := evaluateTimestampArithmetic(op, left, right); ok {
+			return result, err
+		}
+	}
+
+	// Mixing DECIMAL with another number gives a DECIMAL
+	left, right, err = coerceDecimalOperands(left, right)
+	if err != nil {
+		return table.Value{}, err
+	}
+	if left.Type == parser.TypeDecimal && right.Type == parser.TypeDecimal {
+		if result, ok, err := evaluateDecimalArithmetic(op, left, right); ok {
+			return result, err
+		}
+	}
+
 	switch op {
 	case parser.OpEquals:
 		return table.Value{Type: parser.TypeBoolean, Boolean: left.Equals(right)}, nil
@@ -913,7 +2041,7 @@ func (e *Executor) evaluateBinaryOp(op parser.BinaryOp, left, right table.Value)
 			}
 			return table.Value{Type: parser.TypeReal, Real: l + r}, nil
 		}
-		if left.Type == parser.TypeText {
+		if left.Type == parser.TypeText && right.Type == parser.TypeText {
 			return table.Value{Type: parser.TypeText, Text: left.Text + right.Text}, nil
 		}
 		return table.Value{}, fmt.Errorf("cannot add %s and %s", left.Type, right.Type)
@@ -953,7 +2081,7 @@ func (e *Executor) evaluateBinaryOp(op parser.BinaryOp, left, right table.Value)
 		return table.Value{}, fmt.Errorf("cannot multiply %s and %s", left.Type, right.Type)
 
 	case parser.OpDivide:
-		if right.Integer == 0 || right.Real == 0 {
+		if isZero(right) {
 			return table.Value{}, fmt.Errorf("division by zero")
 		}
 		if left.Type == parser.TypeInteger && right.Type == parser.TypeInteger {
@@ -969,14 +2097,144 @@ func (e *Executor) evaluateBinaryOp(op parser.BinaryOp, left, right table.Value)
 		}
 		return table.Value{Type: parser.TypeReal, Real: l / r}, nil
 
+	case parser.OpConcat:
+		// Non-text operands are converted with their display form: 'v' || 2 = 'v2'
+		return table.Value{Type: parser.TypeText, Text: left.String() + right.String()}, nil
+
 	default:
 		return table.Value{}, fmt.Errorf("unsupported operator: %s", op)
 	}
 }
 
+// isZero reports whether a number is zero, reading the field its type
+// keeps it in (a DECIMAL's unscaled digits are in Integer).
+func isZero(v table.Value) bool {
+	switch v.Type {
+	case parser.TypeInteger, parser.TypeDecimal:
+		return v.Integer == 0
+	case parser.TypeReal:
+		return v.Real == 0
+	default:
+		return false
+	}
+}
+
+// fitDecimal converts a value to DECIMAL(precision, scale), rounding it to
+// the scale and rejecting it if it needs more digits than the precision
+// allows. A precision of 0 means the column was declared as plain DECIMAL,
+// so the value keeps its own scale.
+func fitDecimal(val table.Value, precision, scale int) (table.Value, error) {
+	if val.IsNull {
+		return table.Value{Type: parser.TypeDecimal, IsNull: true}, nil
+	}
+	dec, err := table.ToDecimal(val)
+	if err != nil {
+		return table.Value{}, err
+	}
+	if precision == 0 {
+		return dec, nil
+	}
+	if dec, err = table.Rescale(dec, uint8(scale)); err != nil {
+		return table.Value{}, err
+	}
+	if err := table.CheckPrecision(dec, precision); err != nil {
+		return table.Value{}, err
+	}
+	return dec, nil
+}
+
+// coerceDecimalOperands converts an INTEGER or REAL operand to DECIMAL
+// when the other side is a DECIMAL, so price * 2 and price > 9.99 are
+// computed exactly.
+func coerceDecimalOperands(left, right table.Value) (table.Value, table.Value, error) {
+	numeric := func(t parser.DataType) bool {
+		return t == parser.TypeInteger || t == parser.TypeReal
+	}
+
+	var err error
+	if left.Type == parser.TypeDecimal && numeric(right.Type) {
+		right, err = table.ToDecimal(right)
+	} else if right.Type == parser.TypeDecimal && numeric(left.Type) {
+		left, err = table.ToDecimal(left)
+	}
+	return left, right, err
+}
+
+// evaluateDecimalArithmetic handles +, -, * and / between DECIMALs.
+// ok is false for any other operator.
+func evaluateDecimalArithmetic(op parser.BinaryOp, left, right table.Value) (result table.Value, ok bool, err error) {
+	switch op {
+	case parser.OpAdd:
+		result, err = table.DecimalAdd(left, right)
+	case parser.OpSubtract:
+		result, err = table.DecimalSub(left, right)
+	case parser.OpMultiply:
+		result, err = table.DecimalMul(left, right)
+	case parser.OpDivide:
+		result, err = table.DecimalDiv(left, right)
+	default:
+		return table.Value{}, false, nil
+	}
+	return result, true, err
+}
+
+// coerceTimestampOperands parses a TEXT operand when the other side is a
+// TIMESTAMP, so created_at > '2024-01-01' compares instants, not strings.
+func coerceTimestampOperands(left, right table.Value) (table.Value, table.Value, error) {
+	var err error
+	if left.Type == parser.TypeTimestamp && right.Type == parser.TypeText {
+		right, err = table.ParseTimestamp(right.Text)
+	} else if right.Type == parser.TypeTimestamp && left.Type == parser.TypeText {
+		left, err = table.ParseTimestamp(left.Text)
+	}
+	return left, right, err
+}
+
+// evaluateTimestampArithmetic handles + and - involving timestamps.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Without an INTERVAL type we count in whole days, as PostgreSQL does
+// for its DATE type:
+//   timestamp + 1          -> the same time tomorrow
+//   timestamp - 7          -> the same time a week ago
+//   timestamp - timestamp  -> whole days between them (INTEGER)
+// Hours, minutes and calendar months go through DATE_ADD instead.
+// ok is false when the operator isn't timestamp arithmetic (e.g. a
+// comparison), letting the caller fall through to the normal rules.
+func evaluateTimestampArithmetic(op parser.BinaryOp, left, right table.Value) (result table.Value, ok bool, err error) {
+	const day = 86_400 * 1_000_000 // microseconds
+
+	switch op {
+	case parser.OpAdd:
+		if left.Type == parser.TypeTimestamp && right.Type == parser.TypeInteger {
+			return table.Value{Type: parser.TypeTimestamp, Integer: left.Integer + right.Integer*day}, true, nil
+		}
+		if left.Type == parser.TypeInteger && right.Type == parser.TypeTimestamp {
+			return table.Value{Type: parser.TypeTimestamp, Integer: right.Integer + left.Integer*day}, true, nil
+		}
+		return table.Value{}, true, fmt.Errorf("cannot add %s and %s", left.Type, right.Type)
+
+	case parser.OpSubtract:
+		if left.Type == parser.TypeTimestamp && right.Type == parser.TypeInteger {
+			return table.Value{Type: parser.TypeTimestamp, Integer: left.Integer - right.Integer*day}, true, nil
+		}
+		if left.Type == parser.TypeTimestamp && right.Type == parser.TypeTimestamp {
+			return table.Value{Type: parser.TypeInteger, Integer: (left.Integer - right.Integer) / day}, true, nil
+		}
+		return table.Value{}, true, fmt.Errorf("cannot subtract %s from %s", right.Type, left.Type)
+
+	case parser.OpMultiply, parser.OpDivide:
+		return table.Value{}, true, fmt.Errorf("unsupported operator %s for %s", op, parser.TypeTimestamp)
+	}
+
+	return table.Value{}, false, nil
+}
+
 // evaluateUnaryOp evaluates a unary operation.
 func (e *Executor) evaluateUnaryOp(op parser.UnaryOp, operand table.Value) (table.Value, error) {
 	if operand.IsNull {
+		// NOT UNKNOWN is UNKNOWN, -NULL is NULL
 		return table.Value{IsNull: true}, nil
 	}
 
@@ -991,6 +2249,9 @@ func (e *Executor) evaluateUnaryOp(op parser.UnaryOp, operand table.Value) (tabl
 		if operand.Type == parser.TypeReal {
 			return table.Value{Type: parser.TypeReal, Real: -operand.Real}, nil
 		}
+		if operand.Type == parser.TypeDecimal {
+			return table.DecimalSub(table.DecimalValue(0, 0), operand)
+		}
 		return table.Value{}, fmt.Errorf("cannot negate %s", operand.Type)
 
 	default:
@@ -1000,8 +2261,10 @@ func (e *Executor) evaluateUnaryOp(op parser.UnaryOp, operand table.Value) (tabl
 
 // GetTables returns the list of table names.
 func (e *Executor) GetTables() []string {
+	leave, _ := e.enterAccess(false)
+	defer leave()
 	var names []string
-	for name := range e.tables {
+	for name := range e.visibleTables() {
 		names = append(names, name)
 	}
 	sort.Strings(names)
@@ -1010,48 +2273,111 @@ func (e *Executor) GetTables() []string {
 
 // GetTable returns a table by name.
 func (e *Executor) GetTable(name string) (*table.Table, bool) {
-	tbl, ok := e.tables[strings.ToLower(name)]
+	leave, _ := e.enterAccess(false)
+	defer leave()
+	tbl, ok := e.lookupTable(strings.ToLower(name))
 	return tbl, ok
 }
 
-// topKHeap implements a heap for ORDER BY + LIMIT optimization.
-// For ASC order, we use a max-heap: keep the K smallest rows by
-// always ejecting the largest when we exceed K.
-// For DESC order, we use a min-heap: keep the K largest rows.
+// TableDDL returns the statements creating a table and its indexes, as
+// the catalog recorded them when it has, or made up from their definitions.
+func (e *Executor) TableDDL(name string) ([]string, bool) {
+	leave, _ := e.enterAccess(false)
+	defer leave()
+	name = strings.ToLower(name)
+	if vt, ok := e.virtual[name]; ok {
+		return []string{vt.sql}, true
+	}
+	tbl, ok := e.lookupTable(name)
+	if !ok {
+		return nil, false
+	}
+	ddl := append([]string{e.createSQL(name, tbl)}, e.indexDDL(name, tbl)...)
+	for _, t := range e.tableTriggers(name) {
+		ddl = append(ddl, t.sql)
+	}
+	return ddl, true
+}
+
+// createSQL returns the statement creating a table, as the catalog
+// recorded it when it has, or made up from its definition.
+func (e *Executor) createSQL(name string, tbl *table.Table) string {
+	cat, inner := e.catalogOf(name)
+	tableSQL := catalog.TableSQL(inner, tbl.Schema)
+	if e.isTemp(name) {
+		tableSQL = strings.Replace(tableSQL, "CREATE TABLE", "CREATE TEMP TABLE", 1)
+	}
+	if tbl.IsColumnar() {
+		tableSQL += " USING columnar"
+	}
+	if cat != nil {
+		if info, ok := cat.GetTableInfo(inner); ok && info.SQL != "" {
+			tableSQL = info.SQL
+		}
+	}
+	if v, ok := e.views[name]; ok {
+		tableSQL = v.sql
+	}
+	if pt, ok := e.partitions[name]; ok {
+		tableSQL = pt.sql
+	}
+	return tableSQL
+}
+
+// indexDDL returns the statements creating a table's indexes, in order of
+// their names.
+func (e *Executor) indexDDL(name string, tbl *table.Table) []string {
+	indexSQL := make(map[string]string)
+	if cat, inner := e.catalogOf(name); cat != nil {
+		if info, ok := cat.GetTableInfo(inner); ok {
+			for _, idx := range info.Indexes {
+				indexSQL[idx.Name] = idx.SQL
+			}
+		}
+	}
+
+	var ddl []string
+	indexes := tbl.ListIndexes()
+	sort.Strings(indexes)
+	for _, indexName := range indexes {
+		if sql, ok := indexSQL[indexName]; ok {
+			ddl = append(ddl, sql)
+		} else if idx, ok := tbl.GetIndex(indexName); ok {
+			ddl = append(ddl, catalog.IndexSQL(idx))
+		}
+	}
+	return ddl
+}
+
+// PagerStats returns the buffer pool statistics of the database.
+func (e *Executor) PagerStats() storage.PagerStats {
+	return e.pager.Stats()
+}
+
+// topKHeap implements a heap for ORDER BY + LIMIT optimization. The row
+// that sorts last in ORDER BY order is at the root, so the K rows that
+// sort first are kept by always ejecting the root when we exceed K: the
+// largest for ORDER BY x, the smallest for ORDER BY x DESC.
 //
 // EDUCATIONAL NOTE:
 // -----------------
 // When you have ORDER BY x LIMIT K on N rows, naive sorting is O(N log N).
 // With a heap of size K, we can do O(N log K) which is much faster when K << N.
 // For example, LIMIT 10 on 1M rows: O(1M * 10) vs O(1M * 20) - roughly 2x faster.
+//
+// Each ORDER BY clause keeps its own direction: for ORDER BY a, b DESC the
+// root is the row with the largest a and, among those, the smallest b.
 type topKHeap struct {
-	rows       []table.Row
-	orderBy    []parser.OrderByClause
-	schema     *table.Schema
-	descending bool // true = min-heap for DESC, false = max-heap for ASC
+	rows    []table.Row
+	orderBy []parser.OrderByClause
+	schema  *table.Schema
 }
 
 func (h *topKHeap) Len() int { return len(h.rows) }
 
 func (h *topKHeap) Less(i, j int) bool {
-	// For max-heap (ASC order): we want largest at root to eject it
-	// For min-heap (DESC order): we want smallest at root to eject it
-	for _, clause := range h.orderBy {
-		colIdx, found := h.schema.GetColumnIndex(clause.Column)
-		if !found {
-			continue
-		}
-		cmp := h.rows[i].Values[colIdx].Compare(h.rows[j].Values[colIdx])
-		if cmp != 0 {
-			// For ASC order with max-heap: larger value should be "less" so it floats to top
-			// For DESC order with min-heap: smaller value should be "less" so it floats to top
-			if h.descending {
-				return cmp < 0 // min-heap for DESC
-			}
-			return cmp > 0 // max-heap for ASC
-		}
-	}
-	return false
+	// The row that sorts later is "less", so that it floats to the root
+	return compareRows(h.rows[i], h.rows[j], h.orderBy, h.schema) > 0
 }
 
 func (h *topKHeap) Swap(i, j int) {
@@ -1069,43 +2395,3 @@ func (h *topKHeap) Pop() any {
 	h.rows = old[0 : n-1]
 	return x
 }
-
-// selectTopK selects the top K rows using a heap-based algorithm.
-// This is O(N log K) instead of O(N log N) for full sort.
-func selectTopK(rows []table.Row, k int, orderBy []parser.OrderByClause, schema *table.Schema) []table.Row {
-	if k <= 0 || len(rows) == 0 {
-		return nil
-	}
-	if k >= len(rows) {
-		// No optimization needed - need all rows anyway
-		return nil // signal to use regular sort
-	}
-
-	// Determine if first ORDER BY clause is descending
-	descending := false
-	if len(orderBy) > 0 {
-		descending = orderBy[0].Descending
-	}
-
-	h := &topKHeap{
-		rows:       make([]table.Row, 0, k+1),
-		orderBy:    orderBy,
-		schema:     schema,
-		descending: descending,
-	}
-
-	for _, row := range rows {
-		heap.Push(h, row)
-		if h.Len() > k {
-			heap.Pop(h) // Remove the worst element (largest for ASC, smallest for DESC)
-		}
-	}
-
-	// Extract results in sorted order
-	result := make([]table.Row, h.Len())
-	for i := h.Len() - 1; i >= 0; i-- {
-		result[i] = heap.Pop(h).(table.Row)
-	}
-
-	return result
-}
@@ -1,14 +1,18 @@
 package executor
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/sql/planner"
 	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
 )
 
 func setupTestExecutor(t *testing.T) (*Executor, func()) {
@@ -188,8 +192,63 @@ func TestUpdate(t *testing.T) {
 		t.Errorf("expected 1 row updated, got %d", result.RowCount)
 	}
 
-	// Note: Our simplified update doesn't persist changes yet
-	// This test just checks that the update logic runs
+	rows := executeSQL(t, exec, "SELECT age FROM users WHERE name = 'Alice'").Rows
+	if len(rows) != 1 || rows[0][0].Integer != 31 {
+		t.Errorf("expected Alice's age to be 31, got %v", rows)
+	}
+
+	// Assignments see the old row, so this swaps the columns
+	executeSQL(t, exec, "UPDATE users SET id = age, age = id WHERE name = 'Bob'")
+	rows = executeSQL(t, exec, "SELECT id, age FROM users WHERE name = 'Bob'").Rows
+	if len(rows) != 1 || rows[0][0].Integer != 25 || rows[0][1].Integer != 2 {
+		t.Errorf("expected id=25 age=2 after swap, got %v", rows)
+	}
+}
+
+func TestUpdateKeysAndGrowingRows(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_age ON users (age)")
+	for i := 1; i <= 50; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'u%d', %d)", i, i, 20+i%5))
+	}
+
+	// Growing rows no longer fit in place and move to other pages
+	long := strings.Repeat("x", 200)
+	result := executeSQL(t, exec, fmt.Sprintf("UPDATE users SET name = '%s' WHERE id <= 10", long))
+	if result.RowCount != 10 {
+		t.Errorf("expected 10 rows updated, got %d", result.RowCount)
+	}
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 50 {
+		t.Errorf("expected 50 rows after growing update, got %d", len(rows))
+	}
+	rows := executeSQL(t, exec, "SELECT name FROM users WHERE id = 3").Rows
+	if len(rows) != 1 || rows[0][0].Text != long {
+		t.Errorf("moved row not found through the primary key: %v", rows)
+	}
+
+	// Changing the primary key moves the index entry
+	executeSQL(t, exec, "UPDATE users SET id = 100 WHERE id = 5")
+	if rows := executeSQL(t, exec, "SELECT * FROM users WHERE id = 5").Rows; len(rows) != 0 {
+		t.Error("old primary key still finds the row")
+	}
+	if rows := executeSQL(t, exec, "SELECT * FROM users WHERE id = 100").Rows; len(rows) != 1 {
+		t.Error("new primary key does not find the row")
+	}
+
+	// Secondary index follows the new value
+	executeSQL(t, exec, "UPDATE users SET age = 99 WHERE id = 7")
+	rows = executeSQL(t, exec, "SELECT id FROM users WHERE age = 99").Rows
+	if len(rows) != 1 || rows[0][0].Integer != 7 {
+		t.Errorf("expected the index to find id 7 by its new age, got %v", rows)
+	}
+	for _, row := range executeSQL(t, exec, "SELECT id FROM users WHERE age = 22").Rows {
+		if row[0].Integer == 7 {
+			t.Error("old age still finds the updated row")
+		}
+	}
 }
 
 func TestDelete(t *testing.T) {
@@ -205,6 +264,141 @@ func TestDelete(t *testing.T) {
 	if result.RowCount != 1 {
 		t.Errorf("expected 1 row deleted, got %d", result.RowCount)
 	}
+
+	result = executeSQL(t, exec, "SELECT name FROM users ORDER BY name")
+	if len(result.Rows) != 2 || result.Rows[0][0].Text != "Alice" || result.Rows[1][0].Text != "Bob" {
+		t.Errorf("expected Alice and Bob to remain, got %v", result.Rows)
+	}
+
+	// The scan keeps only the rows matching WHERE, and stops at an error
+	executeSQL(t, exec, "INSERT INTO users (id, name, age) VALUES (3, 'Charlie', 17)")
+	result = executeSQL(t, exec, "EXPLAIN ANALYZE DELETE FROM users WHERE age < 18")
+	if got := planRow(t, result, "-> Table Scan (users filter age < 18)"); !strings.HasPrefix(got, "rows=1 ") {
+		t.Errorf("expected the scan to return the 1 matching row, got %q", got)
+	}
+	if _, err := exec.Execute(parseSQL(t, "DELETE FROM users WHERE nosuch = 1")); err == nil {
+		t.Error("expected an error for an unknown column in WHERE")
+	}
+
+	// Deleting again finds nothing
+	if result := executeSQL(t, exec, "DELETE FROM users WHERE age < 18"); result.RowCount != 0 {
+		t.Errorf("expected 0 rows deleted the second time, got %d", result.RowCount)
+	}
+	executeSQL(t, exec, "DELETE FROM users")
+	if result := executeSQL(t, exec, "SELECT * FROM users"); len(result.Rows) != 0 {
+		t.Errorf("expected empty table, got %d rows", len(result.Rows))
+	}
+}
+
+func TestDeleteByPrimaryKeyAndRollback(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "CREATE INDEX idx_name ON users (name)")
+	for i := 1; i <= 200; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d')", i, i))
+	}
+
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "DELETE FROM users WHERE id > 100")
+	if rows := executeSQL(t, exec, "SELECT * FROM users WHERE id = 150").Rows; len(rows) != 0 {
+		t.Error("deleted row still found through the primary key")
+	}
+	executeSQL(t, exec, "ROLLBACK")
+
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 200 {
+		t.Errorf("expected 200 rows after rolling back DELETE, got %d", len(rows))
+	}
+	if rows := executeSQL(t, exec, "SELECT * FROM users WHERE id = 150").Rows; len(rows) != 1 {
+		t.Error("rolled back delete: row not found through the primary key")
+	}
+
+	// The freed key can be reused once the row is gone
+	executeSQL(t, exec, "DELETE FROM users WHERE id = 7")
+	executeSQL(t, exec, "INSERT INTO users VALUES (7, 'seven')")
+	rows := executeSQL(t, exec, "SELECT name FROM users WHERE id = 7").Rows
+	if len(rows) != 1 || rows[0][0].Text != "seven" {
+		t.Errorf("expected the re-inserted row, got %v", rows)
+	}
+}
+
+func TestDuplicatePrimaryKey(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+
+	_, err := exec.Execute(parseSQL(t, "INSERT INTO users VALUES (1, 'Mallory')"))
+	if !errors.Is(err, table.ErrDuplicateKey) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+	_, err = exec.Execute(parseSQL(t, "UPDATE users SET id = 1 WHERE id = 2"))
+	if !errors.Is(err, table.ErrDuplicateKey) {
+		t.Fatalf("expected a duplicate key error for the UPDATE, got %v", err)
+	}
+
+	// A scan and a lookup still agree on the one row with the key
+	if got := resultText(executeSQL(t, exec, "SELECT id, name FROM users")); got != "1 Alice, 2 Bob" {
+		t.Errorf("expected the original rows, got %s", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users WHERE id = 1")); got != "Alice" {
+		t.Errorf("expected Alice, got %s", got)
+	}
+
+	// Updating a row without changing its key is fine
+	executeSQL(t, exec, "UPDATE users SET id = 2, name = 'Robert' WHERE id = 2")
+
+	// An UPDATE failing part way through its rows changes none of them,
+	// in a transaction or not
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, v INTEGER)")
+	for i := 1; i <= 3; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO t VALUES (%d, %d)", i, i))
+	}
+	for _, inTransaction := range []bool{false, true} {
+		if inTransaction {
+			executeSQL(t, exec, "BEGIN")
+		}
+		if _, err := exec.Execute(parseSQL(t, "UPDATE t SET id = 9")); !errors.Is(err, table.ErrDuplicateKey) {
+			t.Errorf("expected a duplicate key error, got %v", err)
+		}
+		if got := resultText(executeSQL(t, exec, "SELECT id FROM t ORDER BY id")); got != "1, 2, 3" {
+			t.Errorf("expected no row changed (in a transaction: %v), got %s", inTransaction, got)
+		}
+		if inTransaction {
+			executeSQL(t, exec, "COMMIT")
+		}
+	}
+
+	// A NULL key, given or left out, is no key at all
+	for _, sql := range []string{
+		"INSERT INTO users VALUES (NULL, 'a')",
+		"INSERT INTO users (name) VALUES ('c')",
+		"UPDATE users SET id = NULL WHERE id = 1",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), "primary key column id cannot be NULL") {
+			t.Errorf("%s: expected a NULL primary key error, got %v", sql, err)
+		}
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM users")); got != "2" {
+		t.Errorf("expected no rows added, got %s", got)
+	}
+
+	// A composite key is only a duplicate if all its columns are
+	executeSQL(t, exec, "CREATE TABLE grades (student INTEGER, course TEXT, PRIMARY KEY (student, course))")
+	executeSQL(t, exec, "INSERT INTO grades VALUES (1, 'math')")
+	executeSQL(t, exec, "INSERT INTO grades VALUES (1, 'art')")
+	_, err = exec.Execute(parseSQL(t, "INSERT INTO grades VALUES (1, 'math')"))
+	if err == nil || !strings.Contains(err.Error(), "(student, course)=(1, math)") {
+		t.Errorf("expected a duplicate key error naming the key, got %v", err)
+	}
+
+	// Without a primary key, rows may be equal
+	executeSQL(t, exec, "CREATE TABLE log (msg TEXT)")
+	executeSQL(t, exec, "INSERT INTO log VALUES ('x')")
+	executeSQL(t, exec, "INSERT INTO log VALUES ('x')")
 }
 
 func TestDropTable(t *testing.T) {
@@ -258,6 +452,35 @@ func TestResultFormatting(t *testing.T) {
 	}
 }
 
+func TestResultWriteTo(t *testing.T) {
+	result := &Result{
+		Columns: []string{"id", "name"},
+		Rows: [][]table.Value{
+			{intValue(1), textValue("Alice")},
+			{intValue(22), {IsNull: true}},
+		},
+	}
+	want := "+----+-------+\n" +
+		"| id | name  |\n" +
+		"+----+-------+\n" +
+		"| 1  | Alice |\n" +
+		"| 22 | NULL  |\n" +
+		"+----+-------+\n" +
+		"(2 rows)\n"
+
+	var sb strings.Builder
+	n, err := result.WriteTo(&sb)
+	if err != nil || sb.String() != want || n != int64(len(want)) {
+		t.Errorf("expected %d bytes %q, got %d bytes %q (err %v)", len(want), want, n, sb.String(), err)
+	}
+	if got := result.String(); got != want {
+		t.Errorf("expected String to match WriteTo, got %q", got)
+	}
+	if got := (&Result{Message: "Table created"}).String(); got != "Table created" {
+		t.Errorf("expected the message, got %q", got)
+	}
+}
+
 func TestArithmeticExpressions(t *testing.T) {
 	exec, cleanup := setupTestExecutor(t)
 	defer cleanup()
@@ -352,6 +575,292 @@ func TestSelectWithPrimaryKeyIndex(t *testing.T) {
 	}
 }
 
+func TestSelectWithPrimaryKeyRange(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, price REAL)")
+	for _, id := range []int{-5, 1, 2, 99, 100, 101, 150, 256, 1000} {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO items (id, price) VALUES (%d, %d.5)", id, id))
+	}
+
+	tests := []struct {
+		sql  string
+		want []int64
+	}{
+		{"SELECT id FROM items WHERE id > 100", []int64{101, 150, 256, 1000}},
+		{"SELECT id FROM items WHERE id >= 100", []int64{100, 101, 150, 256, 1000}},
+		{"SELECT id FROM items WHERE id < 2", []int64{-5, 1}},
+		{"SELECT id FROM items WHERE 2 >= id", []int64{-5, 1, 2}},
+		{"SELECT id FROM items WHERE id > 1 AND id < 256", []int64{2, 99, 100, 101, 150}},
+		{"SELECT id FROM items WHERE id > 1 AND id <= 256 AND price > 120.0", []int64{150, 256}},
+		{"SELECT id FROM items WHERE id > 100 LIMIT 2", []int64{101, 150}},
+		{"SELECT id FROM items WHERE id > 100 ORDER BY id DESC LIMIT 1", []int64{1000}},
+	}
+	for _, tt := range tests {
+		result := executeSQL(t, exec, tt.sql)
+		var got []int64
+		for _, row := range result.Rows {
+			got = append(got, row[0].Integer)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.want, got)
+		}
+	}
+
+	plan := NewPlanner().Plan(parseSQL(t, "SELECT * FROM items WHERE id > 100").(*parser.SelectStatement), exec.tables["items"])
+	if plan.Type != PlanIndexRangeScan || plan.RangeLower.Integer != 100 || plan.LowerInclusive || plan.RangeUpper != nil {
+		t.Errorf("expected a range scan from 100 exclusive, got %+v", plan)
+	}
+	plan = NewPlanner().Plan(parseSQL(t, "SELECT * FROM items WHERE id > 1.5").(*parser.SelectStatement), exec.tables["items"])
+	if plan.Type != PlanTableScan {
+		t.Errorf("expected a REAL bound on an INTEGER key to scan the table, got %+v", plan)
+	}
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "composite.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	// The key's order, course then student, isn't the columns' order
+	executeSQL(t, exec, "CREATE TABLE grades (student INTEGER, course TEXT, grade INTEGER, PRIMARY KEY (course, student))")
+	for _, row := range []string{"(1, 'math', 90)", "(2, 'math', 75)", "(1, 'art', 60)", "(2, 'art', 85)", "(3, 'math', 70)"} {
+		executeSQL(t, exec, "INSERT INTO grades VALUES "+row)
+	}
+	executeSQL(t, exec, "UPDATE grades SET student = 4 WHERE student = 3")
+	executeSQL(t, exec, "DELETE FROM grades WHERE course = 'art' AND student = 1")
+	exec.Flush()
+	pager.Close()
+
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	want := "CREATE TABLE grades (student INTEGER, course TEXT, grade INTEGER, PRIMARY KEY (course, student))"
+	if ddl, _ := exec.TableDDL("grades"); ddl[0] != want {
+		t.Errorf("expected %q, got %q", want, ddl[0])
+	}
+
+	stmt := parseSQL(t, "SELECT grade FROM grades WHERE student = 2 AND course = 'math'").(*parser.SelectStatement)
+	plan := NewPlanner().Plan(stmt, exec.tables["grades"])
+	if plan.Type != PlanIndexScan || len(plan.IndexKeys) != 2 || plan.IndexKeys[0].Text != "math" {
+		t.Errorf("expected a lookup of the whole key, got %+v", plan)
+	}
+	if explained, _ := exec.GetQueryPlan(stmt); explained.AccessMethod != planner.IndexLookup {
+		t.Errorf("expected EXPLAIN to show a lookup, got %s", explained)
+	}
+	if plan := NewPlanner().Plan(parseSQL(t, "SELECT * FROM grades WHERE student = 2").(*parser.SelectStatement),
+		exec.tables["grades"]); plan.Type != PlanTableScan {
+		t.Errorf("expected part of the key to scan the table, got %+v", plan)
+	}
+
+	// Only a leading part of the key is in order, so only its predicates
+	// count as on the key
+	for sql, onKey := range map[string]bool{
+		"SELECT * FROM grades WHERE student = 2":                     false,
+		"SELECT * FROM grades WHERE course = 'math'":                 true,
+		"SELECT * FROM grades WHERE course > 'a' AND student = 2":    false,
+		"SELECT * FROM grades WHERE course = 'math' AND student > 1": true,
+	} {
+		explained, err := exec.GetQueryPlan(parseSQL(t, sql).(*parser.SelectStatement))
+		if err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		for _, pred := range explained.Predicates {
+			if pred.Column == "student" && pred.IsOnPK != onKey {
+				t.Errorf("%s: expected IsOnPK %v for student, got %v", sql, onKey, pred.IsOnPK)
+			}
+		}
+	}
+
+	// No column of the key may be NULL
+	for _, sql := range []string{
+		"INSERT INTO grades VALUES (NULL, 'math', 99)",
+		"INSERT INTO grades VALUES (5, NULL, 99)",
+		"INSERT INTO grades (student, grade) VALUES (5, 99)",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), "cannot be NULL") {
+			t.Errorf("%s: expected a NULL primary key error, got %v", sql, err)
+		}
+	}
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT grade FROM grades WHERE student = 2 AND course = 'math'", "75"},
+		{"SELECT grade FROM grades WHERE course = 'math' AND student = 4", "70"},
+		{"SELECT grade FROM grades WHERE course = 'art' AND student = 1", ""},
+		{"SELECT student, course FROM grades ORDER BY course, student", "2 art, 1 math, 2 math, 4 math"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+	if got := resultText(executeSQL(t, exec, "PRAGMA integrity_check")); got != "ok" {
+		t.Errorf("expected integrity_check ok, got %s", got)
+	}
+}
+
+func TestSelectWithSecondaryIndex(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	for i, age := range []int{30, 25, 30, 41, 18, 30, 65} {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO people (id, name, age) VALUES (%d, 'p%d', %d)", i+1, i+1, age))
+	}
+	executeSQL(t, exec, "INSERT INTO people (id, name) VALUES (8, 'p8')")
+	executeSQL(t, exec, "CREATE INDEX idx_people_age ON people (age)")
+	executeSQL(t, exec, "CREATE UNIQUE INDEX idx_people_name ON people (name)")
+
+	tests := []struct {
+		sql  string
+		want []int64
+	}{
+		{"SELECT id FROM people WHERE age = 30", []int64{1, 3, 6}},
+		{"SELECT id FROM people WHERE age = 30 AND id > 1", []int64{3, 6}},
+		{"SELECT id FROM people WHERE age = 31", nil},
+		{"SELECT id FROM people WHERE name = 'p4'", []int64{4}},
+		{"SELECT id FROM people WHERE age > 25", []int64{1, 3, 6, 4, 7}},
+		{"SELECT id FROM people WHERE age >= 25 AND age < 41", []int64{2, 1, 3, 6}},
+		{"SELECT id FROM people WHERE age <= 30", []int64{5, 2, 1, 3, 6}},
+		{"SELECT id FROM people WHERE age > 25 LIMIT 2", []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		result := executeSQL(t, exec, tt.sql)
+		var got []int64
+		for _, row := range result.Rows {
+			got = append(got, row[0].Integer)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.want, got)
+		}
+	}
+
+	tbl := exec.tables["people"]
+	plan := NewPlanner().Plan(parseSQL(t, "SELECT * FROM people WHERE name = 'p1' AND age = 30").(*parser.SelectStatement), tbl)
+	if plan.Type != PlanIndexScan || plan.Index == nil || plan.Index.Name != "idx_people_name" {
+		t.Errorf("expected a lookup on the unique index, got %+v", plan)
+	}
+	plan = NewPlanner().Plan(parseSQL(t, "SELECT * FROM people WHERE id = 1 AND age = 30").(*parser.SelectStatement), tbl)
+	if plan.Type != PlanIndexScan || plan.Index != nil {
+		t.Errorf("expected a primary key lookup, got %+v", plan)
+	}
+	plan = NewPlanner().Plan(parseSQL(t, "SELECT * FROM people WHERE age = 'thirty'").(*parser.SelectStatement), tbl)
+	if plan.Type != PlanTableScan {
+		t.Errorf("expected a TEXT value on an INTEGER index to scan the table, got %+v", plan)
+	}
+}
+
+func TestSelectChoosesAccessPathByCost(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	exec.SetAutoAnalyze(-1, 0) // analyzed only when the test says so
+
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY, status INTEGER, code INTEGER, note TEXT)")
+	for i := 1; i <= 1000; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO orders VALUES (%d, %d, %d, 'order note %d')", i, i%2, i*7, i))
+	}
+	executeSQL(t, exec, "CREATE INDEX idx_orders_status ON orders (status)")
+	executeSQL(t, exec, "CREATE INDEX idx_orders_code ON orders (code)")
+
+	plan := func(sql string) *QueryPlan {
+		return NewPlanner().Plan(parseSQL(t, sql).(*parser.SelectStatement), exec.tables["orders"])
+	}
+
+	// Before ANALYZE the rules use any index
+	if p := plan("SELECT * FROM orders WHERE status = 1"); p.Index == nil || p.Index.Name != "idx_orders_status" {
+		t.Errorf("expected the rules to use idx_orders_status, got %+v", p)
+	}
+
+	executeSQL(t, exec, "ANALYZE orders")
+
+	tests := []struct {
+		sql   string
+		typ   PlanType
+		index string
+		rows  int
+	}{
+		// Half the table through a secondary index costs more than a scan
+		{"SELECT * FROM orders WHERE status = 1", PlanTableScan, "", 500},
+		{"SELECT * FROM orders WHERE code = 700", PlanIndexScan, "idx_orders_code", 1},
+		{"SELECT * FROM orders WHERE status = 1 AND code = 707", PlanIndexScan, "idx_orders_code", 1},
+		{"SELECT * FROM orders WHERE id > 990", PlanIndexRangeScan, "", 10},
+		{"SELECT * FROM orders WHERE id > 10", PlanTableScan, "", 990},
+		{"SELECT * FROM orders WHERE id = 5 AND status = 1", PlanIndexScan, "", 1},
+	}
+	for _, tt := range tests {
+		p := plan(tt.sql)
+		index := ""
+		if p.Index != nil {
+			index = p.Index.Name
+		}
+		if p.Type != tt.typ || index != tt.index {
+			t.Errorf("%s: expected plan %d using %q, got %d using %q (cost %.2f)", tt.sql, tt.typ, tt.index, p.Type, index, p.Cost)
+		}
+		if rows := executeSQL(t, exec, tt.sql).Rows; len(rows) != tt.rows {
+			t.Errorf("%s: expected %d rows, got %d", tt.sql, tt.rows, len(rows))
+		}
+	}
+
+	// EXPLAIN makes the same choice
+	result := executeSQL(t, exec, "EXPLAIN SELECT * FROM orders WHERE status = 1")
+	if got := planRow(t, result, "Access Method"); got != "FULL_TABLE_SCAN" {
+		t.Errorf("expected EXPLAIN to choose a table scan, got %q", got)
+	}
+}
+
+func TestSelectSkipsPagesByZone(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE events (seq INTEGER, score REAL, note TEXT)")
+	for i := 1; i <= 1000; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO events VALUES (%d, %d.5, 'an event with a fairly long note')", i, i%10))
+	}
+
+	ranges := func(where string) string {
+		stmt := parseSQL(t, "SELECT * FROM events WHERE "+where).(*parser.SelectStatement)
+		var parts []string
+		for _, r := range scanRanges(stmt.Where, exec.tables["events"].Schema, nil) {
+			parts = append(parts, fmt.Sprintf("%d:%v:%v", r.Column, r.Lower, r.Upper))
+		}
+		return strings.Join(parts, " ")
+	}
+	for _, tt := range []struct{ where, want string }{
+		{"seq = 5", "0:5:5"},
+		{"seq > 5 AND seq <= 10 AND note > 'a'", "0:5:10 2:a:<nil>"},
+		{"seq > 5 OR seq < 2", ""},
+		{"score > 5", ""}, // REAL compares differently from WHERE
+		{"seq > '5'", ""},
+	} {
+		if got := ranges(tt.where); got != tt.want {
+			t.Errorf("%s: expected ranges %q, got %q", tt.where, tt.want, got)
+		}
+	}
+
+	// The answers don't change as the zones are built and used, nor after
+	// rows move around
+	count := func(where string) string {
+		return resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM events WHERE "+where))
+	}
+	for i := 0; i < 2; i++ {
+		if got := count("seq >= 991"); got != "10" {
+			t.Errorf("expected 10 rows, got %s", got)
+		}
+	}
+	executeSQL(t, exec, "UPDATE events SET seq = seq + 2000 WHERE seq <= 5")
+	executeSQL(t, exec, "DELETE FROM events WHERE seq = 995")
+	executeSQL(t, exec, "INSERT INTO events VALUES (3000, 0.5, 'late')")
+	if got := count("seq >= 991"); got != "15" {
+		t.Errorf("expected 15 rows, got %s", got)
+	}
+	if got := count("seq = 2003"); got != "1" {
+		t.Errorf("expected the updated row, got %s", got)
+	}
+}
+
 func TestSelectWithPrimaryKeyAndAdditionalConditions(t *testing.T) {
 	exec, cleanup := setupTestExecutor(t)
 	defer cleanup()
@@ -469,15 +978,14 @@ func TestExplainDoesNotExecute(t *testing.T) {
 		t.Fatalf("expected 1 row before EXPLAIN DELETE, got %d", len(result.Rows))
 	}
 
-	// EXPLAIN DELETE should NOT actually delete (it will error since we only support SELECT for now)
-	// But we need to check the row still exists after attempting
+	// EXPLAIN DELETE describes the delete but should NOT actually delete
 	l := lexer.New("EXPLAIN DELETE FROM users WHERE id = 1")
 	p := parser.New(l)
 	stmt, _ := p.Parse()
 
-	// This may error (since EXPLAIN only supports SELECT), but we need to verify
-	// the row wasn't deleted
-	exec.Execute(stmt)
+	if _, err := exec.Execute(stmt); err != nil {
+		t.Fatalf("EXPLAIN DELETE failed: %v", err)
+	}
 
 	// Row should still exist
 	result = executeSQL(t, exec, "SELECT * FROM users")
@@ -575,6 +1083,33 @@ func TestOrderByLimitOffset(t *testing.T) {
 	}
 }
 
+func TestOrderByLimitMixedDirections(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE pairs (id INTEGER PRIMARY KEY, a INTEGER, b INTEGER)")
+	for i := 1; i <= 30; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO pairs VALUES (%d, %d, %d)", i, i%3, i%7))
+	}
+
+	if got := resultText(executeSQL(t, exec, "SELECT a, b FROM pairs ORDER BY a, b DESC LIMIT 4")); got != "0 6, 0 6, 0 5, 0 4" {
+		t.Errorf("expected 0 6, 0 6, 0 5, 0 4, got %s", got)
+	}
+
+	// The top K rows are the first K of the whole sort, whatever the
+	// direction of each clause
+	for _, orderBy := range []string{"a, b DESC, id", "a DESC, b, id DESC", "a DESC, b DESC, id", "b, a DESC, id DESC"} {
+		all := executeSQL(t, exec, "SELECT id FROM pairs ORDER BY "+orderBy).Rows
+		for _, limit := range []int{1, 5, 12} {
+			got := executeSQL(t, exec, fmt.Sprintf("SELECT id FROM pairs ORDER BY %s LIMIT %d", orderBy, limit))
+			want := &Result{Rows: all[:limit]}
+			if resultText(got) != resultText(want) {
+				t.Errorf("ORDER BY %s LIMIT %d: expected %s, got %s", orderBy, limit, resultText(want), resultText(got))
+			}
+		}
+	}
+}
+
 func BenchmarkOrderByLimit(b *testing.B) {
 	exec, cleanup := setupBenchExecutor(b)
 	defer cleanup()
@@ -706,6 +1241,98 @@ func TestDropIndex(t *testing.T) {
 	}
 }
 
+func TestIndexesSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "indexes.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "create table Users (id integer primary key, age int not null)")
+	executeSQL(t, exec, "CREATE UNIQUE INDEX idx_age ON users (Age)")
+	for i := 1; i <= 50; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, %d)", i, i+100))
+	}
+	executeSQL(t, exec, "DELETE FROM users WHERE id > 40")
+	executeSQL(t, exec, "VACUUM")
+	exec.Flush()
+	pager.Close()
+
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	want := []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER NOT NULL)",
+		"CREATE UNIQUE INDEX idx_age ON users (age)",
+	}
+	if ddl, _ := exec.TableDDL("users"); strings.Join(ddl, "; ") != strings.Join(want, "; ") {
+		t.Errorf("expected %q, got %q", want, ddl)
+	}
+
+	plan, err := exec.GetQueryPlan(parseSQL(t, "SELECT * FROM users WHERE age = 120").(*parser.SelectStatement))
+	if err != nil || plan.IndexName != "idx_age" {
+		t.Errorf("expected a lookup in idx_age after reopening, got %+v, %v", plan, err)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM users WHERE age = 120")); got != "20" {
+		t.Errorf("expected 20, got %s", got)
+	}
+	if _, err := exec.Execute(parseSQL(t, "INSERT INTO users VALUES (99, 120)")); err == nil {
+		t.Error("expected the reopened unique index to reject a duplicate age")
+	}
+}
+
+func TestDropTableFreesPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drop.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	fill := func(name string) {
+		executeSQL(t, exec, "CREATE TABLE "+name+" (id INTEGER PRIMARY KEY, tag INTEGER, body TEXT)")
+		executeSQL(t, exec, "CREATE INDEX idx_"+name+" ON "+name+" (tag)")
+		for i := 1; i <= 200; i++ {
+			executeSQL(t, exec, fmt.Sprintf("INSERT INTO %s VALUES (%d, %d, 'row %d')", name, i, i/10, i))
+		}
+		// A row large enough for an overflow chain
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO %s VALUES (0, 0, '%s')", name, strings.Repeat("x", 3000)))
+		executeSQL(t, exec, "ANALYZE "+name)
+	}
+
+	fill("old")
+	used := pager.PageCount()
+	executeSQL(t, exec, "DROP TABLE old")
+	if _, count := pager.FreeList(); count == 0 {
+		t.Fatal("expected DROP TABLE to free the table's pages")
+	}
+	if got := resultText(executeSQL(t, exec, "PRAGMA integrity_check")); got != "ok" {
+		t.Errorf("expected integrity_check ok after DROP TABLE, got %s", got)
+	}
+
+	// The free list survives reopening the database
+	exec.Flush()
+	_, freed := pager.FreeList()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+	if _, count := pager.FreeList(); count != freed {
+		t.Errorf("expected %d free pages after reopening, got %d", freed, count)
+	}
+
+	// The same table again fits in the freed pages
+	fill("new")
+	if pager.PageCount() > used {
+		t.Errorf("expected the file to stay at %d pages, got %d (freed %d)", used, pager.PageCount(), freed)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM new WHERE body = 'row 7'")); got != "1" {
+		t.Errorf("expected the new table to be intact, got %s", got)
+	}
+
+	// Dropping an index frees its B-tree too
+	_, before := pager.FreeList()
+	executeSQL(t, exec, "DROP INDEX idx_new")
+	if _, after := pager.FreeList(); after <= before {
+		t.Errorf("expected DROP INDEX to free pages, had %d free, now %d", before, after)
+	}
+	if got := resultText(executeSQL(t, exec, "PRAGMA integrity_check")); got != "ok" {
+		t.Errorf("expected integrity_check ok, got %s", got)
+	}
+}
+
 func TestCreateIndexOnNonexistentTable(t *testing.T) {
 	exec, cleanup := setupTestExecutor(t)
 	defer cleanup()
@@ -761,6 +1388,44 @@ func TestIndexMaintainedOnInsert(t *testing.T) {
 	}
 }
 
+func TestIndexMaintainedOnUpdateAndDelete(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_users_age ON users (age)")
+	executeSQL(t, exec, "CREATE UNIQUE INDEX idx_users_name ON users (name)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice', 30)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob', 25)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (3, 'Charlie', 30)")
+
+	// Rewritten in place, and moved to a new location by growing
+	executeSQL(t, exec, "UPDATE users SET age = 40 WHERE id = 1")
+	executeSQL(t, exec, "UPDATE users SET name = '"+strings.Repeat("b", 500)+"', age = 26 WHERE id = 2")
+	executeSQL(t, exec, "DELETE FROM users WHERE id = 3")
+
+	// A freed unique key can be taken again
+	executeSQL(t, exec, "INSERT INTO users VALUES (4, 'Bob', 30)")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT id FROM users WHERE age = 30", "4"},
+		{"SELECT id FROM users WHERE age = 40", "1"},
+		{"SELECT id FROM users WHERE age = 26", "2"},
+		{"SELECT id FROM users WHERE age = 25", ""},
+		{"SELECT id FROM users WHERE name = 'Bob'", "4"},
+		{"SELECT id FROM users WHERE name = 'Charlie'", ""},
+		{"PRAGMA integrity_check", "ok"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+}
+
 func TestCreateIndexOnExistingData(t *testing.T) {
 	exec, cleanup := setupTestExecutor(t)
 	defer cleanup()
@@ -813,7 +1478,7 @@ func TestAnalyzeCommand(t *testing.T) {
 	// Create table and insert data
 	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
 	for i := 1; i <= 10; i++ {
-		executeSQL(t, exec, "INSERT INTO users (id, name) VALUES (1, 'User')")
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users (id, name) VALUES (%d, 'User')", i))
 	}
 
 	// Run ANALYZE
@@ -849,3 +1514,382 @@ func TestAnalyzeAllTables(t *testing.T) {
 		t.Errorf("expected message about 2 tables, got %q", result.Message)
 	}
 }
+
+func parseSQL(t *testing.T, sql string) parser.Statement {
+	t.Helper()
+	stmt, err := parser.New(lexer.New(sql)).Parse()
+	if err != nil {
+		t.Fatalf("Parse error for %q: %v", sql, err)
+	}
+	return stmt
+}
+
+func TestExecuteWithParams(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+
+	insert := parseSQL(t, "INSERT INTO users (id, name) VALUES (?, ?)")
+	args := [][]table.Value{
+		{{Type: parser.TypeInteger, Integer: 1}, {Type: parser.TypeText, Text: "Alice"}},
+		{{Type: parser.TypeInteger, Integer: 2}, {Type: parser.TypeText, Text: "'; DROP TABLE users; --"}},
+	}
+	for _, a := range args {
+		if _, err := exec.ExecuteWithParams(insert, a); err != nil {
+			t.Fatalf("ExecuteWithParams failed: %v", err)
+		}
+	}
+
+	// Placeholder on the primary key should still use the index
+	sel := parseSQL(t, "SELECT name FROM users WHERE id = ?")
+	result, err := exec.ExecuteWithParams(sel, []table.Value{{Type: parser.TypeInteger, Integer: 2}})
+	if err != nil {
+		t.Fatalf("ExecuteWithParams failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0].Text != "'; DROP TABLE users; --" {
+		t.Errorf("expected injected text to round-trip as data, got %v", result.Rows)
+	}
+
+	if len(exec.GetTables()) != 1 {
+		t.Errorf("expected users table to survive, got %v", exec.GetTables())
+	}
+}
+
+func TestExecuteWithParamsCountMismatch(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+
+	stmt := parseSQL(t, "SELECT * FROM users WHERE id = ?")
+	if _, err := exec.ExecuteWithParams(stmt, nil); err == nil {
+		t.Error("expected error for missing parameter")
+	}
+
+	// Executing without binding at all reports the unbound placeholder
+	if _, err := exec.Execute(stmt); err == nil {
+		t.Error("expected error for unbound placeholder")
+	}
+}
+
+func TestCastExpression(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE readings (id INTEGER PRIMARY KEY, raw TEXT, value REAL)")
+	executeSQL(t, exec, "INSERT INTO readings VALUES (1, '10', 9.7)")
+	executeSQL(t, exec, "INSERT INTO readings VALUES (2, '20', 20.2)")
+	executeSQL(t, exec, "INSERT INTO readings VALUES (3, CAST(30 AS TEXT), CAST('30.5' AS REAL))")
+
+	result := executeSQL(t, exec, "SELECT id FROM readings WHERE CAST(raw AS INTEGER) >= 20")
+	if len(result.Rows) != 2 {
+		t.Errorf("expected 2 rows, got %d", len(result.Rows))
+	}
+
+	result = executeSQL(t, exec, "SELECT id FROM readings WHERE CAST(value AS INTEGER) = 9")
+	if len(result.Rows) != 1 || result.Rows[0][0].Integer != 1 {
+		t.Errorf("expected row 1 (9.7 truncates to 9), got %v", result.Rows)
+	}
+
+	// Invalid conversions are errors, not silent zeros
+	stmt := parseSQL(t, "SELECT id FROM readings WHERE CAST('abc' AS INTEGER) = 1")
+	if _, err := exec.Execute(stmt); err == nil {
+		t.Error("expected error casting 'abc' to INTEGER")
+	}
+}
+
+func TestDecimalColumns(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY, total DECIMAL(10, 2), rate NUMERIC)")
+	executeSQL(t, exec, "INSERT INTO orders VALUES (1, 0.1, 0.1)")
+	executeSQL(t, exec, "INSERT INTO orders VALUES (2, 19.999, '0.2')")
+	executeSQL(t, exec, "INSERT INTO orders VALUES (3, 5, 1.125)")
+
+	// Values are rounded to the column's scale on the way in
+	result := executeSQL(t, exec, "SELECT total FROM orders WHERE id = 2")
+	if got := result.Rows[0][0].String(); got != "20.00" {
+		t.Errorf("expected 19.999 to be stored as 20.00, got %s", got)
+	}
+
+	tests := []struct {
+		sql      string
+		expected []int64
+	}{
+		// 0.1 + 0.2 is exactly 0.3, unlike REAL
+		{"SELECT id FROM orders WHERE rate + 0.2 = 0.3", []int64{1}},
+		{"SELECT id FROM orders WHERE total * 3 = 60", []int64{2}},
+		{"SELECT id FROM orders WHERE total - 0.05 = 4.95", []int64{3}},
+		{"SELECT id FROM orders WHERE total / 3 > 6.66", []int64{2}},
+		{"SELECT id FROM orders WHERE -total < -10", []int64{2}},
+		{"SELECT id FROM orders WHERE ROUND(rate, 2) = 1.13", []int64{3}},
+		{"SELECT id FROM orders WHERE CAST(total AS INTEGER) = 5", []int64{3}},
+		{"SELECT id FROM orders WHERE CAST(rate AS DECIMAL(3, 1)) = 1.1", []int64{3}},
+	}
+
+	for _, tt := range tests {
+		result := executeSQL(t, exec, tt.sql)
+		if len(result.Rows) != len(tt.expected) {
+			t.Errorf("%s: expected %d rows, got %d", tt.sql, len(tt.expected), len(result.Rows))
+			continue
+		}
+		for i, id := range tt.expected {
+			if result.Rows[i][0].Integer != id {
+				t.Errorf("%s: row %d expected id %d, got %d", tt.sql, i, id, result.Rows[i][0].Integer)
+			}
+		}
+	}
+
+	// DECIMAL(10, 2) holds at most 8 digits before the point
+	if _, err := exec.Execute(parseSQL(t, "INSERT INTO orders VALUES (4, 123456789, 0)")); err == nil {
+		t.Error("expected numeric overflow error")
+	}
+}
+
+func TestVarcharLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "varchar.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, code VARCHAR(3), bio TEXT)")
+	exec.Flush()
+	pager.Close()
+
+	// The length survives reopening the database
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'abc', 'any length at all')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'äöü', NULL)") // characters, not bytes
+	for _, sql := range []string{
+		"INSERT INTO users VALUES (3, 'abcd', NULL)",
+		"UPDATE users SET code = 'wxyz' WHERE id = 1",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), "value too long for column code VARCHAR(3)") {
+			t.Errorf("%s: expected a value too long error, got %v", sql, err)
+		}
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT code FROM users")); got != "abc, äöü" {
+		t.Errorf("expected the rows unchanged, got %s", got)
+	}
+
+	if ddl, _ := exec.TableDDL("users"); ddl[0] != "CREATE TABLE users (id INTEGER PRIMARY KEY, code VARCHAR(3), bio TEXT)" {
+		t.Errorf("unexpected DDL %q", ddl[0])
+	}
+	if got := resultText(executeSQL(t, exec, "DESCRIBE users")); !strings.Contains(got, "code VARCHAR(3) YES NO") {
+		t.Errorf("expected DESCRIBE to show the length, got %s", got)
+	}
+}
+
+func TestCollateNoCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collate.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE users (code TEXT COLLATE NOCASE PRIMARY KEY, email TEXT COLLATE nocase, name TEXT)")
+	executeSQL(t, exec, "CREATE UNIQUE INDEX idx_email ON users (email)")
+	exec.Flush()
+	pager.Close()
+
+	// The collation survives reopening the database
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	executeSQL(t, exec, "INSERT INTO users VALUES ('ab', 'Bob@Example.com', 'Bob')")
+	executeSQL(t, exec, "INSERT INTO users VALUES ('CD', 'carol@example.com', 'carol')")
+	executeSQL(t, exec, "INSERT INTO users VALUES ('Ef', 'alice@example.com', 'Alice')")
+
+	// Keys differing only in case are duplicates
+	for _, sql := range []string{
+		"INSERT INTO users VALUES ('AB', 'dave@example.com', 'Dave')",
+		"INSERT INTO users VALUES ('gh', 'BOB@example.COM', 'Bobby')",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected a duplicate key error", sql)
+		}
+	}
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT name FROM users WHERE email = 'BOB@EXAMPLE.COM'", "Bob"},
+		{"SELECT name FROM users WHERE 'cd' = code", "carol"},
+		{"SELECT name FROM users WHERE code > 'cd'", "Alice"},
+		{"SELECT name FROM users WHERE name = 'bob'", ""},
+		{"SELECT code FROM users ORDER BY code DESC", "Ef, CD, ab"},
+		{"SELECT name FROM users ORDER BY name", "Alice, Bob, carol"},
+		{"SELECT collation_name FROM information_schema.columns WHERE table_name = 'users'", "NOCASE, NOCASE, BINARY"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+
+	if ddl, _ := exec.TableDDL("users"); !strings.Contains(ddl[0], "email TEXT COLLATE NOCASE") {
+		t.Errorf("unexpected DDL %q", ddl[0])
+	}
+}
+
+func TestAlterTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alter.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_name ON users (name)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice', 30)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob', 25)")
+
+	// Rows written before ADD COLUMN read NULL in it
+	executeSQL(t, exec, "ALTER TABLE users ADD COLUMN email TEXT")
+	executeSQL(t, exec, "INSERT INTO users VALUES (3, 'Carol', 41, 'carol@example.com')")
+	if got, want := resultText(executeSQL(t, exec, "SELECT * FROM users ORDER BY id")),
+		"1 Alice 30 NULL, 2 Bob 25 NULL, 3 Carol 41 carol@example.com"; got != want {
+		t.Errorf("after ADD COLUMN: expected %q, got %q", want, got)
+	}
+
+	// and after DROP COLUMN, rows of every version skip its value
+	executeSQL(t, exec, "ALTER TABLE users DROP COLUMN age")
+	executeSQL(t, exec, "UPDATE users SET email = 'bob@example.com' WHERE id = 2")
+	exec.Flush()
+	pager.Close()
+
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM users ORDER BY id", "1 Alice NULL, 2 Bob bob@example.com, 3 Carol carol@example.com"},
+		{"SELECT id FROM users WHERE name = 'Carol'", "3"},
+		{"SELECT COUNT(email) FROM users", "2"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+	if ddl, _ := exec.TableDDL("users"); ddl[0] != "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, email TEXT)" {
+		t.Errorf("unexpected DDL %q", ddl[0])
+	}
+
+	for _, sql := range []string{
+		"ALTER TABLE users ADD COLUMN email TEXT",
+		"ALTER TABLE users ADD COLUMN phone TEXT NOT NULL",
+		"ALTER TABLE users DROP COLUMN age",
+		"ALTER TABLE users DROP COLUMN id",
+		"ALTER TABLE users DROP COLUMN name",
+		"ALTER TABLE missing ADD COLUMN x INTEGER",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+
+	// An ALTER inside a transaction is rolled back with it
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "ALTER TABLE users ADD COLUMN phone TEXT")
+	executeSQL(t, exec, "INSERT INTO users VALUES (4, 'Dave', 'dave@example.com', '555-0100')")
+	executeSQL(t, exec, "ROLLBACK")
+	if got, want := resultText(executeSQL(t, exec, "SELECT * FROM users WHERE id > 2")), "3 Carol carol@example.com"; got != want {
+		t.Errorf("after ROLLBACK: expected %q, got %q", want, got)
+	}
+
+	// VACUUM rewrites every row in the current version
+	executeSQL(t, exec, "VACUUM")
+	if got, want := resultText(executeSQL(t, exec, "SELECT * FROM users ORDER BY id")),
+		"1 Alice NULL, 2 Bob bob@example.com, 3 Carol carol@example.com"; got != want {
+		t.Errorf("after VACUUM: expected %q, got %q", want, got)
+	}
+}
+
+func TestSelectExpressions(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL, qty INTEGER)")
+	executeSQL(t, exec, "INSERT INTO items VALUES (1, 'pen', 1.5, 4)")
+	executeSQL(t, exec, "INSERT INTO items VALUES (2, 'book', 12.0, 1)")
+
+	result := executeSQL(t, exec, "SELECT id, qty + 1, price * qty AS total, UPPER(name) label FROM items ORDER BY id")
+
+	expectedColumns := []string{"id", "qty + 1", "total", "label"}
+	if len(result.Columns) != len(expectedColumns) {
+		t.Fatalf("expected columns %v, got %v", expectedColumns, result.Columns)
+	}
+	for i, name := range expectedColumns {
+		if result.Columns[i] != name {
+			t.Errorf("column %d: expected %q, got %q", i, name, result.Columns[i])
+		}
+	}
+
+	if len(result.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(result.Rows))
+	}
+	first := result.Rows[0]
+	if first[1].Integer != 5 || first[2].Real != 6.0 || first[3].Text != "PEN" {
+		t.Errorf("unexpected first row: %v", first)
+	}
+
+	// Column aliases and star can be mixed with expressions
+	result = executeSQL(t, exec, "SELECT *, id * 10 AS tens FROM items WHERE id = 2")
+	if len(result.Columns) != 5 || result.Columns[4] != "tens" || result.Rows[0][4].Integer != 20 {
+		t.Errorf("unexpected result: %v %v", result.Columns, result.Rows)
+	}
+
+	// Division checks the divisor of its own type for zero
+	for sql, want := range map[string]string{
+		"SELECT 10 / 2": "5",
+		"SELECT price / 2 FROM items WHERE id = 1": "0.75",
+		"SELECT qty / 0.5 FROM items WHERE id = 2": "2",
+		"SELECT 1 / CAST('0.50' AS DECIMAL)":       "2.00000000",
+	} {
+		if got := resultText(executeSQL(t, exec, sql)); got != want {
+			t.Errorf("%s: expected %s, got %s", sql, want, got)
+		}
+	}
+
+	// Errors while evaluating a projection are reported
+	for _, sql := range []string{
+		"SELECT qty / 0 FROM items",
+		"SELECT price / 0.0 FROM items",
+		"SELECT 1 / CAST('0.00' AS DECIMAL)",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected division by zero error", sql)
+		}
+	}
+}
+
+func TestSelectWithoutFrom(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	result := executeSQL(t, exec, "SELECT 1 + 1, UPPER('hi') AS greeting, NOW()")
+	if len(result.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(result.Rows))
+	}
+	if result.Columns[0] != "1 + 1" || result.Columns[1] != "greeting" || result.Columns[2] != "NOW()" {
+		t.Errorf("unexpected columns: %v", result.Columns)
+	}
+	row := result.Rows[0]
+	if row[0].Integer != 2 || row[1].Text != "HI" || row[2].Type != parser.TypeTimestamp {
+		t.Errorf("unexpected row: %v", row)
+	}
+
+	// WHERE and LIMIT can still remove the single row
+	if result := executeSQL(t, exec, "SELECT 1 WHERE 1 = 2"); len(result.Rows) != 0 {
+		t.Errorf("expected no rows, got %v", result.Rows)
+	}
+	if result := executeSQL(t, exec, "SELECT 1 LIMIT 0"); len(result.Rows) != 0 {
+		t.Errorf("expected no rows, got %v", result.Rows)
+	}
+
+	for _, sql := range []string{"SELECT *", "SELECT id", "SELECT 1 / 0"} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
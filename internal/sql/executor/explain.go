@@ -0,0 +1,273 @@
+// Package executor - EXPLAIN ANALYZE
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Plain EXPLAIN shows what the planner *expects* to happen. EXPLAIN ANALYZE
+// runs the statement and reports what *did* happen, step by step:
+//
+//   EXPLAIN ANALYZE SELECT name FROM users WHERE age > 30 ORDER BY name LIMIT 5
+//
+//   -> Project (name)                       rows=5    time=0.004 ms  pages=0
+//     -> Limit (LIMIT 5)                    rows=5    time=0.001 ms  pages=0
+//       -> Sort (name, top 5)               rows=5    time=0.210 ms  pages=0
+//         -> Table Scan (users filter ...)  rows=412  time=1.930 ms  pages=25
+//
+// Reading from the bottom up: the scan produced 412 rows by touching 25
+// pages, the sort picked the first 5 in order, the limit kept them. Comparing the actual row
+// counts with the planner's estimates is how you find bad estimates, and
+// the time and page columns show which step to optimize.
+//
+// Each step's time and pages are its own, not including the steps below it.
+// The statement really executes, so EXPLAIN ANALYZE on an INSERT, UPDATE
+// or DELETE changes data.
+
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// PlanNode is one step (operator) of a plan. A node's input comes from
+// its children.
+type PlanNode struct {
+	Operator string         `json:"operator"`
+	Detail   string         `json:"detail,omitempty"`
+	Estimate *PlanEstimate  `json:"estimate,omitempty"` // planner's guess; nil if not planned
+	Actual   *OperatorStats `json:"actual,omitempty"`   // measured values; nil if the step wasn't run
+	Children []*PlanNode    `json:"children,omitempty"`
+}
+
+// PlanEstimate is what the planner expects a step to produce.
+type PlanEstimate struct {
+	Rows float64 `json:"rows"`
+	Cost float64 `json:"cost"` // cumulative: includes the steps below
+}
+
+// OperatorStats are the measurements of one executed step.
+type OperatorStats struct {
+	Rows      int           // rows produced
+	Time      time.Duration // time spent in this step
+	PagesRead uint64        // page requests made by this step
+}
+
+// MarshalJSON reports the time in milliseconds, like the text output.
+func (s OperatorStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Rows      int     `json:"rows"`
+		TimeMs    float64 `json:"time_ms"`
+		PagesRead uint64  `json:"pages_read"`
+	}{s.Rows, float64(s.Time.Microseconds()) / 1000, s.PagesRead})
+}
+
+// executionTrace records steps in the order they finish.
+type executionTrace struct {
+	steps []*PlanNode
+	last  *tracedIterator // the operator traced last, the input of the next
+}
+
+// stepStart remembers the clock and page counter when a step began.
+type stepStart struct {
+	time  time.Time
+	pages uint64
+}
+
+// startStep marks the beginning of an executor step. It is cheap enough
+// to call unconditionally; nothing is recorded unless a trace is active.
+func (e *Executor) startStep() stepStart {
+	if e.trace == nil {
+		return stepStart{}
+	}
+	return stepStart{time: time.Now(), pages: e.pager.IOStats().PageRequests}
+}
+
+// endStep records a finished step in the active trace, if any.
+func (e *Executor) endStep(start stepStart, operator, detail string, rows int) {
+	if e.trace == nil {
+		return
+	}
+	e.trace.last = nil
+	e.trace.steps = append(e.trace.steps, &PlanNode{
+		Operator: operator,
+		Detail:   detail,
+		Actual: &OperatorStats{
+			Rows:      rows,
+			Time:      time.Since(start.time),
+			PagesRead: e.pager.IOStats().PageRequests - start.pages,
+		},
+	})
+}
+
+// root turns the recorded steps into a tree. Steps run as a pipeline, so
+// each one's input is the step recorded before it.
+func (t *executionTrace) root() *PlanNode {
+	var root *PlanNode
+	for _, step := range t.steps {
+		if root != nil {
+			step.Children = []*PlanNode{root}
+		}
+		root = step
+	}
+	return root
+}
+
+// ExplainAnalyze executes stmt and returns its plan annotated with actual
+// row counts, timings and page reads. The estimated plan rows of plain
+// EXPLAIN come first.
+func (e *Executor) ExplainAnalyze(stmt parser.Statement) (*Result, error) {
+	leave, err := e.enter(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	var rows [][]table.Value
+	switch stmt.(type) {
+	case *parser.SelectStatement, *parser.InsertStatement,
+		*parser.UpdateStatement, *parser.DeleteStatement:
+		estimated, err := e.Explain(stmt)
+		if err != nil {
+			return nil, err
+		}
+		rows = estimated.Rows
+	}
+
+	run, err := e.traceExecution(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	if run.root != nil {
+		rows = append(rows, textRow("Actual Plan", ""))
+		rows = appendPlanRows(rows, run.root, 1)
+	}
+
+	rows = append(rows,
+		textRow(run.rowsLabel(), fmt.Sprintf("%d", run.rows)),
+		textRow("Execution Time", formatDuration(run.elapsed)),
+		textRow("Pages Read", fmt.Sprintf("%d (%d from disk)", run.pagesRead, run.diskReads)),
+	)
+
+	return &Result{
+		Columns: []string{"Property", "Value"},
+		Rows:    rows,
+	}, nil
+}
+
+// tracedRun is the outcome of executing a statement under a trace.
+type tracedRun struct {
+	root      *PlanNode // executed steps; nil if none were recorded
+	isQuery   bool      // rows were returned rather than affected
+	rows      int
+	elapsed   time.Duration
+	pagesRead uint64
+	diskReads uint64
+}
+
+// rowsLabel names the row count for output.
+func (r *tracedRun) rowsLabel() string {
+	if r.isQuery {
+		return "Rows Returned"
+	}
+	return "Rows Affected"
+}
+
+// traceExecution executes stmt, recording each step it runs.
+func (e *Executor) traceExecution(stmt parser.Statement) (*tracedRun, error) {
+	e.trace = &executionTrace{}
+	defer func() { e.trace = nil }()
+
+	before := e.pager.IOStats()
+	start := time.Now()
+	result, err := e.Execute(stmt)
+	elapsed := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+	after := e.pager.IOStats()
+
+	run := &tracedRun{
+		root:      e.trace.root(),
+		rows:      result.RowCount,
+		elapsed:   elapsed,
+		pagesRead: after.PageRequests - before.PageRequests,
+		diskReads: after.DiskReads - before.DiskReads,
+	}
+	if _, ok := stmt.(*parser.SelectStatement); ok {
+		run.isQuery = true
+		run.rows = len(result.Rows)
+	}
+	return run, nil
+}
+
+// appendPlanRows renders a node and its children, one row per node,
+// indented by depth.
+func appendPlanRows(rows [][]table.Value, node *PlanNode, depth int) [][]table.Value {
+	label := strings.Repeat("  ", depth-1) + "-> " + node.Operator
+	if node.Detail != "" {
+		label += " (" + node.Detail + ")"
+	}
+
+	value := ""
+	if node.Actual != nil {
+		value = fmt.Sprintf("rows=%d time=%s pages=%d",
+			node.Actual.Rows, formatDuration(node.Actual.Time), node.Actual.PagesRead)
+	}
+	rows = append(rows, textRow(label, value))
+
+	for _, child := range node.Children {
+		rows = appendPlanRows(rows, child, depth+1)
+	}
+	return rows
+}
+
+// textRow builds a two-column Property/Value row.
+func textRow(property, value string) []table.Value {
+	return []table.Value{
+		{Type: parser.TypeText, Text: property},
+		{Type: parser.TypeText, Text: value},
+	}
+}
+
+// formatDuration renders a duration in milliseconds, the unit people
+// expect to read query times in.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f ms", float64(d.Microseconds())/1000)
+}
+
+// whereDetail describes a step that reads tableName, filtered by where.
+func whereDetail(tableName string, where parser.Expression) string {
+	if where == nil {
+		return tableName
+	}
+	return tableName + " filter " + expressionName(where)
+}
+
+// orderByString renders an ORDER BY list for plan output.
+func orderByString(orderBy []parser.OrderByClause) string {
+	parts := make([]string, len(orderBy))
+	for i, clause := range orderBy {
+		parts[i] = clause.Column
+		if clause.Descending {
+			parts[i] += " DESC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// limitString renders LIMIT/OFFSET for plan output.
+func limitString(limit, offset *int) string {
+	var parts []string
+	if limit != nil {
+		parts = append(parts, fmt.Sprintf("LIMIT %d", *limit))
+	}
+	if offset != nil {
+		parts = append(parts, fmt.Sprintf("OFFSET %d", *offset))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,312 @@
+// Package executor - EXPLAIN output formats
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The default EXPLAIN output is a two-column Property/Value table, which is
+// easy to read but awkward for a program to consume. Like PostgreSQL, we
+// can render the same plan in other formats:
+//
+//   EXPLAIN (FORMAT TREE) SELECT name FROM users WHERE age > 30 ORDER BY name
+//
+//   -> Project (name)  est. rows=5 cost=20.00
+//     -> Sort (name)  est. rows=5 cost=20.00
+//       -> Table Scan (users filter age > 30)  est. rows=5 cost=20.00
+//
+//   EXPLAIN (FORMAT JSON) SELECT ...
+//
+//   {"plan":{"operator":"Project","detail":"name",
+//            "estimate":{"rows":5,"cost":20},"children":[...]}}
+//
+// Both are built from the same tree of PlanNodes. Without ANALYZE the tree
+// comes from the planner; with ANALYZE it is the tree of steps that
+// actually ran, with the planner's estimates attached where a step
+// matches one the planner predicted. Putting estimates and actual rows side
+// by side is the quickest way to spot a misestimate.
+//
+// The tree is a pipeline read from the bottom up: rows flow from the scan
+// at the leaf to the root. The cost shown is cumulative; so far the planner
+// only costs the access method, and later steps pass their input's cost on.
+
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/sql/planner"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// explainFormatted renders EXPLAIN [ANALYZE] in the TREE or JSON format.
+func (e *Executor) explainFormatted(stmt *parser.ExplainStatement) (*Result, error) {
+	plan, err := e.PlanTree(stmt.Statement)
+	if err != nil {
+		return nil, err
+	}
+
+	var run *tracedRun
+	if stmt.Analyze {
+		if run, err = e.traceExecution(stmt.Statement); err != nil {
+			return nil, err
+		}
+		if run.root != nil {
+			annotateEstimates(run.root, plan)
+			plan = run.root
+		}
+	}
+
+	if stmt.Format == parser.ExplainJSON {
+		return planJSON(plan, run)
+	}
+	return planTreeResult(plan, run), nil
+}
+
+// PlanTree returns the planner's estimated plan for a statement as a tree,
+// without executing it.
+func (e *Executor) PlanTree(stmt parser.Statement) (*PlanNode, error) {
+	leave, err := e.enterAccess(false)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	switch s := stmt.(type) {
+	case *parser.SelectStatement:
+		return e.selectPlanTree(s)
+	case *parser.InsertStatement:
+		tbl, err := e.explainTarget(s.Table)
+		if err != nil {
+			return nil, err
+		}
+		return &PlanNode{Operator: "Insert", Detail: tbl.Name, Estimate: &PlanEstimate{Rows: 1}}, nil
+	case *parser.UpdateStatement:
+		tbl, err := e.explainTarget(s.Table)
+		if err != nil {
+			return nil, err
+		}
+		scan := accessNode(e.planner.PlanUpdate(s, tbl.Schema, indexInfos(tbl)...), tbl.Name, s.Where)
+		return pipe(scan, "Update", whereDetail(tbl.Name, s.Where), scan.Estimate.Rows), nil
+	case *parser.DeleteStatement:
+		tbl, err := e.explainTarget(s.Table)
+		if err != nil {
+			return nil, err
+		}
+		scan := accessNode(e.planner.PlanDelete(s, tbl.Schema, indexInfos(tbl)...), tbl.Name, s.Where)
+		return pipe(scan, "Delete", whereDetail(tbl.Name, s.Where), scan.Estimate.Rows), nil
+	default:
+		return nil, fmt.Errorf("EXPLAIN not supported for statement type: %T", stmt)
+	}
+}
+
+// selectPlanTree builds the estimated pipeline for a SELECT, mirroring the
+// steps executeSelect runs: access, then aggregation, sort, limit, locking
+// and projection.
+func (e *Executor) selectPlanTree(stmt *parser.SelectStatement) (*PlanNode, error) {
+	if stmt.From == "" {
+		return &PlanNode{Operator: "Result", Estimate: &PlanEstimate{Rows: 1}}, nil
+	}
+	if stmt.FromFunc != nil {
+		return e.functionScanPlan(stmt)
+	}
+	if len(stmt.Joins) > 0 {
+		return e.joinPlanTree(stmt)
+	}
+
+	tbl, err := e.explainTarget(stmt.From)
+	if err != nil {
+		return nil, err
+	}
+	projection, agg, err := selectProjection(stmt, tbl.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	if pt, ok := e.partitions[strings.ToLower(stmt.From)]; ok {
+		return selectPlanTail(stmt, e.partitionPlanNode(stmt, pt, agg), projection, agg, pt.name, false), nil
+	}
+
+	// Row estimates need statistics; without ANALYZE they are zero
+	plan := e.selectPlan(stmt, tbl)
+	access := accessNode(plan, tbl.Name, stmt.Where)
+	execPlan := NewPlannerWithParams(e.params).Plan(stmt, tbl)
+	if agg != nil && agg.chooseAggregate(tbl, execPlan) {
+		access = aggregateAccessNode(tbl)
+	}
+	ordered, orderScan, orderIndex := indexOrder(stmt, tbl, execPlan, agg)
+	if orderScan {
+		access = orderedScanNode(tbl, orderIndex)
+	}
+	if tbl.IsColumnar() && access.Operator == "Table Scan" {
+		access.Operator, access.Detail = "Columnar Scan", columnarScanDetail(stmt, tbl, tbl.Name)
+	}
+
+	return selectPlanTail(stmt, access, projection, agg, tbl.Name, ordered), nil
+}
+
+// selectPlanTail adds the steps after reading a SELECT's rows from node:
+// aggregation, sort (unless ordered says node's rows need none), limit,
+// locking the rows of tableName and projection.
+func selectPlanTail(stmt *parser.SelectStatement, node *PlanNode, projection []projectedColumn,
+	agg *aggregatePlan, tableName string, ordered bool) *PlanNode {
+	rows := node.Estimate.Rows
+	if agg != nil {
+		node = aggregateNode(node, agg)
+		rows = agg.groups
+	}
+	if len(stmt.OrderBy) > 0 && !ordered {
+		node = pipe(node, "Sort", orderByString(stmt.OrderBy), rows)
+	}
+	if stmt.Limit != nil || stmt.Offset != nil {
+		if stmt.Offset != nil {
+			rows = max(rows-float64(*stmt.Offset), 0)
+		}
+		if stmt.Limit != nil {
+			rows = min(rows, float64(*stmt.Limit))
+		}
+		node = pipe(node, "Limit", limitString(stmt.Limit, stmt.Offset), rows)
+	}
+	if stmt.ForUpdate {
+		node = pipe(node, "Lock Rows", tableName, rows)
+	}
+
+	names := make([]string, len(projection))
+	for i, col := range projection {
+		names[i] = col.name
+	}
+	return pipe(node, "Project", strings.Join(names, ", "), rows)
+}
+
+// accessNode is the leaf of a plan: how the table's rows are found.
+func accessNode(plan *planner.QueryPlan, tableName string, where parser.Expression) *PlanNode {
+	node := &PlanNode{
+		Estimate: &PlanEstimate{Rows: plan.EstimatedRows, Cost: plan.EstimatedCost},
+	}
+	using := tableName + " using primary key"
+	if plan.IndexName != "" {
+		using = tableName + " using index " + plan.IndexName
+	}
+	switch plan.AccessMethod {
+	case planner.IndexLookup:
+		node.Operator, node.Detail = "Index Lookup", using
+	case planner.IndexRangeScan:
+		node.Operator, node.Detail = "Index Range Scan", using
+	case planner.OrderedIndexScan:
+		node.Operator, node.Detail = "Ordered Index Scan", using
+	default:
+		node.Operator, node.Detail = "Table Scan", whereDetail(tableName, where)
+	}
+	return node
+}
+
+// pipe adds a step that consumes input's rows.
+func pipe(input *PlanNode, operator, detail string, rows float64) *PlanNode {
+	return &PlanNode{
+		Operator: operator,
+		Detail:   detail,
+		Estimate: &PlanEstimate{Rows: rows, Cost: input.Estimate.Cost},
+		Children: []*PlanNode{input},
+	}
+}
+
+// annotateEstimates copies estimates from the planned tree onto the
+// executed steps with the same operator.
+func annotateEstimates(actual, estimated *PlanNode) {
+	byOperator := make(map[string]*PlanEstimate)
+	for node := estimated; node != nil; node = firstChild(node) {
+		if _, seen := byOperator[node.Operator]; !seen {
+			byOperator[node.Operator] = node.Estimate
+		}
+	}
+	for node := actual; node != nil; node = firstChild(node) {
+		node.Estimate = byOperator[node.Operator]
+	}
+}
+
+// firstChild returns a node's input, or nil at a leaf.
+func firstChild(node *PlanNode) *PlanNode {
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node.Children[0]
+}
+
+// planTreeResult renders a plan as one indented line per node, followed by
+// totals when the statement was executed.
+func planTreeResult(root *PlanNode, run *tracedRun) *Result {
+	var lines []string
+	var walk func(node *PlanNode, depth int)
+	walk = func(node *PlanNode, depth int) {
+		line := strings.Repeat("  ", depth) + "-> " + node.Operator
+		if node.Detail != "" {
+			line += " (" + node.Detail + ")"
+		}
+		if node.Estimate != nil {
+			line += fmt.Sprintf("  est. rows=%.0f cost=%.2f", node.Estimate.Rows, node.Estimate.Cost)
+		}
+		if node.Actual != nil {
+			line += fmt.Sprintf("  actual rows=%d time=%s pages=%d",
+				node.Actual.Rows, formatDuration(node.Actual.Time), node.Actual.PagesRead)
+		}
+		lines = append(lines, line)
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	walk(root, 0)
+
+	if run != nil {
+		lines = append(lines,
+			fmt.Sprintf("%s: %d", run.rowsLabel(), run.rows),
+			fmt.Sprintf("Execution Time: %s", formatDuration(run.elapsed)),
+			fmt.Sprintf("Pages Read: %d (%d from disk)", run.pagesRead, run.diskReads),
+		)
+	}
+
+	result := &Result{Columns: []string{"QUERY PLAN"}}
+	for _, line := range lines {
+		result.Rows = append(result.Rows, []table.Value{{Type: parser.TypeText, Text: line}})
+	}
+	return result
+}
+
+// explainDocument is the JSON form of EXPLAIN output. The totals are only
+// present with ANALYZE.
+type explainDocument struct {
+	Plan            *PlanNode `json:"plan"`
+	RowsReturned    *int      `json:"rows_returned,omitempty"`
+	RowsAffected    *int      `json:"rows_affected,omitempty"`
+	ExecutionTimeMs *float64  `json:"execution_time_ms,omitempty"`
+	PagesRead       *uint64   `json:"pages_read,omitempty"`
+	DiskReads       *uint64   `json:"disk_reads,omitempty"`
+}
+
+// planJSON renders a plan as a single JSON value in a single row.
+func planJSON(root *PlanNode, run *tracedRun) (*Result, error) {
+	doc := explainDocument{Plan: root}
+	if run != nil {
+		if run.isQuery {
+			doc.RowsReturned = &run.rows
+		} else {
+			doc.RowsAffected = &run.rows
+		}
+		ms := float64(run.elapsed.Microseconds()) / 1000
+		doc.ExecutionTimeMs = &ms
+		doc.PagesRead = &run.pagesRead
+		doc.DiskReads = &run.diskReads
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encoding plan: %w", err)
+	}
+	value, err := table.JSONValue(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Columns: []string{"QUERY PLAN"},
+		Rows:    [][]table.Value{{value}},
+	}, nil
+}
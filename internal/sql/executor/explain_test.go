@@ -0,0 +1,373 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+// planRow returns the Value column of the first EXPLAIN row whose
+// Property contains the given text.
+func planRow(t *testing.T, result *Result, property string) string {
+	t.Helper()
+	for _, row := range result.Rows {
+		if strings.Contains(row[0].Text, property) {
+			return row[1].Text
+		}
+	}
+	t.Fatalf("no %q row in plan:\n%s", property, result)
+	return ""
+}
+
+func TestExplainDML(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice', 30)")
+
+	result := executeSQL(t, exec, "EXPLAIN INSERT INTO users VALUES (2, 'Bob', 25)")
+	if got := planRow(t, result, "Operation"); got != "INSERT" {
+		t.Errorf("expected INSERT operation, got %q", got)
+	}
+	if got := planRow(t, result, "Indexes Updated"); got != "PRIMARY KEY (id)" {
+		t.Errorf("expected primary key index to be updated, got %q", got)
+	}
+
+	result = executeSQL(t, exec, "EXPLAIN UPDATE users SET age = age + 1 WHERE id = 1")
+	if got := planRow(t, result, "Access Method"); got != "INDEX_LOOKUP" {
+		t.Errorf("UPDATE by primary key: expected INDEX_LOOKUP, got %q", got)
+	}
+	if got := planRow(t, result, "Assignments"); got != "age = age + 1" {
+		t.Errorf("expected assignments in plan, got %q", got)
+	}
+
+	result = executeSQL(t, exec, "EXPLAIN DELETE FROM users WHERE age > 20")
+	if got := planRow(t, result, "Access Method"); got != "FULL_TABLE_SCAN" {
+		t.Errorf("DELETE by non-key column: expected FULL_TABLE_SCAN, got %q", got)
+	}
+
+	// None of the explained statements ran
+	rows := executeSQL(t, exec, "SELECT age FROM users").Rows
+	if len(rows) != 1 || rows[0][0].Integer != 30 {
+		t.Errorf("EXPLAIN modified data: %v", rows)
+	}
+}
+
+func TestExplainAnalyzeSelect(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	for i := 1; i <= 20; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d', %d)", i, i, 20+i))
+	}
+
+	result := executeSQL(t, exec, "EXPLAIN ANALYZE SELECT name FROM users WHERE age > 30 ORDER BY name LIMIT 3")
+
+	// The estimated plan from plain EXPLAIN comes first
+	if planRow(t, result, "Access Method") != "FULL_TABLE_SCAN" {
+		t.Errorf("expected estimated access method in output:\n%s", result)
+	}
+
+	expected := map[string]string{
+		"-> Project":    "rows=3 ",
+		"-> Limit":      "rows=3 ",
+		"-> Sort":       "rows=3 ", // top-K keeps only the rows the LIMIT needs
+		"-> Table Scan": "rows=10 ",
+	}
+	for operator, rows := range expected {
+		if got := planRow(t, result, operator); !strings.HasPrefix(got, rows) {
+			t.Errorf("%s: expected %s..., got %q", operator, rows, got)
+		}
+	}
+
+	// Operators are nested: the scan is deepest
+	var scanLabel string
+	for _, row := range result.Rows {
+		if strings.Contains(row[0].Text, "Table Scan") {
+			scanLabel = row[0].Text
+		}
+	}
+	if !strings.HasPrefix(scanLabel, "      -> Table Scan (users filter age > 30)") {
+		t.Errorf("unexpected scan label %q", scanLabel)
+	}
+
+	if planRow(t, result, "Rows Returned") != "3" {
+		t.Errorf("expected 3 rows returned:\n%s", result)
+	}
+	if !strings.HasSuffix(planRow(t, result, "Execution Time"), " ms") {
+		t.Errorf("expected execution time in ms:\n%s", result)
+	}
+	if strings.HasPrefix(planRow(t, result, "Pages Read"), "0 ") {
+		t.Errorf("expected the scan to read pages:\n%s", result)
+	}
+}
+
+func TestExplainAnalyzeIndexLookup(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+
+	result := executeSQL(t, exec, "EXPLAIN ANALYZE SELECT * FROM users WHERE id = 1")
+	if got := planRow(t, result, "-> Index Lookup"); !strings.HasPrefix(got, "rows=1 ") {
+		t.Errorf("expected index lookup with 1 row, got %q", got)
+	}
+}
+
+func TestExplainAnalyzeIndexRangeScan(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	for i := 1; i <= 5; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user')", i))
+	}
+
+	result := executeSQL(t, exec, "EXPLAIN ANALYZE SELECT * FROM users WHERE id > 3")
+	if got := planRow(t, result, "-> Index Range Scan"); !strings.HasPrefix(got, "rows=2 ") {
+		t.Errorf("expected index range scan with 2 rows, got %q", got)
+	}
+}
+
+func TestExplainSecondaryIndex(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER)")
+	for i := 1; i <= 5; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, %d)", i, 20+i))
+	}
+	executeSQL(t, exec, "CREATE INDEX idx_users_age ON users (age)")
+
+	result := executeSQL(t, exec, "EXPLAIN ANALYZE SELECT * FROM users WHERE age >= 24")
+	if got := planRow(t, result, "-> Index Range Scan"); !strings.HasPrefix(got, "rows=2 ") {
+		t.Errorf("expected index range scan with 2 rows, got %q", got)
+	}
+	if !strings.Contains(result.String(), "users using index idx_users_age") {
+		t.Errorf("expected the scan to name the index:\n%s", result)
+	}
+
+	result = executeSQL(t, exec, "EXPLAIN SELECT * FROM users WHERE age = 22")
+	if got := planRow(t, result, "Access Method"); got != "INDEX_LOOKUP" {
+		t.Errorf("expected INDEX_LOOKUP, got %q", got)
+	}
+}
+
+func TestExplainAnalyzeExecutesStatement(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+
+	result := executeSQL(t, exec, "EXPLAIN ANALYZE INSERT INTO users VALUES (1, 'Alice')")
+	if planRow(t, result, "Rows Affected") != "1" {
+		t.Errorf("expected 1 row affected:\n%s", result)
+	}
+	if planRow(t, result, "Operation") != "INSERT" {
+		t.Errorf("expected estimated plan in output:\n%s", result)
+	}
+	if !strings.HasPrefix(planRow(t, result, "-> Insert"), "rows=1 ") {
+		t.Errorf("expected an Insert step:\n%s", result)
+	}
+
+	// Unlike plain EXPLAIN, the insert really happened
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 1 {
+		t.Errorf("expected 1 row after EXPLAIN ANALYZE INSERT, got %d", len(rows))
+	}
+}
+
+func TestParseExplainAnalyze(t *testing.T) {
+	stmt := parseSQL(t, "EXPLAIN ANALYZE SELECT * FROM users")
+	if explain := stmt.(*parser.ExplainStatement); !explain.Analyze {
+		t.Error("expected Analyze to be set")
+	}
+
+	// EXPLAIN of the ANALYZE command itself is still plain EXPLAIN
+	stmt = parseSQL(t, "EXPLAIN ANALYZE users")
+	explain := stmt.(*parser.ExplainStatement)
+	if _, ok := explain.Statement.(*parser.AnalyzeStatement); !ok || explain.Analyze {
+		t.Errorf("expected EXPLAIN of ANALYZE statement, got %v", explain)
+	}
+}
+
+func TestExplainFormatTree(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	for i := 1; i <= 5; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d', %d)", i, i, 20+i))
+	}
+
+	result := executeSQL(t, exec, "EXPLAIN (FORMAT TREE) SELECT name FROM users WHERE age > 22 ORDER BY name LIMIT 2")
+	if len(result.Columns) != 1 || result.Columns[0] != "QUERY PLAN" {
+		t.Fatalf("expected a single QUERY PLAN column, got %v", result.Columns)
+	}
+
+	expected := []string{
+		"-> Project (name)",
+		"  -> Limit (LIMIT 2)",
+		"    -> Sort (name)",
+		"      -> Table Scan (users filter age > 22)",
+	}
+	if len(result.Rows) != len(expected) {
+		t.Fatalf("expected %d lines, got:\n%s", len(expected), result)
+	}
+	for i, prefix := range expected {
+		if line := result.Rows[i][0].Text; !strings.HasPrefix(line, prefix+"  est. rows=") {
+			t.Errorf("line %d: expected %q..., got %q", i, prefix, line)
+		}
+	}
+
+	result = executeSQL(t, exec, "EXPLAIN (ANALYZE, FORMAT TREE) SELECT name FROM users WHERE age > 22")
+	if line := result.Rows[1][0].Text; !strings.Contains(line, "actual rows=3 ") {
+		t.Errorf("expected actual row count on scan, got %q", line)
+	}
+	if last := result.Rows[len(result.Rows)-1][0].Text; !strings.HasPrefix(last, "Pages Read: ") {
+		t.Errorf("expected totals after the tree, got %q", last)
+	}
+}
+
+func TestExplainFormatJSON(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+	executeSQL(t, exec, "ANALYZE users")
+
+	result := executeSQL(t, exec, "EXPLAIN (FORMAT JSON) SELECT * FROM users WHERE id = 1")
+	if len(result.Rows) != 1 || result.Rows[0][0].Type != parser.TypeJSON {
+		t.Fatalf("expected a single JSON value, got %v", result.Rows)
+	}
+
+	var doc struct {
+		Plan PlanNode `json:"plan"`
+	}
+	if err := json.Unmarshal([]byte(result.Rows[0][0].Text), &doc); err != nil {
+		t.Fatalf("invalid JSON plan: %v", err)
+	}
+	if doc.Plan.Operator != "Project" || len(doc.Plan.Children) != 1 {
+		t.Fatalf("expected Project at the root, got %+v", doc.Plan)
+	}
+	scan := doc.Plan.Children[0]
+	if scan.Operator != "Index Lookup" || scan.Estimate == nil || scan.Estimate.Rows != 1 {
+		t.Errorf("expected an index lookup estimated at 1 row, got %+v", scan)
+	}
+
+	// With ANALYZE the document includes measurements, and DML runs
+	result = executeSQL(t, exec, "EXPLAIN (ANALYZE, FORMAT JSON) DELETE FROM users WHERE id = 1")
+	text := result.Rows[0][0].Text
+	for _, field := range []string{`"actual":{"rows":`, `"rows_affected":1`, `"execution_time_ms":`} {
+		if !strings.Contains(text, field) {
+			t.Errorf("expected %s in %s", field, text)
+		}
+	}
+}
+
+func TestOptimizerHints(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_users_age ON users (age)")
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER)")
+	for i := 1; i <= 20; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d', %d)", i, i, 20+i))
+	}
+
+	tests := []struct {
+		sql    string
+		access string
+		want   string
+	}{
+		// The primary key lookup would win without the hint
+		{"SELECT /*+ FULL_SCAN */ name FROM users WHERE id = 3", "Table Scan", "user3"},
+		{"SELECT /*+ INDEX(idx_users_age) */ name FROM users WHERE id = 3 AND age > 21", "using index idx_users_age", "user3"},
+		// ... and the ordered read of the primary key
+		{"SELECT /*+ FULL_SCAN */ id FROM users WHERE age < 23 ORDER BY id", "Table Scan", "1, 2"},
+		// A hint that can't be followed leaves the planner to choose
+		{"SELECT /*+ INDEX(idx_users_age) */ name FROM users WHERE id = 3", "Index Lookup (users using primary key)", "user3"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		for _, explain := range []string{"EXPLAIN (FORMAT TREE) ", "EXPLAIN (ANALYZE, FORMAT TREE) "} {
+			if plan := resultText(executeSQL(t, exec, explain+tt.sql)); !strings.Contains(plan, tt.access) {
+				t.Errorf("%s%s: expected %q, got %s", explain, tt.sql, tt.access, plan)
+			}
+		}
+	}
+
+	// After ANALYZE, the hint still beats the costs
+	executeSQL(t, exec, "ANALYZE users")
+	sql := "SELECT /*+ INDEX(idx_users_age) */ name FROM users WHERE age > 21"
+	if plan := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) "+sql)); !strings.Contains(plan, "using index idx_users_age") {
+		t.Errorf("expected the hinted index after ANALYZE, got %s", plan)
+	}
+	if plan := resultText(executeSQL(t, exec, "EXPLAIN "+sql)); !strings.Contains(plan, "Hint INDEX(idx_users_age)") {
+		t.Errorf("expected EXPLAIN to show the hint, got %s", plan)
+	}
+
+	for _, sql := range []string{
+		"SELECT /*+ INDEX(idx_missing) */ * FROM users",
+		"SELECT /*+ FULL_SCAN */ * FROM users JOIN orders ON users.id = orders.user_id",
+		"SELECT /*+ FULL_SCAN */ 1",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestExplainAnalyzeParallelScan(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE events (id INTEGER PRIMARY KEY, kind INTEGER, note TEXT)")
+	executeSQL(t, exec, "BEGIN")
+	for i := 1; i <= 2000; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO events VALUES (%d, %d, 'event %d, long enough to take up some room')", i, i%10, i))
+	}
+	executeSQL(t, exec, "COMMIT")
+
+	// A full scan of a large table goes through the pool of workers,
+	// returning the rows a sequential scan would
+	result := executeSQL(t, exec, "EXPLAIN ANALYZE SELECT id FROM events WHERE kind = 3")
+	if got := planRow(t, result, "-> Parallel Table Scan"); !strings.HasPrefix(got, "rows=200 ") {
+		t.Errorf("expected a parallel scan finding 200 rows, got %q:\n%s", got, result)
+	}
+	rows := executeSQL(t, exec, "SELECT id FROM events WHERE kind = 3").Rows
+	if len(rows) != 200 || rows[0][0].Integer != 3 || rows[199][0].Integer != 1993 {
+		t.Errorf("expected ids 3, 13, ... 1993 in order, got %d rows", len(rows))
+	}
+	for i, row := range rows {
+		if row[0].Integer != int64(10*i+3) {
+			t.Fatalf("row %d: expected id %d, got %d", i, 10*i+3, row[0].Integer)
+		}
+	}
+
+	// A LIMIT stops it early
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM events LIMIT 2")); got != "1, 2" {
+		t.Errorf("expected the first two rows, got %q", got)
+	}
+
+	// A small table isn't worth the workers
+	executeSQL(t, exec, "CREATE TABLE kinds (id INTEGER PRIMARY KEY)")
+	executeSQL(t, exec, "INSERT INTO kinds VALUES (1)")
+	result = executeSQL(t, exec, "EXPLAIN ANALYZE SELECT id FROM kinds")
+	for _, row := range result.Rows {
+		if strings.Contains(row[0].Text, "Parallel") {
+			t.Errorf("expected a sequential scan of a small table:\n%s", result)
+		}
+	}
+}
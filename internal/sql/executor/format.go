@@ -0,0 +1,229 @@
+// Package executor - Output formats
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A Result is rows of values; how it looks on screen is a separate
+// choice. The REPL's .mode picks one of several formatters, each taking
+// the same Result:
+//
+//   table     the bordered table String draws, for reading
+//   csv       a header line, then one line per row, for spreadsheets
+//   json      one JSON object per row ("JSON lines"), for other programs
+//   vertical  one line per column, for rows too wide for the screen
+//
+// For example, in vertical mode:
+//
+//   *************************** 1. row ***************************
+//     id: 1
+//   name: Alice
+//
+// Keeping formatting out of the executor means a new format is a new
+// Formatter, not a change to every statement. The CSV output reads back
+// with COPY: NULL is written as an empty field, which COPY reads as NULL.
+// JSON keeps the types CSV loses: numbers stay numbers and NULL is null.
+//
+// At a terminal, the REPL draws tables in color: NULL stands out from the
+// text 'NULL', and every other row is shaded so the eye can follow a
+// wide row across the screen. The colors are ANSI escape sequences, which
+// a terminal interprets and a file would keep as junk, so they are only
+// used when the output is a terminal.
+//
+// sqlite3's .mode and psql's \pset format work the same way; MySQL shows
+// a result vertically when a statement ends in \G.
+
+package executor
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// Formatter writes a result in one output format.
+type Formatter interface {
+	Format(w io.Writer, r *Result) error
+}
+
+// Formatters are the output formats by name.
+var Formatters = map[string]Formatter{
+	"table":    TableFormatter{},
+	"csv":      CSVFormatter{},
+	"json":     JSONFormatter{},
+	"vertical": VerticalFormatter{},
+}
+
+// FormatterNames returns the names of the output formats, sorted.
+func FormatterNames() []string {
+	names := make([]string, 0, len(Formatters))
+	for name := range Formatters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TableFormatter draws a result as a table, as String does.
+type TableFormatter struct {
+	Color bool // Highlight NULLs and shade every other row, for a terminal
+}
+
+// Format writes the table, ending with a newline.
+func (f TableFormatter) Format(w io.Writer, r *Result) error {
+	if r.Message != "" {
+		return writeMessage(w, r.Message)
+	}
+	if len(r.Rows) == 0 {
+		return writeMessage(w, "(no rows)")
+	}
+	_, err := r.writeTable(w, f.Color)
+	return err
+}
+
+// ANSI escape sequences for TableFormatter's colors.
+const (
+	ansiReset = "\033[0m"
+	ansiNull  = "\033[2;3m"      // dim italic
+	ansiShade = "\033[48;5;236m" // dark grey background
+)
+
+// colorCell colors a table cell, padding included: a NULL dimmed, and on
+// a shaded row, the background.
+func colorCell(cell string, null, shaded bool) string {
+	style := ""
+	if shaded {
+		style += ansiShade
+	}
+	if null {
+		style += ansiNull
+	}
+	if style == "" {
+		return cell
+	}
+	return style + cell + ansiReset
+}
+
+// CSVFormatter writes a result as CSV with a header line.
+type CSVFormatter struct{}
+
+// Format writes the columns, then the rows. NULL is an empty field.
+func (CSVFormatter) Format(w io.Writer, r *Result) error {
+	if r.Message != "" {
+		return writeMessage(w, r.Message)
+	}
+	out := csv.NewWriter(w)
+	if err := out.Write(r.Columns); err != nil {
+		return err
+	}
+	record := make([]string, len(r.Columns))
+	for _, row := range r.Rows {
+		for i, val := range row {
+			record[i] = ""
+			if !val.IsNull {
+				record[i] = val.String()
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// JSONFormatter writes a result as one JSON object per row.
+type JSONFormatter struct{}
+
+// Format writes each row as an object of its columns, in order.
+func (JSONFormatter) Format(w io.Writer, r *Result) error {
+	if r.Message != "" {
+		return writeMessage(w, r.Message)
+	}
+	out := bufio.NewWriter(w)
+	for _, row := range r.Rows {
+		out.WriteString("{")
+		for i, val := range row {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			key, _ := json.Marshal(r.Columns[i])
+			value, err := json.Marshal(jsonValue(val))
+			if err != nil {
+				return fmt.Errorf("column %s: %w", r.Columns[i], err)
+			}
+			out.Write(key)
+			out.WriteString(":")
+			out.Write(value)
+		}
+		out.WriteString("}\n")
+	}
+	return out.Flush()
+}
+
+// jsonValue converts a value to the JSON value it is written as.
+func jsonValue(v table.Value) any {
+	if v.IsNull {
+		return nil
+	}
+	switch v.Type {
+	case parser.TypeInteger:
+		return v.Integer
+	case parser.TypeReal:
+		return v.Real
+	case parser.TypeBoolean:
+		return v.Boolean
+	case parser.TypeJSON:
+		// The document itself, not a string holding it
+		if json.Valid([]byte(v.Text)) {
+			return json.RawMessage(v.Text)
+		}
+		return v.Text
+	default:
+		// TIMESTAMP and DECIMAL as text, so no precision is lost
+		return v.String()
+	}
+}
+
+// VerticalFormatter writes each column of a row on a line of its own.
+type VerticalFormatter struct{}
+
+// Format writes the rows one after another, each headed by its number.
+func (VerticalFormatter) Format(w io.Writer, r *Result) error {
+	if r.Message != "" {
+		return writeMessage(w, r.Message)
+	}
+	if len(r.Rows) == 0 {
+		return writeMessage(w, "(no rows)")
+	}
+	width := 0
+	for _, col := range r.Columns {
+		width = max(width, utf8.RuneCountInString(col))
+	}
+	out := bufio.NewWriter(w)
+	stars := strings.Repeat("*", 27)
+	for n, row := range r.Rows {
+		fmt.Fprintf(out, "%s %d. row %s\n", stars, n+1, stars)
+		for i, val := range row {
+			fmt.Fprintf(out, "%*s: %s\n", width, r.Columns[i], r.format(val))
+		}
+	}
+	fmt.Fprintf(out, "(%d rows)\n", len(r.Rows))
+	return out.Flush()
+}
+
+// writeMessage writes a statement's message, such as "1 row inserted.",
+// which is the same in every format.
+func writeMessage(w io.Writer, message string) error {
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	_, err := io.WriteString(w, message)
+	return err
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatters(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, score REAL)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice, \"Al\"', 9.5)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, NULL, 7)")
+	result := executeSQL(t, exec, "SELECT id, name, score FROM users ORDER BY id")
+
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"csv", "id,name,score\n1,\"Alice, \"\"Al\"\"\",9.5\n2,,7\n"},
+		{"json", `{"id":1,"name":"Alice, \"Al\"","score":9.5}` + "\n" + `{"id":2,"name":null,"score":7}` + "\n"},
+		{"vertical", "*************************** 1. row ***************************\n" +
+			"   id: 1\n" +
+			" name: Alice, \"Al\"\n" +
+			"score: 9.5\n" +
+			"*************************** 2. row ***************************\n" +
+			"   id: 2\n" +
+			" name: NULL\n" +
+			"score: 7\n" +
+			"(2 rows)\n"},
+		{"table", result.String()},
+	}
+	for _, tt := range tests {
+		var sb strings.Builder
+		if err := Formatters[tt.mode].Format(&sb, result); err != nil {
+			t.Fatalf("%s: Format failed: %v", tt.mode, err)
+		}
+		if sb.String() != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.mode, tt.want, sb.String())
+		}
+	}
+
+	// A message reads the same in every format, but no rows doesn't
+	inserted := executeSQL(t, exec, "INSERT INTO users VALUES (3, 'Carol', 8)")
+	empty := executeSQL(t, exec, "SELECT * FROM users WHERE id > 10")
+	emptyText := map[string]string{
+		"table":    "(no rows)\n",
+		"csv":      "id,name,score\n",
+		"json":     "",
+		"vertical": "(no rows)\n",
+	}
+	for _, name := range FormatterNames() {
+		var sb strings.Builder
+		Formatters[name].Format(&sb, inserted)
+		if sb.String() != inserted.Message+"\n" {
+			t.Errorf("%s: expected the message, got %q", name, sb.String())
+		}
+		sb.Reset()
+		Formatters[name].Format(&sb, empty)
+		if sb.String() != emptyText[name] {
+			t.Errorf("%s: expected %q for no rows, got %q", name, emptyText[name], sb.String())
+		}
+	}
+}
+
+func TestTableFormatterColor(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, NULL)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+	result := executeSQL(t, exec, "SELECT id, name FROM users ORDER BY id")
+
+	var sb strings.Builder
+	if err := (TableFormatter{Color: true}).Format(&sb, result); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	lines := strings.Split(sb.String(), "\n")
+	if want := "| 1  |" + ansiNull + " NULL " + ansiReset + "|"; lines[3] != want {
+		t.Errorf("expected the NULL highlighted, got %q", lines[3])
+	}
+	if want := "|" + ansiShade + " 2  " + ansiReset + "|" + ansiShade + " Bob  " + ansiReset + "|"; lines[4] != want {
+		t.Errorf("expected the second row shaded, got %q", lines[4])
+	}
+
+	// With the colors taken out, it is the plain table
+	plain := sb.String()
+	for _, code := range []string{ansiNull, ansiShade, ansiReset} {
+		plain = strings.ReplaceAll(plain, code, "")
+	}
+	if plain != result.String() {
+		t.Errorf("expected the plain table once the colors are removed, got\n%s", plain)
+	}
+}
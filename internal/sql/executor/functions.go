@@ -0,0 +1,603 @@
+// Package executor - Scalar function evaluation
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A scalar function takes zero or more values from the current row and
+// returns a single value: UPPER(name), ABS(balance), COALESCE(a, b).
+//
+// The NULL-handling functions are special. Most functions evaluate all of
+// their arguments first, but COALESCE stops at the first non-NULL argument.
+// That short-circuiting matters when a later argument would fail (for
+// example a division by zero), so these are evaluated directly on the AST
+// rather than on pre-computed argument values.
+//
+// Every other function lives in a registry: a map from name to a Go
+// function plus its allowed argument count. Adding a function means adding
+// one map entry; the evaluator never changes.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// ScalarFunction describes a function callable from SQL: its allowed
+// argument count and the Go function that computes its result. Built-ins
+// are listed in builtinFunctions; applications add their own with
+// Executor.RegisterFunction.
+type ScalarFunction struct {
+	MinArgs int
+	MaxArgs int // -1 means no upper limit
+
+	// NullOnNullInput makes the function return NULL when any argument
+	// is NULL, without calling Fn. This is the SQL standard behavior for
+	// most functions (UPPER(NULL) is NULL), so Fn never has to check.
+	NullOnNullInput bool
+
+	Fn func(args []table.Value) (table.Value, error)
+}
+
+// builtinFunctions is the registry of scalar functions, keyed by
+// uppercase name.
+var builtinFunctions = map[string]ScalarFunction{
+	"UPPER":   {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnUpper},
+	"LOWER":   {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnLower},
+	"LENGTH":  {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnLength},
+	"SUBSTR":  {MinArgs: 2, MaxArgs: 3, NullOnNullInput: true, Fn: fnSubstr},
+	"TRIM":    {MinArgs: 1, MaxArgs: 2, NullOnNullInput: true, Fn: fnTrim(strings.Trim)},
+	"LTRIM":   {MinArgs: 1, MaxArgs: 2, NullOnNullInput: true, Fn: fnTrim(strings.TrimLeft)},
+	"RTRIM":   {MinArgs: 1, MaxArgs: 2, NullOnNullInput: true, Fn: fnTrim(strings.TrimRight)},
+	"REPLACE": {MinArgs: 3, MaxArgs: 3, NullOnNullInput: true, Fn: fnReplace},
+	"CONCAT":  {MinArgs: 1, MaxArgs: -1, Fn: fnConcat},
+
+	"ABS":     {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnAbs},
+	"ROUND":   {MinArgs: 1, MaxArgs: 2, NullOnNullInput: true, Fn: fnRound},
+	"CEIL":    {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnRealOnly(math.Ceil, true)},
+	"CEILING": {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnRealOnly(math.Ceil, true)},
+	"FLOOR":   {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnRealOnly(math.Floor, false)},
+	"MOD":     {MinArgs: 2, MaxArgs: 2, NullOnNullInput: true, Fn: fnMod},
+	"POWER":   {MinArgs: 2, MaxArgs: 2, NullOnNullInput: true, Fn: fnPower},
+	"POW":     {MinArgs: 2, MaxArgs: 2, NullOnNullInput: true, Fn: fnPower},
+	"RANDOM":  {MinArgs: 0, MaxArgs: 0, Fn: fnRandom},
+
+	"NOW":               {MinArgs: 0, MaxArgs: 0, Fn: fnNow},
+	"CURRENT_TIMESTAMP": {MinArgs: 0, MaxArgs: 0, Fn: fnNow},
+	"DATE":              {MinArgs: 1, MaxArgs: 1, NullOnNullInput: true, Fn: fnDate},
+	"DATE_ADD":          {MinArgs: 3, MaxArgs: 3, NullOnNullInput: true, Fn: fnDateAdd},
+
+	"JSON_EXTRACT": {MinArgs: 2, MaxArgs: 2, NullOnNullInput: true, Fn: fnJSONExtract},
+	"JSON_SET":     {MinArgs: 3, MaxArgs: -1, Fn: fnJSONSet},
+}
+
+// lookupFunction finds a function in the registry.
+func lookupFunction(name string) (ScalarFunction, bool) {
+	fn, ok := builtinFunctions[name]
+	return fn, ok
+}
+
+// evaluateFunctionCall evaluates a function call expression against a row.
+func (e *Executor) evaluateFunctionCall(call *parser.FunctionCall, row table.Row, schema *table.Schema) (table.Value, error) {
+	if aggregateFunctions[call.Name] {
+		// Aggregates are computed over a group by the aggregate operator;
+		// the row it returns holds the result, named by the call
+		if idx, found := schema.GetColumnIndex(call.String()); found {
+			return row.Values[idx], nil
+		}
+		return table.Value{}, fmt.Errorf("aggregate function %s is not allowed here", call.Name)
+	}
+
+	switch call.Name {
+	case "COALESCE":
+		// COALESCE(a, b, ...) returns the first non-NULL argument
+		if len(call.Args) == 0 {
+			return table.Value{}, fmt.Errorf("COALESCE requires at least 1 argument")
+		}
+		for _, arg := range call.Args {
+			val, err := e.evaluateExpression(arg, row, schema)
+			if err != nil {
+				return table.Value{}, err
+			}
+			if !val.IsNull {
+				return val, nil
+			}
+		}
+		return table.Value{IsNull: true}, nil
+
+	case "IFNULL":
+		// IFNULL(a, b) is the two-argument form of COALESCE
+		if len(call.Args) != 2 {
+			return table.Value{}, fmt.Errorf("IFNULL requires 2 arguments, got %d", len(call.Args))
+		}
+		val, err := e.evaluateExpression(call.Args[0], row, schema)
+		if err != nil || !val.IsNull {
+			return val, err
+		}
+		return e.evaluateExpression(call.Args[1], row, schema)
+
+	case "NULLIF":
+		// NULLIF(a, b) returns NULL when a = b, otherwise a.
+		// Handy for turning sentinel values back into NULL: NULLIF(age, 0)
+		if len(call.Args) != 2 {
+			return table.Value{}, fmt.Errorf("NULLIF requires 2 arguments, got %d", len(call.Args))
+		}
+		left, err := e.evaluateExpression(call.Args[0], row, schema)
+		if err != nil {
+			return table.Value{}, err
+		}
+		right, err := e.evaluateExpression(call.Args[1], row, schema)
+		if err != nil {
+			return table.Value{}, err
+		}
+		if !left.IsNull && !right.IsNull && left.Equals(right) {
+			return table.Value{Type: left.Type, IsNull: true}, nil
+		}
+		return left, nil
+
+	case "NEXTVAL":
+		return e.nextval(call, row, schema)
+
+	case "CURRVAL":
+		return e.currval(call, row, schema)
+
+	default:
+		return e.callRegisteredFunction(call, row, schema)
+	}
+}
+
+// callRegisteredFunction evaluates the arguments of a call and invokes
+// the matching function from the registry.
+func (e *Executor) callRegisteredFunction(call *parser.FunctionCall, row table.Row, schema *table.Schema) (table.Value, error) {
+	fn, ok := e.lookupFunction(call.Name)
+	if !ok {
+		return table.Value{}, fmt.Errorf("unknown function: %s", call.Name)
+	}
+
+	if len(call.Args) < fn.MinArgs || (fn.MaxArgs >= 0 && len(call.Args) > fn.MaxArgs) {
+		return table.Value{}, fmt.Errorf("%s: wrong number of arguments (%d)", call.Name, len(call.Args))
+	}
+
+	args := make([]table.Value, len(call.Args))
+	for i, argExpr := range call.Args {
+		val, err := e.evaluateExpression(argExpr, row, schema)
+		if err != nil {
+			return table.Value{}, err
+		}
+		if val.IsNull && fn.NullOnNullInput {
+			return table.Value{IsNull: true}, nil
+		}
+		args[i] = val
+	}
+
+	result, err := invokeFunction(fn, args)
+	if err != nil {
+		return table.Value{}, fmt.Errorf("%s: %w", call.Name, err)
+	}
+	return result, nil
+}
+
+// ============================================================================
+// Argument Helpers
+// ============================================================================
+
+// textValue wraps a string as a TEXT value.
+func textValue(s string) table.Value {
+	return table.Value{Type: parser.TypeText, Text: s}
+}
+
+// intArg reads an argument as an integer. REAL values are truncated.
+func intArg(v table.Value) (int64, error) {
+	switch v.Type {
+	case parser.TypeInteger:
+		return v.Integer, nil
+	case parser.TypeReal:
+		return int64(v.Real), nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %s", v.Type)
+	}
+}
+
+// realArg reads a numeric argument as a float64.
+func realArg(v table.Value) (float64, error) {
+	switch v.Type {
+	case parser.TypeInteger:
+		return float64(v.Integer), nil
+	case parser.TypeReal:
+		return v.Real, nil
+	case parser.TypeDecimal:
+		return table.DecimalToFloat(v), nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %s", v.Type)
+	}
+}
+
+// realValue wraps a float64 as a REAL value.
+func realValue(f float64) table.Value {
+	return table.Value{Type: parser.TypeReal, Real: f}
+}
+
+// ============================================================================
+// String Functions
+// ============================================================================
+
+// Non-text arguments are converted with their display form, so
+// UPPER(TRUE) is 'TRUE' and LENGTH(12345) is 5.
+
+func fnUpper(args []table.Value) (table.Value, error) {
+	return textValue(strings.ToUpper(args[0].String())), nil
+}
+
+func fnLower(args []table.Value) (table.Value, error) {
+	return textValue(strings.ToLower(args[0].String())), nil
+}
+
+// fnLength counts characters, not bytes: LENGTH('héllo') is 5.
+func fnLength(args []table.Value) (table.Value, error) {
+	n := utf8.RuneCountInString(args[0].String())
+	return table.Value{Type: parser.TypeInteger, Integer: int64(n)}, nil
+}
+
+// fnSubstr implements SUBSTR(s, start[, length]).
+//
+// Positions are 1-based as in standard SQL. A negative start counts from
+// the end of the string: SUBSTR('hello', -3) is 'llo'.
+func fnSubstr(args []table.Value) (table.Value, error) {
+	runes := []rune(args[0].String())
+	n := int64(len(runes))
+
+	start, err := intArg(args[1])
+	if err != nil {
+		return table.Value{}, err
+	}
+	length := n
+	if len(args) == 3 {
+		if length, err = intArg(args[2]); err != nil {
+			return table.Value{}, err
+		}
+		if length < 0 {
+			return table.Value{}, fmt.Errorf("negative length %d", length)
+		}
+	}
+
+	// Convert to a 0-based half-open range [from, to)
+	var from int64
+	switch {
+	case start > 0:
+		from = start - 1
+	case start < 0:
+		from = n + start
+	default:
+		// Position 0 is just before the first character, so it
+		// uses up one unit of the requested length.
+		from = 0
+		length--
+	}
+	to := from + length
+
+	if from < 0 {
+		from = 0
+	}
+	if to > n {
+		to = n
+	}
+	if from >= to {
+		return textValue(""), nil
+	}
+	return textValue(string(runes[from:to])), nil
+}
+
+// fnTrim builds TRIM/LTRIM/RTRIM. The optional second argument lists the
+// characters to strip; the default is spaces.
+func fnTrim(trim func(s, cutset string) string) func([]table.Value) (table.Value, error) {
+	return func(args []table.Value) (table.Value, error) {
+		cutset := " "
+		if len(args) == 2 {
+			cutset = args[1].String()
+		}
+		return textValue(trim(args[0].String(), cutset)), nil
+	}
+}
+
+func fnReplace(args []table.Value) (table.Value, error) {
+	s, from, to := args[0].String(), args[1].String(), args[2].String()
+	if from == "" {
+		return textValue(s), nil
+	}
+	return textValue(strings.ReplaceAll(s, from, to)), nil
+}
+
+// fnConcat joins its arguments. Unlike the || operator, CONCAT skips
+// NULL arguments instead of returning NULL.
+func fnConcat(args []table.Value) (table.Value, error) {
+	var sb strings.Builder
+	for _, arg := range args {
+		if !arg.IsNull {
+			sb.WriteString(arg.String())
+		}
+	}
+	return textValue(sb.String()), nil
+}
+
+// ============================================================================
+// Math Functions
+// ============================================================================
+
+// EDUCATIONAL NOTE:
+// -----------------
+// Type promotion follows the same rule as the arithmetic operators: if every
+// input is an INTEGER the result is an INTEGER, otherwise it is a REAL.
+// So ABS(-3) is 3 but ABS(-3.0) is 3.0, and MOD(7, 2) is 1 while
+// MOD(7.5, 2) is 1.5. POWER and RANDOM always produce REAL values because
+// their results are rarely whole numbers.
+
+func fnAbs(args []table.Value) (table.Value, error) {
+	switch args[0].Type {
+	case parser.TypeInteger:
+		n := args[0].Integer
+		if n == math.MinInt64 {
+			return table.Value{}, fmt.Errorf("integer overflow")
+		}
+		if n < 0 {
+			n = -n
+		}
+		return intResult(n), nil
+	case parser.TypeReal:
+		return realValue(math.Abs(args[0].Real)), nil
+	case parser.TypeDecimal:
+		if args[0].Integer < 0 {
+			return table.DecimalSub(table.DecimalValue(0, 0), args[0])
+		}
+		return args[0], nil
+	default:
+		return table.Value{}, fmt.Errorf("expected a number, got %s", args[0].Type)
+	}
+}
+
+// fnRound implements ROUND(x[, digits]). Halves round away from zero, and
+// a negative digit count rounds to tens, hundreds, and so on.
+func fnRound(args []table.Value) (table.Value, error) {
+	digits := int64(0)
+	if len(args) == 2 {
+		var err error
+		if digits, err = intArg(args[1]); err != nil {
+			return table.Value{}, err
+		}
+	}
+
+	switch args[0].Type {
+	case parser.TypeInteger:
+		if digits >= 0 {
+			return args[0], nil
+		}
+		scale := math.Pow(10, float64(-digits))
+		return intResult(int64(math.Round(float64(args[0].Integer)/scale) * scale)), nil
+	case parser.TypeReal:
+		scale := math.Pow(10, float64(digits))
+		return realValue(math.Round(args[0].Real*scale) / scale), nil
+	case parser.TypeDecimal:
+		// Exact: ROUND(2.345, 2) is 2.35, not 2.34 as binary 2.345 would give
+		if digits >= 0 {
+			return table.Rescale(args[0], uint8(min(digits, table.MaxDecimalDigits)))
+		}
+		whole, err := table.Rescale(args[0], 0)
+		if err != nil {
+			return table.Value{}, err
+		}
+		rounded, err := fnRound([]table.Value{intResult(whole.Integer), args[1]})
+		if err != nil {
+			return table.Value{}, err
+		}
+		return table.DecimalValue(rounded.Integer, 0), nil
+	default:
+		return table.Value{}, fmt.Errorf("expected a number, got %s", args[0].Type)
+	}
+}
+
+// fnRealOnly builds CEIL/FLOOR: integers are already whole and pass
+// through unchanged; reals are rounded and stay REAL; decimals are rounded
+// exactly (up if ceil is set, otherwise down) to scale 0.
+func fnRealOnly(round func(float64) float64, ceil bool) func([]table.Value) (table.Value, error) {
+	return func(args []table.Value) (table.Value, error) {
+		switch args[0].Type {
+		case parser.TypeInteger:
+			return args[0], nil
+		case parser.TypeReal:
+			return realValue(round(args[0].Real)), nil
+		case parser.TypeDecimal:
+			unit := int64(math.Pow10(int(args[0].Scale)))
+			q, r := args[0].Integer/unit, args[0].Integer%unit
+			if ceil && r > 0 {
+				q++
+			} else if !ceil && r < 0 {
+				q--
+			}
+			return table.DecimalValue(q, 0), nil
+		default:
+			return table.Value{}, fmt.Errorf("expected a number, got %s", args[0].Type)
+		}
+	}
+}
+
+func fnMod(args []table.Value) (table.Value, error) {
+	if args[0].Type == parser.TypeInteger && args[1].Type == parser.TypeInteger {
+		if args[1].Integer == 0 {
+			return table.Value{}, fmt.Errorf("division by zero")
+		}
+		return intResult(args[0].Integer % args[1].Integer), nil
+	}
+
+	x, err := realArg(args[0])
+	if err != nil {
+		return table.Value{}, err
+	}
+	y, err := realArg(args[1])
+	if err != nil {
+		return table.Value{}, err
+	}
+	if y == 0 {
+		return table.Value{}, fmt.Errorf("division by zero")
+	}
+	return realValue(math.Mod(x, y)), nil
+}
+
+func fnPower(args []table.Value) (table.Value, error) {
+	x, err := realArg(args[0])
+	if err != nil {
+		return table.Value{}, err
+	}
+	y, err := realArg(args[1])
+	if err != nil {
+		return table.Value{}, err
+	}
+	result := math.Pow(x, y)
+	if math.IsNaN(result) {
+		return table.Value{}, fmt.Errorf("result is not a number")
+	}
+	return realValue(result), nil
+}
+
+// fnRandom returns a REAL in [0, 1). It is evaluated per row, so
+// WHERE RANDOM() < 0.1 keeps roughly one row in ten.
+func fnRandom(args []table.Value) (table.Value, error) {
+	return realValue(rand.Float64()), nil
+}
+
+// intResult wraps an int64 as an INTEGER value.
+func intResult(n int64) table.Value {
+	return table.Value{Type: parser.TypeInteger, Integer: n}
+}
+
+// ============================================================================
+// Date/Time Functions
+// ============================================================================
+
+// timestampArg reads an argument as a TIMESTAMP, parsing TEXT if needed.
+func timestampArg(v table.Value) (time.Time, error) {
+	if v.Type != parser.TypeTimestamp {
+		converted, err := table.Cast(v, parser.TypeTimestamp)
+		if err != nil {
+			return time.Time{}, err
+		}
+		v = converted
+	}
+	return table.TimeOf(v), nil
+}
+
+// fnNow returns the current time. NOW() and CURRENT_TIMESTAMP are aliases.
+func fnNow(args []table.Value) (table.Value, error) {
+	return table.TimestampValue(time.Now()), nil
+}
+
+// fnDate returns the calendar date of a timestamp as 'YYYY-MM-DD' text.
+// Being text, it takes no arithmetic (add days to the timestamp instead),
+// but it is convenient for grouping and equality tests:
+//   WHERE DATE(created_at) = '2024-03-01'
+func fnDate(args []table.Value) (table.Value, error) {
+	t, err := timestampArg(args[0])
+	if err != nil {
+		return table.Value{}, err
+	}
+	return textValue(t.Format("2006-01-02")), nil
+}
+
+// fnDateAdd implements DATE_ADD(timestamp, amount, unit), where unit is
+// one of 'second', 'minute', 'hour', 'day', 'month' or 'year'.
+// Months and years follow the calendar and stop at the end of a short
+// month, so one month after January 31st is the last day of February.
+func fnDateAdd(args []table.Value) (table.Value, error) {
+	t, err := timestampArg(args[0])
+	if err != nil {
+		return table.Value{}, err
+	}
+	amount, err := intArg(args[1])
+	if err != nil {
+		return table.Value{}, err
+	}
+	n := int(amount)
+
+	switch strings.ToLower(strings.TrimSuffix(args[2].String(), "s")) {
+	case "second":
+		t = t.Add(time.Duration(amount) * time.Second)
+	case "minute":
+		t = t.Add(time.Duration(amount) * time.Minute)
+	case "hour":
+		t = t.Add(time.Duration(amount) * time.Hour)
+	case "day":
+		t = t.AddDate(0, 0, n)
+	case "month":
+		t = addMonths(t, n)
+	case "year":
+		t = addMonths(t, 12*n)
+	default:
+		return table.Value{}, fmt.Errorf("unknown unit '%s'", args[2].String())
+	}
+	return table.TimestampValue(t), nil
+}
+
+// addMonths moves t by n calendar months, keeping the day of the month
+// unless the target month is shorter. time.AddDate would instead roll
+// January 31st + 1 month over into March.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	first = first.AddDate(0, n, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+// ============================================================================
+// JSON Functions
+// ============================================================================
+
+// jsonArg returns a JSON document argument. TEXT is accepted and checked,
+// so json_extract('{"a": 1}', '$.a') works without a cast.
+func jsonArg(v table.Value) (table.Value, error) {
+	if v.Type == parser.TypeJSON {
+		return v, nil
+	}
+	if v.Type != parser.TypeText {
+		return table.Value{}, fmt.Errorf("expected JSON, got %s", v.Type)
+	}
+	return table.JSONValue(v.Text)
+}
+
+// fnJSONExtract implements JSON_EXTRACT(doc, path). A missing path gives
+// NULL rather than an error, so rows without the field simply don't match.
+func fnJSONExtract(args []table.Value) (table.Value, error) {
+	doc, err := jsonArg(args[0])
+	if err != nil {
+		return table.Value{}, err
+	}
+	return table.JSONExtract(doc, args[1].String())
+}
+
+// fnJSONSet implements JSON_SET(doc, path, value[, path, value ...]).
+// A NULL value stores JSON null; a NULL document gives NULL.
+func fnJSONSet(args []table.Value) (table.Value, error) {
+	if len(args)%2 != 1 {
+		return table.Value{}, fmt.Errorf("JSON_SET requires path/value pairs")
+	}
+	if args[0].IsNull {
+		return table.Value{IsNull: true}, nil
+	}
+
+	doc, err := jsonArg(args[0])
+	if err != nil {
+		return table.Value{}, err
+	}
+	for i := 1; i < len(args); i += 2 {
+		if args[i].IsNull {
+			return table.Value{}, fmt.Errorf("JSON path cannot be NULL")
+		}
+		if doc, err = table.JSONSet(doc, args[i].String(), args[i+1]); err != nil {
+			return table.Value{}, err
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,356 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestNullHandlingFunctions(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, nickname TEXT, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Al', 'Alice', 30)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, NULL, 'Bob', 0)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (3, NULL, 'Carol', COALESCE(NULL, NULL, 41))")
+
+	tests := []struct {
+		sql      string
+		expected []int64
+	}{
+		{"SELECT id FROM users WHERE COALESCE(nickname, name) = 'Bob'", []int64{2}},
+		{"SELECT id FROM users WHERE COALESCE(nickname, name) = 'Al'", []int64{1}},
+		{"SELECT id FROM users WHERE IFNULL(nickname, 'none') = 'none'", []int64{2, 3}},
+		{"SELECT id FROM users WHERE NULLIF(age, 0) > 35", []int64{3}},
+		{"SELECT id FROM users WHERE COALESCE(NULLIF(age, 0), 100) = 100", []int64{2}},
+		// COALESCE short-circuits: the division is never evaluated
+		{"SELECT id FROM users WHERE COALESCE(age, 1 / 0) = 30", []int64{1}},
+	}
+
+	for _, tt := range tests {
+		result := executeSQL(t, exec, tt.sql)
+		if len(result.Rows) != len(tt.expected) {
+			t.Errorf("%s: expected %d rows, got %d", tt.sql, len(tt.expected), len(result.Rows))
+			continue
+		}
+		for i, id := range tt.expected {
+			if result.Rows[i][0].Integer != id {
+				t.Errorf("%s: row %d expected id %d, got %d", tt.sql, i, id, result.Rows[i][0].Integer)
+			}
+		}
+	}
+}
+
+func TestFunctionArgumentErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (1)")
+
+	for _, sql := range []string{
+		"SELECT id FROM t WHERE IFNULL(id) = 1",
+		"SELECT id FROM t WHERE NULLIF(id, 1, 2) = 1",
+		"SELECT id FROM t WHERE NO_SUCH_FUNCTION(id) = 1",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestStringFunctions(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, email TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice', '  alice@example.com  ')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'bob', NULL)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (3, 'Chloé', 'chloe@example.org')")
+
+	tests := []struct {
+		sql      string
+		expected []int64
+	}{
+		{"SELECT id FROM users WHERE UPPER(name) = 'BOB'", []int64{2}},
+		{"SELECT id FROM users WHERE LOWER(name) = 'alice'", []int64{1}},
+		{"SELECT id FROM users WHERE LENGTH(name) = 5", []int64{1, 3}},
+		{"SELECT id FROM users WHERE SUBSTR(name, 1, 2) = 'Al'", []int64{1}},
+		{"SELECT id FROM users WHERE SUBSTR(name, -2) = 'ob'", []int64{2}},
+		{"SELECT id FROM users WHERE SUBSTR(name, 3) = 'loé'", []int64{3}},
+		{"SELECT id FROM users WHERE TRIM(email) = 'alice@example.com'", []int64{1}},
+		{"SELECT id FROM users WHERE RTRIM(name, 'eé') = 'Chlo'", []int64{3}},
+		{"SELECT id FROM users WHERE REPLACE(email, '.org', '.com') = 'chloe@example.com'", []int64{3}},
+		{"SELECT id FROM users WHERE name || '#' || id = 'bob#2'", []int64{2}},
+		{"SELECT id FROM users WHERE CONCAT(name, email) = 'bob'", []int64{2}},
+		// NULL propagates through || and strict functions
+		{"SELECT id FROM users WHERE UPPER(email) = NULL", nil},
+		{"SELECT id FROM users WHERE name || email = 'bob'", nil},
+	}
+
+	for _, tt := range tests {
+		result := executeSQL(t, exec, tt.sql)
+		if len(result.Rows) != len(tt.expected) {
+			t.Errorf("%s: expected %d rows, got %d", tt.sql, len(tt.expected), len(result.Rows))
+			continue
+		}
+		for i, id := range tt.expected {
+			if result.Rows[i][0].Integer != id {
+				t.Errorf("%s: row %d expected id %d, got %d", tt.sql, i, id, result.Rows[i][0].Integer)
+			}
+		}
+	}
+}
+
+func TestSubstrEdgeCases(t *testing.T) {
+	tests := []struct {
+		args     []table.Value
+		expected string
+	}{
+		{[]table.Value{textValue("hello"), intResult(0), intResult(2)}, "h"},
+		{[]table.Value{textValue("hello"), intResult(4), intResult(10)}, "lo"},
+		{[]table.Value{textValue("hello"), intResult(10)}, ""},
+		{[]table.Value{textValue("hello"), intResult(-10), intResult(7)}, "he"},
+	}
+
+	for _, tt := range tests {
+		result, err := fnSubstr(tt.args)
+		if err != nil {
+			t.Errorf("SUBSTR(%v) error: %v", tt.args, err)
+			continue
+		}
+		if result.Text != tt.expected {
+			t.Errorf("SUBSTR(%v) = %q, expected %q", tt.args, result.Text, tt.expected)
+		}
+	}
+}
+
+func TestMathFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []table.Value
+		expected table.Value
+	}{
+		{"ABS", []table.Value{intResult(-3)}, intResult(3)},
+		{"ABS", []table.Value{realValue(-2.5)}, realValue(2.5)},
+		{"ROUND", []table.Value{realValue(2.5)}, realValue(3)},
+		{"ROUND", []table.Value{realValue(-2.5)}, realValue(-3)},
+		{"ROUND", []table.Value{realValue(3.14159), intResult(2)}, realValue(3.14)},
+		{"ROUND", []table.Value{intResult(1250), intResult(-2)}, intResult(1300)},
+		{"ROUND", []table.Value{intResult(7)}, intResult(7)},
+		{"CEIL", []table.Value{realValue(1.2)}, realValue(2)},
+		{"CEIL", []table.Value{intResult(4)}, intResult(4)},
+		{"FLOOR", []table.Value{realValue(-1.2)}, realValue(-2)},
+		{"MOD", []table.Value{intResult(7), intResult(3)}, intResult(1)},
+		{"MOD", []table.Value{realValue(7.5), intResult(2)}, realValue(1.5)},
+		{"POWER", []table.Value{intResult(2), intResult(10)}, realValue(1024)},
+	}
+
+	for _, tt := range tests {
+		fn, ok := lookupFunction(tt.name)
+		if !ok {
+			t.Fatalf("function %s not registered", tt.name)
+		}
+		result, err := fn.Fn(tt.args)
+		if err != nil {
+			t.Errorf("%s(%v) error: %v", tt.name, tt.args, err)
+			continue
+		}
+		if result.Type != tt.expected.Type || !result.Equals(tt.expected) {
+			t.Errorf("%s(%v) = %v (%s), expected %v (%s)",
+				tt.name, tt.args, result, result.Type, tt.expected, tt.expected.Type)
+		}
+	}
+}
+
+func TestMathFunctionsInQueries(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER, rate REAL)")
+	executeSQL(t, exec, "INSERT INTO accounts VALUES (1, -50, 1.25)")
+	executeSQL(t, exec, "INSERT INTO accounts VALUES (2, 75, 2.5)")
+	executeSQL(t, exec, "INSERT INTO accounts VALUES (3, 10, NULL)")
+
+	result := executeSQL(t, exec, "SELECT id FROM accounts WHERE ABS(balance) > 40")
+	if len(result.Rows) != 2 {
+		t.Errorf("expected 2 rows, got %d", len(result.Rows))
+	}
+
+	result = executeSQL(t, exec, "SELECT id FROM accounts WHERE MOD(id, 2) = 0")
+	if len(result.Rows) != 1 || result.Rows[0][0].Integer != 2 {
+		t.Errorf("expected row 2, got %v", result.Rows)
+	}
+
+	// FLOOR of a NULL rate is NULL, which never matches
+	result = executeSQL(t, exec, "SELECT id FROM accounts WHERE FLOOR(rate) = 1.0")
+	if len(result.Rows) != 1 || result.Rows[0][0].Integer != 1 {
+		t.Errorf("expected row 1, got %v", result.Rows)
+	}
+
+	// RANDOM() is evaluated per row and always in [0, 1)
+	result = executeSQL(t, exec, "SELECT id FROM accounts WHERE RANDOM() >= 0.0 AND RANDOM() < 1.0")
+	if len(result.Rows) != 3 {
+		t.Errorf("expected 3 rows, got %d", len(result.Rows))
+	}
+
+	if _, err := exec.Execute(parseSQL(t, "SELECT id FROM accounts WHERE MOD(balance, 0) = 0")); err == nil {
+		t.Error("expected division by zero error")
+	}
+}
+
+func TestDateTimeFunctions(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT, at TIMESTAMP)")
+	executeSQL(t, exec, "INSERT INTO events VALUES (1, 'launch', '2024-03-01 09:30:00')")
+	executeSQL(t, exec, "INSERT INTO events VALUES (2, 'review', '2024-03-15')")
+	executeSQL(t, exec, "INSERT INTO events VALUES (3, 'now', CURRENT_TIMESTAMP)")
+	executeSQL(t, exec, "INSERT INTO events VALUES (4, 'later', DATE_ADD(NOW(), 1, 'day'))")
+
+	tests := []struct {
+		sql      string
+		expected []int64
+	}{
+		{"SELECT id FROM events WHERE at < '2024-03-10'", []int64{1}},
+		{"SELECT id FROM events WHERE DATE(at) = '2024-03-15'", []int64{2}},
+		{"SELECT id FROM events WHERE DATE_ADD(at, 30, 'minutes') = '2024-03-01 10:00:00'", []int64{1}},
+		{"SELECT id FROM events WHERE at + 1 = '2024-03-02 09:30:00'", []int64{1}},
+		{"SELECT id FROM events WHERE 1 + at = '2024-03-16'", []int64{2}},
+		{"SELECT id FROM events WHERE at - 1 = '2024-03-14'", []int64{2}},
+		{"SELECT id FROM events WHERE DATE_ADD(at, 1, 'month') = '2024-04-15'", []int64{2}},
+		{"SELECT id FROM events WHERE at > NOW()", []int64{4}},
+		{"SELECT id FROM events WHERE at - CAST('2024-03-01' AS TIMESTAMP) = 14", []int64{2}},
+		{"SELECT id FROM events WHERE at - CAST('2024-03-01' AS TIMESTAMP) = 0", []int64{1}},
+	}
+
+	for _, tt := range tests {
+		result := executeSQL(t, exec, tt.sql)
+		if len(result.Rows) != len(tt.expected) {
+			t.Errorf("%s: expected %d rows, got %d", tt.sql, len(tt.expected), len(result.Rows))
+			continue
+		}
+		for i, id := range tt.expected {
+			if result.Rows[i][0].Integer != id {
+				t.Errorf("%s: row %d expected id %d, got %d", tt.sql, i, id, result.Rows[i][0].Integer)
+			}
+		}
+	}
+
+	result := executeSQL(t, exec, "SELECT at FROM events WHERE id = 1")
+	if got := result.Rows[0][0].String(); got != "2024-03-01 09:30:00" {
+		t.Errorf("expected timestamp to display as 2024-03-01 09:30:00, got %s", got)
+	}
+
+	if _, err := exec.Execute(parseSQL(t, "INSERT INTO events VALUES (5, 'bad', 'not a date')")); err == nil {
+		t.Error("expected error inserting invalid timestamp text")
+	}
+
+	// Month and year additions stop at the end of a short month
+	calendar := []struct {
+		sql      string
+		expected string
+	}{
+		{"SELECT DATE_ADD('2024-01-31', 1, 'month')", "2024-02-29 00:00:00"},
+		{"SELECT DATE_ADD('2023-01-31 08:15:00', 1, 'month')", "2023-02-28 08:15:00"},
+		{"SELECT DATE_ADD('2024-03-31', -1, 'month')", "2024-02-29 00:00:00"},
+		{"SELECT DATE_ADD('2024-01-31', 3, 'months')", "2024-04-30 00:00:00"},
+		{"SELECT DATE_ADD('2024-01-31', 13, 'month')", "2025-02-28 00:00:00"},
+		{"SELECT DATE_ADD('2024-02-29', 1, 'year')", "2025-02-28 00:00:00"},
+		{"SELECT DATE_ADD('2024-01-15', 1, 'month')", "2024-02-15 00:00:00"},
+		{"SELECT CAST('2024-01-31' AS TIMESTAMP) + 1", "2024-02-01 00:00:00"},
+	}
+	for _, tt := range calendar {
+		result := executeSQL(t, exec, tt.sql)
+		if got := result.Rows[0][0].String(); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.expected, got)
+		}
+	}
+
+	// DATE() is text, so adding a number to it is an error, not a no-op
+	for _, sql := range []string{
+		"SELECT DATE('2024-01-31') + 1",
+		"SELECT 'abc' + 1",
+		"SELECT CAST('2024-01-31' AS TIMESTAMP) + CAST('2024-01-31' AS TIMESTAMP)",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected a type error", sql)
+		}
+	}
+}
+
+func TestJSONFunctions(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE people (id INTEGER PRIMARY KEY, data JSON)")
+	executeSQL(t, exec, `INSERT INTO people VALUES (1, '{"name": "Ada", "age": 36, "tags": ["math"]}')`)
+	executeSQL(t, exec, `INSERT INTO people VALUES (2, '{"name": "Alan", "age": 41, "address": {"city": "Wilmslow"}}')`)
+	executeSQL(t, exec, `INSERT INTO people VALUES (3, '{"name": "Grace"}')`)
+
+	tests := []struct {
+		sql      string
+		expected []int64
+	}{
+		{"SELECT id FROM people WHERE json_extract(data, '$.age') > 40", []int64{2}},
+		{"SELECT id FROM people WHERE json_extract(data, '$.name') = 'Grace'", []int64{3}},
+		{"SELECT id FROM people WHERE json_extract(data, '$.tags[0]') = 'math'", []int64{1}},
+		{"SELECT id FROM people WHERE json_extract(data, '$.address.city') = 'Wilmslow'", []int64{2}},
+		{"SELECT id FROM people WHERE COALESCE(json_extract(data, '$.age'), 0) = 0", []int64{3}},
+		{"SELECT id FROM people WHERE json_extract(json_set(data, '$.age', 99), '$.age') = 99", []int64{1, 2, 3}},
+		{`SELECT id FROM people WHERE json_set('{}', '$.a', 1, '$.b', 'x') = '{"a":1,"b":"x"}'`, []int64{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		result := executeSQL(t, exec, tt.sql)
+		if len(result.Rows) != len(tt.expected) {
+			t.Errorf("%s: expected %d rows, got %d", tt.sql, len(tt.expected), len(result.Rows))
+			continue
+		}
+		for i, id := range tt.expected {
+			if result.Rows[i][0].Integer != id {
+				t.Errorf("%s: row %d expected id %d, got %d", tt.sql, i, id, result.Rows[i][0].Integer)
+			}
+		}
+	}
+
+	if _, err := exec.Execute(parseSQL(t, "INSERT INTO people VALUES (4, '{not json')")); err == nil {
+		t.Error("expected error inserting invalid JSON")
+	}
+	if _, err := exec.Execute(parseSQL(t, "SELECT id FROM people WHERE json_set(data, '$.a') = 1")); err == nil {
+		t.Error("expected error for json_set without a value")
+	}
+}
+
+func TestThreeValuedLogic(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, age INTEGER)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (1, 30)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (2, NULL)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (3, 40)")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT id FROM t WHERE age = 30", "1"},
+		// NULL = 30 is UNKNOWN, and so is its NOT: row 2 matches neither
+		{"SELECT id FROM t WHERE NOT (age = 30) ORDER BY id", "3"},
+		{"SELECT id FROM t WHERE age <> 30 ORDER BY id", "3"},
+		// UNKNOWN AND FALSE is FALSE, so its NOT is TRUE
+		{"SELECT id FROM t WHERE NOT (age = 30 AND id = 1) ORDER BY id", "2, 3"},
+		{"SELECT id FROM t WHERE age > 35 OR id = 2 ORDER BY id", "2, 3"},
+		// UNKNOWN OR FALSE is UNKNOWN
+		{"SELECT id FROM t WHERE NOT (age > 35 OR id = 1) ORDER BY id", ""},
+		{"SELECT age = 30, NOT (age = 30) FROM t ORDER BY id", "TRUE FALSE, NULL NULL, FALSE TRUE"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+}
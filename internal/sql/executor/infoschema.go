@@ -0,0 +1,235 @@
+// Package executor - information_schema
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The CLI's .tables and .schema commands describe the database, but only
+// to someone typing at the CLI. Tools talking SQL (a web client, a schema
+// browser, a migration script) need to ask the same questions in SQL. The
+// SQL standard's answer is information_schema: views describing the
+// database, queried like any table:
+//
+//   SELECT column_name, data_type FROM information_schema.columns
+//   WHERE table_name = 'users' ORDER BY ordinal_position;
+//
+// Three views are available:
+//
+//   information_schema.tables    table_name, table_type, row_count
+//   information_schema.columns   table_name, column_name, ordinal_position,
+//                                data_type, is_nullable, is_primary_key
+//   information_schema.indexes   table_name, index_name, column_names,
+//                                is_unique
+//
+// None of them is stored: their rows are computed from the executor's
+// tables each time a view is read, so they are never out of date. The rows
+// then go through the same WHERE filter, aggregation, ORDER BY and LIMIT
+// as a table's. The views can't be joined, locked or changed.
+//
+// PostgreSQL and MySQL have the same tables and columns views (the
+// standard has no indexes view; PostgreSQL's pg_indexes and MySQL's
+// information_schema.statistics fill that gap), and SQLite has
+// sqlite_master.
+//
+// MySQL's shorthands for the common questions are answered from the same
+// views:
+//
+//   SHOW TABLES;                  -- the tables' names
+//   SHOW INDEXES [FROM users];    -- the indexes, of every table or one
+//   DESCRIBE users;               -- the columns of a table
+
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// systemViews computes the schema and rows of each information_schema
+// view, by name.
+var systemViews = map[string]func(e *Executor) (*table.Schema, []table.Row){
+	"information_schema.tables":  (*Executor).tablesView,
+	"information_schema.columns": (*Executor).columnsView,
+	"information_schema.indexes": (*Executor).indexesView,
+}
+
+// isSystemView reports whether name is an information_schema view.
+func isSystemView(name string) bool {
+	_, ok := systemViews[strings.ToLower(name)]
+	return ok
+}
+
+// selectSystemView runs a SELECT on an information_schema view, whose rows
+// are all computed up front.
+func (e *Executor) selectSystemView(stmt *parser.SelectStatement) (*Result, error) {
+	name := strings.ToLower(stmt.From)
+	if stmt.ForUpdate {
+		return nil, fmt.Errorf("cannot lock rows of %s", name)
+	}
+	schema, rows := systemViews[name](e)
+	projection, agg, err := selectProjection(stmt, schema)
+	if err != nil {
+		return nil, err
+	}
+	if agg != nil {
+		agg.chooseJoined(float64(len(rows)))
+	}
+
+	var it rowIterator = &sliceIterator{rows: rows}
+	it = e.traced(e.filtered(it, stmt.Where, schema), "View Scan", whereDetail(name, stmt.Where))
+	return e.collectResult(e.selectPipeline(stmt, it, schema, projection, agg, false), projection)
+}
+
+// executeShow runs SHOW TABLES or SHOW INDEXES as a SELECT on the view.
+func (e *Executor) executeShow(stmt *parser.ShowStatement) (*Result, error) {
+	switch stmt.What {
+	case "tables":
+		return e.selectSystemView(viewSelect("information_schema.tables", "", "table_name"))
+	case "indexes":
+		if stmt.Table != "" {
+			if _, ok := e.GetTable(stmt.Table); !ok {
+				return nil, fmt.Errorf("table %s does not exist", strings.ToLower(stmt.Table))
+			}
+		}
+		return e.selectSystemView(viewSelect("information_schema.indexes", stmt.Table,
+			"table_name", "index_name", "column_names", "is_unique"))
+	default:
+		return nil, fmt.Errorf("unsupported SHOW %s", stmt.What)
+	}
+}
+
+// executeDescribe runs DESCRIBE as a SELECT on information_schema.columns.
+func (e *Executor) executeDescribe(stmt *parser.DescribeStatement) (*Result, error) {
+	if _, ok := e.GetTable(stmt.Table); !ok {
+		return nil, fmt.Errorf("table %s does not exist", strings.ToLower(stmt.Table))
+	}
+	return e.selectSystemView(viewSelect("information_schema.columns", stmt.Table,
+		"column_name", "data_type", "is_nullable", "is_primary_key"))
+}
+
+// viewSelect builds SELECT columns FROM view, limited to the rows of
+// tableName unless it is empty.
+func viewSelect(view, tableName string, columns ...string) *parser.SelectStatement {
+	stmt := &parser.SelectStatement{From: view}
+	for _, col := range columns {
+		stmt.Columns = append(stmt.Columns, &parser.Identifier{Name: col})
+	}
+	if tableName != "" {
+		stmt.Where = &parser.BinaryExpression{
+			Left:     &parser.Identifier{Name: "table_name"},
+			Operator: parser.OpEquals,
+			Right:    &parser.StringLiteral{Value: strings.ToLower(tableName)},
+		}
+	}
+	return stmt
+}
+
+// tablesView returns information_schema.tables: a row per table, the
+// virtual tables last. Counting a virtual table's rows would mean reading
+// its file, so their row_count is NULL.
+func (e *Executor) tablesView() (*table.Schema, []table.Row) {
+	schema := table.NewSchema([]parser.ColumnDefinition{
+		{Name: "table_name", Type: parser.TypeText},
+		{Name: "table_type", Type: parser.TypeText},
+		{Name: "row_count", Type: parser.TypeInteger},
+	})
+	var rows []table.Row
+	tables := e.visibleTables()
+	for _, name := range e.GetTables() {
+		rows = append(rows, table.Row{Values: []table.Value{
+			textValue(name),
+			textValue("BASE TABLE"),
+			{Type: parser.TypeInteger, Integer: tables[name].Stats().RowCount},
+		}})
+	}
+	for _, name := range e.VirtualTables() {
+		rows = append(rows, table.Row{Values: []table.Value{
+			textValue(name),
+			textValue("VIRTUAL TABLE"),
+			{Type: parser.TypeInteger, IsNull: true},
+		}})
+	}
+	return schema, rows
+}
+
+// columnsView returns information_schema.columns: a row per column of
+// each table, numbered from 1 in the order of the table's definition.
+func (e *Executor) columnsView() (*table.Schema, []table.Row) {
+	schema := table.NewSchema([]parser.ColumnDefinition{
+		{Name: "table_name", Type: parser.TypeText},
+		{Name: "column_name", Type: parser.TypeText},
+		{Name: "ordinal_position", Type: parser.TypeInteger},
+		{Name: "data_type", Type: parser.TypeText},
+		{Name: "is_nullable", Type: parser.TypeText},
+		{Name: "is_primary_key", Type: parser.TypeText},
+		{Name: "collation_name", Type: parser.TypeText},
+	})
+	var rows []table.Row
+	tables := e.visibleTables()
+	for _, name := range e.GetTables() {
+		for i, col := range tables[name].Schema.Columns {
+			collation := table.Value{Type: parser.TypeText, IsNull: true}
+			if col.Type == parser.TypeText {
+				collation = textValue(col.Collation.String())
+			}
+			dataType := col.Type.String()
+			if col.Precision > 0 {
+				dataType += fmt.Sprintf("(%d,%d)", col.Precision, col.Scale)
+			}
+			if col.Length > 0 {
+				dataType = fmt.Sprintf("VARCHAR(%d)", col.Length)
+			}
+			rows = append(rows, table.Row{Values: []table.Value{
+				textValue(name),
+				textValue(col.Name),
+				{Type: parser.TypeInteger, Integer: int64(i + 1)},
+				textValue(dataType),
+				yesNo(!col.NotNull && !col.PrimaryKey),
+				yesNo(col.PrimaryKey),
+				collation,
+			}})
+		}
+	}
+	return schema, rows
+}
+
+// indexesView returns information_schema.indexes: a row per secondary
+// index, with its columns in order, separated by commas.
+func (e *Executor) indexesView() (*table.Schema, []table.Row) {
+	schema := table.NewSchema([]parser.ColumnDefinition{
+		{Name: "table_name", Type: parser.TypeText},
+		{Name: "index_name", Type: parser.TypeText},
+		{Name: "column_names", Type: parser.TypeText},
+		{Name: "is_unique", Type: parser.TypeBoolean},
+	})
+	var rows []table.Row
+	tables := e.visibleTables()
+	for _, name := range e.GetTables() {
+		tbl := tables[name]
+		indexes := tbl.ListIndexes()
+		sort.Strings(indexes)
+		for _, indexName := range indexes {
+			idx, ok := tbl.GetIndex(indexName)
+			if !ok {
+				continue
+			}
+			rows = append(rows, table.Row{Values: []table.Value{
+				textValue(name),
+				textValue(idx.Name),
+				textValue(strings.Join(idx.Columns, ", ")),
+				{Type: parser.TypeBoolean, Boolean: idx.Unique},
+			}})
+		}
+	}
+	return schema, rows
+}
+
+// yesNo returns the 'YES' or 'NO' information_schema uses for a flag.
+func yesNo(b bool) table.Value {
+	if b {
+		return textValue("YES")
+	}
+	return textValue("NO")
+}
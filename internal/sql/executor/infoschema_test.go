@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestInformationSchema(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, balance DECIMAL(10,2))")
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER)")
+	executeSQL(t, exec, "CREATE UNIQUE INDEX idx_name ON users (name)")
+	executeSQL(t, exec, "CREATE INDEX idx_user ON orders (user_id)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'alice', 10.50)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'bob', 3.00)")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM information_schema.tables",
+			"orders BASE TABLE 0, users BASE TABLE 2"},
+		{"SELECT column_name, ordinal_position, data_type, is_nullable, is_primary_key FROM information_schema.columns WHERE table_name = 'users'",
+			"id 1 INTEGER NO YES, name 2 TEXT NO NO, balance 3 DECIMAL(10,2) YES NO"},
+		{"SELECT table_name, index_name, column_names, is_unique FROM INFORMATION_SCHEMA.INDEXES",
+			"orders idx_user user_id FALSE, users idx_name name TRUE"},
+		{"SELECT table_name, COUNT(*) FROM information_schema.columns GROUP BY table_name ORDER BY table_name DESC LIMIT 1",
+			"users 3"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.want, got)
+		}
+	}
+
+	// The views follow the tables as they change
+	executeSQL(t, exec, "DROP TABLE orders")
+	if got := resultText(executeSQL(t, exec, "SELECT table_name FROM information_schema.tables")); got != "users" {
+		t.Errorf("expected users after DROP TABLE, got %s", got)
+	}
+
+	// They can be read a row at a time too
+	rows, err := exec.Query(parseSQL(t, "SELECT column_name FROM information_schema.columns WHERE ordinal_position = ?"),
+		table.Value{Type: parser.TypeInteger, Integer: 2})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !rows.Next() || rows.Values()[0].Text != "name" {
+		t.Errorf("expected the second column, name")
+	}
+	rows.Close()
+
+	for _, sql := range []string{
+		"SELECT * FROM information_schema.nothing",
+		"SELECT * FROM information_schema.tables FOR UPDATE",
+		"CREATE TABLE information_schema.tables (id INTEGER)",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), "information_schema") {
+			t.Errorf("%s: expected an error naming the view, got %v", sql, err)
+		}
+	}
+}
+
+func TestShowAndDescribe(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_age ON users (age)")
+	executeSQL(t, exec, "CREATE INDEX idx_user ON orders (user_id)")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SHOW TABLES", "orders, users"},
+		{"SHOW INDEXES", "orders idx_user user_id FALSE, users idx_age age FALSE"},
+		{"SHOW INDEXES FROM Users", "users idx_age age FALSE"},
+		{"DESCRIBE users", "id INTEGER NO YES, name TEXT NO NO, age INTEGER YES NO"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.want, got)
+		}
+	}
+
+	result := executeSQL(t, exec, "DESCRIBE orders")
+	if strings.Join(result.Columns, ",") != "column_name,data_type,is_nullable,is_primary_key" {
+		t.Errorf("unexpected DESCRIBE columns %v", result.Columns)
+	}
+
+	for _, sql := range []string{"DESCRIBE missing", "SHOW INDEXES FROM missing"} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), "does not exist") {
+			t.Errorf("%s: expected a missing table error, got %v", sql, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package executor - PRAGMA integrity_check
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// PRAGMA integrity_check reads the whole database and reports anything
+// that doesn't add up, one problem per row, or a single row saying "ok":
+//
+//   1. Every page is read back from disk and its checksum verified.
+//   2. The catalog's tables are the tables the executor has loaded, and
+//      the pages it records for them have the right types.
+//   3. Each table's B-trees, data pages and indexes are checked and
+//      compared with each other (see table.CheckIntegrity).
+//
+// It only reads, so it's safe to run at any time, but it visits every
+// page and so takes as long as a scan of every table.
+
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// executePragma runs a PRAGMA command.
+func (e *Executor) executePragma(stmt *parser.PragmaStatement) (*Result, error) {
+	switch stmt.Name {
+	case "integrity_check":
+		return e.executeIntegrityCheck(), nil
+	default:
+		// PRAGMA name shows a setting, as in SQLite
+		if result, ok := e.showSetting(stmt.Name); ok {
+			return result, nil
+		}
+		return nil, fmt.Errorf("unknown pragma: %s", stmt.Name)
+	}
+}
+
+// executeIntegrityCheck checks the whole database for corruption.
+func (e *Executor) executeIntegrityCheck() *Result {
+	r := storage.NewIntegrityReport()
+
+	for pageID := uint32(0); pageID < e.pager.PageCount(); pageID++ {
+		if err := e.pager.VerifyPage(pageID); err != nil {
+			r.Errorf("%v", err)
+		}
+	}
+
+	if e.catalog != nil {
+		r.Claim(catalog.CatalogPageID, "catalog")
+		e.checkCatalog(r)
+	}
+
+	freePages, err := e.pager.FreePageIDs()
+	if err != nil {
+		r.Errorf("free list: %v", err)
+	}
+	for _, pageID := range freePages {
+		r.Claim(pageID, "the free list")
+	}
+
+	// The tables of attached databases are in other files
+	tables := e.ownTables()
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tables[name].CheckIntegrity(r)
+	}
+
+	result := &Result{Columns: []string{"integrity_check"}}
+	problems := r.Problems
+	if len(problems) == 0 {
+		problems = []string{"ok"}
+	}
+	for _, problem := range problems {
+		result.Rows = append(result.Rows, []table.Value{{Type: parser.TypeText, Text: problem}})
+	}
+	result.RowCount = len(result.Rows)
+	return result
+}
+
+// checkCatalog verifies that the catalog lists exactly the loaded tables
+// and that the pages it records for them exist and have the right types.
+//
+// The catalog is only brought up to date when changes are flushed (see
+// syncCatalog), so its page lists may lag behind the tables'; the pages
+// it does list must still be theirs.
+func (e *Executor) checkCatalog(r *storage.IntegrityReport) {
+	listed := make(map[string]bool)
+	for _, name := range e.catalog.ListTables() {
+		listed[name] = true
+		if _, ok := e.tables[name]; !ok {
+			r.Errorf("catalog: table %s is not loaded", name)
+			continue
+		}
+		info, _ := e.catalog.GetTableInfo(name)
+		e.checkCatalogPage(r, name, info.RootPage, storage.PageTypeBTreeLeaf, storage.PageTypeBTreeInternal)
+		for _, pageID := range info.DataPageIDs {
+			e.checkCatalogPage(r, name, pageID, storage.PageTypeData)
+		}
+	}
+	for name := range e.ownTables() {
+		if !listed[name] {
+			r.Errorf("catalog: table %s is missing", name)
+		}
+	}
+}
+
+// checkCatalogPage verifies that a page the catalog records for a table
+// exists and has one of the expected types.
+func (e *Executor) checkCatalogPage(r *storage.IntegrityReport, tableName string, pageID uint32, types ...storage.PageType) {
+	page, err := e.pager.GetPage(pageID)
+	if err != nil {
+		r.Errorf("catalog: table %s: %v", tableName, err)
+		return
+	}
+	for _, pageType := range types {
+		if page.Type() == pageType {
+			return
+		}
+	}
+	want := make([]string, len(types))
+	for i, pageType := range types {
+		want[i] = fmt.Sprint(pageType)
+	}
+	r.Errorf("catalog: table %s: page %d has page type %d, expected %s",
+		tableName, pageID, page.Type(), strings.Join(want, " or "))
+}
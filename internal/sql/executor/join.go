@@ -0,0 +1,604 @@
+// Package executor - Joins
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// SELECT ... FROM a JOIN b ON <condition> pairs each row of a with every
+// row of b for which the condition holds. With more tables the joins run
+// left to right: the rows joined so far are joined with the next table.
+// There are three classic ways to find the pairs:
+//
+//   - Nested loop join: for each row of the left input, check every row of
+//     the right one. It works for any condition, but compares every pair.
+//
+//   - Hash join: for an equality such as a.x = b.y, put the right input's
+//     rows in a hash table by y, then look up each left row's x. Each input
+//     is read once, but the hash table holds the whole right input.
+//
+//   - Merge join: if both inputs are already sorted on the join key, walk
+//     them side by side like merging two sorted lists, advancing whichever
+//     is behind and pairing rows whose keys are equal. Each input is read
+//     once, and nothing needs building: only the rows of the current key
+//     are looked at twice.
+//
+// Reading a table through its primary key's B-tree gives its rows in key
+// order for free, so an equality between two primary keys (say a table
+// of users and one of their profiles, sharing ids) can be merged with no
+// sort and no hash table. That's when the executor uses a merge join; any
+// other equality between columns of the same type gets a hash join, and
+// anything else a nested loop.
+//
+// Both the hash and the merge join produce rows in the order of their left
+// input, so a chain of joins on the first table's primary key can merge
+// all the way. The inputs are still read into memory, unlike the rest of
+// the executor's operators (see operators.go); a streaming merge join
+// would hold no more than one row of each side. PostgreSQL would also
+// sort inputs to merge them, and choose the method and the order of the
+// tables by cost.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/sql/planner"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// JoinMethod is how a join finds the pairs of rows that match.
+type JoinMethod int
+
+const (
+	// NestedLoopJoin compares every pair of rows.
+	NestedLoopJoin JoinMethod = iota
+	// HashJoin looks up each left row in a hash table of the right rows.
+	HashJoin
+	// MergeJoin walks two inputs sorted on the join key side by side.
+	MergeJoin
+)
+
+func (m JoinMethod) String() string {
+	switch m {
+	case HashJoin:
+		return "Hash Join"
+	case MergeJoin:
+		return "Merge Join"
+	default:
+		return "Nested Loop Join"
+	}
+}
+
+// joinedTable is one table of a join, and the position of its first
+// column in the joined rows.
+type joinedTable struct {
+	name   string
+	table  *table.Table
+	offset int
+}
+
+// joinStep is how one JOIN clause runs.
+type joinStep struct {
+	right  joinedTable
+	on     parser.Expression
+	schema *table.Schema // the columns of the tables joined so far, right included
+	method JoinMethod
+
+	// For hash and merge joins: the columns the equality compares, as
+	// positions in the joined rows
+	leftKey, rightKey int
+}
+
+// joinPlan is how a SELECT with joins runs: read the FROM table, then
+// join each table in turn.
+type joinPlan struct {
+	from         joinedTable
+	byPrimaryKey bool // read from in primary key order, for a merge join
+	steps        []joinStep
+	schema       *table.Schema // the columns of every table
+	ambiguous    map[string]bool
+}
+
+// planJoins decides how to run each join of a SELECT.
+func (e *Executor) planJoins(stmt *parser.SelectStatement) (*joinPlan, error) {
+	if stmt.ForUpdate {
+		return nil, fmt.Errorf("FOR UPDATE is not supported with JOIN")
+	}
+
+	tables := make([]joinedTable, 0, len(stmt.Joins)+1)
+	addTable := func(name string) (joinedTable, error) {
+		name = strings.ToLower(name)
+		if e.isVirtualTable(name) {
+			return joinedTable{}, fmt.Errorf("JOIN is not supported with virtual table %s", name)
+		}
+		tbl, exists := e.lookupTable(name)
+		if !exists {
+			return joinedTable{}, fmt.Errorf("table %s does not exist", name)
+		}
+		if _, ok := e.partitions[name]; ok {
+			return joinedTable{}, fmt.Errorf("JOIN is not supported with partitioned table %s", name)
+		}
+		offset := 0
+		for _, jt := range tables {
+			if jt.name == name {
+				return joinedTable{}, fmt.Errorf("table %s is joined more than once", name)
+			}
+			offset += len(jt.table.Schema.Columns)
+		}
+		jt := joinedTable{name: name, table: tbl, offset: offset}
+		tables = append(tables, jt)
+		return jt, nil
+	}
+
+	from, err := addTable(stmt.From)
+	if err != nil {
+		return nil, err
+	}
+	plan := &joinPlan{from: from}
+
+	// ordered is the column the joined rows are sorted on, if any
+	ordered := -1
+	for i, join := range stmt.Joins {
+		right, err := addTable(join.Table)
+		if err != nil {
+			return nil, err
+		}
+		schema, ambiguous := joinSchema(tables)
+		if err := checkAmbiguous(ambiguous, join.On); err != nil {
+			return nil, err
+		}
+		step := joinStep{right: right, on: join.On, schema: schema, method: NestedLoopJoin}
+
+		if left, rightKey, ok := equiJoinKeys(join.On, schema, right.offset); ok &&
+			schema.Columns[left].Type == schema.Columns[rightKey].Type {
+			step.method, step.leftKey, step.rightKey = HashJoin, left, rightKey
+
+			// A merge join needs both sides in key order: the right table
+			// read by its primary key, and the left rows already sorted,
+			// which the first join can arrange by reading the FROM table
+			// by its primary key too
+			rightPK := right.table.Schema.PrimaryKey
+			if i == 0 && from.table.Schema.PrimaryKey >= 0 && left == from.table.Schema.PrimaryKey {
+				ordered = left
+			}
+			if rightPK >= 0 && rightKey == right.offset+rightPK && left == ordered {
+				step.method = MergeJoin
+				plan.byPrimaryKey = true
+			}
+		}
+		plan.steps = append(plan.steps, step)
+	}
+	plan.schema, plan.ambiguous = joinSchema(tables)
+	return plan, nil
+}
+
+// joinSchema describes the rows of a join: the columns of each table in
+// turn. A column is named table.column, or by its name alone unless more
+// than one of the tables has a column of that name, which makes the name
+// ambiguous.
+func joinSchema(tables []joinedTable) (*table.Schema, map[string]bool) {
+	schema := &table.Schema{PrimaryKey: -1, ColumnLookup: make(map[string]int)}
+	ambiguous := make(map[string]bool)
+	for _, jt := range tables {
+		for i, col := range jt.table.Schema.Columns {
+			pos := jt.offset + i
+			schema.Columns = append(schema.Columns, col)
+			schema.ColumnLookup[jt.name+"."+col.Name] = pos
+
+			if _, taken := schema.ColumnLookup[col.Name]; taken || ambiguous[col.Name] {
+				delete(schema.ColumnLookup, col.Name)
+				ambiguous[col.Name] = true
+				continue
+			}
+			schema.ColumnLookup[col.Name] = pos
+		}
+	}
+	return schema, ambiguous
+}
+
+// checkAmbiguous returns an error if any of exprs names an ambiguous
+// column without its table.
+func checkAmbiguous(ambiguous map[string]bool, exprs ...parser.Expression) error {
+	var err error
+	for _, expr := range exprs {
+		parser.WalkExpression(expr, func(ex parser.Expression) bool {
+			if ident, ok := ex.(*parser.Identifier); ok && ambiguous[ident.Name] && err == nil {
+				err = fmt.Errorf("ambiguous column name: %s", ident.Name)
+			}
+			return err == nil
+		})
+	}
+	return err
+}
+
+// equiJoinKeys looks among the AND-ed conditions of on for an equality
+// between a column of the left input and a column of the right table,
+// which starts at rightOffset, and returns their positions.
+func equiJoinKeys(on parser.Expression, schema *table.Schema, rightOffset int) (left, right int, ok bool) {
+	bin, isBinary := on.(*parser.BinaryExpression)
+	if !isBinary {
+		return 0, 0, false
+	}
+	switch bin.Operator {
+	case parser.OpAnd:
+		if left, right, ok := equiJoinKeys(bin.Left, schema, rightOffset); ok {
+			return left, right, true
+		}
+		return equiJoinKeys(bin.Right, schema, rightOffset)
+	case parser.OpEquals:
+		a, okA := columnPosition(bin.Left, schema)
+		b, okB := columnPosition(bin.Right, schema)
+		switch {
+		case !okA || !okB:
+		case a < rightOffset && b >= rightOffset:
+			return a, b, true
+		case b < rightOffset && a >= rightOffset:
+			return b, a, true
+		}
+	}
+	return 0, 0, false
+}
+
+// columnPosition returns the position of the column expr names, if it is
+// a column reference.
+func columnPosition(expr parser.Expression, schema *table.Schema) (int, bool) {
+	ident, ok := expr.(*parser.Identifier)
+	if !ok {
+		return 0, false
+	}
+	return schema.GetColumnIndex(ident.Name)
+}
+
+// executeJoin runs a SELECT with joins: read the FROM table, join each
+// table in turn, then filter, sort, limit and project the joined rows.
+func (e *Executor) executeJoin(stmt *parser.SelectStatement) (*Result, error) {
+	plan, err := e.planJoins(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.checkColumns(stmt); err != nil {
+		return nil, err
+	}
+	projection, agg, err := selectProjection(stmt, plan.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	step := e.startStep()
+	rows, err := e.readJoinInput(plan.from.table, plan.byPrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	operator, detail := joinInputStep(plan.from, plan.byPrimaryKey)
+	e.endStep(step, operator, detail, len(rows))
+
+	for _, js := range plan.steps {
+		step := e.startStep()
+		joined, err := e.join(rows, js)
+		if err != nil {
+			return nil, err
+		}
+		e.release(rowsMemory(rows))
+		rows = joined
+		e.endStep(step, js.method.String(), joinDetail(js), len(rows))
+	}
+
+	var it rowIterator = &sliceIterator{rows: rows}
+	if stmt.Where != nil {
+		it = e.traced(e.filtered(it, stmt.Where, plan.schema), "Filter", expressionName(stmt.Where))
+	}
+	if agg != nil {
+		agg.chooseJoined(float64(len(rows)))
+	}
+	return e.collectResult(e.selectPipeline(stmt, it, plan.schema, projection, agg, false), projection)
+}
+
+// checkColumns returns an error if the select list, WHERE clause or
+// ORDER BY of stmt names an ambiguous column without its table.
+func (p *joinPlan) checkColumns(stmt *parser.SelectStatement) error {
+	if err := checkAmbiguous(p.ambiguous, append(stmt.Columns, stmt.Where)...); err != nil {
+		return err
+	}
+	for _, clause := range stmt.OrderBy {
+		if p.ambiguous[clause.Column] {
+			return fmt.Errorf("ambiguous column name: %s", clause.Column)
+		}
+	}
+	return nil
+}
+
+// readJoinInput reads all rows of a table, in primary key order if
+// ordered is set, reserving their memory as it goes: a table too large
+// for the query's budget fails at the row that goes over it, before the
+// rest is read. The caller releases the rows' memory.
+func (e *Executor) readJoinInput(tbl *table.Table, ordered bool) ([]table.Row, error) {
+	var scan *table.RowIterator
+	if ordered {
+		scan = tbl.Iterator()
+		e.usedIndex("primary key")
+	} else {
+		// A large table's pages are decoded by a pool of workers
+		scan = tbl.NewScanIterator()
+		scan.Parallel(0)
+	}
+	it := e.interruptible(scan)
+	defer it.Close()
+
+	var rows []table.Row
+	for it.Next() {
+		if err := e.reserve(rowMemory(it.Row()), "join input"); err != nil {
+			return nil, err
+		}
+		rows = append(rows, it.Row())
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("scan failed: %w", err)
+	}
+	return rows, nil
+}
+
+// join joins the rows so far with the rows of the step's table.
+func (e *Executor) join(left []table.Row, step joinStep) ([]table.Row, error) {
+	right, err := e.readJoinInput(step.right.table, step.method == MergeJoin)
+	if err != nil {
+		return nil, err
+	}
+	defer e.release(rowsMemory(right))
+
+	// emit keeps a pair of rows if they meet the whole ON condition; the
+	// hash and merge joins only find pairs with equal keys
+	var joined []table.Row
+	emit := func(l, r table.Row) error {
+		if err := e.canceled(); err != nil {
+			return err
+		}
+		values := make([]table.Value, step.right.offset, step.right.offset+len(r.Values))
+		copy(values, l.Values)
+		for i := len(l.Values); i < step.right.offset; i++ {
+			values[i] = table.Value{IsNull: true}
+		}
+		row := table.Row{ID: l.ID, Values: append(values, r.Values...)}
+
+		match, err := e.evaluateCondition(step.on, row, step.schema)
+		if err != nil {
+			return err
+		}
+		if match {
+			if err := e.reserve(rowMemory(row), "join"); err != nil {
+				return err
+			}
+			joined = append(joined, row)
+		}
+		return nil
+	}
+
+	switch step.method {
+	case MergeJoin:
+		err = mergeJoin(left, right, step.leftKey, step.rightKey-step.right.offset, emit)
+	case HashJoin:
+		if err := e.reserve(len(right)*hashEntryMemory, "hash join"); err != nil {
+			return nil, err
+		}
+		defer e.release(len(right) * hashEntryMemory)
+		err = hashJoin(left, right, step.leftKey, step.rightKey-step.right.offset, emit)
+	default:
+		for _, l := range left {
+			for _, r := range right {
+				if err = emit(l, r); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return joined, nil
+}
+
+// mergeJoin pairs the rows of left and right, both sorted on their key
+// columns, whose keys are equal.
+func mergeJoin(left, right []table.Row, leftKey, rightKey int, emit func(l, r table.Row) error) error {
+	key := func(row table.Row, col int) table.Value {
+		if col < len(row.Values) {
+			return row.Values[col]
+		}
+		return table.Value{IsNull: true}
+	}
+
+	i, j := 0, 0
+	for i < len(left) && j < len(right) {
+		lk, rk := key(left[i], leftKey), key(right[j], rightKey)
+		if lk.IsNull {
+			i++
+			continue
+		}
+		if rk.IsNull {
+			j++
+			continue
+		}
+
+		switch cmp := lk.Compare(rk); {
+		case cmp < 0:
+			i++
+		case cmp > 0:
+			j++
+		default:
+			// Pair every left row of this key with every right row of it
+			end := j
+			for end < len(right) && key(right[end], rightKey).Compare(lk) == 0 {
+				end++
+			}
+			for ; i < len(left) && key(left[i], leftKey).Compare(lk) == 0; i++ {
+				for _, r := range right[j:end] {
+					if err := emit(left[i], r); err != nil {
+						return err
+					}
+				}
+			}
+			j = end
+		}
+	}
+	return nil
+}
+
+// hashJoin pairs each left row with the right rows whose key equals its
+// own, found in a hash table of the right rows.
+func hashJoin(left, right []table.Row, leftKey, rightKey int, emit func(l, r table.Row) error) error {
+	buckets := make(map[string][]table.Row)
+	for _, r := range right {
+		if rightKey < len(r.Values) {
+			if k, ok := hashKey(r.Values[rightKey]); ok {
+				buckets[k] = append(buckets[k], r)
+			}
+		}
+	}
+
+	for _, l := range left {
+		if leftKey >= len(l.Values) {
+			continue
+		}
+		k, ok := hashKey(l.Values[leftKey])
+		if !ok {
+			continue
+		}
+		for _, r := range buckets[k] {
+			if err := emit(l, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hashKey returns the hash table key of a join key value: the same for
+// equal values of one type, so 1.5 and 1.50 share a DECIMAL key. NULL
+// equals nothing and has no key.
+func hashKey(val table.Value) (string, bool) {
+	if val.IsNull {
+		return "", false
+	}
+	switch val.Type {
+	case parser.TypeReal:
+		if val.Real == 0 {
+			return "0", true // -0 = 0
+		}
+	case parser.TypeDecimal:
+		s := val.String()
+		if strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+		return s, true
+	}
+	return val.String(), true
+}
+
+// joinInputStep names the step reading the FROM table of a join.
+func joinInputStep(jt joinedTable, byPrimaryKey bool) (operator, detail string) {
+	if byPrimaryKey {
+		return "Index Scan", jt.name + " using primary key"
+	}
+	return "Table Scan", jt.name
+}
+
+// joinDetail describes a join step for plan output.
+func joinDetail(step joinStep) string {
+	return step.right.name + " on " + expressionName(step.on)
+}
+
+// joinPlanTree builds the estimated plan of a SELECT with joins: a join
+// node for each JOIN, whose inputs are the joins before it and a read of
+// its table.
+func (e *Executor) joinPlanTree(stmt *parser.SelectStatement) (*PlanNode, error) {
+	plan, err := e.planJoins(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.checkColumns(stmt); err != nil {
+		return nil, err
+	}
+	projection, agg, err := selectProjection(stmt, plan.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	node := joinInputNode(plan.from, plan.byPrimaryKey)
+	for _, step := range plan.steps {
+		right := joinInputNode(step.right, step.method == MergeJoin)
+		leftRows, rightRows := node.Estimate.Rows, right.Estimate.Rows
+		node = &PlanNode{
+			Operator: step.method.String(),
+			Detail:   joinDetail(step),
+			Estimate: &PlanEstimate{
+				Rows: planner.JoinRows(leftRows, rightRows, step.method != NestedLoopJoin),
+				Cost: node.Estimate.Cost + right.Estimate.Cost +
+					planner.JoinCost(leftRows, rightRows, step.method == NestedLoopJoin),
+			},
+			Children: []*PlanNode{node, right},
+		}
+	}
+	if stmt.Where != nil {
+		node = pipe(node, "Filter", expressionName(stmt.Where), node.Estimate.Rows)
+	}
+	if agg != nil {
+		agg.chooseJoined(node.Estimate.Rows)
+	}
+	return selectPlanTail(stmt, node, projection, agg, plan.from.name, false), nil
+}
+
+// joinInputNode is the read of one table of a join, in primary key order
+// if ordered is set.
+func joinInputNode(jt joinedTable, ordered bool) *PlanNode {
+	stats := jt.table.Stats()
+	rows := float64(stats.RowCount)
+	cost := planner.TableScanCost(&stats)
+	if ordered {
+		cost = planner.IndexScanCost(&stats, rows, false)
+	}
+	operator, detail := joinInputStep(jt, ordered)
+	return &PlanNode{Operator: operator, Detail: detail, Estimate: &PlanEstimate{Rows: rows, Cost: cost}}
+}
+
+// explainJoin returns the Property/Value plan of a SELECT with joins.
+func (e *Executor) explainJoin(stmt *parser.SelectStatement) (*Result, error) {
+	tree, err := e.joinPlanTree(stmt)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := e.planJoins(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{plan.from.name}
+	for _, step := range plan.steps {
+		names = append(names, step.right.name)
+	}
+	text := func(s string) table.Value { return table.Value{Type: parser.TypeText, Text: s} }
+
+	operator, detail := joinInputStep(plan.from, plan.byPrimaryKey)
+	rows := [][]table.Value{
+		{text("Query Plan"), text("JOIN " + strings.Join(names, ", "))},
+		{text("Access Method"), text(operator + " " + detail)},
+	}
+	for i, step := range plan.steps {
+		rows = append(rows, []table.Value{
+			text(fmt.Sprintf("Join [%d]", i+1)),
+			text(step.method.String() + " " + joinDetail(step)),
+		})
+	}
+	if _, agg, err := selectProjection(stmt, plan.schema); err != nil {
+		return nil, err
+	} else if agg != nil {
+		agg.chooseJoined(tree.Estimate.Rows)
+		rows = append(rows, []table.Value{text("Aggregate"), text(agg.method.String() + " " + agg.detail())})
+	}
+
+	// The cost is the joins', under any filter, sort and projection
+	cost := tree
+	for len(cost.Children) == 1 {
+		cost = cost.Children[0]
+	}
+	rows = append(rows, []table.Value{text("Estimated Cost"), text(fmt.Sprintf("%.2f", cost.Estimate.Cost))})
+	return &Result{Columns: []string{"Property", "Value"}, Rows: rows}, nil
+}
@@ -0,0 +1,192 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// setupJoinTables creates users, profiles sharing their ids, and orders
+// referring to users. Rows are inserted out of key order, so that a table
+// scan doesn't return them sorted.
+func setupJoinTables(t *testing.T, exec *Executor) {
+	t.Helper()
+	for _, sql := range []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE profiles (id INTEGER PRIMARY KEY, bio TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, total INTEGER)",
+		"INSERT INTO users VALUES (3, 'Carol')",
+		"INSERT INTO users VALUES (1, 'Alice')",
+		"INSERT INTO users VALUES (2, 'Bob')",
+		"INSERT INTO profiles VALUES (4, 'nobody')",
+		"INSERT INTO profiles VALUES (2, 'builder')",
+		"INSERT INTO profiles VALUES (3, 'cook')",
+		"INSERT INTO orders VALUES (10, 1, 5)",
+		"INSERT INTO orders VALUES (11, 1, 7)",
+		"INSERT INTO orders VALUES (12, 3, 9)",
+		"INSERT INTO orders VALUES (13, NULL, 1)",
+	} {
+		executeSQL(t, exec, sql)
+	}
+}
+
+// resultText renders result rows as "a b, c d" for comparison.
+func resultText(result *Result) string {
+	rows := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		values := make([]string, len(row))
+		for j, val := range row {
+			values[j] = val.String()
+		}
+		rows[i] = strings.Join(values, " ")
+	}
+	return strings.Join(rows, ", ")
+}
+
+func TestJoin(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupJoinTables(t, exec)
+
+	tests := []struct {
+		sql    string
+		method JoinMethod
+		want   string
+	}{
+		{
+			"SELECT users.id, name, bio FROM users JOIN profiles ON users.id = profiles.id",
+			MergeJoin, "2 Bob builder, 3 Carol cook",
+		},
+		{
+			"SELECT name, orders.id FROM users INNER JOIN orders ON orders.user_id = users.id ORDER BY orders.id",
+			HashJoin, "Alice 10, Alice 11, Carol 12",
+		},
+		{
+			"SELECT name, total FROM users JOIN orders ON orders.total > users.id * 3 ORDER BY total, name",
+			NestedLoopJoin, "Alice 5, Alice 7, Bob 7, Alice 9, Bob 9",
+		},
+		{
+			// The merge keeps the rows in key order for the next join
+			"SELECT bio, total FROM users JOIN profiles ON users.id = profiles.id " +
+				"JOIN orders ON users.id = orders.user_id WHERE total > 1",
+			MergeJoin, "cook 9",
+		},
+		{
+			// Extra conditions in ON are checked on each pair
+			"SELECT name, total FROM users JOIN orders ON users.id = orders.user_id AND total > 6 ORDER BY total",
+			HashJoin, "Alice 7, Carol 9",
+		},
+	}
+	for _, tt := range tests {
+		stmt := parseSQL(t, tt.sql).(*parser.SelectStatement)
+		plan, err := exec.planJoins(stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if method := plan.steps[0].method; method != tt.method {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.method, method)
+		}
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+
+	// SELECT * returns every table's columns, named without their table
+	result := executeSQL(t, exec, "SELECT * FROM users JOIN profiles ON users.id = profiles.id")
+	if got := strings.Join(result.Columns, ","); got != "id,name,id,bio" {
+		t.Errorf("expected columns id,name,id,bio, got %s", got)
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupJoinTables(t, exec)
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT id FROM users JOIN profiles ON users.id = profiles.id", "ambiguous column name: id"},
+		{"SELECT name FROM users JOIN profiles ON id = 1", "ambiguous column name: id"},
+		{"SELECT name FROM users JOIN missing ON users.id = missing.id", "table missing does not exist"},
+		{"SELECT name FROM users JOIN users ON users.id = users.id", "joined more than once"},
+		{"SELECT name FROM users JOIN orders ON users.id = orders.user_id FOR UPDATE", "FOR UPDATE"},
+		{"SELECT nope FROM users JOIN orders ON users.id = orders.user_id", "unknown column: nope"},
+	}
+	for _, tt := range tests {
+		_, err := exec.Execute(parseSQL(t, tt.sql))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.sql, tt.want, err)
+		}
+	}
+}
+
+func TestMergeJoinDuplicateKeys(t *testing.T) {
+	rows := func(keys ...int64) []table.Row {
+		var rows []table.Row
+		for i, k := range keys {
+			val := table.Value{Type: parser.TypeInteger, Integer: k}
+			if k < 0 {
+				val = table.Value{IsNull: true}
+			}
+			rows = append(rows, table.Row{ID: uint64(i), Values: []table.Value{val}})
+		}
+		return rows
+	}
+
+	var pairs []string
+	err := mergeJoin(rows(-1, 1, 1, 2, 4), rows(-1, 1, 2, 2, 3), 0, 0, func(l, r table.Row) error {
+		pairs = append(pairs, l.Values[0].String()+"="+r.Values[0].String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("mergeJoin failed: %v", err)
+	}
+	if got := strings.Join(pairs, " "); got != "1=1 1=1 2=2 2=2" {
+		t.Errorf("expected 1=1 1=1 2=2 2=2, got %s", got)
+	}
+}
+
+func TestExplainJoin(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupJoinTables(t, exec)
+
+	sql := "SELECT name FROM users JOIN profiles ON users.id = profiles.id JOIN orders ON users.id = orders.user_id"
+	result := executeSQL(t, exec, "EXPLAIN "+sql)
+	if got := planRow(t, result, "Access Method"); got != "Index Scan users using primary key" {
+		t.Errorf("expected users read by primary key, got %q", got)
+	}
+	if got := planRow(t, result, "Join [1]"); got != "Merge Join profiles on users.id = profiles.id" {
+		t.Errorf("unexpected first join: %q", got)
+	}
+	if got := planRow(t, result, "Join [2]"); got != "Hash Join orders on users.id = orders.user_id" {
+		t.Errorf("unexpected second join: %q", got)
+	}
+
+	result = executeSQL(t, exec, "EXPLAIN (FORMAT TREE) "+sql)
+	expected := []string{
+		"-> Project (name)",
+		"  -> Hash Join (orders on users.id = orders.user_id)",
+		"    -> Merge Join (profiles on users.id = profiles.id)",
+		"      -> Index Scan (users using primary key)",
+		"      -> Index Scan (profiles using primary key)",
+		"    -> Table Scan (orders)",
+	}
+	if len(result.Rows) != len(expected) {
+		t.Fatalf("expected %d lines, got:\n%s", len(expected), result)
+	}
+	for i, prefix := range expected {
+		if line := result.Rows[i][0].Text; !strings.HasPrefix(line, prefix+"  est. rows=") {
+			t.Errorf("line %d: expected %q..., got %q", i, prefix, line)
+		}
+	}
+
+	result = executeSQL(t, exec, "EXPLAIN (ANALYZE, FORMAT TREE) "+sql)
+	if line := result.Rows[1][0].Text; !strings.Contains(line, "Hash Join") || !strings.Contains(line, "actual rows=1 ") {
+		t.Errorf("expected the hash join to produce 1 row, got %q", line)
+	}
+}
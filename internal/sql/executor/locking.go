@@ -0,0 +1,91 @@
+// Package executor - Row locking
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Statements that change rows take an exclusive lock on each row first,
+// through the lock manager (see internal/lock):
+//
+//   INSERT                 the new row
+//   UPDATE / DELETE        every row matching WHERE
+//   SELECT ... FOR UPDATE  every row returned
+//
+// Inside BEGIN ... COMMIT the locks belong to the transaction and are kept
+// until COMMIT or ROLLBACK. In autocommit mode each statement is its own
+// transaction, so its locks are released when it finishes.
+//
+// A lock only conflicts with locks taken by another owner. The sessions
+// of a database (see NewSession) share its lock manager; executors that
+// work on the same tables otherwise must share one too, see
+// SetLockManager.
+//
+// When the lock manager reports that waiting for a row would deadlock,
+// the transaction asking for it is rolled back and the statement fails.
+// Its locks are released, so the transaction it was waiting on can carry
+// on; the client may retry from BEGIN.
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cabewaldrop/claude-db/internal/lock"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// SetLockManager makes the executor, and its database's other sessions,
+// take their row locks from m, so they conflict with those of other
+// executors using m.
+func (e *Executor) SetLockManager(m *lock.Manager) {
+	e.locks = m
+}
+
+// lockOwner returns the owner of locks taken now: the open transaction, or
+// in autocommit mode the current statement.
+func (e *Executor) lockOwner() lock.Owner {
+	if e.tx != nil {
+		return e.tx.owner
+	}
+	if e.stmtOwner == 0 {
+		e.stmtOwner = e.locks.NewOwner()
+	}
+	return e.stmtOwner
+}
+
+// lockRows takes exclusive locks on rows, waiting for other owners to
+// release them, or until the statement is canceled. On deadlock the open
+// transaction is rolled back.
+func (e *Executor) lockRows(tableName string, rows []table.Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	owner := e.lockOwner()
+	for _, row := range rows {
+		target := lock.Row{Table: tableName, RowID: row.ID}
+		err := e.locks.Acquire(ctx, owner, target, lock.Exclusive)
+		if errors.Is(err, lock.ErrDeadlock) && e.tx != nil {
+			if abortErr := e.abort(); abortErr != nil {
+				return fmt.Errorf("%w; rollback failed: %v", err, abortErr)
+			}
+			return fmt.Errorf("%w; transaction rolled back", err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseStatementLocks ends an autocommit statement's hold on its rows.
+// In a batch the locks are kept until the batch ends (see batch.go).
+func (e *Executor) releaseStatementLocks() {
+	if e.stmtOwner != 0 && !e.inBatch {
+		e.locks.ReleaseAll(e.stmtOwner)
+		e.stmtOwner = 0
+	}
+}
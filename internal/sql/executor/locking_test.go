@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/lock"
+)
+
+// lockedRows returns the rows locked by the executor's open transaction.
+func lockedRows(exec *Executor) map[lock.Row]lock.Mode {
+	return exec.locks.Held(exec.tx.owner)
+}
+
+func TestTransactionHoldsRowLocks(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	for _, sql := range []string{
+		"INSERT INTO users VALUES (1, 'Alice')",
+		"INSERT INTO users VALUES (2, 'Bob')",
+		"INSERT INTO users VALUES (3, 'Carol')",
+	} {
+		executeSQL(t, exec, sql)
+	}
+
+	// Autocommit statements release their locks when they finish
+	executeSQL(t, exec, "SELECT * FROM users FOR UPDATE")
+	if exec.stmtOwner != 0 {
+		t.Error("expected autocommit statement locks to be released")
+	}
+
+	executeSQL(t, exec, "BEGIN")
+	result := executeSQL(t, exec, "SELECT * FROM users WHERE id >= 2 FOR UPDATE")
+	if len(result.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(result.Rows))
+	}
+	executeSQL(t, exec, "INSERT INTO users VALUES (4, 'Dave')")
+
+	held := lockedRows(exec)
+	if len(held) != 3 {
+		t.Errorf("expected 3 locked rows, got %v", held)
+	}
+	for row, mode := range held {
+		if row.Table != "users" || mode != lock.Exclusive {
+			t.Errorf("unexpected lock %s %s", row, mode)
+		}
+	}
+
+	owner := exec.tx.owner
+	executeSQL(t, exec, "COMMIT")
+	if held := exec.locks.Held(owner); len(held) != 0 {
+		t.Errorf("expected locks released at COMMIT, still held %v", held)
+	}
+}
+
+func TestRowLocksConflictAcrossExecutors(t *testing.T) {
+	locks := lock.NewManager()
+	sessionA, pagerA := openCatalogExecutor(t, filepath.Join(t.TempDir(), "a.db"))
+	defer pagerA.Close()
+	sessionB, pagerB := openCatalogExecutor(t, filepath.Join(t.TempDir(), "b.db"))
+	defer pagerB.Close()
+
+	// Two sessions over the same rows, sharing one lock manager
+	for _, exec := range []*Executor{sessionA, sessionB} {
+		exec.SetLockManager(locks)
+		executeSQL(t, exec, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER)")
+		executeSQL(t, exec, "INSERT INTO accounts VALUES (1, 100)")
+		executeSQL(t, exec, "INSERT INTO accounts VALUES (2, 100)")
+	}
+
+	executeSQL(t, sessionA, "BEGIN")
+	executeSQL(t, sessionA, "SELECT * FROM accounts WHERE id = 1 FOR UPDATE")
+
+	// A different row is not blocked
+	executeSQL(t, sessionB, "UPDATE accounts SET balance = 50 WHERE id = 2")
+
+	update := parseSQL(t, "UPDATE accounts SET balance = 50 WHERE id = 1")
+	done := make(chan error, 1)
+	go func() {
+		_, err := sessionB.Execute(update)
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("UPDATE of a row locked FOR UPDATE did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	executeSQL(t, sessionA, "COMMIT")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("UPDATE failed after waiting: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("UPDATE still waiting after the lock holder committed")
+	}
+}
+
+func TestExplainForUpdate(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	result := executeSQL(t, exec, "EXPLAIN (FORMAT TREE) SELECT name FROM users LIMIT 1 FOR UPDATE")
+
+	if len(result.Rows) < 2 {
+		t.Fatalf("expected a plan tree, got %v", result.Rows)
+	}
+	if line := result.Rows[1][0].Text; !strings.Contains(line, "-> Lock Rows (users)") {
+		t.Errorf("expected Lock Rows under Project, got %q", line)
+	}
+}
+
+func TestDeadlockAbortsTransaction(t *testing.T) {
+	locks := lock.NewManager()
+	sessionA, pagerA := openCatalogExecutor(t, filepath.Join(t.TempDir(), "a.db"))
+	defer pagerA.Close()
+	sessionB, pagerB := openCatalogExecutor(t, filepath.Join(t.TempDir(), "b.db"))
+	defer pagerB.Close()
+
+	for _, exec := range []*Executor{sessionA, sessionB} {
+		exec.SetLockManager(locks)
+		executeSQL(t, exec, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER)")
+		executeSQL(t, exec, "INSERT INTO accounts VALUES (1, 100)")
+		executeSQL(t, exec, "INSERT INTO accounts VALUES (2, 100)")
+		executeSQL(t, exec, "BEGIN")
+	}
+
+	executeSQL(t, sessionA, "SELECT * FROM accounts WHERE id = 1 FOR UPDATE")
+	executeSQL(t, sessionB, "SELECT * FROM accounts WHERE id = 2 FOR UPDATE")
+
+	// B waits for A's row 1
+	lockB := parseSQL(t, "SELECT * FROM accounts WHERE id = 1 FOR UPDATE")
+	ownerB := sessionB.tx.owner
+	done := make(chan error, 1)
+	go func() {
+		_, err := sessionB.Execute(lockB)
+		done <- err
+	}()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, waiting := locks.Waiting(ownerB); waiting {
+			break
+		}
+	}
+
+	// A asking for B's row 2 closes the cycle: A is rolled back
+	_, err := sessionA.Execute(parseSQL(t, "SELECT * FROM accounts WHERE id = 2 FOR UPDATE"))
+	if !errors.Is(err, lock.ErrDeadlock) {
+		t.Fatalf("expected deadlock error, got %v", err)
+	}
+	if sessionA.InTransaction() {
+		t.Error("expected the deadlock victim's transaction to be rolled back")
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("surviving transaction failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("surviving transaction still waiting after the victim was aborted")
+	}
+	executeSQL(t, sessionB, "COMMIT")
+}
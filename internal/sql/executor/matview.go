@@ -0,0 +1,235 @@
+// Package executor - Materialized views
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A view gives a query a name, so it can be read like a table. A plain
+// view, such as the information_schema views (see infoschema.go), stores
+// nothing: its query runs each time it is read, so it is always up to
+// date but costs the whole query every time. A materialized view stores
+// the query's rows in a table of their own:
+//
+//   CREATE MATERIALIZED VIEW customer_totals AS
+//     SELECT customer, COUNT(*) AS orders, SUM(total) AS spent
+//     FROM orders GROUP BY customer;
+//   SELECT * FROM customer_totals WHERE spent > 1000;
+//   REFRESH MATERIALIZED VIEW customer_totals;
+//
+// Reading it is reading a table: the planner sees its rows and statistics
+// like any other table's, and can use an index created on it, however
+// expensive the query was. The price is that its rows are a snapshot,
+// taken when it was created or last refreshed: orders placed since don't
+// show until REFRESH MATERIALIZED VIEW runs the query again and replaces
+// the rows. So a materialized view suits a costly query over data that
+// changes rarely, or whose readers can live with an answer some minutes
+// old, such as a dashboard.
+//
+// The rows can only change by REFRESH: INSERT, UPDATE, DELETE and ALTER
+// TABLE refuse a materialized view, or its rows would no longer be those
+// of its query. Like a trigger's, its query is kept in the catalog as the
+// statement that created it and parsed again when the database is opened.
+//
+// REFRESH here deletes every row and inserts the new ones. PostgreSQL
+// builds the new rows in a new table and swaps it in, or with REFRESH ...
+// CONCURRENTLY compares old and new rows so that readers aren't blocked
+// while it runs. SQLite and MySQL have no materialized views; a table
+// emptied and filled again by INSERT ... SELECT does the same job by hand.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// materializedView is a materialized view compiled from its CREATE
+// MATERIALIZED VIEW statement. Its rows are in the table of the same name.
+type materializedView struct {
+	name  string
+	sql   string // The CREATE MATERIALIZED VIEW statement
+	query *parser.SelectStatement
+}
+
+// compileView parses a CREATE MATERIALIZED VIEW statement.
+func compileView(sql string) (*materializedView, error) {
+	stmt, err := parser.New(lexer.New(sql)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	create, ok := stmt.(*parser.CreateMaterializedViewStatement)
+	if !ok {
+		return nil, fmt.Errorf("not a CREATE MATERIALIZED VIEW statement: %s", sql)
+	}
+	return &materializedView{name: strings.ToLower(create.Name), sql: sql, query: create.Query}, nil
+}
+
+// loadViews compiles the materialized views recorded in the catalog.
+func (e *Executor) loadViews() error {
+	for _, tableName := range e.catalog.ListTables() {
+		info, _ := e.catalog.GetTableInfo(tableName)
+		if info.ViewSQL == "" {
+			continue
+		}
+		v, err := compileView(info.ViewSQL)
+		if err != nil {
+			return fmt.Errorf("failed to load materialized view %s: %w", tableName, err)
+		}
+		if e.views == nil {
+			e.views = make(map[string]*materializedView)
+		}
+		e.views[v.name] = v
+	}
+	return nil
+}
+
+// checkNotView returns an error if a table holds a materialized view's
+// rows, which only REFRESH may change.
+func (e *Executor) checkNotView(tableName, action string) error {
+	if _, ok := e.views[tableName]; ok {
+		return fmt.Errorf("cannot %s materialized view %s: its rows change only by REFRESH MATERIALIZED VIEW", action, tableName)
+	}
+	return nil
+}
+
+// executeCreateView handles CREATE MATERIALIZED VIEW statements: it runs
+// the query and stores its rows in a new table, whose columns are named
+// after the query's and typed after their values.
+func (e *Executor) executeCreateView(stmt *parser.CreateMaterializedViewStatement) (*Result, error) {
+	name := strings.ToLower(stmt.Name)
+	if strings.Contains(name, ".") {
+		return nil, fmt.Errorf("cannot create materialized view %s: only the main database can have materialized views", name)
+	}
+	if _, exists := e.lookupTable(name); exists || isSystemView(name) || e.isVirtualTable(name) {
+		return nil, fmt.Errorf("table %s already exists", name)
+	}
+	if parser.CountPlaceholders(stmt.Query) > 0 {
+		return nil, fmt.Errorf("materialized view %s: its query can't have parameters", name)
+	}
+
+	rows, err := e.executeSelect(stmt.Query)
+	if err != nil {
+		return nil, fmt.Errorf("materialized view %s: %w", name, err)
+	}
+	columns := make([]parser.ColumnDefinition, len(rows.Columns))
+	seen := make(map[string]bool, len(rows.Columns))
+	for i, col := range rows.Columns {
+		col = strings.ToLower(col)
+		if !isPlainName(col) {
+			return nil, fmt.Errorf("materialized view %s: column %s needs a name, given with AS", name, col)
+		}
+		if seen[col] {
+			return nil, fmt.Errorf("materialized view %s: column %s appears twice", name, col)
+		}
+		seen[col] = true
+		columns[i] = parser.ColumnDefinition{Name: col, Type: parser.TypeText}
+		for _, row := range rows.Rows {
+			if !row[i].IsNull {
+				columns[i].Type = row[i].Type
+				break
+			}
+		}
+	}
+
+	if _, err := e.executeCreateTable(&parser.CreateTableStatement{Table: name, Columns: columns}); err != nil {
+		return nil, err
+	}
+	v := &materializedView{name: name, sql: stmt.String(), query: stmt.Query}
+	if e.catalog != nil {
+		if err := e.catalog.SetView(name, v.sql); err != nil {
+			return nil, fmt.Errorf("failed to save materialized view: %w", err)
+		}
+	}
+	if e.views == nil {
+		e.views = make(map[string]*materializedView)
+	}
+	e.views[name] = v
+
+	if err := e.fillView(e.tables[name], rows.Rows); err != nil {
+		return nil, err
+	}
+	return &Result{
+		Message:  fmt.Sprintf("Materialized view '%s' created (%d rows)", name, len(rows.Rows)),
+		RowCount: len(rows.Rows),
+	}, nil
+}
+
+// isPlainName reports whether a result column's name can name a table's
+// column: a bare identifier, not an expression or a qualified name.
+func isPlainName(name string) bool {
+	for i, r := range name {
+		letter := r == '_' || r >= 'a' && r <= 'z'
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// executeRefreshView handles REFRESH MATERIALIZED VIEW statements.
+func (e *Executor) executeRefreshView(stmt *parser.RefreshMaterializedViewStatement) (*Result, error) {
+	name := strings.ToLower(stmt.Name)
+	v, ok := e.views[name]
+	if !ok {
+		return nil, fmt.Errorf("materialized view %s does not exist", name)
+	}
+	tbl := e.tables[name]
+
+	rows, err := e.executeSelect(v.query)
+	if err != nil {
+		return nil, fmt.Errorf("materialized view %s: %w", name, err)
+	}
+	if len(rows.Columns) != len(tbl.Schema.Columns) {
+		return nil, fmt.Errorf("materialized view %s: its query now gives %d columns, expected %d",
+			name, len(rows.Columns), len(tbl.Schema.Columns))
+	}
+
+	if _, err := tbl.Delete(func(table.Row) bool { return true }); err != nil {
+		return nil, fmt.Errorf("failed to refresh materialized view %s: %w", name, err)
+	}
+	if err := e.fillView(tbl, rows.Rows); err != nil {
+		return nil, err
+	}
+	return &Result{
+		Message:  fmt.Sprintf("Materialized view '%s' refreshed (%d rows)", name, len(rows.Rows)),
+		RowCount: len(rows.Rows),
+	}, nil
+}
+
+// fillView inserts a materialized view's rows into its table.
+func (e *Executor) fillView(tbl *table.Table, rows [][]table.Value) error {
+	for _, row := range rows {
+		values := make([]table.Value, len(row))
+		for i, val := range row {
+			var err error
+			if values[i], err = e.coerceToColumn(val, tbl.Schema.Columns[i]); err != nil {
+				return fmt.Errorf("materialized view %s: %w", tbl.Name, err)
+			}
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			return fmt.Errorf("materialized view %s: %w", tbl.Name, err)
+		}
+	}
+	e.autoAnalyze(tbl)
+	return nil
+}
+
+// executeDropView handles DROP MATERIALIZED VIEW statements.
+func (e *Executor) executeDropView(stmt *parser.DropMaterializedViewStatement) (*Result, error) {
+	name := strings.ToLower(stmt.Name)
+	v, ok := e.views[name]
+	if !ok {
+		return nil, fmt.Errorf("materialized view %s does not exist", name)
+	}
+
+	delete(e.views, name)
+	if _, err := e.executeDropTable(&parser.DropTableStatement{Table: name}); err != nil {
+		e.views[name] = v
+		return nil, err
+	}
+	return &Result{
+		Message: fmt.Sprintf("Materialized view '%s' dropped", name),
+	}, nil
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMaterializedViews(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "views.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total INTEGER)")
+	executeSQL(t, exec, "INSERT INTO orders VALUES (1, 'ann', 10)")
+	executeSQL(t, exec, "INSERT INTO orders VALUES (2, 'bob', 20)")
+	executeSQL(t, exec, "INSERT INTO orders VALUES (3, 'ann', 5)")
+	result := executeSQL(t, exec, `CREATE MATERIALIZED VIEW totals AS
+		SELECT customer, COUNT(*) AS orders, SUM(total) AS spent FROM orders GROUP BY customer`)
+	if result.RowCount != 2 {
+		t.Errorf("expected 2 rows materialized, got %d", result.RowCount)
+	}
+	executeSQL(t, exec, "CREATE INDEX idx_totals_customer ON totals (customer)")
+
+	const query = "SELECT customer, orders, spent FROM totals ORDER BY customer"
+	if got := resultText(executeSQL(t, exec, query)); got != "ann 2 15, bob 1 20" {
+		t.Errorf("unexpected totals %q", got)
+	}
+
+	// The view is a snapshot: new orders show only after REFRESH
+	executeSQL(t, exec, "INSERT INTO orders VALUES (4, 'cy', 7)")
+	if got := resultText(executeSQL(t, exec, query)); got != "ann 2 15, bob 1 20" {
+		t.Errorf("expected the view unchanged before REFRESH, got %q", got)
+	}
+	executeSQL(t, exec, "REFRESH MATERIALIZED VIEW totals")
+	if got := resultText(executeSQL(t, exec, "SELECT spent FROM totals WHERE customer = 'cy'")); got != "7" {
+		t.Errorf("expected the new customer after REFRESH, got %q", got)
+	}
+
+	// The view survives reopening, and can still be refreshed
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	executeSQL(t, exec, "DELETE FROM orders WHERE customer = 'bob'")
+	executeSQL(t, exec, "VACUUM")
+	executeSQL(t, exec, "REFRESH MATERIALIZED VIEW totals")
+	if got := resultText(executeSQL(t, exec, query)); got != "ann 2 15, cy 1 7" {
+		t.Errorf("unexpected totals after reopening %q", got)
+	}
+	ddl, _ := exec.TableDDL("totals")
+	if len(ddl) != 2 || !strings.HasPrefix(ddl[0], "CREATE MATERIALIZED VIEW totals AS SELECT customer") {
+		t.Errorf("unexpected DDL %q", ddl)
+	}
+
+	// Rolling back undoes a DROP MATERIALIZED VIEW
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "DROP MATERIALIZED VIEW totals")
+	executeSQL(t, exec, "ROLLBACK")
+	if _, err := exec.Execute(parseSQL(t, "DELETE FROM totals")); err == nil {
+		t.Error("expected totals to be a materialized view again after ROLLBACK")
+	}
+	executeSQL(t, exec, "DROP MATERIALIZED VIEW totals")
+	executeSQL(t, exec, "CREATE TABLE totals (id INTEGER PRIMARY KEY)")
+	executeSQL(t, exec, "INSERT INTO totals VALUES (1)")
+}
+
+func TestMaterializedViewErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY, total INTEGER)")
+	executeSQL(t, exec, "CREATE MATERIALIZED VIEW big AS SELECT id, total FROM orders WHERE total > 100")
+
+	for sql, want := range map[string]string{
+		"INSERT INTO big VALUES (1, 2)":                                        "cannot insert into materialized view big",
+		"UPDATE big SET total = 0":                                             "cannot update materialized view big",
+		"DELETE FROM big":                                                      "cannot delete from materialized view big",
+		"ALTER TABLE big ADD COLUMN note TEXT":                                 "cannot alter materialized view big",
+		"DROP TABLE big":                                                       "DROP MATERIALIZED VIEW",
+		"CREATE TRIGGER t AFTER INSERT ON big BEGIN SELECT 1; END":             "materialized view big",
+		"CREATE MATERIALIZED VIEW orders AS SELECT 1 AS one":                   "already exists",
+		"CREATE MATERIALIZED VIEW sums AS SELECT SUM(total) FROM orders":       "needs a name",
+		"CREATE MATERIALIZED VIEW twice AS SELECT id, total AS id FROM orders": "appears twice",
+		"REFRESH MATERIALIZED VIEW orders":                                     "does not exist",
+		"DROP MATERIALIZED VIEW orders":                                        "does not exist",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package executor - Query memory limits
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Most operators stream their rows and hold almost nothing, but some have
+// to keep rows in memory: a sort keeps its input until it has seen the
+// last row, a hash join or hash aggregate keeps its hash table, and
+// Execute keeps the whole result to return it. A careless query such as
+//
+//   SELECT * FROM big JOIN other ON big.x = other.x
+//
+// could hold more rows than the machine has memory, and the process would
+// be killed, taking every other session with it. So each statement gets a
+// memory account: operators reserve an estimate of each row or hash table
+// entry they keep (see rowMemory), give it back when they let go of it,
+// and the statement fails with ErrMemoryBudgetExceeded as soon as the
+// total passes the limit set with SetQueryMemory.
+//
+// The estimates are rough: Go doesn't say how much memory a value takes,
+// and counting exactly would cost more than it saves. They only need to
+// grow with the real thing, so that the limit stops a query that would
+// use far more than expected. A sort spills to disk before it reaches the
+// limit (see sort.go); the other operators have nowhere to put their
+// rows, and fail. This is what DuckDB's memory_limit and SQL Server's
+// memory grants do; PostgreSQL's work_mem only limits each operator.
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+const (
+	// defaultQueryMemory is how many bytes a statement may hold in memory.
+	defaultQueryMemory = 256 << 20
+
+	// hashEntryMemory is roughly the memory an entry of a hash table takes,
+	// besides its key and the row it holds.
+	hashEntryMemory = 48
+)
+
+// ErrMemoryBudgetExceeded is returned by a statement that needs more
+// memory than SetQueryMemory allows.
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+// SetQueryMemory sets how many bytes of rows and hash tables a statement
+// may hold in memory; 0 or less means no limit.
+func (e *Executor) SetQueryMemory(bytes int) {
+	e.queryMemory = bytes
+}
+
+// memoryAccount counts the memory a statement holds.
+type memoryAccount struct {
+	limit int // 0 for no limit
+	used  int
+}
+
+// withMemoryAccount makes m the account of the statement being executed,
+// and returns a function restoring the one before.
+func (e *Executor) withMemoryAccount(m *memoryAccount) func() {
+	prev := e.memory
+	e.memory = m
+	return func() { e.memory = prev }
+}
+
+// newMemoryAccount returns an account for a new statement.
+func (e *Executor) newMemoryAccount() *memoryAccount {
+	return &memoryAccount{limit: e.queryMemory}
+}
+
+// reserve adds bytes held by what to the statement's account, returning
+// an error if that takes it past its limit.
+func (e *Executor) reserve(bytes int, what string) error {
+	m := e.memory
+	if m == nil {
+		return nil
+	}
+	m.used += bytes
+	if m.limit > 0 && m.used > m.limit {
+		return fmt.Errorf("%w: %s needs more than the %d bytes a query may use", ErrMemoryBudgetExceeded, what, m.limit)
+	}
+	return nil
+}
+
+// reserveRows reserves the memory of rows held by what.
+func (e *Executor) reserveRows(rows []table.Row, what string) error {
+	return e.reserve(rowsMemory(rows), what)
+}
+
+// rowsMemory estimates the memory rows take.
+func rowsMemory(rows []table.Row) int {
+	size := 0
+	for _, row := range rows {
+		size += rowMemory(row)
+	}
+	return size
+}
+
+// release gives back bytes reserved earlier.
+func (e *Executor) release(bytes int) {
+	if e.memory != nil {
+		e.memory.used -= bytes
+	}
+}
@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestQueryMemory(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 300)
+	setupJoinTables(t, exec)
+	exec.SetQueryMemory(20000) // about 80 rows of items
+
+	// exceeds runs a statement that should need more memory than allowed,
+	// checking the error names the operator that did
+	exceeds := func(sql, what string) {
+		t.Helper()
+		_, err := exec.Execute(parseSQL(t, sql))
+		if !errors.Is(err, ErrMemoryBudgetExceeded) || !strings.Contains(err.Error(), what) {
+			t.Errorf("%s: expected the %s to exceed the memory budget, got %v", sql, what, err)
+		}
+	}
+	exceeds("SELECT * FROM items", "result")
+	exceeds("SELECT * FROM items JOIN users ON items.price = users.id", "join input")
+	exceeds("UPDATE items SET price = 0", "rows to change")
+
+	// Streaming operators hold next to nothing, and each statement starts
+	// with nothing reserved
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*), SUM(price) FROM items")); got != "300 1350" {
+		t.Errorf("expected 300 1350, got %s", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM items WHERE price = 0")); got != "30" {
+		t.Errorf("expected the failed UPDATE to change nothing, got %s", got)
+	}
+	if got := executeSQL(t, exec, "SELECT * FROM items LIMIT 10"); got.RowCount != 10 {
+		t.Errorf("expected 10 rows, got %d", got.RowCount)
+	}
+
+	// Reading rows one at a time, the sort and hash table still count
+	count := func(sql string) (int, error) {
+		t.Helper()
+		rows, err := exec.Query(parseSQL(t, sql))
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		return n, rows.Err()
+	}
+	if _, err := count("SELECT id, COUNT(*) FROM items GROUP BY id"); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Errorf("expected the hash aggregate to exceed the memory budget, got %v", err)
+	}
+	exec.SetSortMemory(0)
+	if _, err := count("SELECT * FROM items ORDER BY price"); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Errorf("expected the sort to exceed the memory budget, got %v", err)
+	}
+
+	// A sort that spills to disk gives its memory back
+	exec.SetSortMemory(4096)
+	if n, err := count("SELECT * FROM items ORDER BY price"); err != nil || n != 300 {
+		t.Errorf("expected 300 sorted rows, got %d and %v", n, err)
+	}
+
+	exec.SetQueryMemory(0)
+	if got := executeSQL(t, exec, "SELECT * FROM items"); got.RowCount != 300 {
+		t.Errorf("expected 300 rows without a limit, got %d", got.RowCount)
+	}
+}
+
+func TestQueryMemoryJoinInputStopsEarly(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupJoinTables(t, exec)
+	executeSQL(t, exec, "CREATE TABLE big (id INTEGER PRIMARY KEY, note TEXT)")
+	executeSQL(t, exec, "BEGIN")
+	for i := 1; i <= 3000; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO big VALUES (%d, 'row %d, long enough to take up some room')", i, i))
+	}
+	executeSQL(t, exec, "COMMIT")
+	join := parseSQL(t, "SELECT * FROM big JOIN users ON big.id = users.id")
+	pagesRead := func() uint64 {
+		t.Helper()
+		before := exec.pager.IOStats().PageRequests
+		_, err := exec.Execute(join)
+		if err != nil && !errors.Is(err, ErrMemoryBudgetExceeded) {
+			t.Fatalf("join failed: %v", err)
+		}
+		return exec.pager.IOStats().PageRequests - before
+	}
+	whole := pagesRead()
+
+	// The join input fails at the row over the budget, having read only
+	// the pages before it, rather than reading the whole table first
+	exec.SetQueryMemory(20000)
+	if _, err := exec.Execute(join); !errors.Is(err, ErrMemoryBudgetExceeded) || !strings.Contains(err.Error(), "join input") {
+		t.Fatalf("expected the join input to exceed the memory budget, got %v", err)
+	}
+	if read := pagesRead(); read >= whole/4 {
+		t.Errorf("expected the join to stop reading early, read %d pages of the %d of the whole join", read, whole)
+	}
+
+	// So does an unordered scan of it
+	if _, err := exec.Execute(parseSQL(t, "SELECT * FROM big JOIN users ON big.note = users.name")); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Errorf("expected the hash join's input to exceed the memory budget, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Package executor - Execution metrics
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// EXPLAIN ANALYZE breaks one statement down step by step, but has to be
+// asked for. Every Result also carries the totals of how its statement
+// ran, cheaply enough to measure always:
+//
+//   RowsScanned   rows read from tables, before WHERE kept or dropped them
+//   PagesRead     pages requested from the pager ("logical reads")
+//   IndexUsed     the indexes the planner chose to read through, if any
+//   Duration      wall-clock time, waiting for other sessions included
+//   PlanDuration  the part of Duration spent choosing access paths
+//
+// Their ratios are what to watch: a query returning 10 rows that scanned
+// a million wants an index, and one reading many pages per row scanned
+// is following an index to rows scattered over the table. MySQL's slow
+// query log reports Rows_examined for the same reason, and PostgreSQL's
+// pg_stat_statements adds up the blocks each query read.
+//
+// A statement run by another (a trigger's) counts towards the one that
+// ran it. The pager is shared by the sessions of a database, so the pages
+// a statement reads include those that sessions reading alongside it
+// read meanwhile.
+
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// statementMetrics counts what the statement being executed reads.
+type statementMetrics struct {
+	scanned  int
+	indexes  []string
+	planning time.Duration
+}
+
+// measure starts the metrics of a statement and returns a function that
+// fills them in on the statement's result, if it has one, when it ends.
+func (e *Executor) measure(result **Result) func() {
+	prev := e.metrics
+	m := &statementMetrics{}
+	e.metrics = m
+	start, pages := time.Now(), e.pager.IOStats().PageRequests
+	return func() {
+		e.metrics = prev
+		if prev != nil {
+			prev.scanned += m.scanned
+			prev.planning += m.planning
+			for _, name := range m.indexes {
+				prev.useIndex(name)
+			}
+		}
+		if r := *result; r != nil {
+			r.RowsScanned = m.scanned
+			r.PagesRead = e.pager.IOStats().PageRequests - pages
+			r.IndexUsed = strings.Join(m.indexes, ", ")
+			r.Duration = time.Since(start)
+			r.PlanDuration = m.planning
+		}
+	}
+}
+
+// scanned counts rows read from a table by the statement.
+func (e *Executor) scanned(rows int) {
+	if e.metrics != nil {
+		e.metrics.scanned += rows
+	}
+}
+
+// planned adds the time since start to the statement's planning time.
+func (e *Executor) planned(start time.Time) {
+	if e.metrics != nil {
+		e.metrics.planning += time.Since(start)
+	}
+}
+
+// usedIndex records that the statement reads through an index.
+func (e *Executor) usedIndex(name string) {
+	if e.metrics != nil {
+		e.metrics.useIndex(name)
+	}
+}
+
+// useIndex adds an index to those used, once.
+func (m *statementMetrics) useIndex(name string) {
+	if !slices.Contains(m.indexes, name) {
+		m.indexes = append(m.indexes, name)
+	}
+}
+
+// Metrics describes how the statement ran, in a line for display after
+// its result.
+func (r *Result) Metrics() string {
+	line := fmt.Sprintf("rows scanned: %d, pages read: %d", r.RowsScanned, r.PagesRead)
+	if r.IndexUsed != "" {
+		line += ", index: " + r.IndexUsed
+	}
+	return line + ", time: " + formatDuration(r.Duration)
+}
+
+// Timing breaks down how long the statement took, given how long it took
+// to parse, for display after its result.
+func (r *Result) Timing(parse time.Duration) string {
+	return fmt.Sprintf("parse: %s, plan: %s, execute: %s",
+		formatDuration(parse), formatDuration(r.PlanDuration), formatDuration(r.Duration-r.PlanDuration))
+}
@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultMetrics(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_age ON users (age)")
+	for i := 1; i <= 20; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d', %d)", i, i, i%5))
+	}
+
+	// A scan reads every row, whatever WHERE keeps
+	result := executeSQL(t, exec, "SELECT name FROM users WHERE name = 'user3'")
+	if result.RowsScanned != 20 || result.PagesRead == 0 || result.IndexUsed != "" || result.Duration <= 0 {
+		t.Errorf("expected a scan of 20 rows, got %d rows, %d pages, index %q, %s",
+			result.RowsScanned, result.PagesRead, result.IndexUsed, result.Duration)
+	}
+
+	// Lookups read only the rows they find
+	result = executeSQL(t, exec, "SELECT name FROM users WHERE id = 3")
+	if result.RowsScanned != 1 || result.IndexUsed != "primary key" {
+		t.Errorf("expected 1 row by the primary key, got %d rows, index %q", result.RowsScanned, result.IndexUsed)
+	}
+	result = executeSQL(t, exec, "SELECT name FROM users WHERE age = 2")
+	if result.RowsScanned != 4 || result.IndexUsed != "idx_age" {
+		t.Errorf("expected 4 rows by idx_age, got %d rows, index %q", result.RowsScanned, result.IndexUsed)
+	}
+	if line := result.Metrics(); !strings.Contains(line, "rows scanned: 4") || !strings.Contains(line, "index: idx_age") {
+		t.Errorf("unexpected metrics line %q", line)
+	}
+
+	// Planning is part of the time taken
+	if result.PlanDuration <= 0 || result.PlanDuration > result.Duration {
+		t.Errorf("expected planning to take part of %s, got %s", result.Duration, result.PlanDuration)
+	}
+	if timing := result.Timing(time.Millisecond); !strings.HasPrefix(timing, "parse: 1.000 ms, plan: ") || !strings.Contains(timing, ", execute: ") {
+		t.Errorf("unexpected timing line %q", timing)
+	}
+
+	// UPDATE counts the rows it scans to find those to change
+	result = executeSQL(t, exec, "UPDATE users SET name = 'x' WHERE age = 0")
+	if result.RowsScanned != 20 {
+		t.Errorf("expected the UPDATE to scan 20 rows, got %d", result.RowsScanned)
+	}
+}
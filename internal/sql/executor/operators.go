@@ -0,0 +1,535 @@
+// Package executor - Query operators
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A SELECT runs as a pipeline of operators, each pulling rows one at a
+// time from the operator below it (the "volcano" or iterator model, after
+// Graefe's Volcano system):
+//
+//   Project    <- Next() -> computes the select list of one row
+//     Limit    <- Next() -> skips OFFSET rows, stops after LIMIT
+//       Sort   <- Next() -> reads all its input first, then returns in order
+//         Scan <- Next() -> reads the next row matching WHERE
+//
+// Nothing runs until the top operator is asked for a row, and each asks
+// its input for only as many rows as it needs. So "SELECT * FROM big
+// LIMIT 10" reads the first page or so of the table and stops, and rows
+// stream to the caller without ever all being in memory.
+//
+// Sort is the exception, a "pipeline breaker": it can't return its first
+// row before it has seen the last. With a LIMIT it only keeps the rows
+// that can still make the top K (see topKHeap), otherwise all of them,
+// on disk once they outgrow the sort's memory (see sort.go).
+// Joins still build their results in memory (see join.go), and the
+// operators above them read from that.
+
+package executor
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// rowIterator is the interface every operator implements, the same as
+// table.RowIterator: Next advances to the next row and reports whether
+// there is one, Row returns it, Err returns the error that stopped the
+// iteration, and Close releases the operator and its inputs.
+type rowIterator interface {
+	Next() bool
+	Row() table.Row
+	Err() error
+	Close() error
+}
+
+// sliceIterator returns rows already in memory.
+type sliceIterator struct {
+	rows []table.Row
+	row  table.Row
+}
+
+func (it *sliceIterator) Next() bool {
+	if len(it.rows) == 0 {
+		return false
+	}
+	it.row, it.rows = it.rows[0], it.rows[1:]
+	return true
+}
+
+func (it *sliceIterator) Row() table.Row { return it.row }
+func (it *sliceIterator) Err() error     { return nil }
+func (it *sliceIterator) Close() error   { it.rows = nil; return nil }
+
+// loadIterator returns the rows of load, called on the first Next. It
+// puts index lookups, which find all their rows at once, in a pipeline.
+type loadIterator struct {
+	load   func() ([]table.Row, error)
+	loaded bool
+	sliceIterator
+	err error
+}
+
+func (it *loadIterator) Next() bool {
+	if !it.loaded {
+		it.loaded = true
+		it.rows, it.err = it.load()
+	}
+	return it.err == nil && it.sliceIterator.Next()
+}
+
+func (it *loadIterator) Err() error { return it.err }
+
+// filterIterator returns the rows of its input for which a condition
+// holds.
+type filterIterator struct {
+	e      *Executor
+	input  rowIterator
+	cond   parser.Expression
+	schema *table.Schema
+	err    error
+}
+
+func (it *filterIterator) Next() bool {
+	for it.err == nil && it.input.Next() {
+		match, err := it.e.evaluateCondition(it.cond, it.input.Row(), it.schema)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *filterIterator) Row() table.Row { return it.input.Row() }
+
+func (it *filterIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.input.Err()
+}
+
+func (it *filterIterator) Close() error { return it.input.Close() }
+
+// sortIterator returns the rows of its input in ORDER BY order. If limit
+// is above 0, only the first limit rows are wanted. Without a limit, rows
+// beyond the memory budget are sorted on disk (see sort.go).
+type sortIterator struct {
+	e       *Executor
+	input   rowIterator
+	orderBy []parser.OrderByClause
+	schema  *table.Schema
+	limit   int
+	memory  int // bytes of rows to hold before spilling; 0 for no limit
+
+	sorted rowIterator // the rows in order, once the input is read
+	spill  *sortSpill  // the runs written to disk, if any
+	topK   bool        // the rows were selected by a heap
+	done   bool
+	err    error
+}
+
+func (it *sortIterator) Next() bool {
+	if !it.done {
+		it.done = true
+		if it.err = it.sort(); it.err != nil {
+			return false
+		}
+	}
+	return it.err == nil && it.sorted != nil && it.sorted.Next()
+}
+
+// sort reads the whole input. With a limit, a heap keeps the best rows
+// seen so far, and once more than limit rows have been read they are the
+// result; otherwise every row is kept and sorted, in runs on disk if
+// there are too many.
+func (it *sortIterator) sort() error {
+	h := &topKHeap{orderBy: it.orderBy, schema: it.schema}
+
+	seen, size := 0, 0
+	for it.input.Next() {
+		seen++
+		row := it.input.Row()
+		if err := it.e.reserve(rowMemory(row), "sort"); err != nil {
+			return err
+		}
+		if it.limit > 0 {
+			heap.Push(h, row)
+			if h.Len() > it.limit {
+				// Remove the worst element (largest for ASC, smallest for DESC)
+				it.e.release(rowMemory(heap.Pop(h).(table.Row)))
+			}
+			continue
+		}
+
+		h.rows = append(h.rows, row)
+		size += rowMemory(row)
+		if it.memory > 0 && size > it.memory {
+			if err := it.writeRun(h.rows); err != nil {
+				return err
+			}
+			it.e.release(size)
+			h.rows, size = nil, 0
+		}
+	}
+	if err := it.input.Err(); err != nil {
+		return err
+	}
+
+	if it.limit > 0 && seen > it.limit {
+		// Extract results in sorted order
+		rows := make([]table.Row, h.Len())
+		for i := h.Len() - 1; i >= 0; i-- {
+			rows[i] = heap.Pop(h).(table.Row)
+		}
+		it.sorted, it.topK = &sliceIterator{rows: rows}, true
+		return nil
+	}
+
+	if it.spill != nil {
+		if len(h.rows) > 0 {
+			if err := it.writeRun(h.rows); err != nil {
+				return err
+			}
+		}
+		it.sorted = newMergeIterator(it.spill, it.orderBy, it.schema)
+		return nil
+	}
+
+	it.sortRows(h.rows)
+	it.sorted = &sliceIterator{rows: h.rows}
+	return nil
+}
+
+// sortRows sorts rows in memory.
+func (it *sortIterator) sortRows(rows []table.Row) {
+	sort.Slice(rows, func(i, j int) bool {
+		return compareRows(rows[i], rows[j], it.orderBy, it.schema) < 0
+	})
+}
+
+// writeRun sorts rows and writes them to disk as a run.
+func (it *sortIterator) writeRun(rows []table.Row) error {
+	if it.spill == nil {
+		spill, err := newSortSpill(it.e.pager.Codec())
+		if err != nil {
+			return err
+		}
+		it.spill = spill
+	}
+	it.sortRows(rows)
+	return it.spill.writeRun(rows)
+}
+
+func (it *sortIterator) Row() table.Row { return it.sorted.Row() }
+
+func (it *sortIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.sorted != nil {
+		return it.sorted.Err()
+	}
+	return it.input.Err()
+}
+
+func (it *sortIterator) Close() error {
+	err := it.input.Close()
+	if it.sorted != nil {
+		it.sorted.Close()
+	}
+	if it.spill != nil {
+		if spillErr := it.spill.close(); err == nil {
+			err = spillErr
+		}
+		it.spill = nil
+	}
+	return err
+}
+
+// describe names the sort for plan output, noting a top-K selection or an
+// external merge.
+func (it *sortIterator) describe() string {
+	detail := orderByString(it.orderBy)
+	if it.topK {
+		detail += fmt.Sprintf(", top %d", it.limit)
+	}
+	if it.spill != nil {
+		detail += fmt.Sprintf(", external merge of %d runs", len(it.spill.runs))
+	}
+	return detail
+}
+
+// limitIterator skips the first offset rows of its input, then returns at
+// most limit rows (any number if limit < 0). It stops reading its input
+// as soon as it has returned them.
+type limitIterator struct {
+	input         rowIterator
+	offset, limit int
+}
+
+func (it *limitIterator) Next() bool {
+	if it.limit == 0 {
+		return false
+	}
+	for ; it.offset > 0; it.offset-- {
+		if !it.input.Next() {
+			return false
+		}
+	}
+	if !it.input.Next() {
+		return false
+	}
+	if it.limit > 0 {
+		it.limit--
+	}
+	return true
+}
+
+func (it *limitIterator) Row() table.Row { return it.input.Row() }
+func (it *limitIterator) Err() error     { return it.input.Err() }
+func (it *limitIterator) Close() error   { return it.input.Close() }
+
+// appendIterator returns the rows of its inputs, one input after the
+// other, such as the partitions of a partitioned table.
+type appendIterator struct {
+	inputs []rowIterator
+	err    error
+}
+
+func (it *appendIterator) Next() bool {
+	for it.err == nil && len(it.inputs) > 0 {
+		if it.inputs[0].Next() {
+			return true
+		}
+		it.err = it.inputs[0].Err()
+		if err := it.inputs[0].Close(); it.err == nil {
+			it.err = err
+		}
+		it.inputs = it.inputs[1:]
+	}
+	return false
+}
+
+func (it *appendIterator) Row() table.Row { return it.inputs[0].Row() }
+func (it *appendIterator) Err() error     { return it.err }
+
+func (it *appendIterator) Close() error {
+	var err error
+	for _, input := range it.inputs {
+		if closeErr := input.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	it.inputs = nil
+	return err
+}
+
+// lockIterator takes an exclusive lock on each row of its input before
+// returning it, for SELECT ... FOR UPDATE.
+type lockIterator struct {
+	e         *Executor
+	input     rowIterator
+	tableName string
+	err       error
+}
+
+func (it *lockIterator) Next() bool {
+	if it.err != nil || !it.input.Next() {
+		return false
+	}
+	it.err = it.e.lockRows(it.tableName, []table.Row{it.input.Row()})
+	return it.err == nil
+}
+
+func (it *lockIterator) Row() table.Row { return it.input.Row() }
+
+func (it *lockIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.input.Err()
+}
+
+func (it *lockIterator) Close() error { return it.input.Close() }
+
+// projectIterator computes the select list of each row of its input. The
+// rows it returns hold the output values, under the input row's ID.
+type projectIterator struct {
+	e          *Executor
+	input      rowIterator
+	projection []projectedColumn
+	schema     *table.Schema
+	row        table.Row
+	err        error
+}
+
+func (it *projectIterator) Next() bool {
+	if it.err != nil || !it.input.Next() {
+		return false
+	}
+	in := it.input.Row()
+	values, err := it.e.projectRow(it.projection, in, it.schema)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.row = table.Row{ID: in.ID, Values: values}
+	return true
+}
+
+func (it *projectIterator) Row() table.Row { return it.row }
+
+func (it *projectIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.input.Err()
+}
+
+func (it *projectIterator) Close() error { return it.input.Close() }
+
+// selectPipeline puts the operators of a SELECT's aggregation, ORDER BY,
+// OFFSET/LIMIT, FOR UPDATE and select list on top of input, the rows
+// matching its WHERE clause. ordered says input is in ORDER BY order
+// already, and needs no sort.
+func (e *Executor) selectPipeline(stmt *parser.SelectStatement, input rowIterator, schema *table.Schema,
+	projection []projectedColumn, agg *aggregatePlan, ordered bool) rowIterator {
+	it := input
+
+	if agg != nil {
+		// From here on, each row is a group
+		it = e.aggregateIterator(it, agg)
+		schema = agg.schema
+	}
+
+	if len(stmt.OrderBy) > 0 && !ordered {
+		// Calculate effective limit (including offset)
+		effectiveLimit := 0
+		if stmt.Limit != nil {
+			effectiveLimit = *stmt.Limit
+			if stmt.Offset != nil {
+				effectiveLimit += *stmt.Offset
+			}
+		}
+		sorter := &sortIterator{e: e, input: it, orderBy: stmt.OrderBy, schema: schema,
+			limit: effectiveLimit, memory: e.sortMemory}
+		it = e.traced(sorter, "Sort", orderByString(stmt.OrderBy))
+	}
+
+	if stmt.Limit != nil || stmt.Offset != nil {
+		limit := &limitIterator{input: it, limit: -1}
+		if stmt.Limit != nil {
+			limit.limit = *stmt.Limit
+		}
+		if stmt.Offset != nil {
+			limit.offset = *stmt.Offset
+		}
+		it = e.traced(limit, "Limit", limitString(stmt.Limit, stmt.Offset))
+	}
+
+	if stmt.ForUpdate {
+		tableName := strings.ToLower(stmt.From)
+		it = e.traced(&lockIterator{e: e, input: it, tableName: tableName}, "Lock Rows", tableName)
+	}
+
+	columns := make([]string, len(projection))
+	for i, col := range projection {
+		columns[i] = col.name
+	}
+	project := &projectIterator{e: e, input: it, projection: projection, schema: schema}
+	return e.traced(project, "Project", strings.Join(columns, ", "))
+}
+
+// collectResult runs a pipeline to the end, returning its rows as the
+// result of a query.
+func (e *Executor) collectResult(it rowIterator, projection []projectedColumn) (*Result, error) {
+	defer it.Close()
+
+	result := &Result{Columns: make([]string, len(projection))}
+	for i, col := range projection {
+		result.Columns[i] = col.name
+	}
+	for it.Next() {
+		if err := e.reserve(rowMemory(it.Row()), "result"); err != nil {
+			return nil, err
+		}
+		result.Rows = append(result.Rows, it.Row().Values)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	result.RowCount = len(result.Rows)
+	return result, nil
+}
+
+// tracedIterator measures an operator while EXPLAIN ANALYZE runs: the rows
+// it returns, and the time and page reads spent in its Next.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Operators run interleaved, so an operator's clock can't be started
+// before it runs and stopped after, as for a step that does all its work
+// at once. Instead each call to Next is timed. That time includes the
+// calls into the operator's input, so the input's own time is taken off:
+// the step's numbers are its own, as EXPLAIN ANALYZE reports them.
+type tracedIterator struct {
+	rowIterator
+	e     *Executor
+	node  *PlanNode
+	input *tracedIterator // the traced operator below, if any
+
+	elapsed time.Duration
+	pages   uint64
+}
+
+// describer is an operator whose plan detail is only known once it has
+// run, such as a sort that may select the top K rows.
+type describer interface {
+	describe() string
+}
+
+// traced wraps op, whose input is the operator traced before it, in a
+// step of the active trace. Without a trace it returns op.
+func (e *Executor) traced(op rowIterator, operator, detail string) rowIterator {
+	if e.trace == nil {
+		return op
+	}
+	t := &tracedIterator{
+		rowIterator: op,
+		e:           e,
+		node:        &PlanNode{Operator: operator, Detail: detail, Actual: &OperatorStats{}},
+		input:       e.trace.last,
+	}
+	e.trace.steps = append(e.trace.steps, t.node)
+	e.trace.last = t
+	return t
+}
+
+func (t *tracedIterator) Next() bool {
+	start, pages := time.Now(), t.e.pager.IOStats().PageRequests
+	ok := t.rowIterator.Next()
+	t.elapsed += time.Since(start)
+	t.pages += t.e.pager.IOStats().PageRequests - pages
+
+	if ok {
+		t.node.Actual.Rows++
+	}
+	if d, isDescriber := t.rowIterator.(describer); isDescriber {
+		t.node.Detail = d.describe()
+	}
+	t.node.Actual.Time, t.node.Actual.PagesRead = t.elapsed, t.pages
+	if t.input != nil {
+		t.node.Actual.Time -= t.input.elapsed
+		t.node.Actual.PagesRead -= t.input.pages
+	}
+	return ok
+}
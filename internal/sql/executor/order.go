@@ -0,0 +1,189 @@
+// Package executor - ORDER BY from an index
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A B-tree keeps its keys sorted, so reading one from start to end returns
+// rows in the order of its columns. When ORDER BY asks for that order, the
+// sort can be skipped:
+//
+//   SELECT * FROM users ORDER BY id LIMIT 10
+//
+//   -> Project (id, name, age)
+//     -> Limit (LIMIT 10)
+//       -> Ordered Index Scan (users using primary key)
+//
+// Instead of reading every row and sorting them to keep the first ten,
+// the scan walks the primary key's leaves and the query stops after ten
+// rows. Without LIMIT it still saves the sort's memory (or its spill to
+// disk), and the first rows come out before the last are read.
+//
+// The rows an access path reads are in ORDER BY order when ORDER BY lists,
+// all ascending, the columns of the index the path reads, or the first of
+// them. Once every column of a unique index (a primary key, say) is
+// listed, no two rows tie, so the columns after them don't matter either:
+//
+//   ORDER BY id             primary key (id): ordered
+//   ORDER BY id, name       primary key (id): ordered, ids are unique
+//   ORDER BY a              primary key (a, b): ordered
+//   ORDER BY b              primary key (a, b): not ordered
+//   ORDER BY id DESC        not ordered: the scan only runs forwards
+//
+// So the planner's choice of access path decides whether the rows come
+// ordered: a range of the primary key, or a lookup or range of a
+// secondary index on the ORDER BY column, already is. A table scan, which
+// reads pages in the order they are stored, is turned into a read of the
+// whole primary key when that would be ordered; it reads the same leaves.
+//
+// Failing that, it is turned into a read of a whole secondary index whose
+// order is ORDER BY's. Every row has an entry, and NULL keys sort first,
+// as ORDER BY puts NULLs. Each entry costs a read of its row's page,
+// though, which a sort of the table often beats, so the index is read
+// only when the query has a LIMIT, which stops it after a few entries,
+// or when its ordered columns are NOT NULL:
+//
+//   SELECT * FROM items ORDER BY price LIMIT 5
+//
+//   -> Project (id, name, price)
+//     -> Limit (LIMIT 5)
+//       -> Ordered Index Scan (items using index items_price)
+//
+// A NULL's key holds the type it was written with, which can differ
+// between two NULLs of the same column, so two NULLs don't sort as equal.
+// That's harmless in the last column ORDER BY lists, but a nullable
+// column before it would split the rows the later columns must order.
+//
+// Keys hold each value's collation key (see collation.go), so an index on
+// a NOCASE column is in the order ORDER BY sorts it in. PostgreSQL and
+// MySQL go further, reading an index backwards for DESC, and weighing a
+// sorted read of a secondary index against a scan and a sort by cost.
+
+package executor
+
+import (
+	"sort"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/sql/planner"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// indexOrder reports whether the rows of tbl that the access plan reads
+// come in the SELECT's ORDER BY order, so that it needs no sort. scan is
+// true if that takes reading the table in the order of an index instead
+// of scanning it: index's, or the primary key's if index is nil. agg is
+// the query's aggregation, if it has one: its groups are sorted, not the
+// rows.
+func indexOrder(stmt *parser.SelectStatement, tbl *table.Table, plan *QueryPlan,
+	agg *aggregatePlan) (ordered, scan bool, index *storage.Index) {
+	if len(stmt.OrderBy) == 0 || agg != nil || tbl.IsColumnar() {
+		return false, false, nil
+	}
+	pk := tbl.Schema.PrimaryKeyColumns
+	switch {
+	case plan.Type == PlanIndexScan && plan.Index == nil:
+		// A lookup of the primary key finds one row at most
+		return true, false, nil
+	case plan.Type == PlanIndexRangeScan && plan.Index == nil:
+		return orderedBy(stmt.OrderBy, tbl.Schema, pk, true), false, nil
+	case plan.Index != nil:
+		columns := indexColumns(tbl.Schema, plan.Index)
+		return orderedBy(stmt.OrderBy, tbl.Schema, columns, plan.Index.Unique), false, nil
+	case plan.FullScan:
+		return false, false, nil
+	case len(pk) > 0 && orderedBy(stmt.OrderBy, tbl.Schema, pk, true):
+		return true, true, nil
+	}
+
+	names := tbl.ListIndexes()
+	sort.Strings(names)
+	for _, name := range names {
+		idx, ok := tbl.GetIndex(name)
+		if ok && indexCoversOrder(stmt, tbl.Schema, idx) {
+			return true, true, idx
+		}
+	}
+	return false, false, nil
+}
+
+// indexColumns returns the positions in schema of idx's columns.
+func indexColumns(schema *table.Schema, idx *storage.Index) []int {
+	columns := make([]int, len(idx.Columns))
+	for i, name := range idx.Columns {
+		columns[i], _ = schema.GetColumnIndex(name)
+	}
+	return columns
+}
+
+// indexCoversOrder reports whether reading all of idx gives the rows in
+// stmt's ORDER BY order, and is worth it over a sort: with a LIMIT, or
+// when the columns ORDER BY reads from it are NOT NULL. A nullable column
+// is only ordered right as the last of them (see the notes above).
+func indexCoversOrder(stmt *parser.SelectStatement, schema *table.Schema, idx *storage.Index) bool {
+	columns := indexColumns(schema, idx)
+	if !orderedBy(stmt.OrderBy, schema, columns, idx.Unique) {
+		return false
+	}
+
+	// The columns before the last one ORDER BY reads must be NOT NULL,
+	// and so must all of a unique index's that ORDER BY lists more after
+	used := min(len(stmt.OrderBy), len(columns))
+	need := used - 1
+	if len(stmt.OrderBy) > len(columns) {
+		need = used
+	}
+	notNull := 0
+	for notNull < len(columns) && columnNotNull(schema, columns[notNull]) {
+		notNull++
+	}
+	return notNull >= need && (notNull >= used || stmt.Limit != nil)
+}
+
+// columnNotNull reports whether column col of schema can't hold NULL.
+func columnNotNull(schema *table.Schema, col int) bool {
+	if schema.Columns[col].NotNull || schema.Columns[col].PrimaryKey {
+		return true
+	}
+	for _, pk := range schema.PrimaryKeyColumns {
+		if pk == col {
+			return true
+		}
+	}
+	return false
+}
+
+// orderedBy reports whether rows in the order of an index on columns are
+// in orderBy's order. unique says the index has no two rows with the same
+// values of its columns.
+func orderedBy(orderBy []parser.OrderByClause, schema *table.Schema, columns []int, unique bool) bool {
+	for i, clause := range orderBy {
+		if i == len(columns) {
+			return unique
+		}
+		col, found := schema.GetColumnIndex(clause.Column)
+		if !found || clause.Descending || col != columns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// orderedScanNode is the plan's read of tbl in the order of index, or of
+// the primary key if index is nil, for its ORDER BY.
+func orderedScanNode(tbl *table.Table, index *storage.Index) *PlanNode {
+	stats := tbl.Stats()
+	rows := float64(stats.RowCount)
+	return &PlanNode{
+		Operator: "Ordered Index Scan",
+		Detail:   tbl.Name + " using " + orderedScanIndex(index),
+		Estimate: &PlanEstimate{Rows: rows, Cost: planner.IndexScanCost(&stats, rows, index != nil)},
+	}
+}
+
+// orderedScanIndex names the index an ordered scan reads.
+func orderedScanIndex(index *storage.Index) string {
+	if index == nil {
+		return "primary key"
+	}
+	return "index " + index.Name
+}
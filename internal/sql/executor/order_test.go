@@ -0,0 +1,182 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrderByPrimaryKeySkipsSort(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	for _, sql := range []string{
+		"INSERT INTO users VALUES (3, 'Carol', 35)",
+		"INSERT INTO users VALUES (1, 'Alice', 30)",
+		"INSERT INTO users VALUES (4, 'Dave', 20)",
+		"INSERT INTO users VALUES (2, 'Bob', 25)",
+	} {
+		executeSQL(t, exec, sql)
+	}
+
+	tests := []struct {
+		sql    string
+		want   string
+		sorted bool
+	}{
+		{"SELECT id FROM users ORDER BY id", "1, 2, 3, 4", false},
+		{"SELECT id FROM users ORDER BY id, name LIMIT 2", "1, 2", false},
+		{"SELECT id FROM users WHERE id > 1 ORDER BY id", "2, 3, 4", false},
+		{"SELECT id FROM users WHERE age < 35 ORDER BY id LIMIT 2 OFFSET 1", "2, 4", false},
+		{"SELECT id FROM users ORDER BY id DESC", "4, 3, 2, 1", true},
+		{"SELECT id FROM users ORDER BY name", "1, 2, 3, 4", true},
+		{"SELECT id FROM users ORDER BY age, id", "4, 2, 1, 3", true},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		plan := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) "+tt.sql))
+		if strings.Contains(plan, "Sort") != tt.sorted {
+			t.Errorf("%s: expected a sort %v, got plan %s", tt.sql, tt.sorted, plan)
+		}
+	}
+
+	// The scan is shown as ordered, planned and run
+	for _, sql := range []string{
+		"EXPLAIN (FORMAT TREE) SELECT * FROM users ORDER BY id",
+		"EXPLAIN (ANALYZE, FORMAT TREE) SELECT * FROM users ORDER BY id",
+	} {
+		if plan := resultText(executeSQL(t, exec, sql)); !strings.Contains(plan, "Ordered Index Scan (users using primary key)") {
+			t.Errorf("%s: expected an ordered index scan, got %s", sql, plan)
+		}
+	}
+	result := executeSQL(t, exec, "EXPLAIN SELECT * FROM users ORDER BY id")
+	if explained := resultText(result); !strings.Contains(explained, "Order By id (ordered index scan of the primary key, no sort)") {
+		t.Errorf("expected EXPLAIN to say the order comes from the index, got %s", explained)
+	}
+	if got := planRow(t, result, "Access Method"); got != "ORDERED_INDEX_SCAN" {
+		t.Errorf("expected the access method to be the ordered index scan, got %q", got)
+	}
+}
+
+func TestOrderByIndexSkipsSort(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT COLLATE NOCASE, age INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_users_age ON users (age)")
+	executeSQL(t, exec, "CREATE INDEX idx_users_name ON users (name)")
+	for _, sql := range []string{
+		"INSERT INTO users VALUES (1, 'carol', 35)",
+		"INSERT INTO users VALUES (2, 'Alice', 30)",
+		"INSERT INTO users VALUES (3, 'dave', 20)",
+		"INSERT INTO users VALUES (4, 'Bob', 25)",
+	} {
+		executeSQL(t, exec, sql)
+	}
+
+	// A range of the index comes in its order; NOCASE keys sort as ORDER BY does
+	for _, tt := range []struct{ sql, want string }{
+		{"SELECT id FROM users WHERE age > 20 ORDER BY age", "4, 2, 1"},
+		{"SELECT id FROM users WHERE age >= 20 ORDER BY age LIMIT 2", "3, 4"},
+		{"SELECT id FROM users WHERE name > 'a' ORDER BY name", "2, 4, 1, 3"},
+	} {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		if plan := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) "+tt.sql)); strings.Contains(plan, "Sort") {
+			t.Errorf("%s: expected no sort, got plan %s", tt.sql, plan)
+		}
+	}
+
+	// Without a condition on it, the index isn't read for ORDER BY alone
+	if plan := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) SELECT id FROM users ORDER BY age")); !strings.Contains(plan, "Sort") {
+		t.Errorf("expected a sort, got plan %s", plan)
+	}
+}
+
+func TestOrderByWholeIndex(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, price INTEGER NOT NULL, weight INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX items_price ON items (price)")
+	executeSQL(t, exec, "CREATE INDEX items_weight ON items (weight)")
+	for _, sql := range []string{
+		"INSERT INTO items VALUES (1, 30, 3)",
+		"INSERT INTO items VALUES (2, 10, NULL)",
+		"INSERT INTO items VALUES (3, 40, 1)",
+		"INSERT INTO items VALUES (4, 20, 2)",
+	} {
+		executeSQL(t, exec, sql)
+	}
+
+	// A NOT NULL column's index holds every row in order; a nullable
+	// one's is read for a LIMIT, with the NULLs first as a sort puts them
+	for _, tt := range []struct {
+		sql    string
+		want   string
+		sorted bool
+	}{
+		{"SELECT id FROM items ORDER BY price", "2, 4, 1, 3", false},
+		{"SELECT id FROM items WHERE price <> 30 ORDER BY price", "2, 4, 3", false},
+		{"SELECT id FROM items ORDER BY price LIMIT 2", "2, 4", false},
+		{"SELECT id FROM items ORDER BY weight LIMIT 3", "2, 3, 4", false},
+		{"SELECT id FROM items ORDER BY weight", "2, 3, 4, 1", true},
+		{"SELECT id FROM items ORDER BY price DESC", "3, 1, 4, 2", true},
+	} {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		plan := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) "+tt.sql))
+		if strings.Contains(plan, "Sort") != tt.sorted {
+			t.Errorf("%s: expected a sort %v, got plan %s", tt.sql, tt.sorted, plan)
+		}
+	}
+
+	// The scan is shown as ordered, planned and run
+	for _, sql := range []string{
+		"EXPLAIN (FORMAT TREE) SELECT * FROM items ORDER BY price",
+		"EXPLAIN (ANALYZE, FORMAT TREE) SELECT * FROM items ORDER BY price",
+	} {
+		if plan := resultText(executeSQL(t, exec, sql)); !strings.Contains(plan, "Ordered Index Scan (items using index items_price)") {
+			t.Errorf("%s: expected an ordered scan of items_price, got %s", sql, plan)
+		}
+	}
+	result := executeSQL(t, exec, "EXPLAIN SELECT * FROM items ORDER BY price")
+	if got := planRow(t, result, "Access Method"); got != "ORDERED_INDEX_SCAN" {
+		t.Errorf("expected the access method to be the ordered index scan, got %q", got)
+	}
+	if got := planRow(t, result, "Order By"); got != "price (ordered index scan of the index items_price, no sort)" {
+		t.Errorf("expected the order to come from items_price, got %q", got)
+	}
+}
+
+func TestOrderByCompositePrimaryKey(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE visits (user_id INTEGER, day INTEGER, pages INTEGER, PRIMARY KEY (user_id, day))")
+	for _, sql := range []string{
+		"INSERT INTO visits VALUES (2, 1, 5)",
+		"INSERT INTO visits VALUES (1, 2, 7)",
+		"INSERT INTO visits VALUES (1, 1, 3)",
+	} {
+		executeSQL(t, exec, sql)
+	}
+
+	for _, tt := range []struct {
+		sql    string
+		want   string
+		sorted bool
+	}{
+		{"SELECT pages FROM visits ORDER BY user_id", "3, 7, 5", false},
+		{"SELECT pages FROM visits ORDER BY user_id, day", "3, 7, 5", false},
+		{"SELECT pages FROM visits ORDER BY day, user_id", "3, 5, 7", true},
+	} {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		plan := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) "+tt.sql))
+		if strings.Contains(plan, "Sort") != tt.sorted {
+			t.Errorf("%s: expected a sort %v, got plan %s", tt.sql, tt.sorted, plan)
+		}
+	}
+}
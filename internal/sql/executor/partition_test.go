@@ -0,0 +1,179 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRangePartitioning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "partitions.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, `CREATE TABLE events (id INTEGER, day INTEGER, msg TEXT) PARTITION BY RANGE (day) (
+		PARTITION p0 VALUES LESS THAN (100), PARTITION p1 VALUES LESS THAN (200), PARTITION pmax VALUES LESS THAN MAXVALUE)`)
+	executeSQL(t, exec, "INSERT INTO events VALUES (1, 50, 'a')")
+	executeSQL(t, exec, "INSERT INTO events VALUES (2, 150, 'b')")
+	executeSQL(t, exec, "INSERT INTO events VALUES (3, 250, 'c')")
+	executeSQL(t, exec, "INSERT INTO events VALUES (4, NULL, 'd')")
+
+	// Each row is stored in the partition of its key; NULL in the first
+	for sql, want := range map[string]string{
+		"SELECT id FROM events_p0 ORDER BY id":            "1, 4",
+		"SELECT id FROM events_p1":                        "2",
+		"SELECT id FROM events_pmax":                      "3",
+		"SELECT id FROM events ORDER BY id":               "1, 2, 3, 4",
+		"SELECT id FROM events WHERE day >= 100":          "2, 3",
+		"SELECT COUNT(*) FROM events WHERE day < 200":     "2",
+		"SELECT msg FROM events WHERE day = 150":          "b",
+		"SELECT id FROM events WHERE day > 150 LIMIT 5":   "3",
+		"SELECT id FROM events WHERE msg = 'a' OR id = 3": "1, 3",
+	} {
+		if got := resultText(executeSQL(t, exec, sql)); got != want {
+			t.Errorf("%s: expected %q, got %q", sql, want, got)
+		}
+	}
+
+	// Conditions on the key prune the partitions that can't match
+	for sql, want := range map[string]string{
+		"EXPLAIN SELECT * FROM events WHERE day >= 100 AND day < 200":  "1 of 3 partitions, pruned p0, pmax",
+		"EXPLAIN SELECT * FROM events WHERE day = 250":                 "1 of 3 partitions, pruned p0, p1",
+		"EXPLAIN SELECT * FROM events WHERE day <= 100":                "2 of 3 partitions, pruned pmax",
+		"EXPLAIN SELECT * FROM events WHERE msg = 'a'":                 "3 of 3 partitions",
+		"EXPLAIN (FORMAT TREE) SELECT id FROM events WHERE day >= 200": "Partition Scan (events: 1 of 3 partitions, pruned p0, p1)",
+	} {
+		if got := resultText(executeSQL(t, exec, sql)); !strings.Contains(got, want) {
+			t.Errorf("%s: expected %q in the plan, got %q", sql, want, got)
+		}
+	}
+
+	// An UPDATE of the key moves the row to its new partition
+	executeSQL(t, exec, "UPDATE events SET day = 120 WHERE id = 1")
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM events_p1 ORDER BY id")); got != "1, 2" {
+		t.Errorf("expected row 1 moved to p1, got %q", got)
+	}
+	if result := executeSQL(t, exec, "DELETE FROM events WHERE day >= 200"); result.RowCount != 1 {
+		t.Errorf("expected 1 row deleted, got %d", result.RowCount)
+	}
+
+	// The partitioning survives reopening
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	executeSQL(t, exec, "INSERT INTO events VALUES (5, 500, 'e')")
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM events_pmax")); got != "5" {
+		t.Errorf("expected row 5 in pmax after reopening, got %q", got)
+	}
+	ddl, _ := exec.TableDDL("events")
+	if len(ddl) != 1 || !strings.HasSuffix(ddl[0], "PARTITION pmax VALUES LESS THAN MAXVALUE)") {
+		t.Errorf("unexpected DDL %q", ddl)
+	}
+
+	// Rolling back undoes a DROP TABLE of the partitioned table
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "DROP TABLE events")
+	executeSQL(t, exec, "ROLLBACK")
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM events")); got != "4" {
+		t.Errorf("expected 4 rows after ROLLBACK, got %q", got)
+	}
+	executeSQL(t, exec, "DROP TABLE events")
+	executeSQL(t, exec, "CREATE TABLE events_p0 (id INTEGER)")
+}
+
+func TestRangePartitioningParenthesizedMaxvalue(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	// MySQL's LESS THAN (MAXVALUE) means the same as LESS THAN MAXVALUE
+	executeSQL(t, exec, `CREATE TABLE events (id INTEGER, day INTEGER) PARTITION BY RANGE (day) (
+		PARTITION p0 VALUES LESS THAN (100), PARTITION pmax VALUES LESS THAN (MAXVALUE))`)
+	executeSQL(t, exec, "INSERT INTO events VALUES (1, 50)")
+	executeSQL(t, exec, "INSERT INTO events VALUES (2, 1000000)")
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM events_pmax")); got != "2" {
+		t.Errorf("expected the large key in the MAXVALUE partition, got %q", got)
+	}
+}
+
+func TestHashPartitioning(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE sessions (id INTEGER PRIMARY KEY, owner TEXT) PARTITION BY HASH (id) PARTITIONS 4")
+	for i := 1; i <= 40; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO sessions VALUES (%d, 'user%d')", i, i%3))
+	}
+
+	// The rows are spread over the partitions
+	total := 0
+	for i := 0; i < 4; i++ {
+		result := executeSQL(t, exec, fmt.Sprintf("SELECT * FROM sessions_p%d", i))
+		if len(result.Rows) == 0 || len(result.Rows) == 40 {
+			t.Errorf("expected partition p%d to hold some of the rows, got %d", i, len(result.Rows))
+		}
+		total += len(result.Rows)
+	}
+	if total != 40 {
+		t.Errorf("expected 40 rows in all, got %d", total)
+	}
+
+	if got := resultText(executeSQL(t, exec, "SELECT owner FROM sessions WHERE id = 7")); got != "user1" {
+		t.Errorf("unexpected owner %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "EXPLAIN SELECT owner FROM sessions WHERE id = 7")); !strings.Contains(got, "1 of 4 partitions") {
+		t.Errorf("expected an equality on the key to prune, got %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "EXPLAIN SELECT owner FROM sessions WHERE id > 7")); !strings.Contains(got, "4 of 4 partitions") {
+		t.Errorf("expected a range not to prune a hash, got %q", got)
+	}
+	const grouped = "SELECT owner, COUNT(*) FROM sessions GROUP BY owner ORDER BY owner"
+	if got := resultText(executeSQL(t, exec, grouped)); got != "user0 13, user1 14, user2 13" {
+		t.Errorf("unexpected groups %q", got)
+	}
+
+	// Equal keys always meet in the same partition
+	if _, err := exec.Execute(parseSQL(t, "INSERT INTO sessions VALUES (7, 'again')")); err == nil {
+		t.Error("expected a duplicate primary key to fail")
+	}
+	executeSQL(t, exec, "UPDATE sessions SET owner = 'admin' WHERE id <= 2")
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM sessions WHERE owner = 'admin'")); got != "2" {
+		t.Errorf("expected 2 rows updated, got %q", got)
+	}
+}
+
+func TestPartitionErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE logs (id INTEGER, day INTEGER) PARTITION BY RANGE (day) (PARTITION old VALUES LESS THAN (10))")
+	executeSQL(t, exec, "CREATE TABLE other (id INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE x_p1 (a INTEGER)")
+
+	for sql, want := range map[string]string{
+		"INSERT INTO logs VALUES (1, 10)":                                                      "no partition of logs for day = 10",
+		"INSERT INTO logs_old VALUES (1, 1)":                                                   "cannot insert into partition logs_old",
+		"DELETE FROM logs_old":                                                                 "cannot delete from partition logs_old",
+		"DROP TABLE logs_old":                                                                  "drop the partitioned table instead",
+		"ALTER TABLE logs ADD COLUMN note TEXT":                                                "cannot alter partitioned table logs",
+		"CREATE INDEX idx_day ON logs (day)":                                                   "create the index on each of its partitions",
+		"SELECT * FROM logs JOIN other ON logs.id = other.id":                                  "JOIN is not supported with partitioned table logs",
+		"CREATE TABLE t (a INTEGER) PARTITION BY HASH (b) PARTITIONS 2":                        "unknown column b",
+		"CREATE TABLE t (a INTEGER PRIMARY KEY, b INTEGER) PARTITION BY HASH (b) PARTITIONS 2": "must include the partition key b",
+		"CREATE TEMP TABLE t (a INTEGER) PARTITION BY HASH (a) PARTITIONS 2":                   "only tables of the main database",
+		"CREATE TABLE t (a INTEGER) PARTITION BY RANGE (a) (PARTITION p VALUES LESS THAN (5), PARTITION q VALUES LESS THAN (5))":      "must increase",
+		"CREATE TABLE t (a INTEGER) PARTITION BY RANGE (a) (PARTITION p VALUES LESS THAN MAXVALUE, PARTITION q VALUES LESS THAN (5))": "only the last partition",
+		"CREATE TABLE t (a INTEGER) PARTITION BY RANGE (a) (PARTITION p VALUES LESS THAN (1), PARTITION p VALUES LESS THAN (5))":      "partition p appears twice",
+		"CREATE TABLE x (a INTEGER) PARTITION BY HASH (a) PARTITIONS 2":                                                               "table x_p1 already exists",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+	for _, name := range []string{"t", "x", "x_p0"} {
+		if _, exists := exec.tables[name]; exists {
+			t.Errorf("expected no table %s left by the failed CREATE TABLEs", name)
+		}
+	}
+}
@@ -8,16 +8,27 @@
 // 2. Use an index (IndexScan) - fast O(log n) for indexed columns
 //
 // Our simple planner looks for equality conditions on the primary key
-// in the WHERE clause. More sophisticated planners would also consider:
-// - Range conditions (>, <, BETWEEN)
-// - Multiple indexes
+// (on every column of a composite key) in the WHERE clause, then on the column of a secondary index, and
+// failing that for range conditions (>, >=, <, <=) on the same columns in
+// the same order, which become a scan of part of a B-tree. Once ANALYZE
+// has gathered statistics, it estimates the cost of each of these and of
+// a table scan instead, and takes the cheapest, estimating how many rows
+// each condition matches from the column histograms. More sophisticated
+// planners would also consider:
+// - BETWEEN and IN lists
+// - Indexes on several columns, and combining indexes
 // - Join ordering
-// - Statistics about data distribution
 
 package executor
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/sql/planner"
+	"github.com/cabewaldrop/claude-db/internal/storage"
 	"github.com/cabewaldrop/claude-db/internal/table"
 )
 
@@ -27,88 +38,421 @@ type PlanType int
 const (
 	// PlanTableScan indicates a full table scan.
 	PlanTableScan PlanType = iota
-	// PlanIndexScan indicates a primary key index lookup.
+	// PlanIndexScan indicates an index lookup.
 	PlanIndexScan
+	// PlanIndexRangeScan indicates a scan of a range of an index.
+	PlanIndexRangeScan
 )
 
 // QueryPlan represents how to execute a SELECT query.
 type QueryPlan struct {
 	Type PlanType
 
-	// For IndexScan: the primary key value to look up
+	// For IndexScan and IndexRangeScan: the secondary index to use, or
+	// nil for the primary key
+	Index *storage.Index
+
+	// For IndexScan: the key value to look up
 	IndexKey *table.Value
+
+	// For IndexScan of a composite primary key: the value of each of its
+	// columns, in key order, instead of IndexKey
+	IndexKeys []table.Value
+
+	// For IndexRangeScan: the bounds of the range (nil = unbounded)
+	RangeLower     *table.Value
+	RangeUpper     *table.Value
+	LowerInclusive bool
+	UpperInclusive bool
+
+	// Cost is the estimated cost of the plan, when statistics were
+	// available to choose it by cost
+	Cost float64
+
+	// FullScan is set for a TableScan a FULL_SCAN hint asked for: the
+	// table must not be read through its primary key instead, even to
+	// get its rows in order
+	FullScan bool
 }
 
 // Planner analyzes queries and produces execution plans.
-type Planner struct{}
+type Planner struct {
+	// params holds values bound to ? placeholders, so that
+	// "WHERE id = ?" can still use the primary key index.
+	params []table.Value
+}
 
 // NewPlanner creates a new query planner.
 func NewPlanner() *Planner {
 	return &Planner{}
 }
 
+// NewPlannerWithParams creates a planner that resolves placeholders
+// against the given bound values.
+func NewPlannerWithParams(params []table.Value) *Planner {
+	return &Planner{params: params}
+}
+
 // Plan analyzes a SELECT statement and returns an execution plan.
 //
 // EDUCATIONAL NOTE:
 // -----------------
-// We look for WHERE clauses of the form: pk_column = literal_value
-// This is the simplest case for index usage. A real planner would handle:
-// - pk_column = expression (if expression can be evaluated without row data)
-// - Compound conditions with AND (pk = val AND other_condition)
+// We look for WHERE clauses of the form: column = literal_value, where
+// the column is the primary key or has a secondary index. This is the
+// simplest case for index usage. A real planner would handle:
+// - column = expression (if expression can be evaluated without row data)
 // - IN clauses (pk IN (1, 2, 3))
-func (p *Planner) Plan(stmt *parser.SelectStatement, schema *table.Schema) *QueryPlan {
-	// Default to table scan
-	plan := &QueryPlan{Type: PlanTableScan}
+func (p *Planner) Plan(stmt *parser.SelectStatement, tbl *table.Table) *QueryPlan {
+	// Can't use index if no WHERE clause, or if told not to
+	if _, fullScan := stmt.Hint(parser.HintFullScan); stmt.Where == nil || fullScan {
+		return &QueryPlan{Type: PlanTableScan, FullScan: fullScan}
+	}
 
-	// Can't use index if no WHERE clause
-	if stmt.Where == nil {
-		return plan
+	paths := p.accessPaths(stmt.Where, tbl)
+	if len(paths) == 0 {
+		return &QueryPlan{Type: PlanTableScan}
 	}
 
-	// Can't use index if no primary key
-	if schema.PrimaryKey < 0 {
-		return plan
+	// An INDEX hint picks the index's path, if there is one, whatever it
+	// costs (see parser.Hint)
+	if hint, ok := stmt.Hint(parser.HintIndex); ok {
+		for _, path := range paths {
+			if path.Index != nil && path.Index.Name == hint.Args[0] {
+				return path
+			}
+		}
+	}
+
+	// Without statistics, follow the rules. A lookup of a unique key finds
+	// at most one row, so nothing beats it (see planner.chooseByCost).
+	stats := tbl.Stats()
+	if !stats.Analyzed() || paths[0].uniqueLookup() {
+		return paths[0]
+	}
+
+	// Otherwise take the cheapest of a table scan and the access paths
+	best := &QueryPlan{Type: PlanTableScan, Cost: planner.TableScanCost(&stats)}
+	for _, path := range paths {
+		path.Cost = planner.IndexScanCost(&stats, path.estimateRows(tbl.Schema, &stats), path.Index != nil)
+		if path.Cost < best.Cost {
+			best = path
+		}
+	}
+	return best
+}
+
+// checkHints reports an error if a SELECT's optimizer hints can't apply:
+// they choose how to read a table of the database, and an INDEX hint
+// must name one of its indexes.
+func (e *Executor) checkHints(stmt *parser.SelectStatement) error {
+	if len(stmt.Hints) == 0 {
+		return nil
+	}
+	if stmt.From == "" || stmt.FromFunc != nil || len(stmt.Joins) > 0 || isSystemView(stmt.From) || e.isVirtualTable(stmt.From) {
+		return fmt.Errorf("optimizer hints only apply to a SELECT from one table")
+	}
+	tbl, exists := e.lookupTable(strings.ToLower(stmt.From))
+	if !exists {
+		return nil // reported when the table is read
+	}
+	if hint, ok := stmt.Hint(parser.HintIndex); ok {
+		if _, ok := tbl.GetIndex(hint.Args[0]); !ok {
+			return fmt.Errorf("optimizer hint %s: table %s has no index %s", hint, tbl.Name, hint.Args[0])
+		}
 	}
+	return nil
+}
 
-	pkColumn := schema.Columns[schema.PrimaryKey].Name
+// accessPaths returns the index accesses the WHERE clause allows, in the
+// order the rules prefer them: an equality on the primary key, then on an
+// indexed column, then a range of the primary key, then of an indexed
+// column.
+func (p *Planner) accessPaths(where parser.Expression, tbl *table.Table) []*QueryPlan {
+	schema := tbl.Schema
+	indexes := singleColumnIndexes(tbl)
+	var paths []*QueryPlan
 
 	// Try to extract PK equality condition
-	keyValue := extractPKEquality(stmt.Where, pkColumn)
-	if keyValue != nil {
-		plan.Type = PlanIndexScan
-		plan.IndexKey = keyValue
+	if schema.PrimaryKey >= 0 {
+		keyValue := extractEquality(where, schema.Columns[schema.PrimaryKey].Name, p.params)
+		if keyValue != nil {
+			paths = append(paths, &QueryPlan{Type: PlanIndexScan, IndexKey: keyValue})
+		}
+	} else if keyValues := primaryKeyValues(where, schema, p.params); keyValues != nil {
+		paths = append(paths, &QueryPlan{Type: PlanIndexScan, IndexKeys: keyValues})
+	}
+
+	// Then an equality on an indexed column. Index keys are compared as
+	// bytes, so the value must have the column's type (see rangeBound).
+	for _, idx := range indexes {
+		col := schema.Columns[columnIndex(schema, idx.Columns[0])]
+		if keyValue := rangeBound(extractEquality(where, col.Name, p.params), col); keyValue != nil {
+			paths = append(paths, &QueryPlan{Type: PlanIndexScan, Index: idx, IndexKey: keyValue})
+		}
+	}
+
+	// Then a range of the primary key, then of an index
+	if schema.PrimaryKey >= 0 {
+		plan := &QueryPlan{Type: PlanIndexRangeScan}
+		extractRange(where, schema.Columns[schema.PrimaryKey], p.params, plan)
+		if plan.RangeLower != nil || plan.RangeUpper != nil {
+			paths = append(paths, plan)
+		}
+	}
+	for _, idx := range indexes {
+		plan := &QueryPlan{Type: PlanIndexRangeScan, Index: idx}
+		extractRange(where, schema.Columns[columnIndex(schema, idx.Columns[0])], p.params, plan)
+		if plan.RangeLower != nil || plan.RangeUpper != nil {
+			paths = append(paths, plan)
+		}
 	}
 
+	return paths
+}
+
+// Rebind returns the access path of a plan made earlier for the same
+// statement, with its keys taken from this planner's bound values, or nil
+// if they no longer allow it (a NULL key, say).
+func (p *Planner) Rebind(cached *QueryPlan, stmt *parser.SelectStatement, tbl *table.Table) *QueryPlan {
+	plan := &QueryPlan{Type: cached.Type, Index: cached.Index, Cost: cached.Cost}
+	if plan.Type == PlanTableScan {
+		return plan
+	}
+
+	schema := tbl.Schema
+	if cached.IndexKeys != nil {
+		if plan.IndexKeys = primaryKeyValues(stmt.Where, schema, p.params); plan.IndexKeys == nil {
+			return nil
+		}
+		return plan
+	}
+	col := schema.Columns[max(schema.PrimaryKey, 0)]
+	if plan.Index != nil {
+		col = schema.Columns[columnIndex(schema, plan.Index.Columns[0])]
+	}
+	if plan.Type == PlanIndexScan {
+		plan.IndexKey = extractEquality(stmt.Where, col.Name, p.params)
+		if plan.Index != nil {
+			plan.IndexKey = rangeBound(plan.IndexKey, col)
+		}
+		if plan.IndexKey == nil {
+			return nil
+		}
+		return plan
+	}
+	extractRange(stmt.Where, col, p.params, plan)
+	if plan.RangeLower == nil && plan.RangeUpper == nil {
+		return nil
+	}
 	return plan
 }
 
-// extractPKEquality looks for a condition of the form: pk_column = literal
+// uniqueLookup reports whether the plan looks up one key of the primary
+// key or a unique index.
+func (plan *QueryPlan) uniqueLookup() bool {
+	return plan.Type == PlanIndexScan && (plan.Index == nil || plan.Index.Unique)
+}
+
+// estimateRows estimates how many rows an index access reads.
+func (plan *QueryPlan) estimateRows(schema *table.Schema, stats *table.TableStats) float64 {
+	if plan.IndexKeys != nil {
+		return min(1, float64(stats.RowCount))
+	}
+	column := ""
+	if plan.Index != nil {
+		column = plan.Index.Columns[0]
+	} else if schema.PrimaryKey >= 0 {
+		column = schema.Columns[schema.PrimaryKey].Name
+	}
+	if plan.Type == PlanIndexScan {
+		return planner.EstimateEqualityRows(stats, column, *plan.IndexKey)
+	}
+	return planner.EstimateRangeRows(stats, column, plan.RangeLower, plan.RangeUpper)
+}
+
+// singleColumnIndexes returns the table's secondary indexes that cover one
+// column, unique ones first since an equality on them matches at most one
+// row, then by name so that plans don't depend on map order.
+func singleColumnIndexes(tbl *table.Table) []*storage.Index {
+	var indexes []*storage.Index
+	for _, name := range tbl.ListIndexes() {
+		if idx, ok := tbl.GetIndex(name); ok && len(idx.Columns) == 1 {
+			if _, ok := tbl.Schema.GetColumnIndex(idx.Columns[0]); ok {
+				indexes = append(indexes, idx)
+			}
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		if indexes[i].Unique != indexes[j].Unique {
+			return indexes[i].Unique
+		}
+		return indexes[i].Name < indexes[j].Name
+	})
+	return indexes
+}
+
+// columnIndex returns the position of a column known to be in schema.
+func columnIndex(schema *table.Schema, name string) int {
+	idx, _ := schema.GetColumnIndex(name)
+	return idx
+}
+
+// extractRange looks for conditions of the form column op literal, with
+// op one of >, >=, < and <=, among the conditions ANDed together in expr,
+// and records them as the bounds of plan's range. Only the range is taken
+// from them; every row in it is still checked against the whole WHERE
+// clause, so a bound that's looser than the condition is harmless.
+func extractRange(expr parser.Expression, col table.Column, params []table.Value, plan *QueryPlan) {
+	e, ok := expr.(*parser.BinaryExpression)
+	if !ok {
+		return
+	}
+	if e.Operator == parser.OpAnd {
+		extractRange(e.Left, col, params, plan)
+		extractRange(e.Right, col, params, plan)
+		return
+	}
+
+	op, literal := e.Operator, e.Right
+	if ident, ok := e.Left.(*parser.Identifier); !ok || ident.Name != col.Name {
+		ident, ok := e.Right.(*parser.Identifier)
+		if !ok || ident.Name != col.Name {
+			return
+		}
+		// "5 < id" is "id > 5"
+		literal = e.Left
+		switch op {
+		case parser.OpLessThan:
+			op = parser.OpGreaterThan
+		case parser.OpGreaterThan:
+			op = parser.OpLessThan
+		case parser.OpLessOrEqual:
+			op = parser.OpGreaterOrEqual
+		case parser.OpGreaterOrEqual:
+			op = parser.OpLessOrEqual
+		}
+	}
+
+	switch op {
+	case parser.OpGreaterThan, parser.OpGreaterOrEqual, parser.OpLessThan, parser.OpLessOrEqual:
+	default:
+		return
+	}
+	bound := rangeBound(extractLiteralValue(literal, params), col)
+	if bound == nil {
+		return
+	}
+	switch op {
+	case parser.OpGreaterThan, parser.OpGreaterOrEqual:
+		plan.RangeLower = bound
+		plan.LowerInclusive = op == parser.OpGreaterOrEqual
+	default:
+		plan.RangeUpper = bound
+		plan.UpperInclusive = op == parser.OpLessOrEqual
+	}
+}
+
+// scanRanges returns the range of each column that the WHERE clause
+// limits, for a table scan to skip the pages with no row in them (see
+// table.RowIterator.SkipPages).
+func scanRanges(where parser.Expression, schema *table.Schema, params []table.Value) []table.ScanRange {
+	if where == nil {
+		return nil
+	}
+	var ranges []table.ScanRange
+	for i, col := range schema.Columns {
+		if !table.ZoneMapColumn(col) {
+			continue
+		}
+		if val := rangeBound(extractEquality(where, col.Name, params), col); val != nil {
+			ranges = append(ranges, table.ScanRange{Column: i, Lower: val, Upper: val,
+				LowerInclusive: true, UpperInclusive: true})
+			continue
+		}
+		var plan QueryPlan
+		extractRange(where, col, params, &plan)
+		if plan.RangeLower != nil || plan.RangeUpper != nil {
+			ranges = append(ranges, table.ScanRange{Column: i, Lower: plan.RangeLower, Upper: plan.RangeUpper,
+				LowerInclusive: plan.LowerInclusive, UpperInclusive: plan.UpperInclusive})
+		}
+	}
+	return ranges
+}
+
+// rangeBound converts a literal to the type of an indexed column, so that
+// its encoded key sorts among the column's keys, or returns nil if it
+// can't be converted exactly.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Keys are compared as bytes, and each starts with its value's type, so a
+// REAL bound would sort before or after every INTEGER key instead of among
+// them. An integer converts exactly to REAL or DECIMAL; the other way
+// round "id > 1.5" would have to become "id >= 2", so that case is left
+// to a table scan.
+func rangeBound(val *table.Value, col table.Column) *table.Value {
+	if val == nil {
+		return nil
+	}
+	switch {
+	case val.Type == col.Type:
+		return val
+	case val.Type == parser.TypeInteger && col.Type == parser.TypeReal:
+		return &table.Value{Type: parser.TypeReal, Real: float64(val.Integer)}
+	case val.Type == parser.TypeInteger && col.Type == parser.TypeDecimal:
+		return &table.Value{Type: parser.TypeDecimal, Integer: val.Integer}
+	default:
+		return nil
+	}
+}
+
+// primaryKeyValues returns the values a WHERE clause gives each column of
+// a composite primary key with an equality, or nil unless it gives them
+// all: the key's B-tree can only be searched for a whole key. Like an
+// index key, each value must have its column's type (see rangeBound).
+func primaryKeyValues(where parser.Expression, schema *table.Schema, params []table.Value) []table.Value {
+	if len(schema.PrimaryKeyColumns) < 2 {
+		return nil
+	}
+	values := make([]table.Value, len(schema.PrimaryKeyColumns))
+	for i, idx := range schema.PrimaryKeyColumns {
+		col := schema.Columns[idx]
+		val := rangeBound(extractEquality(where, col.Name, params), col)
+		if val == nil {
+			return nil
+		}
+		values[i] = *val
+	}
+	return values
+}
+
+// extractEquality looks for a condition of the form: column = literal
 // Returns the literal value if found, nil otherwise.
-func extractPKEquality(expr parser.Expression, pkColumn string) *table.Value {
+func extractEquality(expr parser.Expression, column string, params []table.Value) *table.Value {
 	switch e := expr.(type) {
 	case *parser.BinaryExpression:
 		// Look for equality operator
 		if e.Operator == parser.OpEquals {
-			// Check if left side is the PK column and right side is a literal
+			// Check if left side is the column and right side is a literal
 			if ident, ok := e.Left.(*parser.Identifier); ok {
-				if ident.Name == pkColumn {
-					return extractLiteralValue(e.Right)
+				if ident.Name == column {
+					return extractLiteralValue(e.Right, params)
 				}
 			}
-			// Check if right side is the PK column and left side is a literal
+			// Check if right side is the column and left side is a literal
 			if ident, ok := e.Right.(*parser.Identifier); ok {
-				if ident.Name == pkColumn {
-					return extractLiteralValue(e.Left)
+				if ident.Name == column {
+					return extractLiteralValue(e.Left, params)
 				}
 			}
 		}
 
 		// For AND conditions, check both sides
 		if e.Operator == parser.OpAnd {
-			if val := extractPKEquality(e.Left, pkColumn); val != nil {
+			if val := extractEquality(e.Left, column, params); val != nil {
 				return val
 			}
-			if val := extractPKEquality(e.Right, pkColumn); val != nil {
+			if val := extractEquality(e.Right, column, params); val != nil {
 				return val
 			}
 		}
@@ -118,8 +462,16 @@ func extractPKEquality(expr parser.Expression, pkColumn string) *table.Value {
 }
 
 // extractLiteralValue extracts a table.Value from a literal expression.
-func extractLiteralValue(expr parser.Expression) *table.Value {
+// Bound placeholders count as literals: their value is fixed for the
+// duration of the statement.
+func extractLiteralValue(expr parser.Expression, params []table.Value) *table.Value {
 	switch lit := expr.(type) {
+	case *parser.Placeholder:
+		if lit.Index < len(params) && !params[lit.Index].IsNull {
+			val := params[lit.Index]
+			return &val
+		}
+		return nil
 	case *parser.IntegerLiteral:
 		return &table.Value{Type: parser.TypeInteger, Integer: lit.Value}
 	case *parser.RealLiteral:
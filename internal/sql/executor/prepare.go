@@ -0,0 +1,143 @@
+// Package executor - Prepared statements
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Running a statement from its SQL text takes three steps before any row
+// is touched: lexing, parsing and planning. For a small INSERT run
+// thousands of times, those steps cost more than the insert itself. A
+// prepared statement does them once:
+//
+//   stmt, err := exec.Prepare("INSERT INTO users VALUES (?, ?)")
+//   if err != nil { ... }
+//   defer stmt.Close()
+//   for _, user := range users {   // each a []table.Value
+//       if _, err := stmt.Exec(user...); err != nil { ... }
+//   }
+//
+// The parsed statement is kept, and each execution only binds new values
+// to its ? placeholders (see ExecuteWithParams).
+//
+// A SELECT also keeps its plan: the first execution chooses an index with
+// its values, and later ones reuse it with theirs, only looking up the
+// new keys. This is what PostgreSQL calls a generic plan. The risk
+// is that a path chosen for one value is poor for another (an index for a
+// rare value, reused for a common one), so the plan is only reused while
+// it could still have been chosen: it is made again if its table or index
+// has been dropped, or ANALYZE has gathered new statistics since.
+
+package executor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// Stmt is a prepared statement, parsed once and executed any number of
+// times with different values for its placeholders.
+type Stmt struct {
+	SQL string
+
+	e        *Executor
+	stmt     parser.Statement
+	numInput int
+	plan     *cachedPlan // the SELECT's plan, once it has run
+	closed   bool
+}
+
+// cachedPlan is the plan of a prepared SELECT, and what it was made for.
+type cachedPlan struct {
+	table    *table.Table
+	plan     *QueryPlan
+	analyzed time.Time // when the statistics it was chosen by were gathered
+}
+
+// Prepare parses a statement for repeated execution.
+func (e *Executor) Prepare(sql string) (*Stmt, error) {
+	stmt, err := parser.New(lexer.New(sql)).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	return &Stmt{SQL: sql, e: e, stmt: stmt, numInput: parser.CountPlaceholders(stmt)}, nil
+}
+
+// NumInput returns the number of ? placeholders in the statement.
+func (s *Stmt) NumInput() int {
+	return s.numInput
+}
+
+// Exec runs the statement, binding args to its placeholders.
+func (s *Stmt) Exec(args ...table.Value) (*Result, error) {
+	if err := s.check(args); err != nil {
+		return nil, err
+	}
+	s.e.prepared = s
+	defer func() { s.e.prepared = nil }()
+	return s.e.ExecuteWithParams(s.stmt, args)
+}
+
+// Query runs a prepared SELECT, returning its rows to be read one at a
+// time (see Executor.Query).
+func (s *Stmt) Query(args ...table.Value) (*Rows, error) {
+	if err := s.check(args); err != nil {
+		return nil, err
+	}
+	s.e.prepared = s
+	defer func() { s.e.prepared = nil }()
+	return s.e.Query(s.stmt, args...)
+}
+
+// check returns an error if the statement can't run with args.
+func (s *Stmt) check(args []table.Value) error {
+	if s.closed {
+		return fmt.Errorf("statement is closed")
+	}
+	if len(args) != s.numInput {
+		return fmt.Errorf("statement expects %d parameter(s), got %d", s.numInput, len(args))
+	}
+	return nil
+}
+
+// Close releases the statement; it can't be executed afterwards.
+func (s *Stmt) Close() error {
+	s.closed = true
+	s.plan = nil
+	return nil
+}
+
+// planSelect plans a SELECT on tbl, reusing the plan of the prepared
+// statement being executed if it is still valid.
+func (e *Executor) planSelect(stmt *parser.SelectStatement, tbl *table.Table) *QueryPlan {
+	defer e.planned(time.Now())
+	p := NewPlannerWithParams(e.params)
+	s := e.prepared
+	if s == nil || s.stmt != parser.Statement(stmt) {
+		return p.Plan(stmt, tbl)
+	}
+
+	// A table scan isn't worth keeping: there was no key to look up, and
+	// with these values there may be
+	if c := s.plan; c != nil && c.plan.Type != PlanTableScan && c.valid(e, tbl) {
+		if plan := p.Rebind(c.plan, stmt, tbl); plan != nil {
+			return plan
+		}
+	}
+	plan := p.Plan(stmt, tbl)
+	s.plan = &cachedPlan{table: tbl, plan: plan, analyzed: tbl.Stats().LastAnalyzed}
+	return plan
+}
+
+// valid reports whether a cached plan can still be used to read tbl.
+func (c *cachedPlan) valid(e *Executor, tbl *table.Table) bool {
+	if current, _ := e.lookupTable(tbl.Name); c.table != tbl || current != tbl || !tbl.Stats().LastAnalyzed.Equal(c.analyzed) {
+		return false
+	}
+	if c.plan.Index != nil {
+		idx, ok := tbl.GetIndex(c.plan.Index.Name)
+		return ok && idx == c.plan.Index
+	}
+	return true
+}
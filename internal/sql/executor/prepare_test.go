@@ -0,0 +1,145 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestPrepare(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+
+	insert, err := exec.Prepare("INSERT INTO users VALUES (?, ?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if insert.NumInput() != 3 {
+		t.Errorf("expected 3 placeholders, got %d", insert.NumInput())
+	}
+	for i := 1; i <= 50; i++ {
+		_, err := insert.Exec(
+			table.Value{Type: parser.TypeInteger, Integer: int64(i)},
+			table.Value{Type: parser.TypeText, Text: fmt.Sprintf("user%d", i)},
+			table.Value{Type: parser.TypeInteger, Integer: int64(20 + i%5)},
+		)
+		if err != nil {
+			t.Fatalf("Exec %d failed: %v", i, err)
+		}
+	}
+	if _, err := insert.Exec(); err == nil {
+		t.Error("expected an error for missing parameters")
+	}
+
+	// The plan of the first execution is kept, and used with new keys
+	sel, err := exec.Prepare("SELECT name FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for _, id := range []int64{7, 42} {
+		result, err := sel.Exec(table.Value{Type: parser.TypeInteger, Integer: id})
+		if err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+		if got := resultText(result); got != fmt.Sprintf("user%d", id) {
+			t.Errorf("id %d: got %q", id, got)
+		}
+	}
+	cached := sel.plan
+	if cached == nil || cached.plan.Type != PlanIndexScan {
+		t.Fatalf("expected a cached primary key lookup, got %+v", cached)
+	}
+
+	// A NULL key can't be looked up; the statement is planned again
+	result, err := sel.Exec(table.Value{IsNull: true})
+	if err != nil || len(result.Rows) != 0 {
+		t.Errorf("expected no rows for a NULL id, got %v, %v", result, err)
+	}
+
+	// A dropped index isn't used by the cached plan
+	executeSQL(t, exec, "CREATE INDEX idx_age ON users (age)")
+	byAge, err := exec.Prepare("SELECT COUNT(*) FROM users WHERE age = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	count := func() string {
+		t.Helper()
+		result, err := byAge.Exec(table.Value{Type: parser.TypeInteger, Integer: 21})
+		if err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+		return resultText(result)
+	}
+	if got := count(); got != "10" || byAge.plan.plan.Index == nil {
+		t.Errorf("expected 10 rows through idx_age, got %s with %+v", got, byAge.plan.plan)
+	}
+	executeSQL(t, exec, "DROP INDEX idx_age")
+	if got := count(); got != "10" || byAge.plan.plan.Index != nil {
+		t.Errorf("expected 10 rows by a table scan, got %s with %+v", got, byAge.plan.plan)
+	}
+
+	rows, err := sel.Query(table.Value{Type: parser.TypeInteger, Integer: 3})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !rows.Next() || rows.Values()[0].Text != "user3" {
+		t.Errorf("expected user3, got %v", rows.Err())
+	}
+	rows.Close()
+
+	sel.Close()
+	if _, err := sel.Exec(table.Value{Type: parser.TypeInteger, Integer: 1}); err == nil {
+		t.Error("expected an error executing a closed statement")
+	}
+	if _, err := exec.Prepare("SELECT CAST(age TEXT) FROM users"); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	exec, cleanup := setupBenchExecutor(b)
+	defer cleanup()
+	if _, err := exec.Execute(mustParse(b, "CREATE TABLE bench (id INTEGER PRIMARY KEY, value INTEGER)")); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sql := fmt.Sprintf("INSERT INTO bench (id, value) VALUES (%d, %d)", i, i)
+		if _, err := exec.Execute(mustParse(b, sql)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPreparedInsert(b *testing.B) {
+	exec, cleanup := setupBenchExecutor(b)
+	defer cleanup()
+	if _, err := exec.Execute(mustParse(b, "CREATE TABLE bench (id INTEGER PRIMARY KEY, value INTEGER)")); err != nil {
+		b.Fatal(err)
+	}
+	stmt, err := exec.Prepare("INSERT INTO bench (id, value) VALUES (?, ?)")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		val := table.Value{Type: parser.TypeInteger, Integer: int64(i)}
+		if _, err := stmt.Exec(val, val); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func mustParse(b *testing.B, sql string) parser.Statement {
+	stmt, err := parser.New(lexer.New(sql)).Parse()
+	if err != nil {
+		b.Fatalf("Parse error: %v", err)
+	}
+	return stmt
+}
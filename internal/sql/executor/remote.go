@@ -0,0 +1,205 @@
+// Package executor - Remote tables
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The remote virtual table module makes a table of another claude-db
+// server readable here, through that server's JSON API (see the web
+// package):
+//
+//   CREATE VIRTUAL TABLE eu_orders USING remote('http://eu-db:8080', 'orders');
+//   SELECT status, COUNT(*) FROM eu_orders GROUP BY status;
+//
+// Creating the table asks GET /api/tables/orders for its columns; every
+// SELECT then sends SELECT * FROM orders to POST /api/query and reads the
+// rows out of the JSON. From there they go through the same WHERE filter,
+// aggregation, ORDER BY and LIMIT as a CSV file's rows (see virtual.go).
+//
+// This is federation at its simplest: PostgreSQL's postgres_fdw and
+// MySQL's FEDERATED engine do the same with "foreign tables". What they
+// add is pushdown: postgres_fdw sends the WHERE clause, and sometimes the
+// aggregation, along to the remote server, so only the rows wanted cross
+// the network. Here the whole table crosses it on every query, which is
+// fine for the small tables this is meant for, and spares translating
+// this query's conditions into SQL for the other server.
+//
+// The JSON only knows numbers, strings and booleans, so the API sends
+// TIMESTAMP and DECIMAL values as text, and they arrive here as TEXT
+// columns.
+
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// remoteClient sends the requests of remote tables. A statement's own
+// timeout (see cancel.go) can only shorten the wait.
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+// remoteResponse is the envelope of the web API's responses.
+type remoteResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// checkRemoteArgs checks a remote table's server URL and table name.
+func checkRemoteArgs(server, tableName string) error {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("remote server %q is not an http:// or https:// URL", server)
+	}
+	if !isPlainName(strings.ToLower(tableName)) {
+		return fmt.Errorf("remote table %q is not a table name", tableName)
+	}
+	return nil
+}
+
+// remoteSchema asks a server for the columns of one of its tables.
+func remoteSchema(ctx context.Context, server, tableName string) (*table.Schema, error) {
+	var data struct {
+		Columns []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"columns"`
+	}
+	endpoint := strings.TrimSuffix(server, "/") + "/api/tables/" + url.PathEscape(tableName)
+	if err := remoteCall(ctx, http.MethodGet, endpoint, nil, &data); err != nil {
+		return nil, err
+	}
+	if len(data.Columns) == 0 {
+		return nil, fmt.Errorf("remote table %s has no columns", tableName)
+	}
+
+	columns := make([]parser.ColumnDefinition, len(data.Columns))
+	for i, col := range data.Columns {
+		columns[i] = parser.ColumnDefinition{Name: strings.ToLower(col.Name), Type: parser.TypeText}
+		switch col.Type {
+		case "INTEGER":
+			columns[i].Type = parser.TypeInteger
+		case "REAL":
+			columns[i].Type = parser.TypeReal
+		case "BOOLEAN":
+			columns[i].Type = parser.TypeBoolean
+		}
+	}
+	return table.NewSchema(columns), nil
+}
+
+// remoteRows asks a server for every row of one of its tables.
+func remoteRows(ctx context.Context, server, tableName string, schema *table.Schema) ([]table.Row, error) {
+	body, err := json.Marshal(map[string]string{"sql": "SELECT * FROM " + tableName})
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		Columns []string `json:"columns"`
+		Rows    [][]any  `json:"rows"`
+	}
+	if err := remoteCall(ctx, http.MethodPost, strings.TrimSuffix(server, "/")+"/api/query", body, &data); err != nil {
+		return nil, err
+	}
+	if len(data.Rows) > 0 && len(data.Columns) != len(schema.Columns) {
+		return nil, fmt.Errorf("remote table %s now has %d columns, not %d: create the virtual table again",
+			tableName, len(data.Columns), len(schema.Columns))
+	}
+
+	rows := make([]table.Row, len(data.Rows))
+	for i, values := range data.Rows {
+		if len(values) != len(schema.Columns) {
+			return nil, fmt.Errorf("remote table %s: row %d has %d values", tableName, i+1, len(values))
+		}
+		row := table.Row{ID: uint64(i + 1), Values: make([]table.Value, len(values))}
+		for j, v := range values {
+			if row.Values[j], err = remoteValue(v, schema.Columns[j].Type); err != nil {
+				return nil, fmt.Errorf("remote table %s: row %d, column %s: %w", tableName, i+1, schema.Columns[j].Name, err)
+			}
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// remoteCall sends a request to the web API and decodes the data of its
+// response into data.
+func remoteCall(ctx context.Context, method, endpoint string, body []byte, data any) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// UseNumber keeps integers whole, rather than turning them into
+	// float64s
+	var envelope remoteResponse
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&envelope); err != nil {
+		return fmt.Errorf("%s %s: %s, not an API response", method, endpoint, resp.Status)
+	}
+	if !envelope.Success {
+		return fmt.Errorf("%s %s: %s", method, endpoint, envelope.Error)
+	}
+	dec = json.NewDecoder(bytes.NewReader(envelope.Data))
+	dec.UseNumber()
+	return dec.Decode(data)
+}
+
+// remoteValue converts a value decoded from the JSON to a value of a
+// column's type.
+func remoteValue(v any, typ parser.DataType) (table.Value, error) {
+	if v == nil {
+		return table.Value{Type: typ, IsNull: true}, nil
+	}
+	switch typ {
+	case parser.TypeInteger:
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return table.Value{Type: typ, Integer: i}, nil
+			}
+		}
+		return table.Value{}, fmt.Errorf("%v is not an integer", v)
+	case parser.TypeReal:
+		if n, ok := v.(json.Number); ok {
+			if f, err := n.Float64(); err == nil {
+				return table.Value{Type: typ, Real: f}, nil
+			}
+		}
+		return table.Value{}, fmt.Errorf("%v is not a number", v)
+	case parser.TypeBoolean:
+		if b, ok := v.(bool); ok {
+			return table.Value{Type: typ, Boolean: b}, nil
+		}
+		return table.Value{}, fmt.Errorf("%v is not a boolean", v)
+	}
+
+	// A JSON column's documents arrive decoded, and go back to text
+	if s, ok := v.(string); ok {
+		return textValue(s), nil
+	}
+	text, err := json.Marshal(v)
+	if err != nil {
+		return table.Value{}, err
+	}
+	return textValue(string(text)), nil
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRemoteTableErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	// A server answering like the web API, with the tables items and bad
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/api/tables/items" || r.URL.Path == "/api/tables/bad":
+			w.Write([]byte(`{"success":true,"data":{"columns":[{"name":"id","type":"INTEGER"},{"name":"doc","type":"JSON"}]}}`))
+		case r.URL.Path == "/api/query" && strings.Contains(string(body), "bad"):
+			w.Write([]byte(`{"success":true,"data":{"columns":["id","doc"],"rows":[[1,null],["x","y"]]}}`))
+		case r.URL.Path == "/api/query":
+			w.Write([]byte(`{"success":true,"data":{"columns":["id","doc"],"rows":[[1,{"a":[1,2]}],[2,null]]}}`))
+		case strings.HasPrefix(r.URL.Path, "/other"):
+			http.NotFound(w, r)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"success":false,"error":"table 'missing' not found"}`))
+		}
+	}))
+	defer ts.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for sql, want := range map[string]string{
+		"CREATE VIRTUAL TABLE r USING remote('" + ts.URL + "')":                "remote takes 2 arguments",
+		"CREATE VIRTUAL TABLE r USING remote('ftp://host', 'items')":           "is not an http:// or https:// URL",
+		"CREATE VIRTUAL TABLE r USING remote('" + ts.URL + "', 'items; DROP')": "is not a table name",
+		"CREATE VIRTUAL TABLE r USING remote('" + ts.URL + "', 'missing')":     "table 'missing' not found",
+		"CREATE VIRTUAL TABLE r USING remote('" + closed.URL + "', 'items')":   "connection refused",
+		"CREATE VIRTUAL TABLE r USING remote('" + ts.URL + "/other', 'items')": "404 Not Found, not an API response",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+
+	// A JSON document arrives as text, and a value of the wrong type
+	// fails the query
+	executeSQL(t, exec, "CREATE VIRTUAL TABLE r USING remote('"+ts.URL+"', 'items')")
+	if got := resultText(executeSQL(t, exec, "SELECT doc FROM r WHERE id = 1")); got != `{"a":[1,2]}` {
+		t.Errorf("expected the document as text, got %q", got)
+	}
+	executeSQL(t, exec, "CREATE VIRTUAL TABLE b USING remote('"+ts.URL+"', 'bad')")
+	if _, err := exec.Execute(parseSQL(t, "SELECT * FROM b")); err == nil || !strings.Contains(err.Error(), `row 2, column id: x is not an integer`) {
+		t.Errorf("expected a conversion error, got %v", err)
+	}
+}
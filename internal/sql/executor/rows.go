@@ -0,0 +1,158 @@
+// Package executor - Streaming query results
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Execute returns a Result holding every row of a query. Query returns
+// the pipeline itself instead, and the caller pulls rows from it one at a
+// time, the way database/sql's Rows works:
+//
+//   rows, err := exec.Query(stmt)
+//   if err != nil { ... }
+//   defer rows.Close()
+//   for rows.Next() {
+//       fmt.Println(rows.Values())
+//   }
+//   if err := rows.Err(); err != nil { ... }
+//
+// Each call to Next runs the operators just far enough to produce one
+// more row, so a client can print or send rows as they're found, and stop
+// reading whenever it likes without the rest being computed.
+//
+// The query is still running between calls, so the executor must not be
+// given another statement until Rows is closed; until then the session
+// keeps reading the database, and other sessions can't change it (see
+// session.go).
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// Rows is the result of a query, read one row at a time.
+type Rows struct {
+	Columns []string
+
+	e      *Executor
+	it     rowIterator
+	params []table.Value
+	ctx    context.Context // from QueryContext, or nil
+	cancel context.CancelFunc
+	memory *memoryAccount
+	leave  func() // Gives back the session's access to the database
+	closed bool
+}
+
+// Query runs a SELECT, binding args to its ? placeholders, and returns its
+// rows to be read one at a time. The caller must Close them.
+func (e *Executor) Query(stmt parser.Statement, args ...table.Value) (*Rows, error) {
+	sel, ok := stmt.(*parser.SelectStatement)
+	if !ok {
+		return nil, fmt.Errorf("only SELECT statements can be queried; use Execute for %T", stmt)
+	}
+	if want := parser.CountPlaceholders(stmt); want != len(args) {
+		return nil, fmt.Errorf("statement expects %d parameter(s), got %d", want, len(args))
+	}
+
+	e.params = args
+	defer func() { e.params = nil }()
+
+	rows := &Rows{e: e, params: args, memory: e.newMemoryAccount()}
+	defer e.withMemoryAccount(rows.memory)()
+	rows.ctx, rows.cancel = e.statementContext()
+	defer e.withContext(rows.ctx)()
+	leave, err := e.enter(sel)
+	if err != nil {
+		rows.cancel()
+		return nil, err
+	}
+	rows.leave = leave
+	defer func() {
+		if rows.it == nil {
+			leave()
+		}
+	}()
+	if sel.From == "" || sel.FromFunc != nil || len(sel.Joins) > 0 || isSystemView(sel.From) || e.isVirtualTable(sel.From) {
+		// Without a table, from a table function, with joins, from a view
+		// or from a virtual table, the rows are computed up front
+		result, err := e.executeSelect(sel)
+		if err != nil {
+			rows.cancel()
+			e.releaseStatementLocks()
+			return nil, err
+		}
+		rows.Columns = result.Columns
+		it := &sliceIterator{}
+		for _, values := range result.Rows {
+			it.rows = append(it.rows, table.Row{Values: values})
+		}
+		rows.it = it
+		return rows, nil
+	}
+
+	tableName := strings.ToLower(sel.From)
+	tbl, exists := e.lookupTable(tableName)
+	if !exists {
+		rows.cancel()
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	if err := e.checkHints(sel); err != nil {
+		rows.cancel()
+		return nil, err
+	}
+	projection, agg, err := selectProjection(sel, tbl.Schema)
+	if err != nil {
+		rows.cancel()
+		return nil, err
+	}
+	if rows.it, err = e.selectIterator(sel, tbl, projection, agg); err != nil {
+		rows.cancel()
+		return nil, err
+	}
+	for _, col := range projection {
+		rows.Columns = append(rows.Columns, col.name)
+	}
+	return rows, nil
+}
+
+// Next advances to the next row. It returns false when there are no more
+// rows or an error occurred; see Err.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	r.e.params = r.params
+	defer func() { r.e.params = nil }()
+	defer r.e.withContext(r.ctx)()
+	defer r.e.withMemoryAccount(r.memory)()
+	return r.it.Next()
+}
+
+// Values returns the current row's values, one per column.
+func (r *Rows) Values() []table.Value {
+	return r.it.Row().Values
+}
+
+// Err returns the error that stopped the query, if any.
+func (r *Rows) Err() error {
+	return r.it.Err()
+}
+
+// Close stops the query. In autocommit mode, the locks of SELECT ... FOR
+// UPDATE are held until then.
+func (r *Rows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.cancel()
+	err := r.it.Close()
+	r.e.releaseStatementLocks()
+	r.leave()
+	return err
+}
@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// setupItems creates a table of n items spread over several data pages.
+func setupItems(t *testing.T, exec *Executor, n int) {
+	t.Helper()
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, label TEXT, price INTEGER)")
+	for i := 1; i <= n; i++ {
+		executeSQL(t, exec, fmt.Sprintf(
+			"INSERT INTO items VALUES (%d, 'an item with a fairly long label', %d)", i, i%10))
+	}
+}
+
+func TestQuery(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 300)
+
+	rows, err := exec.Query(parseSQL(t, "SELECT id, price * 2 FROM items WHERE price = ? AND id < 50"),
+		table.Value{Type: parser.TypeInteger, Integer: 3})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if got := strings.Join(rows.Columns, ","); got != "id,price * 2" {
+		t.Errorf("unexpected columns %s", got)
+	}
+	var got []string
+	for rows.Next() {
+		values := rows.Values()
+		got = append(got, values[0].String()+":"+values[1].String())
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	rows.Close()
+	if s := strings.Join(got, " "); s != "3:6 13:6 23:6 33:6 43:6" {
+		t.Errorf("unexpected rows %s", s)
+	}
+
+	// Reading one row of a scan reads only the first page of the table
+	before := exec.pager.IOStats().PageRequests
+	rows, err = exec.Query(parseSQL(t, "SELECT label FROM items"))
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !rows.Next() {
+		t.Fatalf("expected a row: %v", rows.Err())
+	}
+	rows.Close()
+	if reads := exec.pager.IOStats().PageRequests - before; reads != 1 {
+		t.Errorf("expected 1 page read, got %d", reads)
+	}
+	if rows.Next() {
+		t.Error("expected no rows after Close")
+	}
+
+	// FOR UPDATE locks are held until Close
+	rows, err = exec.Query(parseSQL(t, "SELECT id FROM items WHERE id <= 2 FOR UPDATE"))
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for rows.Next() {
+	}
+	if held := exec.locks.Held(exec.stmtOwner); len(held) != 2 {
+		t.Errorf("expected 2 locked rows, got %v", held)
+	}
+	rows.Close()
+	if exec.stmtOwner != 0 {
+		t.Error("expected locks released at Close")
+	}
+
+	if _, err := exec.Query(parseSQL(t, "DELETE FROM items")); err == nil {
+		t.Error("expected an error querying a DELETE")
+	}
+	if _, err := exec.Query(parseSQL(t, "SELECT * FROM items WHERE id = ?")); err == nil {
+		t.Error("expected an error for a missing parameter")
+	}
+}
+
+func TestPipelineStopsEarly(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 300)
+
+	tests := []struct {
+		sql      string
+		scanRows int
+		want     string
+	}{
+		// The scan stops once the limit has its rows
+		{"SELECT * FROM items LIMIT 2", 2, "1 an item with a fairly long label 1, 2 an item with a fairly long label 2"},
+		{"SELECT id FROM items WHERE price = 0 LIMIT 2 OFFSET 1", 3, "20, 30"},
+		// Sorting needs every row first
+		{"SELECT id FROM items WHERE price = 0 ORDER BY id DESC LIMIT 2", 30, "300, 290"},
+	}
+	for _, tt := range tests {
+		if got := resultText(executeSQL(t, exec, tt.sql)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		result := executeSQL(t, exec, "EXPLAIN ANALYZE "+tt.sql)
+		want := fmt.Sprintf("rows=%d ", tt.scanRows)
+		if got := planRow(t, result, "Table Scan"); !strings.HasPrefix(got, want) {
+			t.Errorf("%s: expected the scan to return %d rows, got %q", tt.sql, tt.scanRows, got)
+		}
+	}
+}
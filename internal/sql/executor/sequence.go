@@ -0,0 +1,183 @@
+// Package executor - Sequences
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A sequence is a named counter kept by the database, handing out the
+// numbers of surrogate keys:
+//
+//   CREATE SEQUENCE ids START WITH 1000 INCREMENT BY 10;
+//   CREATE TABLE orders (id INTEGER PRIMARY KEY DEFAULT NEXTVAL('ids'), total REAL);
+//   CREATE TABLE refunds (id INTEGER PRIMARY KEY DEFAULT NEXTVAL('ids'), amount REAL);
+//   INSERT INTO orders (total) VALUES (9.5);      -- id 1000
+//   INSERT INTO refunds (amount) VALUES (2.0);    -- id 1010
+//
+// NEXTVAL('ids') moves the sequence on and returns its new value, and
+// CURRVAL('ids') returns the value NEXTVAL last returned in this session,
+// so a program can learn the key of the row it has just inserted. Unlike
+// a table's own row IDs, one sequence can number the rows of several
+// tables, keeping their keys apart.
+//
+// The sequences are saved in the catalog with the tables, and so are
+// still counting from where they were when the database is opened again.
+// Here a sequence is changed like anything else a transaction does, and
+// a ROLLBACK takes its NEXTVALs back. PostgreSQL never does: NEXTVAL
+// there is not rolled back, leaving gaps, so that two transactions taking
+// numbers at once never wait for each other or hand out the same one.
+// With a single writer, going back costs nothing here.
+
+package executor
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// sequence is a sequence created by CREATE SEQUENCE.
+type sequence struct {
+	name      string
+	start     int64
+	increment int64
+	last      int64 // The last value NEXTVAL returned, if called
+	called    bool
+}
+
+// next moves the sequence on and returns its new value.
+func (s *sequence) next() int64 {
+	if s.called {
+		s.last += s.increment
+	} else {
+		s.last, s.called = s.start, true
+	}
+	return s.last
+}
+
+// loadSequences reads the sequences recorded in the catalog.
+func (e *Executor) loadSequences() {
+	for _, info := range e.catalog.Sequences() {
+		if e.sequences == nil {
+			e.sequences = make(map[string]*sequence)
+		}
+		e.sequences[info.Name] = &sequence{
+			name:      info.Name,
+			start:     info.Start,
+			increment: info.Increment,
+			last:      info.Last,
+			called:    info.Called,
+		}
+	}
+}
+
+// sequenceInfos describes the sequences for the catalog, by name.
+func (e *Executor) sequenceInfos() []catalog.SequenceInfo {
+	infos := make([]catalog.SequenceInfo, 0, len(e.sequences))
+	for _, name := range slices.Sorted(maps.Keys(e.sequences)) {
+		s := e.sequences[name]
+		infos = append(infos, catalog.SequenceInfo{
+			Name:      s.name,
+			Start:     s.start,
+			Increment: s.increment,
+			Last:      s.last,
+			Called:    s.called,
+		})
+	}
+	return infos
+}
+
+// cloneSequences copies the sequences, for a savepoint to go back to.
+func cloneSequences(sequences map[string]*sequence) map[string]*sequence {
+	if sequences == nil {
+		return nil
+	}
+	clone := make(map[string]*sequence, len(sequences))
+	for name, s := range sequences {
+		copied := *s
+		clone[name] = &copied
+	}
+	return clone
+}
+
+// executeCreateSequence handles CREATE SEQUENCE statements.
+func (e *Executor) executeCreateSequence(stmt *parser.CreateSequenceStatement) (*Result, error) {
+	name := strings.ToLower(stmt.Name)
+	if _, exists := e.sequences[name]; exists {
+		return nil, fmt.Errorf("sequence %s already exists", name)
+	}
+
+	if e.sequences == nil {
+		e.sequences = make(map[string]*sequence)
+	}
+	e.sequences[name] = &sequence{name: name, start: stmt.Start, increment: stmt.Increment}
+
+	return &Result{
+		Message: fmt.Sprintf("Sequence '%s' created", name),
+	}, nil
+}
+
+// executeDropSequence handles DROP SEQUENCE statements.
+func (e *Executor) executeDropSequence(stmt *parser.DropSequenceStatement) (*Result, error) {
+	name := strings.ToLower(stmt.Name)
+	if _, exists := e.sequences[name]; !exists {
+		return nil, fmt.Errorf("sequence %s does not exist", name)
+	}
+	delete(e.sequences, name)
+	delete(e.currvals, name)
+
+	return &Result{
+		Message: fmt.Sprintf("Sequence '%s' dropped", name),
+	}, nil
+}
+
+// sequenceArg evaluates the argument of NEXTVAL or CURRVAL, the name of a
+// sequence, and returns that sequence.
+func (e *Executor) sequenceArg(call *parser.FunctionCall, row table.Row, schema *table.Schema) (*sequence, error) {
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("%s requires 1 argument, got %d", call.Name, len(call.Args))
+	}
+	arg, err := e.evaluateExpression(call.Args[0], row, schema)
+	if err != nil {
+		return nil, err
+	}
+	if arg.IsNull || arg.Type != parser.TypeText {
+		return nil, fmt.Errorf("%s: expected the name of a sequence", call.Name)
+	}
+	name := strings.ToLower(arg.Text)
+	s, ok := e.sequences[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: sequence %s does not exist", call.Name, name)
+	}
+	return s, nil
+}
+
+// nextval evaluates NEXTVAL('name').
+func (e *Executor) nextval(call *parser.FunctionCall, row table.Row, schema *table.Schema) (table.Value, error) {
+	s, err := e.sequenceArg(call, row, schema)
+	if err != nil {
+		return table.Value{}, err
+	}
+	value := s.next()
+	if e.currvals == nil {
+		e.currvals = make(map[string]int64)
+	}
+	e.currvals[s.name] = value
+	return table.Value{Type: parser.TypeInteger, Integer: value}, nil
+}
+
+// currval evaluates CURRVAL('name'), which needs NEXTVAL('name') to have
+// been called first in the session.
+func (e *Executor) currval(call *parser.FunctionCall, row table.Row, schema *table.Schema) (table.Value, error) {
+	s, err := e.sequenceArg(call, row, schema)
+	if err != nil {
+		return table.Value{}, err
+	}
+	value, ok := e.currvals[s.name]
+	if !ok {
+		return table.Value{}, fmt.Errorf("CURRVAL: NEXTVAL('%s') has not been called in this session", s.name)
+	}
+	return table.Value{Type: parser.TypeInteger, Integer: value}, nil
+}
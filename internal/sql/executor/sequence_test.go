@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSequences(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequences.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE SEQUENCE ids START WITH 1000 INCREMENT BY 10")
+	executeSQL(t, exec, "CREATE TABLE orders (id INTEGER PRIMARY KEY DEFAULT NEXTVAL('ids'), total INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE refunds (id INTEGER PRIMARY KEY DEFAULT NEXTVAL('ids'), amount INTEGER DEFAULT 0)")
+
+	executeSQL(t, exec, "INSERT INTO orders (total) VALUES (95)")
+	executeSQL(t, exec, "INSERT INTO refunds (amount) VALUES (20)")
+	executeSQL(t, exec, "INSERT INTO orders (total) VALUES (30)")
+	if got := resultText(executeSQL(t, exec, "SELECT CURRVAL('ids')")); got != "1020" {
+		t.Errorf("expected CURRVAL 1020, got %q", got)
+	}
+
+	// A value given explicitly wins over the DEFAULT, which then isn't used
+	executeSQL(t, exec, "INSERT INTO refunds VALUES (5, 1)")
+	executeSQL(t, exec, "INSERT INTO refunds (id) VALUES (6)")
+	if got := resultText(executeSQL(t, exec, "SELECT * FROM orders")); got != "1000 95, 1020 30" {
+		t.Errorf("unexpected orders %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT * FROM refunds ORDER BY id")); got != "5 1, 6 0, 1010 20" {
+		t.Errorf("unexpected refunds %q", got)
+	}
+
+	// The sequence and the defaults are saved with the database
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	if _, err := exec.Execute(parseSQL(t, "SELECT CURRVAL('ids')")); err == nil || !strings.Contains(err.Error(), "has not been called") {
+		t.Errorf("expected CURRVAL to need NEXTVAL in a new session, got %v", err)
+	}
+	executeSQL(t, exec, "INSERT INTO refunds (amount) VALUES (7)")
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM refunds WHERE amount = 7")); got != "1030" {
+		t.Errorf("expected the sequence to go on from 1020, got %q", got)
+	}
+
+	// Rolling back takes back the values handed out
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "SELECT NEXTVAL('ids')")
+	executeSQL(t, exec, "ROLLBACK")
+	if got := resultText(executeSQL(t, exec, "SELECT NEXTVAL('ids')")); got != "1040" {
+		t.Errorf("expected 1040 after the rollback, got %q", got)
+	}
+
+	executeSQL(t, exec, "VACUUM")
+	if got := resultText(executeSQL(t, exec, "SELECT NEXTVAL('ids')")); got != "1050" {
+		t.Errorf("expected VACUUM to keep the sequence, got %q", got)
+	}
+	executeSQL(t, exec, "DROP SEQUENCE ids")
+	if _, err := exec.Execute(parseSQL(t, "INSERT INTO orders (total) VALUES (1)")); err == nil || !strings.Contains(err.Error(), "sequence ids does not exist") {
+		t.Errorf("expected the default to fail without its sequence, got %v", err)
+	}
+}
+
+func TestSequenceErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE SEQUENCE down INCREMENT BY -2")
+	if got := resultText(executeSQL(t, exec, "SELECT NEXTVAL('down'), NEXTVAL('DOWN')")); got != "-1 -3" {
+		t.Errorf("expected -1 -3, got %q", got)
+	}
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, n INTEGER)")
+	for sql, want := range map[string]string{
+		"CREATE SEQUENCE down":                         "already exists",
+		"DROP SEQUENCE up":                             "does not exist",
+		"SELECT NEXTVAL('up')":                         "sequence up does not exist",
+		"SELECT NEXTVAL(1)":                            "name of a sequence",
+		"SELECT NEXTVAL('down', 1)":                    "requires 1 argument",
+		"ALTER TABLE t ADD COLUMN m INTEGER DEFAULT 0": "with a DEFAULT",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package executor - Sessions
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The CLI and the web server both run statements against the same
+// database, and the web server runs each request on its own goroutine.
+// Sharing one Executor between them would share everything: one request's
+// BEGIN would put every other request inside its transaction, and a
+// CREATE TABLE could change the tables map while another request's SELECT
+// was reading it.
+//
+// Each client opens a session of its own instead:
+//
+//   session := exec.NewSession()
+//   defer session.Close()
+//   result, err := session.Execute(stmt)
+//
+// The sessions of a database share what CREATE makes: its tables,
+// indexes, triggers, sequences, views and the pager under them. What
+// belongs to the client is the session's own: its open transaction, its
+// bound parameters, SET settings, CURRVALs, prepared statements and
+// temporary tables (see temp.go).
+//
+// Sessions take turns through a gate, as SQLite's do: any number of
+// sessions may read at once, but a session writing (DDL or DML) has the
+// database to itself, waiting for the readers to finish and keeping new
+// ones out until it has. A transaction is one long write: BEGIN waits
+// for the gate and holds it until COMMIT or ROLLBACK, so no other session
+// sees its changes before they are committed, or runs a statement in the
+// middle of it.
+//
+//   session A: BEGIN; UPDATE ...;          COMMIT;
+//   session B:          SELECT ... (waits)        (runs)
+//
+// A waiting writer goes before readers that arrive after it, so a stream
+// of SELECTs can't starve an UPDATE. A statement waiting for the gate
+// stops when its context is canceled or its statement_timeout passes.
+//
+// A single writer at a time is what makes this simple: no two sessions
+// ever change the catalog or the pager's transaction at once, so neither
+// needs finer locking. It costs concurrency between writers, which
+// PostgreSQL keeps with row versions (MVCC); the row locks of locking.go
+// still matter between executors that share only a lock manager (see
+// SetLockManager).
+//
+// A database opened read-only (see storage.WithReadOnly) refuses, before
+// they start, the statements that would change it; BEGIN, COMMIT and
+// PRAGMA still run.
+//
+// A session left in a transaction holds the gate until it ends, so
+// Close, which rolls the transaction back and drops the session's
+// temporary tables, must be called.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// access is what a session may do to its database.
+type access int
+
+const (
+	noAccess access = iota
+	readAccess
+	writeAccess
+)
+
+// gate lets one session write, or any number of sessions read, at a time.
+// Writers waiting go before readers that arrive after them.
+type gate struct {
+	mu      sync.Mutex
+	readers int
+	writer  bool
+	waiting int           // Writers waiting
+	changed chan struct{} // Closed when the gate opens to a waiter, or nil
+}
+
+// acquire waits until the gate lets the caller read, or write, or ctx is
+// canceled.
+func (g *gate) acquire(ctx context.Context, write bool) error {
+	g.mu.Lock()
+	if write {
+		g.waiting++
+	}
+	for {
+		if !g.writer && (write && g.readers == 0 || !write && g.waiting == 0) {
+			if write {
+				g.waiting--
+				g.writer = true
+			} else {
+				g.readers++
+			}
+			g.mu.Unlock()
+			return nil
+		}
+		if g.changed == nil {
+			g.changed = make(chan struct{})
+		}
+		changed := g.changed
+		g.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			g.mu.Lock()
+			if write {
+				// The readers held back for this writer may go
+				g.waiting--
+				g.signalLocked()
+			}
+			g.mu.Unlock()
+			return ctx.Err()
+		}
+		g.mu.Lock()
+	}
+}
+
+// release gives back what acquire granted.
+func (g *gate) release(write bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if write {
+		g.writer = false
+	} else {
+		g.readers--
+	}
+	g.signalLocked()
+}
+
+// signalLocked wakes the waiters to look at the gate again. Caller must
+// hold mu.
+func (g *gate) signalLocked() {
+	if g.changed != nil {
+		close(g.changed)
+		g.changed = nil
+	}
+}
+
+// NewSession opens another session of the executor's database. It starts
+// with the executor's settings, and outside a transaction. Close it when
+// done.
+func (e *Executor) NewSession() *Executor {
+	return &Executor{
+		database:         e.database,
+		statementTimeout: e.statementTimeout,
+		outputNull:       e.outputNull,
+		typing:           e.typing,
+		sortMemory:       e.sortMemory,
+		queryMemory:      e.queryMemory,
+		analyzeThreshold: e.analyzeThreshold,
+		analyzeScale:     e.analyzeScale,
+	}
+}
+
+// Close ends the session, rolling back its open transaction if it has
+// one and dropping its temporary tables. The database stays open for its
+// other sessions.
+func (e *Executor) Close() error {
+	if e.tx != nil {
+		if _, err := e.Execute(&parser.RollbackStatement{}); err != nil {
+			return fmt.Errorf("failed to roll back the session's transaction: %w", err)
+		}
+	}
+	return e.DropTempTables()
+}
+
+// Read runs fn while the session reads the database, so that no other
+// session changes it meanwhile: for code reading GetTable's tables
+// directly, rather than with a query. It stops with an error wrapping
+// ctx.Err() if ctx is canceled before the database can be read.
+func (e *Executor) Read(ctx context.Context, fn func() error) error {
+	defer e.withContext(ctx)()
+	leave, err := e.enterAccess(false)
+	if err != nil {
+		return err
+	}
+	defer leave()
+	return fn()
+}
+
+// enter waits for the access statements need, read-only or not, and
+// returns a function giving it back. A statement run by another (a
+// trigger's, say) uses the access of the one running it, and a
+// transaction keeps write access from BEGIN until it ends.
+func (e *Executor) enter(stmts ...parser.Statement) (func(), error) {
+	write := false
+	for _, stmt := range stmts {
+		if readOnly(stmt) {
+			continue
+		}
+		if e.pager.ReadOnly() && changesDatabase(stmt) {
+			return nil, fmt.Errorf("cannot change the database: %w", storage.ErrReadOnly)
+		}
+		write = true
+	}
+	return e.enterAccess(write)
+}
+
+// enterAccess is enter for a read or a write.
+func (e *Executor) enterAccess(write bool) (func(), error) {
+	switch {
+	case e.access == writeAccess || e.access == readAccess && !write:
+		return func() {}, nil
+	case e.access == readAccess:
+		return nil, fmt.Errorf("cannot change the database while reading the rows of a query")
+	case e.tx != nil:
+		// The transaction has held the gate since BEGIN
+		e.access = writeAccess
+		return e.leave, nil
+	}
+
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := e.gate.acquire(ctx, write); err != nil {
+		return nil, e.canceled()
+	}
+	e.access = readAccess
+	if write {
+		e.access = writeAccess
+	}
+	return e.leave, nil
+}
+
+// leave gives back the access enter granted, unless a transaction is
+// open and keeps it.
+func (e *Executor) leave() {
+	write := e.access == writeAccess
+	e.access = noAccess
+	if e.tx != nil {
+		return
+	}
+	e.gate.release(write)
+}
+
+// readOnly reports whether a statement only reads the database, and can
+// run alongside other readers.
+func readOnly(stmt parser.Statement) bool {
+	switch s := stmt.(type) {
+	case *parser.SelectStatement:
+		return !s.ForUpdate && !callsNextval(s)
+	case *parser.ExplainStatement:
+		return !s.Analyze || readOnly(s.Statement)
+	case *parser.SetStatement:
+		return !callsNextval(s)
+	case *parser.ShowStatement, *parser.DescribeStatement:
+		return true
+	}
+	return false
+}
+
+// changesDatabase reports whether a statement that isn't read-only
+// changes the database, rather than only taking its turn as a writer:
+// transaction control and PRAGMA don't.
+func changesDatabase(stmt parser.Statement) bool {
+	switch stmt.(type) {
+	case *parser.BeginStatement, *parser.CommitStatement, *parser.RollbackStatement,
+		*parser.SavepointStatement, *parser.ReleaseStatement, *parser.PragmaStatement:
+		return false
+	}
+	return true
+}
+
+// callsNextval reports whether a statement moves a sequence on.
+func callsNextval(stmt parser.Statement) bool {
+	found := false
+	for _, expr := range parser.StatementExpressions(stmt) {
+		parser.WalkExpression(expr, func(expr parser.Expression) bool {
+			if call, ok := expr.(*parser.FunctionCall); ok && strings.EqualFold(call.Name, "NEXTVAL") {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
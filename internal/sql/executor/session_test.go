@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// runSQL runs sql in a session, for goroutines that can't fail the test.
+func runSQL(session *Executor, sql string) (*Result, error) {
+	stmt, err := parser.New(lexer.New(sql)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	return session.Execute(stmt)
+}
+
+func TestSessionsShareTables(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	session := exec.NewSession()
+	defer session.Close()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, session, "INSERT INTO users VALUES (1, 'Alice')")
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users")); got != "Alice" {
+		t.Errorf("expected the session's row, got %q", got)
+	}
+
+	// Settings and transactions are the session's own
+	executeSQL(t, session, "SET output_null = 'none'")
+	if result := executeSQL(t, exec, "SELECT NULL"); result.NullText != "" {
+		t.Errorf("expected the executor's NULL display unchanged, got %q", result.NullText)
+	}
+	executeSQL(t, session, "BEGIN")
+	if exec.InTransaction() || !session.InTransaction() {
+		t.Error("expected only the session to be in a transaction")
+	}
+	executeSQL(t, session, "COMMIT")
+}
+
+func TestSessionWriteWaitsForTransaction(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	reader := exec.NewSession()
+	defer reader.Close()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+
+	// A statement waiting for the transaction gives up at its timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := reader.ExecuteContext(ctx, parseSQL(t, "SELECT COUNT(*) FROM users")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the SELECT to time out waiting, got %v", err)
+	}
+
+	// ... or runs once it commits, and sees its rows
+	done := make(chan string)
+	go func() {
+		result, err := runSQL(reader, "SELECT COUNT(*) FROM users")
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		done <- resultText(result)
+	}()
+	select {
+	case got := <-done:
+		t.Fatalf("expected the SELECT to wait for COMMIT, got %q", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+	executeSQL(t, exec, "COMMIT")
+	if got := <-done; got != "1" {
+		t.Errorf("expected the committed row, got %q", got)
+	}
+}
+
+func TestSessionReadersShareDatabase(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+
+	// Two sessions read at once
+	first, second := exec.NewSession(), exec.NewSession()
+	rows1, err := first.Query(parseSQL(t, "SELECT * FROM users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows2, err := second.Query(parseSQL(t, "SELECT * FROM users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows2.Close()
+
+	// A writer waits for the rows still being read
+	done := make(chan error)
+	go func() {
+		_, err := runSQL(exec, "INSERT INTO users VALUES (2, 'Bob')")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("expected the INSERT to wait for the query, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	rows1.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("INSERT failed: %v", err)
+	}
+}
+
+func TestSessionCloseRollsBack(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+
+	session := exec.NewSession()
+	executeSQL(t, session, "BEGIN")
+	executeSQL(t, session, "INSERT INTO users VALUES (1, 'Alice')")
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The transaction is gone, and the database free for the others
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM users")); got != "2" {
+		t.Errorf("expected only the committed row, got %q", got)
+	}
+}
+
+func TestSessionsRunConcurrently(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	executeSQL(t, exec, "CREATE TABLE events (id INTEGER PRIMARY KEY, session INTEGER)")
+
+	// Sessions create and drop tables while others insert and count rows
+	const sessions, statements = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, sessions)
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session := exec.NewSession()
+			defer session.Close()
+			for j := 0; j < statements; j++ {
+				sqls := []string{
+					fmt.Sprintf("INSERT INTO events VALUES (%d, %d)", i*statements+j, i),
+					"SELECT COUNT(*) FROM events",
+				}
+				if i%2 == 0 {
+					sqls = append(sqls,
+						fmt.Sprintf("CREATE TABLE scratch_%d_%d (id INTEGER PRIMARY KEY)", i, j),
+						fmt.Sprintf("DROP TABLE scratch_%d_%d", i, j))
+				} else {
+					sqls = append(sqls, "BEGIN", "UPDATE events SET session = session WHERE id >= 0", "COMMIT")
+				}
+				for _, sql := range sqls {
+					if _, err := runSQL(session, sql); err != nil {
+						errs <- fmt.Errorf("%s: %w", sql, err)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM events")); got != fmt.Sprint(sessions*statements) {
+		t.Errorf("expected %d rows, got %s", sessions*statements, got)
+	}
+	if tables := exec.GetTables(); len(tables) != 1 || !strings.EqualFold(tables[0], "events") {
+		t.Errorf("expected only the events table left, got %v", tables)
+	}
+}
+
+func TestSessionReadOnlyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "readonly.db")
+	exec, pager := openCatalogExecutor(t, path)
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+	exec.Flush()
+	pager.Close()
+
+	exec, pager = openCatalogExecutor(t, path, storage.WithReadOnly())
+	defer pager.Close()
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users")); got != "Alice" {
+		t.Errorf("expected to read the table, got %q", got)
+	}
+	for _, sql := range []string{
+		"INSERT INTO users VALUES (2, 'Bob')",
+		"CREATE TABLE more (id INTEGER PRIMARY KEY)",
+		"SELECT * FROM users FOR UPDATE",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); !errors.Is(err, storage.ErrReadOnly) {
+			t.Errorf("%s: expected ErrReadOnly, got %v", sql, err)
+		}
+	}
+
+	// Transactions and PRAGMAs only read
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "PRAGMA integrity_check")
+	executeSQL(t, exec, "COMMIT")
+	if err := exec.Flush(); err != nil {
+		t.Errorf("Flush failed: %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+// Package executor - Session settings
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// SET changes how the session's later statements run, without changing
+// any data:
+//
+//   SET statement_timeout = 5000;      -- milliseconds
+//   SET statement_timeout = '2s';      -- or a duration with its unit
+//   SET statement_timeout = 0;         -- no limit (the default)
+//
+// A statement that runs past statement_timeout is canceled, just as if
+// its caller had canceled it (see cancel.go): the timeout is a deadline on
+// the statement's context, checked at the same points. One runaway query
+// then can't hold the database, and its locks, for as long as it likes.
+// The clock starts when the statement does; for rows read one at a time
+// (see Query) it keeps running until they are closed.
+//
+// PostgreSQL has the same setting, counted in milliseconds too, and
+// cancels with "canceling statement due to statement timeout".
+//
+// The other settings adjust, while the program runs, what it would
+// otherwise take a restart with other flags to change:
+//
+//   SET cache_size = 5000;             -- pages the page cache holds
+//   SET query_memory = '64MB';         -- see memory.go
+//   SET sort_memory = '4MB';           -- see sort.go
+//   SET analyze_threshold = -1;        -- no automatic ANALYZE (see autoanalyze.go)
+//   SET output_null = '∅';             -- how results show NULL
+//   PRAGMA cache_size;                 -- show a setting
+//
+// PRAGMA cache_size = 5000 is the same as SET, as SQLite spells it.
+// Settings belong to the session and are forgotten when it ends; none of
+// them is saved in the database.
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// SetStatementTimeout sets how long a statement may run before it is
+// canceled; 0 or less means no limit.
+func (e *Executor) SetStatementTimeout(d time.Duration) {
+	e.statementTimeout = max(d, 0)
+}
+
+// StatementTimeout returns how long a statement may run, 0 for no limit.
+func (e *Executor) StatementTimeout() time.Duration {
+	return e.statementTimeout
+}
+
+// SetOutputNull sets how Result.String shows NULL values; "" shows them
+// as NULL.
+func (e *Executor) SetOutputNull(text string) {
+	e.outputNull = text
+}
+
+// setting is a setting of the session, which SET changes and PRAGMA name
+// shows.
+type setting struct {
+	set  func(e *Executor, val table.Value) error
+	show func(e *Executor) table.Value
+}
+
+// settings are the settings SET knows, by name.
+var settings = map[string]setting{
+	"statement_timeout": {
+		set: func(e *Executor, val table.Value) error {
+			d, err := parseTimeout(val)
+			if err == nil {
+				e.SetStatementTimeout(d)
+			}
+			return err
+		},
+		show: func(e *Executor) table.Value {
+			return intValue(e.statementTimeout.Milliseconds())
+		},
+	},
+	"typing": {
+		set: func(e *Executor, val table.Value) error {
+			if val.IsNull || val.Type != parser.TypeText {
+				return fmt.Errorf("%s is not a typing mode", val.String())
+			}
+			mode, err := ParseTypingMode(val.Text)
+			if err == nil {
+				e.SetTypingMode(mode)
+			}
+			return err
+		},
+		show: func(e *Executor) table.Value { return textValue(e.typing.String()) },
+	},
+	"cache_size": {
+		set: func(e *Executor, val table.Value) error {
+			pages, err := integerSetting(val)
+			if err != nil {
+				return err
+			}
+			return e.pager.SetMaxCacheSize(int(pages))
+		},
+		show: func(e *Executor) table.Value { return intValue(int64(e.pager.MaxCacheSize())) },
+	},
+	"query_memory": {
+		set: func(e *Executor, val table.Value) error {
+			bytes, err := parseBytes(val)
+			if err == nil {
+				e.SetQueryMemory(bytes)
+			}
+			return err
+		},
+		show: func(e *Executor) table.Value { return intValue(int64(e.queryMemory)) },
+	},
+	"sort_memory": {
+		set: func(e *Executor, val table.Value) error {
+			bytes, err := parseBytes(val)
+			if err == nil {
+				e.SetSortMemory(bytes)
+			}
+			return err
+		},
+		show: func(e *Executor) table.Value { return intValue(int64(e.sortMemory)) },
+	},
+	"analyze_threshold": {
+		set: func(e *Executor, val table.Value) error {
+			threshold, err := integerSetting(val)
+			if err == nil {
+				e.SetAutoAnalyze(int(threshold), e.analyzeScale)
+			}
+			return err
+		},
+		show: func(e *Executor) table.Value { return intValue(int64(e.analyzeThreshold)) },
+	},
+	"analyze_scale": {
+		set: func(e *Executor, val table.Value) error {
+			if val.IsNull || val.Type != parser.TypeInteger && val.Type != parser.TypeReal {
+				return fmt.Errorf("%s is not a number", val.String())
+			}
+			scale := val.Real
+			if val.Type == parser.TypeInteger {
+				scale = float64(val.Integer)
+			}
+			if scale < 0 {
+				return fmt.Errorf("scale must not be negative")
+			}
+			e.SetAutoAnalyze(e.analyzeThreshold, scale)
+			return nil
+		},
+		show: func(e *Executor) table.Value {
+			return table.Value{Type: parser.TypeReal, Real: e.analyzeScale}
+		},
+	},
+	"output_null": {
+		set: func(e *Executor, val table.Value) error {
+			if val.IsNull || val.Type != parser.TypeText {
+				return fmt.Errorf("%s is not a string", val.String())
+			}
+			e.SetOutputNull(val.Text)
+			return nil
+		},
+		show: func(e *Executor) table.Value {
+			if e.outputNull == "" {
+				return textValue("NULL")
+			}
+			return textValue(e.outputNull)
+		},
+	},
+}
+
+// executeSet changes a setting of the session.
+func (e *Executor) executeSet(stmt *parser.SetStatement) (*Result, error) {
+	s, ok := settings[stmt.Name]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized setting: %s", stmt.Name)
+	}
+	val, err := e.evaluateExpression(stmt.Value, table.Row{}, table.NewSchema(nil))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.set(e, val); err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", stmt.Name, err)
+	}
+	return &Result{Message: "SET"}, nil
+}
+
+// showSetting returns the value of a setting as a one-row result, for
+// PRAGMA name.
+func (e *Executor) showSetting(name string) (*Result, bool) {
+	s, ok := settings[name]
+	if !ok {
+		return nil, false
+	}
+	return &Result{
+		Columns:  []string{name},
+		Rows:     [][]table.Value{{s.show(e)}},
+		RowCount: 1,
+	}, true
+}
+
+// intValue wraps an integer as an INTEGER value.
+func intValue(n int64) table.Value {
+	return table.Value{Type: parser.TypeInteger, Integer: n}
+}
+
+// integerSetting reads the value of a setting that is a whole number.
+func integerSetting(val table.Value) (int64, error) {
+	if val.IsNull || val.Type != parser.TypeInteger {
+		return 0, fmt.Errorf("%s is not an integer", val.String())
+	}
+	return val.Integer, nil
+}
+
+// byteUnits are the units parseBytes accepts, PostgreSQL's.
+var byteUnits = map[string]int{"B": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30}
+
+// parseBytes reads an amount of memory: a number of bytes, or a string
+// holding either that or a number with a unit, such as '64MB'. 0 means
+// no limit.
+func parseBytes(val table.Value) (int, error) {
+	var n int64
+	switch {
+	case val.IsNull:
+		return 0, fmt.Errorf("NULL")
+	case val.Type == parser.TypeInteger:
+		n = val.Integer
+	case val.Type == parser.TypeText:
+		text := strings.TrimSpace(val.Text)
+		digits := strings.TrimRightFunc(text, func(r rune) bool { return r < '0' || r > '9' })
+		unit, ok := byteUnits[strings.ToUpper(strings.TrimSpace(text[len(digits):]))]
+		if text[len(digits):] == "" {
+			unit, ok = 1, true
+		}
+		var err error
+		if n, err = strconv.ParseInt(digits, 10, 64); err != nil || !ok {
+			return 0, fmt.Errorf("%q is not an amount of memory", val.Text)
+		}
+		n *= int64(unit)
+	default:
+		return 0, fmt.Errorf("%s is not an amount of memory", val.String())
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("memory must not be negative")
+	}
+	return int(n), nil
+}
+
+// parseTimeout reads a timeout: a number of milliseconds, or a string
+// holding either that or a duration such as '1.5s'.
+func parseTimeout(val table.Value) (time.Duration, error) {
+	var d time.Duration
+	switch {
+	case val.IsNull:
+		return 0, fmt.Errorf("NULL")
+	case val.Type == parser.TypeInteger:
+		d = time.Duration(val.Integer) * time.Millisecond
+	case val.Type == parser.TypeText:
+		if ms, err := strconv.ParseInt(val.Text, 10, 64); err == nil {
+			d = time.Duration(ms) * time.Millisecond
+		} else if d, err = time.ParseDuration(val.Text); err != nil {
+			return 0, fmt.Errorf("%q is not a duration", val.Text)
+		}
+	default:
+		return 0, fmt.Errorf("%s is not a duration", val.String())
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("timeout must not be negative")
+	}
+	return d, nil
+}
+
+// statementContext returns the context a statement runs with: the
+// caller's, with the deadline of statement_timeout if one is set. The
+// statement must call cancel when it is done.
+func (e *Executor) statementContext() (ctx context.Context, cancel context.CancelFunc) {
+	if e.statementTimeout <= 0 {
+		return e.ctx, func() {}
+	}
+	parent := e.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, e.statementTimeout)
+}
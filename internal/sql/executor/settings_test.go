@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestSetStatementTimeout(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	for _, tt := range []struct {
+		sql  string
+		want time.Duration
+	}{
+		{"SET statement_timeout = 5000", 5 * time.Second},
+		{"SET Statement_Timeout TO '1.5s'", 1500 * time.Millisecond},
+		{"SET statement_timeout = '250'", 250 * time.Millisecond},
+		{"SET statement_timeout = 0", 0},
+	} {
+		if result := executeSQL(t, exec, tt.sql); result.Message != "SET" {
+			t.Errorf("%s: expected SET, got %q", tt.sql, result.Message)
+		}
+		if got := exec.StatementTimeout(); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.want, got)
+		}
+	}
+
+	for _, sql := range []string{
+		"SET statement_timeout = 'soon'",
+		"SET statement_timeout = -1",
+		"SET statement_timeout = NULL",
+		"SET search_path = 'public'",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+	if got := exec.StatementTimeout(); got != 0 {
+		t.Errorf("expected failed SETs to leave the timeout alone, got %v", got)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 10)
+
+	// SLOW(x) takes longer than the timeout, so a scan calling it times
+	// out before its second row
+	err := exec.RegisterFunction("SLOW", ScalarFunction{MinArgs: 1, MaxArgs: 1,
+		Fn: func(args []table.Value) (table.Value, error) {
+			time.Sleep(20 * time.Millisecond)
+			return args[0], nil
+		}})
+	if err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	executeSQL(t, exec, "SET statement_timeout = 5")
+
+	_, err = exec.Execute(parseSQL(t, "SELECT * FROM items WHERE SLOW(id) > 0"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the statement to time out, got %v", err)
+	}
+	if _, err := exec.Execute(parseSQL(t, "UPDATE items SET price = 0 WHERE SLOW(id) > 0")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the UPDATE to time out, got %v", err)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM items WHERE price = 0")); got != "1" {
+		t.Errorf("expected the UPDATE to change nothing, got %s", got)
+	}
+
+	// Rows read one at a time time out while they are being read
+	rows, err := exec.Query(parseSQL(t, "SELECT SLOW(id) FROM items"))
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	rows.Close()
+	if !errors.Is(rows.Err(), context.DeadlineExceeded) || n == 10 {
+		t.Errorf("expected the rows to time out, got %d rows and %v", n, rows.Err())
+	}
+
+	executeSQL(t, exec, "SET statement_timeout = 0")
+	if got := executeSQL(t, exec, "SELECT * FROM items WHERE SLOW(id) > 0"); got.RowCount != 10 {
+		t.Errorf("expected 10 rows without a timeout, got %d", got.RowCount)
+	}
+}
+
+func TestRuntimeSettings(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	for _, tt := range []struct {
+		sql, pragma, want string
+	}{
+		{"SET cache_size = 50", "PRAGMA cache_size", "50"},
+		{"PRAGMA cache_size = 5000", "PRAGMA cache_size", "5000"},
+		{"SET query_memory = '64MB'", "PRAGMA query_memory", "67108864"},
+		{"SET sort_memory = '512 kB'", "PRAGMA sort_memory", "524288"},
+		{"SET sort_memory = 0", "PRAGMA sort_memory", "0"},
+		{"SET analyze_threshold = -1", "PRAGMA analyze_threshold", "-1"},
+		{"SET analyze_scale = 0.5", "PRAGMA analyze_scale", "0.5"},
+		{"SET statement_timeout = '2s'", "PRAGMA statement_timeout", "2000"},
+		{"SET typing = 'strict'", "PRAGMA typing", "strict"},
+	} {
+		if result := executeSQL(t, exec, tt.sql); result.Message != "SET" {
+			t.Errorf("%s: expected SET, got %q", tt.sql, result.Message)
+		}
+		if got := resultText(executeSQL(t, exec, tt.pragma)); got != tt.want {
+			t.Errorf("%s: expected %s, got %q", tt.pragma, tt.want, got)
+		}
+	}
+	if got := exec.pager.MaxCacheSize(); got != 5000 {
+		t.Errorf("expected the pager's cache to hold 5000 pages, got %d", got)
+	}
+
+	// output_null changes how results show NULL
+	executeSQL(t, exec, "SET typing = 'coercive'")
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (1, NULL)")
+	executeSQL(t, exec, "SET output_null = '∅'")
+	if got := executeSQL(t, exec, "SELECT name FROM t").String(); !strings.Contains(got, "| ∅ ") {
+		t.Errorf("expected NULL shown as ∅, got\n%s", got)
+	}
+
+	for _, sql := range []string{
+		"SET cache_size = 0",
+		"SET cache_size = 'big'",
+		"SET query_memory = '10 parsecs'",
+		"SET sort_memory = -1",
+		"SET analyze_scale = -0.1",
+		"SET output_null = NULL",
+		"PRAGMA search_path",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
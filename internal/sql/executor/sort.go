@@ -0,0 +1,294 @@
+// Package executor - External merge sort
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// ORDER BY without a LIMIT has to hold every row before it can return the
+// first, and a million rows may not fit in memory. When the rows a sort
+// holds pass its memory budget (see SetSortMemory), it switches to an
+// external merge sort, the way databases have sorted since data lived on
+// tape:
+//
+//   1. Sort the rows held so far and write them out as a "run", then
+//      start collecting again. Repeat until the input ends.
+//   2. Merge the runs: read the first row of each, return the smallest,
+//      replace it with the next row of its run, and so on.
+//
+//   input:  8 3 5 | 9 1 7 | 2 6 4        (budget: 3 rows)
+//   runs:   3 5 8   1 7 9   2 4 6
+//   merge:  1 2 3 4 5 6 7 8 9
+//
+// Only one row of each run is in memory while merging, so the budget
+// limits how many runs there are, not how many rows can be sorted. A heap
+// of the runs' current rows finds the smallest in O(log runs).
+//
+// The runs are written to a scratch file through a pager, like VACUUM's,
+// so they are encrypted when the database is. PostgreSQL's work_mem is the
+// same kind of budget, and EXPLAIN ANALYZE shows "external merge" when a
+// sort had to spill, as it does here.
+
+package executor
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+const (
+	// defaultSortMemory is how many bytes of rows a sort holds before it
+	// spills them to disk.
+	defaultSortMemory = 16 << 20
+
+	// valueMemory is roughly the memory a table.Value takes, besides the
+	// text it holds.
+	valueMemory = 64
+
+	// spillCacheSize is the page cache of a sort's scratch file: enough
+	// for the page each run is being read from.
+	spillCacheSize = 64
+)
+
+// SetSortMemory sets how many bytes of rows a sort may hold in memory
+// before writing them to disk; 0 or less means no limit.
+func (e *Executor) SetSortMemory(bytes int) {
+	e.sortMemory = bytes
+}
+
+// rowMemory estimates the memory a row takes.
+func rowMemory(row table.Row) int {
+	size := 32 + len(row.Values)*valueMemory
+	for _, val := range row.Values {
+		size += len(val.Text)
+	}
+	return size
+}
+
+// compareRows compares two rows in ORDER BY order.
+func compareRows(a, b table.Row, orderBy []parser.OrderByClause, schema *table.Schema) int {
+	for _, clause := range orderBy {
+		colIdx, found := schema.GetColumnIndex(clause.Column)
+		if !found {
+			continue
+		}
+		cmp := schema.Columns[colIdx].Collation.Compare(a.Values[colIdx], b.Values[colIdx])
+		if cmp != 0 {
+			if clause.Descending {
+				return -cmp
+			}
+			return cmp
+		}
+	}
+	return 0
+}
+
+// sortSpill holds the sorted runs a sort has written to its scratch file.
+// Each run is a stream of rows, each one its length and then the row as
+// table.EncodeRow writes it, cut into pages.
+type sortSpill struct {
+	pager *storage.Pager
+	path  string
+	runs  [][]uint32 // the pages of each run
+}
+
+// newSortSpill creates a scratch file for runs, encoded with codec.
+func newSortSpill(codec storage.PageCodec) (*sortSpill, error) {
+	file, err := os.CreateTemp("", "claude-db-sort-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sort file: %w", err)
+	}
+	path := file.Name()
+	file.Close()
+
+	pager, err := storage.NewPager(path, storage.WithCodec(codec), storage.WithMaxCacheSize(spillCacheSize))
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to open sort file: %w", err)
+	}
+	return &sortSpill{pager: pager, path: path}, nil
+}
+
+// writeRun writes sorted rows as a new run.
+func (s *sortSpill) writeRun(rows []table.Row) error {
+	var pages []uint32
+	var buf []byte
+	flush := func(n int) error {
+		page, err := s.pager.AllocatePage(storage.PageTypeOverflow)
+		if err != nil {
+			return fmt.Errorf("failed to write sort run: %w", err)
+		}
+		if err := page.SetData(buf[:n]); err != nil {
+			return err
+		}
+		pages = append(pages, page.ID())
+		buf = buf[n:]
+		return nil
+	}
+
+	for _, row := range rows {
+		data, err := table.EncodeRow(row)
+		if err != nil {
+			return fmt.Errorf("failed to encode row: %w", err)
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(data)))
+		buf = append(buf, data...)
+		for len(buf) >= storage.MaxDataSize {
+			if err := flush(storage.MaxDataSize); err != nil {
+				return err
+			}
+		}
+	}
+	if len(buf) > 0 {
+		if err := flush(len(buf)); err != nil {
+			return err
+		}
+	}
+	s.runs = append(s.runs, pages)
+	return nil
+}
+
+// close deletes the scratch file.
+func (s *sortSpill) close() error {
+	err := s.pager.Close()
+	if removeErr := os.Remove(s.path); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// runReader reads the rows of a run back, a page at a time.
+type runReader struct {
+	pager *storage.Pager
+	pages []uint32 // the pages not yet read
+	buf   []byte   // the bytes read but not yet decoded
+}
+
+// read returns the next n bytes of the run.
+func (r *runReader) read(n int) ([]byte, error) {
+	for len(r.buf) < n {
+		if len(r.pages) == 0 {
+			return nil, fmt.Errorf("sort run ended in the middle of a row")
+		}
+		page, err := r.pager.GetPage(r.pages[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sort run: %w", err)
+		}
+		r.buf = append(r.buf, page.GetData()...)
+		r.pages = r.pages[1:]
+	}
+	data := r.buf[:n]
+	r.buf = r.buf[n:]
+	return data, nil
+}
+
+// next returns the next row of the run, or false at its end. The last
+// page is padded with zeros, which read as a length of 0.
+func (r *runReader) next() (table.Row, bool, error) {
+	if len(r.buf) < 4 && len(r.pages) == 0 {
+		return table.Row{}, false, nil
+	}
+	header, err := r.read(4)
+	if err != nil {
+		return table.Row{}, false, err
+	}
+	length := int(binary.LittleEndian.Uint32(header))
+	if length == 0 {
+		r.buf, r.pages = nil, nil
+		return table.Row{}, false, nil
+	}
+	data, err := r.read(length)
+	if err != nil {
+		return table.Row{}, false, err
+	}
+	row, err := table.DecodeRow(data)
+	if err != nil {
+		return table.Row{}, false, fmt.Errorf("failed to decode sorted row: %w", err)
+	}
+	return row, true, nil
+}
+
+// mergeIterator merges sorted runs, returning their rows in order.
+type mergeIterator struct {
+	runs    []*runReader
+	heads   []mergeHead // the current row of each run not yet finished
+	orderBy []parser.OrderByClause
+	schema  *table.Schema
+	started bool
+	row     table.Row
+	err     error
+}
+
+// mergeHead is the current row of a run.
+type mergeHead struct {
+	row table.Row
+	run int
+}
+
+func newMergeIterator(spill *sortSpill, orderBy []parser.OrderByClause, schema *table.Schema) *mergeIterator {
+	m := &mergeIterator{orderBy: orderBy, schema: schema}
+	for _, pages := range spill.runs {
+		m.runs = append(m.runs, &runReader{pager: spill.pager, pages: pages})
+	}
+	return m
+}
+
+// advance replaces the head of run i with its next row, if it has one.
+func (m *mergeIterator) advance(i int) bool {
+	row, ok, err := m.runs[i].next()
+	if err != nil {
+		m.err = err
+		return false
+	}
+	if ok {
+		heap.Push(m, mergeHead{row: row, run: i})
+	}
+	return true
+}
+
+func (m *mergeIterator) Next() bool {
+	if m.err != nil {
+		return false
+	}
+	if !m.started {
+		m.started = true
+		for i := range m.runs {
+			if !m.advance(i) {
+				return false
+			}
+		}
+	}
+	if len(m.heads) == 0 {
+		return false
+	}
+	head := heap.Pop(m).(mergeHead)
+	m.row = head.row
+	return m.advance(head.run)
+}
+
+func (m *mergeIterator) Row() table.Row { return m.row }
+func (m *mergeIterator) Err() error     { return m.err }
+func (m *mergeIterator) Close() error   { m.heads, m.runs = nil, nil; return nil }
+
+// heap.Interface over the heads; ties go to the earlier run, so the
+// order of rows that compare equal doesn't depend on the heap.
+func (m *mergeIterator) Len() int { return len(m.heads) }
+
+func (m *mergeIterator) Less(i, j int) bool {
+	if cmp := compareRows(m.heads[i].row, m.heads[j].row, m.orderBy, m.schema); cmp != 0 {
+		return cmp < 0
+	}
+	return m.heads[i].run < m.heads[j].run
+}
+
+func (m *mergeIterator) Swap(i, j int) { m.heads[i], m.heads[j] = m.heads[j], m.heads[i] }
+func (m *mergeIterator) Push(x any)    { m.heads = append(m.heads, x.(mergeHead)) }
+
+func (m *mergeIterator) Pop() any {
+	head := m.heads[len(m.heads)-1]
+	m.heads = m.heads[:len(m.heads)-1]
+	return head
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestExternalSort(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	setupItems(t, exec, 300)
+
+	sql := "SELECT id, price FROM items WHERE price > 2 ORDER BY price DESC, id"
+	want := resultText(executeSQL(t, exec, sql))
+
+	exec.SetSortMemory(2000)
+	if got := resultText(executeSQL(t, exec, sql)); got != want {
+		t.Errorf("external sort returned a different order:\n%s\nexpected:\n%s", got, want)
+	}
+	result := executeSQL(t, exec, "EXPLAIN ANALYZE "+sql)
+	if plan := result.String(); !strings.Contains(plan, "-> Sort (price DESC, id, external merge of") {
+		t.Errorf("expected the sort to spill:\n%s", plan)
+	}
+
+	// A LIMIT keeps only the top rows, which never spill
+	result = executeSQL(t, exec, "EXPLAIN ANALYZE "+sql+" LIMIT 3")
+	for _, row := range result.Rows {
+		if strings.Contains(row[0].Text, "external merge") {
+			t.Errorf("expected a top-K sort in memory, got %q", row[0].Text)
+		}
+	}
+}
+
+func TestSortRunsSpanPages(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	schema := table.NewSchema([]parser.ColumnDefinition{
+		{Name: "n", Type: parser.TypeInteger},
+		{Name: "s", Type: parser.TypeText},
+	})
+	var rows []table.Row
+	for i, n := range []int64{5, 3, 9, 1, 7, 3} {
+		rows = append(rows, table.Row{ID: uint64(i), Values: []table.Value{
+			{Type: parser.TypeInteger, Integer: n},
+			// Rows longer than a page, and NULLs
+			{Type: parser.TypeText, Text: strings.Repeat("x", int(n)*1500), IsNull: n == 7},
+		}})
+	}
+
+	// A budget of one byte writes each row as a run of its own
+	sorter := &sortIterator{e: exec, input: &sliceIterator{rows: rows},
+		orderBy: []parser.OrderByClause{{Column: "n"}}, schema: schema, memory: 1}
+	var got []int64
+	for sorter.Next() {
+		row := sorter.Row()
+		if !row.Values[1].IsNull && len(row.Values[1].Text) != int(row.Values[0].Integer)*1500 {
+			t.Errorf("row %d came back with %d bytes of text", row.Values[0].Integer, len(row.Values[1].Text))
+		}
+		got = append(got, row.Values[0].Integer)
+	}
+	if err := sorter.Err(); err != nil {
+		t.Fatalf("sort failed: %v", err)
+	}
+	if len(sorter.spill.runs) != len(rows) {
+		t.Errorf("expected %d runs, got %d", len(rows), len(sorter.spill.runs))
+	}
+	path := sorter.spill.path
+	if err := sorter.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := []int64{1, 3, 3, 5, 7, 9}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the sort file to be removed, got %v", err)
+	}
+}
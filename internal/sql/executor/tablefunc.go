@@ -0,0 +1,269 @@
+// Package executor - Table functions
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A table function returns rows instead of a value, and is read in FROM
+// like a table:
+//
+//   SELECT n, n * n AS square FROM generate_series(1, 10) AS n WHERE n > 5;
+//   CREATE MATERIALIZED VIEW orders_sample AS
+//     SELECT n AS id, n * 2.5 AS total FROM generate_series(1, 100000) AS n;
+//
+// generate_series(start, stop[, step]) counts from start to stop, by
+// step (1 unless given; a negative step counts down). Its one column is
+// named after the function, or after the name given with AS, as in
+// PostgreSQL. If any argument is REAL, so are the numbers.
+//
+// The rows are made one at a time as the query reads them, never stored,
+// so a series of millions of rows costs no more memory than the query
+// holding on to them (a LIMIT, say, stops it early). The rows then go
+// through the same WHERE filter, aggregation, ORDER BY and LIMIT as a
+// table's. The arguments are evaluated once, before the first row; they
+// can be parameters but not columns, since there's no table to take
+// them from.
+//
+// Table functions are how databases make rows out of nothing: for tests,
+// demos and benchmark data, or to fill the gaps in a report (a row for
+// every day, whether or not it had orders). PostgreSQL has
+// generate_series and many others, SQLite has generate_series as an
+// extension, and SQL Server has GENERATE_SERIES since 2022.
+
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// tableFunction is a function read in FROM as a table.
+type tableFunction struct {
+	minArgs, maxArgs int
+
+	// open returns the rows for the evaluated arguments, in a single
+	// column called name, and how many rows there will be
+	open func(args []table.Value, name string) (*table.Schema, rowIterator, float64, error)
+}
+
+// tableFunctions are the built-in table functions, by upper-case name.
+var tableFunctions = map[string]tableFunction{
+	"GENERATE_SERIES": {minArgs: 2, maxArgs: 3, open: generateSeries},
+}
+
+// openTableFunction evaluates the arguments of a SELECT's table function
+// and starts making its rows.
+func (e *Executor) openTableFunction(stmt *parser.SelectStatement) (*table.Schema, rowIterator, float64, error) {
+	call := stmt.FromFunc
+	fn, ok := tableFunctions[call.Name]
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("unknown table function %s", strings.ToLower(call.Name))
+	}
+	if len(call.Args) < fn.minArgs || len(call.Args) > fn.maxArgs {
+		return nil, nil, 0, fmt.Errorf("%s takes %d to %d arguments, got %d",
+			strings.ToLower(call.Name), fn.minArgs, fn.maxArgs, len(call.Args))
+	}
+
+	args := make([]table.Value, len(call.Args))
+	for i, arg := range call.Args {
+		column := ""
+		parser.WalkExpression(arg, func(expr parser.Expression) bool {
+			if ident, ok := expr.(*parser.Identifier); ok && column == "" {
+				column = ident.Name
+			}
+			return true
+		})
+		if column != "" {
+			return nil, nil, 0, fmt.Errorf("%s: argument %d refers to column %s, but there is no table to take it from",
+				strings.ToLower(call.Name), i+1, column)
+		}
+		value, err := e.evaluateExpression(arg, table.Row{}, table.NewSchema(nil))
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("%s: %w", strings.ToLower(call.Name), err)
+		}
+		args[i] = value
+	}
+	schema, it, rows, err := fn.open(args, strings.ToLower(stmt.From))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%s: %w", strings.ToLower(call.Name), err)
+	}
+	return schema, it, rows, nil
+}
+
+// selectTableFunction runs a SELECT reading a table function.
+func (e *Executor) selectTableFunction(stmt *parser.SelectStatement) (*Result, error) {
+	if len(stmt.Joins) > 0 {
+		return nil, fmt.Errorf("JOIN is not supported with table function %s", strings.ToLower(stmt.FromFunc.Name))
+	}
+	if stmt.ForUpdate {
+		return nil, fmt.Errorf("cannot lock rows of table function %s", strings.ToLower(stmt.FromFunc.Name))
+	}
+	schema, it, rows, err := e.openTableFunction(stmt)
+	if err != nil {
+		return nil, err
+	}
+	projection, agg, err := selectProjection(stmt, schema)
+	if err != nil {
+		it.Close()
+		return nil, err
+	}
+	if agg != nil {
+		agg.chooseJoined(rows)
+	}
+
+	it = e.traced(e.filtered(e.interruptible(it), stmt.Where, schema), "Function Scan", whereDetail(functionScanName(stmt), stmt.Where))
+	return e.collectResult(e.selectPipeline(stmt, it, schema, projection, agg, false), projection)
+}
+
+// explainTableFunction returns the query plan for a SELECT reading a
+// table function.
+func (e *Executor) explainTableFunction(stmt *parser.SelectStatement) (*Result, error) {
+	plan, err := e.functionScanPlan(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Columns: []string{"Property", "Value"},
+		Rows: [][]table.Value{
+			textRow("Query Plan", "Function Scan on "+functionScanName(stmt)),
+			textRow("Access Method", "Function Scan"),
+			textRow("Estimated Rows", fmt.Sprintf("%.0f", plan.Estimate.Rows)),
+		},
+	}, nil
+}
+
+// functionScanPlan returns the plan of a SELECT reading a table function:
+// always a scan of the rows it makes.
+func (e *Executor) functionScanPlan(stmt *parser.SelectStatement) (*PlanNode, error) {
+	schema, it, rows, err := e.openTableFunction(stmt)
+	if err != nil {
+		return nil, err
+	}
+	it.Close()
+	projection, agg, err := selectProjection(stmt, schema)
+	if err != nil {
+		return nil, err
+	}
+	if agg != nil {
+		agg.chooseJoined(rows)
+	}
+	scan := &PlanNode{
+		Operator: "Function Scan",
+		Detail:   whereDetail(functionScanName(stmt), stmt.Where),
+		Estimate: &PlanEstimate{Rows: rows, Cost: rows},
+	}
+	return selectPlanTail(stmt, scan, projection, agg, stmt.From, false), nil
+}
+
+// functionScanName names a table function's scan in plans: the call, and
+// the name given with AS.
+func functionScanName(stmt *parser.SelectStatement) string {
+	name := strings.ToLower(stmt.FromFunc.String())
+	if !strings.EqualFold(stmt.From, stmt.FromFunc.Name) {
+		name += " AS " + strings.ToLower(stmt.From)
+	}
+	return name
+}
+
+// generateSeries opens generate_series(start, stop[, step]).
+func generateSeries(args []table.Value, name string) (*table.Schema, rowIterator, float64, error) {
+	step := table.Value{Type: parser.TypeInteger, Integer: 1}
+	if len(args) > 2 {
+		step = args[2]
+	}
+	bounds := []table.Value{args[0], args[1], step}
+
+	typ := parser.TypeInteger
+	for i, v := range bounds {
+		switch {
+		case v.IsNull:
+			return nil, nil, 0, fmt.Errorf("argument %d is NULL", i+1)
+		case v.Type == parser.TypeReal:
+			typ = parser.TypeReal
+		case v.Type != parser.TypeInteger:
+			return nil, nil, 0, fmt.Errorf("argument %d must be a number, got %s", i+1, v.Type)
+		}
+	}
+	schema := table.NewSchema([]parser.ColumnDefinition{{Name: name, Type: typ}})
+
+	if typ == parser.TypeInteger {
+		start, stop, by := args[0].Integer, args[1].Integer, step.Integer
+		if by == 0 {
+			return nil, nil, 0, fmt.Errorf("step must not be 0")
+		}
+		rows := 0.0
+		if (by > 0 && start <= stop) || (by < 0 && start >= stop) {
+			rows = math.Floor((float64(stop)-float64(start))/float64(by)) + 1
+		}
+		return schema, &seriesIterator{next: start, stop: stop, step: by}, rows, nil
+	}
+
+	start, stop, by := toReal(bounds[0]), toReal(bounds[1]), toReal(bounds[2])
+	if by == 0 {
+		return nil, nil, 0, fmt.Errorf("step must not be 0")
+	}
+	// The epsilon keeps a stop such as 0.3 = 0.1 * 3 in the series
+	rows := max(math.Floor((stop-start)/by+1e-9)+1, 0)
+	return schema, &realSeriesIterator{start: start, step: by, count: int64(rows)}, rows, nil
+}
+
+// toReal returns an INTEGER or REAL value as a float64.
+func toReal(v table.Value) float64 {
+	if v.Type == parser.TypeReal {
+		return v.Real
+	}
+	return float64(v.Integer)
+}
+
+// seriesIterator counts from next to stop by step.
+type seriesIterator struct {
+	next, stop, step int64
+	done             bool
+	row              table.Row
+	id               uint64
+}
+
+func (it *seriesIterator) Next() bool {
+	if it.done || (it.step > 0 && it.next > it.stop) || (it.step < 0 && it.next < it.stop) {
+		return false
+	}
+	it.id++
+	it.row = table.Row{ID: it.id, Values: []table.Value{{Type: parser.TypeInteger, Integer: it.next}}}
+
+	// Stop rather than wrap around at the ends of int64
+	next := it.next + it.step
+	if (it.step > 0 && next < it.next) || (it.step < 0 && next > it.next) {
+		it.done = true
+	}
+	it.next = next
+	return true
+}
+
+func (it *seriesIterator) Row() table.Row { return it.row }
+func (it *seriesIterator) Err() error     { return nil }
+func (it *seriesIterator) Close() error   { return nil }
+
+// realSeriesIterator makes count REAL numbers from start by step. Each
+// is computed from start, rather than by adding step again and again, so
+// rounding errors don't pile up.
+type realSeriesIterator struct {
+	start, step float64
+	count, i    int64
+	row         table.Row
+}
+
+func (it *realSeriesIterator) Next() bool {
+	if it.i >= it.count {
+		return false
+	}
+	value := it.start + float64(it.i)*it.step
+	it.i++
+	it.row = table.Row{ID: uint64(it.i), Values: []table.Value{{Type: parser.TypeReal, Real: value}}}
+	return true
+}
+
+func (it *realSeriesIterator) Row() table.Row { return it.row }
+func (it *realSeriesIterator) Err() error     { return nil }
+func (it *realSeriesIterator) Close() error   { return nil }
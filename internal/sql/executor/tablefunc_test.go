@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestGenerateSeries(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	for sql, want := range map[string]string{
+		"SELECT * FROM generate_series(1, 5)":                                        "1, 2, 3, 4, 5",
+		"SELECT generate_series FROM generate_series(10, 1, -4)":                     "10, 6, 2",
+		"SELECT n, n * n AS square FROM generate_series(1, 10) AS n WHERE n > 7":     "8 64, 9 81, 10 100",
+		"SELECT COUNT(*), SUM(n), MAX(n) FROM generate_series(1, 100000) AS n":       "100000 5000050000 100000",
+		"SELECT n FROM generate_series(1, 1000000) AS n ORDER BY n DESC LIMIT 2":     "1000000, 999999",
+		"SELECT * FROM generate_series(0, 0.3, 0.1)":                                 "0, 0.1, 0.2, 0.30000000000000004",
+		"SELECT * FROM generate_series(5, 1)":                                        "",
+		"SELECT * FROM generate_series(9223372036854775806, 9223372036854775807, 5)": "9223372036854775806",
+	} {
+		if got := resultText(executeSQL(t, exec, sql)); got != want {
+			t.Errorf("%s: expected %q, got %q", sql, want, got)
+		}
+	}
+
+	// The arguments can be parameters, and the rows fill a materialized view
+	result, err := exec.ExecuteWithParams(parseSQL(t, "SELECT n FROM generate_series(?, ?) AS n"),
+		[]table.Value{intValue(3), intValue(4)})
+	if err != nil || resultText(result) != "3, 4" {
+		t.Errorf("unexpected rows with parameters %v (%v)", result, err)
+	}
+	executeSQL(t, exec, "CREATE MATERIALIZED VIEW ids AS SELECT n AS id FROM generate_series(1, 50) AS n")
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM ids")); got != "50" {
+		t.Errorf("unexpected view rows %q", got)
+	}
+	explained := resultText(executeSQL(t, exec, "EXPLAIN (FORMAT TREE) SELECT n FROM generate_series(1, 10) AS n WHERE n > 3"))
+	if !strings.Contains(explained, "Function Scan (generate_series(1, 10) AS n filter n > 3)") {
+		t.Errorf("expected a function scan, got %q", explained)
+	}
+}
+
+func TestGenerateSeriesErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE t (a INTEGER)")
+	for sql, want := range map[string]string{
+		"SELECT * FROM generate_series(1)":                         "generate_series takes 2 to 3 arguments, got 1",
+		"SELECT * FROM generate_series(1, 5, 0)":                   "step must not be 0",
+		"SELECT * FROM generate_series(1, 'x')":                    "argument 2 must be a number",
+		"SELECT * FROM generate_series(NULL, 5)":                   "argument 1 is NULL",
+		"SELECT * FROM unnest(1, 2)":                               "unknown table function unnest",
+		"SELECT * FROM generate_series(1, a)":                      "refers to column a",
+		"SELECT * FROM generate_series(1, 5) FOR UPDATE":           "cannot lock rows of table function",
+		"SELECT * FROM generate_series(1, 5) AS n JOIN t ON n = a": "JOIN is not supported with table function",
+		"SELECT x FROM generate_series(1, 5)":                      "unknown column: x",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+}
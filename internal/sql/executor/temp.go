@@ -0,0 +1,130 @@
+// Package executor - Temporary tables
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A temporary table holds rows a session needs for a while, such as
+// imported data waiting to be checked or the intermediate results of a
+// report, and goes away when the session ends:
+//
+//   CREATE TEMP TABLE staging (id INTEGER PRIMARY KEY, raw TEXT);
+//   INSERT INTO staging VALUES (1, '...');
+//
+// Its pages don't go in the database file but in a database of the
+// session's own, held in memory (see storage.MemoryPath) and created with
+// its first temporary table. The catalog never hears of it, and the
+// sessions sharing the database don't either: each keeps its temporary
+// tables, and their triggers, next to its transaction rather than with
+// the tables CREATE TABLE makes (see session.go). None of it is written
+// to disk, Close drops it with the session, and there is nothing to clean
+// up after a crash, since the memory is simply gone. SQLite keeps its temporary tables the same way, in a separate
+// "temp" database held in a file it deletes or, with temp_store=MEMORY,
+// in memory; PostgreSQL puts them in a schema of the session's own,
+// pg_temp_N, and doesn't write them to its WAL.
+//
+// Otherwise a temporary table is a table like any other: it can have
+// indexes and triggers, the same statements read and change it, and it
+// takes part in the session's transactions. Its name can't be that of a
+// table the session can see when it is created; should another session
+// later create a permanent table of the same name, the temporary table
+// hides it in its own session, as in SQLite.
+
+package executor
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// tempPager returns the pager of the temporary tables, creating it for the
+// first one. Created inside a transaction, it joins it, with a savepoint
+// for each of the transaction's so their levels keep matching.
+func (e *Executor) tempPager() (*storage.Pager, error) {
+	if e.temp != nil {
+		return e.temp, nil
+	}
+	pager, err := storage.NewPager(storage.MemoryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary database: %w", err)
+	}
+	if e.tx != nil {
+		err := pager.Begin()
+		for i := 1; err == nil && i < len(e.tx.savepoints); i++ {
+			_, err = pager.Savepoint()
+		}
+		if err != nil {
+			pager.Close()
+			return nil, fmt.Errorf("failed to create temporary database: %w", err)
+		}
+	}
+	e.temp = pager
+	return pager, nil
+}
+
+// isTemp reports whether a table is one of the session's temporary tables.
+func (e *Executor) isTemp(tableName string) bool {
+	_, ok := e.tempTables[tableName]
+	return ok
+}
+
+// lookupTable returns the table a statement of the session means by name:
+// its temporary table of that name if it has one, else the database's.
+func (e *Executor) lookupTable(tableName string) (*table.Table, bool) {
+	if tbl, ok := e.tempTables[tableName]; ok {
+		return tbl, true
+	}
+	tbl, ok := e.tables[tableName]
+	return tbl, ok
+}
+
+// setTable puts tbl in place of the table of the same name the session
+// sees, after a change that makes a new one (see executeAlterTable).
+func (e *Executor) setTable(tableName string, tbl *table.Table) {
+	if e.isTemp(tableName) {
+		e.tempTables[tableName] = tbl
+	} else {
+		e.tables[tableName] = tbl
+	}
+}
+
+// visibleTables returns the tables the session sees, by name: the
+// database's and its own temporary ones.
+func (e *Executor) visibleTables() map[string]*table.Table {
+	tables := maps.Clone(e.tables)
+	maps.Copy(tables, e.tempTables)
+	return tables
+}
+
+// catalogOf returns the catalog recording a table, with the table's name
+// in it: that of the attached database it belongs to, or the executor's
+// own. It returns nil for a temporary table, which no catalog records.
+func (e *Executor) catalogOf(tableName string) (*catalog.Catalog, string) {
+	if e.isTemp(tableName) {
+		return nil, tableName
+	}
+	if db, inner := e.databaseOf(tableName); db != nil {
+		return db.catalog, inner
+	}
+	return e.catalog, tableName
+}
+
+// DropTempTables drops the temporary tables, as the end of the session
+// does, and releases their memory.
+func (e *Executor) DropTempTables() error {
+	// Only a session with temporary tables waits its turn to drop them
+	if e.temp == nil {
+		return nil
+	}
+	leave, err := e.enterAccess(true)
+	if err != nil {
+		return err
+	}
+	defer leave()
+
+	pager := e.temp
+	e.temp, e.tempTables, e.tempTriggers = nil, nil, nil
+	return pager.Close()
+}
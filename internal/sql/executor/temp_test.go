@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTempTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "temp.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "CREATE TEMP TABLE staging (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "CREATE INDEX idx_staging_name ON staging (name)")
+	executeSQL(t, exec, "INSERT INTO staging VALUES (1, 'Alice')")
+	executeSQL(t, exec, "INSERT INTO staging VALUES (2, 'Bob')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Carol')")
+	if got := resultText(executeSQL(t, exec, "SELECT id FROM staging WHERE name = 'Bob'")); got != "2" {
+		t.Errorf("expected to find Bob, got %q", got)
+	}
+	if _, err := exec.Execute(parseSQL(t, "CREATE TEMP TABLE users (id INTEGER)")); err == nil {
+		t.Error("expected a temporary table not to take the name of another")
+	}
+	if ddl, _ := exec.TableDDL("staging"); !strings.HasPrefix(ddl[0], "CREATE TEMP TABLE staging") {
+		t.Errorf("unexpected DDL %q", ddl)
+	}
+	if info, _ := exec.catalog.GetTableInfo("staging"); info != nil {
+		t.Error("expected the catalog not to record the temporary table")
+	}
+
+	// A temporary table created in a transaction goes with its rollback,
+	// and one that existed before keeps its rows
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "DELETE FROM staging WHERE id = 1")
+	executeSQL(t, exec, "CREATE TEMP TABLE scratch (n INTEGER)")
+	executeSQL(t, exec, "ROLLBACK")
+	if _, err := exec.Execute(parseSQL(t, "SELECT * FROM scratch")); err == nil {
+		t.Error("expected the rollback to drop the temporary table")
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM staging")); got != "2" {
+		t.Errorf("expected the rollback to keep both rows, got %q", got)
+	}
+
+	executeSQL(t, exec, "VACUUM")
+	if result := executeSQL(t, exec, "PRAGMA integrity_check"); resultText(result) != "ok" {
+		t.Errorf("integrity check failed: %s", resultText(result))
+	}
+
+	// The end of the session drops the temporary tables, and the file
+	// never had them
+	if err := exec.DropTempTables(); err != nil {
+		t.Fatalf("DropTempTables: %v", err)
+	}
+	if _, err := exec.Execute(parseSQL(t, "SELECT * FROM staging")); err == nil {
+		t.Error("expected the temporary table to be gone")
+	}
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+	if _, ok := exec.GetTable("staging"); ok {
+		t.Error("expected the temporary table not to be in the file")
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users")); got != "Carol" {
+		t.Errorf("expected the permanent table to stay, got %q", got)
+	}
+}
+
+func TestTempTablesBelongToTheSession(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+	a, b := exec.NewSession(), exec.NewSession()
+	defer b.Close()
+
+	executeSQL(t, a, "CREATE TEMP TABLE staging (id INTEGER PRIMARY KEY)")
+	executeSQL(t, a, "CREATE TEMP TABLE audit (id INTEGER)")
+	executeSQL(t, a, "CREATE TRIGGER staging_audit AFTER INSERT ON staging BEGIN INSERT INTO audit VALUES (NEW.id); END")
+	executeSQL(t, a, "INSERT INTO staging VALUES (1)")
+
+	// Another session doesn't see them, and may have its own of the
+	// same names
+	if _, ok := b.GetTable("staging"); ok {
+		t.Error("expected another session not to see the temporary table")
+	}
+	if _, err := b.Execute(parseSQL(t, "SELECT * FROM staging")); err == nil {
+		t.Error("expected another session not to read the temporary table")
+	}
+	executeSQL(t, b, "CREATE TEMP TABLE staging (id INTEGER PRIMARY KEY)")
+	executeSQL(t, b, "INSERT INTO staging VALUES (2)")
+	if _, err := b.Execute(parseSQL(t, "DROP TRIGGER staging_audit")); err == nil {
+		t.Error("expected another session not to see the temporary table's trigger")
+	}
+	if got := resultText(executeSQL(t, a, "SELECT id FROM staging")); got != "1" {
+		t.Errorf("expected the session to keep its own rows, got %q", got)
+	}
+	if got := resultText(executeSQL(t, a, "SELECT id FROM audit")); got != "1" {
+		t.Errorf("expected only the session's insert to fire its trigger, got %q", got)
+	}
+
+	// A permanent table created later is hidden by the temporary one
+	executeSQL(t, exec, "CREATE TABLE audit (id INTEGER)")
+	executeSQL(t, exec, "INSERT INTO audit VALUES (7)")
+	if got := resultText(executeSQL(t, a, "SELECT id FROM audit")); got != "1" {
+		t.Errorf("expected the temporary table to hide the permanent one, got %q", got)
+	}
+
+	// Closing the session drops its temporary tables
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, ok := a.GetTable("staging"); ok {
+		t.Error("expected Close to drop the temporary table")
+	}
+	if got := resultText(executeSQL(t, a, "SELECT id FROM audit")); got != "7" {
+		t.Errorf("expected the permanent table to be seen again, got %q", got)
+	}
+	if got := resultText(executeSQL(t, b, "SELECT id FROM staging")); got != "2" {
+		t.Errorf("expected the other session's temporary table to stay, got %q", got)
+	}
+}
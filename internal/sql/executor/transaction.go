@@ -0,0 +1,451 @@
+// Package executor - Transactions
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A transaction groups statements so they take effect together or not at
+// all (the "A" in ACID, atomicity):
+//
+//   BEGIN;
+//   UPDATE accounts SET balance = balance - 100 WHERE id = 1;
+//   UPDATE accounts SET balance = balance + 100 WHERE id = 2;
+//   COMMIT;      -- or ROLLBACK to undo both updates
+//
+// Outside BEGIN ... COMMIT each statement stands alone and is written to
+// disk when the caller flushes ("autocommit").
+//
+// Rolling back touches every layer that holds state:
+//   - the pager puts back the original contents of every page the
+//     transaction changed and forgets pages it allocated
+//   - each table puts back its in-memory bookkeeping (B-tree root, next
+//     row ID, data pages), captured by table.Snapshot at BEGIN
+//   - the executor puts back its set of tables, undoing CREATE/DROP TABLE,
+//     its triggers, sequences and materialized views, and the catalog is
+//     re-read from its restored page
+//
+// A savepoint captures the same three things part way through, so
+// ROLLBACK TO name can return to it while the transaction carries on.
+// BEGIN itself is treated as the bottom savepoint, with no name.
+//
+// A statement that fires triggers changes its rows one at a time, and a
+// trigger can fail after some of them, and their triggers' changes, are
+// written; so can an UPDATE of a key, when a row's new key is another's.
+// So that the statement fails as a whole, it runs under a
+// savepoint of its own, rolled back to if it fails and released if not;
+// outside BEGIN ... COMMIT the savepoint is a transaction of its own.
+// PostgreSQL does the same for every statement, which is why an error
+// inside a transaction never leaves half a statement behind.
+//
+// Row locks taken during the transaction are released when it ends, not
+// by ROLLBACK TO (see locking.go).
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/lock"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// transaction is the state needed to roll back to BEGIN or a savepoint.
+type transaction struct {
+	// savepoints[0] is BEGIN; the rest are in the order they were taken.
+	// A savepoint's index is also its pager level.
+	savepoints []*savepoint
+
+	// owner holds the transaction's row locks until it ends.
+	owner lock.Owner
+}
+
+// savepoint is the executor's state at BEGIN or at a SAVEPOINT.
+type savepoint struct {
+	name       string
+	tables     map[string]*table.Table
+	states     map[*table.Table]table.TableState
+	triggers   map[string]*trigger
+	sequences  map[string]*sequence
+	views      map[string]*materializedView
+	partitions map[string]*partitionedTable
+	virtual    map[string]*virtualTable
+
+	// The session's temporary tables and their triggers
+	tempTables   map[string]*table.Table
+	tempTriggers map[string]*trigger
+}
+
+// newSavepoint captures the current set of tables and their bookkeeping.
+func (e *Executor) newSavepoint(name string) *savepoint {
+	sp := &savepoint{
+		name:   name,
+		tables: maps.Clone(e.tables),
+		states: make(map[*table.Table]table.TableState, len(e.tables)+len(e.tempTables)),
+
+		triggers:   maps.Clone(e.triggers),
+		sequences:  cloneSequences(e.sequences),
+		views:      maps.Clone(e.views),
+		partitions: maps.Clone(e.partitions),
+		virtual:    maps.Clone(e.virtual),
+
+		tempTables:   maps.Clone(e.tempTables),
+		tempTriggers: maps.Clone(e.tempTriggers),
+	}
+	for _, tbl := range e.tables {
+		sp.states[tbl] = tbl.Snapshot()
+	}
+	for _, tbl := range e.tempTables {
+		sp.states[tbl] = tbl.Snapshot()
+	}
+	return sp
+}
+
+// restore puts back the tables captured by newSavepoint. The pages must
+// already have been restored by the pager.
+func (e *Executor) restore(sp *savepoint) error {
+	for tbl, state := range sp.states {
+		tbl.Restore(state)
+	}
+	e.tables = maps.Clone(sp.tables)
+	e.tempTables = maps.Clone(sp.tempTables)
+	e.tempTriggers = maps.Clone(sp.tempTriggers)
+	e.triggers = maps.Clone(sp.triggers)
+	e.sequences = cloneSequences(sp.sequences)
+	e.views = maps.Clone(sp.views)
+	e.partitions = maps.Clone(sp.partitions)
+	e.virtual = maps.Clone(sp.virtual)
+
+	if e.catalog != nil {
+		if err := e.catalog.Reload(); err != nil {
+			return fmt.Errorf("failed to reload catalog: %w", err)
+		}
+	}
+	for _, alias := range e.AttachedDatabases() {
+		if err := e.attached[alias].catalog.Reload(); err != nil {
+			return fmt.Errorf("failed to reload catalog of %s: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+// discardChanges takes the database back to its last flush after cause,
+// the error of a write that found the disk full, so that the tables agree
+// with the file again (see storage/diskfull.go). Without a write-ahead
+// log the changes can't be taken back; they stay in the cache, for a
+// flush to write once there is space.
+func (e *Executor) discardChanges(cause error) error {
+	if e.catalog == nil {
+		return cause
+	}
+	if err := e.pager.Discard(); err != nil {
+		return fmt.Errorf("%w; %v, so the file may be inconsistent until a flush succeeds", cause, err)
+	}
+	if err := e.reload(); err != nil {
+		return fmt.Errorf("%w; reloading the database failed: %v", cause, err)
+	}
+	return fmt.Errorf("%w; the changes since the last flush were rolled back", cause)
+}
+
+// reload reads the main database's tables back from its catalog, dropping
+// those in memory, as after the pager went back to an earlier state.
+func (e *Executor) reload() error {
+	if err := e.catalog.Reload(); err != nil {
+		return fmt.Errorf("failed to reload catalog: %w", err)
+	}
+	for name := range e.ownTables() {
+		delete(e.tables, name)
+	}
+	e.triggers, e.sequences, e.views, e.partitions, e.virtual = nil, nil, nil, nil, nil
+	return e.load()
+}
+
+// InTransaction reports whether a BEGIN is waiting for COMMIT or ROLLBACK.
+func (e *Executor) InTransaction() bool {
+	return e.tx != nil
+}
+
+// executeBegin starts a transaction.
+func (e *Executor) executeBegin() (*Result, error) {
+	if e.tx != nil {
+		return nil, errors.New("transaction already in progress")
+	}
+
+	// Bring the catalog up to date first, so the state the pager flushes
+	// at Begin (and would return to) is complete
+	if err := e.syncCatalog(); err != nil {
+		return nil, err
+	}
+	// The attached databases take part in the transaction too
+	pagers := e.pagers()
+	for i, pager := range pagers {
+		if err := pager.Begin(); err != nil {
+			for _, begun := range pagers[:i] {
+				begun.Rollback()
+			}
+			return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+	}
+
+	e.tx = &transaction{
+		savepoints: []*savepoint{e.newSavepoint("")},
+		owner:      e.locks.NewOwner(),
+	}
+
+	return &Result{Message: "Transaction started"}, nil
+}
+
+// executeCommit makes the transaction's changes durable.
+func (e *Executor) executeCommit() (*Result, error) {
+	if e.tx == nil {
+		return nil, errors.New("no transaction in progress")
+	}
+
+	if err := e.syncCatalog(); err != nil {
+		return nil, err
+	}
+	begin := e.tx.savepoints[0]
+	defer e.locks.ReleaseAll(e.tx.owner)
+	e.tx = nil
+	pagers := e.pagers()
+	for i, pager := range pagers {
+		if err := pager.Commit(); err != nil {
+			// The pager rolled back its part (see storage.Pager.Commit);
+			// the others still open follow. A database committed before
+			// it keeps its changes, so the tables are only put back if
+			// none was.
+			for _, open := range pagers[i+1:] {
+				open.Rollback()
+			}
+			if i > 0 {
+				return nil, fmt.Errorf("failed to commit transaction: %w; the databases before it were committed", err)
+			}
+			if restoreErr := e.restore(begin); restoreErr != nil {
+				return nil, fmt.Errorf("failed to commit transaction: %w; %v", err, restoreErr)
+			}
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+
+	return &Result{Message: "Transaction committed"}, nil
+}
+
+// executeRollback discards the transaction's changes, or with TO, the
+// changes made since a savepoint.
+func (e *Executor) executeRollback(stmt *parser.RollbackStatement) (*Result, error) {
+	if e.tx == nil {
+		return nil, errors.New("no transaction in progress")
+	}
+	if stmt.Savepoint != "" {
+		return e.rollbackToSavepoint(stmt.Savepoint)
+	}
+
+	if err := e.abort(); err != nil {
+		return nil, err
+	}
+	return &Result{Message: "Transaction rolled back"}, nil
+}
+
+// abort rolls back the whole transaction and releases its locks.
+func (e *Executor) abort() error {
+	begin := e.tx.savepoints[0]
+	defer e.locks.ReleaseAll(e.tx.owner)
+	e.tx = nil
+
+	for _, pager := range e.pagers() {
+		if err := pager.Rollback(); err != nil {
+			return fmt.Errorf("failed to roll back transaction: %w", err)
+		}
+	}
+	return e.restore(begin)
+}
+
+// atomically runs a statement that changes rows, under a savepoint of its
+// own if it might fail after changing some of them (see
+// needsStatementSavepoint), so that if it fails it changes nothing.
+func (e *Executor) atomically(stmt parser.Statement, run func() (*Result, error)) (*Result, error) {
+	if !e.needsStatementSavepoint(stmt) {
+		return run()
+	}
+	if e.tx == nil {
+		if _, err := e.executeBegin(); err != nil {
+			return nil, err
+		}
+		result, err := run()
+		switch {
+		case e.tx == nil:
+			// Already rolled back, on deadlock
+			return result, err
+		case err != nil:
+			if abortErr := e.abort(); abortErr != nil {
+				return nil, fmt.Errorf("%w; rollback failed: %v", err, abortErr)
+			}
+			return nil, err
+		}
+		if _, err := e.executeCommit(); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	level := len(e.tx.savepoints)
+	for _, pager := range e.pagers() {
+		if _, err := pager.Savepoint(); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+	}
+	sp := e.newSavepoint("")
+	e.tx.savepoints = append(e.tx.savepoints, sp)
+	result, err := run()
+	if e.tx == nil {
+		return result, err
+	}
+	if err != nil {
+		for _, pager := range e.pagers() {
+			if rollbackErr := pager.RollbackTo(level); rollbackErr != nil {
+				return nil, fmt.Errorf("%w; rollback failed: %v", err, rollbackErr)
+			}
+		}
+		if restoreErr := e.restore(sp); restoreErr != nil {
+			return nil, fmt.Errorf("%w; rollback failed: %v", err, restoreErr)
+		}
+	}
+	for _, pager := range e.pagers() {
+		if releaseErr := pager.Release(level); releaseErr != nil && err == nil {
+			err = fmt.Errorf("failed to release savepoint: %w", releaseErr)
+		}
+	}
+	e.tx.savepoints = e.tx.savepoints[:level]
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// needsStatementSavepoint reports whether a statement might fail after
+// changing some of its rows: an INSERT, UPDATE or DELETE on a table with
+// triggers, or an UPDATE setting a column of the primary key or a unique
+// index, whose new value can collide with another row's part way through.
+func (e *Executor) needsStatementSavepoint(stmt parser.Statement) bool {
+	if len(e.tableTriggers(changedTable(stmt))) > 0 {
+		return true
+	}
+	update, ok := stmt.(*parser.UpdateStatement)
+	if !ok {
+		return false
+	}
+	tbl, ok := e.lookupTable(strings.ToLower(update.Table))
+	if !ok {
+		return false
+	}
+	keyColumns := tbl.Schema.PrimaryKeyNames()
+	for _, name := range tbl.ListIndexes() {
+		if idx, _ := tbl.GetIndex(name); idx != nil && idx.Unique {
+			keyColumns = append(keyColumns, idx.Columns...)
+		}
+	}
+	for _, assignment := range update.Assignments {
+		if slices.ContainsFunc(keyColumns, func(column string) bool {
+			return strings.EqualFold(column, assignment.Column)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// executeSavepoint marks a point the transaction can roll back to.
+func (e *Executor) executeSavepoint(stmt *parser.SavepointStatement) (*Result, error) {
+	if e.tx == nil {
+		return nil, errors.New("SAVEPOINT can only be used inside a transaction")
+	}
+
+	// Each pager's level always matches the savepoint's index
+	for _, pager := range e.pagers() {
+		if _, err := pager.Savepoint(); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+	}
+	e.tx.savepoints = append(e.tx.savepoints, e.newSavepoint(stmt.Name))
+
+	return &Result{Message: fmt.Sprintf("Savepoint '%s' created", stmt.Name)}, nil
+}
+
+// rollbackToSavepoint undoes the changes made since the named savepoint,
+// which stays in place so it can be rolled back to again.
+func (e *Executor) rollbackToSavepoint(name string) (*Result, error) {
+	level, err := e.findSavepoint(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pager := range e.pagers() {
+		if err := pager.RollbackTo(level); err != nil {
+			return nil, fmt.Errorf("failed to roll back to savepoint: %w", err)
+		}
+	}
+	sp := e.tx.savepoints[level]
+	e.tx.savepoints = e.tx.savepoints[:level+1]
+	if err := e.restore(sp); err != nil {
+		return nil, err
+	}
+
+	return &Result{Message: fmt.Sprintf("Rolled back to savepoint '%s'", sp.name)}, nil
+}
+
+// executeRelease forgets a savepoint and any taken after it, keeping
+// their changes.
+func (e *Executor) executeRelease(stmt *parser.ReleaseStatement) (*Result, error) {
+	if e.tx == nil {
+		return nil, errors.New("RELEASE can only be used inside a transaction")
+	}
+	level, err := e.findSavepoint(stmt.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pager := range e.pagers() {
+		if err := pager.Release(level); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+	e.tx.savepoints = e.tx.savepoints[:level]
+
+	return &Result{Message: fmt.Sprintf("Savepoint '%s' released", stmt.Name)}, nil
+}
+
+// findSavepoint returns the level of the most recent savepoint with the
+// given name. Reusing a name hides the older savepoint, as in PostgreSQL.
+func (e *Executor) findSavepoint(name string) (int, error) {
+	for level := len(e.tx.savepoints) - 1; level > 0; level-- {
+		if strings.EqualFold(e.tx.savepoints[level].name, name) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("savepoint %s does not exist", name)
+}
+
+// syncCatalog records the tables' current metadata in the catalog, and
+// those of each attached database in its own.
+func (e *Executor) syncCatalog() error {
+	if e.pager.ReadOnly() {
+		return nil // nothing can have changed
+	}
+	for _, alias := range e.AttachedDatabases() {
+		db := e.attached[alias]
+		if err := db.catalog.UpdateTables(e.tablesOf(db)); err != nil {
+			return fmt.Errorf("failed to save table metadata of %s: %w", alias, err)
+		}
+	}
+	if e.catalog == nil {
+		return nil
+	}
+	e.catalog.SetSequences(e.sequenceInfos())
+	e.catalog.SetVirtualTables(e.virtualTableInfos())
+	if err := e.catalog.UpdateTables(e.ownTables()); err != nil {
+		return fmt.Errorf("failed to save table metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// openCatalogExecutor opens (or reopens) a database file with a catalog,
+// as the REPL does.
+func openCatalogExecutor(t *testing.T, path string, opts ...storage.PagerOption) (*Executor, *storage.Pager) {
+	t.Helper()
+	pager, err := storage.NewPager(path, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create pager: %v", err)
+	}
+	cat, err := catalog.NewCatalog(pager)
+	if err != nil {
+		t.Fatalf("Failed to open catalog: %v", err)
+	}
+	exec, err := NewWithCatalog(pager, cat)
+	if err != nil {
+		t.Fatalf("Failed to load executor: %v", err)
+	}
+	return exec, pager
+}
+
+func TestTransactionRollback(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+
+	executeSQL(t, exec, "BEGIN")
+	if !exec.InTransaction() {
+		t.Fatal("expected an open transaction after BEGIN")
+	}
+	// Enough rows to allocate new data pages and split the B-tree root
+	for i := 2; i <= 300; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d')", i, i))
+	}
+	executeSQL(t, exec, "CREATE TABLE scratch (id INTEGER)")
+	executeSQL(t, exec, "ROLLBACK")
+
+	if exec.InTransaction() {
+		t.Error("expected no transaction after ROLLBACK")
+	}
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 1 {
+		t.Errorf("expected 1 row after rollback, got %d", len(rows))
+	}
+	if rows := executeSQL(t, exec, "SELECT * FROM users WHERE id = 150").Rows; len(rows) != 0 {
+		t.Errorf("rolled back row still found through the index")
+	}
+	if _, ok := exec.GetTable("scratch"); ok {
+		t.Error("expected CREATE TABLE to be rolled back")
+	}
+
+	// The table is still usable after rolling back
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+	if rows := executeSQL(t, exec, "SELECT * FROM users WHERE id = 2").Rows; len(rows) != 1 {
+		t.Errorf("expected to find row inserted after rollback")
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "BEGIN TRANSACTION")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+	executeSQL(t, exec, "COMMIT")
+
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 1 {
+		t.Errorf("expected 1 row after commit, got %d", len(rows))
+	}
+}
+
+func TestTransactionErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	for _, sql := range []string{"COMMIT", "ROLLBACK"} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s without BEGIN: expected error", sql)
+		}
+	}
+
+	executeSQL(t, exec, "BEGIN")
+	if _, err := exec.Execute(parseSQL(t, "BEGIN")); err == nil {
+		t.Error("nested BEGIN: expected error")
+	}
+	executeSQL(t, exec, "ROLLBACK")
+}
+
+func TestTransactionDurability(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tx.db")
+
+	exec, pager := openCatalogExecutor(t, path)
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	if err := exec.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	executeSQL(t, exec, "BEGIN")
+	for i := 1; i <= 200; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d')", i, i))
+	}
+	executeSQL(t, exec, "COMMIT")
+
+	// Uncommitted work is lost when the database is closed
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1000, 'uncommitted')")
+	exec.Flush()
+	pager.Close()
+
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 200 {
+		t.Errorf("expected 200 committed rows after reopening, got %d", len(rows))
+	}
+	if rows := executeSQL(t, exec, "SELECT * FROM users WHERE id = 1000").Rows; len(rows) != 0 {
+		t.Error("uncommitted row survived reopening")
+	}
+}
+
+func TestSavepoints(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	count := func() int {
+		return len(executeSQL(t, exec, "SELECT * FROM users").Rows)
+	}
+
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+	executeSQL(t, exec, "SAVEPOINT one")
+	for i := 2; i <= 150; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d')", i, i))
+	}
+	executeSQL(t, exec, "SAVEPOINT two")
+	executeSQL(t, exec, "CREATE TABLE scratch (id INTEGER)")
+
+	executeSQL(t, exec, "ROLLBACK TO SAVEPOINT two")
+	if _, ok := exec.GetTable("scratch"); ok {
+		t.Error("expected CREATE TABLE after savepoint two to be undone")
+	}
+	if got := count(); got != 150 {
+		t.Errorf("after ROLLBACK TO two: expected 150 rows, got %d", got)
+	}
+
+	executeSQL(t, exec, "ROLLBACK TO one")
+	if got := count(); got != 1 {
+		t.Errorf("after ROLLBACK TO one: expected 1 row, got %d", got)
+	}
+	if _, err := exec.Execute(parseSQL(t, "ROLLBACK TO two")); err == nil {
+		t.Error("expected savepoint two to be gone after rolling back past it")
+	}
+
+	// The savepoint survives ROLLBACK TO and can be used again
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+	executeSQL(t, exec, "ROLLBACK TO one")
+	executeSQL(t, exec, "INSERT INTO users VALUES (3, 'Carol')")
+	executeSQL(t, exec, "RELEASE SAVEPOINT one")
+	if _, err := exec.Execute(parseSQL(t, "ROLLBACK TO one")); err == nil {
+		t.Error("expected released savepoint to be gone")
+	}
+	executeSQL(t, exec, "COMMIT")
+
+	rows := executeSQL(t, exec, "SELECT name FROM users ORDER BY name").Rows
+	if len(rows) != 2 || rows[0][0].Text != "Alice" || rows[1][0].Text != "Carol" {
+		t.Errorf("expected Alice and Carol after commit, got %v", rows)
+	}
+}
+
+func TestSavepointOutsideTransaction(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	for _, sql := range []string{"SAVEPOINT a", "RELEASE a", "ROLLBACK TO a"} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil {
+			t.Errorf("%s outside a transaction: expected error", sql)
+		}
+	}
+}
+
+func TestDiscardChangesAfterDiskFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full.db")
+	exec, pager := openCatalogExecutor(t, path, storage.WithWAL(), storage.WithMaxCacheSize(4))
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice')")
+	if err := exec.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// A write fails once enough has changed that pages went to the log
+	executeSQL(t, exec, "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)")
+	for i := 2; i < 200; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, '%s')", i, strings.Repeat("x", 100)))
+	}
+	cause := fmt.Errorf("failed to write page 7: %w", storage.ErrDiskFull)
+	if err := exec.discardChanges(cause); !errors.Is(err, storage.ErrDiskFull) || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected the changes rolled back, got %v", err)
+	}
+
+	// The database is as it was flushed, and carries on from there
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users")); got != "Alice" {
+		t.Errorf("expected only the flushed row, got %q", got)
+	}
+	if tables := exec.GetTables(); len(tables) != 1 {
+		t.Errorf("expected the table created since to be gone, got %v", tables)
+	}
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+	exec.Flush()
+	pager.Close()
+
+	exec, pager = openCatalogExecutor(t, path, storage.WithWAL())
+	defer pager.Close()
+	if got := resultText(executeSQL(t, exec, "SELECT name FROM users ORDER BY id")); got != "Alice, Bob" {
+		t.Errorf("expected the rows flushed, got %q", got)
+	}
+	if result := executeSQL(t, exec, "PRAGMA integrity_check"); resultText(result) != "ok" {
+		t.Errorf("expected a sound database, got %q", resultText(result))
+	}
+
+	// Without the log, the changes stay to be written later
+	direct, directPager := openCatalogExecutor(t, filepath.Join(t.TempDir(), "direct.db"))
+	defer directPager.Close()
+	executeSQL(t, direct, "CREATE TABLE users (id INTEGER PRIMARY KEY)")
+	if err := direct.discardChanges(cause); !strings.Contains(err.Error(), "inconsistent") {
+		t.Errorf("expected the changes kept, got %v", err)
+	}
+	if tables := direct.GetTables(); len(tables) != 1 {
+		t.Errorf("expected the table kept, got %v", tables)
+	}
+}
@@ -0,0 +1,356 @@
+// Package executor - Triggers
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A trigger is a list of statements the database runs on its own whenever
+// a row of a table is inserted, updated or deleted:
+//
+//   CREATE TRIGGER log_price AFTER UPDATE ON items FOR EACH ROW BEGIN
+//     INSERT INTO price_log VALUES (OLD.id, OLD.price, NEW.price);
+//   END;
+//
+// The body sees the row being changed: NEW.price is its value after the
+// change and OLD.price before it. An INSERT only has a NEW row and a
+// DELETE only an OLD one. A BEFORE trigger runs before the row is
+// written and an AFTER trigger once it has been; if either fails, so does
+// the statement that fired it. Since the body runs inside the database,
+// an audit table or a running total kept by triggers is right whatever
+// program changed the table.
+//
+// The body is parsed once, when the trigger is created or the database is
+// opened, and each NEW.x and OLD.x in it is replaced by a ? placeholder.
+// Firing the trigger is then running its statements like prepared ones,
+// with the row's values bound as their arguments (see ExecuteWithParams).
+// Like SQLite's sqlite_master, the catalog keeps only the CREATE TRIGGER
+// statement, and parses it again each time the database is opened.
+//
+// A trigger's body may not change a table whose change is firing it,
+// whether its own table or, through other triggers, the table a chain of
+// them started from. Such a trigger could fire itself without end, and
+// the statement that fired it has found the rows it changes before
+// changing any (see scanWhere): rows moved under it would be lost.
+// SQLite and PostgreSQL allow it, and leave avoiding the loop to whoever
+// writes the trigger. Only row-level triggers exist here, without the
+// statement-level (FOR EACH STATEMENT) triggers of PostgreSQL, the WHEN
+// condition or the INSTEAD OF triggers on views.
+
+package executor
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// trigger is a trigger compiled from its CREATE TRIGGER statement.
+type trigger struct {
+	name   string
+	table  string
+	timing string // BEFORE or AFTER
+	event  string // INSERT, UPDATE or DELETE
+	sql    string // The CREATE TRIGGER statement
+	body   []parser.Statement
+
+	// refs holds, for each statement of body, the row value each of its
+	// placeholders stands for
+	refs [][]triggerRef
+}
+
+// triggerRef is a NEW.column or OLD.column reference in a trigger's body.
+type triggerRef struct {
+	old    bool
+	column string
+}
+
+// compileTrigger parses a CREATE TRIGGER statement and replaces the row
+// references of its body by placeholders.
+func compileTrigger(sql string) (*trigger, error) {
+	stmt, err := parser.New(lexer.New(sql)).Parse()
+	if err != nil {
+		return nil, err
+	}
+	create, ok := stmt.(*parser.CreateTriggerStatement)
+	if !ok {
+		return nil, fmt.Errorf("not a CREATE TRIGGER statement: %s", sql)
+	}
+
+	t := &trigger{
+		name:   strings.ToLower(create.Name),
+		table:  strings.ToLower(create.Table),
+		timing: create.Timing,
+		event:  create.Event,
+		sql:    sql,
+		body:   create.Body,
+		refs:   make([][]triggerRef, len(create.Body)),
+	}
+	for i, body := range t.body {
+		if parser.CountPlaceholders(body) > 0 {
+			return nil, fmt.Errorf("trigger %s: a trigger's body can't have parameters", t.name)
+		}
+		if target := changedTable(body); target == t.table {
+			return nil, fmt.Errorf("trigger %s: a trigger can't change its own table %s", t.name, t.table)
+		}
+
+		var refErr error
+		parser.ReplaceStatementExpressions(body, func(expr parser.Expression) parser.Expression {
+			ident, ok := expr.(*parser.Identifier)
+			if !ok {
+				return nil
+			}
+			prefix, column, found := strings.Cut(ident.Name, ".")
+			old := strings.EqualFold(prefix, "OLD")
+			if !found || !old && !strings.EqualFold(prefix, "NEW") {
+				return nil
+			}
+			if old && t.event == "INSERT" {
+				refErr = fmt.Errorf("trigger %s: an INSERT trigger has no OLD row", t.name)
+			} else if !old && t.event == "DELETE" {
+				refErr = fmt.Errorf("trigger %s: a DELETE trigger has no NEW row", t.name)
+			}
+			t.refs[i] = append(t.refs[i], triggerRef{old: old, column: column})
+			return &parser.Placeholder{Index: len(t.refs[i]) - 1}
+		})
+		if refErr != nil {
+			return nil, refErr
+		}
+	}
+	return t, nil
+}
+
+// changedTable returns the table a trigger's body statement changes, or
+// "" for a SELECT.
+func changedTable(stmt parser.Statement) string {
+	switch s := stmt.(type) {
+	case *parser.InsertStatement:
+		return strings.ToLower(s.Table)
+	case *parser.UpdateStatement:
+		return strings.ToLower(s.Table)
+	case *parser.DeleteStatement:
+		return strings.ToLower(s.Table)
+	default:
+		return ""
+	}
+}
+
+// loadTriggers compiles the triggers recorded in the catalog.
+func (e *Executor) loadTriggers() error {
+	for _, tableName := range e.catalog.ListTables() {
+		info, _ := e.catalog.GetTableInfo(tableName)
+		for _, ti := range info.Triggers {
+			t, err := compileTrigger(ti.SQL)
+			if err != nil {
+				return fmt.Errorf("failed to load trigger %s: %w", ti.Name, err)
+			}
+			if e.triggers == nil {
+				e.triggers = make(map[string]*trigger)
+			}
+			e.triggers[t.name] = t
+		}
+	}
+	return nil
+}
+
+// executeCreateTrigger handles CREATE TRIGGER statements.
+func (e *Executor) executeCreateTrigger(stmt *parser.CreateTriggerStatement) (*Result, error) {
+	name, tableName := strings.ToLower(stmt.Name), strings.ToLower(stmt.Table)
+	if strings.Contains(tableName, ".") {
+		return nil, fmt.Errorf("cannot create trigger %s on %s: only tables of the main database can have triggers", name, tableName)
+	}
+	if err := e.checkNotVirtual(tableName, "create a trigger on"); err != nil {
+		return nil, err
+	}
+	tbl, exists := e.lookupTable(tableName)
+	if !exists {
+		return nil, fmt.Errorf("table %s does not exist", tableName)
+	}
+	if _, exists := e.lookupTrigger(name); exists {
+		return nil, fmt.Errorf("trigger %s already exists", name)
+	}
+	if err := e.checkNotView(tableName, "create a trigger on"); err != nil {
+		return nil, err
+	}
+	if err := e.checkNotPartition(tableName, "create a trigger on"); err != nil {
+		return nil, err
+	}
+
+	t, err := compileTrigger(stmt.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, refs := range t.refs {
+		for _, ref := range refs {
+			if _, ok := tbl.Schema.GetColumnIndex(ref.column); !ok {
+				return nil, fmt.Errorf("trigger %s: table %s has no column %s", name, tableName, ref.column)
+			}
+		}
+	}
+
+	// A temporary table's triggers are the session's, like the table
+	switch {
+	case e.isTemp(tableName):
+		if e.tempTriggers == nil {
+			e.tempTriggers = make(map[string]*trigger)
+		}
+		e.tempTriggers[name] = t
+	default:
+		if e.catalog != nil {
+			if err := e.catalog.AddTrigger(tableName, name, t.sql); err != nil {
+				return nil, fmt.Errorf("failed to save trigger: %w", err)
+			}
+		}
+		if e.triggers == nil {
+			e.triggers = make(map[string]*trigger)
+		}
+		e.triggers[name] = t
+	}
+
+	return &Result{
+		Message: fmt.Sprintf("Trigger '%s' created", name),
+	}, nil
+}
+
+// executeDropTrigger handles DROP TRIGGER statements.
+func (e *Executor) executeDropTrigger(stmt *parser.DropTriggerStatement) (*Result, error) {
+	name := strings.ToLower(stmt.Name)
+	t, exists := e.lookupTrigger(name)
+	if !exists {
+		return nil, fmt.Errorf("trigger %s does not exist", name)
+	}
+
+	if _, ok := e.tempTriggers[name]; ok {
+		delete(e.tempTriggers, name)
+	} else {
+		if e.catalog != nil {
+			if err := e.catalog.RemoveTrigger(t.table, name); err != nil {
+				return nil, fmt.Errorf("failed to remove trigger: %w", err)
+			}
+		}
+		delete(e.triggers, name)
+	}
+
+	return &Result{
+		Message: fmt.Sprintf("Trigger '%s' dropped", name),
+	}, nil
+}
+
+// dropTableTriggers forgets the triggers of a dropped table. The catalog
+// forgets them with the table.
+func (e *Executor) dropTableTriggers(tableName string) {
+	maps.DeleteFunc(e.triggersOf(tableName), func(_ string, t *trigger) bool {
+		return t.table == tableName
+	})
+}
+
+// lookupTrigger returns a trigger the session sees by name, whether on a
+// table of the database or on one of its temporary tables.
+func (e *Executor) lookupTrigger(name string) (*trigger, bool) {
+	if t, ok := e.tempTriggers[name]; ok {
+		return t, true
+	}
+	t, ok := e.triggers[name]
+	return t, ok
+}
+
+// triggersOf returns the map holding the triggers of a table: the
+// session's for a temporary table, else the database's.
+func (e *Executor) triggersOf(tableName string) map[string]*trigger {
+	if e.isTemp(tableName) {
+		return e.tempTriggers
+	}
+	return e.triggers
+}
+
+// tableTriggers returns the triggers of a table, by name.
+func (e *Executor) tableTriggers(tableName string) []*trigger {
+	var triggers []*trigger
+	for _, t := range e.triggersOf(tableName) {
+		if t.table == tableName {
+			triggers = append(triggers, t)
+		}
+	}
+	slices.SortFunc(triggers, func(a, b *trigger) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return triggers
+}
+
+// fireTriggers runs the triggers of a table with the given timing and
+// event for one row, whose values are old before the change and new after
+// it (nil for the one the event doesn't have). Triggers of the same kind
+// run in order of name.
+func (e *Executor) fireTriggers(tableName, timing, event string, schema *table.Schema, old, new []table.Value) error {
+	if len(e.triggers) == 0 && len(e.tempTriggers) == 0 {
+		return nil
+	}
+	var triggers []*trigger
+	for _, t := range e.tableTriggers(tableName) {
+		if t.timing == timing && t.event == event {
+			triggers = append(triggers, t)
+		}
+	}
+	if len(triggers) == 0 {
+		return nil
+	}
+
+	// The body runs as statements of its own: it has its own arguments,
+	// no plan cache and no EXPLAIN ANALYZE trace
+	params, prepared, trace := e.params, e.prepared, e.trace
+	e.prepared, e.trace = nil, nil
+	if e.firing == nil {
+		e.firing = make(map[string]bool)
+	}
+	e.firing[tableName] = true
+	defer func() {
+		e.params, e.prepared, e.trace = params, prepared, trace
+		delete(e.firing, tableName)
+	}()
+
+	for _, t := range triggers {
+		for i, stmt := range t.body {
+			args := make([]table.Value, len(t.refs[i]))
+			for j, ref := range t.refs[i] {
+				idx, ok := schema.GetColumnIndex(ref.column)
+				if !ok {
+					return fmt.Errorf("trigger %s: table %s has no column %s", t.name, tableName, ref.column)
+				}
+				if ref.old {
+					args[j] = old[idx]
+				} else {
+					args[j] = new[idx]
+				}
+			}
+			e.params = args
+			if err := e.executeTriggerStatement(stmt); err != nil {
+				return fmt.Errorf("trigger %s: %w", t.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// executeTriggerStatement runs a statement of a trigger's body.
+func (e *Executor) executeTriggerStatement(stmt parser.Statement) error {
+	if target := changedTable(stmt); e.firing[target] {
+		return fmt.Errorf("cannot change table %s while a change to it is firing triggers", target)
+	}
+
+	var err error
+	switch s := stmt.(type) {
+	case *parser.InsertStatement:
+		_, err = e.executeInsert(s)
+	case *parser.UpdateStatement:
+		_, err = e.executeUpdate(s)
+	case *parser.DeleteStatement:
+		_, err = e.executeDelete(s)
+	case *parser.SelectStatement:
+		_, err = e.executeSelect(s)
+	default:
+		err = fmt.Errorf("unsupported statement in trigger: %s", stmt)
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTriggers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "triggers.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, price INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE price_log (item INTEGER, old_price INTEGER, new_price INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE totals (id INTEGER PRIMARY KEY, n INTEGER, total INTEGER)")
+	executeSQL(t, exec, "INSERT INTO totals VALUES (1, 0, 0)")
+	executeSQL(t, exec, `CREATE TRIGGER count_items AFTER INSERT ON items BEGIN
+		UPDATE totals SET n = n + 1, total = total + NEW.price WHERE id = 1;
+	END`)
+	executeSQL(t, exec, `CREATE TRIGGER log_price AFTER UPDATE ON items FOR EACH ROW BEGIN
+		INSERT INTO price_log VALUES (OLD.id, OLD.price, NEW.price);
+		UPDATE totals SET total = total - OLD.price + NEW.price WHERE id = 1;
+	END`)
+	executeSQL(t, exec, `CREATE TRIGGER uncount_items AFTER DELETE ON items BEGIN
+		UPDATE totals SET n = n - 1, total = total - OLD.price WHERE id = 1;
+	END`)
+
+	executeSQL(t, exec, "INSERT INTO items VALUES (1, 10)")
+	executeSQL(t, exec, "INSERT INTO items VALUES (2, 20)")
+	executeSQL(t, exec, "UPDATE items SET price = price * 2")
+	executeSQL(t, exec, "DELETE FROM items WHERE id = 1")
+	if got := resultText(executeSQL(t, exec, "SELECT n, total FROM totals")); got != "1 40" {
+		t.Errorf("expected 1 item for 40, got %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT * FROM price_log")); got != "1 10 20, 2 20 40" {
+		t.Errorf("unexpected price log %q", got)
+	}
+
+	// The triggers are saved with the database, and .schema shows them
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	executeSQL(t, exec, "INSERT INTO items VALUES (3, 5)")
+	if got := resultText(executeSQL(t, exec, "SELECT n, total FROM totals")); got != "2 45" {
+		t.Errorf("expected the triggers to fire after reopening, got %q", got)
+	}
+	ddl, _ := exec.TableDDL("items")
+	if len(ddl) != 4 || !strings.HasPrefix(ddl[1], "CREATE TRIGGER count_items AFTER INSERT ON items FOR EACH ROW BEGIN UPDATE totals") {
+		t.Errorf("unexpected DDL %q", ddl)
+	}
+
+	// Rolling back undoes a DROP TRIGGER, along with what the trigger did
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "DELETE FROM items WHERE id = 3")
+	executeSQL(t, exec, "DROP TRIGGER uncount_items")
+	executeSQL(t, exec, "ROLLBACK")
+	executeSQL(t, exec, "DELETE FROM items WHERE id = 3")
+	if got := resultText(executeSQL(t, exec, "SELECT n, total FROM totals")); got != "1 40" {
+		t.Errorf("expected the dropped trigger back after ROLLBACK, got %q", got)
+	}
+
+	executeSQL(t, exec, "VACUUM")
+	executeSQL(t, exec, "DROP TRIGGER log_price")
+	executeSQL(t, exec, "UPDATE items SET price = 1")
+	if got := resultText(executeSQL(t, exec, "SELECT n, total FROM totals")); got != "1 40" {
+		t.Errorf("expected a dropped trigger not to fire, got %q", got)
+	}
+
+	// Dropping a table drops its triggers
+	executeSQL(t, exec, "DROP TABLE items")
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, price INTEGER)")
+	executeSQL(t, exec, "INSERT INTO items VALUES (1, 10)")
+	if got := resultText(executeSQL(t, exec, "SELECT n, total FROM totals")); got != "1 40" {
+		t.Errorf("expected the triggers to go with their table, got %q", got)
+	}
+}
+
+func TestBeforeTriggerStopsChange(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, balance INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE history (id INTEGER, old_balance INTEGER, new_balance INTEGER NOT NULL)")
+	executeSQL(t, exec, "INSERT INTO accounts VALUES (1, 100)")
+	executeSQL(t, exec, `CREATE TRIGGER keep_history BEFORE UPDATE ON accounts BEGIN
+		INSERT INTO history VALUES (OLD.id, OLD.balance, NEW.balance);
+	END`)
+
+	executeSQL(t, exec, "UPDATE accounts SET balance = 50 WHERE id = 1")
+	_, err := exec.Execute(parseSQL(t, "UPDATE accounts SET balance = NULL WHERE id = 1"))
+	if err == nil || !strings.Contains(err.Error(), "trigger keep_history") {
+		t.Fatalf("expected the trigger to stop the update, got %v", err)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT balance FROM accounts")); got != "50" {
+		t.Errorf("expected the balance to stay 50, got %q", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT * FROM history")); got != "1 100 50" {
+		t.Errorf("unexpected history %q", got)
+	}
+}
+
+func TestFailingTriggerUndoesStatement(t *testing.T) {
+	exec, pager := openCatalogExecutor(t, filepath.Join(t.TempDir(), "undo.db"))
+	defer pager.Close()
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, v INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE audit (v INTEGER PRIMARY KEY)")
+	executeSQL(t, exec, "INSERT INTO audit VALUES (10)")
+	executeSQL(t, exec, "CREATE TRIGGER t_ins AFTER INSERT ON t BEGIN INSERT INTO audit VALUES (NEW.v); END")
+	executeSQL(t, exec, "CREATE TRIGGER t_upd AFTER UPDATE ON t BEGIN INSERT INTO audit VALUES (NEW.v); END")
+	executeSQL(t, exec, "CREATE TRIGGER t_del BEFORE DELETE ON t BEGIN INSERT INTO audit VALUES (OLD.v + 100); END")
+
+	unchanged := func(when, wantT, wantAudit string) {
+		t.Helper()
+		if got := resultText(executeSQL(t, exec, "SELECT id, v FROM t ORDER BY id")); got != wantT {
+			t.Errorf("%s: expected t to hold %q, got %q", when, wantT, got)
+		}
+		if got := resultText(executeSQL(t, exec, "SELECT v FROM audit ORDER BY v")); got != wantAudit {
+			t.Errorf("%s: expected audit to hold %q, got %q", when, wantAudit, got)
+		}
+	}
+	fails := func(sql string) {
+		t.Helper()
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), "trigger") {
+			t.Fatalf("%s: expected the trigger to fail, got %v", sql, err)
+		}
+	}
+
+	// The row the trigger fails for isn't left behind
+	fails("INSERT INTO t VALUES (1, 10)")
+	unchanged("after a failed INSERT", "", "10")
+
+	// Nor are the rows changed before it, or what their triggers did
+	executeSQL(t, exec, "INSERT INTO audit VALUES (3)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (1, 1)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (2, 2)")
+	fails("UPDATE t SET v = v + 1")
+	unchanged("after a failed UPDATE", "1 1, 2 2", "1, 2, 3, 10")
+	executeSQL(t, exec, "INSERT INTO audit VALUES (102)")
+	fails("DELETE FROM t")
+	unchanged("after a failed DELETE", "1 1, 2 2", "1, 2, 3, 10, 102")
+
+	// Inside a transaction only the failed statement is undone, and the
+	// transaction carries on
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "INSERT INTO t VALUES (4, 4)")
+	fails("INSERT INTO t VALUES (5, 10)")
+	unchanged("inside a transaction", "1 1, 2 2, 4 4", "1, 2, 3, 4, 10, 102")
+	executeSQL(t, exec, "COMMIT")
+	unchanged("after COMMIT", "1 1, 2 2, 4 4", "1, 2, 3, 4, 10, 102")
+	if got := resultText(executeSQL(t, exec, "PRAGMA integrity_check")); got != "ok" {
+		t.Errorf("expected integrity_check ok, got %s", got)
+	}
+}
+
+func TestTriggerErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE a (id INTEGER PRIMARY KEY)")
+	executeSQL(t, exec, "CREATE TABLE b (id INTEGER PRIMARY KEY)")
+	executeSQL(t, exec, "CREATE TRIGGER a_to_b AFTER INSERT ON a BEGIN INSERT INTO b VALUES (NEW.id); END")
+
+	for sql, want := range map[string]string{
+		"CREATE TRIGGER a_to_b AFTER DELETE ON a BEGIN SELECT 1; END":                  "already exists",
+		"CREATE TRIGGER t AFTER INSERT ON c BEGIN SELECT 1; END":                       "does not exist",
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN INSERT INTO b VALUES (OLD.id); END":  "no OLD row",
+		"CREATE TRIGGER t AFTER DELETE ON a BEGIN INSERT INTO b VALUES (NEW.id); END":  "no NEW row",
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN INSERT INTO b VALUES (NEW.age); END": "no column age",
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN DELETE FROM a; END":                  "its own table",
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN INSERT INTO b VALUES (?); END":       "parameters",
+		"DROP TRIGGER t": "does not exist",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+
+	// A chain of triggers can't come back to the table it started from
+	executeSQL(t, exec, "CREATE TRIGGER b_to_a AFTER INSERT ON b BEGIN DELETE FROM a WHERE id = NEW.id; END")
+	_, err := exec.Execute(parseSQL(t, "INSERT INTO a VALUES (1)"))
+	if err == nil || !strings.Contains(err.Error(), "cannot change table a") {
+		t.Errorf("expected the loop to be refused, got %v", err)
+	}
+}
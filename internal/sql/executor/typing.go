@@ -0,0 +1,119 @@
+// Package executor - Typing modes
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A value written to a column doesn't always have the column's type: an
+// INSERT may give an INTEGER column the text '42', or a REAL column the
+// integer 3. Databases disagree about what to do. PostgreSQL converts a
+// value only when the conversion is safe and rejects the rest; MySQL, out
+// of strict mode, converts anything, turning 'abc' into 0 with a warning;
+// SQLite stores whatever it is given. Two modes cover the useful ground:
+//
+//   SET typing = 'coercive';   -- the default
+//   SET typing = 'strict';
+//
+// In coercive mode a value is converted with the rules of CAST (see
+// table.Cast), so '42' becomes 42 and 3.7 becomes 3, while 'abc' can't
+// become an INTEGER and is an error: nothing is ever stored as garbage.
+//
+// In strict mode a value must already have the column's type, or be one
+// whose meaning can't change on the way in:
+//
+//   INTEGER -> REAL, DECIMAL      widening a number
+//   REAL    -> DECIMAL           a literal like 19.99 is REAL
+//   TEXT    -> TIMESTAMP, JSON   these are written as text literals
+//
+// Everything else, '42' in an INTEGER column or 3.7 in one, is rejected.
+// Either way, a TIMESTAMP, JSON or DECIMAL value is checked (parsed,
+// validated, fitted to the precision) on the way in.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// TypingMode is how values of another type are stored in a column.
+type TypingMode int
+
+const (
+	// CoerciveTyping converts a value to the column's type by the rules
+	// of CAST, rejecting it if it can't be converted.
+	CoerciveTyping TypingMode = iota
+	// StrictTyping rejects a value of another type, except for the few
+	// conversions that can't change its meaning.
+	StrictTyping
+)
+
+func (m TypingMode) String() string {
+	if m == StrictTyping {
+		return "strict"
+	}
+	return "coercive"
+}
+
+// ParseTypingMode reads the name of a typing mode, as SET typing takes it.
+func ParseTypingMode(name string) (TypingMode, error) {
+	switch strings.ToLower(name) {
+	case "coercive":
+		return CoerciveTyping, nil
+	case "strict":
+		return StrictTyping, nil
+	default:
+		return 0, fmt.Errorf("unknown typing mode %q (expected 'strict' or 'coercive')", name)
+	}
+}
+
+// SetTypingMode sets how INSERT and UPDATE store values of another type
+// than their column's.
+func (e *Executor) SetTypingMode(mode TypingMode) {
+	e.typing = mode
+}
+
+// TypingMode returns how values of another type than their column's are
+// stored.
+func (e *Executor) TypingMode() TypingMode {
+	return e.typing
+}
+
+// coerceToColumn converts a value to be stored in a column, following the
+// typing mode.
+func (e *Executor) coerceToColumn(val table.Value, col table.Column) (table.Value, error) {
+	if val.IsNull {
+		return table.Value{Type: col.Type, IsNull: true}, nil
+	}
+	if val.Type != col.Type && e.typing == StrictTyping && !strictConversion(val.Type, col.Type) {
+		return table.Value{}, fmt.Errorf("column %s is %s, got %s %s", col.Name, col.Type, val.Type, val.String())
+	}
+
+	var converted table.Value
+	var err error
+	if col.Type == parser.TypeDecimal {
+		converted, err = fitDecimal(val, col.Precision, col.Scale)
+	} else {
+		converted, err = table.Cast(val, col.Type)
+	}
+	if err != nil {
+		return table.Value{}, fmt.Errorf("column %s: %w", col.Name, err)
+	}
+	return converted, nil
+}
+
+// strictConversion reports whether strict mode lets a value of type from
+// be stored in a column of type to.
+func strictConversion(from, to parser.DataType) bool {
+	switch to {
+	case parser.TypeReal:
+		return from == parser.TypeInteger
+	case parser.TypeDecimal:
+		return from == parser.TypeInteger || from == parser.TypeReal
+	case parser.TypeTimestamp, parser.TypeJSON:
+		return from == parser.TypeText
+	default:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoerciveTyping(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, score REAL, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO t VALUES ('1', 2, 3)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (2.9, '4.5', 'x')")
+
+	result := executeSQL(t, exec, "SELECT id, score, name FROM t ORDER BY id")
+	if got := resultText(result); got != "1 2 3, 2 4.5 x" {
+		t.Errorf("expected values converted to the column types, got %q", got)
+	}
+
+	// Text that isn't a number is an error, not garbage
+	if _, err := exec.Execute(parseSQL(t, "INSERT INTO t VALUES ('abc', 1, 'y')")); err == nil {
+		t.Error("expected 'abc' to be rejected for an INTEGER column")
+	}
+	if _, err := exec.Execute(parseSQL(t, "UPDATE t SET score = 'high' WHERE id = 1")); err == nil {
+		t.Error("expected 'high' to be rejected for a REAL column")
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM t")); got != "2" {
+		t.Errorf("expected 2 rows, got %s", got)
+	}
+}
+
+func TestStrictTyping(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	if result := executeSQL(t, exec, "SET typing = 'strict'"); result.Message != "SET" {
+		t.Fatalf("expected SET, got %q", result.Message)
+	}
+	if exec.TypingMode() != StrictTyping {
+		t.Fatalf("expected strict typing, got %v", exec.TypingMode())
+	}
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY, score REAL, price DECIMAL(6,2), at TIMESTAMP, name TEXT)")
+
+	// Widening a number and writing a timestamp as text are allowed
+	executeSQL(t, exec, "INSERT INTO t VALUES (1, 2, 19.99, '2024-03-01 12:00:00', 'a')")
+
+	for _, sql := range []string{
+		"INSERT INTO t VALUES ('2', 1.0, 1, '2024-03-01', 'b')",
+		"INSERT INTO t VALUES (2.5, 1.0, 1, '2024-03-01', 'b')",
+		"INSERT INTO t VALUES (2, 1.0, 1, '2024-03-01', 5)",
+		"UPDATE t SET score = '3.5' WHERE id = 1",
+	} {
+		_, err := exec.Execute(parseSQL(t, sql))
+		if err == nil || !strings.Contains(err.Error(), "column") {
+			t.Errorf("%s: expected a type error, got %v", sql, err)
+		}
+	}
+
+	// NULL fits any column
+	executeSQL(t, exec, "INSERT INTO t VALUES (2, NULL, NULL, NULL, NULL)")
+
+	executeSQL(t, exec, "SET typing = 'coercive'")
+	executeSQL(t, exec, "INSERT INTO t VALUES ('3', 1, 1, '2024-03-01', 5)")
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM t")); got != "3" {
+		t.Errorf("expected 3 rows, got %s", got)
+	}
+
+	if _, err := exec.Execute(parseSQL(t, "SET typing = 'loose'")); err == nil {
+		t.Error("expected an unknown typing mode to be rejected")
+	}
+}
@@ -0,0 +1,107 @@
+// Package executor - User-defined functions
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// An embedded database runs inside the application's process, so the
+// application can extend SQL with its own Go code. SQLite's
+// sqlite3_create_function works this way, and so does RegisterFunction:
+//
+//   exec.RegisterFunction("slugify", executor.ScalarFunction{
+//       MinArgs: 1, MaxArgs: 1, NullOnNullInput: true,
+//       Fn: func(args []table.Value) (table.Value, error) {
+//           return table.TextValue(slug.Make(args[0].String())), nil
+//       },
+//   })
+//
+//   SELECT * FROM posts WHERE slugify(title) = 'hello-world'
+//
+// The executor checks the argument count before calling Fn, so Fn can index
+// args without bounds checks. User functions live on one Executor, not in a
+// global table, so two databases in the same program don't see each
+// other's functions.
+
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// specialFunctions are evaluated directly on the AST (they short-circuit,
+// or use the executor's sequences) and so can never be replaced by a
+// registered function.
+var specialFunctions = map[string]bool{
+	"COALESCE": true,
+	"IFNULL":   true,
+	"NULLIF":   true,
+	"NEXTVAL":  true,
+	"CURRVAL":  true,
+}
+
+// RegisterFunction makes fn callable from SQL under name, which is
+// case-insensitive. Registering the same name again replaces the earlier
+// function; built-in functions cannot be replaced.
+func (e *Executor) RegisterFunction(name string, fn ScalarFunction) error {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	if upper == "" {
+		return fmt.Errorf("function name cannot be empty")
+	}
+	if _, builtin := builtinFunctions[upper]; builtin || specialFunctions[upper] || aggregateFunctions[upper] {
+		return fmt.Errorf("cannot redefine built-in function %s", upper)
+	}
+	if fn.Fn == nil {
+		return fmt.Errorf("function %s has no implementation", upper)
+	}
+	if fn.MinArgs < 0 || (fn.MaxArgs >= 0 && fn.MaxArgs < fn.MinArgs) || fn.MaxArgs < -1 {
+		return fmt.Errorf("function %s: invalid argument count %d..%d", upper, fn.MinArgs, fn.MaxArgs)
+	}
+
+	leave, err := e.enterAccess(true)
+	if err != nil {
+		return err
+	}
+	defer leave()
+	if e.functions == nil {
+		e.functions = make(map[string]ScalarFunction)
+	}
+	e.functions[upper] = fn
+	return nil
+}
+
+// UnregisterFunction removes a function added with RegisterFunction.
+// It reports whether the function existed.
+func (e *Executor) UnregisterFunction(name string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	leave, err := e.enterAccess(true)
+	if err != nil {
+		return false
+	}
+	defer leave()
+	if _, ok := e.functions[upper]; !ok {
+		return false
+	}
+	delete(e.functions, upper)
+	return true
+}
+
+// lookupFunction finds a built-in or registered function by uppercase name.
+func (e *Executor) lookupFunction(name string) (ScalarFunction, bool) {
+	if fn, ok := lookupFunction(name); ok {
+		return fn, true
+	}
+	fn, ok := e.functions[name]
+	return fn, ok
+}
+
+// invokeFunction calls fn, turning a panic into an error so a bug in an
+// application's function fails the statement instead of the process.
+func invokeFunction(fn ScalarFunction, args []table.Value) (result table.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("function panicked: %v", r)
+		}
+	}()
+	return fn.Fn(args)
+}
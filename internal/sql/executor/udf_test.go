@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+func TestRegisterFunction(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	err := exec.RegisterFunction("double", ScalarFunction{
+		MinArgs: 1, MaxArgs: 1, NullOnNullInput: true,
+		Fn: func(args []table.Value) (table.Value, error) {
+			n, err := args[0].AsInt64()
+			return table.IntegerValue(n * 2), err
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction error: %v", err)
+	}
+
+	err = exec.RegisterFunction("Initials", ScalarFunction{
+		MinArgs: 1, MaxArgs: -1,
+		Fn: func(args []table.Value) (table.Value, error) {
+			var sb strings.Builder
+			for _, arg := range args {
+				if !arg.IsNull && arg.Text != "" {
+					sb.WriteByte(arg.Text[0])
+				}
+			}
+			return table.TextValue(sb.String()), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction error: %v", err)
+	}
+
+	executeSQL(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, qty INTEGER, first TEXT, last TEXT)")
+	executeSQL(t, exec, "INSERT INTO items VALUES (1, 3, 'Ada', 'Lovelace')")
+	executeSQL(t, exec, "INSERT INTO items VALUES (2, 8, 'Alan', 'Turing')")
+	executeSQL(t, exec, "INSERT INTO items VALUES (3, NULL, 'Grace', NULL)")
+
+	result := executeSQL(t, exec, "SELECT id FROM items WHERE DOUBLE(qty) > 10")
+	if len(result.Rows) != 1 || result.Rows[0][0].Integer != 2 {
+		t.Errorf("expected row 2, got %v", result.Rows)
+	}
+
+	result = executeSQL(t, exec, "SELECT id FROM items WHERE initials(first, last) = 'AL'")
+	if len(result.Rows) != 1 || result.Rows[0][0].Integer != 1 {
+		t.Errorf("expected row 1, got %v", result.Rows)
+	}
+
+	result = executeSQL(t, exec, "SELECT id FROM items WHERE initials(first, last) = 'G'")
+	if len(result.Rows) != 1 || result.Rows[0][0].Integer != 3 {
+		t.Errorf("expected row 3, got %v", result.Rows)
+	}
+
+	// Arity is checked before the function runs
+	if _, err := exec.Execute(parseSQL(t, "SELECT id FROM items WHERE double(qty, 2) > 0")); err == nil {
+		t.Error("expected argument count error")
+	}
+	if _, err := exec.Execute(parseSQL(t, "SELECT id FROM items WHERE initials() = ''")); err == nil {
+		t.Error("expected argument count error")
+	}
+
+	if !exec.UnregisterFunction("DOUBLE") {
+		t.Error("expected UnregisterFunction to find DOUBLE")
+	}
+	if _, err := exec.Execute(parseSQL(t, "SELECT id FROM items WHERE double(qty) > 0")); err == nil {
+		t.Error("expected unknown function error after unregistering")
+	}
+}
+
+func TestRegisterFunctionErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	identity := func(args []table.Value) (table.Value, error) { return args[0], nil }
+
+	tests := []struct {
+		name string
+		fn   ScalarFunction
+	}{
+		{"", ScalarFunction{MinArgs: 1, MaxArgs: 1, Fn: identity}},
+		{"upper", ScalarFunction{MinArgs: 1, MaxArgs: 1, Fn: identity}},
+		{"coalesce", ScalarFunction{MinArgs: 1, MaxArgs: 1, Fn: identity}},
+		{"nofn", ScalarFunction{MinArgs: 1, MaxArgs: 1}},
+		{"badarity", ScalarFunction{MinArgs: 2, MaxArgs: 1, Fn: identity}},
+	}
+
+	for _, tt := range tests {
+		if err := exec.RegisterFunction(tt.name, tt.fn); err == nil {
+			t.Errorf("RegisterFunction(%q) expected error", tt.name)
+		}
+	}
+}
+
+func TestRegisteredFunctionPanicBecomesError(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	exec.RegisterFunction("boom", ScalarFunction{
+		Fn: func(args []table.Value) (table.Value, error) {
+			panic(fmt.Sprintf("bad input %d", len(args)))
+		},
+	})
+
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	executeSQL(t, exec, "INSERT INTO t VALUES (1)")
+
+	_, err := exec.Execute(parseSQL(t, "SELECT id FROM t WHERE boom() = 1"))
+	if err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("expected panic to be reported as an error, got %v", err)
+	}
+}
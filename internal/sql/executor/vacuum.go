@@ -0,0 +1,128 @@
+// Package executor - VACUUM
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The database file only ever grows. Space is wasted in several ways:
+//
+//   - DELETE leaves a tombstone where the row was (see table.DeleteRow)
+//   - an UPDATE that makes a row longer moves it, leaving another one
+//   - B-tree leaves emptied by deletes are never merged away
+//   - DROP TABLE and DROP INDEX put their pages on the free list, which
+//     reuses them but never gives them back to the file system
+//   - updating or deleting a large row abandons its overflow pages
+//
+// VACUUM gets the space back the way SQLite's VACUUM does:
+//
+//   1. Build a fresh database in a scratch file: the catalog, then each
+//      table's live rows packed into full pages, then its B-trees built
+//      bottom up from the sorted keys.
+//   2. Copy the scratch database over the real one, page by page, and
+//      cut the file down to the new size (see storage.Pager.CopyFrom).
+//   3. Point the executor's tables and the catalog at the new pages.
+//
+// The copy is one big write, so with a write-ahead log it commits or
+// rolls back as a whole. It needs free disk space for the scratch file,
+// and like any schema change it can't run inside a transaction.
+//
+// PostgreSQL's plain VACUUM only marks dead rows as reusable without
+// shrinking anything; its VACUUM FULL is the rebuild done here.
+
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// executeVacuum rebuilds the database without its unused space.
+func (e *Executor) executeVacuum() (*Result, error) {
+	if e.tx != nil {
+		return nil, errors.New("VACUUM cannot run inside a transaction")
+	}
+	pagesBefore := e.pager.PageCount()
+
+	scratchFile, err := os.CreateTemp("", "claude-db-vacuum-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch database: %w", err)
+	}
+	scratchPath := scratchFile.Name()
+	scratchFile.Close()
+	defer os.Remove(scratchPath)
+
+	// An encrypted database must not be copied to a plain scratch file
+	scratch, err := storage.NewPager(scratchPath, storage.WithCodec(e.pager.Codec()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scratch database: %w", err)
+	}
+	defer scratch.Close()
+
+	// The catalog must come first, since it lives on page 0
+	var scratchCatalog *catalog.Catalog
+	if e.catalog != nil {
+		if scratchCatalog, err = catalog.NewCatalog(scratch); err != nil {
+			return nil, fmt.Errorf("failed to create scratch catalog: %w", err)
+		}
+		scratchCatalog.SetSequences(e.sequenceInfos())
+		scratchCatalog.SetVirtualTables(e.virtualTableInfos())
+	}
+
+	// Copy tables in name order, so the same data gives the same file.
+	// The tables of attached databases stay in their own files.
+	names := make([]string, 0, len(e.tables))
+	for name := range e.ownTables() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	compacted := make(map[string]*table.Table, len(names))
+	for _, name := range names {
+		tbl, err := e.tables[name].CompactInto(scratch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compact table %s: %w", name, err)
+		}
+		compacted[name] = tbl
+		if scratchCatalog != nil {
+			var sql, viewSQL string
+			var triggers []catalog.TriggerInfo
+			if info, ok := e.catalog.GetTableInfo(name); ok {
+				sql, viewSQL, triggers = info.SQL, info.ViewSQL, info.Triggers
+			}
+			if err := scratchCatalog.AddTable(name, tbl, sql); err != nil {
+				return nil, fmt.Errorf("failed to save table metadata: %w", err)
+			}
+			if viewSQL != "" {
+				if err := scratchCatalog.SetView(name, viewSQL); err != nil {
+					return nil, fmt.Errorf("failed to save materialized view %s: %w", name, err)
+				}
+			}
+			for _, t := range triggers {
+				if err := scratchCatalog.AddTrigger(name, t.Name, t.SQL); err != nil {
+					return nil, fmt.Errorf("failed to save trigger %s: %w", t.Name, err)
+				}
+			}
+		}
+	}
+
+	if err := e.pager.CopyFrom(scratch); err != nil {
+		return nil, fmt.Errorf("failed to replace database: %w", err)
+	}
+	for name, tbl := range compacted {
+		e.tables[name] = tbl.Reopen(e.pager)
+	}
+	if e.catalog != nil {
+		if err := e.catalog.Reload(); err != nil {
+			return nil, fmt.Errorf("failed to reload catalog: %w", err)
+		}
+	}
+
+	return &Result{
+		Message: fmt.Sprintf("Vacuumed %d table(s): %d pages -> %d pages",
+			len(names), pagesBefore, e.pager.PageCount()),
+	}, nil
+}
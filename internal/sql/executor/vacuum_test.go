@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+func TestVacuum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vacuum.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)")
+	executeSQL(t, exec, "CREATE INDEX idx_age ON users (age)")
+	for i := 1; i <= 600; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO users VALUES (%d, 'user%d', %d)", i, i, 20+i%10))
+	}
+	executeSQL(t, exec, "CREATE TABLE scratch (id INTEGER PRIMARY KEY, note TEXT)")
+	for i := 1; i <= 100; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO scratch VALUES (%d, 'note')", i))
+	}
+	executeSQL(t, exec, "DROP TABLE scratch")
+	executeSQL(t, exec, "DELETE FROM users WHERE id > 100")
+	executeSQL(t, exec, fmt.Sprintf("UPDATE users SET name = '%s' WHERE id <= 10", strings.Repeat("x", 100)))
+	if err := exec.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	pagesBefore := pager.PageCount()
+
+	result := executeSQL(t, exec, "VACUUM")
+	if !strings.Contains(result.Message, "Vacuumed 1 table(s)") {
+		t.Errorf("unexpected message %q", result.Message)
+	}
+	if pages := pager.PageCount(); pages >= pagesBefore/2 {
+		t.Errorf("expected VACUUM to at least halve %d pages, left %d", pagesBefore, pages)
+	}
+	stat, _ := os.Stat(path)
+	if stat.Size() != int64(pager.PageCount())*storage.PageSize {
+		t.Errorf("expected file of %d pages, got %d bytes", pager.PageCount(), stat.Size())
+	}
+
+	// Rows, the primary key and the secondary index all survive
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 100 {
+		t.Errorf("expected 100 rows after VACUUM, got %d", len(rows))
+	}
+	rows := executeSQL(t, exec, "SELECT name FROM users WHERE id = 7").Rows
+	if len(rows) != 1 || rows[0][0].Text != strings.Repeat("x", 100) {
+		t.Errorf("primary key lookup after VACUUM returned %v", rows)
+	}
+	if rows := executeSQL(t, exec, "SELECT id FROM users WHERE age = 23").Rows; len(rows) != 10 {
+		t.Errorf("expected 10 rows with age 23, got %d", len(rows))
+	}
+	executeSQL(t, exec, "INSERT INTO users VALUES (1000, 'after', 40)")
+	executeSQL(t, exec, "DELETE FROM users WHERE id = 50")
+
+	// The rebuilt file is a valid database
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	if tables := exec.GetTables(); len(tables) != 1 || tables[0] != "users" {
+		t.Errorf("expected only users after reopening, got %v", tables)
+	}
+	if rows := executeSQL(t, exec, "SELECT * FROM users").Rows; len(rows) != 100 {
+		t.Errorf("expected 100 rows after reopening, got %d", len(rows))
+	}
+	if rows := executeSQL(t, exec, "SELECT name FROM users WHERE id = 1000").Rows; len(rows) != 1 {
+		t.Error("row inserted after VACUUM not found after reopening")
+	}
+}
+
+func TestVacuumInTransaction(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	executeSQL(t, exec, "BEGIN")
+	stmt, _ := parser.New(lexer.New("VACUUM")).Parse()
+	if _, err := exec.Execute(stmt); err == nil {
+		t.Error("expected VACUUM to fail inside a transaction")
+	}
+	executeSQL(t, exec, "ROLLBACK")
+
+	// Without a catalog VACUUM still compacts the tables
+	executeSQL(t, exec, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	for i := 1; i <= 300; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO t VALUES (%d)", i))
+	}
+	executeSQL(t, exec, "DELETE FROM t WHERE id > 3")
+	executeSQL(t, exec, "VACUUM")
+	if rows := executeSQL(t, exec, "SELECT * FROM t WHERE id = 2").Rows; len(rows) != 1 {
+		t.Errorf("expected to find id 2 after VACUUM, got %v", rows)
+	}
+}
+
+func TestVacuumOverflowPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vacuum_overflow.db")
+	exec, pager := openCatalogExecutor(t, path)
+
+	big := strings.Repeat("0123456789", 2000)
+	executeSQL(t, exec, "CREATE TABLE docs (id INTEGER PRIMARY KEY, body TEXT)")
+	for i := 1; i <= 6; i++ {
+		executeSQL(t, exec, fmt.Sprintf("INSERT INTO docs VALUES (%d, '%s')", i, big))
+	}
+	// Deleting and shrinking rows abandons their overflow chains
+	executeSQL(t, exec, "DELETE FROM docs WHERE id > 3")
+	executeSQL(t, exec, "UPDATE docs SET body = 'short' WHERE id = 3")
+	pagesBefore := pager.PageCount()
+
+	executeSQL(t, exec, "VACUUM")
+	if pages := pager.PageCount(); pages >= pagesBefore/2 {
+		t.Errorf("expected VACUUM to drop abandoned overflow pages, %d -> %d pages", pagesBefore, pages)
+	}
+
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	rows := executeSQL(t, exec, "SELECT id, body FROM docs").Rows
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows after reopening, got %d", len(rows))
+	}
+	for _, row := range rows {
+		want := big
+		if row[0].Integer == 3 {
+			want = "short"
+		}
+		if row[1].Text != want {
+			t.Errorf("row %d: expected %d bytes of text, got %d", row[0].Integer, len(want), len(row[1].Text))
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Package executor - Virtual tables
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A virtual table looks like a table to a query but keeps its rows
+// somewhere else. CREATE VIRTUAL TABLE names the module providing them
+// and its arguments; the csv module reads a CSV file:
+//
+//   CREATE VIRTUAL TABLE logs USING csv('logs/access.csv');
+//   SELECT path, COUNT(*) FROM logs WHERE status >= 500
+//   GROUP BY path ORDER BY COUNT(*) DESC LIMIT 10;
+//
+// The file's first line names the columns. Their types are guessed from
+// the file when the table is created: a column is INTEGER if every value
+// in it is a whole number, REAL if every value is a number, and TEXT
+// otherwise. Empty fields are NULL.
+//
+// The remote module reads a table of another claude-db server instead
+// (see remote.go).
+//
+// Only the statement is stored in the catalog, never the rows: each
+// SELECT reads the file again from the start, a row at a time, so the
+// table always shows the file as it is now, and the file can be far
+// bigger than memory. The rows then go through the same WHERE filter,
+// aggregation, ORDER BY and LIMIT as a table's. With no pages there is
+// nothing to index, lock or change, so INSERT, UPDATE, DELETE, ALTER
+// TABLE, CREATE INDEX, triggers and joins are refused; DROP TABLE
+// forgets the table and leaves the file alone.
+//
+// SQLite has the same statement and a csv module among its extensions,
+// and PostgreSQL's file_fdw serves the same purpose as a "foreign table".
+// Both, like this one, read the whole file for every query: a file has
+// no index to seek in.
+
+package executor
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cabewaldrop/claude-db/internal/catalog"
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// virtualTable is a table created by CREATE VIRTUAL TABLE.
+type virtualTable struct {
+	name   string
+	sql    string   // The CREATE VIRTUAL TABLE statement, for the catalog
+	module string   // What provides the rows: csv or remote
+	args   []string // The module's arguments, such as a file name
+
+	mu     sync.Mutex // Guards the loading of schema by concurrent readers
+	schema *table.Schema
+}
+
+// isVirtualTable reports whether name is a virtual table.
+func (e *Executor) isVirtualTable(name string) bool {
+	_, ok := e.virtual[strings.ToLower(name)]
+	return ok
+}
+
+// checkNotVirtual returns an error if a table is a virtual table, whose
+// rows can only be read.
+func (e *Executor) checkNotVirtual(tableName, action string) error {
+	if e.isVirtualTable(tableName) {
+		return fmt.Errorf("cannot %s virtual table %s: its rows are kept outside the database", action, tableName)
+	}
+	return nil
+}
+
+// VirtualTables returns the names of the virtual tables, sorted. They
+// aren't among GetTables, having no table.Table.
+func (e *Executor) VirtualTables() []string {
+	leave, _ := e.enterAccess(false)
+	defer leave()
+	return slices.Sorted(maps.Keys(e.virtual))
+}
+
+// executeCreateVirtualTable handles CREATE VIRTUAL TABLE statements.
+func (e *Executor) executeCreateVirtualTable(stmt *parser.CreateVirtualTableStatement) (*Result, error) {
+	name := strings.ToLower(stmt.Name)
+	if strings.Contains(name, ".") {
+		return nil, fmt.Errorf("cannot create virtual table %s: only the main database can have virtual tables", name)
+	}
+	if _, exists := e.lookupTable(name); exists || isSystemView(name) || e.isVirtualTable(name) {
+		return nil, fmt.Errorf("table %s already exists", name)
+	}
+
+	vt, err := newVirtualTable(name, stmt)
+	if err != nil {
+		return nil, err
+	}
+	if err := vt.load(e.ctx); err != nil {
+		return nil, err
+	}
+	if e.virtual == nil {
+		e.virtual = make(map[string]*virtualTable)
+	}
+	e.virtual[name] = vt
+
+	return &Result{
+		Message: fmt.Sprintf("Virtual table '%s' created", name),
+	}, nil
+}
+
+// newVirtualTable checks a CREATE VIRTUAL TABLE statement. The columns
+// are read from where the rows are kept by load.
+func newVirtualTable(name string, stmt *parser.CreateVirtualTableStatement) (*virtualTable, error) {
+	switch stmt.Module {
+	case "csv":
+		if len(stmt.Args) != 1 {
+			return nil, fmt.Errorf("csv takes 1 argument, the file name, got %d", len(stmt.Args))
+		}
+	case "remote":
+		if len(stmt.Args) != 2 {
+			return nil, fmt.Errorf("remote takes 2 arguments, the server's URL and a table name, got %d", len(stmt.Args))
+		}
+		if err := checkRemoteArgs(stmt.Args[0], stmt.Args[1]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown virtual table module %s: expected csv or remote", stmt.Module)
+	}
+	return &virtualTable{name: name, sql: stmt.String(), module: stmt.Module, args: stmt.Args}, nil
+}
+
+// load reads the table's columns, unless it has already.
+func (vt *virtualTable) load(ctx context.Context) error {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	if vt.schema != nil {
+		return nil
+	}
+	var schema *table.Schema
+	var err error
+	if vt.module == "remote" {
+		schema, err = remoteSchema(ctx, vt.args[0], vt.args[1])
+	} else {
+		schema, err = csvSchema(vt.args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("virtual table %s: %w", vt.name, err)
+	}
+	vt.schema = schema
+	return nil
+}
+
+// open starts reading the table's rows.
+func (vt *virtualTable) open(ctx context.Context) (rowIterator, error) {
+	if vt.module == "remote" {
+		rows, err := remoteRows(ctx, vt.args[0], vt.args[1], vt.schema)
+		if err != nil {
+			return nil, fmt.Errorf("virtual table %s: %w", vt.name, err)
+		}
+		return &sliceIterator{rows: rows}, nil
+	}
+	return vt.openCSV()
+}
+
+// source describes where the table's rows are kept, for EXPLAIN.
+func (vt *virtualTable) source() string {
+	if vt.module == "remote" {
+		return fmt.Sprintf("remote table %s at %s", vt.args[1], vt.args[0])
+	}
+	return "csv file " + vt.args[0]
+}
+
+// csvSchema reads a CSV file's header for the column names, and the rest
+// of the file for their types.
+func csvSchema(path string) (*table.Schema, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("csv file %s has no header line", path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	columns := make([]parser.ColumnDefinition, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, col := range header {
+		col = strings.ToLower(strings.TrimSpace(col))
+		if !isPlainName(col) {
+			return nil, fmt.Errorf("csv file %s: column %q isn't a name", path, header[i])
+		}
+		if seen[col] {
+			return nil, fmt.Errorf("csv file %s: column %s appears twice", path, col)
+		}
+		seen[col] = true
+		columns[i] = parser.ColumnDefinition{Name: col, Type: parser.TypeInteger}
+	}
+
+	// Each column starts out as INTEGER and widens to REAL or TEXT when
+	// a value doesn't fit
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for i, field := range record {
+			switch {
+			case field == "" || columns[i].Type == parser.TypeText:
+			case columns[i].Type == parser.TypeInteger && isInteger(field):
+			case isNumber(field):
+				columns[i].Type = parser.TypeReal
+			default:
+				columns[i].Type = parser.TypeText
+			}
+		}
+	}
+	return table.NewSchema(columns), nil
+}
+
+// isInteger reports whether a CSV field is a whole number.
+func isInteger(field string) bool {
+	_, err := strconv.ParseInt(field, 10, 64)
+	return err == nil
+}
+
+// isNumber reports whether a CSV field is a number.
+func isNumber(field string) bool {
+	_, err := strconv.ParseFloat(field, 64)
+	return err == nil
+}
+
+// loadVirtualTables recreates the virtual tables recorded in the catalog.
+// Their files are read when they are first queried, so a database whose
+// file has gone still opens, and a column's type follows the file.
+func (e *Executor) loadVirtualTables() error {
+	for _, info := range e.catalog.VirtualTables() {
+		parsed, err := parser.New(lexer.New(info.SQL)).Parse()
+		if err != nil {
+			return fmt.Errorf("failed to parse virtual table %s: %w", info.Name, err)
+		}
+		stmt, ok := parsed.(*parser.CreateVirtualTableStatement)
+		if !ok {
+			return fmt.Errorf("virtual table %s: %q is not CREATE VIRTUAL TABLE", info.Name, info.SQL)
+		}
+		vt, err := newVirtualTable(info.Name, stmt)
+		if err != nil {
+			return fmt.Errorf("failed to load virtual table %s: %w", info.Name, err)
+		}
+		if e.virtual == nil {
+			e.virtual = make(map[string]*virtualTable)
+		}
+		e.virtual[info.Name] = vt
+	}
+	return nil
+}
+
+// virtualTableInfos describes the virtual tables for the catalog, by name.
+func (e *Executor) virtualTableInfos() []catalog.VirtualTableInfo {
+	infos := make([]catalog.VirtualTableInfo, 0, len(e.virtual))
+	for _, name := range e.VirtualTables() {
+		infos = append(infos, catalog.VirtualTableInfo{Name: name, SQL: e.virtual[name].sql})
+	}
+	return infos
+}
+
+// dropVirtualTable forgets a virtual table. Its file is left alone.
+func (e *Executor) dropVirtualTable(name string) (*Result, error) {
+	delete(e.virtual, name)
+	return &Result{
+		Message: fmt.Sprintf("Table '%s' dropped", name),
+	}, nil
+}
+
+// selectVirtualTable runs a SELECT on a virtual table, reading its rows
+// from where they are kept.
+func (e *Executor) selectVirtualTable(stmt *parser.SelectStatement) (*Result, error) {
+	name := strings.ToLower(stmt.From)
+	vt := e.virtual[name]
+	if stmt.ForUpdate {
+		return nil, fmt.Errorf("cannot lock rows of virtual table %s", name)
+	}
+	if err := vt.load(e.ctx); err != nil {
+		return nil, err
+	}
+	projection, agg, err := selectProjection(stmt, vt.schema)
+	if err != nil {
+		return nil, err
+	}
+	if agg != nil {
+		agg.chooseJoined(0)
+	}
+
+	it, err := vt.open(e.ctx)
+	if err != nil {
+		return nil, err
+	}
+	it = e.traced(e.filtered(it, stmt.Where, vt.schema), "Virtual Table Scan", whereDetail(name+" on "+vt.source(), stmt.Where))
+	return e.collectResult(e.selectPipeline(stmt, it, vt.schema, projection, agg, false), projection)
+}
+
+// explainVirtualTable returns the query plan for a SELECT on a virtual
+// table: always a scan of all its rows.
+func (e *Executor) explainVirtualTable(stmt *parser.SelectStatement) (*Result, error) {
+	name := strings.ToLower(stmt.From)
+	vt := e.virtual[name]
+	if err := vt.load(e.ctx); err != nil {
+		return nil, err
+	}
+	if _, _, err := selectProjection(stmt, vt.schema); err != nil {
+		return nil, err
+	}
+	return &Result{
+		Columns: []string{"Property", "Value"},
+		Rows: [][]table.Value{
+			textRow("Query Plan", "Virtual Table Scan on "+name),
+			textRow("Access Method", "Virtual Table Scan"),
+			textRow("Storage", vt.source()),
+		},
+	}, nil
+}
+
+// openCSV starts reading the table's CSV file, after its header.
+func (vt *virtualTable) openCSV() (*csvIterator, error) {
+	f, err := os.Open(vt.args[0])
+	if err != nil {
+		return nil, fmt.Errorf("virtual table %s: %w", vt.name, err)
+	}
+	it := &csvIterator{vt: vt, file: f, reader: csv.NewReader(f)}
+	it.reader.FieldsPerRecord = len(vt.schema.Columns)
+	it.reader.ReuseRecord = true
+	if _, err := it.reader.Read(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("virtual table %s: the header of %s: %w", vt.name, vt.args[0], err)
+	}
+	return it, nil
+}
+
+// csvIterator reads a virtual table's rows from its CSV file, one line at
+// a time.
+type csvIterator struct {
+	vt     *virtualTable
+	file   *os.File
+	reader *csv.Reader
+	row    table.Row
+	err    error
+}
+
+func (it *csvIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	record, err := it.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return false
+	} else if err != nil {
+		it.err = fmt.Errorf("virtual table %s: %w", it.vt.name, err)
+		return false
+	}
+
+	values := make([]table.Value, len(record))
+	for i, field := range record {
+		if values[i], err = csvValue(field, it.vt.schema.Columns[i].Type); err != nil {
+			line, _ := it.reader.FieldPos(i)
+			it.err = fmt.Errorf("virtual table %s: line %d, column %s: %w",
+				it.vt.name, line, it.vt.schema.Columns[i].Name, err)
+			return false
+		}
+	}
+	it.row = table.Row{Values: values}
+	return true
+}
+
+func (it *csvIterator) Row() table.Row { return it.row }
+func (it *csvIterator) Err() error     { return it.err }
+func (it *csvIterator) Close() error   { return it.file.Close() }
+
+// csvValue converts a CSV field to a value of a column's type. An empty
+// field is NULL.
+func csvValue(field string, typ parser.DataType) (table.Value, error) {
+	if field == "" {
+		return table.Value{Type: typ, IsNull: true}, nil
+	}
+	switch typ {
+	case parser.TypeInteger:
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return table.Value{}, fmt.Errorf("%q is not an integer", field)
+		}
+		return table.Value{Type: typ, Integer: n}, nil
+	case parser.TypeReal:
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return table.Value{}, fmt.Errorf("%q is not a number", field)
+		}
+		return table.Value{Type: typ, Real: f}, nil
+	default:
+		return textValue(field), nil
+	}
+}
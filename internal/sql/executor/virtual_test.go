@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVirtualTables(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "access.csv")
+	data := "Path,Status,Ms,Agent\n" +
+		"/,200,12.5,curl\n" +
+		"/login,500,80,\"Mozilla, 5.0\"\n" +
+		"/,200,9,\n" +
+		"/admin,403,3,curl\n" +
+		"/login,500,120.25,curl\n"
+	if err := os.WriteFile(csvPath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "virtual.db")
+	exec, pager := openCatalogExecutor(t, path)
+	executeSQL(t, exec, "CREATE VIRTUAL TABLE logs USING csv('"+csvPath+"')")
+
+	for sql, want := range map[string]string{
+		"SELECT path, ms FROM logs WHERE status >= 400 ORDER BY ms DESC":             "/login 120.25, /login 80, /admin 3",
+		"SELECT path, COUNT(*), SUM(ms) FROM logs GROUP BY path ORDER BY path":       "/ 2 21.5, /admin 1 3, /login 2 200.25",
+		"SELECT agent FROM logs WHERE status = 500 ORDER BY ms LIMIT 1":              "Mozilla, 5.0",
+		"SELECT COUNT(*), COUNT(agent) FROM logs":                                    "5 4",
+		"SELECT table_type FROM information_schema.tables WHERE table_name = 'logs'": "VIRTUAL TABLE",
+	} {
+		if got := resultText(executeSQL(t, exec, sql)); got != want {
+			t.Errorf("%s: expected %q, got %q", sql, want, got)
+		}
+	}
+	if got := resultText(executeSQL(t, exec, "EXPLAIN SELECT * FROM logs")); !strings.Contains(got, "Virtual Table Scan") {
+		t.Errorf("expected a virtual table scan, got %q", got)
+	}
+
+	// The table reads the file as it is now
+	f, err := os.OpenFile(csvPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("/,200,7,wget\n")
+	f.Close()
+	if got := resultText(executeSQL(t, exec, "SELECT COUNT(*) FROM logs")); got != "6" {
+		t.Errorf("expected the appended row to be read, got %q", got)
+	}
+
+	// A CREATE rolled back is forgotten, and the table survives reopening
+	executeSQL(t, exec, "BEGIN")
+	executeSQL(t, exec, "CREATE VIRTUAL TABLE more USING csv('"+csvPath+"')")
+	executeSQL(t, exec, "ROLLBACK")
+	exec.Flush()
+	pager.Close()
+	exec, pager = openCatalogExecutor(t, path)
+	defer pager.Close()
+
+	if got := exec.VirtualTables(); len(got) != 1 || got[0] != "logs" {
+		t.Errorf("expected only logs after reopening, got %v", got)
+	}
+	if got := resultText(executeSQL(t, exec, "SELECT status FROM logs WHERE path = '/admin'")); got != "403" {
+		t.Errorf("unexpected status after reopening %q", got)
+	}
+	ddl, _ := exec.TableDDL("logs")
+	if len(ddl) != 1 || ddl[0] != "CREATE VIRTUAL TABLE logs USING csv('"+csvPath+"')" {
+		t.Errorf("unexpected DDL %q", ddl)
+	}
+
+	// Dropping the table leaves the file alone
+	executeSQL(t, exec, "DROP TABLE logs")
+	if exec.isVirtualTable("logs") {
+		t.Error("expected logs to be dropped")
+	}
+	if _, err := os.Stat(csvPath); err != nil {
+		t.Errorf("expected the file to remain: %v", err)
+	}
+}
+
+func TestVirtualTableErrors(t *testing.T) {
+	exec, cleanup := setupTestExecutor(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.csv")
+	os.WriteFile(good, []byte("a,b\n1,x\n"), 0o644)
+	duplicate := filepath.Join(dir, "duplicate.csv")
+	os.WriteFile(duplicate, []byte("a,A\n1,2\n"), 0o644)
+	empty := filepath.Join(dir, "empty.csv")
+	os.WriteFile(empty, nil, 0o644)
+
+	executeSQL(t, exec, "CREATE VIRTUAL TABLE v USING csv('"+good+"')")
+	executeSQL(t, exec, "CREATE TABLE t (a INTEGER)")
+
+	for sql, want := range map[string]string{
+		"INSERT INTO v VALUES (2, 'y')":                                 "cannot insert into virtual table v",
+		"UPDATE v SET b = 'y'":                                          "cannot update virtual table v",
+		"DELETE FROM v":                                                 "cannot delete from virtual table v",
+		"ALTER TABLE v ADD COLUMN c INTEGER":                            "cannot alter virtual table v",
+		"CREATE INDEX idx_a ON v (a)":                                   "cannot index virtual table v",
+		"SELECT * FROM t JOIN v ON t.a = v.a":                           "JOIN is not supported with virtual table v",
+		"SELECT * FROM v FOR UPDATE":                                    "cannot lock rows of virtual table v",
+		"CREATE TABLE v (a INTEGER)":                                    "table v already exists",
+		"CREATE VIRTUAL TABLE t USING csv('" + good + "')":              "table t already exists",
+		"CREATE VIRTUAL TABLE w USING json('" + good + "')":             "unknown virtual table module json",
+		"CREATE VIRTUAL TABLE w USING csv()":                            "csv takes 1 argument",
+		"CREATE VIRTUAL TABLE w USING csv('" + duplicate + "')":         "column a appears twice",
+		"CREATE VIRTUAL TABLE w USING csv('" + empty + "')":             "has no header line",
+		"CREATE VIRTUAL TABLE w USING csv('" + dir + "/missing.csv')":   "no such file",
+		"CREATE TRIGGER trg AFTER INSERT ON v BEGIN DELETE FROM t; END": "cannot create a trigger on virtual table v",
+	} {
+		if _, err := exec.Execute(parseSQL(t, sql)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", sql, want, err)
+		}
+	}
+
+	// A value that no longer fits its column's type fails the query
+	os.WriteFile(good, []byte("a,b\n1,x\nlots,y\n"), 0o644)
+	if _, err := exec.Execute(parseSQL(t, "SELECT * FROM v")); err == nil || !strings.Contains(err.Error(), `line 3, column a: "lots" is not an integer`) {
+		t.Errorf("expected a conversion error, got %v", err)
+	}
+}
@@ -17,7 +17,8 @@
 // - Literals (strings, numbers)
 // - Operators (=, <, >, !=, etc.)
 // - Punctuation (commas, parentheses)
-// - Whitespace (which we skip)
+// - Whitespace and /* comments */ (which we skip)
+// - Optimizer hints: a comment starting /*+, which is kept as one token
 
 package lexer
 
@@ -37,10 +38,12 @@ const (
 	TokenIllegal
 
 	// Literals
-	TokenIdent   // column names, table names
-	TokenNumber  // 123, 45.67
-	TokenString  // 'hello'
-	TokenBoolean // TRUE, FALSE
+	TokenIdent       // column names, table names
+	TokenNumber      // 123, 45.67
+	TokenString      // 'hello'
+	TokenBoolean     // TRUE, FALSE
+	TokenPlaceholder // ? (bound at execution time)
+	TokenHint        // /*+ INDEX(idx_users_age) */ (the text between /*+ and */)
 
 	// Keywords
 	TokenSelect
@@ -49,6 +52,7 @@ const (
 	TokenDelete
 	TokenCreate
 	TokenDrop
+	TokenAlter
 	TokenInto
 	TokenValues
 	TokenFrom
@@ -71,6 +75,24 @@ const (
 	TokenUnique
 	TokenOn
 	TokenAnalyze
+	TokenAs
+	TokenCast
+	TokenBegin
+	TokenCommit
+	TokenRollback
+	TokenTransaction
+	TokenSavepoint
+	TokenRelease
+	TokenFor
+	TokenVacuum
+	TokenPragma
+	TokenShow
+	TokenDescribe
+	TokenAttach
+	TokenDetach
+	TokenJoin
+	TokenInner
+	TokenGroup
 
 	// Data types
 	TokenInt
@@ -91,6 +113,7 @@ const (
 	TokenMinus          // -
 	TokenAsterisk       // *
 	TokenSlash          // /
+	TokenConcat         // ||
 
 	// Punctuation
 	TokenComma       // ,
@@ -109,6 +132,7 @@ type Token struct {
 	Literal string
 	Line    int
 	Column  int
+	Pos     int // Byte offset of the token's first character in the input
 }
 
 // String returns a human-readable representation of the token.
@@ -127,12 +151,15 @@ func tokenTypeName(t TokenType) string {
 		TokenNumber:         "NUMBER",
 		TokenString:         "STRING",
 		TokenBoolean:        "BOOLEAN",
+		TokenPlaceholder:    "PLACEHOLDER",
+		TokenHint:           "HINT",
 		TokenSelect:         "SELECT",
 		TokenInsert:         "INSERT",
 		TokenUpdate:         "UPDATE",
 		TokenDelete:         "DELETE",
 		TokenCreate:         "CREATE",
 		TokenDrop:           "DROP",
+		TokenAlter:          "ALTER",
 		TokenInto:           "INTO",
 		TokenValues:         "VALUES",
 		TokenFrom:           "FROM",
@@ -154,6 +181,25 @@ func tokenTypeName(t TokenType) string {
 		TokenIndex:          "INDEX",
 		TokenUnique:         "UNIQUE",
 		TokenOn:             "ON",
+		TokenAnalyze:        "ANALYZE",
+		TokenAs:             "AS",
+		TokenCast:           "CAST",
+		TokenBegin:          "BEGIN",
+		TokenCommit:         "COMMIT",
+		TokenRollback:       "ROLLBACK",
+		TokenTransaction:    "TRANSACTION",
+		TokenSavepoint:      "SAVEPOINT",
+		TokenRelease:        "RELEASE",
+		TokenFor:            "FOR",
+		TokenVacuum:         "VACUUM",
+		TokenPragma:         "PRAGMA",
+		TokenShow:           "SHOW",
+		TokenDescribe:       "DESCRIBE",
+		TokenAttach:         "ATTACH",
+		TokenDetach:         "DETACH",
+		TokenJoin:           "JOIN",
+		TokenInner:          "INNER",
+		TokenGroup:          "GROUP",
 		TokenInt:            "INT",
 		TokenInteger:        "INTEGER",
 		TokenText:           "TEXT",
@@ -170,6 +216,7 @@ func tokenTypeName(t TokenType) string {
 		TokenMinus:          "MINUS",
 		TokenAsterisk:       "ASTERISK",
 		TokenSlash:          "SLASH",
+		TokenConcat:         "CONCAT",
 		TokenComma:          "COMMA",
 		TokenSemicolon:      "SEMICOLON",
 		TokenLeftParen:      "LEFT_PAREN",
@@ -194,6 +241,7 @@ var keywords = map[string]TokenType{
 	"DELETE":  TokenDelete,
 	"CREATE":  TokenCreate,
 	"DROP":    TokenDrop,
+	"ALTER":   TokenAlter,
 	"INTO":    TokenInto,
 	"VALUES":  TokenValues,
 	"FROM":    TokenFrom,
@@ -219,6 +267,39 @@ var keywords = map[string]TokenType{
 	"UNIQUE":  TokenUnique,
 	"ON":      TokenOn,
 	"ANALYZE": TokenAnalyze,
+	"AS":      TokenAs,
+	"CAST":    TokenCast,
+
+	// Transactions
+	"BEGIN":       TokenBegin,
+	"COMMIT":      TokenCommit,
+	"ROLLBACK":    TokenRollback,
+	"TRANSACTION": TokenTransaction,
+	"SAVEPOINT":   TokenSavepoint,
+	"RELEASE":     TokenRelease,
+
+	// Row locking (SELECT ... FOR UPDATE)
+	"FOR": TokenFor,
+
+	// Maintenance
+	"VACUUM": TokenVacuum,
+	"PRAGMA": TokenPragma,
+
+	// Introspection
+	"SHOW":     TokenShow,
+	"DESCRIBE": TokenDescribe,
+
+	// Multiple databases
+	"ATTACH": TokenAttach,
+	"DETACH": TokenDetach,
+
+	// Joins
+	"JOIN":  TokenJoin,
+	"INNER": TokenInner,
+
+	// Aggregation
+	"GROUP": TokenGroup,
+
 	"INT":     TokenInt,
 	"INTEGER": TokenInteger,
 	"TEXT":    TokenText,
@@ -228,6 +309,12 @@ var keywords = map[string]TokenType{
 	"REAL":    TokenReal,
 }
 
+// IsKeyword reports whether word, in any case, is an SQL keyword.
+func IsKeyword(word string) bool {
+	tokenType, ok := keywords[strings.ToUpper(word)]
+	return ok && tokenType != TokenIdent
+}
+
 // Lexer tokenizes SQL input.
 type Lexer struct {
 	input   string
@@ -275,17 +362,30 @@ func (l *Lexer) peekChar() byte {
 }
 
 // NextToken returns the next token from the input.
+func (l *Lexer) NextToken() Token {
+	l.skipWhitespace()
+	start := l.pos
+	tok := l.scanToken()
+	tok.Pos = start
+	return tok
+}
+
+// Input returns the text being tokenized, for a caller that needs the
+// source of part of it (see Token.Pos).
+func (l *Lexer) Input() string {
+	return l.input
+}
+
+// scanToken reads the token starting at the current character.
 //
 // EDUCATIONAL NOTE:
 // -----------------
 // This is the main lexer function. It examines the current character
 // and decides what type of token it starts. This is essentially a
 // big switch statement with some helper functions.
-func (l *Lexer) NextToken() Token {
+func (l *Lexer) scanToken() Token {
 	var tok Token
 
-	l.skipWhitespace()
-
 	tok.Line = l.line
 	tok.Column = l.column
 
@@ -305,6 +405,10 @@ func (l *Lexer) NextToken() Token {
 	case '*':
 		tok = l.makeToken(TokenAsterisk, string(l.ch))
 	case '/':
+		if l.peekChar() == '*' {
+			// A comment skipWhitespace left is a hint
+			return l.readHint()
+		}
 		tok = l.makeToken(TokenSlash, string(l.ch))
 	case '<':
 		if l.peekChar() == '=' {
@@ -334,6 +438,16 @@ func (l *Lexer) NextToken() Token {
 		} else {
 			tok = l.makeToken(TokenIllegal, string(l.ch))
 		}
+	case '|':
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			tok = l.makeToken(TokenConcat, string(ch)+string(l.ch))
+		} else {
+			tok = l.makeToken(TokenIllegal, string(l.ch))
+		}
+	case '?':
+		tok = l.makeToken(TokenPlaceholder, string(l.ch))
 	case ',':
 		tok = l.makeToken(TokenComma, string(l.ch))
 	case ';':
@@ -383,20 +497,71 @@ func (l *Lexer) makeToken(tokenType TokenType, literal string) Token {
 	}
 }
 
-// skipWhitespace skips spaces, tabs, and newlines.
+// skipWhitespace skips spaces, tabs, and newlines, and comments that
+// aren't hints. An unterminated comment is left for readHint to report.
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '*' && !strings.HasPrefix(l.input[l.readPos:], "*+"):
+			end := strings.Index(l.input[l.readPos+1:], "*/")
+			if end < 0 {
+				return
+			}
+			for skip := end + 4; skip > 0; skip-- {
+				l.readChar()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// readHint reads an optimizer hint: /*+ ... */, with the text between the
+// markers as its literal.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Hints are written as comments, as Oracle and MySQL do, so that another
+// database reading the same SQL just skips them. The lexer keeps them,
+// and the parser reads the hints in them (see parser.Hint).
+func (l *Lexer) readHint() Token {
+	startLine := l.line
+	startColumn := l.column
+
+	end := strings.Index(l.input[l.readPos+1:], "*/")
+	if end < 0 {
+		return Token{
+			Type:    TokenError,
+			Literal: "unterminated comment",
+			Line:    startLine,
+			Column:  startColumn,
+		}
+	}
+	text := l.input[l.readPos+2 : l.readPos+1+end] // after "/*+"
+	for skip := end + 4; skip > 0; skip-- {
 		l.readChar()
 	}
+	return Token{
+		Type:    TokenHint,
+		Literal: strings.TrimSpace(text),
+		Line:    startLine,
+		Column:  startColumn,
+	}
 }
 
 // readIdentifier reads an identifier or keyword.
+//
+// A qualified name such as users.id is read as a single identifier, dot
+// included: the executor resolves it against the columns of the tables in
+// the query.
 func (l *Lexer) readIdentifier() Token {
 	startLine := l.line
 	startColumn := l.column
 	startPos := l.pos
 
-	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '_' {
+	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '_' || (l.ch == '.' && isLetter(l.peekChar())) {
 		l.readChar()
 	}
 
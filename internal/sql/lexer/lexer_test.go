@@ -222,3 +222,116 @@ func TestLexerPositionTracking(t *testing.T) {
 		t.Errorf("name should be on line 2, got %d", tok.Line)
 	}
 }
+
+func TestLexerPlaceholder(t *testing.T) {
+	input := "SELECT * FROM users WHERE id = ? AND name = ?"
+
+	l := New(input)
+	tokens := l.Tokenize()
+
+	placeholders := 0
+	for _, tok := range tokens {
+		if tok.Type == TokenPlaceholder {
+			placeholders++
+			if tok.Literal != "?" {
+				t.Errorf("expected literal '?', got %q", tok.Literal)
+			}
+		}
+	}
+
+	if placeholders != 2 {
+		t.Errorf("expected 2 placeholders, got %d", placeholders)
+	}
+}
+
+func TestLexerConcat(t *testing.T) {
+	tokens := New("a || 'b' | c").Tokenize()
+
+	expected := []TokenType{TokenIdent, TokenConcat, TokenString, TokenIllegal, TokenIdent, TokenEOF}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, exp := range expected {
+		if tokens[i].Type != exp {
+			t.Errorf("token %d: expected %s, got %s", i, tokenTypeName(exp), tokenTypeName(tokens[i].Type))
+		}
+	}
+}
+
+func TestLexerJoinAndQualifiedNames(t *testing.T) {
+	tokens := New("FROM users INNER JOIN orders ON users.id = orders.user_id").Tokenize()
+
+	expected := []struct {
+		tokenType TokenType
+		literal   string
+	}{
+		{TokenFrom, "FROM"},
+		{TokenIdent, "users"},
+		{TokenInner, "INNER"},
+		{TokenJoin, "JOIN"},
+		{TokenIdent, "orders"},
+		{TokenOn, "ON"},
+		{TokenIdent, "users.id"},
+		{TokenEquals, "="},
+		{TokenIdent, "orders.user_id"},
+		{TokenEOF, ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d", len(expected), len(tokens))
+	}
+	for i, exp := range expected {
+		if tokens[i].Type != exp.tokenType || tokens[i].Literal != exp.literal {
+			t.Errorf("token %d: expected %s %q, got %s %q", i,
+				tokenTypeName(exp.tokenType), exp.literal, tokenTypeName(tokens[i].Type), tokens[i].Literal)
+		}
+	}
+}
+
+func TestLexerCommentsAndHints(t *testing.T) {
+	tokens := New("SELECT /*+ INDEX(idx_users_age) */ a /* not a hint */ / 2 /**/FROM t").Tokenize()
+
+	expected := []struct {
+		tokenType TokenType
+		literal   string
+	}{
+		{TokenSelect, "SELECT"},
+		{TokenHint, "INDEX(idx_users_age)"},
+		{TokenIdent, "a"},
+		{TokenSlash, "/"},
+		{TokenNumber, "2"},
+		{TokenFrom, "FROM"},
+		{TokenIdent, "t"},
+		{TokenEOF, ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %d: %v", len(expected), len(tokens), tokens)
+	}
+	for i, exp := range expected {
+		if tokens[i].Type != exp.tokenType || tokens[i].Literal != exp.literal {
+			t.Errorf("token %d: expected %s %q, got %s %q", i,
+				tokenTypeName(exp.tokenType), exp.literal, tokenTypeName(tokens[i].Type), tokens[i].Literal)
+		}
+	}
+
+	for _, input := range []string{"SELECT /* no end", "SELECT /*+ FULL_SCAN"} {
+		tokens := New(input).Tokenize()
+		if last := tokens[len(tokens)-1]; last.Type != TokenError || last.Literal != "unterminated comment" {
+			t.Errorf("%s: expected an unterminated comment error, got %v", input, last)
+		}
+	}
+}
+
+func TestIsKeyword(t *testing.T) {
+	for word, want := range map[string]bool{
+		"SELECT": true,
+		"where":  true,
+		"NULL":   true,
+		"KEY":    false, // read as an identifier
+		"users":  false,
+		"":       false,
+	} {
+		if got := IsKeyword(word); got != want {
+			t.Errorf("IsKeyword(%q) = %v, want %v", word, got, want)
+		}
+	}
+}
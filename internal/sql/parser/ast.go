@@ -21,6 +21,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Node is the base interface for all AST nodes.
@@ -49,18 +50,49 @@ type Expression interface {
 //
 // Example: SELECT name, age FROM users WHERE age > 18 ORDER BY name LIMIT 10
 type SelectStatement struct {
-	Columns  []Expression    // Columns to select (* means all)
-	From     string          // Table name
-	Where    Expression      // Optional WHERE clause
-	OrderBy  []OrderByClause // Optional ORDER BY clause
-	Limit    *int            // Optional LIMIT
-	Offset   *int            // Optional OFFSET
+	Columns   []Expression    // Columns to select (* means all)
+	From      string          // Table name; empty for SELECT without FROM
+	FromFunc  *FunctionCall   // Table function read instead of a table, as in FROM generate_series(1, 10)
+	Joins     []JoinClause    // Tables joined to From, in order
+	Where     Expression      // Optional WHERE clause
+	GroupBy   []string        // Optional GROUP BY columns
+	OrderBy   []OrderByClause // Optional ORDER BY clause
+	Limit     *int            // Optional LIMIT
+	Offset    *int            // Optional OFFSET
+	ForUpdate bool            // FOR UPDATE: lock the returned rows
+	Hints     []Hint          // Optimizer hints, from /*+ ... */ after SELECT (see hints.go)
 }
 
 func (s *SelectStatement) node()      {}
 func (s *SelectStatement) statement() {}
 func (s *SelectStatement) String() string {
-	return fmt.Sprintf("SELECT %v FROM %s", s.Columns, s.From)
+	if s.From == "" {
+		return fmt.Sprintf("SELECT %v", s.Columns)
+	}
+	from := s.From
+	if s.FromFunc != nil {
+		from = fmt.Sprintf("%s AS %s", s.FromFunc, s.From)
+	}
+	for _, join := range s.Joins {
+		from += " " + join.String()
+	}
+	if s.ForUpdate {
+		return fmt.Sprintf("SELECT %v FROM %s FOR UPDATE", s.Columns, from)
+	}
+	return fmt.Sprintf("SELECT %v FROM %s", s.Columns, from)
+}
+
+// JoinClause is one JOIN of a SELECT: the table joined to the rows of the
+// tables before it, and the condition a pair of rows must meet.
+//
+// Example: SELECT * FROM users JOIN orders ON users.id = orders.user_id
+type JoinClause struct {
+	Table string
+	On    Expression
+}
+
+func (j JoinClause) String() string {
+	return fmt.Sprintf("JOIN %s ON %s", j.Table, j.On)
 }
 
 // OrderByClause represents a single ORDER BY item.
@@ -84,6 +116,37 @@ func (s *InsertStatement) String() string {
 	return fmt.Sprintf("INSERT INTO %s (%v) VALUES (%v)", s.Table, s.Columns, s.Values)
 }
 
+// CopyStatement represents a COPY ... FROM statement, which loads rows
+// from a CSV file, or from the client with STDIN.
+//
+// Example: COPY users (name, age) FROM 'users.csv' WITH HEADER
+type CopyStatement struct {
+	Table   string
+	Columns []string // The columns the fields are for; all, in order, if empty
+	File    string   // The file to read, unless Stdin
+	Stdin   bool     // Read the rows the client sends instead
+	Header  bool     // The first line names the columns and is skipped
+}
+
+func (s *CopyStatement) node()      {}
+func (s *CopyStatement) statement() {}
+func (s *CopyStatement) String() string {
+	var sb strings.Builder
+	sb.WriteString("COPY " + s.Table)
+	if len(s.Columns) > 0 {
+		sb.WriteString(" (" + strings.Join(s.Columns, ", ") + ")")
+	}
+	if s.Stdin {
+		sb.WriteString(" FROM STDIN")
+	} else {
+		sb.WriteString(" FROM '" + strings.ReplaceAll(s.File, "'", "''") + "'")
+	}
+	if s.Header {
+		sb.WriteString(" WITH HEADER")
+	}
+	return sb.String()
+}
+
 // UpdateStatement represents an UPDATE query.
 //
 // Example: UPDATE users SET age = 31 WHERE name = 'Alice'
@@ -125,13 +188,86 @@ func (s *DeleteStatement) String() string {
 type CreateTableStatement struct {
 	Table      string
 	Columns    []ColumnDefinition
-	PrimaryKey string
+	PrimaryKey string // The primary key column, if the key has one column
+
+	// PrimaryKeyColumns lists the columns of a composite primary key,
+	// declared as PRIMARY KEY (a, b), in key order; nil for a key of one
+	// column
+	PrimaryKeyColumns []string
+
+	// Temporary is set by CREATE TEMP TABLE: the table lives in memory
+	// and goes away with the session
+	Temporary bool
+
+	// Partition is the PARTITION BY clause; nil for a table that isn't
+	// partitioned
+	Partition *PartitionSpec
+
+	// Using is the storage method of a USING clause, "columnar" for a
+	// table stored column by column; "" for the default row storage
+	Using string
 }
 
 func (s *CreateTableStatement) node()      {}
 func (s *CreateTableStatement) statement() {}
 func (s *CreateTableStatement) String() string {
-	return fmt.Sprintf("CREATE TABLE %s (%v)", s.Table, s.Columns)
+	composite := len(s.PrimaryKeyColumns) > 1
+	columns := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		// The columns of a composite key are named by its constraint
+		if composite {
+			col.PrimaryKey = false
+		}
+		columns[i] = col.String()
+	}
+	if composite {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(s.PrimaryKeyColumns, ", ")))
+	}
+	create := "CREATE TABLE"
+	if s.Temporary {
+		create = "CREATE TEMP TABLE"
+	}
+	sql := fmt.Sprintf("%s %s (%s)", create, s.Table, strings.Join(columns, ", "))
+	if s.Partition != nil {
+		sql += " " + s.Partition.String()
+	}
+	if s.Using != "" {
+		sql += " USING " + s.Using
+	}
+	return sql
+}
+
+// PartitionSpec is the PARTITION BY clause of a CREATE TABLE:
+//
+//   PARTITION BY RANGE (day) (PARTITION p0 VALUES LESS THAN (100),
+//     PARTITION p1 VALUES LESS THAN MAXVALUE)
+//   PARTITION BY HASH (id) PARTITIONS 4
+type PartitionSpec struct {
+	Method     string                // "RANGE" or "HASH"
+	Column     string                // The partition key
+	Partitions []PartitionDefinition // RANGE: in the order of their bounds
+	Count      int                   // HASH: the number of partitions
+}
+
+func (s *PartitionSpec) String() string {
+	if s.Method == "HASH" {
+		return fmt.Sprintf("PARTITION BY HASH (%s) PARTITIONS %d", s.Column, s.Count)
+	}
+	parts := make([]string, len(s.Partitions))
+	for i, part := range s.Partitions {
+		bound := "MAXVALUE"
+		if part.Bound != nil {
+			bound = "(" + part.Bound.String() + ")"
+		}
+		parts[i] = fmt.Sprintf("PARTITION %s VALUES LESS THAN %s", part.Name, bound)
+	}
+	return fmt.Sprintf("PARTITION BY RANGE (%s) (%s)", s.Column, strings.Join(parts, ", "))
+}
+
+// PartitionDefinition is one partition of a RANGE partitioned table.
+type PartitionDefinition struct {
+	Name  string
+	Bound Expression // VALUES LESS THAN (bound); nil for MAXVALUE
 }
 
 // ColumnDefinition represents a column definition in CREATE TABLE.
@@ -140,16 +276,33 @@ type ColumnDefinition struct {
 	Type       DataType
 	PrimaryKey bool
 	NotNull    bool
+	Precision  int // DECIMAL(precision, scale); 0 if not given
+	Scale      int
+	Length     int        // VARCHAR(length); 0 if not given
+	Collation  string     // COLLATE NOCASE; "" for the default, BINARY
+	Default    Expression // DEFAULT expr; nil if not given
 }
 
 func (c ColumnDefinition) String() string {
 	s := fmt.Sprintf("%s %s", c.Name, c.Type)
+	if c.Length > 0 {
+		s = fmt.Sprintf("%s VARCHAR(%d)", c.Name, c.Length)
+	}
+	if c.Precision > 0 {
+		s += fmt.Sprintf("(%d,%d)", c.Precision, c.Scale)
+	}
+	if c.Collation != "" {
+		s += " COLLATE " + c.Collation
+	}
 	if c.PrimaryKey {
 		s += " PRIMARY KEY"
 	}
 	if c.NotNull {
 		s += " NOT NULL"
 	}
+	if c.Default != nil {
+		s += " DEFAULT " + c.Default.String()
+	}
 	return s
 }
 
@@ -162,6 +315,9 @@ const (
 	TypeReal
 	TypeText
 	TypeBoolean
+	TypeTimestamp // stored as microseconds since the Unix epoch (UTC)
+	TypeDecimal   // exact fixed-point number
+	TypeJSON      // validated JSON document, stored as text
 )
 
 func (d DataType) String() string {
@@ -174,6 +330,12 @@ func (d DataType) String() string {
 		return "TEXT"
 	case TypeBoolean:
 		return "BOOLEAN"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeDecimal:
+		return "DECIMAL"
+	case TypeJSON:
+		return "JSON"
 	default:
 		return "UNKNOWN"
 	}
@@ -190,19 +352,200 @@ func (s *DropTableStatement) String() string {
 	return fmt.Sprintf("DROP TABLE %s", s.Table)
 }
 
+// AlterTableStatement represents ALTER TABLE ... ADD COLUMN or DROP COLUMN.
+type AlterTableStatement struct {
+	Table      string
+	AddColumn  *ColumnDefinition // The column ADD COLUMN adds, or nil
+	DropColumn string            // The column DROP COLUMN drops
+}
+
+func (s *AlterTableStatement) node()      {}
+func (s *AlterTableStatement) statement() {}
+func (s *AlterTableStatement) String() string {
+	if s.AddColumn != nil {
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", s.Table, s.AddColumn)
+	}
+	return fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", s.Table, s.DropColumn)
+}
+
+// CreateMaterializedViewStatement represents a CREATE MATERIALIZED VIEW
+// statement, which stores the rows of a query in a table of their own.
+//
+// Example: CREATE MATERIALIZED VIEW totals AS SELECT customer, SUM(total) AS total FROM orders GROUP BY customer
+type CreateMaterializedViewStatement struct {
+	Name  string
+	Query *SelectStatement
+
+	// QuerySQL is the text of Query as written, since the String of a
+	// statement doesn't always give it back
+	QuerySQL string
+}
+
+func (s *CreateMaterializedViewStatement) node()      {}
+func (s *CreateMaterializedViewStatement) statement() {}
+func (s *CreateMaterializedViewStatement) String() string {
+	return fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS %s", s.Name, s.QuerySQL)
+}
+
+// CreateVirtualTableStatement represents a CREATE VIRTUAL TABLE statement,
+// which makes rows kept outside the database readable as a table.
+//
+// Example: CREATE VIRTUAL TABLE logs USING csv('logs/access.csv')
+type CreateVirtualTableStatement struct {
+	Name   string
+	Module string   // What provides the rows, such as csv
+	Args   []string // The module's arguments, such as a file name
+}
+
+func (s *CreateVirtualTableStatement) node()      {}
+func (s *CreateVirtualTableStatement) statement() {}
+func (s *CreateVirtualTableStatement) String() string {
+	args := make([]string, len(s.Args))
+	for i, arg := range s.Args {
+		args[i] = "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	}
+	return fmt.Sprintf("CREATE VIRTUAL TABLE %s USING %s(%s)", s.Name, s.Module, strings.Join(args, ", "))
+}
+
+// RefreshMaterializedViewStatement represents a REFRESH MATERIALIZED VIEW
+// statement, which runs a materialized view's query again.
+type RefreshMaterializedViewStatement struct {
+	Name string
+}
+
+func (s *RefreshMaterializedViewStatement) node()      {}
+func (s *RefreshMaterializedViewStatement) statement() {}
+func (s *RefreshMaterializedViewStatement) String() string {
+	return "REFRESH MATERIALIZED VIEW " + s.Name
+}
+
+// DropMaterializedViewStatement represents a DROP MATERIALIZED VIEW
+// statement.
+type DropMaterializedViewStatement struct {
+	Name string
+}
+
+func (s *DropMaterializedViewStatement) node()      {}
+func (s *DropMaterializedViewStatement) statement() {}
+func (s *DropMaterializedViewStatement) String() string {
+	return "DROP MATERIALIZED VIEW " + s.Name
+}
+
+// CreateSequenceStatement represents a CREATE SEQUENCE statement.
+//
+// Example: CREATE SEQUENCE order_ids START WITH 1000 INCREMENT BY 1
+type CreateSequenceStatement struct {
+	Name      string
+	Start     int64 // The first value NEXTVAL returns
+	Increment int64 // Added to the last value by each NEXTVAL; not 0
+}
+
+func (s *CreateSequenceStatement) node()      {}
+func (s *CreateSequenceStatement) statement() {}
+func (s *CreateSequenceStatement) String() string {
+	return fmt.Sprintf("CREATE SEQUENCE %s START WITH %d INCREMENT BY %d", s.Name, s.Start, s.Increment)
+}
+
+// DropSequenceStatement represents a DROP SEQUENCE statement.
+type DropSequenceStatement struct {
+	Name string
+}
+
+func (s *DropSequenceStatement) node()      {}
+func (s *DropSequenceStatement) statement() {}
+func (s *DropSequenceStatement) String() string {
+	return "DROP SEQUENCE " + s.Name
+}
+
+// CreateTriggerStatement represents a CREATE TRIGGER statement.
+//
+// Example:
+//
+//   CREATE TRIGGER log_price AFTER UPDATE ON items FOR EACH ROW BEGIN
+//     INSERT INTO price_log VALUES (OLD.id, OLD.price, NEW.price);
+//   END
+type CreateTriggerStatement struct {
+	Name   string
+	Timing string // BEFORE or AFTER
+	Event  string // INSERT, UPDATE or DELETE
+	Table  string
+	Body   []Statement
+
+	// BodySQL holds the text of each statement of Body as written, since
+	// the String of a statement doesn't always give it back
+	BodySQL []string
+}
+
+func (s *CreateTriggerStatement) node()      {}
+func (s *CreateTriggerStatement) statement() {}
+func (s *CreateTriggerStatement) String() string {
+	var body strings.Builder
+	for _, sql := range s.BodySQL {
+		body.WriteString(" " + sql + ";")
+	}
+	return fmt.Sprintf("CREATE TRIGGER %s %s %s ON %s FOR EACH ROW BEGIN%s END", s.Name, s.Timing, s.Event, s.Table, body.String())
+}
+
+// DropTriggerStatement represents a DROP TRIGGER statement.
+type DropTriggerStatement struct {
+	Name string
+}
+
+func (s *DropTriggerStatement) node()      {}
+func (s *DropTriggerStatement) statement() {}
+func (s *DropTriggerStatement) String() string {
+	return "DROP TRIGGER " + s.Name
+}
+
 // ExplainStatement represents an EXPLAIN query.
 //
 // Example: EXPLAIN SELECT * FROM users WHERE id = 5
+//
+// With ANALYZE the statement is actually run, and the plan is reported
+// with the real row counts, timings and page reads of each step.
 type ExplainStatement struct {
-	Statement Statement // The statement to explain (SELECT, UPDATE, DELETE)
+	Statement Statement     // The statement to explain (SELECT, UPDATE, DELETE)
+	Analyze   bool          // EXPLAIN ANALYZE: execute and measure
+	Format    ExplainFormat // EXPLAIN (FORMAT ...): how to render the plan
 }
 
 func (s *ExplainStatement) node()      {}
 func (s *ExplainStatement) statement() {}
 func (s *ExplainStatement) String() string {
+	if s.Format != ExplainText {
+		var options []string
+		if s.Analyze {
+			options = append(options, "ANALYZE")
+		}
+		options = append(options, "FORMAT "+s.Format.String())
+		return fmt.Sprintf("EXPLAIN (%s) %s", strings.Join(options, ", "), s.Statement)
+	}
+	if s.Analyze {
+		return fmt.Sprintf("EXPLAIN ANALYZE %s", s.Statement)
+	}
 	return fmt.Sprintf("EXPLAIN %s", s.Statement)
 }
 
+// ExplainFormat selects how EXPLAIN renders a plan.
+type ExplainFormat int
+
+const (
+	ExplainText ExplainFormat = iota // Property/Value rows (the default)
+	ExplainTree                      // one indented line per plan node
+	ExplainJSON                      // a single JSON document
+)
+
+func (f ExplainFormat) String() string {
+	switch f {
+	case ExplainTree:
+		return "TREE"
+	case ExplainJSON:
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
 // CreateIndexStatement represents a CREATE INDEX query.
 //
 // Example: CREATE INDEX idx_users_age ON users (age)
@@ -226,7 +569,7 @@ func (s *CreateIndexStatement) String() string {
 	if s.Unique {
 		unique = "UNIQUE "
 	}
-	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%v)", unique, s.IndexName, s.Table, s.Columns)
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, s.IndexName, s.Table, strings.Join(s.Columns, ", "))
 }
 
 // DropIndexStatement represents a DROP INDEX query.
@@ -256,6 +599,167 @@ func (s *AnalyzeStatement) String() string {
 	}
 	return fmt.Sprintf("ANALYZE %s", s.Table)
 }
+
+// VacuumStatement represents VACUUM.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Deleting rows doesn't make the database file smaller: the space they
+// used stays in their pages, and pages nobody uses any more stay in the
+// file. VACUUM rebuilds the database so it takes only the space its live
+// data needs, and hands the rest back to the operating system.
+type VacuumStatement struct{}
+
+func (s *VacuumStatement) node()          {}
+func (s *VacuumStatement) statement()     {}
+func (s *VacuumStatement) String() string { return "VACUUM" }
+
+// PragmaStatement represents PRAGMA name, a command that inspects or
+// maintains the database rather than querying its data. The only one so
+// far is PRAGMA integrity_check.
+type PragmaStatement struct {
+	Name string // Lowercased pragma name
+}
+
+func (s *PragmaStatement) node()      {}
+func (s *PragmaStatement) statement() {}
+func (s *PragmaStatement) String() string {
+	return fmt.Sprintf("PRAGMA %s", s.Name)
+}
+
+// SetStatement represents SET name = value (or SET name TO value), which
+// changes a setting of the session, such as statement_timeout.
+type SetStatement struct {
+	Name  string // Lowercased setting name
+	Value Expression
+}
+
+func (s *SetStatement) node()      {}
+func (s *SetStatement) statement() {}
+func (s *SetStatement) String() string {
+	return fmt.Sprintf("SET %s = %s", s.Name, s.Value.String())
+}
+
+// ShowStatement represents SHOW TABLES or SHOW INDEXES [FROM table],
+// which list the tables, or the indexes of all tables or of one.
+type ShowStatement struct {
+	What  string // "tables" or "indexes"
+	Table string // Table of SHOW INDEXES FROM; empty for all
+}
+
+func (s *ShowStatement) node()      {}
+func (s *ShowStatement) statement() {}
+func (s *ShowStatement) String() string {
+	if s.Table != "" {
+		return fmt.Sprintf("SHOW %s FROM %s", strings.ToUpper(s.What), s.Table)
+	}
+	return fmt.Sprintf("SHOW %s", strings.ToUpper(s.What))
+}
+
+// DescribeStatement represents DESCRIBE table, which lists the columns of
+// a table.
+type DescribeStatement struct {
+	Table string
+}
+
+func (s *DescribeStatement) node()      {}
+func (s *DescribeStatement) statement() {}
+func (s *DescribeStatement) String() string {
+	return fmt.Sprintf("DESCRIBE %s", s.Table)
+}
+
+// AttachStatement represents ATTACH [DATABASE] 'file' AS alias, which
+// opens another database file whose tables are then named alias.table.
+type AttachStatement struct {
+	Path  string
+	Alias string
+}
+
+func (s *AttachStatement) node()      {}
+func (s *AttachStatement) statement() {}
+func (s *AttachStatement) String() string {
+	return fmt.Sprintf("ATTACH DATABASE '%s' AS %s", strings.ReplaceAll(s.Path, "'", "''"), s.Alias)
+}
+
+// DetachStatement represents DETACH [DATABASE] alias.
+type DetachStatement struct {
+	Alias string
+}
+
+func (s *DetachStatement) node()          {}
+func (s *DetachStatement) statement()     {}
+func (s *DetachStatement) String() string { return "DETACH DATABASE " + s.Alias }
+
+// BeginStatement represents BEGIN [TRANSACTION].
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Without BEGIN, every statement is its own transaction ("autocommit").
+// BEGIN groups the statements that follow, up to COMMIT or ROLLBACK,
+// so that they take effect together or not at all.
+type BeginStatement struct{}
+
+func (s *BeginStatement) node()          {}
+func (s *BeginStatement) statement()     {}
+func (s *BeginStatement) String() string { return "BEGIN" }
+
+// CommitStatement represents COMMIT [TRANSACTION].
+type CommitStatement struct{}
+
+func (s *CommitStatement) node()          {}
+func (s *CommitStatement) statement()     {}
+func (s *CommitStatement) String() string { return "COMMIT" }
+
+// RollbackStatement represents ROLLBACK [TRANSACTION] [TO [SAVEPOINT] name].
+type RollbackStatement struct {
+	Savepoint string // Empty string means roll back the whole transaction
+}
+
+func (s *RollbackStatement) node()      {}
+func (s *RollbackStatement) statement() {}
+func (s *RollbackStatement) String() string {
+	if s.Savepoint == "" {
+		return "ROLLBACK"
+	}
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", s.Savepoint)
+}
+
+// SavepointStatement represents SAVEPOINT name.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A savepoint marks a point inside a transaction that can be returned to
+// without abandoning the whole transaction:
+//
+//   BEGIN;
+//   INSERT ...;                 -- kept
+//   SAVEPOINT before_cleanup;
+//   DELETE ...;                 -- undone
+//   ROLLBACK TO before_cleanup;
+//   COMMIT;
+//
+// RELEASE name forgets a savepoint but keeps its changes.
+type SavepointStatement struct {
+	Name string
+}
+
+func (s *SavepointStatement) node()      {}
+func (s *SavepointStatement) statement() {}
+func (s *SavepointStatement) String() string {
+	return fmt.Sprintf("SAVEPOINT %s", s.Name)
+}
+
+// ReleaseStatement represents RELEASE [SAVEPOINT] name.
+type ReleaseStatement struct {
+	Name string
+}
+
+func (s *ReleaseStatement) node()      {}
+func (s *ReleaseStatement) statement() {}
+func (s *ReleaseStatement) String() string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", s.Name)
+}
+
 // ============================================================================
 // Expressions
 // ============================================================================
@@ -327,6 +831,81 @@ func (e *NullLiteral) String() string {
 	return "NULL"
 }
 
+// Placeholder represents a positional parameter marker (?).
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Placeholders let a program send the SQL text and the values separately:
+//   INSERT INTO users (name) VALUES (?)   with args ['O''Brien']
+// Because the value never passes through the lexer, it can't change the
+// structure of the query. This is what makes parameterized statements the
+// standard defense against SQL injection.
+//
+// Index is zero-based and assigned in order of appearance.
+type Placeholder struct {
+	Index int
+}
+
+func (e *Placeholder) node()       {}
+func (e *Placeholder) expression() {}
+func (e *Placeholder) String() string {
+	return "?"
+}
+
+// CastExpression represents CAST(expr AS type).
+//
+// Example: CAST(price AS INTEGER), CAST(total AS DECIMAL(10,2))
+type CastExpression struct {
+	Expr      Expression
+	Type      DataType
+	Precision int // For DECIMAL(p,s); 0 if not given
+	Scale     int
+}
+
+func (e *CastExpression) node()       {}
+func (e *CastExpression) expression() {}
+func (e *CastExpression) String() string {
+	return fmt.Sprintf("CAST(%s AS %s)", e.Expr, e.Type)
+}
+
+// FunctionCall represents a call to a scalar function.
+//
+// Example: COALESCE(nickname, name)
+//
+// The parser doesn't know which functions exist; Name is stored in
+// uppercase and resolved by the executor at evaluation time.
+type FunctionCall struct {
+	Name string
+	Args []Expression
+}
+
+func (e *FunctionCall) node()       {}
+func (e *FunctionCall) expression() {}
+func (e *FunctionCall) String() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	return fmt.Sprintf("%s(%s)", e.Name, strings.Join(args, ", "))
+}
+
+// AliasExpression names an item in the SELECT list.
+//
+// Example: SELECT price * qty AS total FROM orders
+//
+// The alias only renames the output column; it isn't an expression that
+// can appear in WHERE.
+type AliasExpression struct {
+	Expr  Expression
+	Alias string
+}
+
+func (e *AliasExpression) node()       {}
+func (e *AliasExpression) expression() {}
+func (e *AliasExpression) String() string {
+	return fmt.Sprintf("%s AS %s", e.Expr, e.Alias)
+}
+
 // StarExpression represents * (all columns).
 type StarExpression struct{}
 
@@ -345,6 +924,7 @@ func (e *StarExpression) String() string {
 // - Comparison: =, !=, <, >, <=, >=
 // - Logical: AND, OR
 // - Arithmetic: +, -, *, /
+// - String: || (concatenation)
 type BinaryExpression struct {
 	Left     Expression
 	Operator BinaryOp
@@ -377,6 +957,8 @@ const (
 	OpSubtract
 	OpMultiply
 	OpDivide
+	// String operators
+	OpConcat
 )
 
 func (op BinaryOp) String() string {
@@ -405,6 +987,8 @@ func (op BinaryOp) String() string {
 		return "*"
 	case OpDivide:
 		return "/"
+	case OpConcat:
+		return "||"
 	default:
 		return "?"
 	}
@@ -0,0 +1,126 @@
+// Package parser - Optimizer hints
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The planner picks an access path from its rules, or once ANALYZE has
+// run, from its cost estimates. Estimates can be wrong (statistics go
+// stale, a histogram misses a skewed value), and when they are, a hint
+// tells the planner what to do instead:
+//
+//   SELECT /*+ FULL_SCAN */ * FROM users WHERE id = 5
+//   SELECT /*+ INDEX(idx_users_age) */ * FROM users WHERE age > 30 AND id > 100
+//
+// FULL_SCAN reads the whole table, using no index. INDEX(name) reads
+// through the named index whenever the WHERE clause lets it, whatever
+// the costs say. Comparing EXPLAIN ANALYZE with and without a hint is a
+// good way to see what an index buys, or what the planner got wrong.
+//
+// A hint goes in a comment straight after SELECT, as in Oracle and MySQL,
+// so SQL with hints still runs on a database that ignores them. The two
+// hints here contradict each other, so a SELECT takes one. Unlike Oracle,
+// which silently ignores a hint it can't read, an unknown hint is an
+// error here, and so is an INDEX hint naming an index the table doesn't
+// have: a typo shouldn't look like a planner that refuses to listen. A
+// hint that can be read but not followed, such as INDEX on a column the
+// WHERE clause doesn't mention, leaves the planner to choose as usual.
+//
+// PostgreSQL has no hints at all, on the view that a planner needing them
+// should be fixed; its pg_hint_plan extension adds them the same way.
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
+)
+
+// Optimizer hints.
+const (
+	HintFullScan = "FULL_SCAN" // read the whole table, using no index
+	HintIndex    = "INDEX"     // INDEX(name): read through the named index
+)
+
+// Hint is an optimizer hint of a SELECT.
+type Hint struct {
+	Name string   // HintFullScan or HintIndex
+	Args []string // For HintIndex, the index's name
+}
+
+func (h Hint) String() string {
+	if len(h.Args) == 0 {
+		return h.Name
+	}
+	return h.Name + "(" + strings.Join(h.Args, ", ") + ")"
+}
+
+// Hint returns the statement's hint with the given name, if it has one.
+func (s *SelectStatement) Hint(name string) (Hint, bool) {
+	for _, hint := range s.Hints {
+		if hint.Name == name {
+			return hint, true
+		}
+	}
+	return Hint{}, false
+}
+
+// parseHints reads the hints in the text of a /*+ ... */ comment: names,
+// each optionally followed by arguments in parentheses.
+func parseHints(text string) ([]Hint, error) {
+	var hints []Hint
+	l := lexer.New(text)
+	tok := l.NextToken()
+	for tok.Type != lexer.TokenEOF {
+		if !isWord(tok.Literal) {
+			return nil, fmt.Errorf("invalid optimizer hint %q", text)
+		}
+		hint := Hint{Name: strings.ToUpper(tok.Literal)}
+		tok = l.NextToken()
+		if tok.Type == lexer.TokenLeftParen {
+			for {
+				tok = l.NextToken()
+				if !isWord(tok.Literal) {
+					return nil, fmt.Errorf("invalid arguments to optimizer hint %s", hint.Name)
+				}
+				hint.Args = append(hint.Args, tok.Literal)
+				tok = l.NextToken()
+				if tok.Type == lexer.TokenRightParen {
+					break
+				}
+				if tok.Type != lexer.TokenComma {
+					return nil, fmt.Errorf("invalid arguments to optimizer hint %s", hint.Name)
+				}
+			}
+			tok = l.NextToken()
+		}
+
+		switch {
+		case hint.Name == HintFullScan && len(hint.Args) == 0:
+		case hint.Name == HintIndex && len(hint.Args) == 1:
+		case hint.Name == HintFullScan || hint.Name == HintIndex:
+			return nil, fmt.Errorf("wrong arguments to optimizer hint %s", hint)
+		default:
+			return nil, fmt.Errorf("unknown optimizer hint %s", hint.Name)
+		}
+		hints = append(hints, hint)
+	}
+	if len(hints) > 1 {
+		return nil, fmt.Errorf("conflicting optimizer hints %s and %s", hints[0], hints[1])
+	}
+	return hints, nil
+}
+
+// isWord reports whether a token's literal is a name: an identifier, or
+// a keyword such as INDEX.
+func isWord(literal string) bool {
+	if literal == "" {
+		return false
+	}
+	for _, ch := range literal {
+		if ch != '_' && !('a' <= ch && ch <= 'z') && !('A' <= ch && ch <= 'Z') && !('0' <= ch && ch <= '9') {
+			return false
+		}
+	}
+	return !('0' <= literal[0] && literal[0] <= '9')
+}
@@ -18,6 +18,7 @@ package parser
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -30,6 +31,10 @@ type Parser struct {
 	curToken  lexer.Token
 	peekToken lexer.Token
 	errors    []string
+
+	// placeholders counts the ? markers seen so far. Each one is numbered
+	// in order of appearance so the executor can bind positional arguments.
+	placeholders int
 }
 
 // New creates a new Parser for the given lexer.
@@ -47,12 +52,48 @@ func New(l *lexer.Lexer) *Parser {
 // Parse parses the input and returns the AST.
 func (p *Parser) Parse() (Statement, error) {
 	stmt := p.parseStatement()
+	if p.peekTokenIs(lexer.TokenHint) {
+		// Rather than stop at it, leaving the rest of the statement unread
+		p.errors = append(p.errors, "an optimizer hint /*+ ... */ must come right after SELECT")
+	} else if stmt != nil && len(p.errors) == 0 && !p.peekTokenIs(lexer.TokenSemicolon) && !p.peekTokenIs(lexer.TokenEOF) {
+		p.errors = append(p.errors, fmt.Sprintf("unexpected %q after the end of the statement", p.peekToken.Literal))
+	} else if stmt != nil && len(p.errors) == 0 {
+		// Parse reads a single statement; a second one after the
+		// semicolon would otherwise never run (see SplitStatements)
+		for p.peekTokenIs(lexer.TokenSemicolon) {
+			p.nextToken()
+		}
+		if !p.peekTokenIs(lexer.TokenEOF) {
+			p.errors = append(p.errors, fmt.Sprintf("unexpected %q after the end of the statement: run one statement at a time", p.peekToken.Literal))
+		}
+	}
 	if len(p.errors) > 0 {
 		return nil, fmt.Errorf("parse errors: %s", strings.Join(p.errors, "; "))
 	}
 	return stmt, nil
 }
 
+// UnfinishedTrigger reports whether sql is the start of a CREATE TRIGGER
+// statement that hasn't reached the END of its body. Whatever splits a
+// script into statements at semicolons uses it to go on past those ending
+// the statements of the body.
+func UnfinishedTrigger(sql string) bool {
+	l := lexer.New(sql)
+	if tok := l.NextToken(); tok.Type != lexer.TokenCreate {
+		return false
+	}
+	if tok := l.NextToken(); tok.Type != lexer.TokenIdent || !strings.EqualFold(tok.Literal, "TRIGGER") {
+		return false
+	}
+	var last lexer.Token
+	for tok := l.NextToken(); tok.Type != lexer.TokenEOF; tok = l.NextToken() {
+		if tok.Type != lexer.TokenSemicolon {
+			last = tok
+		}
+	}
+	return last.Type != lexer.TokenIdent || !strings.EqualFold(last.Literal, "END")
+}
+
 // Errors returns any parsing errors encountered.
 func (p *Parser) Errors() []string {
 	return p.errors
@@ -111,34 +152,117 @@ func (p *Parser) parseStatement() Statement {
 		return p.parseCreateStatement()
 	case lexer.TokenDrop:
 		return p.parseDropStatement()
+	case lexer.TokenAlter:
+		return p.parseAlterTableStatement()
 	case lexer.TokenExplain:
 		return p.parseExplainStatement()
 	case lexer.TokenAnalyze:
 		return p.parseAnalyzeStatement()
+	case lexer.TokenVacuum:
+		return &VacuumStatement{}
+	case lexer.TokenPragma:
+		return p.parsePragmaStatement()
+	case lexer.TokenSet:
+		return p.parseSetStatement()
+	case lexer.TokenShow:
+		return p.parseShowStatement()
+	case lexer.TokenDescribe:
+		if !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		return &DescribeStatement{Table: p.curToken.Literal}
+	case lexer.TokenAttach:
+		return p.parseAttachStatement()
+	case lexer.TokenDetach:
+		p.skipDatabaseKeyword()
+		if !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		return &DetachStatement{Alias: p.curToken.Literal}
+	case lexer.TokenBegin:
+		p.skipTransactionKeyword()
+		return &BeginStatement{}
+	case lexer.TokenCommit:
+		p.skipTransactionKeyword()
+		return &CommitStatement{}
+	case lexer.TokenRollback:
+		return p.parseRollbackStatement()
+	case lexer.TokenSavepoint:
+		if !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		return &SavepointStatement{Name: p.curToken.Literal}
+	case lexer.TokenRelease:
+		return p.parseReleaseStatement()
+	case lexer.TokenIdent:
+		// REFRESH is not a reserved word, so it arrives as an identifier
+		if strings.EqualFold(p.curToken.Literal, "REFRESH") {
+			return p.parseRefreshStatement()
+		}
+		if strings.EqualFold(p.curToken.Literal, "COPY") {
+			return p.parseCopyStatement()
+		}
+		p.errors = append(p.errors, fmt.Sprintf("unexpected token: %s", p.curToken.Literal))
+		return nil
 	default:
 		p.errors = append(p.errors, fmt.Sprintf("unexpected token: %s", p.curToken.Literal))
 		return nil
 	}
 }
 
-// parseSelectStatement parses: SELECT columns FROM table [WHERE condition] [ORDER BY ...] [LIMIT n]
+// parseSelectStatement parses: SELECT columns FROM table [[INNER] JOIN table ON condition ...]
+// [WHERE condition] [GROUP BY column, ...] [ORDER BY ...] [LIMIT n] [FOR UPDATE]
 func (p *Parser) parseSelectStatement() *SelectStatement {
 	stmt := &SelectStatement{}
 
-	// Parse column list
 	p.nextToken() // move past SELECT
-	stmt.Columns = p.parseExpressionList()
-
-	// Expect FROM
-	if !p.expectPeek(lexer.TokenFrom) {
-		return nil
+	if p.curTokenIs(lexer.TokenHint) {
+		hints, err := parseHints(p.curToken.Literal)
+		if err != nil {
+			p.errors = append(p.errors, err.Error())
+			return nil
+		}
+		stmt.Hints = hints
+		p.nextToken()
 	}
 
-	// Parse table name
-	if !p.expectPeek(lexer.TokenIdent) {
-		return nil
+	// Parse column list
+	stmt.Columns = p.parseSelectList()
+
+	// Optional FROM: without it, SELECT evaluates its expressions once,
+	// like a calculator (SELECT 1 + 1)
+	if p.peekTokenIs(lexer.TokenFrom) {
+		p.nextToken() // move to FROM
+		if !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		stmt.From = p.curToken.Literal
+
+		// A table function, named after itself unless given AS a name:
+		// FROM generate_series(1, 10) AS n
+		if p.peekTokenIs(lexer.TokenLeftParen) {
+			call, ok := p.parseFunctionCall().(*FunctionCall)
+			if !ok {
+				return nil
+			}
+			stmt.FromFunc, stmt.From = call, strings.ToLower(call.Name)
+			if p.peekTokenIs(lexer.TokenAs) {
+				p.nextToken() // move to AS
+				if !p.expectPeek(lexer.TokenIdent) {
+					return nil
+				}
+				stmt.From = p.curToken.Literal
+			}
+		}
+
+		for p.peekTokenIs(lexer.TokenJoin) || p.peekTokenIs(lexer.TokenInner) {
+			join, ok := p.parseJoinClause()
+			if !ok {
+				return nil
+			}
+			stmt.Joins = append(stmt.Joins, join)
+		}
 	}
-	stmt.From = p.curToken.Literal
 
 	// Optional WHERE clause
 	if p.peekTokenIs(lexer.TokenWhere) {
@@ -147,6 +271,26 @@ func (p *Parser) parseSelectStatement() *SelectStatement {
 		stmt.Where = p.parseExpression(PrecedenceLowest)
 	}
 
+	// Optional GROUP BY clause
+	if p.peekTokenIs(lexer.TokenGroup) {
+		p.nextToken() // move to GROUP
+		if !p.expectPeek(lexer.TokenBy) {
+			return nil
+		}
+		for {
+			p.nextToken() // move to column
+			if !p.curTokenIs(lexer.TokenIdent) {
+				p.errors = append(p.errors, "expected column name in GROUP BY")
+				return nil
+			}
+			stmt.GroupBy = append(stmt.GroupBy, p.curToken.Literal)
+			if !p.peekTokenIs(lexer.TokenComma) {
+				break
+			}
+			p.nextToken() // consume comma
+		}
+	}
+
 	// Optional ORDER BY clause
 	if p.peekTokenIs(lexer.TokenOrder) {
 		p.nextToken() // move to ORDER
@@ -180,9 +324,40 @@ func (p *Parser) parseSelectStatement() *SelectStatement {
 		}
 	}
 
+	// Optional FOR UPDATE: lock the rows returned
+	if p.peekTokenIs(lexer.TokenFor) {
+		p.nextToken() // move to FOR
+		if !p.expectPeek(lexer.TokenUpdate) {
+			return nil
+		}
+		if stmt.From == "" {
+			p.errors = append(p.errors, "FOR UPDATE requires a FROM clause")
+			return nil
+		}
+		stmt.ForUpdate = true
+	}
+
 	return stmt
 }
 
+// parseJoinClause parses: [INNER] JOIN table ON condition
+func (p *Parser) parseJoinClause() (JoinClause, bool) {
+	p.nextToken() // move to INNER or JOIN
+	if p.curTokenIs(lexer.TokenInner) && !p.expectPeek(lexer.TokenJoin) {
+		return JoinClause{}, false
+	}
+	if !p.expectPeek(lexer.TokenIdent) {
+		return JoinClause{}, false
+	}
+	join := JoinClause{Table: p.curToken.Literal}
+	if !p.expectPeek(lexer.TokenOn) {
+		return JoinClause{}, false
+	}
+	p.nextToken() // move past ON
+	join.On = p.parseExpression(PrecedenceLowest)
+	return join, true
+}
+
 // parseOrderByClause parses: ORDER BY column [ASC|DESC], ...
 func (p *Parser) parseOrderByClause() []OrderByClause {
 	var clauses []OrderByClause
@@ -363,6 +538,39 @@ func (p *Parser) parseCreateStatement() Statement {
 		return p.parseCreateIndexStatement(false)
 	}
 
+	if p.peekWordIs("TRIGGER") {
+		p.nextToken() // move to TRIGGER
+		return p.parseCreateTriggerStatement()
+	}
+
+	if p.peekWordIs("SEQUENCE") {
+		p.nextToken() // move to SEQUENCE
+		return p.parseCreateSequenceStatement()
+	}
+
+	if p.peekWordIs("MATERIALIZED") {
+		p.nextToken() // move to MATERIALIZED
+		return p.parseCreateMaterializedViewStatement()
+	}
+
+	if p.peekWordIs("VIRTUAL") {
+		p.nextToken() // move to VIRTUAL
+		return p.parseCreateVirtualTableStatement()
+	}
+
+	if p.peekWordIs("TEMP") || p.peekWordIs("TEMPORARY") {
+		p.nextToken() // move to TEMP
+		if !p.expectPeek(lexer.TokenTable) {
+			return nil
+		}
+		stmt := p.parseCreateTableStatement()
+		if stmt == nil {
+			return nil
+		}
+		stmt.Temporary = true
+		return stmt
+	}
+
 	// Expect TABLE
 	if !p.expectPeek(lexer.TokenTable) {
 		return nil
@@ -371,7 +579,8 @@ func (p *Parser) parseCreateStatement() Statement {
 	return p.parseCreateTableStatement()
 }
 
-// parseCreateTableStatement parses: CREATE TABLE name (column_definitions)
+// parseCreateTableStatement parses: CREATE TABLE name (column_definitions),
+// where the definitions may end with a PRIMARY KEY (a, b) constraint.
 func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
 	stmt := &CreateTableStatement{}
 
@@ -387,7 +596,8 @@ func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
 	}
 
 	// Parse column definitions
-	stmt.Columns = p.parseColumnDefinitions()
+	var keyColumns []string
+	stmt.Columns, keyColumns = p.parseColumnDefinitions()
 
 	// Expect )
 	if !p.expectPeek(lexer.TokenRightParen) {
@@ -395,54 +605,190 @@ func (p *Parser) parseCreateTableStatement() *CreateTableStatement {
 	}
 
 	// Find primary key
-	for _, col := range stmt.Columns {
-		if col.PrimaryKey {
-			stmt.PrimaryKey = col.Name
-			break
+	if !p.setPrimaryKey(stmt, keyColumns) {
+		return nil
+	}
+
+	// Optional PARTITION BY clause
+	if p.peekWordIs("PARTITION") {
+		p.nextToken()
+		if stmt.Partition = p.parsePartitionSpec(); stmt.Partition == nil {
+			return nil
+		}
+	}
+
+	// Optional USING clause: the storage method, where "heap" is the
+	// default row storage
+	if p.peekWordIs("USING") {
+		p.nextToken()
+		if !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		switch method := strings.ToLower(p.curToken.Literal); method {
+		case "heap":
+		case "columnar":
+			stmt.Using = method
+		default:
+			p.errors = append(p.errors, fmt.Sprintf("unknown table storage method %s: expected heap or columnar", p.curToken.Literal))
+			return nil
 		}
 	}
 
 	return stmt
 }
 
-// parseColumnDefinitions parses column definitions in CREATE TABLE.
-func (p *Parser) parseColumnDefinitions() []ColumnDefinition {
-	var columns []ColumnDefinition
+// parsePartitionSpec parses the rest of a PARTITION BY clause, from BY:
+//
+//   PARTITION BY RANGE (column) (PARTITION name VALUES LESS THAN (bound), ...
+//     [, PARTITION name VALUES LESS THAN MAXVALUE])
+//   PARTITION BY HASH (column) PARTITIONS n
+func (p *Parser) parsePartitionSpec() *PartitionSpec {
+	if !p.expectPeek(lexer.TokenBy) || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	spec := &PartitionSpec{Method: strings.ToUpper(p.curToken.Literal)}
+	if spec.Method != "RANGE" && spec.Method != "HASH" {
+		p.errors = append(p.errors, fmt.Sprintf("expected RANGE or HASH, got %s", p.curToken.Literal))
+		return nil
+	}
+	if !p.expectPeek(lexer.TokenLeftParen) || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	spec.Column = p.curToken.Literal
+	if !p.expectPeek(lexer.TokenRightParen) {
+		return nil
+	}
+
+	if spec.Method == "HASH" {
+		if !p.expectPeekWord("PARTITIONS") || !p.expectPeek(lexer.TokenNumber) {
+			return nil
+		}
+		n, err := strconv.Atoi(p.curToken.Literal)
+		if err != nil || n < 1 {
+			p.errors = append(p.errors, fmt.Sprintf("invalid number of partitions: %s", p.curToken.Literal))
+			return nil
+		}
+		spec.Count = n
+		return spec
+	}
 
+	if !p.expectPeek(lexer.TokenLeftParen) {
+		return nil
+	}
 	for {
-		p.nextToken()
-		if !p.curTokenIs(lexer.TokenIdent) {
-			p.errors = append(p.errors, "expected column name")
+		if !p.expectPeekWord("PARTITION") || !p.expectPeek(lexer.TokenIdent) {
 			return nil
 		}
+		part := PartitionDefinition{Name: p.curToken.Literal}
+		if !p.expectPeek(lexer.TokenValues) || !p.expectPeekWord("LESS") || !p.expectPeekWord("THAN") {
+			return nil
+		}
+		// MAXVALUE may come in parentheses too, as MySQL allows
+		if p.peekWordIs("MAXVALUE") {
+			p.nextToken()
+		} else {
+			if !p.expectPeek(lexer.TokenLeftParen) {
+				return nil
+			}
+			if p.peekWordIs("MAXVALUE") {
+				p.nextToken()
+			} else {
+				p.nextToken()
+				if part.Bound = p.parseExpression(PrecedenceLowest); part.Bound == nil {
+					return nil
+				}
+			}
+			if !p.expectPeek(lexer.TokenRightParen) {
+				return nil
+			}
+		}
+		spec.Partitions = append(spec.Partitions, part)
+		if !p.peekTokenIs(lexer.TokenComma) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(lexer.TokenRightParen) {
+		return nil
+	}
+	return spec
+}
 
-		col := ColumnDefinition{
-			Name: p.curToken.Literal,
+// setPrimaryKey marks the primary key's columns: those of the PRIMARY KEY
+// constraint if there was one, or the one declared PRIMARY KEY. A
+// constraint naming one column is kept as a column's PRIMARY KEY, so that
+// both spellings give the same statement.
+func (p *Parser) setPrimaryKey(stmt *CreateTableStatement, keyColumns []string) bool {
+	var declared []string
+	for _, col := range stmt.Columns {
+		if col.PrimaryKey {
+			declared = append(declared, col.Name)
 		}
+	}
+	if len(declared) > 1 || (len(declared) > 0 && keyColumns != nil) {
+		p.errors = append(p.errors, fmt.Sprintf("table %s has more than one primary key", stmt.Table))
+		return false
+	}
 
-		// Parse data type
-		p.nextToken()
-		col.Type = p.parseDataType()
+	for _, name := range keyColumns {
+		i := slices.IndexFunc(stmt.Columns, func(col ColumnDefinition) bool { return col.Name == name })
+		if i < 0 {
+			p.errors = append(p.errors, fmt.Sprintf("primary key column %s does not exist", name))
+			return false
+		}
+		if stmt.Columns[i].PrimaryKey {
+			p.errors = append(p.errors, fmt.Sprintf("column %s appears twice in the primary key", name))
+			return false
+		}
+		stmt.Columns[i].PrimaryKey = true
+	}
+	if len(keyColumns) > 1 {
+		stmt.PrimaryKeyColumns = keyColumns
+		return true
+	}
+	if len(keyColumns) == 1 {
+		declared = keyColumns
+	}
+	if len(declared) == 1 {
+		stmt.PrimaryKey = declared[0]
+	}
+	return true
+}
 
-		// Check for PRIMARY KEY
-		if p.peekTokenIs(lexer.TokenPrimaryKey) {
-			p.nextToken()
+// parseColumnDefinitions parses column definitions in CREATE TABLE, and
+// the columns of a PRIMARY KEY (a, b) constraint among them.
+func (p *Parser) parseColumnDefinitions() ([]ColumnDefinition, []string) {
+	var columns []ColumnDefinition
+	var keyColumns []string
+
+	for {
+		p.nextToken()
+		if p.curTokenIs(lexer.TokenPrimaryKey) {
+			if keyColumns != nil {
+				p.errors = append(p.errors, "more than one PRIMARY KEY constraint")
+				return nil, nil
+			}
 			// Check for KEY after PRIMARY
 			if p.peekTokenIs(lexer.TokenIdent) && strings.ToUpper(p.peekToken.Literal) == "KEY" {
 				p.nextToken()
 			}
-			col.PrimaryKey = true
-		}
-
-		// Check for NOT NULL
-		if p.peekTokenIs(lexer.TokenNot) {
-			p.nextToken()
-			if p.peekTokenIs(lexer.TokenNull) {
-				p.nextToken()
-				col.NotNull = true
+			if !p.expectPeek(lexer.TokenLeftParen) {
+				return nil, nil
 			}
+			keyColumns = p.parseIndexColumnList()
+			if keyColumns == nil || !p.expectPeek(lexer.TokenRightParen) {
+				return nil, nil
+			}
+			if !p.peekTokenIs(lexer.TokenComma) {
+				break
+			}
+			p.nextToken() // consume comma
+			continue
+		}
+		col, ok := p.parseColumnDefinition()
+		if !ok {
+			return nil, nil
 		}
-
 		columns = append(columns, col)
 
 		// Check for comma or end
@@ -452,10 +798,79 @@ func (p *Parser) parseColumnDefinitions() []ColumnDefinition {
 		p.nextToken() // consume comma
 	}
 
-	return columns
+	return columns, keyColumns
+}
+
+// parseColumnDefinition parses one column definition, starting at the
+// column's name: name type [COLLATE c] [PRIMARY KEY] [NOT NULL] [DEFAULT
+// expr], where DEFAULT may also come before NOT NULL.
+func (p *Parser) parseColumnDefinition() (ColumnDefinition, bool) {
+	if !p.curTokenIs(lexer.TokenIdent) {
+		p.errors = append(p.errors, "expected column name")
+		return ColumnDefinition{}, false
+	}
+
+	col := ColumnDefinition{
+		Name: p.curToken.Literal,
+	}
+
+	// Parse data type
+	p.nextToken()
+	col.Type = p.parseDataType()
+	if col.Type == TypeDecimal {
+		col.Precision, col.Scale = p.parseDecimalModifiers()
+	}
+	if col.Type == TypeText {
+		col.Length = p.parseLength()
+	}
+	if p.peekTokenIs(lexer.TokenIdent) && strings.ToUpper(p.peekToken.Literal) == "COLLATE" {
+		p.nextToken()
+		col.Collation = p.parseCollation(col)
+	}
+
+	// Check for PRIMARY KEY
+	if p.peekTokenIs(lexer.TokenPrimaryKey) {
+		p.nextToken()
+		// Check for KEY after PRIMARY
+		if p.peekTokenIs(lexer.TokenIdent) && strings.ToUpper(p.peekToken.Literal) == "KEY" {
+			p.nextToken()
+		}
+		col.PrimaryKey = true
+	}
+
+	if !p.parseDefault(&col) {
+		return col, false
+	}
+
+	// Check for NOT NULL
+	if p.peekTokenIs(lexer.TokenNot) {
+		p.nextToken()
+		if p.peekTokenIs(lexer.TokenNull) {
+			p.nextToken()
+			col.NotNull = true
+		}
+	}
+
+	if col.Default == nil && !p.parseDefault(&col) {
+		return col, false
+	}
+	return col, true
+}
+
+// parseDefault parses a column's DEFAULT expr, if the next token starts
+// one.
+func (p *Parser) parseDefault(col *ColumnDefinition) bool {
+	if !p.peekWordIs("DEFAULT") {
+		return true
+	}
+	p.nextToken() // move to DEFAULT
+	p.nextToken()
+	col.Default = p.parseExpression(PrecedenceLowest)
+	return col.Default != nil
 }
 
-// parseDataType parses a SQL data type.
+// parseDataType parses a SQL data type. A text type's length, as in
+// VARCHAR(n), is left for parseLength.
 func (p *Parser) parseDataType() DataType {
 	switch p.curToken.Type {
 	case lexer.TokenInt, lexer.TokenInteger:
@@ -463,12 +878,6 @@ func (p *Parser) parseDataType() DataType {
 	case lexer.TokenReal:
 		return TypeReal
 	case lexer.TokenText, lexer.TokenVarchar:
-		// Handle VARCHAR(n)
-		if p.peekTokenIs(lexer.TokenLeftParen) {
-			p.nextToken() // (
-			p.nextToken() // size
-			p.nextToken() // )
-		}
 		return TypeText
 	case lexer.TokenBool:
 		return TypeBoolean
@@ -483,6 +892,12 @@ func (p *Parser) parseDataType() DataType {
 			return TypeText
 		case "BOOL", "BOOLEAN":
 			return TypeBoolean
+		case "TIMESTAMP", "DATETIME":
+			return TypeTimestamp
+		case "DECIMAL", "NUMERIC":
+			return TypeDecimal
+		case "JSON":
+			return TypeJSON
 		default:
 			p.errors = append(p.errors, fmt.Sprintf("unknown data type: %s", p.curToken.Literal))
 			return TypeUnknown
@@ -490,6 +905,89 @@ func (p *Parser) parseDataType() DataType {
 	}
 }
 
+// parseDecimalModifiers parses the optional (precision[, scale]) after
+// DECIMAL. With no modifiers both are 0, meaning "any precision, keep the
+// scale of whatever is stored".
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// DECIMAL(10,2) holds up to 10 significant digits, 2 of them after the
+// decimal point - so the largest value is 99999999.99. Precision is capped
+// at 18 digits because the unscaled value is stored in an int64.
+func (p *Parser) parseDecimalModifiers() (precision, scale int) {
+	if !p.peekTokenIs(lexer.TokenLeftParen) {
+		return 0, 0
+	}
+	p.nextToken() // (
+
+	precision = p.parseTypeModifier()
+	if p.peekTokenIs(lexer.TokenComma) {
+		p.nextToken() // ,
+		scale = p.parseTypeModifier()
+	}
+	if !p.expectPeek(lexer.TokenRightParen) {
+		return 0, 0
+	}
+
+	if precision < 1 || precision > 18 {
+		p.errors = append(p.errors, fmt.Sprintf("DECIMAL precision %d must be between 1 and 18", precision))
+	} else if scale > precision {
+		p.errors = append(p.errors, fmt.Sprintf("DECIMAL scale %d cannot exceed precision %d", scale, precision))
+	}
+	return precision, scale
+}
+
+// parseLength parses the optional (n) after VARCHAR, the most characters
+// the column holds, returning 0 if there is none.
+func (p *Parser) parseLength() int {
+	if !p.peekTokenIs(lexer.TokenLeftParen) {
+		return 0
+	}
+	p.nextToken() // (
+
+	length := p.parseTypeModifier()
+	if !p.expectPeek(lexer.TokenRightParen) {
+		return 0
+	}
+	if length < 1 {
+		p.errors = append(p.errors, fmt.Sprintf("VARCHAR length %d must be at least 1", length))
+	}
+	return length
+}
+
+// parseCollation parses the collation name after COLLATE, returning ""
+// for BINARY, the default. Only text columns have a collation.
+func (p *Parser) parseCollation(col ColumnDefinition) string {
+	if !p.expectPeek(lexer.TokenIdent) {
+		return ""
+	}
+	name := strings.ToUpper(p.curToken.Literal)
+	switch {
+	case col.Type != TypeText:
+		p.errors = append(p.errors, fmt.Sprintf("collations are not supported by type %s of column %s", col.Type, col.Name))
+	case name == "BINARY":
+		return ""
+	case name == "NOCASE":
+		return name
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("unknown collation: %s", p.curToken.Literal))
+	}
+	return ""
+}
+
+// parseTypeModifier parses one integer inside a type's parentheses.
+func (p *Parser) parseTypeModifier() int {
+	if !p.expectPeek(lexer.TokenNumber) {
+		return 0
+	}
+	n, err := strconv.Atoi(p.curToken.Literal)
+	if err != nil || n < 0 {
+		p.errors = append(p.errors, fmt.Sprintf("invalid type modifier: %s", p.curToken.Literal))
+		return 0
+	}
+	return n
+}
+
 // parseCreateIndexStatement parses: CREATE [UNIQUE] INDEX name ON table (columns)
 func (p *Parser) parseCreateIndexStatement(unique bool) *CreateIndexStatement {
 	stmt := &CreateIndexStatement{
@@ -557,6 +1055,30 @@ func (p *Parser) parseDropStatement() Statement {
 		return p.parseDropIndexStatement()
 	}
 
+	if p.peekWordIs("TRIGGER") {
+		p.nextToken() // move to TRIGGER
+		if !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		return &DropTriggerStatement{Name: p.curToken.Literal}
+	}
+
+	if p.peekWordIs("SEQUENCE") {
+		p.nextToken() // move to SEQUENCE
+		if !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		return &DropSequenceStatement{Name: p.curToken.Literal}
+	}
+
+	if p.peekWordIs("MATERIALIZED") {
+		p.nextToken() // move to MATERIALIZED
+		if !p.expectPeekWord("VIEW") || !p.expectPeek(lexer.TokenIdent) {
+			return nil
+		}
+		return &DropMaterializedViewStatement{Name: p.curToken.Literal}
+	}
+
 	// Expect TABLE
 	if !p.expectPeek(lexer.TokenTable) {
 		return nil
@@ -573,18 +1095,78 @@ func (p *Parser) parseDropStatement() Statement {
 	return stmt
 }
 
-// parseExplainStatement parses: EXPLAIN <statement>
+// parseAlterTableStatement parses:
 //
-// EDUCATIONAL NOTE:
-// -----------------
-// EXPLAIN shows the query plan without executing the query.
-// It wraps another statement (SELECT, UPDATE, DELETE) to explain.
+//   ALTER TABLE name ADD [COLUMN] column-definition
+//   ALTER TABLE name DROP [COLUMN] column
+func (p *Parser) parseAlterTableStatement() Statement {
+	if !p.expectPeek(lexer.TokenTable) {
+		return nil
+	}
+	if !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt := &AlterTableStatement{Table: p.curToken.Literal}
+
+	p.nextToken()
+	add := p.curTokenIs(lexer.TokenIdent) && strings.ToUpper(p.curToken.Literal) == "ADD"
+	if !add && !p.curTokenIs(lexer.TokenDrop) {
+		p.errors = append(p.errors, fmt.Sprintf("expected ADD or DROP after ALTER TABLE %s, got %s", stmt.Table, p.curToken.Literal))
+		return nil
+	}
+	p.nextToken()
+	if p.curTokenIs(lexer.TokenIdent) && strings.ToUpper(p.curToken.Literal) == "COLUMN" {
+		p.nextToken()
+	}
+
+	if add {
+		col, ok := p.parseColumnDefinition()
+		if !ok {
+			return nil
+		}
+		stmt.AddColumn = &col
+		return stmt
+	}
+	if !p.curTokenIs(lexer.TokenIdent) {
+		p.errors = append(p.errors, "expected column name")
+		return nil
+	}
+	stmt.DropColumn = p.curToken.Literal
+	return stmt
+}
+
+// parseExplainStatement parses: EXPLAIN [ANALYZE | (option, ...)] <statement>
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// EXPLAIN shows the query plan without executing the query.
+// It wraps another statement (SELECT, UPDATE, DELETE) to explain.
+//
+// Options can also be given PostgreSQL-style, in parentheses, which is how
+// a plan is requested in a machine-readable format:
+//
+//   EXPLAIN (FORMAT JSON) SELECT ...
+//   EXPLAIN (ANALYZE, FORMAT TREE) SELECT ...
 func (p *Parser) parseExplainStatement() *ExplainStatement {
 	stmt := &ExplainStatement{}
 
 	// Move past EXPLAIN
 	p.nextToken()
 
+	if p.curTokenIs(lexer.TokenLeftParen) {
+		if !p.parseExplainOptions(stmt) {
+			return nil
+		}
+		p.nextToken()
+	}
+
+	// EXPLAIN ANALYZE <statement>. Plain EXPLAIN ANALYZE [table] still
+	// explains an ANALYZE statement.
+	if p.curTokenIs(lexer.TokenAnalyze) && p.startsStatement(p.peekToken) {
+		stmt.Analyze = true
+		p.nextToken()
+	}
+
 	// Parse the inner statement
 	inner := p.parseStatement()
 	if inner == nil {
@@ -595,6 +1177,290 @@ func (p *Parser) parseExplainStatement() *ExplainStatement {
 	return stmt
 }
 
+// parseExplainOptions parses the parenthesized option list of EXPLAIN,
+// leaving the closing parenthesis as the current token.
+func (p *Parser) parseExplainOptions(stmt *ExplainStatement) bool {
+	for {
+		p.nextToken()
+		switch {
+		case p.curTokenIs(lexer.TokenAnalyze):
+			stmt.Analyze = true
+		case strings.EqualFold(p.curToken.Literal, "FORMAT"):
+			p.nextToken()
+			switch strings.ToUpper(p.curToken.Literal) {
+			case "TEXT":
+				stmt.Format = ExplainText
+			case "TREE":
+				stmt.Format = ExplainTree
+			case "JSON":
+				stmt.Format = ExplainJSON
+			default:
+				p.errors = append(p.errors, fmt.Sprintf("unknown EXPLAIN format: %s", p.curToken.Literal))
+				return false
+			}
+		default:
+			p.errors = append(p.errors, fmt.Sprintf("unknown EXPLAIN option: %s", p.curToken.Literal))
+			return false
+		}
+
+		if !p.peekTokenIs(lexer.TokenComma) {
+			return p.expectPeek(lexer.TokenRightParen)
+		}
+		p.nextToken()
+	}
+}
+
+// startsStatement reports whether tok can begin a statement that
+// EXPLAIN ANALYZE can run.
+func (p *Parser) startsStatement(tok lexer.Token) bool {
+	switch tok.Type {
+	case lexer.TokenSelect, lexer.TokenInsert, lexer.TokenUpdate, lexer.TokenDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseCreateSequenceStatement parses:
+//
+//   CREATE SEQUENCE name [START [WITH] n] [INCREMENT [BY] n]
+//
+// A sequence counts up from 1 by default, or down from -1 with a negative
+// INCREMENT.
+func (p *Parser) parseCreateSequenceStatement() Statement {
+	if !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt := &CreateSequenceStatement{Name: p.curToken.Literal, Start: 1, Increment: 1}
+
+	start := false
+	for p.peekWordIs("START") || p.peekWordIs("INCREMENT") {
+		p.nextToken()
+		option := strings.ToUpper(p.curToken.Literal)
+		if p.peekWordIs("WITH") || p.peekTokenIs(lexer.TokenBy) {
+			p.nextToken()
+		}
+		n, ok := p.parseSignedInteger()
+		if !ok {
+			return nil
+		}
+		if option == "START" {
+			stmt.Start, start = n, true
+		} else {
+			stmt.Increment = n
+		}
+	}
+	if stmt.Increment == 0 {
+		p.errors = append(p.errors, fmt.Sprintf("sequence %s: INCREMENT must not be zero", stmt.Name))
+		return nil
+	}
+	if !start && stmt.Increment < 0 {
+		stmt.Start = -1
+	}
+	return stmt
+}
+
+// parseSignedInteger parses the next tokens as an integer, with an
+// optional minus sign.
+func (p *Parser) parseSignedInteger() (int64, bool) {
+	negative := p.peekTokenIs(lexer.TokenMinus)
+	if negative {
+		p.nextToken()
+	}
+	if !p.expectPeek(lexer.TokenNumber) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+	if err != nil {
+		p.errors = append(p.errors, fmt.Sprintf("expected an integer, got %s", p.curToken.Literal))
+		return 0, false
+	}
+	if negative {
+		n = -n
+	}
+	return n, true
+}
+
+// parseCreateMaterializedViewStatement parses:
+//
+//   CREATE MATERIALIZED VIEW name AS SELECT ...
+func (p *Parser) parseCreateMaterializedViewStatement() Statement {
+	if !p.expectPeekWord("VIEW") || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt := &CreateMaterializedViewStatement{Name: p.curToken.Literal}
+	if !p.expectPeek(lexer.TokenAs) || !p.expectPeek(lexer.TokenSelect) {
+		return nil
+	}
+	start := p.curToken.Pos
+	if stmt.Query = p.parseSelectStatement(); stmt.Query == nil {
+		return nil
+	}
+	stmt.QuerySQL = strings.TrimSpace(p.lexer.Input()[start:p.peekToken.Pos])
+	return stmt
+}
+
+// parseCreateVirtualTableStatement parses:
+//
+//   CREATE VIRTUAL TABLE name USING module('argument', ...)
+func (p *Parser) parseCreateVirtualTableStatement() Statement {
+	if !p.expectPeek(lexer.TokenTable) || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt := &CreateVirtualTableStatement{Name: p.curToken.Literal}
+	if !p.expectPeekWord("USING") || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt.Module = strings.ToLower(p.curToken.Literal)
+	if !p.expectPeek(lexer.TokenLeftParen) {
+		return nil
+	}
+	for !p.peekTokenIs(lexer.TokenRightParen) {
+		if !p.expectPeek(lexer.TokenString) {
+			return nil
+		}
+		stmt.Args = append(stmt.Args, p.curToken.Literal)
+		if !p.peekTokenIs(lexer.TokenComma) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(lexer.TokenRightParen) {
+		return nil
+	}
+	return stmt
+}
+
+// parseCopyStatement parses:
+//
+//   COPY table [(column, ...)] FROM 'file' | STDIN [WITH HEADER]
+func (p *Parser) parseCopyStatement() Statement {
+	if !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt := &CopyStatement{Table: p.curToken.Literal}
+	if p.peekTokenIs(lexer.TokenLeftParen) {
+		p.nextToken()
+		stmt.Columns = p.parseIdentifierList()
+		if !p.expectPeek(lexer.TokenRightParen) {
+			return nil
+		}
+	}
+	if !p.expectPeek(lexer.TokenFrom) {
+		return nil
+	}
+	if p.peekWordIs("STDIN") {
+		p.nextToken()
+		stmt.Stdin = true
+	} else if p.expectPeek(lexer.TokenString) {
+		stmt.File = p.curToken.Literal
+	} else {
+		return nil
+	}
+	if p.peekWordIs("WITH") {
+		p.nextToken()
+		if !p.expectPeekWord("HEADER") {
+			return nil
+		}
+		stmt.Header = true
+	}
+	return stmt
+}
+
+// parseRefreshStatement parses: REFRESH MATERIALIZED VIEW name
+func (p *Parser) parseRefreshStatement() Statement {
+	if !p.expectPeekWord("MATERIALIZED") || !p.expectPeekWord("VIEW") || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	return &RefreshMaterializedViewStatement{Name: p.curToken.Literal}
+}
+
+// parseCreateTriggerStatement parses:
+//
+//   CREATE TRIGGER name BEFORE|AFTER INSERT|UPDATE|DELETE ON table
+//   [FOR EACH ROW] BEGIN statement; ... END
+//
+// The body's statements are the ones EXPLAIN ANALYZE can run, each ended
+// by a semicolon.
+func (p *Parser) parseCreateTriggerStatement() Statement {
+	if !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt := &CreateTriggerStatement{Name: p.curToken.Literal}
+
+	p.nextToken()
+	stmt.Timing = strings.ToUpper(p.curToken.Literal)
+	if !p.curTokenIs(lexer.TokenIdent) || (stmt.Timing != "BEFORE" && stmt.Timing != "AFTER") {
+		p.errors = append(p.errors, fmt.Sprintf("expected BEFORE or AFTER after CREATE TRIGGER %s, got %s", stmt.Name, p.curToken.Literal))
+		return nil
+	}
+	p.nextToken()
+	switch p.curToken.Type {
+	case lexer.TokenInsert, lexer.TokenUpdate, lexer.TokenDelete:
+		stmt.Event = strings.ToUpper(p.curToken.Literal)
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("expected INSERT, UPDATE or DELETE after %s, got %s", stmt.Timing, p.curToken.Literal))
+		return nil
+	}
+	if !p.expectPeek(lexer.TokenOn) || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt.Table = p.curToken.Literal
+
+	if p.peekTokenIs(lexer.TokenFor) {
+		p.nextToken()
+		for _, word := range []string{"EACH", "ROW"} {
+			if !p.peekWordIs(word) {
+				p.errors = append(p.errors, fmt.Sprintf("expected FOR EACH ROW, got %s", p.peekToken.Literal))
+				return nil
+			}
+			p.nextToken()
+		}
+	}
+	if !p.expectPeek(lexer.TokenBegin) {
+		return nil
+	}
+
+	p.nextToken()
+	for !(p.curTokenIs(lexer.TokenIdent) && strings.EqualFold(p.curToken.Literal, "END")) {
+		if !p.startsStatement(p.curToken) {
+			p.errors = append(p.errors, fmt.Sprintf("expected SELECT, INSERT, UPDATE, DELETE or END in trigger body, got %s", p.curToken.Literal))
+			return nil
+		}
+		start := p.curToken.Pos
+		body := p.parseStatement()
+		if body == nil || !p.expectPeek(lexer.TokenSemicolon) {
+			return nil
+		}
+		stmt.Body = append(stmt.Body, body)
+		stmt.BodySQL = append(stmt.BodySQL, strings.TrimSpace(p.lexer.Input()[start:p.curToken.Pos]))
+		p.nextToken()
+	}
+	if len(stmt.Body) == 0 {
+		p.errors = append(p.errors, fmt.Sprintf("trigger %s has no statements", stmt.Name))
+		return nil
+	}
+	return stmt
+}
+
+// peekWordIs reports whether the next token is the identifier word, for
+// keywords like TRIGGER that are only keywords in one place.
+func (p *Parser) peekWordIs(word string) bool {
+	return p.peekTokenIs(lexer.TokenIdent) && strings.EqualFold(p.peekToken.Literal, word)
+}
+
+// expectPeekWord moves to the next token if it is the identifier word,
+// and records an error if not, like expectPeek for a word that isn't
+// reserved.
+func (p *Parser) expectPeekWord(word string) bool {
+	if p.peekWordIs(word) {
+		p.nextToken()
+		return true
+	}
+	p.errors = append(p.errors, fmt.Sprintf("expected %s, got %s", word, p.peekToken.Literal))
+	return false
+}
+
 // parseDropIndexStatement parses: DROP INDEX name
 func (p *Parser) parseDropIndexStatement() *DropIndexStatement {
 	stmt := &DropIndexStatement{}
@@ -625,6 +1491,144 @@ func (p *Parser) parseAnalyzeStatement() Statement {
 
 	return stmt
 }
+
+// parsePragmaStatement parses: PRAGMA name [= value]
+//
+// PRAGMA name = value is SQLite's way of changing a setting, and is the
+// same statement as SET name = value.
+func (p *Parser) parsePragmaStatement() Statement {
+	if !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	name := strings.ToLower(p.curToken.Literal)
+	if !p.peekTokenIs(lexer.TokenEquals) {
+		return &PragmaStatement{Name: name}
+	}
+	p.nextToken()
+	p.nextToken()
+	stmt := &SetStatement{Name: name}
+	if stmt.Value = p.parseExpression(PrecedenceLowest); stmt.Value == nil {
+		p.errors = append(p.errors, fmt.Sprintf("expected a value for %s", stmt.Name))
+		return nil
+	}
+	return stmt
+}
+
+// parseSetStatement parses: SET name {= | TO} value
+func (p *Parser) parseSetStatement() Statement {
+	if !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt := &SetStatement{Name: strings.ToLower(p.curToken.Literal)}
+
+	// TO is not a reserved word, so it arrives as an identifier
+	if p.peekTokenIs(lexer.TokenIdent) && strings.EqualFold(p.peekToken.Literal, "TO") {
+		p.nextToken()
+	} else if !p.expectPeek(lexer.TokenEquals) {
+		return nil
+	}
+	p.nextToken()
+	if stmt.Value = p.parseExpression(PrecedenceLowest); stmt.Value == nil {
+		p.errors = append(p.errors, fmt.Sprintf("expected a value for %s", stmt.Name))
+		return nil
+	}
+	return stmt
+}
+
+// parseShowStatement parses: SHOW TABLES | SHOW INDEXES [FROM table]
+func (p *Parser) parseShowStatement() Statement {
+	// TABLES and INDEXES are not reserved words, so they arrive as
+	// identifiers
+	if !p.peekTokenIs(lexer.TokenIdent) {
+		p.errors = append(p.errors, fmt.Sprintf("expected TABLES or INDEXES after SHOW, got %s", p.peekToken.Literal))
+		return nil
+	}
+	p.nextToken()
+	stmt := &ShowStatement{What: strings.ToLower(p.curToken.Literal)}
+
+	switch stmt.What {
+	case "tables":
+	case "indexes":
+		if p.peekTokenIs(lexer.TokenFrom) {
+			p.nextToken()
+			if !p.expectPeek(lexer.TokenIdent) {
+				return nil
+			}
+			stmt.Table = p.curToken.Literal
+		}
+	default:
+		p.errors = append(p.errors, fmt.Sprintf("expected TABLES or INDEXES after SHOW, got %s", p.curToken.Literal))
+		return nil
+	}
+	return stmt
+}
+
+// skipTransactionKeyword consumes the optional TRANSACTION after BEGIN,
+// COMMIT or ROLLBACK.
+func (p *Parser) skipTransactionKeyword() {
+	if p.peekTokenIs(lexer.TokenTransaction) {
+		p.nextToken()
+	}
+}
+
+// parseAttachStatement parses: ATTACH [DATABASE] 'file' AS alias
+func (p *Parser) parseAttachStatement() Statement {
+	p.skipDatabaseKeyword()
+	if !p.expectPeek(lexer.TokenString) {
+		return nil
+	}
+	stmt := &AttachStatement{Path: p.curToken.Literal}
+	if !p.expectPeek(lexer.TokenAs) || !p.expectPeek(lexer.TokenIdent) {
+		return nil
+	}
+	stmt.Alias = p.curToken.Literal
+	return stmt
+}
+
+// skipDatabaseKeyword consumes the optional DATABASE of ATTACH and DETACH.
+// DATABASE is not a reserved word, so it arrives as an identifier.
+func (p *Parser) skipDatabaseKeyword() {
+	if p.peekTokenIs(lexer.TokenIdent) && strings.EqualFold(p.peekToken.Literal, "DATABASE") {
+		p.nextToken()
+	}
+}
+
+// parseRollbackStatement parses: ROLLBACK [TRANSACTION] [TO [SAVEPOINT] name]
+func (p *Parser) parseRollbackStatement() Statement {
+	stmt := &RollbackStatement{}
+	p.skipTransactionKeyword()
+
+	// TO is not a reserved word, so it arrives as an identifier
+	if p.peekTokenIs(lexer.TokenIdent) && strings.EqualFold(p.peekToken.Literal, "TO") {
+		p.nextToken()
+		stmt.Savepoint = p.parseSavepointName()
+		if stmt.Savepoint == "" {
+			return nil
+		}
+	}
+	return stmt
+}
+
+// parseReleaseStatement parses: RELEASE [SAVEPOINT] name
+func (p *Parser) parseReleaseStatement() Statement {
+	name := p.parseSavepointName()
+	if name == "" {
+		return nil
+	}
+	return &ReleaseStatement{Name: name}
+}
+
+// parseSavepointName parses: [SAVEPOINT] name
+func (p *Parser) parseSavepointName() string {
+	if p.peekTokenIs(lexer.TokenSavepoint) {
+		p.nextToken()
+	}
+	if !p.expectPeek(lexer.TokenIdent) {
+		return ""
+	}
+	return p.curToken.Literal
+}
+
 // parseIdentifierList parses: ident, ident, ident
 func (p *Parser) parseIdentifierList() []string {
 	var identifiers []string
@@ -645,6 +1649,50 @@ func (p *Parser) parseIdentifierList() []string {
 	return identifiers
 }
 
+// reservedWords are SQL keywords the lexer reads as identifiers, since
+// this parser doesn't implement them. They can't be bare column aliases:
+// SELECT 1 IS NULL is an error, not a column named IS (AS "is" still
+// works).
+var reservedWords = map[string]bool{
+	"IS": true, "IN": true, "LIKE": true, "BETWEEN": true, "ESCAPE": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true,
+	"HAVING": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+	"LEFT": true, "RIGHT": true, "FULL": true, "OUTER": true, "CROSS": true,
+	"DISTINCT": true, "ALL": true, "EXISTS": true,
+}
+
+// parseSelectList parses the SELECT list: * and expressions, each
+// expression with an optional alias (price * 2 AS doubled, or
+// price * 2 doubled).
+func (p *Parser) parseSelectList() []Expression {
+	var expressions []Expression
+	for {
+		if p.curTokenIs(lexer.TokenAsterisk) {
+			expressions = append(expressions, &StarExpression{})
+		} else if expr := p.parseExpression(PrecedenceLowest); expr != nil {
+			if p.peekTokenIs(lexer.TokenAs) {
+				p.nextToken() // move to AS
+				if !p.expectPeek(lexer.TokenIdent) {
+					return nil
+				}
+				expr = &AliasExpression{Expr: expr, Alias: p.curToken.Literal}
+			} else if p.peekTokenIs(lexer.TokenIdent) && !reservedWords[strings.ToUpper(p.peekToken.Literal)] {
+				p.nextToken()
+				expr = &AliasExpression{Expr: expr, Alias: p.curToken.Literal}
+			}
+			expressions = append(expressions, expr)
+		}
+
+		if !p.peekTokenIs(lexer.TokenComma) {
+			break
+		}
+		p.nextToken() // move to comma
+		p.nextToken() // move past comma
+	}
+
+	return expressions
+}
+
 // parseExpressionList parses a comma-separated list of expressions.
 func (p *Parser) parseExpressionList() []Expression {
 	var expressions []Expression
@@ -712,6 +1760,7 @@ var precedences = map[lexer.TokenType]int{
 	lexer.TokenGreaterOrEqual: PrecedenceComparison,
 	lexer.TokenPlus:           PrecedenceAddSub,
 	lexer.TokenMinus:          PrecedenceAddSub,
+	lexer.TokenConcat:         PrecedenceAddSub,
 	lexer.TokenAsterisk:       PrecedenceMulDiv,
 	lexer.TokenSlash:          PrecedenceMulDiv,
 }
@@ -758,6 +1807,13 @@ func (p *Parser) parseExpression(precedence int) Expression {
 func (p *Parser) parsePrefixExpression() Expression {
 	switch p.curToken.Type {
 	case lexer.TokenIdent:
+		if p.peekTokenIs(lexer.TokenLeftParen) {
+			return p.parseFunctionCall()
+		}
+		// CURRENT_TIMESTAMP is a function call written without parentheses
+		if strings.ToUpper(p.curToken.Literal) == "CURRENT_TIMESTAMP" {
+			return &FunctionCall{Name: "CURRENT_TIMESTAMP"}
+		}
 		return &Identifier{Name: p.curToken.Literal}
 
 	case lexer.TokenNumber:
@@ -772,6 +1828,11 @@ func (p *Parser) parsePrefixExpression() Expression {
 	case lexer.TokenNull:
 		return &NullLiteral{}
 
+	case lexer.TokenPlaceholder:
+		ph := &Placeholder{Index: p.placeholders}
+		p.placeholders++
+		return ph
+
 	case lexer.TokenAsterisk:
 		return &StarExpression{}
 
@@ -784,11 +1845,84 @@ func (p *Parser) parsePrefixExpression() Expression {
 	case lexer.TokenLeftParen:
 		return p.parseGroupedExpression()
 
+	case lexer.TokenCast:
+		return p.parseCastExpression()
+
 	default:
 		return nil
 	}
 }
 
+// parseFunctionCall parses: name([arg, ...])
+func (p *Parser) parseFunctionCall() Expression {
+	call := &FunctionCall{Name: strings.ToUpper(p.curToken.Literal)}
+
+	p.nextToken() // move to (
+
+	// Empty argument list: RANDOM()
+	if p.peekTokenIs(lexer.TokenRightParen) {
+		p.nextToken()
+		return call
+	}
+
+	for {
+		p.nextToken() // move to argument
+		arg := p.parseExpression(PrecedenceLowest)
+		if arg == nil {
+			p.errors = append(p.errors, fmt.Sprintf("expected argument in call to %s", call.Name))
+			return nil
+		}
+		call.Args = append(call.Args, arg)
+
+		if !p.peekTokenIs(lexer.TokenComma) {
+			break
+		}
+		p.nextToken() // consume comma
+	}
+
+	if !p.expectPeek(lexer.TokenRightParen) {
+		return nil
+	}
+
+	return call
+}
+
+// parseCastExpression parses: CAST(expr AS type)
+func (p *Parser) parseCastExpression() Expression {
+	if !p.expectPeek(lexer.TokenLeftParen) {
+		return nil
+	}
+	p.nextToken() // move past (
+
+	expr := p.parseExpression(PrecedenceLowest)
+	if expr == nil {
+		p.errors = append(p.errors, "expected expression in CAST")
+		return nil
+	}
+
+	if !p.expectPeek(lexer.TokenAs) {
+		return nil
+	}
+	p.nextToken() // move to type name
+
+	cast := &CastExpression{Expr: expr, Type: p.parseDataType()}
+	if cast.Type == TypeUnknown {
+		return nil
+	}
+	if cast.Type == TypeDecimal {
+		cast.Precision, cast.Scale = p.parseDecimalModifiers()
+	}
+	if cast.Type == TypeText {
+		p.parseLength() // CAST doesn't truncate to the length
+	}
+
+	if !p.expectPeek(lexer.TokenRightParen) {
+		return nil
+	}
+
+	return cast
+}
+
 // parseNumberLiteral parses an integer or real literal.
 func (p *Parser) parseNumberLiteral() Expression {
 	literal := p.curToken.Literal
@@ -835,8 +1969,13 @@ func (p *Parser) parseInfixExpression(left Expression) Expression {
 	}
 
 	precedence := p.curPrecedence()
+	operator := p.curToken.Literal
 	p.nextToken()
 	expr.Right = p.parseExpression(precedence)
+	if expr.Right == nil {
+		p.errors = append(p.errors, fmt.Sprintf("expected an expression after %s", operator))
+		return nil
+	}
 
 	return expr
 }
@@ -868,6 +2007,8 @@ func (p *Parser) tokenToOperator(t lexer.TokenType) BinaryOp {
 		return OpMultiply
 	case lexer.TokenSlash:
 		return OpDivide
+	case lexer.TokenConcat:
+		return OpConcat
 	default:
 		return OpUnknown
 	}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
@@ -208,6 +210,270 @@ func TestParseCreateTable(t *testing.T) {
 	}
 }
 
+func TestParseCreateTempTable(t *testing.T) {
+	for _, input := range []string{"CREATE TEMP TABLE staging (id INTEGER)", "create temporary table staging (id INTEGER)"} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		create, ok := stmt.(*CreateTableStatement)
+		if !ok || !create.Temporary {
+			t.Fatalf("%s: expected a temporary CreateTableStatement, got %v", input, stmt)
+		}
+		if got := create.String(); got != "CREATE TEMP TABLE staging (id INTEGER)" {
+			t.Errorf("%s: got %q", input, got)
+		}
+	}
+	if _, err := New(lexer.New("CREATE TEMP INDEX idx ON t (a)")).Parse(); err == nil {
+		t.Error("expected a parse error for CREATE TEMP INDEX")
+	}
+}
+
+func TestParseMaterializedView(t *testing.T) {
+	input := "CREATE MATERIALIZED VIEW totals AS SELECT customer, SUM(total) AS total FROM orders GROUP BY customer;"
+	stmt, err := New(lexer.New(input)).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	create, ok := stmt.(*CreateMaterializedViewStatement)
+	if !ok || create.Name != "totals" || create.Query == nil || create.Query.From != "orders" {
+		t.Fatalf("expected a materialized view of orders, got %#v", stmt)
+	}
+	if got := create.String(); got != input[:len(input)-1] {
+		t.Errorf("got %q", got)
+	}
+
+	for input, want := range map[string]string{
+		"refresh materialized view totals": "REFRESH MATERIALIZED VIEW totals",
+		"DROP MATERIALIZED VIEW totals":    "DROP MATERIALIZED VIEW totals",
+	} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		if got := stmt.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", input, want, got)
+		}
+	}
+
+	for _, input := range []string{
+		"CREATE MATERIALIZED VIEW totals SELECT 1",
+		"CREATE MATERIALIZED TABLE totals AS SELECT 1",
+		"CREATE MATERIALIZED VIEW totals AS DELETE FROM orders",
+		"REFRESH VIEW totals",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParsePartitionBy(t *testing.T) {
+	for input, want := range map[string]string{
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, day INTEGER) partition by range (day) (partition old values less than (100), partition new values less than maxvalue)":   "CREATE TABLE events (id INTEGER PRIMARY KEY, day INTEGER) PARTITION BY RANGE (day) (PARTITION old VALUES LESS THAN (100), PARTITION new VALUES LESS THAN MAXVALUE)",
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, day INTEGER) PARTITION BY RANGE (day) (PARTITION old VALUES LESS THAN (100), PARTITION new VALUES LESS THAN (MAXVALUE))": "CREATE TABLE events (id INTEGER PRIMARY KEY, day INTEGER) PARTITION BY RANGE (day) (PARTITION old VALUES LESS THAN (100), PARTITION new VALUES LESS THAN MAXVALUE)",
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, day INTEGER) PARTITION BY HASH (id) PARTITIONS 4":                                                                        "CREATE TABLE events (id INTEGER PRIMARY KEY, day INTEGER) PARTITION BY HASH (id) PARTITIONS 4",
+	} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		if got := stmt.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", input, want, got)
+		}
+	}
+
+	for _, input := range []string{
+		"CREATE TABLE t (a INTEGER) PARTITION BY LIST (a) PARTITIONS 2",
+		"CREATE TABLE t (a INTEGER) PARTITION BY HASH (a) PARTITIONS 0",
+		"CREATE TABLE t (a INTEGER) PARTITION BY HASH (a)",
+		"CREATE TABLE t (a INTEGER) PARTITION BY RANGE (a) (PARTITION p0 VALUES LESS THAN 10)",
+		"CREATE TABLE t (a INTEGER) PARTITION BY RANGE (a) ()",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseCreateTableUsing(t *testing.T) {
+	for input, want := range map[string]string{
+		"CREATE TABLE facts (region TEXT, amount INTEGER) using COLUMNAR": "CREATE TABLE facts (region TEXT, amount INTEGER) USING columnar",
+		"CREATE TABLE facts (region TEXT) USING heap":                     "CREATE TABLE facts (region TEXT)",
+	} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		if got := stmt.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", input, want, got)
+		}
+	}
+
+	for _, input := range []string{
+		"CREATE TABLE t (a INTEGER) USING rows",
+		"CREATE TABLE t (a INTEGER) USING",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseCreateVirtualTable(t *testing.T) {
+	for input, want := range map[string]string{
+		"CREATE VIRTUAL TABLE logs USING csv('logs/access.csv')": "CREATE VIRTUAL TABLE logs USING csv('logs/access.csv')",
+		"create virtual table t using CSV('it''s.csv', 'x')":     "CREATE VIRTUAL TABLE t USING csv('it''s.csv', 'x')",
+		"CREATE VIRTUAL TABLE t USING csv()":                     "CREATE VIRTUAL TABLE t USING csv()",
+	} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		if got := stmt.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", input, want, got)
+		}
+	}
+
+	for _, input := range []string{
+		"CREATE VIRTUAL TABLE t USING csv",
+		"CREATE VIRTUAL TABLE t USING csv(file.csv)",
+		"CREATE VIRTUAL TABLE t csv('file.csv')",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseCopy(t *testing.T) {
+	for input, want := range map[string]string{
+		"COPY users FROM 'users.csv'":                          "COPY users FROM 'users.csv'",
+		"copy users (name, age) from 'it''s.csv' with header;": "COPY users (name, age) FROM 'it''s.csv' WITH HEADER",
+		"COPY users FROM stdin":                                "COPY users FROM STDIN",
+		"COPY users (id) FROM STDIN WITH HEADER":               "COPY users (id) FROM STDIN WITH HEADER",
+	} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		if got := stmt.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", input, want, got)
+		}
+	}
+
+	for _, input := range []string{
+		"COPY users 'users.csv'",
+		"COPY users FROM users.csv",
+		"COPY users FROM 'users.csv' WITH",
+		"COPY FROM 'users.csv'",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseSelectFromFunction(t *testing.T) {
+	for input, want := range map[string]string{
+		"SELECT * FROM generate_series(1, 10)":                    "generate_series GENERATE_SERIES(1, 10)",
+		"SELECT n FROM generate_series(1, ?, 2) AS n WHERE n > 3": "n GENERATE_SERIES(1, ?, 2)",
+		"SELECT COUNT(*) FROM generate_series(0, 100) LIMIT 5":    "generate_series GENERATE_SERIES(0, 100)",
+	} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		sel := stmt.(*SelectStatement)
+		if sel.FromFunc == nil {
+			t.Fatalf("%s: expected a table function", input)
+		}
+		if got := sel.From + " " + sel.FromFunc.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", input, want, got)
+		}
+	}
+	stmt, err := New(lexer.New("SELECT ? FROM generate_series(?, ?)")).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if n := CountPlaceholders(stmt); n != 3 {
+		t.Errorf("expected 3 placeholders, got %d", n)
+	}
+
+	for _, input := range []string{
+		"SELECT * FROM generate_series(1, 10) AS",
+		"SELECT * FROM generate_series(1,",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseCreateSequence(t *testing.T) {
+	for input, want := range map[string]string{
+		"CREATE SEQUENCE ids":                              "CREATE SEQUENCE ids START WITH 1 INCREMENT BY 1",
+		"create sequence ids start 1000 increment 10":      "CREATE SEQUENCE ids START WITH 1000 INCREMENT BY 10",
+		"CREATE SEQUENCE ids INCREMENT BY -1":              "CREATE SEQUENCE ids START WITH -1 INCREMENT BY -1",
+		"CREATE SEQUENCE ids START WITH -5 INCREMENT BY 2": "CREATE SEQUENCE ids START WITH -5 INCREMENT BY 2",
+		"CREATE TABLE t (id INTEGER PRIMARY KEY DEFAULT NEXTVAL('ids'), n INTEGER NOT NULL DEFAULT 0)": "CREATE TABLE t (id INTEGER PRIMARY KEY DEFAULT NEXTVAL('ids'), n INTEGER NOT NULL DEFAULT 0)",
+	} {
+		stmt, err := New(lexer.New(input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", input, err)
+		}
+		if got := stmt.String(); got != want {
+			t.Errorf("%s: expected %q, got %q", input, want, got)
+		}
+	}
+	if _, err := New(lexer.New("CREATE SEQUENCE ids INCREMENT BY 0")).Parse(); err == nil {
+		t.Error("expected a parse error for INCREMENT BY 0")
+	}
+	stmt, err := New(lexer.New("DROP SEQUENCE ids")).Parse()
+	if drop, ok := stmt.(*DropSequenceStatement); err != nil || !ok || drop.Name != "ids" {
+		t.Errorf("expected DROP SEQUENCE ids, got %v (%v)", stmt, err)
+	}
+}
+
+func TestParseCompositePrimaryKey(t *testing.T) {
+	input := "CREATE TABLE grades (student INTEGER, course TEXT NOT NULL, grade INTEGER, PRIMARY KEY (course, student))"
+	stmt, err := New(lexer.New(input)).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	create := stmt.(*CreateTableStatement)
+	if fmt.Sprint(create.PrimaryKeyColumns) != "[course student]" || create.PrimaryKey != "" {
+		t.Errorf("expected key (course, student), got %v", create.PrimaryKeyColumns)
+	}
+	if !create.Columns[0].PrimaryKey || !create.Columns[1].PrimaryKey || create.Columns[2].PrimaryKey {
+		t.Errorf("expected student and course marked, got %+v", create.Columns)
+	}
+	if create.String() != input {
+		t.Errorf("expected %q, got %q", input, create.String())
+	}
+
+	// A constraint of one column is the same as declaring it on the column
+	stmt, err = New(lexer.New("CREATE TABLE t (a INTEGER, b TEXT, PRIMARY KEY (b))")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if got := stmt.String(); got != "CREATE TABLE t (a INTEGER, b TEXT PRIMARY KEY)" {
+		t.Errorf("unexpected statement %s", got)
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE t (a INTEGER PRIMARY KEY, b TEXT PRIMARY KEY)",
+		"CREATE TABLE t (a INTEGER PRIMARY KEY, b TEXT, PRIMARY KEY (a, b))",
+		"CREATE TABLE t (a INTEGER, b TEXT, PRIMARY KEY (a, c))",
+		"CREATE TABLE t (a INTEGER, b TEXT, PRIMARY KEY (a, a))",
+		"CREATE TABLE t (a INTEGER, b TEXT, PRIMARY KEY (a), PRIMARY KEY (b))",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
 func TestParseUpdate(t *testing.T) {
 	input := "UPDATE users SET age = 31 WHERE name = 'Alice'"
 
@@ -284,6 +550,84 @@ func TestParseDropTable(t *testing.T) {
 	}
 }
 
+func TestParseAlterTable(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"ALTER TABLE users ADD COLUMN email VARCHAR(100) COLLATE NOCASE", "ALTER TABLE users ADD COLUMN email VARCHAR(100) COLLATE NOCASE"},
+		{"ALTER TABLE users ADD age INTEGER", "ALTER TABLE users ADD COLUMN age INTEGER"},
+		{"ALTER TABLE users DROP COLUMN email", "ALTER TABLE users DROP COLUMN email"},
+		{"alter table users drop age", "ALTER TABLE users DROP COLUMN age"},
+	}
+	for _, tt := range tests {
+		stmt, err := New(lexer.New(tt.input)).Parse()
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", tt.input, err)
+		}
+		alter, ok := stmt.(*AlterTableStatement)
+		if !ok {
+			t.Fatalf("%s: expected AlterTableStatement, got %T", tt.input, stmt)
+		}
+		if got := alter.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{
+		"ALTER TABLE users RENAME TO people",
+		"ALTER TABLE users ADD COLUMN",
+		"ALTER users DROP COLUMN age",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
+func TestParseCreateTrigger(t *testing.T) {
+	input := `CREATE TRIGGER log_price AFTER UPDATE ON items BEGIN
+		INSERT INTO price_log VALUES (OLD.id, OLD.price, NEW.price);
+		UPDATE totals SET n = n + 1  WHERE id = 1;
+	END`
+	stmt, err := New(lexer.New(input)).Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	trigger, ok := stmt.(*CreateTriggerStatement)
+	if !ok {
+		t.Fatalf("expected CreateTriggerStatement, got %T", stmt)
+	}
+	if trigger.Name != "log_price" || trigger.Timing != "AFTER" || trigger.Event != "UPDATE" || trigger.Table != "items" {
+		t.Errorf("unexpected trigger %+v", trigger)
+	}
+	if _, ok := trigger.Body[1].(*UpdateStatement); !ok || len(trigger.Body) != 2 {
+		t.Fatalf("expected an INSERT and an UPDATE, got %v", trigger.Body)
+	}
+	want := "CREATE TRIGGER log_price AFTER UPDATE ON items FOR EACH ROW BEGIN " +
+		"INSERT INTO price_log VALUES (OLD.id, OLD.price, NEW.price); UPDATE totals SET n = n + 1  WHERE id = 1; END"
+	if got := trigger.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	stmt, err = New(lexer.New("drop trigger log_price")).Parse()
+	if drop, ok := stmt.(*DropTriggerStatement); err != nil || !ok || drop.Name != "log_price" {
+		t.Errorf("expected DROP TRIGGER log_price, got %v (%v)", stmt, err)
+	}
+
+	for _, input := range []string{
+		"CREATE TRIGGER t INSTEAD OF INSERT ON items BEGIN SELECT 1; END",
+		"CREATE TRIGGER t AFTER INSERT ON items BEGIN END",
+		"CREATE TRIGGER t AFTER INSERT ON items BEGIN DROP TABLE items; END",
+		"CREATE TRIGGER t AFTER INSERT ON items FOR EACH STATEMENT BEGIN SELECT 1; END",
+		"CREATE TRIGGER t AFTER INSERT ON items BEGIN SELECT 1",
+	} {
+		if _, err := New(lexer.New(input)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+}
+
 func TestParseExpressionPrecedence(t *testing.T) {
 	// Test that 1 + 2 * 3 is parsed as 1 + (2 * 3)
 	input := "SELECT * FROM t WHERE x = 1 + 2 * 3"
@@ -348,3 +692,598 @@ func TestParseComplexWhere(t *testing.T) {
 		t.Errorf("expected AND at top level, got %v", andExpr.Operator)
 	}
 }
+
+func TestParsePlaceholders(t *testing.T) {
+	input := "UPDATE users SET name = ? WHERE id = ? AND age > ?"
+
+	l := lexer.New(input)
+	p := New(l)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	upd, ok := stmt.(*UpdateStatement)
+	if !ok {
+		t.Fatalf("expected UpdateStatement, got %T", stmt)
+	}
+
+	ph, ok := upd.Assignments[0].Value.(*Placeholder)
+	if !ok {
+		t.Fatalf("expected Placeholder, got %T", upd.Assignments[0].Value)
+	}
+	if ph.Index != 0 {
+		t.Errorf("expected first placeholder index 0, got %d", ph.Index)
+	}
+
+	if n := CountPlaceholders(stmt); n != 3 {
+		t.Errorf("expected 3 placeholders, got %d", n)
+	}
+}
+
+func TestParseCast(t *testing.T) {
+	input := "SELECT name FROM users WHERE CAST(age AS TEXT) = '30'"
+
+	l := lexer.New(input)
+	p := New(l)
+	stmt, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	sel := stmt.(*SelectStatement)
+	binExpr, ok := sel.Where.(*BinaryExpression)
+	if !ok {
+		t.Fatalf("expected BinaryExpression in WHERE, got %T", sel.Where)
+	}
+
+	cast, ok := binExpr.Left.(*CastExpression)
+	if !ok {
+		t.Fatalf("expected CastExpression, got %T", binExpr.Left)
+	}
+	if cast.Type != TypeText {
+		t.Errorf("expected cast to TEXT, got %s", cast.Type)
+	}
+	if cast.String() != "CAST(age AS TEXT)" {
+		t.Errorf("unexpected String(): %s", cast.String())
+	}
+
+	// Missing AS is a parse error
+	if _, err := New(lexer.New("SELECT CAST(age TEXT) FROM users")).Parse(); err == nil {
+		t.Error("expected parse error for CAST without AS")
+	}
+}
+
+func TestParseFunctionCall(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		argCount int
+	}{
+		{"SELECT * FROM t WHERE coalesce(a, b, 'x') = 1", "COALESCE", 3},
+		{"SELECT * FROM t WHERE NULLIF(a, 0) = 1", "NULLIF", 2},
+		{"SELECT * FROM t WHERE random() = 1", "RANDOM", 0},
+	}
+
+	for _, tt := range tests {
+		stmt, err := New(lexer.New(tt.input)).Parse()
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.input, err)
+			continue
+		}
+		where := stmt.(*SelectStatement).Where.(*BinaryExpression)
+		call, ok := where.Left.(*FunctionCall)
+		if !ok {
+			t.Errorf("Parse(%q) expected FunctionCall, got %T", tt.input, where.Left)
+			continue
+		}
+		if call.Name != tt.name || len(call.Args) != tt.argCount {
+			t.Errorf("Parse(%q) got %s with %d args", tt.input, call.Name, len(call.Args))
+		}
+	}
+}
+
+func TestParseTimestampType(t *testing.T) {
+	stmt, err := New(lexer.New("CREATE TABLE events (id INTEGER, at TIMESTAMP, seen DATETIME)")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	create := stmt.(*CreateTableStatement)
+	if create.Columns[1].Type != TypeTimestamp || create.Columns[2].Type != TypeTimestamp {
+		t.Errorf("expected TIMESTAMP columns, got %v", create.Columns)
+	}
+
+	stmt, err = New(lexer.New("INSERT INTO events VALUES (1, CURRENT_TIMESTAMP, NOW())")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	ins := stmt.(*InsertStatement)
+	if call, ok := ins.Values[1].(*FunctionCall); !ok || call.Name != "CURRENT_TIMESTAMP" {
+		t.Errorf("expected CURRENT_TIMESTAMP function call, got %v", ins.Values[1])
+	}
+}
+
+func TestParseDecimalType(t *testing.T) {
+	stmt, err := New(lexer.New("CREATE TABLE orders (total DECIMAL(10, 2), rate NUMERIC(5), amount DECIMAL)")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	create := stmt.(*CreateTableStatement)
+
+	expected := []struct{ precision, scale int }{{10, 2}, {5, 0}, {0, 0}}
+	for i, want := range expected {
+		col := create.Columns[i]
+		if col.Type != TypeDecimal || col.Precision != want.precision || col.Scale != want.scale {
+			t.Errorf("column %d: expected DECIMAL(%d,%d), got %s", i, want.precision, want.scale, col)
+		}
+	}
+
+	stmt, err = New(lexer.New("SELECT * FROM orders WHERE CAST(total AS DECIMAL(6, 1)) > 1")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	cast := stmt.(*SelectStatement).Where.(*BinaryExpression).Left.(*CastExpression)
+	if cast.Type != TypeDecimal || cast.Precision != 6 || cast.Scale != 1 {
+		t.Errorf("expected CAST to DECIMAL(6,1), got %+v", cast)
+	}
+
+	for _, bad := range []string{
+		"CREATE TABLE t (x DECIMAL(0))",
+		"CREATE TABLE t (x DECIMAL(19, 2))",
+		"CREATE TABLE t (x DECIMAL(4, 5))",
+	} {
+		if _, err := New(lexer.New(bad)).Parse(); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestParseVarcharLength(t *testing.T) {
+	input := "CREATE TABLE users (name VARCHAR(50) NOT NULL, bio TEXT)"
+	stmt, err := New(lexer.New(input)).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	create := stmt.(*CreateTableStatement)
+	if col := create.Columns[0]; col.Type != TypeText || col.Length != 50 || !col.NotNull {
+		t.Errorf("expected VARCHAR(50) NOT NULL, got %+v", col)
+	}
+	if create.Columns[1].Length != 0 {
+		t.Errorf("expected TEXT without a length, got %+v", create.Columns[1])
+	}
+	if got := create.String(); got != input {
+		t.Errorf("expected %q, got %q", input, got)
+	}
+
+	// CAST accepts a length too
+	if _, err := New(lexer.New("SELECT CAST(1 AS VARCHAR(3))")).Parse(); err != nil {
+		t.Errorf("Parse error: %v", err)
+	}
+	if _, err := New(lexer.New("CREATE TABLE t (x VARCHAR(0))")).Parse(); err == nil {
+		t.Error("expected an error for VARCHAR(0)")
+	}
+}
+
+func TestParseCollate(t *testing.T) {
+	input := "CREATE TABLE users (email VARCHAR(80) COLLATE nocase NOT NULL, name TEXT COLLATE BINARY)"
+	stmt, err := New(lexer.New(input)).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	create := stmt.(*CreateTableStatement)
+	if col := create.Columns[0]; col.Collation != "NOCASE" || col.Length != 80 || !col.NotNull {
+		t.Errorf("expected VARCHAR(80) COLLATE NOCASE NOT NULL, got %+v", col)
+	}
+	if create.Columns[1].Collation != "" {
+		t.Errorf("expected BINARY to be the default collation, got %+v", create.Columns[1])
+	}
+	if got, want := create.String(), "CREATE TABLE users (email VARCHAR(80) COLLATE NOCASE NOT NULL, name TEXT)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE t (x TEXT COLLATE FRENCH)",
+		"CREATE TABLE t (x INTEGER COLLATE NOCASE)",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestParseJSONType(t *testing.T) {
+	stmt, err := New(lexer.New("CREATE TABLE docs (id INTEGER, body JSON)")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if col := stmt.(*CreateTableStatement).Columns[1]; col.Type != TypeJSON {
+		t.Errorf("expected JSON column, got %s", col)
+	}
+}
+
+func TestParseSelectAliases(t *testing.T) {
+	stmt, err := New(lexer.New("SELECT id, price * 2 AS doubled, UPPER(name) label FROM items")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if len(sel.Columns) != 3 {
+		t.Fatalf("expected 3 columns, got %d", len(sel.Columns))
+	}
+
+	if _, ok := sel.Columns[0].(*Identifier); !ok {
+		t.Errorf("expected plain identifier, got %T", sel.Columns[0])
+	}
+	for i, alias := range []string{"", "doubled", "label"} {
+		if alias == "" {
+			continue
+		}
+		a, ok := sel.Columns[i].(*AliasExpression)
+		if !ok || a.Alias != alias {
+			t.Errorf("column %d: expected alias %s, got %v", i, alias, sel.Columns[i])
+		}
+	}
+
+	if _, err := New(lexer.New("SELECT id AS FROM items")).Parse(); err == nil {
+		t.Error("expected error for AS without an alias")
+	}
+
+	// A keyword isn't taken for an alias
+	for _, sql := range []string{
+		"SELECT 1 IS NULL",
+		"SELECT 1 NOT IN (1)",
+		"SELECT name LIKE 'a%' FROM items",
+		"SELECT price BETWEEN 1 AND 2 FROM items",
+		"SELECT 1 AND",
+		"SELECT 1 OR 2 3",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", sql)
+		}
+	}
+	stmt, err = New(lexer.New("SELECT 1 AS is")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if a, ok := stmt.(*SelectStatement).Columns[0].(*AliasExpression); !ok || a.Alias != "is" {
+		t.Errorf("expected a keyword after AS to be an alias, got %v", stmt)
+	}
+}
+
+func TestParseSelectWithoutFrom(t *testing.T) {
+	stmt, err := New(lexer.New("SELECT 1 + 1, NOW()")).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if sel.From != "" || len(sel.Columns) != 2 {
+		t.Errorf("expected 2 columns and no FROM, got %v", sel)
+	}
+
+	// What follows a complete statement is an error, not dropped
+	for _, sql := range []string{"SELECT 1 2 3", "SELECT 1 + 1 FROM t garbage", "DELETE FROM t WHERE id = 1 2"} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected error for the tokens left over", sql)
+		}
+	}
+	if _, err := New(lexer.New("SELECT 1;")).Parse(); err != nil {
+		t.Errorf("expected a closing semicolon to be accepted, got %v", err)
+	}
+	if _, err := New(lexer.New("SELECT 1;;")).Parse(); err != nil {
+		t.Errorf("expected repeated semicolons to be accepted, got %v", err)
+	}
+
+	// A second statement is an error too, not silently skipped
+	for _, sql := range []string{"SELECT 1; SELECT 2;", "SELECT 1; SELECT bad; SELECT 2", "DELETE FROM t; garbage"} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected error for the statement after the first", sql)
+		}
+	}
+}
+
+func TestParseExplainFormat(t *testing.T) {
+	tests := []struct {
+		sql     string
+		format  ExplainFormat
+		analyze bool
+	}{
+		{"EXPLAIN (FORMAT JSON) SELECT * FROM users", ExplainJSON, false},
+		{"EXPLAIN (format tree) SELECT * FROM users", ExplainTree, false},
+		{"EXPLAIN (ANALYZE, FORMAT JSON) DELETE FROM users", ExplainJSON, true},
+		{"EXPLAIN (FORMAT TEXT) SELECT * FROM users", ExplainText, false},
+	}
+
+	for _, tt := range tests {
+		stmt, err := New(lexer.New(tt.sql)).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		explain := stmt.(*ExplainStatement)
+		if explain.Format != tt.format || explain.Analyze != tt.analyze {
+			t.Errorf("%s: got format %s analyze %v", tt.sql, explain.Format, explain.Analyze)
+		}
+	}
+
+	for _, sql := range []string{
+		"EXPLAIN (FORMAT XML) SELECT * FROM users",
+		"EXPLAIN (VERBOSE) SELECT * FROM users",
+		"EXPLAIN (FORMAT JSON SELECT * FROM users",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParseTransactionStatements(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected Statement
+	}{
+		{"BEGIN", &BeginStatement{}},
+		{"BEGIN TRANSACTION", &BeginStatement{}},
+		{"COMMIT", &CommitStatement{}},
+		{"commit transaction", &CommitStatement{}},
+		{"ROLLBACK", &RollbackStatement{}},
+		{"ROLLBACK TO SAVEPOINT a", &RollbackStatement{Savepoint: "a"}},
+		{"ROLLBACK TRANSACTION TO a", &RollbackStatement{Savepoint: "a"}},
+		{"SAVEPOINT a", &SavepointStatement{Name: "a"}},
+		{"RELEASE SAVEPOINT a", &ReleaseStatement{Name: "a"}},
+		{"RELEASE a", &ReleaseStatement{Name: "a"}},
+	}
+
+	for _, tt := range tests {
+		stmt, err := New(lexer.New(tt.sql)).Parse()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if stmt.String() != tt.expected.String() || fmt.Sprintf("%T", stmt) != fmt.Sprintf("%T", tt.expected) {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.expected, stmt)
+		}
+	}
+}
+
+func TestParseSelectForUpdate(t *testing.T) {
+	stmt, err := New(lexer.New("SELECT * FROM users WHERE id = 1 LIMIT 5 FOR UPDATE")).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	sel, ok := stmt.(*SelectStatement)
+	if !ok || !sel.ForUpdate {
+		t.Fatalf("expected SELECT with ForUpdate, got %#v", stmt)
+	}
+	if sel.Limit == nil || *sel.Limit != 5 {
+		t.Errorf("expected LIMIT 5 before FOR UPDATE, got %v", sel.Limit)
+	}
+
+	for _, sql := range []string{
+		"SELECT 1 FOR UPDATE",
+		"SELECT * FROM users FOR",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParseSelectGroupBy(t *testing.T) {
+	stmt, err := New(lexer.New("SELECT city, COUNT(*) FROM users WHERE age > 18 GROUP BY city, country ORDER BY city")).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if len(sel.GroupBy) != 2 || sel.GroupBy[0] != "city" || sel.GroupBy[1] != "country" {
+		t.Errorf("expected GROUP BY city, country, got %v", sel.GroupBy)
+	}
+	if len(sel.OrderBy) != 1 || sel.Where == nil {
+		t.Errorf("expected WHERE and ORDER BY around GROUP BY, got %#v", sel)
+	}
+	if call, ok := sel.Columns[1].(*FunctionCall); !ok || call.String() != "COUNT(*)" {
+		t.Errorf("expected COUNT(*), got %v", sel.Columns[1])
+	}
+
+	for _, sql := range []string{
+		"SELECT city FROM users GROUP city",
+		"SELECT city FROM users GROUP BY",
+		"SELECT city FROM users GROUP BY city,",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParseSelectJoin(t *testing.T) {
+	sql := "SELECT users.name, total FROM users JOIN orders ON users.id = orders.user_id " +
+		"INNER JOIN items ON orders.id = items.order_id AND items.qty > 1 WHERE total > 10"
+	stmt, err := New(lexer.New(sql)).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	sel := stmt.(*SelectStatement)
+	if sel.From != "users" || len(sel.Joins) != 2 {
+		t.Fatalf("expected users with 2 joins, got %s with %d", sel.From, len(sel.Joins))
+	}
+	if sel.Joins[0].Table != "orders" || sel.Joins[0].On.String() != "(users.id = orders.user_id)" {
+		t.Errorf("unexpected first join: %s", sel.Joins[0])
+	}
+	if sel.Joins[1].Table != "items" || sel.Joins[1].On.String() != "((orders.id = items.order_id) AND (items.qty > 1))" {
+		t.Errorf("unexpected second join: %s", sel.Joins[1])
+	}
+	if sel.Where == nil {
+		t.Error("expected WHERE after the joins")
+	}
+
+	for _, sql := range []string{
+		"SELECT * FROM users JOIN orders",
+		"SELECT * FROM users INNER orders ON users.id = orders.id",
+		"SELECT * FROM users JOIN ON users.id = 1",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParseVacuum(t *testing.T) {
+	stmt, err := New(lexer.New("vacuum")).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, ok := stmt.(*VacuumStatement); !ok {
+		t.Fatalf("expected *VacuumStatement, got %T", stmt)
+	}
+}
+
+func TestParsePragma(t *testing.T) {
+	stmt, err := New(lexer.New("PRAGMA Integrity_Check")).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pragma, ok := stmt.(*PragmaStatement)
+	if !ok {
+		t.Fatalf("expected *PragmaStatement, got %T", stmt)
+	}
+	if pragma.Name != "integrity_check" {
+		t.Errorf("expected name integrity_check, got %q", pragma.Name)
+	}
+
+	if _, err := New(lexer.New("PRAGMA")).Parse(); err == nil {
+		t.Error("expected error for PRAGMA without a name")
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	for _, sql := range []string{"SET Statement_Timeout = '2s'", "SET statement_timeout TO '2s'", "PRAGMA statement_timeout = '2s'"} {
+		stmt, err := New(lexer.New(sql)).Parse()
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", sql, err)
+		}
+		set, ok := stmt.(*SetStatement)
+		if !ok {
+			t.Fatalf("%s: expected *SetStatement, got %T", sql, stmt)
+		}
+		if set.String() != "SET statement_timeout = '2s'" {
+			t.Errorf("%s: unexpected statement %s", sql, set.String())
+		}
+	}
+
+	for _, sql := range []string{"SET", "SET statement_timeout", "SET statement_timeout =", "PRAGMA statement_timeout ="} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", sql)
+		}
+	}
+}
+
+func TestParseShowAndDescribe(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SHOW TABLES", "SHOW TABLES"},
+		{"show indexes", "SHOW INDEXES"},
+		{"SHOW INDEXES FROM users", "SHOW INDEXES FROM users"},
+		{"DESCRIBE users", "DESCRIBE users"},
+	}
+	for _, tt := range tests {
+		stmt, err := New(lexer.New(tt.sql)).Parse()
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", tt.sql, err)
+		}
+		if stmt.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.want, stmt.String())
+		}
+	}
+
+	for _, sql := range []string{"SHOW", "SHOW COLUMNS", "SHOW INDEXES FROM", "DESCRIBE"} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", sql)
+		}
+	}
+}
+
+func TestParseAttachAndDetach(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"ATTACH DATABASE 'archive.db' AS archive", "ATTACH DATABASE 'archive.db' AS archive"},
+		{"attach 'it''s.db' as old", "ATTACH DATABASE 'it''s.db' AS old"},
+		{"DETACH DATABASE archive", "DETACH DATABASE archive"},
+		{"DETACH old", "DETACH DATABASE old"},
+	}
+	for _, tt := range tests {
+		stmt, err := New(lexer.New(tt.sql)).Parse()
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", tt.sql, err)
+		}
+		if stmt.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.sql, tt.want, stmt.String())
+		}
+	}
+
+	for _, sql := range []string{"ATTACH archive", "ATTACH 'a.db'", "ATTACH 'a.db' AS", "DETACH"} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", sql)
+		}
+	}
+}
+
+func TestParseHints(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT /*+ FULL_SCAN */ * FROM users", "FULL_SCAN"},
+		{"SELECT /*+ index( idx_users_age ) */ name FROM users WHERE age > 30", "INDEX(idx_users_age)"},
+		{"SELECT /* a comment */ * FROM users", ""},
+	}
+	for _, tt := range tests {
+		stmt, err := New(lexer.New(tt.sql)).Parse()
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", tt.sql, err)
+		}
+		sel := stmt.(*SelectStatement)
+		got := ""
+		for _, hint := range sel.Hints {
+			got += hint.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected hints %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT /*+ BOGUS */ * FROM users",
+		"SELECT /*+ INDEX */ * FROM users",
+		"SELECT /*+ FULL_SCAN(users) */ * FROM users",
+		"SELECT /*+ INDEX(a, b) */ * FROM users",
+		"SELECT /*+ INDEX(a */ * FROM users",
+		"SELECT /*+ FULL_SCAN INDEX(a) */ * FROM users",
+		"SELECT * /*+ FULL_SCAN */ FROM users",
+		"SELECT * FROM users /*+ FULL_SCAN */ WHERE id = 1",
+	} {
+		if _, err := New(lexer.New(sql)).Parse(); err == nil {
+			t.Errorf("%s: expected a parse error", sql)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"SELECT 1; -- one; two\nSELECT 'a;''b' ;;\n  SELECT 2", []string{"SELECT 1", "SELECT 'a;''b'", "SELECT 2"}},
+		{"CREATE TRIGGER t AFTER INSERT ON a BEGIN INSERT INTO b VALUES (NEW.id); DELETE FROM c; END; SELECT 1",
+			[]string{"CREATE TRIGGER t AFTER INSERT ON a BEGIN INSERT INTO b VALUES (NEW.id); DELETE FROM c; END", "SELECT 1"}},
+		{"/* seed; data */ INSERT INTO a VALUES (1);\nSELECT /*+ FULL_SCAN */ * FROM a /* all; */;",
+			[]string{"INSERT INTO a VALUES (1)", "SELECT /*+ FULL_SCAN */ * FROM a"}},
+		{"SELECT 1; /* unterminated; comment", []string{"SELECT 1", "/* unterminated; comment"}},
+	}
+	for _, tt := range tests {
+		if got := SplitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.script, tt.want, got)
+		}
+	}
+}
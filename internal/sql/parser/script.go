@@ -0,0 +1,78 @@
+// Package parser - Splitting scripts into statements
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The parser reads one statement at a time, but SQL usually comes in
+// scripts: a migration, a dump, a file of seed data. Splitting a script
+// at its semicolons almost works, except where a semicolon doesn't end
+// a statement:
+//
+//   INSERT INTO notes VALUES ('a; b');            -- inside a string
+//   -- a comment; with a semicolon
+//   /* so can a block comment; like this */
+//   CREATE TRIGGER t AFTER INSERT ON a BEGIN
+//     INSERT INTO log VALUES (NEW.id);            -- inside a trigger body
+//   END;
+//
+// SplitStatements steps through the script keeping track of which of these
+// it is in. Comments are dropped, except an optimizer hint (/*+ ... */),
+// which belongs to its SELECT. A trigger's body is recognized by trying
+// the statement so far with UnfinishedTrigger at each semicolon.
+// sqlite3 and psql split what they read the same way, and so does every
+// database driver that takes more than one statement at once.
+
+package parser
+
+import "strings"
+
+// SplitStatements splits a script into its statements at the semicolons
+// outside string literals, comments and trigger bodies, leaving out the
+// comments and empty statements.
+func SplitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	flush := func() {
+		if sql := strings.TrimSpace(current.String()); sql != "" {
+			stmts = append(stmts, sql)
+		}
+		current.Reset()
+	}
+
+	inString := false
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case inString:
+			// A doubled quote inside a literal ends it and starts it
+			// again, which comes to the same thing
+			inString = c != '\''
+			current.WriteByte(c)
+		case c == '\'':
+			inString = true
+			current.WriteByte(c)
+		case c == '-' && i+1 < len(script) && script[i+1] == '-':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+		case strings.HasPrefix(script[i:], "/*") && !strings.HasPrefix(script[i:], "/*+"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				// Unterminated: left for the parser to report
+				current.WriteString(script[i:])
+				i = len(script)
+				break
+			}
+			i += 2 + end + 1
+			current.WriteByte(' ')
+		case c == ';' && UnfinishedTrigger(current.String()):
+			current.WriteByte(c)
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
@@ -0,0 +1,149 @@
+// Package parser - AST traversal helpers
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Many tasks need to look at every expression in a statement without caring
+// about the statement's shape: counting placeholders, collecting referenced
+// columns, checking whether an expression is constant. Rather than repeating
+// the same type switch everywhere, we walk the tree once here and hand each
+// expression to a callback.
+
+package parser
+
+// WalkExpression calls fn for expr and then for each of its sub-expressions,
+// depth first. If fn returns false, the children of that node are skipped.
+func WalkExpression(expr Expression, fn func(Expression) bool) {
+	if expr == nil {
+		return
+	}
+	if !fn(expr) {
+		return
+	}
+
+	switch e := expr.(type) {
+	case *BinaryExpression:
+		WalkExpression(e.Left, fn)
+		WalkExpression(e.Right, fn)
+	case *UnaryExpression:
+		WalkExpression(e.Operand, fn)
+	case *CastExpression:
+		WalkExpression(e.Expr, fn)
+	case *AliasExpression:
+		WalkExpression(e.Expr, fn)
+	case *FunctionCall:
+		for _, arg := range e.Args {
+			WalkExpression(arg, fn)
+		}
+	}
+}
+
+// ReplaceExpression returns expr with each sub-expression for which fn
+// returns a replacement replaced by it, depth first. Nodes are changed in
+// place; the children of a replaced node aren't visited.
+func ReplaceExpression(expr Expression, fn func(Expression) Expression) Expression {
+	if expr == nil {
+		return nil
+	}
+	if replacement := fn(expr); replacement != nil {
+		return replacement
+	}
+
+	switch e := expr.(type) {
+	case *BinaryExpression:
+		e.Left = ReplaceExpression(e.Left, fn)
+		e.Right = ReplaceExpression(e.Right, fn)
+	case *UnaryExpression:
+		e.Operand = ReplaceExpression(e.Operand, fn)
+	case *CastExpression:
+		e.Expr = ReplaceExpression(e.Expr, fn)
+	case *AliasExpression:
+		e.Expr = ReplaceExpression(e.Expr, fn)
+	case *FunctionCall:
+		for i, arg := range e.Args {
+			e.Args[i] = ReplaceExpression(arg, fn)
+		}
+	}
+	return expr
+}
+
+// ReplaceStatementExpressions calls ReplaceExpression on each of the
+// top-level expressions StatementExpressions returns, changing the
+// statement in place.
+func ReplaceStatementExpressions(stmt Statement, fn func(Expression) Expression) {
+	replace := func(exprs []Expression) {
+		for i, expr := range exprs {
+			exprs[i] = ReplaceExpression(expr, fn)
+		}
+	}
+
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		replace(s.Columns)
+		for i := range s.Joins {
+			s.Joins[i].On = ReplaceExpression(s.Joins[i].On, fn)
+		}
+		s.Where = ReplaceExpression(s.Where, fn)
+	case *InsertStatement:
+		replace(s.Values)
+	case *UpdateStatement:
+		for i := range s.Assignments {
+			s.Assignments[i].Value = ReplaceExpression(s.Assignments[i].Value, fn)
+		}
+		s.Where = ReplaceExpression(s.Where, fn)
+	case *DeleteStatement:
+		s.Where = ReplaceExpression(s.Where, fn)
+	case *ExplainStatement:
+		ReplaceStatementExpressions(s.Statement, fn)
+	case *SetStatement:
+		s.Value = ReplaceExpression(s.Value, fn)
+	}
+}
+
+// StatementExpressions returns the top-level expressions of a statement
+// (select list, WHERE clause, inserted values, assignments).
+func StatementExpressions(stmt Statement) []Expression {
+	var exprs []Expression
+
+	switch s := stmt.(type) {
+	case *SelectStatement:
+		exprs = append(exprs, s.Columns...)
+		if s.FromFunc != nil {
+			exprs = append(exprs, s.FromFunc)
+		}
+		for _, join := range s.Joins {
+			exprs = append(exprs, join.On)
+		}
+		exprs = append(exprs, s.Where)
+	case *InsertStatement:
+		exprs = append(exprs, s.Values...)
+	case *UpdateStatement:
+		for _, a := range s.Assignments {
+			exprs = append(exprs, a.Value)
+		}
+		exprs = append(exprs, s.Where)
+	case *DeleteStatement:
+		exprs = append(exprs, s.Where)
+	case *ExplainStatement:
+		exprs = append(exprs, StatementExpressions(s.Statement)...)
+	case *SetStatement:
+		exprs = append(exprs, s.Value)
+	}
+
+	return exprs
+}
+
+// CountPlaceholders returns the number of ? markers in a statement.
+// The executor uses it to check that the caller supplied one argument
+// per placeholder.
+func CountPlaceholders(stmt Statement) int {
+	count := 0
+	for _, expr := range StatementExpressions(stmt) {
+		WalkExpression(expr, func(e Expression) bool {
+			if _, ok := e.(*Placeholder); ok {
+				count++
+			}
+			return true
+		})
+	}
+	return count
+}
@@ -0,0 +1,310 @@
+// Package planner - Cost model
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Once ANALYZE has gathered statistics, the planner stops following fixed
+// rules ("an index always beats a scan") and instead estimates what each
+// way of reading the table would cost, picking the cheapest. The cost is
+// counted in page reads, the dominant expense of a query, plus a little
+// for each row examined:
+//
+//   - A table scan reads every data page once, in order, and checks every
+//     row: pages + rows * cpuRowCost.
+//
+//   - An index first descends its B-tree, one page per level. Through the
+//     primary key, rows in a range tend to sit together in the data pages,
+//     so about that fraction of the pages is read. Through a secondary
+//     index, each matching row can be on a different page, and each costs
+//     a random read (randomPageCost), which on a spinning disk is far
+//     slower than reading the next page of a scan.
+//
+// So an index wins when few rows match, and a scan wins when many do, or
+// when the table is small enough that reading all of it is cheap anyway.
+// How many rows match is estimated from the column statistics (see
+// table.ColumnStats), using the histogram where there is one. The
+// constants are PostgreSQL's defaults for seq_page_cost, random_page_cost
+// and cpu_tuple_cost.
+
+package planner
+
+import (
+	"math"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+const (
+	seqPageCost    = 1.0  // Reading the next page of a scan
+	randomPageCost = 4.0  // Reading a page somewhere else in the file
+	cpuRowCost     = 0.01 // Checking one row against the WHERE clause
+
+	// defaultRangeSelectivity is the fraction of rows assumed to match
+	// each bound of a range the statistics can't estimate
+	defaultRangeSelectivity = 0.3
+)
+
+// TableScanCost estimates the cost of reading every row of the table.
+func TableScanCost(stats *table.TableStats) float64 {
+	return float64(stats.PageCount)*seqPageCost + float64(stats.RowCount)*cpuRowCost
+}
+
+// IndexScanCost estimates the cost of finding rows through an index:
+// through the primary key if secondary is false, else a secondary index.
+func IndexScanCost(stats *table.TableStats, rows float64, secondary bool) float64 {
+	descend := float64(table.EstimateTreeHeight(int(stats.RowCount))) * randomPageCost
+
+	// Pages of rows to read, never more than the table has
+	var fetch float64
+	if secondary {
+		fetch = math.Min(math.Ceil(rows), float64(stats.PageCount)) * randomPageCost
+	} else if stats.RowCount > 0 {
+		fetch = math.Ceil(rows/float64(stats.RowCount)*float64(stats.PageCount)) * seqPageCost
+	}
+	return descend + fetch + rows*cpuRowCost
+}
+
+// JoinRows estimates how many rows a join of inputs of leftRows and
+// rightRows rows produces. For an equality between their columns, the
+// larger input's row count stands in for the number of distinct values,
+// each matched by the same number of rows: leftRows * rightRows / max,
+// the estimate System R used. For any other condition, a tenth of the
+// pairs are assumed to match.
+func JoinRows(leftRows, rightRows float64, equality bool) float64 {
+	if !equality {
+		return leftRows * rightRows * 0.1
+	}
+	if larger := math.Max(leftRows, rightRows); larger > 0 {
+		return leftRows * rightRows / larger
+	}
+	return 0
+}
+
+// JoinCost estimates the cost of joining inputs of leftRows and rightRows
+// rows, on top of reading them: a nested loop checks every pair, a hash or
+// merge join looks at each row once.
+func JoinCost(leftRows, rightRows float64, nestedLoop bool) float64 {
+	if nestedLoop {
+		return leftRows * rightRows * cpuRowCost
+	}
+	return (leftRows + rightRows) * cpuRowCost
+}
+
+// EstimateGroups estimates how many groups GROUP BY columns make: the
+// product of their numbers of distinct values, but no more than there are
+// rows. A column without statistics is assumed to repeat each value ten
+// times.
+func EstimateGroups(stats *table.TableStats, columns []string) float64 {
+	rows := float64(stats.RowCount)
+	groups := 1.0
+	for _, column := range columns {
+		if col, ok := stats.Columns[column]; ok {
+			distinct := float64(col.DistinctValues)
+			if col.NullCount > 0 {
+				distinct++ // NULLs make a group of their own
+			}
+			groups *= math.Max(distinct, 1)
+		} else {
+			groups *= math.Max(rows*0.1, 1)
+		}
+	}
+	return math.Min(groups, math.Max(rows, 1))
+}
+
+// AggregateCost estimates the cost of aggregating rows into groups, on top
+// of reading them. Each row is added to its group's totals; a hash
+// aggregate also keeps an entry for each group in a hash table, while a
+// streaming aggregate, reading rows already ordered by group, only ever
+// holds the group in progress.
+func AggregateCost(rows, groups float64, hashed bool) float64 {
+	cost := rows * cpuRowCost
+	if hashed {
+		cost += groups * cpuRowCost
+	}
+	return cost
+}
+
+// EstimateEqualityRows estimates how many rows have val in column.
+func EstimateEqualityRows(stats *table.TableStats, column string, val table.Value) float64 {
+	col, ok := stats.Columns[column]
+	if !ok {
+		return float64(stats.RowCount) * 0.1
+	}
+	if col.DistinctValues == 0 || outsideRange(col, val) {
+		return 0
+	}
+	nonNull := float64(stats.RowCount - col.NullCount)
+	rows := nonNull / float64(col.DistinctValues)
+
+	// A value that is the bound of several buckets fills the buckets
+	// between them: it's more common than 1/NDV says
+	if h := col.Histogram; len(h) > 1 {
+		matches := 0
+		for _, bound := range h {
+			if cmp, ok := compareValues(bound, val); ok && cmp == 0 {
+				matches++
+			}
+		}
+		if matches > 1 {
+			rows = math.Max(rows, nonNull*float64(matches-1)/float64(len(h)-1))
+		}
+	}
+	return rows
+}
+
+// EstimateRangeRows estimates how many rows have a value in column between
+// lower and upper (nil for no bound).
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// With a histogram, the fraction of rows below a bound is the number of
+// whole buckets below it, plus the part of its own bucket it covers: for
+// numbers, assuming the values are spread evenly within the bucket, and
+// otherwise half of it. The buckets follow the data, so this stays close
+// even when most values are bunched together.
+//
+// Without one, for numbers, the range's overlap with [min, max] is taken
+// as the fraction of rows in it: with ages from 0 to 100, "age > 75" is a
+// quarter of the rows. For other types, each bound is assumed to keep
+// defaultRangeSelectivity of them.
+func EstimateRangeRows(stats *table.TableStats, column string, lower, upper *table.Value) float64 {
+	col, ok := stats.Columns[column]
+	if !ok {
+		return float64(stats.RowCount) * boundSelectivity(lower, upper)
+	}
+	nonNull := float64(stats.RowCount - col.NullCount)
+
+	if len(col.Histogram) > 1 {
+		lo, hi := 0.0, 1.0
+		okLo, okHi := true, true
+		if lower != nil {
+			lo, okLo = histogramFraction(col.Histogram, *lower)
+		}
+		if upper != nil {
+			hi, okHi = histogramFraction(col.Histogram, *upper)
+		}
+		if okLo && okHi {
+			return nonNull * math.Max(0, hi-lo)
+		}
+	}
+
+	minVal, minOK := numeric(col.Min)
+	maxVal, maxOK := numeric(col.Max)
+	if !minOK || !maxOK {
+		return nonNull * boundSelectivity(lower, upper)
+	}
+	lo, hi := minVal, maxVal
+	if lower != nil {
+		v, ok := numeric(*lower)
+		if !ok {
+			return nonNull * boundSelectivity(lower, upper)
+		}
+		lo = math.Max(lo, v)
+	}
+	if upper != nil {
+		v, ok := numeric(*upper)
+		if !ok {
+			return nonNull * boundSelectivity(lower, upper)
+		}
+		hi = math.Min(hi, v)
+	}
+
+	switch {
+	case hi < lo:
+		return 0
+	case maxVal == minVal:
+		return nonNull
+	default:
+		return nonNull * (hi - lo) / (maxVal - minVal)
+	}
+}
+
+// histogramFraction estimates the fraction of values below v from the
+// bounds of an equi-depth histogram, or returns false if v can't be
+// compared with them.
+func histogramFraction(bounds []table.Value, v table.Value) (float64, bool) {
+	buckets := float64(len(bounds) - 1)
+	if cmp, ok := compareValues(v, bounds[0]); !ok {
+		return 0, false
+	} else if cmp <= 0 {
+		return 0, true
+	}
+
+	for i := 1; i < len(bounds); i++ {
+		if cmp, _ := compareValues(v, bounds[i]); cmp > 0 {
+			continue
+		}
+		// v is in bucket i-1, between bounds[i-1] and bounds[i]; a value
+		// that ends several buckets belongs to the first of them
+		within := 0.5
+		lo, okLo := numeric(bounds[i-1])
+		hi, okHi := numeric(bounds[i])
+		x, okX := numeric(v)
+		if okLo && okHi && okX && hi > lo {
+			within = (x - lo) / (hi - lo)
+		}
+		return (float64(i-1) + within) / buckets, true
+	}
+	return 1, true
+}
+
+// compareValues compares two values, treating all numbers alike, or
+// returns false if they aren't comparable.
+func compareValues(a, b table.Value) (int, bool) {
+	x, okX := numeric(a)
+	y, okY := numeric(b)
+	switch {
+	case okX && okY:
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case a.Type == b.Type && !a.IsNull && !b.IsNull:
+		return a.Compare(b), true
+	default:
+		return 0, false
+	}
+}
+
+// boundSelectivity is the fraction of rows assumed to lie in a range the
+// statistics can't estimate.
+func boundSelectivity(lower, upper *table.Value) float64 {
+	sel := 1.0
+	if lower != nil {
+		sel *= defaultRangeSelectivity
+	}
+	if upper != nil {
+		sel *= defaultRangeSelectivity
+	}
+	return sel
+}
+
+// outsideRange reports whether val is known to be below the column's
+// minimum or above its maximum.
+func outsideRange(col table.ColumnStats, val table.Value) bool {
+	v, ok := numeric(val)
+	minVal, minOK := numeric(col.Min)
+	maxVal, maxOK := numeric(col.Max)
+	return ok && minOK && maxOK && (v < minVal || v > maxVal)
+}
+
+// numeric returns a number's value as a float64, or false if val isn't a
+// non-NULL number.
+func numeric(val table.Value) (float64, bool) {
+	if val.IsNull {
+		return 0, false
+	}
+	switch val.Type {
+	case parser.TypeInteger, parser.TypeTimestamp:
+		return float64(val.Integer), true
+	case parser.TypeReal:
+		return val.Real, true
+	case parser.TypeDecimal:
+		return float64(val.Integer) / math.Pow10(int(val.Scale)), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,150 @@
+package planner
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/table"
+)
+
+// analyzedStats returns statistics for 10000 users over 100 pages, with
+// ages spread evenly from 0 to 99 and 100 NULL names.
+func analyzedStats() *table.TableStats {
+	return &table.TableStats{
+		RowCount:     10000,
+		PageCount:    100,
+		LastAnalyzed: time.Now(),
+		Columns: map[string]table.ColumnStats{
+			"id": {DistinctValues: 10000,
+				Min: table.Value{Type: parser.TypeInteger, Integer: 1},
+				Max: table.Value{Type: parser.TypeInteger, Integer: 10000}},
+			"age": {DistinctValues: 100,
+				Min: table.Value{Type: parser.TypeInteger, Integer: 0},
+				Max: table.Value{Type: parser.TypeInteger, Integer: 100}},
+			"name": {DistinctValues: 9900, NullCount: 100,
+				Min: table.Value{Type: parser.TypeText, Text: "Aaron"},
+				Max: table.Value{Type: parser.TypeText, Text: "Zoe"}},
+		},
+	}
+}
+
+func TestEstimateRows(t *testing.T) {
+	stats := analyzedStats()
+	intVal := func(n int64) *table.Value { return &table.Value{Type: parser.TypeInteger, Integer: n} }
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"age = 30", EstimateEqualityRows(stats, "age", *intVal(30)), 100},
+		{"age = 200", EstimateEqualityRows(stats, "age", *intVal(200)), 0},
+		{"name = 'Bob'", EstimateEqualityRows(stats, "name", table.Value{Type: parser.TypeText, Text: "Bob"}), 1},
+		{"age > 75", EstimateRangeRows(stats, "age", intVal(75), nil), 2500},
+		{"age > 20 AND age < 30", EstimateRangeRows(stats, "age", intVal(20), intVal(30)), 1000},
+		{"age < -1", EstimateRangeRows(stats, "age", nil, intVal(-1)), 0},
+		{"name > 'M'", EstimateRangeRows(stats, "name", &table.Value{Type: parser.TypeText, Text: "M"}, nil), 9900 * defaultRangeSelectivity},
+		{"unknown = 1", EstimateEqualityRows(stats, "unknown", *intVal(1)), 1000},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 0.001 {
+			t.Errorf("%s: expected %.2f rows, got %.2f", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestEstimateGroups(t *testing.T) {
+	stats := analyzedStats()
+	tests := []struct {
+		columns []string
+		want    float64
+	}{
+		{nil, 1},
+		{[]string{"age"}, 100},
+		{[]string{"name"}, 9901}, // the NULLs are a group too
+		{[]string{"age", "id"}, 10000},
+		{[]string{"unknown"}, 1000},
+	}
+	for _, tt := range tests {
+		if got := EstimateGroups(stats, tt.columns); got != tt.want {
+			t.Errorf("GROUP BY %v: expected %.0f groups, got %.0f", tt.columns, tt.want, got)
+		}
+	}
+}
+
+func TestEstimateRowsWithHistogram(t *testing.T) {
+	// 9000 users aged 0, and one each aged 1 to 1000: min and max alone
+	// would spread them evenly from 0 to 1000
+	intVal := func(n int64) *table.Value { return &table.Value{Type: parser.TypeInteger, Integer: n} }
+	var bounds []table.Value
+	for _, n := range []int64{0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1000} {
+		bounds = append(bounds, *intVal(n))
+	}
+	stats := &table.TableStats{
+		RowCount:     10000,
+		PageCount:    100,
+		LastAnalyzed: time.Now(),
+		Columns: map[string]table.ColumnStats{
+			"age": {DistinctValues: 1001, Min: *intVal(0), Max: *intVal(1000), Histogram: bounds},
+		},
+	}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		// 0 ends eight buckets
+		{"age = 0", EstimateEqualityRows(stats, "age", *intVal(0)), 8000},
+		{"age = 500", EstimateEqualityRows(stats, "age", *intVal(500)), 10000.0 / 1001},
+		{"age < 1", EstimateRangeRows(stats, "age", nil, intVal(1)), 9000},
+		{"age > 500", EstimateRangeRows(stats, "age", intVal(500), nil), 500.5},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1 {
+			t.Errorf("%s: expected %.2f rows, got %.2f", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestPlanSelectWithStats_ChoosesByCost(t *testing.T) {
+	planner := New()
+	schema := testSchema()
+	indexes := []IndexInfo{{Name: "idx_users_age", Column: "age"}, {Name: "idx_users_name", Column: "name"}}
+
+	tests := []struct {
+		sql    string
+		method AccessMethod
+		index  string
+	}{
+		// A few rows: an index beats reading 100 pages
+		{"SELECT * FROM users WHERE name = 'Bob'", IndexLookup, "idx_users_name"},
+		{"SELECT * FROM users WHERE id > 9990", IndexRangeScan, ""},
+		// A percent of the table, scattered: the scan is cheaper
+		{"SELECT * FROM users WHERE age = 30", FullTableScan, ""},
+		// Most of the table: the scan is cheaper even for the primary key
+		{"SELECT * FROM users WHERE id > 100", FullTableScan, ""},
+		// The cheaper of two usable indexes wins, not the first
+		{"SELECT * FROM users WHERE age = 30 AND name = 'Bob'", IndexLookup, "idx_users_name"},
+		// A unique key lookup is always taken
+		{"SELECT * FROM users WHERE id = 5 AND name = 'Bob'", IndexLookup, ""},
+	}
+	for _, tt := range tests {
+		stmt := &parser.SelectStatement{From: "users", Where: parseWhere(t, tt.sql)}
+		plan := planner.PlanSelectWithStats(stmt, schema, analyzedStats(), nil, indexes...)
+		if plan.AccessMethod != tt.method || plan.IndexName != tt.index {
+			t.Errorf("%s: expected %v using %q, got %v using %q (cost %.2f)",
+				tt.sql, tt.method, tt.index, plan.AccessMethod, plan.IndexName, plan.EstimatedCost)
+		}
+	}
+
+	// Without ANALYZE the rules still apply
+	stats := analyzedStats()
+	stats.LastAnalyzed = time.Time{}
+	stmt := &parser.SelectStatement{From: "users", Where: parseWhere(t, "SELECT * FROM users WHERE age = 30")}
+	if plan := planner.PlanSelectWithStats(stmt, schema, stats, nil, indexes...); plan.AccessMethod != IndexLookup {
+		t.Errorf("expected the rules to use the index without statistics, got %v", plan.AccessMethod)
+	}
+}
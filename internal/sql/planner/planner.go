@@ -20,6 +20,9 @@ package planner
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
 	"github.com/cabewaldrop/claude-db/internal/table"
@@ -35,6 +38,9 @@ const (
 	IndexLookup
 	// IndexRangeScan uses an index for range queries (e.g., pk > 5).
 	IndexRangeScan
+	// OrderedIndexScan reads every row in the order of an index, for an
+	// ORDER BY that then needs no sort.
+	OrderedIndexScan
 )
 
 func (m AccessMethod) String() string {
@@ -45,6 +51,8 @@ func (m AccessMethod) String() string {
 		return "INDEX_LOOKUP"
 	case IndexRangeScan:
 		return "INDEX_RANGE_SCAN"
+	case OrderedIndexScan:
+		return "ORDERED_INDEX_SCAN"
 	default:
 		return "UNKNOWN"
 	}
@@ -56,27 +64,37 @@ type Predicate struct {
 	Operator parser.BinaryOp
 	Value    interface{} // The literal value being compared to
 	IsOnPK   bool        // True if this predicate is on the primary key
+	Index    string      // Secondary index on the column, if any
+}
+
+// IndexInfo describes a single-column secondary index the planner may use.
+type IndexInfo struct {
+	Name   string
+	Column string
+	Unique bool
 }
 
 // QueryPlan represents the execution plan for a query.
 type QueryPlan struct {
 	AccessMethod   AccessMethod
 	Predicates     []Predicate
-	IndexColumn    string      // Column to use for index access (if applicable)
-	IndexLookupKey interface{} // Key value for IndexLookup
-	RangeLower     interface{} // Lower bound for IndexRangeScan (nil = unbounded)
-	RangeUpper     interface{} // Upper bound for IndexRangeScan (nil = unbounded)
-	LowerInclusive bool        // True if lower bound is inclusive (>=)
-	UpperInclusive bool        // True if upper bound is inclusive (<=)
-	EstimatedCost  float64     // Relative cost estimate (lower is better)
-	EstimatedRows  float64     // Estimated number of rows returned
+	IndexColumn    string       // Column to use for index access (if applicable)
+	IndexName      string       // Secondary index to use ("" for the primary key)
+	IndexLookupKey interface{}  // Key value for IndexLookup
+	RangeLower     interface{}  // Lower bound for IndexRangeScan (nil = unbounded)
+	RangeUpper     interface{}  // Upper bound for IndexRangeScan (nil = unbounded)
+	LowerInclusive bool         // True if lower bound is inclusive (>=)
+	UpperInclusive bool         // True if upper bound is inclusive (<=)
+	EstimatedCost  float64      // Relative cost estimate (lower is better)
+	EstimatedRows  float64      // Estimated number of rows returned
+	Hint           *parser.Hint // Optimizer hint the plan was made under, if any
 }
 
 // String returns a human-readable representation of the query plan.
 func (p *QueryPlan) String() string {
 	switch p.AccessMethod {
 	case IndexLookup:
-		return fmt.Sprintf("INDEX_LOOKUP on %s = %v (cost: %.2f)", p.IndexColumn, p.IndexLookupKey, p.EstimatedCost)
+		return fmt.Sprintf("INDEX_LOOKUP on %s = %v%s (cost: %.2f)", p.IndexColumn, p.IndexLookupKey, p.usingIndex(), p.EstimatedCost)
 	case IndexRangeScan:
 		lower := "-inf"
 		upper := "+inf"
@@ -94,12 +112,22 @@ func (p *QueryPlan) String() string {
 			}
 			upper = fmt.Sprintf("%s %v", op, p.RangeUpper)
 		}
-		return fmt.Sprintf("INDEX_RANGE_SCAN on %s (%s, %s) (cost: %.2f)", p.IndexColumn, lower, upper, p.EstimatedCost)
+		return fmt.Sprintf("INDEX_RANGE_SCAN on %s (%s, %s)%s (cost: %.2f)", p.IndexColumn, lower, upper, p.usingIndex(), p.EstimatedCost)
+	case OrderedIndexScan:
+		return fmt.Sprintf("ORDERED_INDEX_SCAN%s (cost: %.2f)", p.usingIndex(), p.EstimatedCost)
 	default:
 		return fmt.Sprintf("FULL_TABLE_SCAN (cost: %.2f)", p.EstimatedCost)
 	}
 }
 
+// usingIndex names the secondary index the plan uses, if any.
+func (p *QueryPlan) usingIndex() string {
+	if p.IndexName == "" {
+		return ""
+	}
+	return " using " + p.IndexName
+}
+
 // Planner analyzes queries and generates execution plans.
 type Planner struct{}
 
@@ -108,37 +136,74 @@ func New() *Planner {
 	return &Planner{}
 }
 
-// PlanSelect analyzes a SELECT statement and returns a query plan.
-func (p *Planner) PlanSelect(stmt *parser.SelectStatement, schema *table.Schema) *QueryPlan {
+// PlanSelect analyzes a SELECT statement and returns a query plan. The
+// table's secondary indexes, if given, are considered as access paths
+// alongside the primary key.
+func (p *Planner) PlanSelect(stmt *parser.SelectStatement, schema *table.Schema, indexes ...IndexInfo) *QueryPlan {
+	var hint *parser.Hint
+	if len(stmt.Hints) > 0 {
+		hint = &stmt.Hints[0]
+	}
+	return p.planWhere(stmt.Where, schema, hint, indexes)
+}
+
+// PlanUpdate plans how an UPDATE finds the rows it changes.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// UPDATE and DELETE have to find their rows exactly like a SELECT does, so
+// they get the same access method and predicate analysis. The difference
+// is what happens afterwards: every index on the table must also be
+// updated, which makes writes to heavily indexed tables more expensive.
+func (p *Planner) PlanUpdate(stmt *parser.UpdateStatement, schema *table.Schema, indexes ...IndexInfo) *QueryPlan {
+	return p.PlanWhere(stmt.Where, schema, indexes...)
+}
+
+// PlanDelete plans how a DELETE finds the rows it removes.
+func (p *Planner) PlanDelete(stmt *parser.DeleteStatement, schema *table.Schema, indexes ...IndexInfo) *QueryPlan {
+	return p.PlanWhere(stmt.Where, schema, indexes...)
+}
+
+// PlanWhere chooses an access method for the rows matching a WHERE
+// clause (nil means every row).
+func (p *Planner) PlanWhere(where parser.Expression, schema *table.Schema, indexes ...IndexInfo) *QueryPlan {
+	return p.planWhere(where, schema, nil, indexes)
+}
+
+// planWhere is PlanWhere following an optimizer hint, unless hint is nil.
+func (p *Planner) planWhere(where parser.Expression, schema *table.Schema, hint *parser.Hint, indexes []IndexInfo) *QueryPlan {
 	plan := &QueryPlan{
 		AccessMethod:  FullTableScan,
 		Predicates:    []Predicate{},
 		EstimatedCost: 100.0, // Base cost for full table scan
+		Hint:          hint,
 	}
 
-	if stmt.Where == nil {
+	if where == nil {
 		return plan
 	}
 
 	// Extract predicates from WHERE clause
-	plan.Predicates = p.extractPredicates(stmt.Where, schema)
+	plan.Predicates = p.extractPredicates(where, schema)
 
 	// Check if we can use an index
-	pkName := ""
-	if schema.PrimaryKey >= 0 {
-		pkName = schema.Columns[schema.PrimaryKey].Name
-	}
+	pk := schema.PrimaryKeyNames()
 
-	// Look for predicates on the primary key
+	// Look for predicates on the primary key and indexed columns
 	for i := range plan.Predicates {
 		pred := &plan.Predicates[i]
-		if pred.Column == pkName {
-			pred.IsOnPK = true
+		pred.IsOnPK = onKeyPrefix(plan.Predicates, pk, pred.Column)
+		for _, idx := range indexes {
+			if pred.Column == idx.Column {
+				pred.Index = idx.Name
+				break
+			}
 		}
 	}
 
-	// Determine best access method based on PK predicates
-	p.selectAccessMethod(plan, pkName)
+	// Determine best access method based on PK predicates, falling back
+	// to the secondary indexes
+	p.selectAccessMethod(plan, pk, indexes)
 
 	return plan
 }
@@ -274,55 +339,217 @@ func (p *Planner) extractLiteral(expr parser.Expression) interface{} {
 //
 // 3. FULL_TABLE_SCAN: Required when no useful index exists
 //    Cost: O(n) - must examine every row
-func (p *Planner) selectAccessMethod(plan *QueryPlan, pkName string) {
-	if pkName == "" {
-		// No primary key, can only do full scan
-		return
+//
+// Without statistics the first access path the rules prefer is taken
+// (see accessPaths); PlanSelectWithStats compares their costs instead.
+func (p *Planner) selectAccessMethod(plan *QueryPlan, pk []string, indexes []IndexInfo) {
+	if paths, _ := plan.hinted(p.accessPaths(plan, pk, indexes)); len(paths) > 0 {
+		paths[0].apply(plan)
 	}
+}
 
-	var eqPredicate *Predicate
-	var rangeLower *Predicate
-	var rangeUpper *Predicate
+// hinted returns the access paths the plan's hint allows of paths, and
+// whether the hint chose them, to be taken whatever they cost. FULL_SCAN
+// allows none; INDEX allows the paths through its index, unless there
+// are none, when the hint can't be followed and all of paths are allowed.
+func (plan *QueryPlan) hinted(paths []accessPath) ([]accessPath, bool) {
+	switch {
+	case plan.Hint == nil:
+		return paths, false
+	case plan.Hint.Name == parser.HintFullScan:
+		return nil, true
+	}
+	var chosen []accessPath
+	for _, path := range paths {
+		if path.index.Name == plan.Hint.Args[0] {
+			chosen = append(chosen, path)
+		}
+	}
+	if len(chosen) == 0 {
+		return paths, false
+	}
+	return chosen, true
+}
 
-	for i := range plan.Predicates {
-		pred := &plan.Predicates[i]
-		if !pred.IsOnPK {
-			continue
+// accessPath is one way of reading rows through an index: a lookup if eq
+// (or keys) is set, else a scan of the range between lower and upper.
+type accessPath struct {
+	index IndexInfo // Name is "" for the primary key
+	eq    *Predicate
+	lower *Predicate
+	upper *Predicate
+	keys  []*Predicate // The equality on each column of a composite primary key
+}
+
+// accessPaths returns the ways the plan's predicates allow rows to be read
+// through the primary key or a secondary index, in the order the rules
+// prefer them.
+//
+// The primary key is tried first, then each secondary index in turn, and
+// every equality comes before any range: an equality on a secondary index
+// usually matches a handful of rows, where a range can match most of the
+// table. A secondary index also costs a little more than the primary key,
+// since each entry found still has to be followed to its row. A composite
+// primary key can only be looked up whole, with an equality on each of
+// its columns.
+func (p *Planner) accessPaths(plan *QueryPlan, pk []string, indexes []IndexInfo) []accessPath {
+	var candidates []IndexInfo
+	var lookups, ranges []accessPath
+	switch {
+	case len(pk) == 1:
+		candidates = append(candidates, IndexInfo{Column: pk[0], Unique: true})
+	case len(pk) > 1:
+		if path, ok := compositeKeyPath(plan, pk); ok {
+			lookups = append(lookups, path)
+		}
+	}
+	candidates = append(candidates, indexes...)
+
+	for _, idx := range candidates {
+		path := accessPath{index: idx}
+		for i := range plan.Predicates {
+			pred := &plan.Predicates[i]
+			if pred.Column != idx.Column {
+				continue
+			}
+			switch pred.Operator {
+			case parser.OpEquals:
+				path.eq = pred
+			case parser.OpGreaterThan, parser.OpGreaterOrEqual:
+				path.lower = pred
+			case parser.OpLessThan, parser.OpLessOrEqual:
+				path.upper = pred
+			}
+		}
+		switch {
+		case path.eq != nil:
+			lookups = append(lookups, path)
+		case path.lower != nil || path.upper != nil:
+			ranges = append(ranges, path)
+		}
+	}
+	return append(lookups, ranges...)
+}
+
+// compositeKeyPath returns the lookup of a composite primary key, if the
+// plan's predicates have an equality on each of its columns.
+func compositeKeyPath(plan *QueryPlan, pk []string) (accessPath, bool) {
+	path := accessPath{index: IndexInfo{Column: "(" + strings.Join(pk, ", ") + ")", Unique: true}}
+	for _, column := range pk {
+		var eq *Predicate
+		for i := range plan.Predicates {
+			if pred := &plan.Predicates[i]; pred.Column == column && pred.Operator == parser.OpEquals {
+				eq = pred
+			}
 		}
+		if eq == nil {
+			return accessPath{}, false
+		}
+		path.keys = append(path.keys, eq)
+	}
+	return path, true
+}
 
-		switch pred.Operator {
-		case parser.OpEquals:
-			eqPredicate = pred
-		case parser.OpGreaterThan, parser.OpGreaterOrEqual:
-			rangeLower = pred
-		case parser.OpLessThan, parser.OpLessOrEqual:
-			rangeUpper = pred
+// onKeyPrefix reports whether column is one of the primary key's columns
+// whose predicates the key's order helps with: the first, or a later one
+// when every column before it has an equality. The rows of a key (a, b)
+// are in order of b only among the rows with the same a.
+func onKeyPrefix(predicates []Predicate, pk []string, column string) bool {
+	position := slices.Index(pk, column)
+	if position < 0 {
+		return false
+	}
+	for _, earlier := range pk[:position] {
+		if !slices.ContainsFunc(predicates, func(pred Predicate) bool {
+			return pred.Column == earlier && pred.Operator == parser.OpEquals
+		}) {
+			return false
 		}
 	}
+	return true
+}
 
-	// Prefer equality lookup over range scan
-	if eqPredicate != nil {
+// apply makes plan read its rows through the path.
+func (a accessPath) apply(plan *QueryPlan) {
+	plan.IndexColumn = a.index.Column
+	plan.IndexName = a.index.Name
+	if a.keys != nil {
+		values := make([]string, len(a.keys))
+		for i, pred := range a.keys {
+			values[i] = fmt.Sprint(pred.Value)
+		}
 		plan.AccessMethod = IndexLookup
-		plan.IndexColumn = pkName
-		plan.IndexLookupKey = eqPredicate.Value
+		plan.IndexLookupKey = "(" + strings.Join(values, ", ") + ")"
+		plan.EstimatedCost = 1.0
+		return
+	}
+	if a.eq != nil {
+		plan.AccessMethod = IndexLookup
+		plan.IndexLookupKey = a.eq.Value
 		plan.EstimatedCost = 1.0 // Very cheap - single lookup
+		if a.index.Name != "" {
+			plan.EstimatedCost = 2.0
+		}
 		return
 	}
 
-	// Check for range scan
-	if rangeLower != nil || rangeUpper != nil {
-		plan.AccessMethod = IndexRangeScan
-		plan.IndexColumn = pkName
-		plan.EstimatedCost = 10.0 // Cheaper than full scan, more than lookup
+	plan.AccessMethod = IndexRangeScan
+	plan.EstimatedCost = 10.0 // Cheaper than full scan, more than lookup
+	if a.index.Name != "" {
+		plan.EstimatedCost = 15.0
+	}
+	if a.lower != nil {
+		plan.RangeLower = a.lower.Value
+		plan.LowerInclusive = a.lower.Operator == parser.OpGreaterOrEqual
+	}
+	if a.upper != nil {
+		plan.RangeUpper = a.upper.Value
+		plan.UpperInclusive = a.upper.Operator == parser.OpLessOrEqual
+	}
+}
+
+// uniqueLookup reports whether the path looks up a single key of a unique
+// index, and so finds at most one row.
+func (a accessPath) uniqueLookup() bool {
+	return (a.eq != nil || a.keys != nil) && a.index.Unique
+}
 
-		if rangeLower != nil {
-			plan.RangeLower = rangeLower.Value
-			plan.LowerInclusive = rangeLower.Operator == parser.OpGreaterOrEqual
-		}
-		if rangeUpper != nil {
-			plan.RangeUpper = rangeUpper.Value
-			plan.UpperInclusive = rangeUpper.Operator == parser.OpLessOrEqual
+// rows estimates how many rows the path reads.
+func (a accessPath) rows(stats *table.TableStats) float64 {
+	if a.keys != nil {
+		return math.Min(1, float64(stats.RowCount))
+	}
+	if a.eq != nil {
+		if a.index.Unique {
+			return math.Min(1, EstimateEqualityRows(stats, a.index.Column, literalValue(a.eq.Value)))
 		}
+		return EstimateEqualityRows(stats, a.index.Column, literalValue(a.eq.Value))
+	}
+	var lower, upper *table.Value
+	if a.lower != nil {
+		v := literalValue(a.lower.Value)
+		lower = &v
+	}
+	if a.upper != nil {
+		v := literalValue(a.upper.Value)
+		upper = &v
+	}
+	return EstimateRangeRows(stats, a.index.Column, lower, upper)
+}
+
+// literalValue converts a predicate's literal back to a table.Value.
+func literalValue(v interface{}) table.Value {
+	switch v := v.(type) {
+	case int64:
+		return table.Value{Type: parser.TypeInteger, Integer: v}
+	case float64:
+		return table.Value{Type: parser.TypeReal, Real: v}
+	case string:
+		return table.Value{Type: parser.TypeText, Text: v}
+	case bool:
+		return table.Value{Type: parser.TypeBoolean, Boolean: v}
+	default:
+		return table.Value{IsNull: true}
 	}
 }
 
@@ -347,14 +574,11 @@ func (p *Planner) AnalyzeWhere(where parser.Expression, schema *table.Schema) *W
 	analysis.Predicates = p.extractPredicates(where, schema)
 
 	// Check for primary key predicates
-	pkName := ""
-	if schema.PrimaryKey >= 0 {
-		pkName = schema.Columns[schema.PrimaryKey].Name
-	}
+	pk := schema.PrimaryKeyNames()
 
 	for i := range analysis.Predicates {
 		pred := &analysis.Predicates[i]
-		if pred.Column == pkName {
+		if onKeyPrefix(analysis.Predicates, pk, pred.Column) {
 			pred.IsOnPK = true
 			analysis.HasIndexablePred = true
 		}
@@ -408,9 +632,10 @@ func (p *Planner) containsOR(expr parser.Expression) bool {
 // Statistics-based planning is the foundation of cost-based query optimization.
 // By knowing how many rows a table has and how selective predicates are,
 // the planner can estimate the cost of different access paths and choose
-// the cheapest one.
-func (p *Planner) PlanSelectWithStats(stmt *parser.SelectStatement, schema *table.Schema, stats *table.TableStats, indexStats *table.IndexStats) *QueryPlan {
-	plan := p.PlanSelect(stmt, schema)
+// the cheapest one. Once ANALYZE has run, that choice replaces the rules
+// PlanSelect follows (see cost.go).
+func (p *Planner) PlanSelectWithStats(stmt *parser.SelectStatement, schema *table.Schema, stats *table.TableStats, indexStats *table.IndexStats, indexes ...IndexInfo) *QueryPlan {
+	plan := p.PlanSelect(stmt, schema, indexes...)
 
 	// Apply selectivity estimation if we have stats
 	if stats != nil && stats.RowCount > 0 {
@@ -418,7 +643,7 @@ func (p *Planner) PlanSelectWithStats(stmt *parser.SelectStatement, schema *tabl
 
 		// Estimate rows based on predicates
 		for _, pred := range plan.Predicates {
-			sel := p.EstimateSelectivity(pred, indexStats)
+			sel := p.predicateSelectivity(pred, stats, indexStats)
 			rowCount *= sel
 		}
 
@@ -428,11 +653,20 @@ func (p *Planner) PlanSelectWithStats(stmt *parser.SelectStatement, schema *tabl
 		}
 		plan.EstimatedRows = rowCount
 
+		if stats.Analyzed() {
+			p.chooseByCost(plan, schema, stats, indexes)
+			return plan
+		}
+
 		// Update cost estimate based on row count
 		switch plan.AccessMethod {
 		case IndexLookup:
-			// Index lookup is very cheap - O(log n) to find + 1 row
+			// Index lookup is very cheap - O(log n) to find + 1 row, or
+			// every row with the key for a secondary index
 			plan.EstimatedCost = 1.0
+			if plan.IndexName != "" {
+				plan.EstimatedCost = 1.0 + plan.EstimatedRows*0.1
+			}
 		case IndexRangeScan:
 			// Range scan: O(log n) to find start + read matching rows
 			plan.EstimatedCost = 2.0 + plan.EstimatedRows*0.1
@@ -445,6 +679,62 @@ func (p *Planner) PlanSelectWithStats(stmt *parser.SelectStatement, schema *tabl
 	return plan
 }
 
+// chooseByCost makes plan use the cheapest way of reading its rows: a
+// table scan or one of its access paths.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A lookup of one key of a unique index finds at most one row, so it's
+// taken without comparing costs, as MySQL does for its "const" access.
+// Everything else is costed, and can lose to a scan: reading a table of
+// three pages is cheaper than descending an index and then reading
+// scattered rows.
+func (p *Planner) chooseByCost(plan *QueryPlan, schema *table.Schema, stats *table.TableStats, indexes []IndexInfo) {
+	paths, forced := plan.hinted(p.accessPaths(plan, schema.PrimaryKeyNames(), indexes))
+
+	// Start again from a table scan, the access method always available
+	plan.AccessMethod = FullTableScan
+	plan.IndexColumn, plan.IndexName = "", ""
+	plan.IndexLookupKey, plan.RangeLower, plan.RangeUpper = nil, nil, nil
+	plan.LowerInclusive, plan.UpperInclusive = false, false
+	plan.EstimatedCost = TableScanCost(stats)
+
+	for _, path := range paths {
+		cost := IndexScanCost(stats, path.rows(stats), path.index.Name != "")
+		if forced || path.uniqueLookup() || cost < plan.EstimatedCost {
+			path.apply(plan)
+			plan.EstimatedCost = cost
+			if forced || path.uniqueLookup() {
+				return
+			}
+		}
+	}
+}
+
+// predicateSelectivity estimates the fraction of rows matching pred, from
+// the column's statistics if ANALYZE gathered them.
+func (p *Planner) predicateSelectivity(pred Predicate, stats *table.TableStats, indexStats *table.IndexStats) float64 {
+	if _, ok := stats.Columns[pred.Column]; !ok || stats.RowCount == 0 {
+		return p.EstimateSelectivity(pred, indexStats)
+	}
+
+	val := literalValue(pred.Value)
+	var rows float64
+	switch pred.Operator {
+	case parser.OpEquals:
+		rows = EstimateEqualityRows(stats, pred.Column, val)
+	case parser.OpNotEquals:
+		rows = float64(stats.RowCount) - EstimateEqualityRows(stats, pred.Column, val)
+	case parser.OpGreaterThan, parser.OpGreaterOrEqual:
+		rows = EstimateRangeRows(stats, pred.Column, &val, nil)
+	case parser.OpLessThan, parser.OpLessOrEqual:
+		rows = EstimateRangeRows(stats, pred.Column, nil, &val)
+	default:
+		return p.EstimateSelectivity(pred, indexStats)
+	}
+	return rows / float64(stats.RowCount)
+}
+
 // EstimateSelectivity estimates what fraction of rows will match a predicate.
 //
 // EDUCATIONAL NOTE:
@@ -187,6 +187,36 @@ func TestPlanSelect_NonPKColumn(t *testing.T) {
 	}
 }
 
+func TestPlanSelect_SecondaryIndex(t *testing.T) {
+	planner := New()
+	schema := testSchema()
+	indexes := []IndexInfo{{Name: "idx_users_age", Column: "age"}}
+
+	tests := []struct {
+		sql    string
+		method AccessMethod
+		index  string
+	}{
+		{"SELECT * FROM users WHERE age = 30", IndexLookup, "idx_users_age"},
+		{"SELECT * FROM users WHERE age > 18 AND age <= 65", IndexRangeScan, "idx_users_age"},
+		{"SELECT * FROM users WHERE age = 30 AND id = 5", IndexLookup, ""},
+		{"SELECT * FROM users WHERE age = 30 AND id > 5", IndexLookup, "idx_users_age"},
+		{"SELECT * FROM users WHERE name = 'Bob'", FullTableScan, ""},
+	}
+	for _, tt := range tests {
+		stmt := &parser.SelectStatement{From: "users", Where: parseWhere(t, tt.sql)}
+		plan := planner.PlanSelect(stmt, schema, indexes...)
+		if plan.AccessMethod != tt.method || plan.IndexName != tt.index {
+			t.Errorf("%s: expected %v using %q, got %v using %q", tt.sql, tt.method, tt.index, plan.AccessMethod, plan.IndexName)
+		}
+	}
+
+	stmt := &parser.SelectStatement{From: "users", Where: parseWhere(t, "SELECT * FROM users WHERE age = 30")}
+	if s := planner.PlanSelect(stmt, schema, indexes...).String(); !contains(s, "using idx_users_age") {
+		t.Errorf("expected plan to name the index, got %q", s)
+	}
+}
+
 func TestPlanSelect_PKEqualityPreferredOverRange(t *testing.T) {
 	planner := New()
 	schema := testSchema()
@@ -206,6 +236,29 @@ func TestPlanSelect_PKEqualityPreferredOverRange(t *testing.T) {
 	}
 }
 
+func TestPlanUpdateAndDelete(t *testing.T) {
+	planner := New()
+	schema := testSchema()
+
+	update := &parser.UpdateStatement{
+		Table: "users",
+		Where: parseWhere(t, "SELECT * FROM users WHERE id = 7"),
+	}
+	plan := planner.PlanUpdate(update, schema)
+	if plan.AccessMethod != IndexLookup || plan.IndexLookupKey != int64(7) {
+		t.Errorf("expected IndexLookup on 7 for UPDATE, got %v", plan)
+	}
+
+	del := &parser.DeleteStatement{
+		Table: "users",
+		Where: parseWhere(t, "SELECT * FROM users WHERE age > 30"),
+	}
+	plan = planner.PlanDelete(del, schema)
+	if plan.AccessMethod != FullTableScan || len(plan.Predicates) != 1 {
+		t.Errorf("expected FullTableScan with 1 predicate for DELETE, got %v", plan)
+	}
+}
+
 func TestAnalyzeWhere_NoWhere(t *testing.T) {
 	planner := New()
 	schema := testSchema()
@@ -347,3 +400,29 @@ func containsAt(s, substr string, start int) bool {
 	}
 	return false
 }
+
+func TestPlanSelect_Hints(t *testing.T) {
+	planner := New()
+	schema := testSchema()
+	ageIndex := IndexInfo{Name: "idx_users_age", Column: "age"}
+
+	tests := []struct {
+		sql    string
+		method AccessMethod
+		index  string
+	}{
+		{"SELECT /*+ FULL_SCAN */ * FROM users WHERE id = 5", FullTableScan, ""},
+		{"SELECT /*+ INDEX(idx_users_age) */ * FROM users WHERE id = 5 AND age > 30", IndexRangeScan, "idx_users_age"},
+		{"SELECT /*+ INDEX(idx_users_age) */ * FROM users WHERE id = 5", IndexLookup, ""},
+	}
+	for _, tt := range tests {
+		stmt, err := parser.New(lexer.New(tt.sql)).Parse()
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", tt.sql, err)
+		}
+		plan := planner.PlanSelect(stmt.(*parser.SelectStatement), schema, ageIndex)
+		if plan.AccessMethod != tt.method || plan.IndexName != tt.index || plan.Hint == nil {
+			t.Errorf("%s: expected %s %q under a hint, got %s", tt.sql, tt.method, tt.index, plan)
+		}
+	}
+}
@@ -26,15 +26,27 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 )
 
-const (
-	// MaxKeys is the maximum number of keys in a node.
-	// This is chosen so that a node fits comfortably in a page.
-	MaxKeys = 100
+// ErrKeyTooLarge is returned when a key is longer than MaxKeySize.
+var ErrKeyTooLarge = errors.New("key too large for index")
 
-	// MinKeys is the minimum number of keys in a non-root node.
-	MinKeys = MaxKeys / 2
+const (
+	// MaxKeySize is the largest key a B-tree accepts. Keys this size still
+	// fit four to a page, so a full node split in two always has room for
+	// one more.
+	MaxKeySize = 1000
+
+	// nodeHeaderSize is the size of a serialized node before its keys:
+	// flags, numKeys, number of children and the two sibling pointers.
+	nodeHeaderSize = 1 + 2 + 2 + 4 + 4
+
+	// nodeLeafFlag and nodePrefixFlag are the bits of a node's first byte.
+	// A leaf written with nodePrefixFlag stores the prefix its keys share
+	// once, followed by the rest of each key; see leafSize.
+	nodeLeafFlag   = 0x01
+	nodePrefixFlag = 0x02
 )
 
 // BTreeNode represents a node in the B-tree.
@@ -97,6 +109,7 @@ type BTreeIterator struct {
 	currentKey     []byte
 	currentVal     uint64
 	leafPageID     uint32
+	pinned         bool // leafPageID is pinned in the pager
 	keyIdx         int
 	node           *BTreeNode
 	started        bool
@@ -131,8 +144,8 @@ func NewBTree(pager *Pager) (*BTree, error) {
 		pageID:   rootPage.ID(),
 		isLeaf:   true,
 		numKeys:  0,
-		keys:     make([][]byte, 0, MaxKeys),
-		values:   make([]uint64, 0, MaxKeys),
+		keys:     nil,
+		values:   nil,
 		children: nil,
 	}
 
@@ -221,6 +234,10 @@ func (bt *BTree) searchNode(pageID uint32, key []byte) (uint64, bool, error) {
 // 2. This ensures we can always insert without backtracking
 // 3. If root is full, create new root first (tree grows upward)
 func (bt *BTree) Insert(key []byte, value uint64) error {
+	if err := CheckKeySize(key); err != nil {
+		return err
+	}
+
 	rootPage, err := bt.pager.GetPage(bt.rootPage)
 	if err != nil {
 		return err
@@ -232,7 +249,7 @@ func (bt *BTree) Insert(key []byte, value uint64) error {
 	}
 
 	// If root is full, split it first
-	if root.numKeys >= MaxKeys {
+	if root.isFull(key) {
 		// Create new root
 		newRootPage, err := bt.pager.AllocatePage(PageTypeBTreeInternal)
 		if err != nil {
@@ -243,13 +260,13 @@ func (bt *BTree) Insert(key []byte, value uint64) error {
 			pageID:   newRootPage.ID(),
 			isLeaf:   false,
 			numKeys:  0,
-			keys:     make([][]byte, 0, MaxKeys),
+			keys:     nil,
 			values:   nil,
 			children: []uint32{bt.rootPage},
 		}
 
 		// Split old root as child of new root
-		if err := bt.splitChild(newRoot, newRootPage, 0); err != nil {
+		if err := bt.splitChild(newRoot, newRootPage, 0, key); err != nil {
 			return err
 		}
 
@@ -261,6 +278,23 @@ func (bt *BTree) Insert(key []byte, value uint64) error {
 	return bt.insertNonFull(root, rootPage, key, value)
 }
 
+// CheckKeySize returns an error wrapping ErrKeyTooLarge if key is longer
+// than MaxKeySize.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Large row values can move to overflow pages, but keys can't: a search
+// compares the key at every node on its way down, and following an
+// overflow chain for each comparison would defeat the point of the tree.
+// Capping key size instead keeps several keys in every node. PostgreSQL
+// does the same, rejecting index entries over about a third of a page.
+func CheckKeySize(key []byte) error {
+	if len(key) > MaxKeySize {
+		return fmt.Errorf("%w: %d bytes exceeds the maximum of %d", ErrKeyTooLarge, len(key), MaxKeySize)
+	}
+	return nil
+}
+
 // insertNonFull inserts into a node that is guaranteed not to be full.
 func (bt *BTree) insertNonFull(node *BTreeNode, page *Page, key []byte, value uint64) error {
 	idx := bt.findKeyIndex(node, key)
@@ -287,8 +321,12 @@ func (bt *BTree) insertNonFull(node *BTreeNode, page *Page, key []byte, value ui
 		return serializeNode(page, node)
 	}
 
-	// Internal node - find child to descend into
+	// Internal node - find child to descend into. A key equal to a
+	// separator belongs to the right child, as in searchNode.
 	childIdx := idx
+	if idx < int(node.numKeys) && bytes.Equal(key, node.keys[idx]) {
+		childIdx++
+	}
 	if childIdx >= len(node.children) {
 		childIdx = len(node.children) - 1
 	}
@@ -305,8 +343,8 @@ func (bt *BTree) insertNonFull(node *BTreeNode, page *Page, key []byte, value ui
 	}
 
 	// If child is full, split it first
-	if child.numKeys >= MaxKeys {
-		if err := bt.splitChild(node, page, childIdx); err != nil {
+	if child.isFull(key) {
+		if err := bt.splitChild(node, page, childIdx, key); err != nil {
 			return err
 		}
 
@@ -316,8 +354,9 @@ func (bt *BTree) insertNonFull(node *BTreeNode, page *Page, key []byte, value ui
 			return err
 		}
 
-		// Decide which child to follow after split
-		if idx < int(node.numKeys) && bytes.Compare(key, node.keys[idx]) >= 0 {
+		// Decide which child to follow after split: the promoted median
+		// is now at childIdx
+		if childIdx < int(node.numKeys) && bytes.Compare(key, node.keys[childIdx]) >= 0 {
 			childIdx++
 		}
 
@@ -339,9 +378,165 @@ func (bt *BTree) insertNonFull(node *BTreeNode, page *Page, key []byte, value ui
 	return bt.insertNonFull(child, childPage, key, value)
 }
 
-// splitChild splits the child at childIdx into two nodes.
-// The median key is promoted to the parent.
-func (bt *BTree) splitChild(parent *BTreeNode, parentPage *Page, childIdx int) error {
+// isFull reports whether node may not have room for what inserting key
+// would add to it, and so must be split before the insert goes into it or
+// below it.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A node's capacity is measured in bytes, not keys: a page holds about
+// 240 eight-byte keys but only four of MaxKeySize. A fixed key count would
+// either overflow the page with long keys or leave it mostly empty with
+// short ones.
+//
+// A leaf only ever gains the key being inserted. An internal node gains
+// the separator promoted when one of its children splits, which can be
+// any key from that child, so it needs room for the largest key allowed.
+func (node *BTreeNode) isFull(key []byte) bool {
+	if node.isLeaf {
+		keyBytes := 0
+		for _, k := range node.keys {
+			keyBytes += len(k)
+		}
+		if node.numKeys == 0 {
+			return leafSize(key, key, 1, len(key)) > MaxDataSize
+		}
+		return leafSizeWith(node.keys[0], node.keys[node.numKeys-1], int(node.numKeys), keyBytes, key) > MaxDataSize
+	}
+	return node.size()+2+MaxKeySize+4 > MaxDataSize
+}
+
+// size returns the number of bytes serializeNode writes for node.
+func (node *BTreeNode) size() int {
+	if node.isLeaf {
+		if node.numKeys == 0 {
+			return leafSize(nil, nil, 0, 0)
+		}
+		keyBytes := 0
+		for _, key := range node.keys {
+			keyBytes += len(key)
+		}
+		return leafSize(node.keys[0], node.keys[node.numKeys-1], int(node.numKeys), keyBytes)
+	}
+
+	size := nodeHeaderSize
+	for _, key := range node.keys {
+		size += 2 + len(key)
+	}
+	return size + 4*len(node.children)
+}
+
+// leafSize returns the number of bytes serializeNode writes for a leaf
+// holding count sorted keys from first to last, keyBytes long in total.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Keys next to each other in an index tend to start the same way: the
+// same table ID, the same customer, "https://www.". Since a leaf's keys
+// are sorted, the prefix its first and last keys share is shared by every
+// key between them, so the leaf stores it once and keeps only the rest of
+// each key (prefix compression):
+//
+//   keys:   "user:alice" "user:bob" "user:carol"
+//   stored: "user:" + "alice" "bob" "carol"
+//
+// Keys are rebuilt in full when the node is read, so nothing but the page
+// layout changes. The more the keys share, the more of them fit in a
+// page, and the fewer pages a scan or lookup reads.
+//
+// Inserting a key can shorten the prefix and so make every other key in
+// the leaf longer, which is why isFull and leafSplitPoint work out the
+// size with the new key already in place. Internal nodes aren't
+// compressed: they hold few keys, and must keep room for any separator a
+// child split promotes. InnoDB and MyISAM compress index keys in a
+// similar way; PostgreSQL instead truncates separator keys.
+func leafSize(first, last []byte, count, keyBytes int) int {
+	prefix := 0
+	if count > 0 {
+		prefix = commonPrefixLen(first, last)
+	}
+	return nodeHeaderSize + 2 + prefix + keyBytes - count*prefix + count*(2+8)
+}
+
+// leafSizeWith returns leafSize for the same keys with key added.
+func leafSizeWith(first, last []byte, count, keyBytes int, key []byte) int {
+	if bytes.Compare(key, first) < 0 {
+		first = key
+	}
+	if bytes.Compare(key, last) > 0 {
+		last = key
+	}
+	return leafSize(first, last, count+1, keyBytes+len(key))
+}
+
+// commonPrefixLen returns the length of the longest prefix a and b share.
+func commonPrefixLen(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// splitPoint returns the index a full internal node is split at: the
+// first key past the middle of its bytes, so both halves get about half a
+// page even when the keys differ in size.
+func (node *BTreeNode) splitPoint() int {
+	entrySizes := make([]int, node.numKeys)
+	total := 0
+	for i, key := range node.keys {
+		entrySizes[i] = 2 + len(key) + 4
+		total += entrySizes[i]
+	}
+
+	// The median moves up to the parent, and each side keeps at least
+	// one key
+	last := int(node.numKeys) - 2
+	mid, used := 0, 0
+	for mid < last && (mid == 0 || used < total/2) {
+		used += entrySizes[mid]
+		mid++
+	}
+	return mid
+}
+
+// leafSplitPoint returns the index a full leaf is split at before key is
+// inserted into it: the one that leaves the larger half, counting key in
+// the half it will go to, as small as possible.
+//
+// Splitting at the middle isn't always enough with prefix compression: a
+// key that shares nothing with the rest makes the half it lands in lose
+// its prefix, and that half can then be bigger than the whole leaf was.
+func (node *BTreeNode) leafSplitPoint(key []byte) int {
+	n := int(node.numKeys)
+	// keyBytes[i] is the total length of keys[:i]
+	keyBytes := make([]int, n+1)
+	for i, k := range node.keys {
+		keyBytes[i+1] = keyBytes[i] + len(k)
+	}
+
+	best, bestSize := n/2, -1
+	for mid := 1; mid < n; mid++ {
+		left := leafSize(node.keys[0], node.keys[mid-1], mid, keyBytes[mid])
+		right := leafSize(node.keys[mid], node.keys[n-1], n-mid, keyBytes[n]-keyBytes[mid])
+		// A key equal to or past the median goes right, as in insertNonFull
+		if bytes.Compare(key, node.keys[mid]) < 0 {
+			left = leafSizeWith(node.keys[0], node.keys[mid-1], mid, keyBytes[mid], key)
+		} else {
+			right = leafSizeWith(node.keys[mid], node.keys[n-1], n-mid, keyBytes[n]-keyBytes[mid], key)
+		}
+		if larger := max(left, right); bestSize < 0 || larger < bestSize {
+			best, bestSize = mid, larger
+		}
+	}
+	return best
+}
+
+// splitChild splits the child at childIdx into two nodes, making room for
+// key to be inserted below it. The median key is promoted to the parent.
+func (bt *BTree) splitChild(parent *BTreeNode, parentPage *Page, childIdx int, key []byte) error {
 	childPageID := parent.children[childIdx]
 	childPage, err := bt.pager.GetPage(childPageID)
 	if err != nil {
@@ -353,7 +548,12 @@ func (bt *BTree) splitChild(parent *BTreeNode, parentPage *Page, childIdx int) e
 		return err
 	}
 
-	mid := int(child.numKeys) / 2
+	var mid int
+	if child.isLeaf {
+		mid = child.leafSplitPoint(key)
+	} else {
+		mid = child.splitPoint()
+	}
 
 	// Create new sibling for the right half
 	var siblingPageType PageType
@@ -451,6 +651,202 @@ func (bt *BTree) splitChild(parent *BTreeNode, parentPage *Page, childIdx int) e
 	return serializeNode(parentPage, parent)
 }
 
+// Delete removes a key from the B-tree, reporting whether it was present.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A textbook B-tree delete keeps every node at least half full by
+// borrowing keys from a sibling or merging two siblings, which can ripple
+// up to the root. We only remove the key from its leaf and leave the
+// leaf as it is, even if that leaves it empty. Lookups and scans still
+// work: separator keys in internal nodes only guide the search, and
+// iterators skip empty leaves by following the sibling links.
+//
+// The cost is wasted space after many deletes. PostgreSQL makes the same
+// trade-off and leaves reclaiming it to VACUUM.
+func (bt *BTree) Delete(key []byte) (bool, error) {
+	leafID, err := bt.findLeaf(bt.rootPage, key)
+	if err != nil {
+		return false, err
+	}
+	page, err := bt.pager.GetPage(leafID)
+	if err != nil {
+		return false, err
+	}
+	node, err := deserializeNode(page)
+	if err != nil {
+		return false, err
+	}
+
+	idx := bt.findKeyIndex(node, key)
+	if idx >= int(node.numKeys) || !bytes.Equal(node.keys[idx], key) {
+		return false, nil
+	}
+
+	node.keys = append(node.keys[:idx], node.keys[idx+1:]...)
+	node.values = append(node.values[:idx], node.values[idx+1:]...)
+	node.numKeys--
+
+	return true, serializeNode(page, node)
+}
+
+// BuildBTree creates a B-tree holding the given pairs, which must be
+// sorted by key. If a key repeats, the last of its values is kept, as if
+// the pairs had been inserted in order.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Inserting keys one at a time leaves leaves about half full, since a
+// full leaf is split into two halves, and deletes leave more holes (see
+// Delete). When every key is known up front the tree can instead be built
+// from the bottom up, which is called "bulk loading":
+//
+//   1. Pack the sorted pairs into leaves, filling each one, and link them.
+//   2. Build a level of internal nodes over the leaves. Every child but
+//      the first contributes its smallest key as a separator.
+//   3. Repeat on the new level until a single node, the root, is left.
+//
+// Every node is full and every page is written once. The pages of each
+// level are also allocated one after another, so a range scan reads the
+// leaves in file order. PostgreSQL's CREATE INDEX builds B-trees the same
+// way.
+func BuildBTree(pager *Pager, keys [][]byte, values []uint64) (*BTree, error) {
+	// Drop repeated keys, keeping the last value
+	var leafKeys [][]byte
+	var leafValues []uint64
+	for i, key := range keys {
+		if err := CheckKeySize(key); err != nil {
+			return nil, err
+		}
+		if n := len(leafKeys); n > 0 && bytes.Equal(leafKeys[n-1], key) {
+			leafValues[n-1] = values[i]
+			continue
+		}
+		leafKeys = append(leafKeys, key)
+		leafValues = append(leafValues, values[i])
+	}
+	if len(leafKeys) == 0 {
+		return NewBTree(pager)
+	}
+
+	// Pack the leaves. The keys are sorted, so each new key is the last.
+	var level []*BTreeNode
+	leaf := &BTreeNode{isLeaf: true}
+	keyBytes := 0
+	for i, key := range leafKeys {
+		if leaf.numKeys > 0 && leafSize(leaf.keys[0], key, int(leaf.numKeys)+1, keyBytes+len(key)) > MaxDataSize {
+			level = append(level, leaf)
+			leaf = &BTreeNode{isLeaf: true}
+			keyBytes = 0
+		}
+		leaf.keys = append(leaf.keys, key)
+		leaf.values = append(leaf.values, leafValues[i])
+		leaf.numKeys++
+		keyBytes += len(key)
+	}
+	level = append(level, leaf)
+	if err := allocateNodes(pager, level); err != nil {
+		return nil, err
+	}
+	for i, node := range level {
+		if i > 0 {
+			node.prevLeaf = level[i-1].pageID
+		}
+		if i < len(level)-1 {
+			node.nextLeaf = level[i+1].pageID
+		}
+	}
+
+	// Build internal levels until one node is left. minKeys[i] is the
+	// smallest key under level[i].
+	minKeys := make([][]byte, len(level))
+	for i, node := range level {
+		minKeys[i] = node.keys[0]
+	}
+	for len(level) > 1 {
+		if err := writeNodes(pager, level); err != nil {
+			return nil, err
+		}
+
+		var parents []*BTreeNode
+		var parentMinKeys [][]byte
+		var parent *BTreeNode
+		size := 0
+		for i, child := range level {
+			entry := 4
+			if parent != nil {
+				entry += 2 + len(minKeys[i])
+			}
+			if parent == nil || size+entry > MaxDataSize {
+				parent = &BTreeNode{}
+				parents = append(parents, parent)
+				parentMinKeys = append(parentMinKeys, minKeys[i])
+				size = nodeHeaderSize + 4
+				parent.children = append(parent.children, child.pageID)
+				continue
+			}
+			parent.keys = append(parent.keys, minKeys[i])
+			parent.children = append(parent.children, child.pageID)
+			parent.numKeys++
+			size += entry
+		}
+
+		// A last node with a single child has nothing to separate; give it
+		// one more child from its neighbour
+		if n := len(parents); n > 1 && parents[n-1].numKeys == 0 && parents[n-2].numKeys > 1 {
+			last, prev := parents[n-1], parents[n-2]
+			moved := len(prev.children) - 1
+			last.keys = [][]byte{parentMinKeys[n-1]}
+			last.children = append([]uint32{prev.children[moved]}, last.children...)
+			last.numKeys = 1
+			parentMinKeys[n-1] = prev.keys[prev.numKeys-1]
+			prev.keys = prev.keys[:prev.numKeys-1]
+			prev.children = prev.children[:moved]
+			prev.numKeys--
+		}
+
+		if err := allocateNodes(pager, parents); err != nil {
+			return nil, err
+		}
+		level, minKeys = parents, parentMinKeys
+	}
+	if err := writeNodes(pager, level); err != nil {
+		return nil, err
+	}
+
+	return &BTree{pager: pager, rootPage: level[0].pageID}, nil
+}
+
+// allocateNodes gives each node a new page of the matching type.
+func allocateNodes(pager *Pager, nodes []*BTreeNode) error {
+	for _, node := range nodes {
+		pageType := PageTypeBTreeInternal
+		if node.isLeaf {
+			pageType = PageTypeBTreeLeaf
+		}
+		page, err := pager.AllocatePage(pageType)
+		if err != nil {
+			return err
+		}
+		node.pageID = page.ID()
+	}
+	return nil
+}
+
+// writeNodes serializes each node into its page.
+func writeNodes(pager *Pager, nodes []*BTreeNode) error {
+	for _, node := range nodes {
+		page, err := pager.GetPage(node.pageID)
+		if err != nil {
+			return err
+		}
+		if err := serializeNode(page, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // findKeyIndex finds the index where key should be inserted (or exists).
 func (bt *BTree) findKeyIndex(node *BTreeNode, key []byte) int {
 	low, high := 0, int(node.numKeys)
@@ -668,13 +1064,40 @@ func (it *BTreeIterator) Next() bool {
 		if err := it.seekStart(); err != nil {
 			it.err = err
 			it.done = true
+			it.unpinLeaf()
 			return false
 		}
 		// seekStart positions us before the first key, fall through to advance
 	}
 
 	// Advance to next key
-	return it.advance()
+	if !it.advance() {
+		it.unpinLeaf()
+		return false
+	}
+	return true
+}
+
+// pinLeaf makes pageID the iterator's current leaf, pinning it in the
+// pager so it stays cached while the iterator reads from it, and
+// unpinning the previous one.
+func (it *BTreeIterator) pinLeaf(pageID uint32) (*Page, error) {
+	page, err := it.bt.pager.PinPage(pageID)
+	if err != nil {
+		return nil, err
+	}
+	it.unpinLeaf()
+	it.leafPageID = pageID
+	it.pinned = true
+	return page, nil
+}
+
+// unpinLeaf releases the pin on the current leaf, if any.
+func (it *BTreeIterator) unpinLeaf() {
+	if it.pinned {
+		it.bt.pager.UnpinPage(it.leafPageID)
+		it.pinned = false
+	}
 }
 
 // seekStart positions the iterator at the starting point.
@@ -693,10 +1116,8 @@ func (it *BTreeIterator) seekStart() error {
 		return err
 	}
 
-	it.leafPageID = leafPageID
-
 	// Load the leaf node
-	page, err := it.bt.pager.GetPage(leafPageID)
+	page, err := it.pinLeaf(leafPageID)
 	if err != nil {
 		return err
 	}
@@ -763,7 +1184,7 @@ func (it *BTreeIterator) advance() bool {
 			return false
 		}
 
-		page, err := it.bt.pager.GetPage(it.node.nextLeaf)
+		page, err := it.pinLeaf(it.node.nextLeaf)
 		if err != nil {
 			it.err = err
 			it.done = true
@@ -777,7 +1198,6 @@ func (it *BTreeIterator) advance() bool {
 			return false
 		}
 
-		it.leafPageID = it.node.nextLeaf
 		it.node = node
 		it.keyIdx = 0
 	}
@@ -799,10 +1219,11 @@ func (it *BTreeIterator) Err() error {
 	return it.err
 }
 
-// Close releases any resources held by the iterator.
-// It's good practice to call Close when done, though not strictly required
-// for this in-memory implementation.
+// Close releases the pin on the iterator's current leaf. An iterator that
+// runs to the end releases it by itself, but one abandoned part way must
+// be closed, or its leaf stays pinned in the cache.
 func (it *BTreeIterator) Close() error {
+	it.unpinLeaf()
 	it.done = true
 	it.node = nil
 	return nil
@@ -829,9 +1250,9 @@ func (it *BTreeIterator) Collect() ([][]byte, []uint64, error) {
 func serializeNode(page *Page, node *BTreeNode) error {
 	buf := bytes.NewBuffer(nil)
 
-	// Write metadata: isLeaf (1 byte), numKeys (2 bytes)
+	// Write metadata: flags (1 byte), numKeys (2 bytes)
 	if node.isLeaf {
-		buf.WriteByte(1)
+		buf.WriteByte(nodeLeafFlag | nodePrefixFlag)
 	} else {
 		buf.WriteByte(0)
 	}
@@ -845,10 +1266,19 @@ func serializeNode(page *Page, node *BTreeNode) error {
 	binary.Write(buf, binary.LittleEndian, node.nextLeaf)
 	binary.Write(buf, binary.LittleEndian, node.prevLeaf)
 
-	// Write keys
+	// Write keys, leaf keys after the prefix they share
+	var prefix []byte
+	if node.isLeaf {
+		if node.numKeys > 0 {
+			prefix = node.keys[0][:commonPrefixLen(node.keys[0], node.keys[node.numKeys-1])]
+		}
+		binary.Write(buf, binary.LittleEndian, uint16(len(prefix)))
+		buf.Write(prefix)
+	}
 	for i := 0; i < int(node.numKeys); i++ {
-		binary.Write(buf, binary.LittleEndian, uint16(len(node.keys[i])))
-		buf.Write(node.keys[i])
+		suffix := node.keys[i][len(prefix):]
+		binary.Write(buf, binary.LittleEndian, uint16(len(suffix)))
+		buf.Write(suffix)
 	}
 
 	// Write values (for leaves) or children (for internal)
@@ -879,11 +1309,11 @@ func deserializeNode(page *Page) (*BTreeNode, error) {
 	}
 
 	// Read metadata
-	isLeafByte, err := buf.ReadByte()
+	flags, err := buf.ReadByte()
 	if err != nil {
 		return nil, err
 	}
-	node.isLeaf = isLeafByte == 1
+	node.isLeaf = flags&nodeLeafFlag != 0
 
 	if err := binary.Read(buf, binary.LittleEndian, &node.numKeys); err != nil {
 		return nil, err
@@ -902,6 +1332,20 @@ func deserializeNode(page *Page) (*BTreeNode, error) {
 		return nil, err
 	}
 
+	// Read the shared prefix, which leaves written before prefix
+	// compression don't have
+	var prefix []byte
+	if flags&nodePrefixFlag != 0 {
+		var prefixLen uint16
+		if err := binary.Read(buf, binary.LittleEndian, &prefixLen); err != nil {
+			return nil, err
+		}
+		prefix = make([]byte, prefixLen)
+		if _, err := io.ReadFull(buf, prefix); err != nil {
+			return nil, err
+		}
+	}
+
 	// Read keys
 	node.keys = make([][]byte, node.numKeys)
 	for i := uint16(0); i < node.numKeys; i++ {
@@ -909,8 +1353,9 @@ func deserializeNode(page *Page) (*BTreeNode, error) {
 		if err := binary.Read(buf, binary.LittleEndian, &keyLen); err != nil {
 			return nil, err
 		}
-		node.keys[i] = make([]byte, keyLen)
-		if _, err := buf.Read(node.keys[i]); err != nil {
+		node.keys[i] = make([]byte, len(prefix)+int(keyLen))
+		copy(node.keys[i], prefix)
+		if _, err := io.ReadFull(buf, node.keys[i][len(prefix):]); err != nil {
 			return nil, err
 		}
 	}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -402,8 +404,9 @@ func TestBTreeLeafSiblingPointers(t *testing.T) {
 	btree, pager, cleanup := setupTestBTree(t)
 	defer cleanup()
 
-	// Insert enough keys to cause at least one split
-	numKeys := 150
+	// Insert enough keys to cause at least one split: a page holds a
+	// couple of hundred keys this size
+	numKeys := 600
 	for i := 0; i < numKeys; i++ {
 		key := []byte(fmt.Sprintf("key_%04d", i))
 		if err := btree.Insert(key, uint64(i)); err != nil {
@@ -1064,3 +1067,331 @@ func BenchmarkRangeScan(b *testing.B) {
 		iter.Close()
 	}
 }
+
+func TestBTreeDelete(t *testing.T) {
+	btree, _, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	// Enough keys to split into several leaves
+	for i := 0; i < 500; i++ {
+		if err := btree.Insert([]byte(fmt.Sprintf("key%04d", i)), uint64(i)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Delete every even key, and the whole range 200-299 so some leaves empty
+	for i := 0; i < 500; i++ {
+		if i%2 != 0 && (i < 200 || i >= 300) {
+			continue
+		}
+		found, err := btree.Delete([]byte(fmt.Sprintf("key%04d", i)))
+		if err != nil {
+			t.Fatalf("Delete key%04d failed: %v", i, err)
+		}
+		if !found {
+			t.Errorf("Delete key%04d: expected key to be found", i)
+		}
+	}
+
+	if found, _ := btree.Delete([]byte("key0000")); found {
+		t.Error("deleting an already deleted key reported it as found")
+	}
+
+	for i := 0; i < 500; i++ {
+		_, found, err := btree.Search([]byte(fmt.Sprintf("key%04d", i)))
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		want := i%2 != 0 && (i < 200 || i >= 300)
+		if found != want {
+			t.Errorf("key%04d: found=%v, want %v", i, found, want)
+		}
+	}
+
+	// Scans and iterators step over the emptied leaves
+	keys, _, err := btree.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(keys) != 200 {
+		t.Errorf("expected 200 keys after deletes, got %d", len(keys))
+	}
+	keys, _, err = btree.NewRangeIterator([]byte("key0190"), []byte("key0310")).Collect()
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if len(keys) != 10 || string(keys[0]) != "key0191" || string(keys[5]) != "key0301" {
+		t.Errorf("unexpected range across deleted keys: %q", keys)
+	}
+
+	// Deleted keys can be inserted again
+	if err := btree.Insert([]byte("key0250"), 250); err != nil {
+		t.Fatalf("re-Insert failed: %v", err)
+	}
+	if value, found, _ := btree.Search([]byte("key0250")); !found || value != 250 {
+		t.Errorf("expected re-inserted key0250=250, got %d (found=%v)", value, found)
+	}
+}
+
+func TestBuildBTree(t *testing.T) {
+	_, pager, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	var keys [][]byte
+	var values []uint64
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("key%04d", i)))
+		values = append(values, uint64(i))
+		if i == 500 {
+			// A repeated key keeps its last value
+			keys = append(keys, keys[i])
+			values = append(values, 5000)
+		}
+	}
+
+	before := pager.PageCount()
+	btree, err := BuildBTree(pager, keys, values)
+	if err != nil {
+		t.Fatalf("BuildBTree failed: %v", err)
+	}
+
+	// 1000 keys in leaves filled to the page, plus one root. The keys in
+	// each leaf share the prefix "key0", which is stored once.
+	perLeaf := (MaxDataSize - nodeHeaderSize - 2 - len("key0")) / (2 + len("000") + 8)
+	wantPages := (1000+perLeaf-1)/perLeaf + 1
+	if pages := int(pager.PageCount() - before); pages != wantPages {
+		t.Errorf("expected %d pages for a packed tree, got %d", wantPages, pages)
+	}
+
+	for i := 0; i < 1000; i++ {
+		value, found, err := btree.Search([]byte(fmt.Sprintf("key%04d", i)))
+		want := uint64(i)
+		if i == 500 {
+			want = 5000
+		}
+		if err != nil || !found || value != want {
+			t.Errorf("key%04d: got %d (found=%v, err=%v), want %d", i, value, found, err, want)
+		}
+	}
+
+	from, to := fmt.Sprintf("key%04d", perLeaf-5), fmt.Sprintf("key%04d", perLeaf+5)
+	scanned, _, err := btree.NewRangeIterator([]byte(from), []byte(to)).Collect()
+	if err != nil || len(scanned) != 11 {
+		t.Errorf("expected a range across two leaves to return 11 keys, got %d (err=%v)", len(scanned), err)
+	}
+
+	// The tree keeps working as an ordinary B-tree
+	if err := btree.Insert([]byte("key0050a"), 1); err != nil {
+		t.Fatalf("Insert into built tree failed: %v", err)
+	}
+	if all, _, _ := btree.Scan(); len(all) != 1001 || !bytes.Equal(all[51], []byte("key0050a")) {
+		t.Errorf("expected inserted key in order among 1001 keys, got %d keys", len(all))
+	}
+
+	empty, err := BuildBTree(pager, nil, nil)
+	if err != nil {
+		t.Fatalf("BuildBTree with no keys failed: %v", err)
+	}
+	if _, found, _ := empty.Search([]byte("key")); found {
+		t.Error("expected empty tree")
+	}
+}
+
+func TestBuildBTreeLevels(t *testing.T) {
+	_, pager, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	// Enough leaves for two internal levels. A leaf holds at most perLeaf
+	// keys, when all of them share the prefix "key000" and only the last
+	// three digits are stored for each.
+	perLeaf := (MaxDataSize - nodeHeaderSize - 2 - len("key000")) / (2 + len("000") + 8)
+	fanout := (MaxDataSize-nodeHeaderSize-4)/(2+len("key000000")+4) + 1
+	n := perLeaf * (fanout + 1)
+	keys := make([][]byte, n)
+	values := make([]uint64, n)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key%06d", i))
+		values[i] = uint64(i)
+	}
+
+	btree, err := BuildBTree(pager, keys, values)
+	if err != nil {
+		t.Fatalf("BuildBTree failed: %v", err)
+	}
+	for _, i := range []int{0, 1, perLeaf - 1, perLeaf, n / 2, n - perLeaf - 1, n - perLeaf, n - 1} {
+		if value, found, _ := btree.Search(keys[i]); !found || value != uint64(i) {
+			t.Errorf("%s: got %d (found=%v)", keys[i], value, found)
+		}
+	}
+	if all, _, _ := btree.Scan(); len(all) != n {
+		t.Errorf("expected %d keys, got %d", n, len(all))
+	}
+}
+
+func TestBTreeVaryingKeySizes(t *testing.T) {
+	btree, _, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	// Keys of very different sizes: nodes must split by bytes, not by
+	// key count
+	sizes := []int{8, MaxKeySize, 300, 40, 700, 4}
+	var keys [][]byte
+	for i := 0; i < 300; i++ {
+		key := []byte(fmt.Sprintf("%04d", i*7%300))
+		key = append(key, bytes.Repeat([]byte("x"), sizes[i%len(sizes)]-4)...)
+		if err := btree.Insert(key, uint64(i)); err != nil {
+			t.Fatalf("Insert of %d-byte key %d failed: %v", len(key), i, err)
+		}
+		keys = append(keys, key)
+	}
+
+	for i, key := range keys {
+		value, found, err := btree.Search(key)
+		if err != nil || !found || value != uint64(i) {
+			t.Fatalf("Search for key %d: got %d, %v, %v", i, value, found, err)
+		}
+	}
+	scanned, _, err := btree.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(scanned) != len(keys) {
+		t.Fatalf("expected %d keys from scan, got %d", len(keys), len(scanned))
+	}
+	for i := 1; i < len(scanned); i++ {
+		if bytes.Compare(scanned[i-1], scanned[i]) >= 0 {
+			t.Fatalf("scan out of order at %d", i)
+		}
+	}
+}
+
+func TestBTreeKeyTooLarge(t *testing.T) {
+	btree, pager, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	key := bytes.Repeat([]byte("k"), MaxKeySize+1)
+	if err := btree.Insert(key, 1); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("expected ErrKeyTooLarge from Insert, got %v", err)
+	}
+	if _, err := BuildBTree(pager, [][]byte{key}, []uint64{1}); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("expected ErrKeyTooLarge from BuildBTree, got %v", err)
+	}
+	if err := btree.Insert(key[:MaxKeySize], 1); err != nil {
+		t.Errorf("key of exactly MaxKeySize rejected: %v", err)
+	}
+}
+
+func TestBTreeCapacityBySize(t *testing.T) {
+	btree, _, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	// Eight-byte keys in random order; leaves end up between half and
+	// completely full, so well over 100 keys each
+	n := 5000
+	for i := 0; i < n; i++ {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, uint64(i*7919%n))
+		if err := btree.Insert(key, uint64(i)); err != nil {
+			t.Fatalf("Insert %d failed: %v", i, err)
+		}
+	}
+
+	r := NewIntegrityReport()
+	if entries := btree.CheckIntegrity(r, "test"); entries != n || len(r.Problems) != 0 {
+		t.Fatalf("expected a healthy tree of %d keys, got %d keys and %v", n, entries, r.Problems)
+	}
+	pageID, err := btree.FirstLeaf()
+	if err != nil {
+		t.Fatalf("FirstLeaf failed: %v", err)
+	}
+	leaves := 1
+	for {
+		page, _ := btree.pager.GetPage(pageID)
+		node, _ := deserializeNode(page)
+		if node.nextLeaf == 0 {
+			break
+		}
+		pageID = node.nextLeaf
+		leaves++
+	}
+	if leaves > n/100 {
+		t.Errorf("expected fewer than %d leaves for %d small keys, got %d", n/100, n, leaves)
+	}
+}
+
+func TestBTreePrefixCompression(t *testing.T) {
+	btree, pager, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	// Long keys that differ only at the end: without compression a leaf
+	// holds 4080 / (2 + 58 + 8) = 60 of them
+	prefix := "https://www.example.com/products/category/item?id="
+	n := 2000
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("%s%08d", prefix, i*7919%n))
+		if err := btree.Insert(key, uint64(i)); err != nil {
+			t.Fatalf("Insert %d failed: %v", i, err)
+		}
+	}
+
+	// A key sharing nothing with the rest lands in a leaf whose keys
+	// share a long prefix; splitting it must still leave both halves
+	// room to store it
+	if err := btree.Insert([]byte("zzz"), 1); err != nil {
+		t.Fatalf("Insert of unrelated key failed: %v", err)
+	}
+
+	r := NewIntegrityReport()
+	if entries := btree.CheckIntegrity(r, "test"); entries != n+1 || len(r.Problems) != 0 {
+		t.Fatalf("expected a healthy tree of %d keys, got %d keys and %v", n+1, entries, r.Problems)
+	}
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("%s%08d", prefix, i*7919%n))
+		if value, found, err := btree.Search(key); err != nil || !found || value != uint64(i) {
+			t.Fatalf("%s: got %d (found=%v, err=%v)", key, value, found, err)
+		}
+	}
+
+	pageID, err := btree.FirstLeaf()
+	if err != nil {
+		t.Fatalf("FirstLeaf failed: %v", err)
+	}
+	leaves := 1
+	for {
+		page, _ := pager.GetPage(pageID)
+		node, _ := deserializeNode(page)
+		if node.nextLeaf == 0 {
+			break
+		}
+		pageID = node.nextLeaf
+		leaves++
+	}
+	if leaves > n/100 {
+		t.Errorf("expected fewer than %d leaves with compressed keys, got %d", n/100, leaves)
+	}
+}
+
+func TestBTreeReadsUncompressedNodes(t *testing.T) {
+	// A leaf as written before prefix compression: flags 1, two keys
+	// stored in full
+	data := []byte{
+		0x01, 0x02, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x02, 0x00, 'i', 'd', 0x04, 0x00, 'n', 'a', 'm', 'e',
+		0x01, 0, 0, 0, 0, 0, 0, 0,
+		0x02, 0, 0, 0, 0, 0, 0, 0,
+	}
+	page := NewPage(1, PageTypeBTreeLeaf)
+	if err := page.SetData(data); err != nil {
+		t.Fatalf("SetData failed: %v", err)
+	}
+
+	node, err := deserializeNode(page)
+	if err != nil {
+		t.Fatalf("deserializeNode failed: %v", err)
+	}
+	if !node.isLeaf || node.numKeys != 2 || string(node.keys[0]) != "id" || string(node.keys[1]) != "name" ||
+		node.values[0] != 1 || node.values[1] != 2 {
+		t.Errorf("unexpected node: leaf=%v keys=%q values=%v", node.isLeaf, node.keys, node.values)
+	}
+}
@@ -0,0 +1,83 @@
+// Package storage - Page codecs
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A page codec transforms each page on its way to and from storage, below
+// everything else in the engine: the cache, the B-trees and the tables
+// only ever see plain pages, and only the bytes in the database file and
+// the write-ahead log are encoded.
+//
+//   Page --Serialize--> 4096 bytes --Encode--> stored bytes --> file/WAL
+//   Page <-Deserialize- 4096 bytes <-Decode--- stored bytes <-- file/WAL
+//
+// Each page keeps a fixed-size slot in the file, so page N still starts
+// at a known offset: N * (PageSize + Overhead). A codec that adds bytes,
+// such as an authentication tag, makes every slot that much bigger.
+//
+// The codec must be given every time the database is opened; the file
+// doesn't record which one was used.
+
+package storage
+
+import "fmt"
+
+// PageCodec encodes pages before they are written to storage and decodes
+// them when they are read back.
+type PageCodec interface {
+	// Encode transforms a serialized page (PageSize bytes). The result
+	// may be at most PageSize+Overhead() bytes.
+	Encode(pageID uint32, page []byte) ([]byte, error)
+
+	// Decode reverses Encode, given the page's whole slot, and returns
+	// the PageSize bytes of the serialized page.
+	Decode(pageID uint32, stored []byte) ([]byte, error)
+
+	// Overhead is how many bytes the codec may add to a page.
+	Overhead() int
+}
+
+// WithCodec makes the pager encode pages with codec before writing them
+// to the database file or the write-ahead log, and decode them on reading.
+func WithCodec(codec PageCodec) PagerOption {
+	return func(p *Pager) {
+		p.codec = codec
+	}
+}
+
+// Codec returns the pager's page codec, or nil if pages are stored as is.
+func (p *Pager) Codec() PageCodec {
+	return p.codec
+}
+
+// slotSize returns the space each page takes in storage with codec.
+func slotSize(codec PageCodec) int64 {
+	if codec == nil {
+		return PageSize
+	}
+	return PageSize + int64(codec.Overhead())
+}
+
+// encodePage serializes a page and encodes it with codec, if any.
+func encodePage(codec PageCodec, page *Page) ([]byte, error) {
+	data := page.Serialize()
+	if codec == nil {
+		return data, nil
+	}
+	encoded, err := codec.Encode(page.ID(), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode page %d: %w", page.ID(), err)
+	}
+	return encoded, nil
+}
+
+// decodePage decodes a page's slot with codec, if any, and deserializes it.
+func decodePage(codec PageCodec, pageID uint32, stored []byte) (*Page, error) {
+	if codec == nil {
+		return Deserialize(stored)
+	}
+	data, err := codec.Decode(pageID, stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page %d: %w", pageID, err)
+	}
+	return Deserialize(data)
+}
@@ -0,0 +1,391 @@
+// Package storage - Page compression
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Pages are rarely full of useful bytes: a data page has free space at its
+// end, rows of text repeat words and column values, and B-tree leaves
+// repeat key prefixes. Compressing each page as it's written, and
+// decompressing it as it's read, trades some CPU for a smaller file and
+// fewer bytes to read from disk.
+//
+// Compressing the page is the easy part (DEFLATE at its fastest setting
+// here; engines usually pick LZ4, Snappy or Zstandard, which are faster
+// still). The hard part is storing pages of different sizes. In a file of
+// fixed 4 KB slots a page compressed to 900 bytes still takes 4 KB, so
+// compressed databases use a different file layout, a sequence of
+// variable-sized records:
+//
+//   +--------+--------------------+--------------------+----
+//   | header | page 0, 900 bytes  | page 1, 2300 bytes | ...
+//   +--------+--------------------+--------------------+----
+//
+// Each record holds one page and has room to spare (its size is rounded
+// up to 256 bytes), so a page that grows a little is rewritten in place.
+// A page that outgrows its record is appended at the end of the file
+// instead, and the old record is left as dead space. Because a new record
+// always comes after the one it replaces, opening the file just reads
+// the records in order and keeps the last one for each page. VACUUM
+// removes the dead space by rewriting the records into a new file.
+//
+// InnoDB's compressed tables and the "page maps" of other engines solve
+// the same problem; they also reuse dead space instead of waiting for a
+// rebuild. Compression is chosen when a database is created and is
+// recorded in its header, so the file is opened the right way after that.
+
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// WithCompression makes a newly created database compress its pages. An
+// existing database keeps the layout it was created with.
+func WithCompression() PagerOption {
+	return func(p *Pager) {
+		p.compress = true
+	}
+}
+
+// openCompressed switches the pager to the compressed file layout if the
+// database file uses it, or is new and WithCompression was given. It
+// returns the size of the file as the pager sees it.
+func (p *Pager) openCompressed(file *os.File, size int64) (int64, error) {
+	compressed, err := isExtentFile(file, size)
+	if err != nil {
+		return 0, err
+	}
+	if !compressed && !(p.compress && size == 0) {
+		return size, nil
+	}
+	if p.codec != nil {
+		return 0, errors.New("compression can't be combined with another page codec")
+	}
+
+	p.codec = CompressionCodec{}
+	f, err := openExtentFile(file, p.filePath, size, slotSize(p.codec))
+	if err != nil {
+		return 0, err
+	}
+	p.file = f
+	p.useMmap = false // the file holds compressed records, not pages
+	return f.size(), nil
+}
+
+// Compressed reports whether the database's pages are compressed.
+func (p *Pager) Compressed() bool {
+	_, ok := p.file.(*extentFile)
+	return ok
+}
+
+// ----------------------------------------------------------------------------
+// Codec
+// ----------------------------------------------------------------------------
+
+const (
+	// compressionRaw and compressionDeflate start an encoded page: it's
+	// stored as is (it didn't compress), or deflated.
+	compressionRaw     = 0
+	compressionDeflate = 1
+
+	// compressionHeaderSize is the method byte and the deflated length.
+	compressionHeaderSize = 3
+)
+
+// CompressionCodec is a PageCodec that compresses pages with DEFLATE.
+type CompressionCodec struct{}
+
+// Overhead returns the method byte added to a page that doesn't compress.
+func (CompressionCodec) Overhead() int {
+	return 1
+}
+
+// Encode compresses a page, or stores it as is if that's no smaller.
+func (CompressionCodec) Encode(pageID uint32, page []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, compressionHeaderSize))
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(page); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if buf.Len() > len(page) {
+		return append([]byte{compressionRaw}, page...), nil
+	}
+	out := buf.Bytes()
+	out[0] = compressionDeflate
+	binary.LittleEndian.PutUint16(out[1:3], uint16(buf.Len()-compressionHeaderSize))
+	return out, nil
+}
+
+// Decode decompresses a page. Bytes after the encoded page are ignored.
+func (CompressionCodec) Decode(pageID uint32, stored []byte) ([]byte, error) {
+	if len(stored) == 0 {
+		return nil, errors.New("empty compressed page")
+	}
+	switch stored[0] {
+	case compressionRaw:
+		if len(stored) < 1+PageSize {
+			return nil, fmt.Errorf("uncompressed page has %d bytes", len(stored)-1)
+		}
+		return stored[1 : 1+PageSize], nil
+	case compressionDeflate:
+		if len(stored) < compressionHeaderSize {
+			return nil, errors.New("truncated compressed page")
+		}
+		length := int(binary.LittleEndian.Uint16(stored[1:3]))
+		if compressionHeaderSize+length > len(stored) {
+			return nil, fmt.Errorf("compressed page length %d is past its end", length)
+		}
+		page := make([]byte, PageSize)
+		r := flate.NewReader(bytes.NewReader(stored[compressionHeaderSize : compressionHeaderSize+length]))
+		defer r.Close()
+		if _, err := io.ReadFull(r, page); err != nil {
+			return nil, fmt.Errorf("failed to decompress page: %w", err)
+		}
+		return page, nil
+	default:
+		return nil, fmt.Errorf("unknown compression method %d", stored[0])
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Variable-sized page records
+// ----------------------------------------------------------------------------
+
+const (
+	// extentMagic identifies a compressed database file ("CDBZ").
+	extentMagic = 0x5A424443
+
+	// extentVersion is the format version written to the header.
+	extentVersion = 1
+
+	// extentFileHeaderSize is the size of the file header: magic,
+	// version, reserved.
+	extentFileHeaderSize = 16
+
+	// extentHeaderSize is the size of each record's header: page ID,
+	// length of the stored page, and the record's capacity.
+	extentHeaderSize = 8
+
+	// extentUnit is what record capacities are rounded up to.
+	extentUnit = 256
+)
+
+// extent is where a page's record is in the file.
+type extent struct {
+	offset   int64 // of the record header
+	length   int   // of the stored page
+	capacity int   // room for the stored page
+}
+
+// extentFile is a dbFile that stores each page in a variable-sized record.
+// The pager addresses it as fixed slots of slot bytes; each write holds
+// one page, and may be shorter than a slot.
+type extentFile struct {
+	file    *os.File
+	path    string
+	slot    int64
+	extents map[uint32]extent
+	end     int64  // where the next record is appended
+	pages   uint32 // highest page written, plus one
+}
+
+// isExtentFile reports whether file starts with a compressed database
+// header.
+func isExtentFile(file *os.File, size int64) (bool, error) {
+	if size < extentFileHeaderSize {
+		return false, nil
+	}
+	var magic [4]byte
+	if _, err := file.ReadAt(magic[:], 0); err != nil {
+		return false, fmt.Errorf("failed to read database header: %w", err)
+	}
+	return binary.LittleEndian.Uint32(magic[:]) == extentMagic, nil
+}
+
+// openExtentFile reads the records of a compressed database file, or
+// writes the header of a new one.
+func openExtentFile(file *os.File, path string, size, slot int64) (*extentFile, error) {
+	f := &extentFile{file: file, path: path, slot: slot, extents: make(map[uint32]extent), end: extentFileHeaderSize}
+	if size == 0 {
+		if _, err := file.WriteAt(extentHeader(), 0); err != nil {
+			return nil, fmt.Errorf("failed to write database header: %w", err)
+		}
+		return f, nil
+	}
+
+	var header [extentHeaderSize]byte
+	for f.end+extentHeaderSize <= size {
+		if _, err := file.ReadAt(header[:], f.end); err != nil {
+			return nil, fmt.Errorf("failed to read page record: %w", err)
+		}
+		pageID, ext := decodeExtentHeader(header[:], f.end)
+		if ext.capacity == 0 || ext.length > ext.capacity || int64(ext.length) > slot ||
+			f.end+extentHeaderSize+int64(ext.capacity) > size {
+			break // torn write at the end of the file
+		}
+		f.extents[pageID] = ext // later records replace earlier ones
+		f.pages = max(f.pages, pageID+1)
+		f.end += extentHeaderSize + int64(ext.capacity)
+	}
+	return f, nil
+}
+
+// extentHeader returns a compressed database file header.
+func extentHeader() []byte {
+	header := make([]byte, extentFileHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], extentMagic)
+	binary.LittleEndian.PutUint32(header[4:8], extentVersion)
+	return header
+}
+
+// decodeExtentHeader reads the record header at offset.
+func decodeExtentHeader(header []byte, offset int64) (uint32, extent) {
+	return binary.LittleEndian.Uint32(header[0:4]), extent{
+		offset:   offset,
+		length:   int(binary.LittleEndian.Uint16(header[4:6])),
+		capacity: int(binary.LittleEndian.Uint16(header[6:8])),
+	}
+}
+
+// record encodes a page's record with its header.
+func (e extent) record(pageID uint32, data []byte) []byte {
+	buf := make([]byte, extentHeaderSize+e.capacity)
+	binary.LittleEndian.PutUint32(buf[0:4], pageID)
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(e.length))
+	binary.LittleEndian.PutUint16(buf[6:8], uint16(e.capacity))
+	copy(buf[extentHeaderSize:], data)
+	return buf
+}
+
+// size returns the size of the file as the pager sees it: a slot for each
+// page.
+func (f *extentFile) size() int64 {
+	return int64(f.pages) * f.slot
+}
+
+// pageAt returns the page ID a slot offset refers to.
+func (f *extentFile) pageAt(offset int64) (uint32, error) {
+	if offset%f.slot != 0 {
+		return 0, fmt.Errorf("offset %d is not the start of a page", offset)
+	}
+	return uint32(offset / f.slot), nil
+}
+
+// ReadAt reads the page stored for the slot at offset into buf, padded
+// with zeros.
+func (f *extentFile) ReadAt(buf []byte, offset int64) (int, error) {
+	pageID, err := f.pageAt(offset)
+	if err != nil {
+		return 0, err
+	}
+	if pageID >= f.pages {
+		return 0, io.EOF
+	}
+	clear(buf)
+	if ext, ok := f.extents[pageID]; ok {
+		if _, err := f.file.ReadAt(buf[:min(len(buf), ext.length)], ext.offset+extentHeaderSize); err != nil {
+			return 0, err
+		}
+	}
+	return len(buf), nil
+}
+
+// WriteAt stores one page, in place if it fits in its record.
+func (f *extentFile) WriteAt(data []byte, offset int64) (int, error) {
+	pageID, err := f.pageAt(offset)
+	if err != nil {
+		return 0, err
+	}
+	if int64(len(data)) > f.slot {
+		return 0, fmt.Errorf("page %d is %d bytes, more than a slot", pageID, len(data))
+	}
+
+	ext, ok := f.extents[pageID]
+	end := f.end
+	if !ok || len(data) > ext.capacity {
+		ext = extent{offset: f.end, capacity: (len(data) + extentUnit - 1) / extentUnit * extentUnit}
+		end += extentHeaderSize + int64(ext.capacity)
+	}
+	ext.length = len(data)
+	// A record written in part (the disk is full) is overwritten by the
+	// next one appended
+	if _, err := f.file.WriteAt(ext.record(pageID, data), ext.offset); err != nil {
+		return 0, err
+	}
+	f.end = end
+	f.extents[pageID] = ext
+	f.pages = max(f.pages, pageID+1)
+	return len(data), nil
+}
+
+// Truncate drops the pages past size and rewrites the file without them
+// or the dead space left by moved records. The new file is written next
+// to the old one and renamed over it, so a crash leaves one or the other.
+func (f *extentFile) Truncate(size int64) error {
+	pages := uint32(size / f.slot)
+
+	pageIDs := make([]uint32, 0, len(f.extents))
+	for pageID := range f.extents {
+		if pageID < pages {
+			pageIDs = append(pageIDs, pageID)
+		}
+	}
+	sort.Slice(pageIDs, func(i, j int) bool { return pageIDs[i] < pageIDs[j] })
+
+	tmpPath := f.path + "-compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	compacted := &extentFile{file: tmp, path: f.path, slot: f.slot, extents: make(map[uint32]extent), end: extentFileHeaderSize}
+	err = func() error {
+		// The new file replaces the one locked, so it needs the lock too
+		if err := lockFile(tmp, true); err != nil {
+			return err
+		}
+		if _, err := tmp.WriteAt(extentHeader(), 0); err != nil {
+			return err
+		}
+		buf := make([]byte, f.slot)
+		for _, pageID := range pageIDs {
+			ext := f.extents[pageID]
+			if _, err := f.file.ReadAt(buf[:ext.length], ext.offset+extentHeaderSize); err != nil {
+				return err
+			}
+			if _, err := compacted.WriteAt(buf[:ext.length], int64(pageID)*f.slot); err != nil {
+				return err
+			}
+		}
+		if err := tmp.Sync(); err != nil {
+			return err
+		}
+		return os.Rename(tmpPath, f.path)
+	}()
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact database file: %w", err)
+	}
+
+	f.file.Close()
+	f.file, f.extents, f.end = tmp, compacted.extents, compacted.end
+	f.pages = pages
+	return nil
+}
+
+func (f *extentFile) Sync() error  { return f.file.Sync() }
+func (f *extentFile) Close() error { return f.file.Close() }
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fillTextPages allocates count data pages full of repetitive text and
+// returns what was written to each.
+func fillTextPages(t *testing.T, pager *Pager, count int) [][]byte {
+	t.Helper()
+	var written [][]byte
+	for i := 0; i < count; i++ {
+		page, err := pager.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage failed: %v", err)
+		}
+		text := []byte(fmt.Sprintf("page %d: ", i))
+		for len(text) < MaxDataSize-100 {
+			text = append(text, "the quick brown fox jumps over the lazy dog "...)
+		}
+		if err := page.SetData(text); err != nil {
+			t.Fatalf("SetData failed: %v", err)
+		}
+		written = append(written, text)
+	}
+	return written
+}
+
+func checkTextPages(t *testing.T, pager *Pager, written [][]byte) {
+	t.Helper()
+	for i, want := range written {
+		page, err := pager.GetPage(uint32(i))
+		if err != nil {
+			t.Fatalf("GetPage %d failed: %v", i, err)
+		}
+		if got := page.GetData()[:len(want)]; !bytes.Equal(got, want) {
+			t.Errorf("page %d: data differs after reopening", i)
+		}
+	}
+}
+
+func TestCompressedPager(t *testing.T) {
+	for _, opts := range [][]PagerOption{
+		{WithCompression()},
+		{WithCompression(), WithWAL()},
+		{WithCompression(), WithMaxCacheSize(4)},
+	} {
+		testFile := filepath.Join(t.TempDir(), "compressed.db")
+		pager, err := NewPager(testFile, opts...)
+		if err != nil {
+			t.Fatalf("NewPager failed: %v", err)
+		}
+		if !pager.Compressed() {
+			t.Fatal("expected a new database to be compressed")
+		}
+		written := fillTextPages(t, pager, 50)
+		if err := pager.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		stat, _ := os.Stat(testFile)
+		if stat.Size() > 50*PageSize/4 {
+			t.Errorf("expected text pages to compress to under a quarter, file is %d bytes", stat.Size())
+		}
+
+		// Compression is remembered by the file
+		pager, err = NewPager(testFile)
+		if err != nil {
+			t.Fatalf("reopening failed: %v", err)
+		}
+		if !pager.Compressed() || pager.PageCount() != 50 {
+			t.Errorf("expected a compressed database of 50 pages, got compressed=%v, %d pages",
+				pager.Compressed(), pager.PageCount())
+		}
+		checkTextPages(t, pager, written)
+		pager.Close()
+	}
+}
+
+func TestCompressedPagerRewrites(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "compressed.db")
+	pager, _ := NewPager(testFile, WithCompression())
+	written := fillTextPages(t, pager, 3)
+	pager.FlushAll()
+
+	// Random bytes don't compress: page 1 outgrows its record and moves
+	// to the end of the file
+	noise := make([]byte, MaxDataSize)
+	rand.Read(noise)
+	page, _ := pager.GetPage(1)
+	page.SetData(noise)
+	written[1] = noise
+	pager.Close()
+
+	pager, _ = NewPager(testFile)
+	checkTextPages(t, pager, written)
+
+	// Shrinking the database rewrites the file without dead space
+	before, _ := os.Stat(testFile)
+	src, _ := NewPager(MemoryPath)
+	defer src.Close()
+	written = fillTextPages(t, src, 2)
+	if err := pager.CopyFrom(src); err != nil {
+		t.Fatalf("CopyFrom failed: %v", err)
+	}
+	pager.Close()
+
+	after, _ := os.Stat(testFile)
+	if after.Size() >= before.Size() {
+		t.Errorf("expected the file to shrink from %d bytes, got %d", before.Size(), after.Size())
+	}
+	if _, err := os.Stat(testFile + "-compact"); !os.IsNotExist(err) {
+		t.Error("expected no compaction file to be left behind")
+	}
+	pager, _ = NewPager(testFile)
+	defer pager.Close()
+	if pager.PageCount() != 2 {
+		t.Errorf("expected 2 pages, got %d", pager.PageCount())
+	}
+	checkTextPages(t, pager, written)
+}
+
+func TestCompressionOnlyForNewDatabases(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "plain.db")
+	pager, _ := NewPager(testFile)
+	written := fillTextPages(t, pager, 2)
+	pager.Close()
+
+	pager, err := NewPager(testFile, WithCompression())
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+	if pager.Compressed() {
+		t.Error("expected an existing database to keep its layout")
+	}
+	checkTextPages(t, pager, written)
+
+	codec, _ := NewEncryptionCodec(make([]byte, 32))
+	if _, err := NewPager(filepath.Join(t.TempDir(), "both.db"), WithCompression(), WithCodec(codec)); err == nil {
+		t.Error("expected an error combining compression and encryption")
+	}
+}
@@ -0,0 +1,169 @@
+// Package storage - Running out of disk space
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A write can fail half done. When the disk fills up, write(2) either
+// fails with ENOSPC or writes fewer bytes than asked (a "short write"),
+// and the file is left with part of a page, or the first pages of a
+// flush and not the rest. A quota running out (EDQUOT) looks the same.
+// The pager reports all of these as ErrDiskFull, wrapping the system's
+// error, so that callers can tell "free some space and try again" from
+// a broken disk:
+//
+//   if errors.Is(err, storage.ErrDiskFull) { ... }
+//
+// What is left behind depends on how pages reach the file:
+//
+//   - With a write-ahead log, nothing is lost. A frame written in part is
+//     cut off the end of the log, so the log still ends with whole
+//     frames, and the database file isn't touched until a checkpoint.
+//     A commit that fails leaves no commit frame, and the frames written
+//     since the last one can be dropped (see WAL.Rollback), going back
+//     to the last commit as recovery after a crash would. Pager.Discard
+//     does that for the whole pager, forgetting the cached pages too. A
+//     checkpoint that fails just leaves the log in place, to be tried
+//     again: the log still has every page.
+//
+//   - Without the log, pages are written in place, so a failed flush
+//     leaves some new pages in the file and some old. A transaction can
+//     still be undone, since it kept a copy of each page it changed: the
+//     copies are written back (overwriting a page needs no new space)
+//     and the file is cut back to its size at BEGIN. Changes made outside
+//     a transaction have no copies; they stay in the cache, dirty, to be
+//     written by the next flush once there is space.
+//
+// PostgreSQL stops with a PANIC if it can't write its log, and replays it
+// at restart; SQLite returns SQLITE_FULL and rolls back the statement,
+// using its rollback journal or log as here.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+)
+
+// ErrDiskFull is wrapped by the errors of writes that failed because the
+// disk, or the user's quota, is full.
+var ErrDiskFull = errors.New("disk full")
+
+// diskError wraps err with ErrDiskFull if it says the disk is full.
+func diskError(err error) error {
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || errors.Is(err, io.ErrShortWrite) {
+		return fmt.Errorf("%w: %w", ErrDiskFull, err)
+	}
+	return err
+}
+
+// writeFull writes all of data at offset, reporting a short write as an
+// error. Errors saying the disk is full wrap ErrDiskFull.
+func writeFull(w io.WriterAt, data []byte, offset int64) error {
+	n, err := w.WriteAt(data, offset)
+	if err == nil && n < len(data) {
+		err = fmt.Errorf("wrote %d of %d bytes: %w", n, len(data), io.ErrShortWrite)
+	}
+	if err != nil {
+		return diskError(err)
+	}
+	return nil
+}
+
+// Discard throws away every change made since the last flush: the dirty
+// pages in the cache, the frames written to the log for pages evicted
+// since, and the pages allocated since. After a flush fails with
+// ErrDiskFull it takes the pager back to the last commit; anything that
+// remembers page IDs (tables, the catalog) must then be reloaded.
+//
+// It needs a write-ahead log, since without one the pages evicted since
+// the flush have already overwritten the file, and it can't run inside a
+// transaction, which Rollback undoes instead.
+func (p *Pager) Discard() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tx != nil {
+		return errors.New("cannot discard changes inside a transaction; roll it back")
+	}
+	if p.wal == nil {
+		return errors.New("changes written to the database file can't be discarded without a write-ahead log")
+	}
+	for pageID, pins := range p.pins {
+		if pins > 0 {
+			return fmt.Errorf("cannot discard changes while page %d is pinned", pageID)
+		}
+	}
+
+	if err := p.wal.Rollback(); err != nil {
+		return err
+	}
+	for pageID := range p.cache {
+		p.evictor.remove(pageID)
+		delete(p.cache, pageID)
+	}
+	p.pageCount = p.flushed.pageCount
+	p.freeHead, p.freeCount = p.flushed.freeHead, p.flushed.freeCount
+	return nil
+}
+
+// markFlushedLocked records the page count and free list as those of the
+// last flush, for Discard. Caller must hold the lock.
+func (p *Pager) markFlushedLocked() {
+	p.flushed.pageCount = p.pageCount
+	p.flushed.freeHead, p.flushed.freeCount = p.freeHead, p.freeCount
+}
+
+// abortCommitLocked undoes a transaction whose Commit failed to write its
+// pages, so that the cache, and as far as possible the files, are as they
+// were at Begin. It returns the commit's error, saying whether the undo
+// worked. Caller must hold the lock.
+func (p *Pager) abortCommitLocked(tx *pagerTx, err error) error {
+	touched := make(map[uint32]bool)
+	for _, frame := range tx.frames {
+		for pageID := range frame.beforeImages {
+			touched[pageID] = true
+		}
+	}
+
+	p.tx = tx
+	undoErr := p.rollbackToLocked(0)
+	p.tx = nil
+	if undoErr == nil && p.wal != nil {
+		// No commit frame was written, so the log only has to lose any
+		// frames after the last one
+		undoErr = p.wal.Rollback()
+	} else if undoErr == nil {
+		// Some pages may have been written in place: write back what
+		// they held at Begin, and cut off the pages added since
+		for pageID := range touched {
+			if page, ok := p.cache[pageID]; ok {
+				page.dirty = true
+			}
+		}
+		undoErr = p.flushAllLocked()
+		if undoErr == nil {
+			undoErr = p.truncateLocked(p.pageCount)
+		}
+	}
+	if undoErr != nil {
+		return fmt.Errorf("%w; undoing the transaction failed too, so the database file may be inconsistent: %v", err, undoErr)
+	}
+	return fmt.Errorf("%w; the transaction was rolled back", err)
+}
+
+// truncateLocked cuts the database file back to pageCount pages. Caller
+// must hold the lock.
+func (p *Pager) truncateLocked(pageCount uint32) error {
+	if err := p.unmapLocked(); err != nil {
+		return fmt.Errorf("failed to unmap database file: %w", err)
+	}
+	if err := p.file.Truncate(int64(pageCount) * slotSize(p.codec)); err != nil {
+		return fmt.Errorf("failed to truncate database file: %w", err)
+	}
+	if p.useMmap {
+		p.remapLocked()
+	}
+	p.unsynced = true
+	return p.syncLocked()
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// fullDisk is a file on a disk with no room to grow past limit bytes.
+type fullDisk struct {
+	dbFile
+	limit int64
+}
+
+func (f *fullDisk) WriteAt(data []byte, offset int64) (int, error) {
+	if offset+int64(len(data)) <= f.limit {
+		return f.dbFile.WriteAt(data, offset)
+	}
+	n := 0
+	if offset < f.limit {
+		n, _ = f.dbFile.WriteAt(data[:f.limit-offset], offset)
+	}
+	return n, syscall.ENOSPC
+}
+
+// shortWriter writes only half of what it is given, without an error.
+type shortWriter struct{}
+
+func (shortWriter) WriteAt(data []byte, offset int64) (int, error) {
+	return len(data) / 2, nil
+}
+
+// pageText returns the first byte of a page's data, as a string.
+func pageText(t *testing.T, p *Pager, pageID uint32) string {
+	t.Helper()
+	page, err := p.GetPage(pageID)
+	if err != nil {
+		t.Fatalf("GetPage(%d) failed: %v", pageID, err)
+	}
+	return string(page.GetData()[:1])
+}
+
+// setPageText overwrites the start of a page's data.
+func setPageText(t *testing.T, p *Pager, pageID uint32, text string) {
+	t.Helper()
+	page, err := p.GetPage(pageID)
+	if err != nil {
+		t.Fatalf("GetPage(%d) failed: %v", pageID, err)
+	}
+	page.SetData([]byte(text))
+}
+
+func TestWriteFull(t *testing.T) {
+	err := writeFull(shortWriter{}, make([]byte, 10), 0)
+	if !errors.Is(err, ErrDiskFull) || !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("expected a short write to be ErrDiskFull, got %v", err)
+	}
+	err = writeFull(&fullDisk{dbFile: &memFile{}, limit: 4}, make([]byte, 10), 0)
+	if !errors.Is(err, ErrDiskFull) || !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("expected ENOSPC to be ErrDiskFull, got %v", err)
+	}
+	if err := diskError(os.ErrPermission); errors.Is(err, ErrDiskFull) {
+		t.Errorf("expected other errors left alone, got %v", err)
+	}
+}
+
+func TestPagerCommitDiskFullWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full.db")
+	pager, err := NewPager(path, WithWAL())
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	page, _ := pager.AllocatePage(PageTypeData)
+	page.SetData([]byte("a"))
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	walSize := fileSize(t, WALPath(path))
+
+	// The log has room for one frame of the two the commit writes
+	pager.Begin()
+	setPageText(t, pager, 0, "b")
+	added, _ := pager.AllocatePage(PageTypeData)
+	added.SetData([]byte("c"))
+	pager.wal.file = &fullDisk{dbFile: pager.wal.file, limit: walSize + pager.wal.frameSize}
+	if err := pager.Commit(); !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("expected Commit to fail with ErrDiskFull, got %v", err)
+	}
+
+	// The transaction is undone, and the log ends with whole frames
+	if pager.InTransaction() || pager.PageCount() != 1 || pageText(t, pager, 0) != "a" {
+		t.Errorf("expected the transaction rolled back, got %d pages, page 0 %q", pager.PageCount(), pageText(t, pager, 0))
+	}
+	if size := fileSize(t, WALPath(path)); size != walSize {
+		t.Errorf("expected the log cut back to %d bytes, got %d", walSize, size)
+	}
+
+	// Once there is room, the database carries on from the last commit
+	pager.wal.file = pager.wal.file.(*fullDisk).dbFile
+	setPageText(t, pager, 0, "d")
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	crash(pager)
+	pager, err = NewPager(path, WithWAL())
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer pager.Close()
+	if pager.PageCount() != 1 || pageText(t, pager, 0) != "d" {
+		t.Errorf("expected the later commit only, got %d pages, page 0 %q", pager.PageCount(), pageText(t, pager, 0))
+	}
+}
+
+func TestPagerCommitDiskFullNoWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full.db")
+	pager, err := NewPager(path)
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	for _, text := range []string{"a", "b"} {
+		page, _ := pager.AllocatePage(PageTypeData)
+		page.SetData([]byte(text))
+	}
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+
+	// The pages in the file are overwritten, and then the file can't grow
+	pager.Begin()
+	setPageText(t, pager, 0, "x")
+	setPageText(t, pager, 1, "y")
+	added, _ := pager.AllocatePage(PageTypeData)
+	added.SetData([]byte("z"))
+	pager.file = &fullDisk{dbFile: pager.file, limit: fileSize(t, path)}
+	if err := pager.Commit(); !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("expected Commit to fail with ErrDiskFull, got %v", err)
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The pages overwritten were written back
+	pager, err = NewPager(path)
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer pager.Close()
+	if pager.PageCount() != 2 || pageText(t, pager, 0) != "a" || pageText(t, pager, 1) != "b" {
+		t.Errorf("expected the pages from before the transaction, got %d pages, %q and %q",
+			pager.PageCount(), pageText(t, pager, 0), pageText(t, pager, 1))
+	}
+}
+
+func TestPagerDiscard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "discard.db")
+	pager, err := NewPager(path, WithWAL(), WithMaxCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+	page, _ := pager.AllocatePage(PageTypeData)
+	page.SetData([]byte("a"))
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	head, count := pager.FreeList()
+	frames := pager.WALFrames()
+
+	// Enough new pages that some are evicted to the log
+	setPageText(t, pager, 0, "b")
+	for i := 0; i < 5; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		page.SetData([]byte("c"))
+	}
+	if pager.WALFrames() == frames {
+		t.Fatal("expected evictions to write frames")
+	}
+	if err := pager.Discard(); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	if pager.PageCount() != 1 || pageText(t, pager, 0) != "a" || pager.WALFrames() != frames {
+		t.Errorf("expected the last flush back, got %d pages, page 0 %q, %d frames",
+			pager.PageCount(), pageText(t, pager, 0), pager.WALFrames())
+	}
+	if h, c := pager.FreeList(); h != head || c != count {
+		t.Errorf("expected the free list %d/%d, got %d/%d", head, count, h, c)
+	}
+
+	memory, _ := NewPager(MemoryPath)
+	defer memory.Close()
+	if err := memory.Discard(); err == nil {
+		t.Error("expected Discard without a log to fail")
+	}
+}
@@ -0,0 +1,93 @@
+// Package storage - Page encryption
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Encryption at rest protects a database file that ends up somewhere it
+// shouldn't: a stolen laptop, a backup on shared storage, a disk sent for
+// repair. Each page is encrypted with AES-256 in GCM mode as it's written
+// and decrypted as it's read, so the data is only ever in plain text in
+// memory. SQLCipher does the same for SQLite.
+//
+// GCM is "authenticated" encryption. Besides the ciphertext it produces a
+// 16-byte tag, and decryption fails unless the tag matches, so a page
+// that was corrupted or deliberately altered is rejected instead of
+// decrypting to garbage. The page ID is authenticated too (as "additional
+// data"), so an attacker can't swap two encrypted pages either.
+//
+// Every encryption needs a nonce that is never reused with the same key,
+// or GCM's guarantees fall apart. A page is rewritten many times, so each
+// write picks a fresh random 12-byte nonce and stores it with the page:
+//
+//   slot (4124 bytes):  nonce (12) | encrypted page (4096) | tag (16)
+//
+// The key itself is not stored anywhere. Opening the database with the
+// wrong key fails on the first page read. Here the key is given as raw
+// bytes; a real system would derive it from a passphrase with a slow key
+// derivation function (PBKDF2, scrypt, Argon2) and a salt stored in the
+// file header.
+
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrDecryptionFailed is returned when a page can't be decrypted: the key
+// is wrong, or the page was changed since it was written.
+var ErrDecryptionFailed = errors.New("page decryption failed (wrong key or corrupted page)")
+
+// EncryptionCodec is a PageCodec that encrypts pages with AES-GCM.
+type EncryptionCodec struct {
+	aead cipher.AEAD
+}
+
+// NewEncryptionCodec creates a codec that encrypts with key, which must be
+// 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256).
+func NewEncryptionCodec(key []byte) (*EncryptionCodec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptionCodec{aead: aead}, nil
+}
+
+// Overhead returns the size of the nonce and tag stored with each page.
+func (c *EncryptionCodec) Overhead() int {
+	return c.aead.NonceSize() + c.aead.Overhead()
+}
+
+// Encode encrypts a page under a fresh random nonce.
+func (c *EncryptionCodec) Encode(pageID uint32, page []byte) ([]byte, error) {
+	out := make([]byte, c.aead.NonceSize(), PageSize+c.Overhead())
+	if _, err := rand.Read(out); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(out, out, page, pageIDData(pageID)), nil
+}
+
+// Decode decrypts a page and verifies that it hasn't been changed.
+func (c *EncryptionCodec) Decode(pageID uint32, stored []byte) ([]byte, error) {
+	if len(stored) != PageSize+c.Overhead() {
+		return nil, fmt.Errorf("encrypted page has %d bytes, expected %d", len(stored), PageSize+c.Overhead())
+	}
+	nonce, ciphertext := stored[:c.aead.NonceSize()], stored[c.aead.NonceSize():]
+	page, err := c.aead.Open(nil, nonce, ciphertext, pageIDData(pageID))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return page, nil
+}
+
+// pageIDData encodes a page ID as GCM additional data.
+func pageIDData(pageID uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, pageID)
+}
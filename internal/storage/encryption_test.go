@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testCodec(t *testing.T, key byte) *EncryptionCodec {
+	t.Helper()
+	codec, err := NewEncryptionCodec(bytes.Repeat([]byte{key}, 32))
+	if err != nil {
+		t.Fatalf("NewEncryptionCodec failed: %v", err)
+	}
+	return codec
+}
+
+func TestEncryptedPager(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "encrypted.db")
+	secret := []byte("the secret is out")
+
+	for _, opts := range [][]PagerOption{
+		{WithCodec(testCodec(t, 1))},
+		{WithCodec(testCodec(t, 1)), WithWAL()},
+		{WithCodec(testCodec(t, 1)), WithMmap()},
+	} {
+		os.Remove(testFile)
+		pager, err := NewPager(testFile, opts...)
+		if err != nil {
+			t.Fatalf("NewPager failed: %v", err)
+		}
+		var offsets []uint16
+		for i := 0; i < 3; i++ {
+			page, _ := pager.AllocatePage(PageTypeData)
+			offset, _ := page.WriteData(secret)
+			offsets = append(offsets, offset)
+		}
+		if err := pager.FlushAll(); err != nil {
+			t.Fatalf("FlushAll failed: %v", err)
+		}
+		if pager.wal != nil {
+			// Check the log before the checkpoint on close empties it
+			if wal, _ := os.ReadFile(WALPath(testFile)); bytes.Contains(wal, secret) {
+				t.Error("expected the WAL to hold no plain text")
+			}
+		}
+		if err := pager.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		data, _ := os.ReadFile(testFile)
+		if bytes.Contains(data, secret) {
+			t.Error("expected the database file to hold no plain text")
+		}
+		if len(data) != 3*(PageSize+28) {
+			t.Errorf("expected 3 slots of %d bytes, got %d bytes", PageSize+28, len(data))
+		}
+
+		pager, err = NewPager(testFile, opts...)
+		if err != nil {
+			t.Fatalf("reopening failed: %v", err)
+		}
+		for i, offset := range offsets {
+			page, err := pager.GetPage(uint32(i))
+			if err != nil {
+				t.Fatalf("GetPage %d failed: %v", i, err)
+			}
+			if got := page.ReadData(offset, uint16(len(secret))); !bytes.Equal(got, secret) {
+				t.Errorf("page %d: expected %q, got %q", i, secret, got)
+			}
+		}
+		pager.Close()
+	}
+}
+
+func TestEncryptedPagerRejectsWrongKeyAndTampering(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "encrypted.db")
+
+	pager, _ := NewPager(testFile, WithCodec(testCodec(t, 1)))
+	for i := 0; i < 2; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		page.WriteData([]byte("data"))
+	}
+	pager.Close()
+
+	pager, _ = NewPager(testFile, WithCodec(testCodec(t, 2)))
+	if _, err := pager.GetPage(0); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed with the wrong key, got %v", err)
+	}
+	pager.Close()
+
+	// Swap the two pages: each is intact, but in the wrong place
+	data, _ := os.ReadFile(testFile)
+	slot := PageSize + 28
+	swapped := append(append([]byte{}, data[slot:]...), data[:slot]...)
+	os.WriteFile(testFile, swapped, 0644)
+
+	pager, _ = NewPager(testFile, WithCodec(testCodec(t, 1)))
+	defer pager.Close()
+	if _, err := pager.GetPage(0); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed for a moved page, got %v", err)
+	}
+}
+
+func TestNewEncryptionCodecKeySize(t *testing.T) {
+	if _, err := NewEncryptionCodec(make([]byte, 10)); err == nil {
+		t.Error("expected an error for a 10-byte key")
+	}
+}
@@ -0,0 +1,377 @@
+// Package storage - Cache eviction policies
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// When the page cache is full, reading one more page means dropping one.
+// Which page to drop is the eviction policy, and the best choice depends
+// on the workload:
+//
+//   - LRU evicts the page used least recently. It's simple and good for
+//     most lookups, but a scan of a big table touches every page once and
+//     pushes the whole hot set (B-tree roots, small tables) out of the
+//     cache on its way through: "sequential flooding".
+//
+//   - CLOCK approximates LRU without reordering a list on every hit. Each
+//     page has a reference bit that a hit sets; a hand sweeps the pages in
+//     a circle, clearing set bits and evicting the first page whose bit is
+//     already clear. A hit is just setting a bit. PostgreSQL uses a
+//     variant with a usage count instead of a single bit.
+//
+//   - 2Q keeps pages seen only once in a small FIFO queue (a quarter of
+//     the cache), and only promotes a page to the main LRU list when it's
+//     asked for again after leaving that queue, which a short "ghost" list
+//     of recently evicted page IDs remembers. A scan's pages pass through
+//     the small queue and are evicted from it, leaving the main list, and
+//     the hot set in it, alone. This makes it the default here.
+//
+//   - LFU evicts the page used least often. It keeps hot pages through any
+//     scan, but a page that was popular once keeps its high count long
+//     after it stopped being used.
+//
+// Pages that can't be evicted right now (dirty pages in a transaction)
+// are skipped, so each policy yields its best candidate that is allowed.
+
+package storage
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// EvictionPolicy selects how the pager chooses which cached page to drop
+// when the cache is full.
+type EvictionPolicy int
+
+const (
+	// EvictionTwoQueue is the scan-resistant 2Q policy, the default.
+	EvictionTwoQueue EvictionPolicy = iota
+	// EvictionLRU evicts the least recently used page.
+	EvictionLRU
+	// EvictionClock is the CLOCK approximation of LRU.
+	EvictionClock
+	// EvictionLFU evicts the least frequently used page.
+	EvictionLFU
+)
+
+// evictionPolicyNames maps each policy to its name in ParseEvictionPolicy.
+var evictionPolicyNames = map[EvictionPolicy]string{
+	EvictionTwoQueue: "2q",
+	EvictionLRU:      "lru",
+	EvictionClock:    "clock",
+	EvictionLFU:      "lfu",
+}
+
+// String returns the policy's name.
+func (e EvictionPolicy) String() string {
+	if name, ok := evictionPolicyNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("EvictionPolicy(%d)", int(e))
+}
+
+// ParseEvictionPolicy returns the policy called name: "2q", "lru",
+// "clock" or "lfu".
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	for policy, policyName := range evictionPolicyNames {
+		if name == policyName {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown eviction policy %q (expected 2q, lru, clock or lfu)", name)
+}
+
+// WithEvictionPolicy sets the policy the pager uses to choose which page
+// to evict when the cache is full.
+func WithEvictionPolicy(policy EvictionPolicy) PagerOption {
+	return func(p *Pager) {
+		p.evictionPolicy = policy
+	}
+}
+
+// evictor tracks the pages in the cache for an eviction policy.
+type evictor interface {
+	// add records a page entering the cache.
+	add(pageID uint32)
+	// access records a cache hit on a page.
+	access(pageID uint32)
+	// victim returns the page to evict next, passing over pages for which
+	// skip returns true. It returns false if every page is skipped.
+	victim(skip func(pageID uint32) bool) (uint32, bool)
+	// evicted records that the page victim returned was evicted.
+	evicted(pageID uint32)
+	// remove forgets a page dropped from the cache for another reason.
+	remove(pageID uint32)
+}
+
+// newEvictor creates the evictor for a policy and cache capacity.
+func newEvictor(policy EvictionPolicy, capacity int) evictor {
+	switch policy {
+	case EvictionLRU:
+		return newLRUEvictor()
+	case EvictionClock:
+		return newClockEvictor()
+	case EvictionLFU:
+		return newLFUEvictor()
+	default:
+		return newTwoQueueEvictor(capacity)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// LRU
+// ----------------------------------------------------------------------------
+
+// lruEvictor keeps pages in a list, most recently used at the front, with
+// a map for O(1) access to each page's element.
+type lruEvictor struct {
+	order    *list.List
+	elements map[uint32]*list.Element
+}
+
+func newLRUEvictor() *lruEvictor {
+	return &lruEvictor{order: list.New(), elements: make(map[uint32]*list.Element)}
+}
+
+func (l *lruEvictor) add(pageID uint32) {
+	l.elements[pageID] = l.order.PushFront(pageID)
+}
+
+func (l *lruEvictor) access(pageID uint32) {
+	if elem, ok := l.elements[pageID]; ok {
+		l.order.MoveToFront(elem)
+	}
+}
+
+func (l *lruEvictor) victim(skip func(uint32) bool) (uint32, bool) {
+	for elem := l.order.Back(); elem != nil; elem = elem.Prev() {
+		if pageID := elem.Value.(uint32); !skip(pageID) {
+			return pageID, true
+		}
+	}
+	return 0, false
+}
+
+func (l *lruEvictor) evicted(pageID uint32) { l.remove(pageID) }
+
+func (l *lruEvictor) remove(pageID uint32) {
+	if elem, ok := l.elements[pageID]; ok {
+		l.order.Remove(elem)
+		delete(l.elements, pageID)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// CLOCK
+// ----------------------------------------------------------------------------
+
+// clockFrame is one slot on the clock face.
+type clockFrame struct {
+	pageID     uint32
+	used       bool // the slot holds a page
+	referenced bool
+}
+
+// clockEvictor keeps pages in a circle of slots swept by a hand. Slots
+// freed by removed pages are reused.
+type clockEvictor struct {
+	frames []clockFrame
+	slots  map[uint32]int
+	free   []int
+	hand   int
+}
+
+func newClockEvictor() *clockEvictor {
+	return &clockEvictor{slots: make(map[uint32]int)}
+}
+
+func (c *clockEvictor) add(pageID uint32) {
+	frame := clockFrame{pageID: pageID, used: true, referenced: true}
+	if n := len(c.free); n > 0 {
+		slot := c.free[n-1]
+		c.free = c.free[:n-1]
+		c.frames[slot] = frame
+		c.slots[pageID] = slot
+		return
+	}
+	c.frames = append(c.frames, frame)
+	c.slots[pageID] = len(c.frames) - 1
+}
+
+func (c *clockEvictor) access(pageID uint32) {
+	if slot, ok := c.slots[pageID]; ok {
+		c.frames[slot].referenced = true
+	}
+}
+
+// victim sweeps at most twice round the clock: the first pass may only
+// clear reference bits, the second then finds a page if any is allowed.
+func (c *clockEvictor) victim(skip func(uint32) bool) (uint32, bool) {
+	for i := 0; i < 2*len(c.frames); i++ {
+		frame := &c.frames[c.hand]
+		if frame.used && !skip(frame.pageID) {
+			if !frame.referenced {
+				return frame.pageID, true
+			}
+			frame.referenced = false
+		}
+		c.hand = (c.hand + 1) % len(c.frames)
+	}
+	return 0, false
+}
+
+func (c *clockEvictor) evicted(pageID uint32) { c.remove(pageID) }
+
+func (c *clockEvictor) remove(pageID uint32) {
+	if slot, ok := c.slots[pageID]; ok {
+		c.frames[slot] = clockFrame{}
+		c.free = append(c.free, slot)
+		delete(c.slots, pageID)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// 2Q
+// ----------------------------------------------------------------------------
+
+// twoQueueEvictor is the full 2Q algorithm (Johnson and Shasha, 1994):
+// new pages enter the FIFO queue in; pages evicted from in are remembered
+// in the ghost queue out; a page read again while remembered there goes
+// to the main LRU list.
+type twoQueueEvictor struct {
+	in      *lruEvictor // pages seen once, in arrival order
+	main    *lruEvictor // pages seen again, in LRU order
+	out     *list.List  // IDs of pages recently evicted from in
+	outIDs  map[uint32]*list.Element
+	inSize  int // how many pages in may hold before it's evicted from first
+	outSize int // how many page IDs out remembers
+}
+
+func newTwoQueueEvictor(capacity int) *twoQueueEvictor {
+	return &twoQueueEvictor{
+		in:      newLRUEvictor(),
+		main:    newLRUEvictor(),
+		out:     list.New(),
+		outIDs:  make(map[uint32]*list.Element),
+		inSize:  max(1, capacity/4),
+		outSize: max(1, capacity/2),
+	}
+}
+
+// resize sizes the queues for a new cache capacity, forgetting the oldest
+// evicted pages if out now remembers too many.
+func (q *twoQueueEvictor) resize(capacity int) {
+	q.inSize, q.outSize = max(1, capacity/4), max(1, capacity/2)
+	for q.out.Len() > q.outSize {
+		oldest := q.out.Back()
+		q.out.Remove(oldest)
+		delete(q.outIDs, oldest.Value.(uint32))
+	}
+}
+
+func (q *twoQueueEvictor) add(pageID uint32) {
+	if elem, ok := q.outIDs[pageID]; ok {
+		q.out.Remove(elem)
+		delete(q.outIDs, pageID)
+		q.main.add(pageID)
+		return
+	}
+	q.in.add(pageID)
+}
+
+// access only affects pages in the main list: repeated hits soon after a
+// page arrives are usually the same operation (reading several rows from
+// one page) and say nothing about whether it will be needed later.
+func (q *twoQueueEvictor) access(pageID uint32) {
+	q.main.access(pageID)
+}
+
+func (q *twoQueueEvictor) victim(skip func(uint32) bool) (uint32, bool) {
+	if q.in.order.Len() > q.inSize || q.main.order.Len() == 0 {
+		if pageID, ok := q.in.victim(skip); ok {
+			return pageID, true
+		}
+	}
+	if pageID, ok := q.main.victim(skip); ok {
+		return pageID, true
+	}
+	return q.in.victim(skip)
+}
+
+func (q *twoQueueEvictor) evicted(pageID uint32) {
+	if _, ok := q.in.elements[pageID]; ok {
+		q.in.remove(pageID)
+		q.outIDs[pageID] = q.out.PushFront(pageID)
+		if q.out.Len() > q.outSize {
+			oldest := q.out.Back()
+			q.out.Remove(oldest)
+			delete(q.outIDs, oldest.Value.(uint32))
+		}
+		return
+	}
+	q.main.remove(pageID)
+}
+
+func (q *twoQueueEvictor) remove(pageID uint32) {
+	q.in.remove(pageID)
+	q.main.remove(pageID)
+	if elem, ok := q.outIDs[pageID]; ok {
+		q.out.Remove(elem)
+		delete(q.outIDs, pageID)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// LFU
+// ----------------------------------------------------------------------------
+
+// lfuEntry is a cached page's use count, and when it was last used to
+// break ties between equal counts.
+type lfuEntry struct {
+	uses     uint64
+	lastUsed uint64
+}
+
+// lfuEvictor counts the uses of each page. Finding the victim scans every
+// page, which is fine for a cache of a few thousand pages; a large cache
+// would keep the pages in a heap or in lists per use count instead.
+type lfuEvictor struct {
+	entries map[uint32]*lfuEntry
+	clock   uint64
+}
+
+func newLFUEvictor() *lfuEvictor {
+	return &lfuEvictor{entries: make(map[uint32]*lfuEntry)}
+}
+
+func (l *lfuEvictor) add(pageID uint32) {
+	l.clock++
+	l.entries[pageID] = &lfuEntry{uses: 1, lastUsed: l.clock}
+}
+
+func (l *lfuEvictor) access(pageID uint32) {
+	if entry, ok := l.entries[pageID]; ok {
+		l.clock++
+		entry.uses++
+		entry.lastUsed = l.clock
+	}
+}
+
+func (l *lfuEvictor) victim(skip func(uint32) bool) (uint32, bool) {
+	var best *lfuEntry
+	var bestID uint32
+	for pageID, entry := range l.entries {
+		if skip(pageID) {
+			continue
+		}
+		if best == nil || entry.uses < best.uses || (entry.uses == best.uses && entry.lastUsed < best.lastUsed) {
+			best, bestID = entry, pageID
+		}
+	}
+	return bestID, best != nil
+}
+
+func (l *lfuEvictor) evicted(pageID uint32) { l.remove(pageID) }
+
+func (l *lfuEvictor) remove(pageID uint32) {
+	delete(l.entries, pageID)
+}
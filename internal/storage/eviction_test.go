@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEvictorVictims(t *testing.T) {
+	none := func(uint32) bool { return false }
+
+	tests := []struct {
+		policy EvictionPolicy
+		want   uint32
+	}{
+		// Pages 1, 2, 3 added; 1 used twice more, 2 once more
+		{EvictionLRU, 3},      // least recently used
+		{EvictionClock, 3},    // the only page without its reference bit set
+		{EvictionLFU, 3},      // used least often
+		{EvictionTwoQueue, 1}, // oldest in the queue of new pages; hits don't count there
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			e := newEvictor(tt.policy, 4)
+			e.add(1)
+			e.add(2)
+			e.add(3)
+			if tt.policy == EvictionClock {
+				// Clear every reference bit, then reference 1 and 2 again
+				c := e.(*clockEvictor)
+				for i := range c.frames {
+					c.frames[i].referenced = false
+				}
+			}
+			e.access(1)
+			e.access(2)
+			e.access(1)
+
+			if got, ok := e.victim(none); !ok || got != tt.want {
+				t.Errorf("expected victim %d, got %d (ok=%v)", tt.want, got, ok)
+			}
+			if got, ok := e.victim(func(pageID uint32) bool { return pageID == tt.want }); !ok || got == tt.want {
+				t.Errorf("expected a different victim when %d is skipped, got %d (ok=%v)", tt.want, got, ok)
+			}
+			if _, ok := e.victim(func(uint32) bool { return true }); ok {
+				t.Error("expected no victim when every page is skipped")
+			}
+
+			e.evicted(tt.want)
+			e.remove(2)
+			for i := 0; i < 3; i++ {
+				if got, ok := e.victim(none); !ok || got == tt.want || got == 2 {
+					t.Errorf("expected only the remaining page, got %d (ok=%v)", got, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestParseEvictionPolicy(t *testing.T) {
+	for _, name := range []string{"2q", "lru", "clock", "lfu"} {
+		policy, err := ParseEvictionPolicy(name)
+		if err != nil || policy.String() != name {
+			t.Errorf("ParseEvictionPolicy(%q) = %v, %v", name, policy, err)
+		}
+	}
+	if _, err := ParseEvictionPolicy("random"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+// TestEvictionScanResistance reads a small hot set of pages often, then
+// scans many more pages once, and counts how many hot pages the scan
+// pushed out of the cache.
+func TestEvictionScanResistance(t *testing.T) {
+	const cacheSize, hot, warm, total = 20, 5, 20, 150
+
+	hotMisses := func(policy EvictionPolicy) uint64 {
+		pager, err := NewPager(filepath.Join(t.TempDir(), "scan.db"),
+			WithMaxCacheSize(cacheSize), WithEvictionPolicy(policy))
+		if err != nil {
+			t.Fatalf("NewPager failed: %v", err)
+		}
+		defer pager.Close()
+		for i := 0; i < total; i++ {
+			pager.AllocatePage(PageTypeData)
+		}
+		pager.FlushAll()
+
+		// Warm up: the hot pages are read, pushed out by other reads, and
+		// read again a few times, as a working set would be over time
+		for i := uint32(0); i < hot; i++ {
+			pager.GetPage(i)
+		}
+		for i := uint32(hot); i < hot+warm; i++ {
+			pager.GetPage(i)
+		}
+		for round := 0; round < 3; round++ {
+			for i := uint32(0); i < hot; i++ {
+				pager.GetPage(i)
+			}
+		}
+
+		// The scan, of pages never read before
+		for i := uint32(hot + warm); i < total; i++ {
+			pager.GetPage(i)
+		}
+
+		before := pager.Stats().Misses
+		for i := uint32(0); i < hot; i++ {
+			pager.GetPage(i)
+		}
+		return pager.Stats().Misses - before
+	}
+
+	if misses := hotMisses(EvictionLRU); misses != hot {
+		t.Errorf("expected the scan to flush every hot page out of an LRU cache, got %d misses", misses)
+	}
+	if misses := hotMisses(EvictionTwoQueue); misses != 0 {
+		t.Errorf("expected the hot pages to survive the scan with 2Q, got %d misses", misses)
+	}
+	if misses := hotMisses(EvictionLFU); misses != 0 {
+		t.Errorf("expected the hot pages to survive the scan with LFU, got %d misses", misses)
+	}
+}
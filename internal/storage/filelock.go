@@ -0,0 +1,73 @@
+// Package storage - File locking
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// The pager caches pages, keeps the free list in memory and appends to
+// its own write-ahead log, all on the assumption that nothing else writes
+// the file. Two processes opening the same database would each overwrite
+// the other's pages and free the other's allocations: corruption that
+// shows up long after, as a B-tree pointing at a page holding rows.
+//
+// So NewPager locks the file with an advisory lock (flock(2) on Unix)
+// before reading it. A pager that writes takes an exclusive lock; a
+// read-only one (see WithReadOnly) takes a shared lock, which other
+// readers may share but no writer. The locks are released when the file
+// is closed, or when the process dies, so a crash never leaves a database
+// locked. Whoever comes second fails straight away with ErrLocked rather
+// than waiting: the lock is held for as long as the database is open, so
+// a wait could last forever.
+//
+// "Advisory" means only processes that ask for the lock are kept out;
+// cp or another program can still write the file. SQLite uses the same
+// kind of lock, taken per transaction rather than per open, which needs a
+// protocol for readers to see the file change under them; holding it for
+// the whole session keeps the pager's cache always right.
+//
+// Locking is only implemented for the Unix systems that have flock;
+// elsewhere the database is opened unlocked, as before.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrLocked is returned by NewPager when another process has the
+// database open in a way that conflicts.
+var ErrLocked = errors.New("database is locked by another process")
+
+// ErrReadOnly is returned on writing to a database opened with
+// WithReadOnly.
+var ErrReadOnly = errors.New("database is open read-only")
+
+// errWouldBlock is returned by flockFile when another lock is in the way.
+var errWouldBlock = errors.New("lock is held")
+
+// WithReadOnly opens the database for reading only. Other read-only
+// pagers may have it open too, but no pager that writes. The file must
+// exist.
+func WithReadOnly() PagerOption {
+	return func(p *Pager) {
+		p.readOnly = true
+	}
+}
+
+// ReadOnly reports whether the pager was opened with WithReadOnly.
+func (p *Pager) ReadOnly() bool {
+	return p.readOnly
+}
+
+// lockFile locks a database file: exclusively for a pager that writes,
+// shared for a read-only one. It fails with ErrLocked if another process
+// holds a lock in the way.
+func lockFile(file *os.File, exclusive bool) error {
+	if err := flockFile(file, exclusive); err != nil {
+		if errors.Is(err, errWouldBlock) {
+			return fmt.Errorf("%s: %w", file.Name(), ErrLocked)
+		}
+		return fmt.Errorf("failed to lock database file: %w", err)
+	}
+	return nil
+}
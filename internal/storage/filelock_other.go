@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package storage
+
+import "os"
+
+// flockFile does nothing: file locking is only implemented where flock is
+// available, and the database is opened unlocked elsewhere.
+func flockFile(file *os.File, exclusive bool) error {
+	return nil
+}
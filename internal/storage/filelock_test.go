@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPagerFileLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file locking is not supported on this platform")
+	}
+	path := filepath.Join(t.TempDir(), "locked.db")
+
+	// A pager that writes keeps every other out
+	writer, err := NewPager(path)
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	page, _ := writer.AllocatePage(PageTypeData)
+	page.WriteData([]byte("hello"))
+	for _, opts := range [][]PagerOption{nil, {WithReadOnly()}} {
+		if _, err := NewPager(path, opts...); !errors.Is(err, ErrLocked) {
+			t.Errorf("expected ErrLocked while the file is open for writing, got %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Readers share the file, and keep writers out
+	first, err := NewPager(path, WithReadOnly())
+	if err != nil {
+		t.Fatalf("opening read-only failed: %v", err)
+	}
+	second, err := NewPager(path, WithReadOnly())
+	if err != nil {
+		t.Fatalf("opening read-only twice failed: %v", err)
+	}
+	if _, err := NewPager(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked while the file is open read-only, got %v", err)
+	}
+	if page, err := second.GetPage(0); err != nil || page.Type() != PageTypeData {
+		t.Errorf("expected to read the page written, got %v", err)
+	}
+	if _, err := first.AllocatePage(PageTypeData); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly allocating a page, got %v", err)
+	}
+	first.Close()
+	second.Close()
+
+	// Closing releases the lock
+	writer, err = NewPager(path)
+	if err != nil {
+		t.Fatalf("reopening for writing failed: %v", err)
+	}
+	writer.Close()
+}
+
+func TestPagerReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewPager(filepath.Join(dir, "missing.db"), WithReadOnly()); err == nil {
+		t.Error("expected opening a missing file read-only to fail")
+	}
+
+	// A log left by a crash has to be recovered by a writer first
+	path := filepath.Join(dir, "crashed.db")
+	pager, err := NewPager(path, WithWAL())
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	pager.AllocatePage(PageTypeData)
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	crash(pager)
+	if _, err := NewPager(path, WithWAL(), WithReadOnly()); err == nil {
+		t.Error("expected opening read-only with a log to recover to fail")
+	}
+
+	pager, err = NewPager(path, WithWAL())
+	if err != nil {
+		t.Fatalf("recovering failed: %v", err)
+	}
+	pager.Close()
+	pager, err = NewPager(path, WithWAL(), WithReadOnly())
+	if err != nil {
+		t.Fatalf("opening read-only after recovery failed: %v", err)
+	}
+	defer pager.Close()
+	if pager.PageCount() != 1 {
+		t.Errorf("expected the recovered page, got %d pages", pager.PageCount())
+	}
+}
@@ -0,0 +1,173 @@
+// Package storage - Free list
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Pages are allocated at the end of the file. Without a way to give them
+// back, DROP TABLE only forgets a table: its data pages and B-tree stay
+// in the file, unreachable, until VACUUM rebuilds it. The free list keeps
+// track of pages that are no longer used, so the next allocation takes
+// one of them instead of growing the file.
+//
+// The list costs no extra space, because it is stored in the free pages
+// themselves: each one holds the ID of the next, and only the first is
+// remembered elsewhere (here in the catalog, see SetFreeList):
+//
+//   head: 12 ---> page 12 [next: 40] ---> page 40 [next: 7] ---> page 7 [next: 0]
+//
+// Freeing a page pushes it on the front of the list and allocating pops
+// the front, so both are a single page write. The file never shrinks:
+// free pages stay in it, ready for reuse, until VACUUM cuts them off.
+// SQLite keeps a free list the same way, with trunk pages listing many
+// free pages each so that freeing a large table touches fewer pages.
+//
+// Like any other page change, freeing and reusing pages inside a
+// transaction is undone by a rollback, which also puts the list's head
+// back where it was.
+
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FreePage puts a page that is no longer used on the free list, for
+// AllocatePage to reuse. The page's contents are lost.
+func (p *Pager) FreePage(pageID uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 0 ends the list, so page 0 can't be on it; it is the catalog's page
+	// anyway, except in a database without one, where it is simply left
+	// unused
+	if pageID == 0 {
+		return nil
+	}
+	if pageID >= p.pageCount {
+		return fmt.Errorf("cannot free page %d (only %d pages)", pageID, p.pageCount)
+	}
+	page, err := p.getPageLocked(pageID)
+	if err != nil {
+		return err
+	}
+	if page.Type() == PageTypeFree {
+		return fmt.Errorf("page %d is already free", pageID)
+	}
+
+	freed := NewPage(pageID, PageTypeFree)
+	var next [4]byte
+	binary.LittleEndian.PutUint32(next[:], p.freeHead)
+	if err := freed.SetData(next[:]); err != nil {
+		return err
+	}
+	*page = *freed
+	p.freeHead = pageID
+	p.freeCount++
+	return nil
+}
+
+// reuseFreePageLocked takes the first page off the free list and turns it
+// into an empty page of the given type. It returns nil if the list is
+// empty. Caller must hold the lock.
+func (p *Pager) reuseFreePageLocked(pageType PageType) (*Page, error) {
+	if p.freeHead == 0 {
+		return nil, nil
+	}
+	page, err := p.getPageLocked(p.freeHead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read free page %d: %w", p.freeHead, err)
+	}
+
+	// A head saved before a crash may point at a page that was reused
+	// since; rather than hand it out twice, give up on the list. Its
+	// pages are lost until VACUUM, which is safe.
+	if page.Type() != PageTypeFree {
+		p.freeHead, p.freeCount = 0, 0
+		return nil, nil
+	}
+
+	next := binary.LittleEndian.Uint32(page.GetData())
+	*page = *NewPage(page.ID(), pageType)
+	p.freeHead = next
+	if p.freeCount > 0 {
+		p.freeCount--
+	}
+	return page, nil
+}
+
+// FreeList returns the first page of the free list (0 if it is empty) and
+// the number of pages on it, for the caller to save with the database.
+func (p *Pager) FreeList() (head, count uint32) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.freeHead, p.freeCount
+}
+
+// SetFreeList restores the free list saved from FreeList when the
+// database was last written.
+func (p *Pager) SetFreeList(head, count uint32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if head >= p.pageCount {
+		head, count = 0, 0
+	}
+	p.freeHead, p.freeCount = head, count
+	p.flushed.freeHead, p.flushed.freeCount = head, count
+}
+
+// FreePageIDs returns the pages on the free list, in order, checking
+// that each is a free page and that there are as many as counted.
+func (p *Pager) FreePageIDs() ([]uint32, error) {
+	head, count := p.FreeList()
+	var pages []uint32
+	for pageID := head; pageID != 0; {
+		if uint32(len(pages)) == count {
+			return pages, fmt.Errorf("free list is longer than its %d pages", count)
+		}
+		page, err := p.GetPage(pageID)
+		if err != nil {
+			return pages, err
+		}
+		if page.Type() != PageTypeFree {
+			return pages, fmt.Errorf("page %d on the free list has page type %d", pageID, page.Type())
+		}
+		pages = append(pages, pageID)
+		pageID = binary.LittleEndian.Uint32(page.GetData())
+	}
+	if uint32(len(pages)) != count {
+		return pages, fmt.Errorf("free list has %d pages, expected %d", len(pages), count)
+	}
+	return pages, nil
+}
+
+// Pages returns the IDs of every page of the B-tree.
+func (bt *BTree) Pages() ([]uint32, error) {
+	var pages []uint32
+	var walk func(pageID uint32) error
+	walk = func(pageID uint32) error {
+		pages = append(pages, pageID)
+		page, err := bt.pager.GetPage(pageID)
+		if err != nil {
+			return err
+		}
+		node, err := deserializeNode(page)
+		if err != nil {
+			return err
+		}
+		if node.isLeaf {
+			return nil
+		}
+		for _, child := range node.children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return pages, walk(bt.rootPage)
+}
+
+// Pages returns the IDs of every page of the index's B-tree.
+func (idx *Index) Pages() ([]uint32, error) {
+	return idx.btree.Pages()
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFreeListReusesPages(t *testing.T) {
+	pager, cleanup := setupTestPager(t)
+	defer cleanup()
+
+	for i := 0; i < 5; i++ {
+		if _, err := pager.AllocatePage(PageTypeData); err != nil {
+			t.Fatalf("AllocatePage failed: %v", err)
+		}
+	}
+	for _, pageID := range []uint32{1, 3, 4} {
+		if err := pager.FreePage(pageID); err != nil {
+			t.Fatalf("FreePage(%d) failed: %v", pageID, err)
+		}
+	}
+	if err := pager.FreePage(3); err == nil {
+		t.Error("expected freeing a free page to fail")
+	}
+
+	pages, err := pager.FreePageIDs()
+	if err != nil || !reflect.DeepEqual(pages, []uint32{4, 3, 1}) {
+		t.Fatalf("expected free pages 4, 3, 1, got %v, %v", pages, err)
+	}
+
+	// The last page freed is the first reused, and the file doesn't grow
+	for _, want := range []uint32{4, 3, 1, 5} {
+		page, err := pager.AllocatePage(PageTypeOverflow)
+		if err != nil {
+			t.Fatalf("AllocatePage failed: %v", err)
+		}
+		if page.ID() != want || page.Type() != PageTypeOverflow || page.FreeSpace() != MaxDataSize {
+			t.Errorf("expected an empty overflow page %d, got page %d of type %d", want, page.ID(), page.Type())
+		}
+	}
+	if head, count := pager.FreeList(); head != 0 || count != 0 || pager.PageCount() != 6 {
+		t.Errorf("expected an empty free list and 6 pages, got %d, %d and %d pages", head, count, pager.PageCount())
+	}
+}
+
+func TestFreeListRollback(t *testing.T) {
+	pager, cleanup := setupTestPager(t)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		if _, err := pager.AllocatePage(PageTypeData); err != nil {
+			t.Fatalf("AllocatePage failed: %v", err)
+		}
+	}
+	if err := pager.FreePage(1); err != nil {
+		t.Fatalf("FreePage failed: %v", err)
+	}
+
+	if err := pager.Begin(); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := pager.FreePage(2); err != nil {
+		t.Fatalf("FreePage failed: %v", err)
+	}
+	if _, err := pager.AllocatePage(PageTypeData); err != nil {
+		t.Fatalf("AllocatePage failed: %v", err)
+	}
+	if err := pager.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if head, count := pager.FreeList(); head != 1 || count != 1 {
+		t.Errorf("expected the free list back at page 1 with 1 page, got %d with %d", head, count)
+	}
+	page, err := pager.GetPage(2)
+	if err != nil || page.Type() != PageTypeData {
+		t.Errorf("expected page 2 to be a data page again, got %v", err)
+	}
+	if pages, err := pager.FreePageIDs(); err != nil || !reflect.DeepEqual(pages, []uint32{1}) {
+		t.Errorf("expected free page 1, got %v, %v", pages, err)
+	}
+}
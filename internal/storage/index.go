@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"sort"
 )
 
 // Index represents a secondary index on one or more columns.
@@ -71,6 +72,46 @@ func NewIndex(name, tableName string, columns []string, unique bool, pager *Page
 	}, nil
 }
 
+// BuildIndex creates a secondary index holding the given entries, building
+// its B-tree bottom up (see BuildBTree). keys[i] is the serialized column
+// value(s) of the row stored at locations[i]; they need not be sorted.
+func BuildIndex(name, tableName string, columns []string, unique bool, pager *Pager, keys [][]byte, locations []uint64) (*Index, error) {
+	idx := &Index{
+		Name:    name,
+		Table:   tableName,
+		Columns: columns,
+		Unique:  unique,
+	}
+
+	entryKeys := make([][]byte, len(keys))
+	order := make([]int, len(keys))
+	for i := range keys {
+		entryKeys[i] = idx.entryKey(keys[i], locations[i])
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return bytes.Compare(entryKeys[order[a]], entryKeys[order[b]]) < 0
+	})
+
+	sortedKeys := make([][]byte, len(order))
+	sortedLocations := make([]uint64, len(order))
+	for i, j := range order {
+		sortedKeys[i] = entryKeys[j]
+		sortedLocations[i] = locations[j]
+		if i > 0 && bytes.Equal(sortedKeys[i-1], sortedKeys[i]) {
+			return nil, fmt.Errorf("duplicate key value violates unique constraint %q", name)
+		}
+	}
+
+	btree, err := BuildBTree(pager, sortedKeys, sortedLocations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build B-tree for index: %w", err)
+	}
+	idx.btree = btree
+	idx.rootPage = btree.RootPage()
+	return idx, nil
+}
+
 // LoadIndex loads an existing index from storage.
 func LoadIndex(name, tableName string, columns []string, unique bool, pager *Pager, rootPage uint32) *Index {
 	return &Index{
@@ -87,16 +128,7 @@ func LoadIndex(name, tableName string, columns []string, unique bool, pager *Pag
 // keyBytes is the serialized column value(s).
 // location is the row location (same format as primary key index).
 func (idx *Index) Insert(keyBytes []byte, location uint64) error {
-	// For non-unique indexes, append location to key to make it unique
-	var indexKey []byte
-	if idx.Unique {
-		indexKey = keyBytes
-	} else {
-		// Append location to make key unique
-		indexKey = make([]byte, len(keyBytes)+8)
-		copy(indexKey, keyBytes)
-		binary.BigEndian.PutUint64(indexKey[len(keyBytes):], location)
-	}
+	indexKey := idx.entryKey(keyBytes, location)
 
 	// For unique indexes, check if key already exists
 	if idx.Unique {
@@ -115,20 +147,36 @@ func (idx *Index) Insert(keyBytes []byte, location uint64) error {
 // Delete removes an entry from the index.
 // For non-unique indexes, the location is needed to identify the specific entry.
 func (idx *Index) Delete(keyBytes []byte, location uint64) error {
-	// For non-unique indexes, we need to include location in the key
-	var indexKey []byte
+	indexKey := idx.entryKey(keyBytes, location)
+
+	// A unique index may already point at a different row with this key
 	if idx.Unique {
-		indexKey = keyBytes
-	} else {
-		indexKey = make([]byte, len(keyBytes)+8)
-		copy(indexKey, keyBytes)
-		binary.BigEndian.PutUint64(indexKey[len(keyBytes):], location)
+		current, found, err := idx.btree.Search(indexKey)
+		if err != nil || !found || current != location {
+			return err
+		}
 	}
 
-	// Note: B-tree doesn't currently support delete, so we mark this as a TODO
-	// In a production system, we'd implement B-tree delete or use tombstones
-	_ = indexKey
-	return nil // TODO: Implement delete when B-tree supports it
+	_, err := idx.btree.Delete(indexKey)
+	return err
+}
+
+// CheckKeySize returns an error wrapping ErrKeyTooLarge if keyBytes makes
+// an index entry longer than MaxKeySize.
+func (idx *Index) CheckKeySize(keyBytes []byte) error {
+	return CheckKeySize(idx.entryKey(keyBytes, 0))
+}
+
+// entryKey returns the B-tree key of an entry. For non-unique indexes the
+// location is appended to make every entry's key unique.
+func (idx *Index) entryKey(keyBytes []byte, location uint64) []byte {
+	if idx.Unique {
+		return keyBytes
+	}
+	indexKey := make([]byte, len(keyBytes)+8)
+	copy(indexKey, keyBytes)
+	binary.BigEndian.PutUint64(indexKey[len(keyBytes):], location)
+	return indexKey
 }
 
 // Lookup finds all row locations matching the exact key value.
@@ -218,9 +266,16 @@ func (idx *Index) RangeScan(startKey, endKey []byte) ([]uint64, error) {
 	return locations, nil
 }
 
-// RootPage returns the root page ID for persistence.
+// Iterator returns an iterator over every entry of the index, in key
+// order. Each entry's value is the location of its row.
+func (idx *Index) Iterator() *BTreeIterator {
+	return idx.btree.NewRangeIterator(nil, nil)
+}
+
+// RootPage returns the root page ID for persistence. The root moves when
+// the root node splits, so ask the B-tree rather than trusting rootPage.
 func (idx *Index) RootPage() uint32 {
-	return idx.rootPage
+	return idx.btree.RootPage()
 }
 
 // IndexManager manages all secondary indexes for a table.
@@ -266,7 +321,7 @@ func (im *IndexManager) DropIndex(name string) error {
 	}
 
 	delete(im.indexes, name)
-	// Note: In a real system, we'd also free the B-tree pages
+	// Note: unlike Table.DropIndex, this doesn't free the B-tree pages
 	return nil
 }
 
@@ -212,3 +212,87 @@ func setupTestPager(t *testing.T) (*Pager, func()) {
 
 	return pager, cleanup
 }
+
+func TestIndexDelete(t *testing.T) {
+	pager, cleanup := setupTestPager(t)
+	defer cleanup()
+
+	key := func(v uint64) []byte {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, v)
+		return b
+	}
+
+	idx, _ := NewIndex("idx_age", "users", []string{"age"}, false, pager)
+	idx.Insert(key(25), 100)
+	idx.Insert(key(25), 200)
+
+	if err := idx.Delete(key(25), 100); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	locations, _ := idx.Lookup(key(25))
+	if len(locations) != 1 || locations[0] != 200 {
+		t.Errorf("expected only location 200 to remain, got %v", locations)
+	}
+
+	unique, _ := NewIndex("idx_email", "users", []string{"email"}, true, pager)
+	unique.Insert(key(1), 100)
+
+	// Deleting with a stale location leaves the entry alone
+	unique.Delete(key(1), 999)
+	if locations, _ := unique.Lookup(key(1)); len(locations) != 1 {
+		t.Errorf("expected entry for another row to survive, got %v", locations)
+	}
+	unique.Delete(key(1), 100)
+	if locations, _ := unique.Lookup(key(1)); len(locations) != 0 {
+		t.Errorf("expected entry to be deleted, got %v", locations)
+	}
+	if err := unique.Insert(key(1), 300); err != nil {
+		t.Errorf("expected key to be free after delete: %v", err)
+	}
+}
+
+func TestBuildIndex(t *testing.T) {
+	pager, cleanup := setupTestPager(t)
+	defer cleanup()
+
+	key := func(v uint64) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		return b
+	}
+
+	// Unsorted entries, several rows per key
+	var keys [][]byte
+	var locations []uint64
+	for i := uint64(300); i > 0; i-- {
+		keys = append(keys, key(i%10))
+		locations = append(locations, i)
+	}
+
+	idx, err := BuildIndex("idx_age", "users", []string{"age"}, false, pager, keys, locations)
+	if err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+	found, _ := idx.Lookup(key(3))
+	if len(found) != 30 {
+		t.Errorf("expected 30 rows with key 3, got %d", len(found))
+	}
+	for _, location := range found {
+		if location%10 != 3 {
+			t.Errorf("key 3 led to location %d", location)
+		}
+	}
+	if err := idx.Insert(key(3), 1000); err != nil {
+		t.Fatalf("Insert into built index failed: %v", err)
+	}
+	if found, _ := idx.Lookup(key(3)); len(found) != 31 {
+		t.Errorf("expected 31 rows with key 3 after insert, got %d", len(found))
+	}
+
+	_, err = BuildIndex("idx_email", "users", []string{"email"}, true, pager,
+		[][]byte{key(1), key(2), key(1)}, []uint64{10, 20, 30})
+	if err == nil {
+		t.Error("expected a duplicate key to fail building a unique index")
+	}
+}
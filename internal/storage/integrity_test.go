@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBTreeCheckIntegrity(t *testing.T) {
+	btree, pager, cleanup := setupTestBTree(t)
+	defer cleanup()
+
+	for i := 0; i < 1000; i++ {
+		btree.Insert([]byte(fmt.Sprintf("key%04d", i*37%1000)), uint64(i))
+	}
+	btree.Delete([]byte("key0500"))
+
+	r := NewIntegrityReport()
+	if entries := btree.CheckIntegrity(r, "test"); entries != 999 {
+		t.Errorf("expected 999 entries, got %d", entries)
+	}
+	if len(r.Problems) != 0 {
+		t.Fatalf("expected a healthy tree, got %v", r.Problems)
+	}
+
+	// Swap two keys in the first leaf and break its sibling link
+	first, _ := btree.FirstLeaf()
+	page, _ := pager.GetPage(first)
+	node, _ := deserializeNode(page)
+	node.keys[0], node.keys[1] = node.keys[1], node.keys[0]
+	node.nextLeaf = 0
+	serializeNode(page, node)
+
+	r = NewIntegrityReport()
+	btree.CheckIntegrity(r, "test")
+	report := strings.Join(r.Problems, "\n")
+	if !strings.Contains(report, "not greater than the key before it") {
+		t.Errorf("expected key order problem, got:\n%s", report)
+	}
+	if !strings.Contains(report, fmt.Sprintf("leaf page %d links to", first)) {
+		t.Errorf("expected sibling link problem, got:\n%s", report)
+	}
+
+	// A page claimed by two structures is reported
+	r.Claim(first, "another tree")
+	if !strings.Contains(r.Problems[len(r.Problems)-1], "used by both test and another tree") {
+		t.Errorf("expected shared page problem, got %q", r.Problems[len(r.Problems)-1])
+	}
+}
+
+func TestVerifyPage(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_verify.db")
+
+	pager, err := NewPager(testFile)
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		page.WriteData([]byte(fmt.Sprintf("page %d", i)))
+	}
+	pager.Close()
+
+	// Damage page 1 on disk behind the pager's back
+	file, _ := os.OpenFile(testFile, os.O_RDWR, 0644)
+	file.WriteAt([]byte{0xAB}, PageSize+PageHeaderSize+2)
+	file.Close()
+
+	pager, err = NewPager(testFile)
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer pager.Close()
+
+	for pageID, wantErr := range []bool{false, true, false} {
+		err := pager.VerifyPage(uint32(pageID))
+		if wantErr != errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("page %d: expected mismatch %v, got %v", pageID, wantErr, err)
+		}
+	}
+	if _, err := pager.GetPage(1); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected GetPage to refuse the damaged page, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package storage - In-memory databases
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Opening the path ":memory:" gives a database that lives only in memory,
+// as in SQLite: no file is created, nothing is ever synced, and the data
+// is gone when the pager is closed. It's useful for tests, which then
+// leave nothing behind, and for scratch work that doesn't need to outlive
+// the process.
+//
+// The pager doesn't need to know much about it. Everything it does with
+// the database file goes through a few methods (ReadAt, WriteAt, Sync,
+// Truncate, Close), so a byte slice that implements them can stand in for
+// the file:
+//
+//   cache (bounded, evicts)  --write back-->  memFile (a []byte)
+//
+// Pages evicted from the cache are written into the slice and read back
+// from it, just as they would be with a file, so the cache size and the
+// eviction policy behave the same. A write-ahead log and memory-mapped
+// reads protect or speed up a file on disk, so they're turned off.
+
+package storage
+
+import (
+	"io"
+	"sync"
+)
+
+// MemoryPath is the path that opens an in-memory database.
+const MemoryPath = ":memory:"
+
+// dbFile is the storage a pager keeps its pages in: an *os.File, or a
+// memFile for an in-memory database.
+type dbFile interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// memFile is a dbFile held in a byte slice.
+type memFile struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+func (f *memFile) ReadAt(buf []byte, offset int64) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if offset >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(buf, f.data[offset:])
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(buf []byte, offset int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if end := offset + int64(len(buf)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	return copy(f.data[offset:], buf), nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if size < int64(len(f.data)) {
+		f.data = f.data[:size]
+	} else {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+	return nil
+}
+
+// Sync does nothing: there's no disk to wait for.
+func (f *memFile) Sync() error { return nil }
+
+// Close frees the data.
+func (f *memFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data = nil
+	return nil
+}
+
+// InMemory reports whether the pager holds an in-memory database (see
+// MemoryPath).
+func (p *Pager) InMemory() bool {
+	_, ok := p.file.(*memFile)
+	return ok
+}
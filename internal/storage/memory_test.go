@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPagerInMemory(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	pager, err := NewPager(MemoryPath, WithMaxCacheSize(2), WithWAL(), WithMmap())
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	if !pager.InMemory() {
+		t.Error("expected an in-memory pager")
+	}
+
+	// Write more pages than the cache holds, so most are evicted
+	offsets := make([]uint16, 10)
+	for i := range offsets {
+		page, err := pager.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage failed: %v", err)
+		}
+		if offsets[i], err = page.WriteData([]byte{byte(i), 0xAB}); err != nil {
+			t.Fatalf("WriteData failed: %v", err)
+		}
+	}
+	for i, offset := range offsets {
+		page, err := pager.GetPage(uint32(i))
+		if err != nil {
+			t.Fatalf("GetPage %d failed: %v", i, err)
+		}
+		if data := page.ReadData(offset, 2); data[0] != byte(i) || data[1] != 0xAB {
+			t.Errorf("page %d: expected its data back, got %v", i, data)
+		}
+	}
+
+	stats := pager.Stats()
+	if stats.Evictions == 0 || stats.DiskReads == 0 {
+		t.Errorf("expected evicted pages to be read back, got %+v", stats)
+	}
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	if n := pager.Stats().Syncs; n != 0 {
+		t.Errorf("expected no syncs, got %d", n)
+	}
+	if pager.Mapped() || pager.WALFrames() != 0 {
+		t.Error("expected no memory mapping or write-ahead log")
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	entries, _ := os.ReadDir(".")
+	if len(entries) != 0 {
+		t.Errorf("expected no files to be created, found %d", len(entries))
+	}
+
+	// Each in-memory database starts empty
+	pager, err = NewPager(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+	if n := pager.PageCount(); n != 0 {
+		t.Errorf("expected an empty database, got %d pages", n)
+	}
+}
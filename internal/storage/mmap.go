@@ -0,0 +1,100 @@
+// Package storage - Memory-mapped reads
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Reading a page with ReadAt is a system call that copies 4 KB from the
+// operating system's file cache into our buffer. With the file memory
+// mapped, the file cache itself appears as a byte slice in our address
+// space: reading a page is a slice expression, and the kernel loads the
+// data on first touch with a page fault instead of a system call.
+//
+//   ReadAt:  file cache --copy--> buf --copy--> Page
+//   mmap:    file cache (mapped) ------copy---> Page
+//
+// The pager still copies the bytes into a Page, so it can verify the
+// checksum and change the page without touching the file, but a scan that
+// misses the cache on every page saves a system call and a copy each.
+//
+// Writes still go through WriteAt (or the write-ahead log). The mapping is
+// shared, so it sees them as soon as they're made. The file can outgrow
+// the mapping, so a page past its end triggers a remap; shrinking the file
+// under a mapping would make reading the cut-off pages crash the process,
+// so the mapping is dropped before truncating.
+//
+// If the file can't be mapped (an empty file, or a platform without
+// mmap) the pager quietly goes on with ReadAt. LMDB reads entirely through
+// mmap; SQLite offers it as an option (PRAGMA mmap_size), as here.
+
+package storage
+
+import "os"
+
+// WithMmap makes the pager read pages through a memory mapping of the
+// database file instead of ReadAt, falling back to ReadAt if the file
+// can't be mapped.
+func WithMmap() PagerOption {
+	return func(p *Pager) {
+		p.useMmap = true
+	}
+}
+
+// Mapped reports whether pages are currently read through a memory
+// mapping.
+func (p *Pager) Mapped() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.mapped != nil
+}
+
+// remapLocked maps the whole database file, replacing any earlier
+// mapping. If mapping fails, memory-mapped reads are turned off. Caller
+// must hold the lock.
+func (p *Pager) remapLocked() {
+	if err := p.unmapLocked(); err != nil {
+		p.useMmap = false
+		return
+	}
+	file, ok := p.file.(*os.File)
+	if !ok {
+		p.useMmap = false
+		return
+	}
+	stat, err := file.Stat()
+	if err != nil || stat.Size() < slotSize(p.codec) {
+		return // nothing to map yet
+	}
+	data, err := mmapFile(file, int(stat.Size()))
+	if err != nil {
+		p.useMmap = false
+		return
+	}
+	p.mapped = data
+}
+
+// unmapLocked removes the mapping, if any. Caller must hold the lock.
+func (p *Pager) unmapLocked() error {
+	if p.mapped == nil {
+		return nil
+	}
+	err := munmapFile(p.mapped)
+	p.mapped = nil
+	return err
+}
+
+// mappedPageLocked returns a page's bytes in the mapping, or nil if it
+// can't be read that way. Caller must hold the lock.
+func (p *Pager) mappedPageLocked(pageID uint32) []byte {
+	if !p.useMmap {
+		return nil
+	}
+	start := int64(pageID) * slotSize(p.codec)
+	end := start + slotSize(p.codec)
+	if end > int64(len(p.mapped)) {
+		// The file may have grown since it was mapped
+		p.remapLocked()
+		if end > int64(len(p.mapped)) {
+			return nil
+		}
+	}
+	return p.mapped[start:end]
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile always fails: memory-mapped reads are only implemented for
+// Unix, and the pager falls back to ReadAt.
+func mmapFile(file *os.File, size int) ([]byte, error) {
+	return nil, errors.New("mmap is not supported on this platform")
+}
+
+// munmapFile is never called, since mmapFile never succeeds.
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPagerMmap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("mmap is not supported on this platform")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mmap.db")
+
+	// A small cache, so reads go to the file
+	pager, err := NewPager(path, WithMmap(), WithMaxCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+	if pager.Mapped() {
+		t.Error("expected an empty file not to be mapped")
+	}
+
+	write := func(from, to int) {
+		for i := from; i < to; i++ {
+			page, _ := pager.AllocatePage(PageTypeData)
+			page.WriteData([]byte(fmt.Sprintf("page %d", i)))
+		}
+		if err := pager.FlushAll(); err != nil {
+			t.Fatalf("FlushAll failed: %v", err)
+		}
+	}
+	check := func(pageID int) {
+		t.Helper()
+		page, err := pager.GetPage(uint32(pageID))
+		if err != nil {
+			t.Fatalf("GetPage(%d) failed: %v", pageID, err)
+		}
+		want := fmt.Sprintf("page %d", pageID)
+		if got := string(page.ReadData(0, uint16(len(want)))); got != want {
+			t.Errorf("page %d: expected %q, got %q", pageID, want, got)
+		}
+	}
+
+	write(0, 5)
+	for i := 0; i < 5; i++ {
+		check(i)
+	}
+	if !pager.Mapped() {
+		t.Fatal("expected the file to be mapped once it has pages")
+	}
+
+	// Pages written past the end of the mapping are found by remapping
+	write(5, 10)
+	for i := 0; i < 10; i++ {
+		check(i)
+	}
+
+	// Shrinking the file drops and remakes the mapping
+	src, err := NewPager(filepath.Join(dir, "src.db"))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer src.Close()
+	for i := 0; i < 3; i++ {
+		page, _ := src.AllocatePage(PageTypeData)
+		page.WriteData([]byte(fmt.Sprintf("page %d", i)))
+	}
+	if err := pager.CopyFrom(src); err != nil {
+		t.Fatalf("CopyFrom failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		check(i)
+	}
+	if !pager.Mapped() {
+		t.Error("expected the file to be mapped again after shrinking")
+	}
+}
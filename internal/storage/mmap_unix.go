@@ -0,0 +1,19 @@
+//go:build unix
+
+package storage
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the first size bytes of file read-only and shared, so
+// later writes to the file show through.
+func mmapFile(file *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// munmapFile removes a mapping made by mmapFile.
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
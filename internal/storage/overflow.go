@@ -0,0 +1,125 @@
+// Package storage - Overflow pages
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A record has to fit in a page, but a TEXT value can be far bigger than
+// one. Large values are moved out of the page into a chain of overflow
+// pages, and the record keeps only a small pointer to the chain:
+//
+//   data page                 overflow pages
+//   +--------------------+    +-------------+    +-------------+
+//   | ... [len][page 7]  |--->| next: 9     |--->| next: 0     |
+//   +--------------------+    | 4076 bytes  |    | the rest    |
+//                             +-------------+    +-------------+
+//
+// Each overflow page starts with the ID of the next page in the chain
+// (0 ends it), followed by as much of the value as fits. Reading the
+// value back means following the chain, one page read per 4 KB.
+//
+// SQLite spills the tail of a large row into overflow pages the same way;
+// PostgreSQL's TOAST instead stores large values, compressed and cut into
+// chunks, in a separate table.
+//
+// When a row that owns a chain is updated or deleted, the chain is simply
+// left behind until VACUUM rebuilds the file without it; only dropping
+// the whole table gives the chains of its rows back to the free list.
+
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// overflowHeaderSize is the size of the next page ID that starts the
+	// data of each overflow page.
+	overflowHeaderSize = 4
+
+	// overflowChunkSize is how much of a value each overflow page holds.
+	overflowChunkSize = MaxDataSize - overflowHeaderSize
+)
+
+// WriteOverflow stores data in a chain of new overflow pages and returns
+// the ID of the first one.
+func (p *Pager) WriteOverflow(data []byte) (uint32, error) {
+	count := (len(data) + overflowChunkSize - 1) / overflowChunkSize
+	if count == 0 {
+		count = 1
+	}
+
+	// Allocate the whole chain first, so each page knows its successor
+	pages := make([]*Page, count)
+	for i := range pages {
+		page, err := p.AllocatePage(PageTypeOverflow)
+		if err != nil {
+			return 0, err
+		}
+		pages[i] = page
+	}
+
+	for i, page := range pages {
+		chunk := data[min(i*overflowChunkSize, len(data)):min((i+1)*overflowChunkSize, len(data))]
+		buf := make([]byte, overflowHeaderSize+len(chunk))
+		if i+1 < len(pages) {
+			binary.LittleEndian.PutUint32(buf, pages[i+1].ID())
+		}
+		copy(buf[overflowHeaderSize:], chunk)
+		if err := page.SetData(buf); err != nil {
+			return 0, err
+		}
+	}
+
+	return pages[0].ID(), nil
+}
+
+// ReadOverflow reads length bytes from the overflow chain starting at
+// pageID.
+func (p *Pager) ReadOverflow(pageID uint32, length int) ([]byte, error) {
+	data := make([]byte, 0, length)
+	err := p.walkOverflow(pageID, length, func(_ uint32, chunk []byte) {
+		data = append(data, chunk...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// OverflowPages returns the IDs of the pages in the overflow chain holding
+// length bytes from pageID.
+func (p *Pager) OverflowPages(pageID uint32, length int) ([]uint32, error) {
+	var pages []uint32
+	err := p.walkOverflow(pageID, length, func(pageID uint32, _ []byte) {
+		pages = append(pages, pageID)
+	})
+	return pages, err
+}
+
+// walkOverflow follows the overflow chain holding length bytes from
+// pageID, calling fn with each page and the part of the value it holds.
+func (p *Pager) walkOverflow(pageID uint32, length int, fn func(pageID uint32, chunk []byte)) error {
+	read := 0
+	for {
+		page, err := p.GetPage(pageID)
+		if err != nil {
+			return fmt.Errorf("failed to get overflow page %d: %w", pageID, err)
+		}
+		if page.Type() != PageTypeOverflow {
+			return fmt.Errorf("page %d is not an overflow page", pageID)
+		}
+
+		pageData := page.GetData()
+		chunk := min(length-read, overflowChunkSize)
+		fn(pageID, pageData[overflowHeaderSize:overflowHeaderSize+chunk])
+		read += chunk
+		if read == length {
+			return nil
+		}
+
+		pageID = binary.LittleEndian.Uint32(pageData)
+		if pageID == 0 {
+			return fmt.Errorf("overflow chain ended after %d of %d bytes", read, length)
+		}
+	}
+}
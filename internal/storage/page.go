@@ -15,8 +15,14 @@ package storage
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 )
 
+// ErrChecksumMismatch is returned when a page read back from storage
+// doesn't match the checksum written with it.
+var ErrChecksumMismatch = errors.New("page checksum mismatch")
+
 const (
 	// PageSize is the size of each page in bytes.
 	// Real databases use 4KB-16KB. We use 4KB for simplicity.
@@ -28,6 +34,9 @@ const (
 
 	// MaxDataSize is the maximum amount of data a page can hold.
 	MaxDataSize = PageSize - PageHeaderSize
+
+	// checksumOffset is where a page's checksum is stored in its header.
+	checksumOffset = 12
 )
 
 // PageType indicates what kind of data a page holds.
@@ -42,6 +51,8 @@ const (
 	PageTypeBTreeInternal
 	// PageTypeBTreeLeaf indicates a B-tree leaf node.
 	PageTypeBTreeLeaf
+	// PageTypeOverflow indicates part of a value too large for its page.
+	PageTypeOverflow
 )
 
 // Page represents a fixed-size block of storage.
@@ -53,7 +64,8 @@ const (
 // |   - Type (1)     |
 // |   - NumSlots (2) |
 // |   - FreeSpace (2)|
-// |   - Reserved (7) |
+// |   - Reserved (3) |
+// |   - Checksum (4) |
 // +------------------+
 // | Data Area        |
 // | (4080 bytes)     |
@@ -119,6 +131,11 @@ func (p *Page) MarkClean() {
 	p.dirty = false
 }
 
+// MarkDirty marks the page as modified, for changes made through GetData.
+func (p *Page) MarkDirty() {
+	p.dirty = true
+}
+
 // WriteData writes data to the page at the current free space offset.
 // Returns the offset where data was written, or an error if not enough space.
 func (p *Page) WriteData(data []byte) (uint16, error) {
@@ -168,6 +185,13 @@ func (p *Page) SetData(data []byte) error {
 // Serialization is the process of converting in-memory structures to bytes.
 // We use little-endian byte order (least significant byte first) because
 // it's the native format on most modern CPUs (x86, ARM).
+//
+// The last four header bytes hold a CRC-32 checksum of the whole page,
+// computed with those bytes zeroed. Disks and filesystems can silently
+// return different bytes than were written (a bad sector, a torn write,
+// a bug); the checksum lets Deserialize notice instead of handing
+// garbage to the B-tree code. PostgreSQL (data_checksums) and SQLite's
+// cksumvfs do the same.
 func (p *Page) Serialize() []byte {
 	buf := make([]byte, PageSize)
 
@@ -176,19 +200,37 @@ func (p *Page) Serialize() []byte {
 	buf[4] = byte(p.pageType)
 	binary.LittleEndian.PutUint16(buf[5:7], p.numSlots)
 	binary.LittleEndian.PutUint16(buf[7:9], p.freeSpaceOffset)
-	// Bytes 9-15 are reserved for future use
+	// Bytes 9-11 are reserved for future use
 
 	// Write data
 	copy(buf[PageHeaderSize:], p.data[:])
 
+	binary.LittleEndian.PutUint32(buf[checksumOffset:], pageChecksum(buf))
 	return buf
 }
 
-// Deserialize reads a page from a byte slice.
+// pageChecksum computes the checksum of a serialized page, skipping the
+// checksum field itself.
+func pageChecksum(buf []byte) uint32 {
+	sum := crc32.ChecksumIEEE(buf[:checksumOffset])
+	sum = crc32.Update(sum, crc32.IEEETable, make([]byte, 4))
+	return crc32.Update(sum, crc32.IEEETable, buf[checksumOffset+4:])
+}
+
+// Deserialize reads a page from a byte slice, verifying its checksum.
+//
+// A stored checksum of zero means none was written, as in files created
+// before checksums were added, and isn't checked.
 func Deserialize(buf []byte) (*Page, error) {
 	if len(buf) != PageSize {
 		return nil, errors.New("invalid page size")
 	}
+	if stored := binary.LittleEndian.Uint32(buf[checksumOffset:]); stored != 0 {
+		if computed := pageChecksum(buf); computed != stored {
+			return nil, fmt.Errorf("%w: page %d has checksum %08x, contents give %08x",
+				ErrChecksumMismatch, binary.LittleEndian.Uint32(buf[0:4]), stored, computed)
+		}
+	}
 
 	p := &Page{
 		id:              binary.LittleEndian.Uint32(buf[0:4]),
@@ -12,18 +12,21 @@
 // 4. Allocating new pages
 // 5. Managing a simple page cache (buffer pool)
 //
+// With WithWAL, writes go to a write-ahead log instead of the database
+// file (see wal.go). Opening a database whose log was left behind by a
+// crash replays the committed transactions in it before anything is read.
+//
 // In production databases, the pager would also handle:
-// - Write-ahead logging (WAL) for crash recovery
 // - Page checksums for corruption detection
 // - Background flushing of dirty pages
 
 package storage
 
 import (
-	"container/list"
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"sync"
 )
 
@@ -33,29 +36,128 @@ const DefaultMaxCacheSize = 1000
 
 // Pager manages reading and writing pages to the database file.
 type Pager struct {
-	file     *os.File
+	file     dbFile
 	filePath string
 
 	// pageCount is the total number of pages in the file.
 	pageCount uint32
 
-	// cache is an in-memory cache of pages with LRU eviction.
+	// freeHead is the first page of the free list, or 0, and freeCount
+	// the number of pages on it (see freelist.go).
+	freeHead  uint32
+	freeCount uint32
+
+	// flushed is the page count and free list as of the last flush, which
+	// Discard goes back to (see diskfull.go).
+	flushed struct {
+		pageCount, freeHead, freeCount uint32
+	}
+
+	// cache is an in-memory cache of pages.
 	cache map[uint32]*Page
 
-	// lruList maintains pages in LRU order (most recently used at front).
-	// Each element's Value is the page ID (uint32).
-	lruList *list.List
+	// evictor chooses which cached page to drop when the cache is full,
+	// following evictionPolicy (see eviction.go).
+	evictor        evictor
+	evictionPolicy EvictionPolicy
+
+	// codec encodes pages on their way to storage, or is nil (see
+	// WithCodec). compress asks for a new database to be compressed.
+	codec    PageCodec
+	compress bool
 
-	// lruMap provides O(1) access from page ID to its list element.
-	lruMap map[uint32]*list.Element
+	// pins counts the pins held on each pinned page; pinned pages are
+	// never evicted (see pin.go).
+	pins map[uint32]int
 
 	// maxCacheSize is the maximum number of pages to keep in cache.
 	maxCacheSize int
 
+	// unsynced is set when pages have been written to the database file
+	// since the last fsync (see flushAllLocked).
+	unsynced bool
+
+	// io counts page accesses and disk I/O (see IOStats).
+	io IOStats
+
+	// pool counts cache hits, misses and evictions (see Stats).
+	pool PagerStats
+
+	// tx is the open transaction, or nil (see Begin).
+	tx *pagerTx
+
+	// wal is the write-ahead log, or nil if pages are written directly
+	// to the database file (see WithWAL).
+	wal              *WAL
+	useWAL           bool
+	checkpointFrames int
+
+	// mapped is a read-only memory mapping of the database file, or nil
+	// if pages are read with ReadAt (see WithMmap).
+	mapped  []byte
+	useMmap bool
+
+	// readOnly refuses writes; the file is then locked shared rather than
+	// exclusively (see WithReadOnly).
+	readOnly bool
+
 	// mu protects concurrent access to the pager.
 	mu sync.RWMutex
 }
 
+// IOStats counts the pager's page traffic since it was opened.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// PageRequests counts every GetPage call (a "logical read"); DiskReads
+// counts only the cache misses that went to the file (a "physical read").
+// Their ratio is the cache hit rate - the most important number when
+// sizing a buffer pool. EXPLAIN ANALYZE reports the difference in these
+// counters before and after running a query.
+//
+// Syncs counts the fsyncs of the database file, each of which waits for
+// the disk; see flushAllLocked.
+type IOStats struct {
+	PageRequests uint64
+	DiskReads    uint64
+	DiskWrites   uint64
+	Syncs        uint64
+}
+
+// PagerStats describes how well the page cache (the buffer pool) is
+// working, as returned by Pager.Stats.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A low hit rate with many evictions means the working set doesn't fit
+// and a bigger cache would save disk reads; a high hit rate with a cache
+// that's never full means memory could be given back. Dirty evictions
+// are the expensive kind: the page has to be written before its slot can
+// be reused, so the read that needed the slot waits for a write too.
+// PostgreSQL's pg_buffercache and pg_stat_database show the same numbers.
+type PagerStats struct {
+	IOStats
+
+	Hits           uint64 // page requests answered from the cache
+	Misses         uint64 // page requests read from disk
+	Evictions      uint64 // pages dropped from the cache to make room
+	DirtyEvictions uint64 // evicted pages that had to be written first
+
+	CachedPages  int // pages in the cache now
+	DirtyPages   int // cached pages with changes not yet written
+	PinnedPages  int // cached pages that can't be evicted (see PinPage)
+	MaxCacheSize int
+}
+
+// HitRate returns the fraction of page requests answered from the cache,
+// or 0 if there have been none.
+func (s PagerStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
 // PagerOption is a functional option for configuring the Pager.
 type PagerOption func(*Pager)
 
@@ -68,33 +170,38 @@ func WithMaxCacheSize(size int) PagerOption {
 	}
 }
 
-// NewPager creates a new pager for the given file path.
-// If the file doesn't exist, it will be created.
-// Optional PagerOption functions can be passed to configure the pager.
-func NewPager(filePath string, opts ...PagerOption) (*Pager, error) {
-	// Open file with read/write permissions, create if doesn't exist
-	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database file: %w", err)
+// WithWAL makes the pager write changed pages to a write-ahead log and
+// copy them into the database file at checkpoints.
+func WithWAL() PagerOption {
+	return func(p *Pager) {
+		p.useWAL = true
 	}
+}
 
-	// Get file size to determine page count
-	stat, err := file.Stat()
-	if err != nil {
-		file.Close()
-		return nil, fmt.Errorf("failed to stat database file: %w", err)
+// WithCheckpointFrames sets how many frames the write-ahead log may hold
+// before a commit triggers a checkpoint.
+func WithCheckpointFrames(frames int) PagerOption {
+	return func(p *Pager) {
+		if frames > 0 {
+			p.checkpointFrames = frames
+		}
 	}
+}
 
-	pageCount := uint32(stat.Size() / PageSize)
-
+// NewPager creates a new pager for the given file path.
+// If the file doesn't exist, it will be created; the path MemoryPath
+// (":memory:") creates an in-memory database instead.
+// Optional PagerOption functions can be passed to configure the pager.
+// It fails with ErrLocked if another process has the file open (see
+// filelock.go).
+func NewPager(filePath string, opts ...PagerOption) (*Pager, error) {
 	p := &Pager{
-		file:         file,
 		filePath:     filePath,
-		pageCount:    pageCount,
 		cache:        make(map[uint32]*Page),
-		lruList:      list.New(),
-		lruMap:       make(map[uint32]*list.Element),
+		pins:         make(map[uint32]int),
 		maxCacheSize: DefaultMaxCacheSize,
+
+		checkpointFrames: DefaultWALCheckpointFrames,
 	}
 
 	// Apply options
@@ -102,23 +209,103 @@ func NewPager(filePath string, opts ...PagerOption) (*Pager, error) {
 		opt(p)
 	}
 
+	var file dbFile = &memFile{}
+	var fileSize int64
+	if filePath != MemoryPath {
+		// Open file with read/write permissions, create if doesn't exist
+		flags := os.O_RDWR | os.O_CREATE
+		if p.readOnly {
+			flags = os.O_RDONLY
+		}
+		osFile, err := os.OpenFile(filePath, flags, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database file: %w", err)
+		}
+		if err := lockFile(osFile, !p.readOnly); err != nil {
+			osFile.Close()
+			return nil, err
+		}
+
+		// Get file size to determine page count
+		stat, err := osFile.Stat()
+		if err != nil {
+			osFile.Close()
+			return nil, fmt.Errorf("failed to stat database file: %w", err)
+		}
+
+		file = osFile
+		fileSize = stat.Size()
+	}
+	p.file = file
+
+	p.evictor = newEvictor(p.evictionPolicy, p.maxCacheSize)
+	if osFile, ok := file.(*os.File); ok {
+		var err error
+		if fileSize, err = p.openCompressed(osFile, fileSize); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	p.pageCount = uint32(fileSize / slotSize(p.codec))
+	if p.InMemory() {
+		// Nothing on disk to protect or map
+		p.useWAL = false
+		p.useMmap = false
+	}
+	if p.readOnly && p.useWAL {
+		// Nothing is written to log, but a log left by a crash holds
+		// commits the file doesn't have yet
+		p.useWAL = false
+		if stat, err := os.Stat(WALPath(filePath)); err == nil && stat.Size() > walHeaderSize {
+			file.Close()
+			return nil, fmt.Errorf("database %s has a write-ahead log to recover; open it for writing first", filePath)
+		}
+	}
+
+	if p.useWAL {
+		var err error
+		if p.wal, err = OpenWAL(filePath, p.codec); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := p.recoverLocked(); err != nil {
+			p.wal.Close(false)
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if p.useMmap {
+		p.remapLocked()
+	}
+	p.markFlushedLocked()
+
 	return p, nil
 }
 
-// Close flushes all dirty pages and closes the database file.
+// Close flushes all dirty pages and closes the database file. Changes
+// made in an open transaction are discarded.
 func (p *Pager) Close() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	// Flush all dirty pages before closing
-	for _, page := range p.cache {
-		if page.IsDirty() {
-			if err := p.flushPageLocked(page); err != nil {
-				return fmt.Errorf("failed to flush page %d: %w", page.ID(), err)
-			}
+	if err := p.flushAllLocked(); err != nil {
+		return err
+	}
+
+	if p.wal != nil {
+		if err := p.wal.Checkpoint(p.file); err != nil {
+			return err
+		}
+		if err := p.wal.Close(true); err != nil {
+			return fmt.Errorf("failed to close WAL: %w", err)
 		}
 	}
 
+	if err := p.unmapLocked(); err != nil {
+		return fmt.Errorf("failed to unmap database file: %w", err)
+	}
 	return p.file.Close()
 }
 
@@ -129,17 +316,22 @@ func (p *Pager) Close() error {
 // This is where the caching magic happens. We first check if the page
 // is already in memory (cache hit). If not, we read it from disk (cache miss).
 // This is similar to how CPU caches work - frequently accessed data stays
-// in fast memory. We use LRU (Least Recently Used) eviction to bound memory.
+// in fast memory. An eviction policy bounds memory (see eviction.go).
 func (p *Pager) GetPage(pageID uint32) (*Page, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	return p.getPageLocked(pageID)
+}
+
+// getPageLocked implements GetPage. Caller must hold the lock.
+func (p *Pager) getPageLocked(pageID uint32) (*Page, error) {
+	p.io.PageRequests++
 
 	// Check cache first (cache hit)
 	if page, ok := p.cache[pageID]; ok {
-		// Move to front of LRU list (most recently used)
-		if elem, exists := p.lruMap[pageID]; exists {
-			p.lruList.MoveToFront(elem)
-		}
+		p.pool.Hits++
+		p.evictor.access(pageID)
+		p.saveBeforeImageLocked(page)
 		return page, nil
 	}
 
@@ -158,12 +350,14 @@ func (p *Pager) GetPage(pageID uint32) (*Page, error) {
 	if err != nil {
 		return nil, err
 	}
+	p.io.DiskReads++
+	p.pool.Misses++
 
-	// Add to cache and LRU list
+	// Add to cache
 	p.cache[pageID] = page
-	elem := p.lruList.PushFront(pageID)
-	p.lruMap[pageID] = elem
+	p.evictor.add(pageID)
 
+	p.saveBeforeImageLocked(page)
 	return page, nil
 }
 
@@ -171,13 +365,21 @@ func (p *Pager) GetPage(pageID uint32) (*Page, error) {
 //
 // EDUCATIONAL NOTE:
 // -----------------
-// When we need a new page, we allocate space at the end of the file.
-// A more sophisticated implementation would maintain a free list of
-// previously deleted pages to reuse.
+// When we need a new page, we first look for one that was freed (see
+// freelist.go); only when there is none do we allocate space at the end
+// of the file.
 func (p *Pager) AllocatePage(pageType PageType) (*Page, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	if page, err := p.reuseFreePageLocked(pageType); page != nil || err != nil {
+		return page, err
+	}
+
 	// Evict if cache is full before adding new page
 	if err := p.evictIfNeededLocked(); err != nil {
 		return nil, fmt.Errorf("failed to evict page: %w", err)
@@ -187,10 +389,9 @@ func (p *Pager) AllocatePage(pageType PageType) (*Page, error) {
 	page := NewPage(p.pageCount, pageType)
 	p.pageCount++
 
-	// Add to cache and LRU list
+	// Add to cache
 	p.cache[page.ID()] = page
-	elem := p.lruList.PushFront(page.ID())
-	p.lruMap[page.ID()] = elem
+	p.evictor.add(page.ID())
 
 	return page, nil
 }
@@ -201,26 +402,206 @@ func (p *Pager) FlushPage(pageID uint32) error {
 	defer p.mu.Unlock()
 
 	page, ok := p.cache[pageID]
-	if !ok {
-		return nil // Page not in cache, nothing to flush
+	if !ok || !page.IsDirty() || p.tx != nil {
+		return nil // nothing to flush
 	}
 
-	return p.flushPageLocked(page)
+	if err := p.writePageLocked(page); err != nil {
+		return err
+	}
+	return p.syncLocked()
 }
 
-// FlushAll writes all dirty pages to disk.
+// FlushAll writes all dirty pages to disk. With a write-ahead log this
+// is a commit: the pages are appended to the log with a single fsync.
 func (p *Pager) FlushAll() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	return p.flushAllLocked()
+}
+
+// Checkpoint commits any dirty pages and copies the write-ahead log into
+// the database file. Without a log it is the same as FlushAll.
+func (p *Pager) Checkpoint() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tx != nil {
+		return errors.New("cannot checkpoint inside a transaction")
+	}
+	if err := p.flushAllLocked(); err != nil {
+		return err
+	}
+	if p.wal == nil {
+		return nil
+	}
+	return p.wal.Checkpoint(p.file)
+}
+
+// CopyFrom replaces every page of the database with the pages of src and
+// shrinks the file to src's size. VACUUM uses it to install a compacted
+// copy of the database built in a scratch file.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// The pages are copied through the cache like any other change. With a
+// write-ahead log the whole copy is then committed with one fsync, so a
+// crash part way through leaves the old database intact. The file can
+// only be truncated after a checkpoint: until then the log may hold
+// frames for the pages being cut off. Without a log, a crash during the
+// copy leaves a mix of old and new pages, as with any write that spans
+// several pages.
+//
+// A *Page obtained before the copy sees the new contents, but anything
+// that remembers page IDs (tables, B-trees) must be reloaded.
+func (p *Pager) CopyFrom(src *Pager) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tx != nil {
+		return errors.New("cannot replace the database inside a transaction")
+	}
+
+	pageCount := src.PageCount()
+	for pageID := uint32(0); pageID < pageCount; pageID++ {
+		srcPage, err := src.GetPage(pageID)
+		if err != nil {
+			return fmt.Errorf("failed to read page %d to copy: %w", pageID, err)
+		}
+		copied, err := Deserialize(srcPage.Serialize())
+		if err != nil {
+			return err
+		}
+		copied.dirty = true
+
+		if page, ok := p.cache[pageID]; ok {
+			*page = *copied
+			p.evictor.access(pageID)
+			continue
+		}
+		if err := p.evictIfNeededLocked(); err != nil {
+			return fmt.Errorf("failed to evict page: %w", err)
+		}
+		p.cache[pageID] = copied
+		p.evictor.add(pageID)
+	}
 
+	// Forget pages past the new end of the database
+	for pageID := range p.cache {
+		if pageID >= pageCount {
+			p.evictor.remove(pageID)
+			delete(p.cache, pageID)
+		}
+	}
+	p.pageCount = pageCount
+	p.freeHead, p.freeCount = src.FreeList()
+
+	if err := p.flushAllLocked(); err != nil {
+		return err
+	}
+	if p.wal != nil {
+		if err := p.wal.Checkpoint(p.file); err != nil {
+			return err
+		}
+	}
+	return p.truncateLocked(pageCount)
+}
+
+// WALFrames returns the number of frames in the write-ahead log, or 0
+// without one.
+func (p *Pager) WALFrames() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.wal == nil {
+		return 0
+	}
+	return p.wal.Frames()
+}
+
+// RecoveredFrames returns the number of log frames replayed when the
+// database was opened after an unclean shutdown.
+func (p *Pager) RecoveredFrames() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.wal == nil {
+		return 0
+	}
+	frames, _ := p.wal.Recovered()
+	return frames
+}
+
+// recoverLocked replays committed transactions found in the log at open.
+// The database file may be shorter than the last commit says, since pages
+// allocated since the last checkpoint exist only in the log.
+func (p *Pager) recoverLocked() error {
+	frames, dbSize := p.wal.Recovered()
+	if frames == 0 {
+		return nil
+	}
+	p.pageCount = max(p.pageCount, dbSize)
+	if err := p.wal.Checkpoint(p.file); err != nil {
+		return fmt.Errorf("failed to replay WAL: %w", err)
+	}
+	return nil
+}
+
+// flushAllLocked writes every dirty page. Caller must hold the lock.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A write only hands the data to the operating system; fsync is what
+// waits for it to reach the disk, and it takes milliseconds on a real
+// drive where the write takes microseconds. Syncing after every page
+// made a bulk load of 10,000 rows wait for thousands of fsyncs. Instead
+// the pages are all written, in file order, and then synced once: one
+// wait for the whole batch. This is the idea behind "group commit", where
+// databases gather the commits of many transactions into one log fsync.
+//
+// Pages written when they're evicted from the cache aren't synced until
+// the next flush. Without a write-ahead log a crash could already leave a
+// mix of old and new pages, so nothing is lost that wasn't before; with
+// the log, frames are only synced at commit anyway.
+func (p *Pager) flushAllLocked() error {
+	if p.tx != nil {
+		return nil // written at Commit
+	}
+
+	var dirty []*Page
 	for _, page := range p.cache {
 		if page.IsDirty() {
-			if err := p.flushPageLocked(page); err != nil {
+			dirty = append(dirty, page)
+		}
+	}
+	sort.Slice(dirty, func(i, j int) bool { return dirty[i].ID() < dirty[j].ID() })
+
+	if p.wal == nil {
+		for _, page := range dirty {
+			if err := p.writePageLocked(page); err != nil {
 				return err
 			}
 		}
+		if err := p.syncLocked(); err != nil {
+			return err
+		}
+		p.markFlushedLocked()
+		return nil
 	}
 
+	if err := p.wal.WriteFrames(dirty, true, p.pageCount); err != nil {
+		return err
+	}
+	p.io.DiskWrites += uint64(len(dirty))
+	for _, page := range dirty {
+		page.MarkClean()
+	}
+	p.markFlushedLocked()
+
+	if p.wal.Frames() >= p.checkpointFrames {
+		// The pages are committed to the log either way; if the
+		// checkpoint fails (the disk is full, say) the log keeps them
+		// and the next commit tries again
+		p.wal.Checkpoint(p.file)
+	}
 	return nil
 }
 
@@ -238,6 +619,58 @@ func (p *Pager) CacheSize() int {
 	return len(p.cache)
 }
 
+// IOStats returns a snapshot of the page access counters.
+func (p *Pager) IOStats() IOStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.io
+}
+
+// Stats returns a snapshot of the buffer pool's counters and contents.
+func (p *Pager) Stats() PagerStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := p.pool
+	stats.IOStats = p.io
+	stats.CachedPages = len(p.cache)
+	stats.MaxCacheSize = p.maxCacheSize
+	stats.PinnedPages = len(p.pins)
+	for _, page := range p.cache {
+		if page.IsDirty() {
+			stats.DirtyPages++
+		}
+	}
+	return stats
+}
+
+// SetMaxCacheSize changes the maximum cache size while the pager is open,
+// evicting pages at once if the cache holds more than size. Pages that
+// can't be evicted yet, pinned or changed by the open transaction, stay
+// until they can.
+func (p *Pager) SetMaxCacheSize(size int) error {
+	if size <= 0 {
+		return fmt.Errorf("cache size must be positive, got %d", size)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.maxCacheSize = size
+	if q, ok := p.evictor.(*twoQueueEvictor); ok {
+		q.resize(size)
+	}
+	for len(p.cache) > size {
+		before := len(p.cache)
+		if err := p.evictIfNeededLocked(); err != nil {
+			return err
+		}
+		if len(p.cache) == before {
+			break
+		}
+	}
+	return nil
+}
+
 // MaxCacheSize returns the maximum cache size.
 func (p *Pager) MaxCacheSize() int {
 	p.mu.RLock()
@@ -245,99 +678,365 @@ func (p *Pager) MaxCacheSize() int {
 	return p.maxCacheSize
 }
 
-// evictIfNeededLocked evicts the least recently used page if cache is at capacity.
-// Caller must hold the lock.
+// evictIfNeededLocked evicts the page the eviction policy chooses if the
+// cache is at capacity. Caller must hold the lock.
 //
 // EDUCATIONAL NOTE:
 // -----------------
-// LRU (Least Recently Used) eviction removes pages that haven't been accessed recently.
-// We track access order with a doubly-linked list for O(1) operations:
-// - Access a page: move to front (O(1))
-// - Evict: remove from back (O(1))
-// - Find in list: use map for O(1) lookup
-// Before evicting a dirty page, we must write it back to disk to preserve changes.
+// Before evicting a dirty page, we must write it back to disk to preserve
+// changes. Inside a transaction dirty pages can't be written until
+// commit, so the policy passes over them, as it does over pinned pages;
+// if no page can be evicted the cache grows instead.
 func (p *Pager) evictIfNeededLocked() error {
 	// Only evict if we're at capacity
 	if len(p.cache) < p.maxCacheSize {
 		return nil
 	}
 
-	// Get the least recently used page (back of list)
-	back := p.lruList.Back()
-	if back == nil {
+	pageID, ok := p.evictor.victim(func(pageID uint32) bool {
+		if p.pins[pageID] > 0 {
+			return true
+		}
+		page, exists := p.cache[pageID]
+		return exists && p.tx != nil && page.IsDirty()
+	})
+	if !ok {
 		return nil
 	}
 
-	pageID := back.Value.(uint32)
 	page, exists := p.cache[pageID]
 	if !exists {
-		// Inconsistent state - remove from LRU anyway
-		p.lruList.Remove(back)
-		delete(p.lruMap, pageID)
+		// Inconsistent state - forget the page anyway
+		p.evictor.remove(pageID)
 		return nil
 	}
 
 	// Write dirty page to disk before eviction
 	if page.IsDirty() {
-		if err := p.flushPageLocked(page); err != nil {
+		if err := p.writePageLocked(page); err != nil {
 			return fmt.Errorf("failed to flush dirty page %d before eviction: %w", pageID, err)
 		}
+		p.pool.DirtyEvictions++
 	}
+	p.pool.Evictions++
 
-	// Remove from cache and LRU tracking
+	// Remove from cache and eviction tracking
 	delete(p.cache, pageID)
-	p.lruList.Remove(back)
-	delete(p.lruMap, pageID)
+	p.evictor.evicted(pageID)
 
 	return nil
 }
 
-// readPageFromDisk reads a page from the database file.
+// readPageFromDisk reads a page from the database file, or from the
+// write-ahead log if it holds a newer version.
 func (p *Pager) readPageFromDisk(pageID uint32) (*Page, error) {
+	if p.wal != nil && p.wal.Contains(pageID) {
+		return p.wal.ReadPage(pageID)
+	}
+	if data := p.mappedPageLocked(pageID); data != nil {
+		return decodePage(p.codec, pageID, data)
+	}
+
 	// Calculate file offset for this page
-	offset := int64(pageID) * PageSize
+	slot := slotSize(p.codec)
+	offset := int64(pageID) * slot
 
 	// Read page data
-	buf := make([]byte, PageSize)
+	buf := make([]byte, slot)
 	n, err := p.file.ReadAt(buf, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read page %d: %w", pageID, err)
 	}
-	if n != PageSize {
-		return nil, fmt.Errorf("short read for page %d: got %d bytes, expected %d", pageID, n, PageSize)
+	if n != len(buf) {
+		return nil, fmt.Errorf("short read for page %d: got %d bytes, expected %d", pageID, n, len(buf))
 	}
 
-	return Deserialize(buf)
+	return decodePage(p.codec, pageID, buf)
 }
 
-// flushPageLocked writes a page to disk. Caller must hold the lock.
-func (p *Pager) flushPageLocked(page *Page) error {
-	if !page.IsDirty() {
+// writePageLocked writes a dirty page to the database file, or to the
+// write-ahead log as an uncommitted frame, without waiting for it to
+// reach the disk (see syncLocked). Caller must hold the lock. Inside a
+// transaction nothing is written until Commit.
+func (p *Pager) writePageLocked(page *Page) error {
+	if !page.IsDirty() || p.tx != nil {
+		return nil
+	}
+	if p.readOnly {
+		return ErrReadOnly
+	}
+
+	if p.wal != nil {
+		if err := p.wal.WriteFrames([]*Page{page}, false, 0); err != nil {
+			return err
+		}
+		p.io.DiskWrites++
+		page.MarkClean()
 		return nil
 	}
 
 	// Calculate file offset
-	offset := int64(page.ID()) * PageSize
+	offset := int64(page.ID()) * slotSize(p.codec)
 
 	// Serialize and write
-	data := page.Serialize()
-	n, err := p.file.WriteAt(data, offset)
+	data, err := encodePage(p.codec, page)
 	if err != nil {
-		return fmt.Errorf("failed to write page %d: %w", page.ID(), err)
+		return err
 	}
-	if n != PageSize {
-		return fmt.Errorf("short write for page %d: wrote %d bytes, expected %d", page.ID(), n, PageSize)
+	if err := writeFull(p.file, data, offset); err != nil {
+		return fmt.Errorf("failed to write page %d: %w", page.ID(), err)
 	}
 
-	// Sync to ensure data is on disk
+	p.io.DiskWrites++
+	p.unsynced = true
+	page.MarkClean()
+	return nil
+}
+
+// syncLocked waits for everything written to the database file to reach
+// the disk, if anything was written since the last sync. Caller must hold
+// the lock.
+func (p *Pager) syncLocked() error {
+	if !p.unsynced || p.InMemory() {
+		return nil
+	}
 	if err := p.file.Sync(); err != nil {
-		return fmt.Errorf("failed to sync after writing page %d: %w", page.ID(), err)
+		return fmt.Errorf("failed to sync database file: %w", diskError(err))
 	}
+	p.io.Syncs++
+	p.unsynced = false
+	return nil
+}
 
-	page.MarkClean()
+// ============================================================================
+// Transactions
+// ============================================================================
+
+// pagerTx holds what is needed to undo an open transaction.
+type pagerTx struct {
+	// frames[0] covers the whole transaction; each savepoint pushes
+	// another frame on top.
+	frames []*txFrame
+}
+
+// txFrame records what changed since Begin or since a savepoint.
+type txFrame struct {
+	// beforeImages holds each page's bytes as they were when it was
+	// first touched after the frame began.
+	beforeImages map[uint32][]byte
+
+	// pageCount is the page count when the frame began; later pages are new.
+	pageCount uint32
+
+	// freeHead and freeCount are the free list when the frame began.
+	freeHead  uint32
+	freeCount uint32
+}
+
+// newTxFrame starts a frame at the current page count. Caller must hold
+// the lock.
+func (p *Pager) newTxFrame() *txFrame {
+	return &txFrame{
+		beforeImages: make(map[uint32][]byte),
+		pageCount:    p.pageCount,
+		freeHead:     p.freeHead,
+		freeCount:    p.freeCount,
+	}
+}
+
+// Begin starts a transaction. Until Commit or Rollback, no page is written
+// to the file.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// This is the simplest form of atomicity: keep every change in memory and
+// write nothing until commit. To make rollback possible, the first time a
+// transaction touches a page we save a copy of it (its "before-image").
+// Rolling back copies the before-images over the modified pages and
+// forgets any pages allocated since Begin.
+//
+// The price is that a transaction's changes must fit in memory: dirty
+// pages can't be evicted, because evicting means writing. Real databases
+// lift this limit with an undo or write-ahead log, which also makes the
+// commit itself atomic - here a crash halfway through Commit can still
+// leave some pages written and others not.
+func (p *Pager) Begin() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tx != nil {
+		return errors.New("transaction already in progress")
+	}
+
+	// Start from a clean cache, so every before-image is also what's on disk
+	if err := p.flushAllLocked(); err != nil {
+		return err
+	}
+
+	p.tx = &pagerTx{frames: []*txFrame{p.newTxFrame()}}
+	return nil
+}
+
+// Commit ends the transaction and writes its changes to disk. If they
+// can't be written (the disk is full, say), the transaction is rolled
+// back instead, and the error says so (see diskfull.go).
+func (p *Pager) Commit() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tx == nil {
+		return errors.New("no transaction in progress")
+	}
+	tx := p.tx
+	p.tx = nil
+	if err := p.flushAllLocked(); err != nil {
+		return p.abortCommitLocked(tx, err)
+	}
 	return nil
 }
 
+// Rollback ends the transaction and discards its changes.
+//
+// Pages are restored in place, so a *Page obtained before the rollback
+// sees the restored contents.
+func (p *Pager) Rollback() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tx == nil {
+		return errors.New("no transaction in progress")
+	}
+	if err := p.rollbackToLocked(0); err != nil {
+		return err
+	}
+	p.tx = nil
+	return nil
+}
+
+// Savepoint marks the current state of the transaction and returns its
+// level, for RollbackTo and Release.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Each savepoint starts a new frame of before-images, recording pages as
+// they were at the savepoint. Rolling back to a savepoint replays the
+// frames newest first, so each page ends up as it was when the savepoint
+// was taken. Releasing a savepoint folds its frames into the one below,
+// keeping the older image of any page both recorded.
+func (p *Pager) Savepoint() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.tx == nil {
+		return 0, errors.New("no transaction in progress")
+	}
+	p.tx.frames = append(p.tx.frames, p.newTxFrame())
+	return len(p.tx.frames) - 1, nil
+}
+
+// RollbackTo undoes every change made since the savepoint at level. The
+// savepoint itself remains; later ones are discarded.
+func (p *Pager) RollbackTo(level int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.checkSavepointLocked(level); err != nil {
+		return err
+	}
+	return p.rollbackToLocked(level)
+}
+
+// Release discards the savepoint at level and any later ones, keeping
+// their changes as part of the enclosing transaction or savepoint.
+func (p *Pager) Release(level int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.checkSavepointLocked(level); err != nil {
+		return err
+	}
+
+	parent := p.tx.frames[level-1]
+	for _, frame := range p.tx.frames[level:] {
+		for pageID, image := range frame.beforeImages {
+			if _, saved := parent.beforeImages[pageID]; !saved && pageID < parent.pageCount {
+				parent.beforeImages[pageID] = image
+			}
+		}
+	}
+	p.tx.frames = p.tx.frames[:level]
+	return nil
+}
+
+// InTransaction reports whether a transaction is open.
+func (p *Pager) InTransaction() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.tx != nil
+}
+
+// checkSavepointLocked reports an error unless level names an open
+// savepoint. Caller must hold the lock.
+func (p *Pager) checkSavepointLocked(level int) error {
+	if p.tx == nil {
+		return errors.New("no transaction in progress")
+	}
+	if level < 1 || level >= len(p.tx.frames) {
+		return fmt.Errorf("no savepoint at level %d", level)
+	}
+	return nil
+}
+
+// rollbackToLocked restores pages to their state when frame level began
+// and leaves that frame empty. Caller must hold the lock.
+func (p *Pager) rollbackToLocked(level int) error {
+	frames := p.tx.frames
+	for i := len(frames) - 1; i >= level; i-- {
+		for pageID, image := range frames[i].beforeImages {
+			page, ok := p.cache[pageID]
+			if !ok {
+				continue // evicted while clean, so the file has the original
+			}
+			restored, err := Deserialize(image)
+			if err != nil {
+				return fmt.Errorf("failed to restore page %d: %w", pageID, err)
+			}
+			*page = *restored
+			// At a savepoint the page may still differ from the file
+			page.dirty = level > 0
+		}
+	}
+
+	// Forget pages allocated since the frame began
+	target := frames[level]
+	for pageID := target.pageCount; pageID < p.pageCount; pageID++ {
+		p.evictor.remove(pageID)
+		delete(p.cache, pageID)
+	}
+	p.pageCount = target.pageCount
+	p.freeHead, p.freeCount = target.freeHead, target.freeCount
+
+	p.tx.frames = frames[:level+1]
+	target.beforeImages = make(map[uint32][]byte)
+	return nil
+}
+
+// saveBeforeImageLocked records a page's contents the first time it is
+// touched since the transaction or latest savepoint began. Caller must
+// hold the lock.
+func (p *Pager) saveBeforeImageLocked(page *Page) {
+	if p.tx == nil {
+		return
+	}
+	frame := p.tx.frames[len(p.tx.frames)-1]
+	if page.ID() >= frame.pageCount {
+		return
+	}
+	if _, saved := frame.beforeImages[page.ID()]; !saved {
+		frame.beforeImages[page.ID()] = page.Serialize()
+	}
+}
+
 // DeleteFile removes the database file. Used for testing.
 func DeleteFile(filePath string) error {
 	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -122,7 +123,7 @@ func TestPagerLRUEviction(t *testing.T) {
 	testFile := filepath.Join(t.TempDir(), "test_lru.db")
 
 	// Create pager with small cache (3 pages max)
-	pager, err := NewPager(testFile, WithMaxCacheSize(3))
+	pager, err := NewPager(testFile, WithMaxCacheSize(3), WithEvictionPolicy(EvictionLRU))
 	if err != nil {
 		t.Fatalf("NewPager failed: %v", err)
 	}
@@ -167,11 +168,44 @@ func TestPagerLRUEviction(t *testing.T) {
 	}
 }
 
+func TestPagerSetMaxCacheSize(t *testing.T) {
+	pager, err := NewPager(filepath.Join(t.TempDir(), "resize.db"))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := pager.AllocatePage(PageTypeData); err != nil {
+			t.Fatalf("AllocatePage %d failed: %v", i, err)
+		}
+	}
+	if err := pager.SetMaxCacheSize(4); err != nil {
+		t.Fatalf("SetMaxCacheSize failed: %v", err)
+	}
+	if pager.MaxCacheSize() != 4 || pager.CacheSize() != 4 {
+		t.Errorf("expected the cache shrunk to 4 pages, got %d of %d", pager.CacheSize(), pager.MaxCacheSize())
+	}
+
+	// The evicted pages were written first, and read back when needed
+	for i := uint32(0); i < 10; i++ {
+		if _, err := pager.GetPage(i); err != nil {
+			t.Fatalf("GetPage %d failed: %v", i, err)
+		}
+	}
+	if pager.CacheSize() > 4 {
+		t.Errorf("expected at most 4 cached pages, got %d", pager.CacheSize())
+	}
+	if err := pager.SetMaxCacheSize(0); err == nil {
+		t.Error("expected an error for a cache size of 0")
+	}
+}
+
 func TestPagerLRUEvictionWritesDirtyPages(t *testing.T) {
 	testFile := filepath.Join(t.TempDir(), "test_lru_dirty.db")
 
 	// Create pager with small cache (2 pages max)
-	pager, err := NewPager(testFile, WithMaxCacheSize(2))
+	pager, err := NewPager(testFile, WithMaxCacheSize(2), WithEvictionPolicy(EvictionLRU))
 	if err != nil {
 		t.Fatalf("NewPager failed: %v", err)
 	}
@@ -227,7 +261,7 @@ func TestPagerLRUAccessOrder(t *testing.T) {
 	testFile := filepath.Join(t.TempDir(), "test_lru_order.db")
 
 	// Create pager with small cache (3 pages max)
-	pager, err := NewPager(testFile, WithMaxCacheSize(3))
+	pager, err := NewPager(testFile, WithMaxCacheSize(3), WithEvictionPolicy(EvictionLRU))
 	if err != nil {
 		t.Fatalf("NewPager failed: %v", err)
 	}
@@ -264,3 +298,312 @@ func TestPagerLRUAccessOrder(t *testing.T) {
 		t.Errorf("expected cache size 3, got %d", pager.CacheSize())
 	}
 }
+
+func TestPagerIOStats(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_io.db")
+
+	pager, err := NewPager(testFile, WithMaxCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	for i := 0; i < 3; i++ {
+		page, err := pager.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage %d failed: %v", i, err)
+		}
+		page.WriteData([]byte{byte(i)})
+	}
+
+	// Allocating page 2 evicted dirty page 0, writing it to disk
+	stats := pager.IOStats()
+	if stats.DiskWrites != 1 || stats.PageRequests != 0 {
+		t.Errorf("unexpected stats after allocation: %+v", stats)
+	}
+
+	// Page 2 is cached; page 0 must be read back from disk
+	if _, err := pager.GetPage(2); err != nil {
+		t.Fatalf("GetPage(2) failed: %v", err)
+	}
+	if _, err := pager.GetPage(0); err != nil {
+		t.Fatalf("GetPage(0) failed: %v", err)
+	}
+
+	stats = pager.IOStats()
+	if stats.PageRequests != 2 || stats.DiskReads != 1 {
+		t.Errorf("expected 2 requests and 1 disk read, got %+v", stats)
+	}
+}
+
+func TestPagerStats(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_stats.db")
+
+	pager, err := NewPager(testFile, WithMaxCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	// Page 0 is clean when page 2's allocation evicts it; page 1 is dirty
+	// when page 0 is read back in
+	pager.AllocatePage(PageTypeData)
+	pager.FlushAll()
+	page, _ := pager.AllocatePage(PageTypeData)
+	page.WriteData([]byte("dirty"))
+	pager.AllocatePage(PageTypeData)
+	pager.GetPage(2)
+	pager.GetPage(0)
+
+	stats := pager.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.HitRate() != 0.5 {
+		t.Errorf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+	if stats.Evictions != 2 || stats.DirtyEvictions != 1 {
+		t.Errorf("expected 2 evictions, 1 dirty, got %+v", stats)
+	}
+	if stats.CachedPages != 2 || stats.MaxCacheSize != 2 || stats.DirtyPages != 1 {
+		t.Errorf("expected 2 cached pages, 1 dirty, got %+v", stats)
+	}
+}
+
+func TestPagerBatchesSyncs(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_sync.db")
+
+	pager, err := NewPager(testFile, WithMaxCacheSize(10))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	// Pages evicted along the way are written but not synced
+	for i := 0; i < 100; i++ {
+		page, err := pager.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage %d failed: %v", i, err)
+		}
+		page.WriteData([]byte{byte(i)})
+	}
+	if stats := pager.IOStats(); stats.DiskWrites != 90 || stats.Syncs != 0 {
+		t.Errorf("expected 90 writes and no syncs before flushing, got %+v", stats)
+	}
+
+	// One flush syncs everything once
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	if stats := pager.IOStats(); stats.DiskWrites != 100 || stats.Syncs != 1 {
+		t.Errorf("expected 100 writes and 1 sync after flushing, got %+v", stats)
+	}
+
+	// Nothing written, nothing to sync
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	if syncs := pager.IOStats().Syncs; syncs != 1 {
+		t.Errorf("expected an idle flush not to sync, got %d syncs", syncs)
+	}
+}
+
+func TestPagerTransactionRollback(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_tx_rollback.db")
+
+	pager, err := NewPager(testFile)
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	page, _ := pager.AllocatePage(PageTypeData)
+	offset, _ := page.WriteData([]byte("before"))
+
+	if err := pager.Begin(); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := pager.Begin(); err == nil {
+		t.Error("expected error for nested Begin")
+	}
+
+	page, err = pager.GetPage(0)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	page.WriteData([]byte("during"))
+	if _, err := pager.AllocatePage(PageTypeData); err != nil {
+		t.Fatalf("AllocatePage failed: %v", err)
+	}
+
+	if err := pager.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	// The page object we hold was restored in place
+	if page.NumSlots() != 1 || string(page.ReadData(offset, 6)) != "before" {
+		t.Errorf("page not restored: %d slots", page.NumSlots())
+	}
+	if pager.PageCount() != 1 {
+		t.Errorf("expected allocated page to be discarded, have %d pages", pager.PageCount())
+	}
+	if pager.InTransaction() {
+		t.Error("expected transaction to be closed")
+	}
+	if err := pager.Rollback(); err == nil {
+		t.Error("expected error for Rollback without Begin")
+	}
+}
+
+func TestPagerTransactionDefersWrites(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_tx_commit.db")
+
+	// A tiny cache: dirty pages must stay cached during the transaction
+	pager, err := NewPager(testFile, WithMaxCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	if err := pager.Begin(); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		page, err := pager.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage %d failed: %v", i, err)
+		}
+		page.WriteData([]byte{byte(i)})
+	}
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+
+	if writes := pager.IOStats().DiskWrites; writes != 0 {
+		t.Errorf("expected no writes before commit, got %d", writes)
+	}
+	if pager.CacheSize() != 4 {
+		t.Errorf("expected dirty pages to stay cached, cache has %d", pager.CacheSize())
+	}
+
+	if err := pager.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if writes := pager.IOStats().DiskWrites; writes != 4 {
+		t.Errorf("expected 4 writes at commit, got %d", writes)
+	}
+}
+
+func TestPagerSavepoints(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_tx_savepoint.db")
+
+	pager, err := NewPager(testFile)
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	page, _ := pager.AllocatePage(PageTypeData)
+	page.WriteData([]byte("a"))
+
+	pager.Begin()
+	page, _ = pager.GetPage(0)
+	page.WriteData([]byte("b"))
+
+	level, err := pager.Savepoint()
+	if err != nil || level != 1 {
+		t.Fatalf("Savepoint: got level %d, err %v", level, err)
+	}
+	page, _ = pager.GetPage(0)
+	page.WriteData([]byte("c"))
+	pager.AllocatePage(PageTypeData)
+
+	inner, _ := pager.Savepoint()
+	page, _ = pager.GetPage(0)
+	page.WriteData([]byte("d"))
+
+	// Back to the first savepoint: "b" stays, "c" and "d" go
+	if err := pager.RollbackTo(level); err != nil {
+		t.Fatalf("RollbackTo failed: %v", err)
+	}
+	if page.NumSlots() != 2 || pager.PageCount() != 1 {
+		t.Errorf("expected 2 slots and 1 page, got %d slots and %d pages", page.NumSlots(), pager.PageCount())
+	}
+	if err := pager.RollbackTo(inner); err == nil {
+		t.Error("expected the later savepoint to be discarded")
+	}
+
+	// Released changes belong to the transaction and roll back with it
+	page, _ = pager.GetPage(0)
+	page.WriteData([]byte("e"))
+	if err := pager.Release(level); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := pager.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if page.NumSlots() != 1 {
+		t.Errorf("expected the original page after rollback, got %d slots", page.NumSlots())
+	}
+}
+
+func TestPagerCopyFrom(t *testing.T) {
+	for _, wal := range []bool{false, true} {
+		t.Run(fmt.Sprintf("wal=%v", wal), func(t *testing.T) {
+			dir := t.TempDir()
+			var opts []PagerOption
+			if wal {
+				opts = append(opts, WithWAL())
+			}
+
+			dstPath := filepath.Join(dir, "dst.db")
+			dst, err := NewPager(dstPath, opts...)
+			if err != nil {
+				t.Fatalf("NewPager failed: %v", err)
+			}
+			for i := 0; i < 5; i++ {
+				page, _ := dst.AllocatePage(PageTypeData)
+				page.WriteData([]byte("old"))
+			}
+			dst.FlushAll()
+			held, _ := dst.GetPage(1)
+
+			src, err := NewPager(filepath.Join(dir, "src.db"))
+			if err != nil {
+				t.Fatalf("NewPager failed: %v", err)
+			}
+			defer src.Close()
+			for i := 0; i < 2; i++ {
+				page, _ := src.AllocatePage(PageTypeData)
+				page.WriteData([]byte{'a' + byte(i)})
+			}
+
+			if err := dst.CopyFrom(src); err != nil {
+				t.Fatalf("CopyFrom failed: %v", err)
+			}
+			if dst.PageCount() != 2 {
+				t.Errorf("expected 2 pages after copy, got %d", dst.PageCount())
+			}
+			if size := fileSize(t, dstPath); size != 2*PageSize {
+				t.Errorf("expected file shrunk to 2 pages, got %d bytes", size)
+			}
+			if got := held.ReadData(0, 1); got[0] != 'b' {
+				t.Errorf("expected a page held across the copy to see new contents, got %q", got)
+			}
+			if _, err := dst.GetPage(4); err == nil {
+				t.Error("expected pages past the new end to be gone")
+			}
+
+			// The copy survives reopening
+			dst.Close()
+			dst, err = NewPager(dstPath, opts...)
+			if err != nil {
+				t.Fatalf("reopen failed: %v", err)
+			}
+			defer dst.Close()
+			for i := 0; i < 2; i++ {
+				page, _ := dst.GetPage(uint32(i))
+				if got := page.ReadData(0, 1); got[0] != 'a'+byte(i) {
+					t.Errorf("page %d: expected %q, got %q", i, 'a'+byte(i), got)
+				}
+			}
+		})
+	}
+}
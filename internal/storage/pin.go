@@ -0,0 +1,65 @@
+// Package storage - Pinning pages in the cache
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// GetPage returns a pointer into the cache, and nothing stops the page
+// from being evicted while the caller still holds it. If it is, the next
+// GetPage reads a fresh copy from disk, and the two copies silently
+// diverge: changes made through the old pointer are lost, and reads
+// through it miss changes made since.
+//
+// Buffer pools solve this with pin counts. A caller pins a page while it
+// uses it and unpins it when done; a page with a pin count above zero is
+// never chosen for eviction. Pins nest, so two iterators can hold the
+// same leaf, and it stays in the cache until both have let go:
+//
+//   page, _ := pager.PinPage(7)   // pin count 1
+//   ...                           // page 7 can't be evicted here
+//   pager.UnpinPage(7)            // pin count 0, evictable again
+//
+// Pins should be held briefly. If every cached page is pinned, nothing
+// can be evicted and the cache grows past its limit until pages are
+// unpinned. PostgreSQL's ReadBuffer/ReleaseBuffer and SQLite's
+// sqlite3PagerGet/sqlite3PagerUnref work the same way.
+
+package storage
+
+import "fmt"
+
+// PinPage returns a page like GetPage and pins it in the cache, so it
+// won't be evicted until UnpinPage is called for it as many times as it
+// was pinned.
+func (p *Pager) PinPage(pageID uint32) (*Page, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	page, err := p.getPageLocked(pageID)
+	if err != nil {
+		return nil, err
+	}
+	p.pins[pageID]++
+	return page, nil
+}
+
+// UnpinPage releases one pin on a page taken by PinPage.
+func (p *Pager) UnpinPage(pageID uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.pins[pageID] {
+	case 0:
+		return fmt.Errorf("page %d is not pinned", pageID)
+	case 1:
+		delete(p.pins, pageID)
+	default:
+		p.pins[pageID]--
+	}
+	return nil
+}
+
+// PinCount returns the number of pins held on a page.
+func (p *Pager) PinCount(pageID uint32) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pins[pageID]
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestPagerPinPage(t *testing.T) {
+	pager, err := NewPager(filepath.Join(t.TempDir(), "pin.db"), WithMaxCacheSize(3))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := pager.AllocatePage(PageTypeData); err != nil {
+			t.Fatalf("AllocatePage failed: %v", err)
+		}
+	}
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+
+	pinned, err := pager.PinPage(0)
+	if err != nil {
+		t.Fatalf("PinPage failed: %v", err)
+	}
+	if _, err := pager.PinPage(0); err != nil {
+		t.Fatalf("PinPage failed: %v", err)
+	}
+	if n := pager.PinCount(0); n != 2 {
+		t.Errorf("expected 2 pins, got %d", n)
+	}
+
+	// Read enough other pages to evict everything that isn't pinned
+	for i := uint32(1); i < 10; i++ {
+		pager.GetPage(i)
+	}
+	if page, _ := pager.GetPage(0); page != pinned {
+		t.Error("expected the pinned page to stay in the cache")
+	}
+	if stats := pager.Stats(); stats.PinnedPages != 1 || stats.CachedPages > 3 {
+		t.Errorf("expected 1 pinned page in a cache of at most 3, got %+v", stats)
+	}
+
+	// Still pinned once
+	pager.UnpinPage(0)
+	for i := uint32(1); i < 10; i++ {
+		pager.GetPage(i)
+	}
+	if page, _ := pager.GetPage(0); page != pinned {
+		t.Error("expected the page to stay in the cache until its last pin is released")
+	}
+
+	pager.UnpinPage(0)
+	for i := uint32(1); i < 10; i++ {
+		pager.GetPage(i)
+	}
+	if page, _ := pager.GetPage(0); page == pinned {
+		t.Error("expected the unpinned page to be evicted")
+	}
+
+	if err := pager.UnpinPage(0); err == nil {
+		t.Error("expected an error unpinning a page that isn't pinned")
+	}
+}
+
+func TestPagerPinnedCacheGrows(t *testing.T) {
+	pager, err := NewPager(filepath.Join(t.TempDir(), "pin.db"), WithMaxCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	for i := uint32(0); i < 3; i++ {
+		pager.AllocatePage(PageTypeData)
+		if _, err := pager.PinPage(i); err != nil {
+			t.Fatalf("PinPage failed: %v", err)
+		}
+	}
+	if n := pager.CacheSize(); n != 3 {
+		t.Errorf("expected the cache to grow to 3 pinned pages, got %d", n)
+	}
+}
+
+func TestBTreeIteratorPinsLeaf(t *testing.T) {
+	pager, err := NewPager(filepath.Join(t.TempDir(), "pin.db"), WithMaxCacheSize(4))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	bt, err := NewBTree(pager)
+	if err != nil {
+		t.Fatalf("NewBTree failed: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := bt.Insert([]byte(fmt.Sprintf("key%04d", i)), uint64(i)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	iter := bt.NewIterator()
+	if !iter.Next() {
+		t.Fatalf("expected a key: %v", iter.Err())
+	}
+	leaf := iter.leafPageID
+	if n := pager.PinCount(leaf); n != 1 {
+		t.Errorf("expected the current leaf to be pinned once, got %d", n)
+	}
+
+	// Other reads can't evict the leaf the iterator is on
+	for i := 999; i >= 0; i -= 50 {
+		bt.Search([]byte(fmt.Sprintf("key%04d", i)))
+	}
+	if n := pager.PinCount(leaf); n != 1 {
+		t.Errorf("expected the leaf to stay pinned, got %d", n)
+	}
+
+	// Moving to the next leaf moves the pin
+	for iter.Next() && iter.leafPageID == leaf {
+	}
+	if iter.leafPageID == leaf {
+		t.Fatal("expected the iterator to reach a second leaf")
+	}
+	if pager.PinCount(leaf) != 0 || pager.PinCount(iter.leafPageID) != 1 {
+		t.Error("expected the pin to move to the new leaf")
+	}
+
+	iter.Close()
+	if n := pager.Stats().PinnedPages; n != 0 {
+		t.Errorf("expected Close to release the pin, got %d pinned pages", n)
+	}
+
+	// An iterator that runs to the end releases its pin by itself
+	iter = bt.NewIterator()
+	count := 0
+	for iter.Next() {
+		count++
+	}
+	if count != 1000 {
+		t.Errorf("expected 1000 keys, got %d", count)
+	}
+	if n := pager.Stats().PinnedPages; n != 0 {
+		t.Errorf("expected no pinned pages after the scan, got %d", n)
+	}
+}
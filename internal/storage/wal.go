@@ -0,0 +1,408 @@
+// Package storage - Write-ahead log
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Without a log, committing means writing every changed page into the
+// database file and calling fsync after each one. That is slow (an fsync
+// can take milliseconds) and unsafe: a crash between two page writes
+// leaves the file with some of the transaction's pages and not others.
+//
+// A write-ahead log (WAL) changes the order of events:
+//
+//   1. Changed pages are appended to a separate log file (<db>-wal).
+//   2. The last page of a transaction is marked as a commit, and the log
+//      is fsynced once. The transaction is now durable.
+//   3. Later, a "checkpoint" copies the newest version of each logged
+//      page into the database file and empties the log.
+//
+// Appending is sequential I/O, and one fsync per commit replaces one per
+// page. Until a checkpoint, readers must look in the log first, because it
+// holds newer versions of pages than the database file does.
+//
+// File layout:
+//
+//   Header (16 bytes): magic, format version, page size, reserved
+//   Frame  (16 + 4096 bytes, more with a page codec):
+//     page ID      uint32
+//     commit size  uint32  database size in pages if this frame ends a
+//                          transaction, otherwise 0
+//     checksum     uint32  CRC-32 of the page ID, commit size and data
+//     reserved     uint32
+//     page image   PageSize bytes, encoded like the database file's
+//                  pages if there is a codec (see codec.go)
+//
+// The checksum lets recovery find where a torn (partly written) frame
+// begins after a crash. This is the design SQLite uses in its WAL mode.
+//
+// Crash recovery:
+//
+// A clean Close checkpoints and deletes the log, so finding a non-empty
+// log at startup means the process died (crash, power loss, kill -9).
+// Recovery reads the frames in order and keeps only whole transactions:
+//
+//   frame 1  page 3             \
+//   frame 2  page 7             |- transaction A, ends with a commit frame
+//   frame 3  page 3  commit=8   /   -> kept (replayed)
+//   frame 4  page 9             \
+//   frame 5  page 2  (torn)     /- no commit frame -> discarded
+//
+// Discarding the tail is how an incomplete transaction is rolled back: its
+// pages were never copied into the database file, so the file still holds
+// the versions from before it started. The kept frames are then
+// checkpointed, which is safe to repeat if we crash again while doing it.
+//
+// A write that fails (see diskfull.go) is cut off the end of the log the
+// same way, without waiting for a crash: the log always ends with whole
+// frames, and a commit frame is only counted once it is synced.
+
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+const (
+	// walMagic identifies a WAL file ("WAL1").
+	walMagic = 0x57414C31
+
+	// walVersion is the format version written to the header.
+	walVersion = 1
+
+	// walHeaderSize is the size of the file header.
+	walHeaderSize = 16
+
+	// walFrameHeaderSize is the size of each frame's header.
+	walFrameHeaderSize = 16
+
+	// DefaultWALCheckpointFrames is how many frames the log may hold before
+	// a commit triggers a checkpoint.
+	DefaultWALCheckpointFrames = 1000
+)
+
+// WAL is an append-only log of page images.
+type WAL struct {
+	file dbFile
+	path string
+
+	// codec encodes the page images, or is nil; frameSize is the size of
+	// a whole frame with it.
+	codec     PageCodec
+	frameSize int64
+
+	// index maps a page ID to the file offset of the page image in its
+	// newest frame, committed or not.
+	index map[uint32]int64
+
+	// frames is the number of frames in the log.
+	frames int
+
+	// uncommitted counts frames written since the last commit frame.
+	uncommitted int
+
+	// lastPage is the page ID of the newest frame.
+	lastPage uint32
+
+	// recovered is the number of committed frames found at open, and
+	// dbSize the database size in pages recorded by the last of them.
+	recovered int
+	dbSize    uint32
+}
+
+// WALPath returns the log file path used for a database file.
+func WALPath(dbPath string) string {
+	return dbPath + "-wal"
+}
+
+// OpenWAL opens the log for a database file, creating it if needed. Frames
+// of committed transactions left by an unclean shutdown are kept so the
+// caller can replay them; anything after the last commit is discarded.
+// Page images are encoded with codec, which may be nil.
+func OpenWAL(dbPath string, codec PageCodec) (*WAL, error) {
+	path := WALPath(dbPath)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+
+	w := &WAL{
+		file:      file,
+		path:      path,
+		codec:     codec,
+		frameSize: walFrameHeaderSize + slotSize(codec),
+		index:     make(map[uint32]int64),
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat WAL: %w", err)
+	}
+	if stat.Size() > walHeaderSize {
+		err = w.recover(stat.Size())
+	} else {
+		err = w.reset()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// recover scans a log left by an unclean shutdown, indexing the frames of
+// committed transactions and truncating everything after the last commit.
+func (w *WAL) recover(size int64) error {
+	var header [walHeaderSize]byte
+	if _, err := w.file.ReadAt(header[:], 0); err != nil {
+		return fmt.Errorf("failed to read WAL header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(header[0:4]) != walMagic {
+		return fmt.Errorf("%s is not a WAL file", w.path)
+	}
+	if pageSize := binary.LittleEndian.Uint32(header[8:12]); pageSize != PageSize {
+		return fmt.Errorf("WAL page size %d does not match %d", pageSize, PageSize)
+	}
+
+	// Frames since the last commit, not yet known to be complete
+	pending := make(map[uint32]int64)
+	frames, committed := 0, 0
+
+	frame := make([]byte, w.frameSize)
+	for offset := int64(walHeaderSize); offset+w.frameSize <= size; offset += w.frameSize {
+		if _, err := w.file.ReadAt(frame, offset); err != nil {
+			return fmt.Errorf("failed to read WAL frame: %w", err)
+		}
+		data := frame[walFrameHeaderSize:]
+		if binary.LittleEndian.Uint32(frame[8:12]) != frameChecksum(frame[0:8], data) {
+			break // torn write: this frame and everything after it are lost
+		}
+
+		pageID := binary.LittleEndian.Uint32(frame[0:4])
+		pending[pageID] = offset + walFrameHeaderSize
+		frames++
+
+		if commitSize := binary.LittleEndian.Uint32(frame[4:8]); commitSize > 0 {
+			for id, pageOffset := range pending {
+				w.index[id] = pageOffset
+			}
+			clear(pending)
+			committed = frames
+			w.dbSize = commitSize
+			w.lastPage = pageID
+		}
+	}
+
+	end := walHeaderSize + int64(committed)*w.frameSize
+	if err := w.file.Truncate(end); err != nil {
+		return fmt.Errorf("failed to truncate WAL: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL: %w", err)
+	}
+
+	w.frames = committed
+	w.recovered = committed
+	return nil
+}
+
+// WriteFrames appends page images to the log. If commit is set, the last
+// frame is marked as ending a transaction that leaves the database with
+// dbSize pages, and the log is synced to disk.
+func (w *WAL) WriteFrames(pages []*Page, commit bool, dbSize uint32) error {
+	if commit && len(pages) == 0 {
+		if w.uncommitted == 0 {
+			return nil // nothing to commit
+		}
+		// Earlier frames were written by eviction; repeat the newest page
+		// so there's a frame to carry the commit mark
+		page, err := w.ReadPage(w.lastPage)
+		if err != nil {
+			return err
+		}
+		pages = []*Page{page}
+	}
+
+	buf := make([]byte, 0, int64(len(pages))*w.frameSize)
+	for i, page := range pages {
+		var commitSize uint32
+		if commit && i == len(pages)-1 {
+			commitSize = dbSize
+		}
+		var err error
+		if buf, err = w.appendFrame(buf, page, commitSize); err != nil {
+			return err
+		}
+	}
+
+	offset := walHeaderSize + int64(w.frames)*w.frameSize
+	err := writeFull(w.file, buf, offset)
+	if err == nil && commit {
+		if err = w.file.Sync(); err != nil {
+			err = fmt.Errorf("failed to sync: %w", diskError(err))
+		}
+	}
+	if err != nil {
+		// Cut off what was written, so the log ends with the last whole
+		// frame before these
+		if truncErr := w.file.Truncate(offset); truncErr != nil {
+			return fmt.Errorf("failed to append to WAL: %w; truncating it failed too: %v", err, truncErr)
+		}
+		return fmt.Errorf("failed to append to WAL: %w", err)
+	}
+
+	for _, page := range pages {
+		w.index[page.ID()] = offset + walFrameHeaderSize
+		offset += w.frameSize
+	}
+	w.frames += len(pages)
+	w.lastPage = pages[len(pages)-1].ID()
+
+	if !commit {
+		w.uncommitted += len(pages)
+		return nil
+	}
+	w.uncommitted = 0
+	return nil
+}
+
+// Rollback discards the frames written since the last commit, as recovery
+// would after a crash, leaving the log with committed transactions only.
+func (w *WAL) Rollback() error {
+	if w.uncommitted == 0 {
+		return nil
+	}
+	recovered, dbSize := w.recovered, w.dbSize
+	w.index = make(map[uint32]int64)
+	err := w.recover(walHeaderSize + int64(w.frames)*w.frameSize)
+	w.recovered, w.dbSize = recovered, dbSize
+	w.uncommitted = 0
+	return err
+}
+
+// appendFrame encodes one frame onto buf.
+func (w *WAL) appendFrame(buf []byte, page *Page, commitSize uint32) ([]byte, error) {
+	var header [walFrameHeaderSize]byte
+	data, err := encodePage(w.codec, page)
+	if err != nil {
+		return nil, err
+	}
+	// The frame has a fixed size even if the codec shrank the page
+	data = append(data, make([]byte, slotSize(w.codec)-int64(len(data)))...)
+	binary.LittleEndian.PutUint32(header[0:4], page.ID())
+	binary.LittleEndian.PutUint32(header[4:8], commitSize)
+	binary.LittleEndian.PutUint32(header[8:12], frameChecksum(header[0:8], data))
+
+	buf = append(buf, header[:]...)
+	return append(buf, data...), nil
+}
+
+// frameChecksum covers a frame's page ID, commit size and page image.
+func frameChecksum(header, data []byte) uint32 {
+	sum := crc32.ChecksumIEEE(header)
+	return crc32.Update(sum, crc32.IEEETable, data)
+}
+
+// Contains reports whether the log holds a version of the page.
+func (w *WAL) Contains(pageID uint32) bool {
+	_, ok := w.index[pageID]
+	return ok
+}
+
+// ReadPage reads the newest logged version of a page.
+func (w *WAL) ReadPage(pageID uint32) (*Page, error) {
+	offset, ok := w.index[pageID]
+	if !ok {
+		return nil, fmt.Errorf("page %d is not in the WAL", pageID)
+	}
+
+	buf := make([]byte, slotSize(w.codec))
+	if _, err := w.file.ReadAt(buf, offset); err != nil {
+		return nil, fmt.Errorf("failed to read page %d from WAL: %w", pageID, err)
+	}
+	return decodePage(w.codec, pageID, buf)
+}
+
+// Frames returns the number of frames in the log.
+func (w *WAL) Frames() int {
+	return w.frames
+}
+
+// Recovered returns the number of committed frames found when the log was
+// opened, and the database size in pages they leave behind. Both are zero
+// after a clean shutdown.
+func (w *WAL) Recovered() (frames int, dbSize uint32) {
+	return w.recovered, w.dbSize
+}
+
+// Checkpoint copies the newest version of every logged page into the
+// database file, syncs it, and empties the log.
+//
+// Frames not yet followed by a commit are copied too: they belong to the
+// one writer, which has already seen them.
+func (w *WAL) Checkpoint(db dbFile) error {
+	if w.frames == 0 {
+		return nil
+	}
+
+	for pageID := range w.index {
+		page, err := w.ReadPage(pageID)
+		if err != nil {
+			return err
+		}
+		data, err := encodePage(w.codec, page)
+		if err != nil {
+			return err
+		}
+		if err := writeFull(db, data, int64(pageID)*slotSize(w.codec)); err != nil {
+			return fmt.Errorf("checkpoint failed to write page %d: %w", pageID, err)
+		}
+	}
+
+	// The database file must be durable before the log is emptied
+	if err := db.Sync(); err != nil {
+		return fmt.Errorf("checkpoint failed to sync database: %w", diskError(err))
+	}
+	return w.reset()
+}
+
+// reset empties the log, leaving only the header.
+func (w *WAL) reset() error {
+	// Cutting the frames off is what empties the log. The header stays,
+	// so rewriting it needs no new space on a full disk
+	if err := w.file.Truncate(walHeaderSize); err != nil {
+		return fmt.Errorf("failed to truncate WAL: %w", err)
+	}
+	w.index = make(map[uint32]int64)
+	w.frames = 0
+	w.uncommitted = 0
+
+	var header [walHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:4], walMagic)
+	binary.LittleEndian.PutUint32(header[4:8], walVersion)
+	binary.LittleEndian.PutUint32(header[8:12], PageSize)
+	if err := writeFull(w.file, header[:], 0); err != nil {
+		return fmt.Errorf("failed to write WAL header: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL: %w", diskError(err))
+	}
+	return nil
+}
+
+// Close closes the log file. If remove is set, the file is deleted; only
+// do that after a checkpoint.
+func (w *WAL) Close(remove bool) error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if remove {
+		if err := os.Remove(w.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
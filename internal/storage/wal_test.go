@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat %s failed: %v", path, err)
+	}
+	return stat.Size()
+}
+
+func TestWALCommitAndCheckpoint(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_wal.db")
+
+	pager, err := NewPager(testFile, WithWAL())
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		page, err := pager.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage %d failed: %v", i, err)
+		}
+		page.WriteData([]byte{byte('a' + i)})
+	}
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+
+	// Committed pages are in the log, not yet in the database file
+	if pager.WALFrames() != 3 {
+		t.Errorf("expected 3 frames in the WAL, got %d", pager.WALFrames())
+	}
+	if size := fileSize(t, testFile); size != 0 {
+		t.Errorf("expected empty database file before checkpoint, got %d bytes", size)
+	}
+
+	if err := pager.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if pager.WALFrames() != 0 {
+		t.Errorf("expected empty WAL after checkpoint, got %d frames", pager.WALFrames())
+	}
+	if size := fileSize(t, testFile); size != 3*PageSize {
+		t.Errorf("expected 3 pages in database file, got %d bytes", size)
+	}
+
+	if err := pager.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(WALPath(testFile)); !os.IsNotExist(err) {
+		t.Errorf("expected WAL file to be removed on clean close")
+	}
+}
+
+func TestWALReadsEvictedPages(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_wal_evict.db")
+
+	pager, err := NewPager(testFile, WithWAL(), WithMaxCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	var offsets []uint16
+	for i := 0; i < 4; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		offset, _ := page.WriteData([]byte{byte('a' + i)})
+		offsets = append(offsets, offset)
+	}
+
+	// Pages 0 and 1 were evicted into the log; they must be read back from it
+	for i := 0; i < 4; i++ {
+		page, err := pager.GetPage(uint32(i))
+		if err != nil {
+			t.Fatalf("GetPage %d failed: %v", i, err)
+		}
+		if got := page.ReadData(offsets[i], 1); got[0] != byte('a'+i) {
+			t.Errorf("page %d: expected %q, got %q", i, 'a'+i, got)
+		}
+	}
+}
+
+func TestWALAutoCheckpoint(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_wal_auto.db")
+
+	pager, err := NewPager(testFile, WithWAL(), WithCheckpointFrames(4))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	for i := 0; i < 3; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		page.WriteData([]byte{byte(i)})
+		if err := pager.FlushAll(); err != nil {
+			t.Fatalf("FlushAll failed: %v", err)
+		}
+	}
+	if frames := pager.WALFrames(); frames != 3 {
+		t.Fatalf("expected 3 frames before the threshold, got %d", frames)
+	}
+
+	page, _ := pager.GetPage(0)
+	page.WriteData([]byte{9})
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+	if frames := pager.WALFrames(); frames != 0 {
+		t.Errorf("expected the commit reaching 4 frames to checkpoint, have %d", frames)
+	}
+}
+
+func TestWALTransactionCommitsOnce(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_wal_tx.db")
+
+	pager, err := NewPager(testFile, WithWAL())
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+
+	pager.Begin()
+	for i := 0; i < 3; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		page.WriteData([]byte{byte(i)})
+	}
+	pager.FlushAll()
+	if frames := pager.WALFrames(); frames != 0 {
+		t.Errorf("expected nothing logged before commit, got %d frames", frames)
+	}
+
+	if err := pager.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if frames := pager.WALFrames(); frames != 3 {
+		t.Errorf("expected 3 frames after commit, got %d", frames)
+	}
+}
+
+// crash abandons a pager without flushing or checkpointing, as if the
+// process had been killed.
+func crash(p *Pager) {
+	p.wal.file.Close()
+	p.file.Close()
+}
+
+func TestWALRecovery(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_wal_recover.db")
+
+	pager, err := NewPager(testFile, WithWAL())
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	var offsets []uint16
+	for i := 0; i < 3; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		offset, _ := page.WriteData([]byte{byte('a' + i)})
+		offsets = append(offsets, offset)
+	}
+	if err := pager.FlushAll(); err != nil {
+		t.Fatalf("FlushAll failed: %v", err)
+	}
+
+	// An incomplete transaction: a new page and a change to page 0 reach
+	// the log, but no commit frame does
+	page, _ := pager.AllocatePage(PageTypeData)
+	page.WriteData([]byte{'x'})
+	pager.FlushPage(page.ID())
+	page, _ = pager.GetPage(0)
+	page.WriteData([]byte{'z'})
+	pager.FlushPage(0)
+	crash(pager)
+
+	// The last frame was torn part way through
+	walFile, err := os.OpenFile(WALPath(testFile), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open WAL failed: %v", err)
+	}
+	walFile.Write(make([]byte, (walFrameHeaderSize+PageSize)/2))
+	walFile.Close()
+
+	pager, err = NewPager(testFile, WithWAL())
+	if err != nil {
+		t.Fatalf("reopening after crash failed: %v", err)
+	}
+	defer pager.Close()
+
+	if got := pager.RecoveredFrames(); got != 3 {
+		t.Errorf("expected 3 committed frames replayed, got %d", got)
+	}
+	if got := pager.PageCount(); got != 3 {
+		t.Errorf("expected uncommitted page to be discarded, page count %d", got)
+	}
+	if frames := pager.WALFrames(); frames != 0 {
+		t.Errorf("expected WAL to be checkpointed after recovery, has %d frames", frames)
+	}
+	if size := fileSize(t, testFile); size != 3*PageSize {
+		t.Errorf("expected recovered pages in database file, got %d bytes", size)
+	}
+	for i := 0; i < 3; i++ {
+		page, err := pager.GetPage(uint32(i))
+		if err != nil {
+			t.Fatalf("GetPage %d failed: %v", i, err)
+		}
+		if got := page.ReadData(offsets[i], 1); got[0] != byte('a'+i) {
+			t.Errorf("page %d: expected committed %q, got %q", i, 'a'+i, got)
+		}
+		if page.NumSlots() != 1 {
+			t.Errorf("page %d: uncommitted record survived recovery", i)
+		}
+	}
+}
+
+func TestWALRecoveryIgnoresCorruptFrame(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_wal_corrupt.db")
+
+	pager, _ := NewPager(testFile, WithWAL())
+	for i := 0; i < 2; i++ {
+		page, _ := pager.AllocatePage(PageTypeData)
+		page.WriteData([]byte{byte(i)})
+		pager.FlushAll()
+	}
+	crash(pager)
+
+	// Flip a byte in the second transaction's page image
+	walFile, _ := os.OpenFile(WALPath(testFile), os.O_RDWR, 0644)
+	walFile.WriteAt([]byte{0xFF}, walHeaderSize+(walFrameHeaderSize+PageSize)+walFrameHeaderSize+100)
+	walFile.Close()
+
+	pager, err := NewPager(testFile, WithWAL())
+	if err != nil {
+		t.Fatalf("reopening after crash failed: %v", err)
+	}
+	defer pager.Close()
+
+	if got := pager.RecoveredFrames(); got != 1 {
+		t.Errorf("expected only the first transaction to be replayed, got %d frames", got)
+	}
+	if got := pager.PageCount(); got != 1 {
+		t.Errorf("expected 1 page after recovery, got %d", got)
+	}
+}
@@ -0,0 +1,228 @@
+// Package table - Bulk loading
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Insert does three things for each row: it finds a data page with room
+// for it, adds its key to the primary key B-tree, and adds an entry to
+// every secondary index. Loading a million rows that way costs a million
+// descents of each tree, splits that leave leaves half full, and a search
+// of the data pages for free space that grows with the table.
+//
+// A BulkLoader loads an empty table in two phases instead:
+//
+//   1. Add packs each row into the last data page, starting a new page
+//      when it's full, and only notes the row's keys and location.
+//   2. Finish sorts the keys and builds each B-tree from the bottom up
+//      (see storage.BuildBTree), every page written once and packed full.
+//
+// The rows aren't part of the table until Finish: the new pages are
+// added to it, and the new trees replace its empty ones, in one step. A
+// load that fails part way, or a crash during it, leaves the table as it
+// was, and Abort gives the pages back. Duplicate keys are only found once
+// they are sorted, so a load with one fails at Finish, as a whole.
+//
+// The keys are kept in memory until Finish, which is the price of
+// sorting them: a few dozen bytes per row, rather than the rows. This is
+// how PostgreSQL's CREATE INDEX sorts its entries, and why COPY into an
+// empty table is faster with the indexes created afterwards.
+
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// BulkLoader loads rows into an empty table, building its B-trees at the
+// end. Create one with NewBulkLoader.
+type BulkLoader struct {
+	t        *Table
+	rootPage uint32 // The table's empty primary key B-tree
+
+	page    *storage.Page // The data page rows are being packed into
+	pageIDs []uint32      // The data pages written, in order
+
+	keys         [][]byte            // Primary key of each row, in load order
+	locations    []uint64            // Where each row is stored
+	indexColumns map[string][]int    // The columns of each index
+	indexKeys    map[string][][]byte // Each index's key of each row
+	rowCount     int64
+	bytes        int64
+}
+
+// NewBulkLoader starts a bulk load of the table. It returns nil if the
+// table can't be bulk loaded: if it has rows, or is columnar.
+func (t *Table) NewBulkLoader() (*BulkLoader, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.columnar {
+		return nil, nil
+	}
+	if empty, err := t.isEmpty(); err != nil || !empty {
+		return nil, err
+	}
+	l := &BulkLoader{
+		t:            t,
+		rootPage:     t.btree.RootPage(),
+		indexColumns: make(map[string][]int, len(t.indexes)),
+		indexKeys:    make(map[string][][]byte, len(t.indexes)),
+	}
+	for name, idx := range t.indexes {
+		l.indexColumns[name] = t.indexColumns(idx)
+	}
+	return l, nil
+}
+
+// isEmpty reports whether the table has no rows. Caller must hold the
+// lock.
+func (t *Table) isEmpty() (bool, error) {
+	it := t.btree.NewIterator()
+	defer it.Close()
+	if it.Next() {
+		return false, nil
+	}
+	return true, it.Err()
+}
+
+// Add stores a row in the load and returns its row ID. The row isn't
+// visible in the table until Finish.
+func (l *BulkLoader) Add(values []Value) (uint64, error) {
+	t := l.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.validateValues(values); err != nil {
+		return 0, err
+	}
+	rowID := t.nextRowID
+	key, err := t.primaryIndexKey(values, rowID)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.checkKeySizes(key, values); err != nil {
+		return 0, err
+	}
+
+	rowData, err := t.serializeRow(rowID, values)
+	if err != nil {
+		return 0, fmt.Errorf("failed to serialize row: %w", err)
+	}
+	record, flags, err := t.encodeRecord(rowData)
+	if err != nil {
+		return 0, err
+	}
+	if l.page == nil || int(l.page.FreeSpace()) < len(record)+2 {
+		if l.page, err = t.pager.AllocatePage(storage.PageTypeData); err != nil {
+			return 0, err
+		}
+		l.pageIDs = append(l.pageIDs, l.page.ID())
+	}
+	offset, err := t.writeRecordToPage(l.page, record, flags)
+	if err != nil {
+		return 0, err
+	}
+	t.nextRowID++
+
+	row := Row{ID: rowID, Values: values}
+	l.keys = append(l.keys, key)
+	l.locations = append(l.locations, uint64(l.page.ID())<<32|uint64(offset))
+	for name, columns := range l.indexColumns {
+		l.indexKeys[name] = append(l.indexKeys[name], t.buildIndexKey(row, columns))
+	}
+	l.rowCount++
+	l.bytes += int64(len(rowData))
+	return rowID, nil
+}
+
+// Rows returns how many rows have been added.
+func (l *BulkLoader) Rows() int64 {
+	return l.rowCount
+}
+
+// Finish builds the table's B-trees from the rows added and makes the
+// rows part of the table. If it fails, the table is left as it was; call
+// Abort to give the pages back.
+func (l *BulkLoader) Finish() error {
+	t := l.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.btree.RootPage() != l.rootPage || len(t.indexes) != len(l.indexColumns) {
+		return fmt.Errorf("table %s was changed during the load", t.Name)
+	}
+	if empty, err := t.isEmpty(); err != nil {
+		return err
+	} else if !empty {
+		return fmt.Errorf("table %s was changed during the load", t.Name)
+	}
+
+	order := make([]int, len(l.keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return bytes.Compare(l.keys[order[a]], l.keys[order[b]]) < 0
+	})
+	sortedKeys := make([][]byte, len(order))
+	locations := make([]uint64, len(order))
+	for i, j := range order {
+		sortedKeys[i] = l.keys[j]
+		locations[i] = l.locations[j]
+		if i > 0 && bytes.Equal(sortedKeys[i-1], sortedKeys[i]) {
+			return fmt.Errorf("%w of table %s: rows %d and %d of the load have the same key",
+				ErrDuplicateKey, t.Name, order[i-1]+1, j+1)
+		}
+	}
+	btree, err := storage.BuildBTree(t.pager, sortedKeys, locations)
+	if err != nil {
+		return fmt.Errorf("failed to build primary key index: %w", err)
+	}
+
+	indexes := make(map[string]*storage.Index, len(l.indexColumns))
+	for name := range l.indexColumns {
+		idx, ok := t.indexes[name]
+		if !ok {
+			return fmt.Errorf("table %s was changed during the load", t.Name)
+		}
+		built, err := storage.BuildIndex(idx.Name, t.Name, idx.Columns, idx.Unique, t.pager, l.indexKeys[name], l.locations)
+		if err != nil {
+			return fmt.Errorf("failed to build index %s: %w", name, err)
+		}
+		indexes[name] = built
+	}
+
+	// The empty trees replaced are left unused until VACUUM
+	t.btree = btree
+	for name, idx := range indexes {
+		t.indexes[name] = idx
+	}
+	t.dataPageIDs = append(t.dataPageIDs, l.pageIDs...)
+	t.stats.RowCount += l.rowCount
+	t.stats.BytesStored += l.bytes
+	t.stats.Modified += l.rowCount
+	l.pageIDs = nil
+	return nil
+}
+
+// Abort gives back the pages of a load that won't be finished.
+func (l *BulkLoader) Abort() error {
+	t := l.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pages := slices.Clone(l.pageIDs)
+	for _, pageID := range l.pageIDs {
+		chains, err := t.overflowPages(pageID)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, chains...)
+	}
+	l.pageIDs = nil
+	return t.freePages(pages)
+}
@@ -0,0 +1,106 @@
+package table
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+func userValues(id int64, name string, age int64) []Value {
+	return []Value{
+		{Type: parser.TypeInteger, Integer: id},
+		{Type: parser.TypeText, Text: name},
+		{Type: parser.TypeInteger, Integer: age},
+	}
+}
+
+func TestBulkLoader(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+	if err := tbl.CreateIndex("idx_age", []string{"age"}, false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	loader, err := tbl.NewBulkLoader()
+	if err != nil || loader == nil {
+		t.Fatalf("expected a loader for an empty table, got %v, %v", loader, err)
+	}
+	// Out of key order, with a large row spilling to overflow pages
+	n := int64(2000)
+	for i := n; i >= 1; i-- {
+		name := "user"
+		if i == 500 {
+			name = strings.Repeat("x", 3000)
+		}
+		if _, err := loader.Add(userValues(i, name, i%10)); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// Nothing is visible before Finish
+	if rows, _ := tbl.Scan(); len(rows) != 0 {
+		t.Errorf("expected no rows before Finish, got %d", len(rows))
+	}
+	if err := loader.Finish(); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	if rows, _ := tbl.Scan(); int64(len(rows)) != n {
+		t.Errorf("expected %d rows, got %d", n, len(rows))
+	}
+	if row, found, err := tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 500}); err != nil || !found || len(row.Values[1].Text) != 3000 {
+		t.Errorf("expected to find row 500, got %v %v", found, err)
+	}
+	if rows, err := tbl.LookupIndex("idx_age", Value{Type: parser.TypeInteger, Integer: 3}, func(Row) bool { return true }, 0); err != nil || len(rows) != 200 {
+		t.Errorf("expected 200 rows with age 3, got %d (err=%v)", len(rows), err)
+	}
+	if got := tbl.Stats().RowCount; got != n {
+		t.Errorf("expected a row count of %d, got %d", n, got)
+	}
+	r := storage.NewIntegrityReport()
+	tbl.CheckIntegrity(r)
+	if len(r.Problems) != 0 {
+		t.Errorf("expected a healthy table, got %v", r.Problems)
+	}
+
+	// Inserts carry on after the loaded rows; a table with rows can't be
+	// bulk loaded
+	if _, err := tbl.Insert(userValues(n+1, "late", 0)); err != nil {
+		t.Errorf("Insert after a load failed: %v", err)
+	}
+	if again, err := tbl.NewBulkLoader(); again != nil || err != nil {
+		t.Errorf("expected no loader for a table with rows, got %v, %v", again, err)
+	}
+}
+
+func TestBulkLoaderDuplicateKey(t *testing.T) {
+	tbl, pager, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	loader, _ := tbl.NewBulkLoader()
+	for _, id := range []int64{3, 1, 2, 1} {
+		loader.Add(userValues(id, "user", 0))
+	}
+	err := loader.Finish()
+	if !errors.Is(err, ErrDuplicateKey) || !strings.Contains(err.Error(), "rows 2 and 4") {
+		t.Fatalf("expected a duplicate key error for rows 2 and 4, got %v", err)
+	}
+
+	// The table is left empty, and its pages are given back
+	_, before := pager.FreeList()
+	if err := loader.Abort(); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if _, after := pager.FreeList(); after <= before {
+		t.Error("expected Abort to free the load's pages")
+	}
+	if rows, _ := tbl.Scan(); len(rows) != 0 {
+		t.Errorf("expected no rows after a failed load, got %d", len(rows))
+	}
+	if _, err := tbl.Insert(userValues(1, "user", 0)); err != nil {
+		t.Errorf("Insert after a failed load failed: %v", err)
+	}
+}
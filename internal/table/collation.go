@@ -0,0 +1,78 @@
+// Package table - Collations
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A collation is a rule for comparing text. The default, BINARY, compares
+// the bytes, so 'Alice' and 'alice' are different values and every
+// capital letter sorts before every small one ('Zoe' < 'adam'). A column
+// declared with NOCASE compares without regard to case:
+//
+//   CREATE TABLE users (email TEXT COLLATE NOCASE UNIQUE, ...);
+//   SELECT * FROM users WHERE email = 'Bob@Example.com';  -- finds bob@example.com
+//
+// Rather than teach every comparison about case, NOCASE maps each value
+// to a "collation key", its lower-case form, and compares the keys the
+// usual way. The same key goes into the column's B-tree keys (its index
+// entries, and its primary key), so an index lookup for 'BOB' lands on
+// 'bob', and a UNIQUE or PRIMARY KEY column rejects 'Bob' once it holds
+// 'bob'. The stored value keeps the case it was written with: only its
+// comparisons ignore it.
+//
+// As in SQLite, a comparison uses the collation of the column it
+// involves: WHERE email = 'X' compares with email's collation, whichever
+// side the column is on, and ORDER BY email sorts with it. SQLite's NOCASE
+// only folds the 26 ASCII letters; this one folds any letter Go knows the
+// lower case of. Real collations go much further: PostgreSQL and MySQL
+// use ICU or the operating system's locales, where 'ä' sorts next to 'a'
+// in German and after 'z' in Swedish.
+
+package table
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Collation is how a TEXT column's values compare.
+type Collation string
+
+const (
+	// CollateBinary compares text byte by byte, the default.
+	CollateBinary Collation = ""
+	// CollateNoCase compares text without regard to case.
+	CollateNoCase Collation = "NOCASE"
+)
+
+// ParseCollation returns the collation with the given name, as a COLLATE
+// clause names it.
+func ParseCollation(name string) (Collation, error) {
+	switch strings.ToUpper(name) {
+	case "BINARY":
+		return CollateBinary, nil
+	case "NOCASE":
+		return CollateNoCase, nil
+	default:
+		return CollateBinary, fmt.Errorf("unknown collation: %s", name)
+	}
+}
+
+func (c Collation) String() string {
+	if c == CollateBinary {
+		return "BINARY"
+	}
+	return string(c)
+}
+
+// Key returns the value that stands for v in comparisons under the
+// collation: v itself, or for NOCASE text, v in lower case.
+func (c Collation) Key(v Value) Value {
+	if c == CollateNoCase && !v.IsNull && isTextual(v.Type) {
+		v.Text = strings.ToLower(v.Text)
+	}
+	return v
+}
+
+// Compare compares two values under the collation, like Value.Compare.
+func (c Collation) Compare(a, b Value) int {
+	return c.Key(a).Compare(c.Key(b))
+}
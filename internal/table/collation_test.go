@@ -0,0 +1,33 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestCollation(t *testing.T) {
+	text := func(s string) Value { return Value{Type: parser.TypeText, Text: s} }
+
+	if CollateBinary.Compare(text("Zoe"), text("adam")) >= 0 {
+		t.Error("expected BINARY to sort capitals first")
+	}
+	if CollateNoCase.Compare(text("Zoe"), text("adam")) <= 0 {
+		t.Error("expected NOCASE to sort Zoe after adam")
+	}
+	if CollateNoCase.Compare(text("ÄBC"), text("äbc")) != 0 {
+		t.Error("expected NOCASE to ignore the case of any letter")
+	}
+	if got := CollateNoCase.Key(Value{Type: parser.TypeInteger, Integer: 7}); got.Integer != 7 {
+		t.Errorf("expected a number to be its own key, got %v", got)
+	}
+
+	for name, want := range map[string]Collation{"binary": CollateBinary, "NoCase": CollateNoCase} {
+		if got, err := ParseCollation(name); err != nil || got != want {
+			t.Errorf("ParseCollation(%q) = %v, %v", name, got, err)
+		}
+	}
+	if _, err := ParseCollation("french"); err == nil {
+		t.Error("expected an unknown collation to be rejected")
+	}
+}
@@ -0,0 +1,666 @@
+// Package table - Columnar storage
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A table normally keeps each row's values together in a data page, which
+// suits reading and writing whole rows. An analytics query reads the
+// opposite way: "SELECT region, SUM(amount) FROM sales GROUP BY region"
+// needs two columns of every row of a table that may have fifty. A row
+// store still reads all fifty, since they share the pages. A column store
+// keeps each column apart, so the query reads two columns' worth of pages
+// and never touches the rest:
+//
+//   row storage:     [id|region|amount|...] [id|region|amount|...] ...
+//   column storage:  [id id id ...] [region region ...] [amount amount ...]
+//
+// Values of one column also look alike, which makes them compress well.
+// Each column of a group of rows is stored as a "chunk", encoded in
+// whichever of these is smallest:
+//
+//   plain       each value in the row format
+//   RLE         runs of equal values, as (count, value):  a a a b -> 3a 1b
+//   dictionary  the distinct values once, then an index per row
+//   delta       integers as differences from the one before: 100 101 103
+//               -> 100 +1 +2, small numbers taking a byte or two as varints
+//
+// Rows arrive one at a time, though, and re-encoding a column for each
+// insert would cost more than it saves. Like Citus's columnar access
+// method and the delta stores of SQL Server's columnstore indexes, a
+// columnar table therefore writes new rows to ordinary data pages first.
+// When rowGroupSize of them have gathered, they are "packed" into a row
+// group: one chunk per column, plus one of their row IDs, each in an
+// overflow chain of its own, with the range of the chunk's values noted
+// like a zone map (see zonemap.go) so scans can skip whole groups.
+//
+// Packed rows are never rewritten, so columnar tables don't support
+// UPDATE, DELETE, a primary key or indexes, which would all need to find a
+// row's place in the chunks. That is the usual trade: columnar storage is
+// for large tables that are appended to and scanned.
+
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// defaultRowGroupSize is how many rows a columnar table gathers in its
+// data pages before packing them into a row group.
+const defaultRowGroupSize = 1024
+
+// The encodings of a chunk, given by its first byte.
+const (
+	encodingPlain byte = iota
+	encodingRLE
+	encodingDictionary
+	encodingDelta
+)
+
+// rowGroup is a group of rows of a columnar table, stored column by
+// column.
+type rowGroup struct {
+	rows   int
+	rowIDs columnChunk   // The row IDs, as an INTEGER column
+	chunks []columnChunk // One per column of the schema
+}
+
+// columnChunk is the values of one column of a row group: an encoded
+// chunk in an overflow chain, and the range of its values.
+type columnChunk struct {
+	page   uint32
+	length int
+	zone   columnZone
+}
+
+// NewColumnarTable creates a new table with the given schema that stores
+// its rows column by column (see the notes above).
+func NewColumnarTable(name string, schema *Schema, pager *storage.Pager) (*Table, error) {
+	if len(schema.PrimaryKeyColumns) > 0 {
+		return nil, fmt.Errorf("a columnar table cannot have a primary key")
+	}
+	t, err := NewTable(name, schema, pager)
+	if err != nil {
+		return nil, err
+	}
+	t.columnar = true
+	return t, nil
+}
+
+// IsColumnar reports whether the table stores its rows column by column.
+func (t *Table) IsColumnar() bool {
+	return t.columnar
+}
+
+// RowGroups returns how many row groups a columnar table has packed, and
+// how many of its rows are still in data pages waiting to be.
+func (t *Table) RowGroups() (groups int, buffered int64) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.groups), t.stats.RowCount - t.packedRowCount()
+}
+
+// rowGroupSize returns how many rows make a row group.
+func (t *Table) rowGroupSize() int {
+	if t.groupSize > 0 {
+		return t.groupSize
+	}
+	return defaultRowGroupSize
+}
+
+// packedRowCount returns how many rows are in row groups. Caller must hold
+// the lock.
+func (t *Table) packedRowCount() int64 {
+	var count int64
+	for _, g := range t.groups {
+		count += int64(g.rows)
+	}
+	return count
+}
+
+// packedBytes returns the size of the encoded chunks of the row groups.
+// Caller must hold the lock.
+func (t *Table) packedBytes() int64 {
+	var size int64
+	for _, g := range t.groups {
+		size += g.size()
+	}
+	return size
+}
+
+// size returns the size of the group's encoded chunks.
+func (g rowGroup) size() int64 {
+	size := int64(g.rowIDs.length)
+	for _, chunk := range g.chunks {
+		size += int64(chunk.length)
+	}
+	return size
+}
+
+// excludes reports whether the zones of the group's chunks show it has no
+// row in every one of ranges.
+func (g rowGroup) excludes(ranges []ScanRange) bool {
+	for _, r := range ranges {
+		if r.Column < len(g.chunks) && r.excludes(g.chunks[r.Column].zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// packIfFull packs the rows in the data pages into a row group once there
+// are enough of them. Caller must hold the write lock.
+func (t *Table) packIfFull() error {
+	if !t.columnar || t.stats.RowCount-t.packedRowCount() < int64(t.rowGroupSize()) {
+		return nil
+	}
+	return t.packRowGroup()
+}
+
+// packRowGroup moves the rows in the data pages into a new row group, and
+// frees the pages and the B-tree that held them. Caller must hold the
+// write lock.
+func (t *Table) packRowGroup() error {
+	var rows []Row
+	var rowBytes int64
+	for _, pageID := range t.dataPageIDs {
+		page, err := t.pager.GetPage(pageID)
+		if err != nil {
+			return err
+		}
+		pageRows, err := t.readRowsFromPage(page)
+		if err != nil {
+			return err
+		}
+		for _, row := range pageRows {
+			rowBytes += int64(row.size)
+		}
+		rows = append(rows, pageRows...)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	group, err := t.writeRowGroup(t.pager, rows)
+	if err != nil {
+		return fmt.Errorf("failed to pack row group: %w", err)
+	}
+
+	// The packed rows are found through the group from now on, so the
+	// pages that held them, and their B-tree, start over empty
+	pages, err := t.btree.Pages()
+	if err != nil {
+		return fmt.Errorf("failed to find pages of the primary key: %w", err)
+	}
+	for _, pageID := range t.dataPageIDs {
+		chains, err := t.overflowPages(pageID)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, pageID)
+		pages = append(pages, chains...)
+	}
+	if err := t.freePages(pages); err != nil {
+		return err
+	}
+	btree, err := storage.NewBTree(t.pager)
+	if err != nil {
+		return fmt.Errorf("failed to create B-tree: %w", err)
+	}
+
+	t.btree = btree
+	t.dataPageIDs = nil
+	t.groups = append(t.groups, group)
+	t.clearZones()
+	t.stats.BytesStored += group.size() - rowBytes
+	return nil
+}
+
+// writeRowGroup encodes rows column by column into new chunks in pager.
+func (t *Table) writeRowGroup(pager *storage.Pager, rows []Row) (rowGroup, error) {
+	group := rowGroup{rows: len(rows), chunks: make([]columnChunk, len(t.Schema.Columns))}
+	values := make([]Value, len(rows))
+
+	for i, row := range rows {
+		values[i] = Value{Type: parser.TypeInteger, Integer: int64(row.ID)}
+	}
+	var err error
+	if group.rowIDs, err = t.writeChunk(pager, values); err != nil {
+		return group, err
+	}
+
+	for col := range t.Schema.Columns {
+		for i, row := range rows {
+			values[i] = row.Values[col]
+		}
+		if group.chunks[col], err = t.writeChunk(pager, values); err != nil {
+			return group, fmt.Errorf("column %s: %w", t.Schema.Columns[col].Name, err)
+		}
+	}
+	return group, nil
+}
+
+// writeChunk encodes values into a new chunk in pager.
+func (t *Table) writeChunk(pager *storage.Pager, values []Value) (columnChunk, error) {
+	data, err := t.encodeChunk(values)
+	if err != nil {
+		return columnChunk{}, err
+	}
+	page, err := pager.WriteOverflow(data)
+	if err != nil {
+		return columnChunk{}, err
+	}
+
+	chunk := columnChunk{page: page, length: len(data), zone: columnZone{known: true}}
+	for _, val := range values {
+		chunk.zone.add(val)
+	}
+	return chunk, nil
+}
+
+// encodeChunk encodes the values of a column in the smallest of the
+// encodings that suit them.
+func (t *Table) encodeChunk(values []Value) ([]byte, error) {
+	// Each value in the row format, which is also how values are compared
+	// for runs and the dictionary
+	encoded := make([][]byte, len(values))
+	for i, val := range values {
+		var buf bytes.Buffer
+		if err := t.serializeValue(&buf, val); err != nil {
+			return nil, err
+		}
+		encoded[i] = buf.Bytes()
+	}
+
+	plain := []byte{encodingPlain}
+	for _, e := range encoded {
+		plain = append(plain, e...)
+	}
+	best := plain
+
+	rle := []byte{encodingRLE}
+	for i := 0; i < len(encoded); {
+		run := 1
+		for i+run < len(encoded) && bytes.Equal(encoded[i+run], encoded[i]) {
+			run++
+		}
+		rle = binary.AppendUvarint(rle, uint64(run))
+		rle = append(rle, encoded[i]...)
+		i += run
+	}
+	if len(rle) < len(best) {
+		best = rle
+	}
+
+	ids := make(map[string]int)
+	var dictionary [][]byte
+	indexes := make([]int, len(encoded))
+	for i, e := range encoded {
+		id, ok := ids[string(e)]
+		if !ok {
+			id = len(dictionary)
+			ids[string(e)] = id
+			dictionary = append(dictionary, e)
+		}
+		indexes[i] = id
+	}
+	dict := binary.AppendUvarint([]byte{encodingDictionary}, uint64(len(dictionary)))
+	for _, e := range dictionary {
+		dict = append(dict, e...)
+	}
+	for _, id := range indexes {
+		dict = binary.AppendUvarint(dict, uint64(id))
+	}
+	if len(dict) < len(best) {
+		best = dict
+	}
+
+	if delta, ok := encodeDelta(values); ok && len(delta) < len(best) {
+		best = delta
+	}
+	return best, nil
+}
+
+// encodeDelta encodes integers as their differences from the one before,
+// if values are all integers (or all timestamps) and none is NULL.
+func encodeDelta(values []Value) ([]byte, bool) {
+	if len(values) == 0 {
+		return nil, false
+	}
+	typ := values[0].Type
+	if typ != parser.TypeInteger && typ != parser.TypeTimestamp {
+		return nil, false
+	}
+	data := []byte{encodingDelta, byte(typ)}
+	var prev int64
+	for _, val := range values {
+		if val.IsNull || val.Type != typ {
+			return nil, false
+		}
+		// The difference may overflow, but wraps back when added
+		data = binary.AppendVarint(data, val.Integer-prev)
+		prev = val.Integer
+	}
+	return data, true
+}
+
+// readChunk reads and decodes the n values of a chunk.
+func (t *Table) readChunk(chunk columnChunk, n int) ([]Value, error) {
+	data, err := t.pager.ReadOverflow(chunk.page, chunk.length)
+	if err != nil {
+		return nil, err
+	}
+	values, err := t.decodeChunk(data, n)
+	if err != nil {
+		return nil, fmt.Errorf("chunk at page %d: %w", chunk.page, err)
+	}
+	return values, nil
+}
+
+// decodeChunk decodes n values encoded by encodeChunk.
+func (t *Table) decodeChunk(data []byte, n int) ([]Value, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty chunk")
+	}
+	buf := bytes.NewReader(data[1:])
+	values := make([]Value, 0, n)
+
+	switch data[0] {
+	case encodingPlain:
+		for len(values) < n {
+			val, err := t.deserializeValue(buf)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, val)
+		}
+	case encodingRLE:
+		for len(values) < n {
+			run, err := binary.ReadUvarint(buf)
+			if err != nil {
+				return nil, err
+			}
+			val, err := t.deserializeValue(buf)
+			if err != nil {
+				return nil, err
+			}
+			if run > uint64(n-len(values)) {
+				return nil, fmt.Errorf("run of %d values runs past the end of the chunk", run)
+			}
+			for ; run > 0; run-- {
+				values = append(values, val)
+			}
+		}
+	case encodingDictionary:
+		size, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(n) {
+			return nil, fmt.Errorf("dictionary of %d values for %d rows", size, n)
+		}
+		dictionary := make([]Value, size)
+		for i := range dictionary {
+			if dictionary[i], err = t.deserializeValue(buf); err != nil {
+				return nil, err
+			}
+		}
+		for len(values) < n {
+			id, err := binary.ReadUvarint(buf)
+			if err != nil {
+				return nil, err
+			}
+			if id >= size {
+				return nil, fmt.Errorf("dictionary index %d out of range", id)
+			}
+			values = append(values, dictionary[id])
+		}
+	case encodingDelta:
+		typ, err := buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		var prev int64
+		for len(values) < n {
+			delta, err := binary.ReadVarint(buf)
+			if err != nil {
+				return nil, err
+			}
+			prev += delta
+			values = append(values, Value{Type: parser.DataType(typ), Integer: prev})
+		}
+	default:
+		return nil, fmt.Errorf("unknown chunk encoding %d", data[0])
+	}
+
+	if buf.Len() != 0 {
+		return nil, fmt.Errorf("%d bytes left after %d values", buf.Len(), n)
+	}
+	return values, nil
+}
+
+// groupRows decodes the rows of a row group, reading only the chunks of
+// the columns set in columns (nil for all of them); the others read as
+// NULL.
+func (t *Table) groupRows(g rowGroup, columns []bool) ([]Row, error) {
+	ids, err := t.readChunk(g.rowIDs, g.rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rows' values share one allocation
+	numColumns := len(t.Schema.Columns)
+	values := make([]Value, g.rows*numColumns)
+	rows := make([]Row, g.rows)
+	for i := range rows {
+		rows[i] = Row{ID: uint64(ids[i].Integer), Values: values[i*numColumns : (i+1)*numColumns]}
+	}
+
+	for col, chunk := range g.chunks {
+		if columns != nil && (col >= len(columns) || !columns[col]) {
+			for i := range rows {
+				rows[i].Values[col] = Value{IsNull: true}
+			}
+			continue
+		}
+		chunkValues, err := t.readChunk(chunk, g.rows)
+		if err != nil {
+			return nil, err
+		}
+		for i, val := range chunkValues {
+			rows[i].Values[col] = val
+		}
+	}
+	return rows, nil
+}
+
+// packedRows decodes the rows of every row group, in order. Caller must
+// hold the lock.
+func (t *Table) packedRows() ([]Row, error) {
+	var rows []Row
+	for _, g := range t.groups {
+		groupRows, err := t.groupRows(g, nil)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, groupRows...)
+	}
+	return rows, nil
+}
+
+// copyGroupsInto copies the row groups' chunks into compacted's pager and
+// counts their rows in its statistics. Caller must hold the lock.
+func (t *Table) copyGroupsInto(compacted *Table) error {
+	copyChunk := func(chunk columnChunk) (columnChunk, error) {
+		data, err := t.pager.ReadOverflow(chunk.page, chunk.length)
+		if err != nil {
+			return chunk, err
+		}
+		chunk.page, err = compacted.pager.WriteOverflow(data)
+		return chunk, err
+	}
+
+	compacted.columnar, compacted.groupSize = t.columnar, t.groupSize
+	for _, g := range t.groups {
+		copied := rowGroup{rows: g.rows, chunks: make([]columnChunk, len(g.chunks))}
+		var err error
+		if copied.rowIDs, err = copyChunk(g.rowIDs); err != nil {
+			return fmt.Errorf("failed to copy row group: %w", err)
+		}
+		for i, chunk := range g.chunks {
+			if copied.chunks[i], err = copyChunk(chunk); err != nil {
+				return fmt.Errorf("failed to copy row group: %w", err)
+			}
+		}
+		compacted.groups = append(compacted.groups, copied)
+		compacted.stats.RowCount += int64(g.rows)
+		compacted.stats.BytesStored += g.size()
+	}
+	return nil
+}
+
+// groupPages returns the pages of the row groups' chunks. Caller must hold
+// the lock.
+func (t *Table) groupPages() ([]uint32, error) {
+	var pages []uint32
+	for _, g := range t.groups {
+		for _, chunk := range append([]columnChunk{g.rowIDs}, g.chunks...) {
+			chain, err := t.pager.OverflowPages(chunk.page, chunk.length)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find pages of row group: %w", err)
+			}
+			pages = append(pages, chain...)
+		}
+	}
+	return pages, nil
+}
+
+// checkRowGroups verifies that the chunks of the row groups decode, and
+// claims their pages. Caller must hold the lock.
+func (t *Table) checkRowGroups(r *storage.IntegrityReport, owner string) {
+	for i, g := range t.groups {
+		for _, chunk := range append([]columnChunk{g.rowIDs}, g.chunks...) {
+			pages, err := t.pager.OverflowPages(chunk.page, chunk.length)
+			if err != nil {
+				r.Errorf("%s: row group %d: %v", owner, i, err)
+				continue
+			}
+			for _, pageID := range pages {
+				r.Claim(pageID, owner)
+			}
+		}
+		if _, err := t.groupRows(g, nil); err != nil {
+			r.Errorf("%s: row group %d: %v", owner, i, err)
+		}
+	}
+}
+
+// EncodeRowGroups serializes the row groups of a columnar table for the
+// catalog, or returns nil for a table stored by rows.
+//
+// The groups are stored as their count, then for each its row count, its
+// row ID chunk and its column chunks. A chunk is its first page, its
+// length, and whether it has values other than NULL, followed by their
+// minimum and maximum in the row format if it does.
+func (t *Table) EncodeRowGroups() ([]byte, error) {
+	if !t.columnar {
+		return nil, nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	buf := bytes.NewBuffer(nil)
+	writeChunk := func(chunk columnChunk) error {
+		binary.Write(buf, binary.LittleEndian, chunk.page)
+		binary.Write(buf, binary.LittleEndian, uint32(chunk.length))
+		if !chunk.zone.nonNull {
+			buf.WriteByte(0)
+			return nil
+		}
+		buf.WriteByte(1)
+		if err := t.serializeValue(buf, chunk.zone.min); err != nil {
+			return err
+		}
+		return t.serializeValue(buf, chunk.zone.max)
+	}
+
+	binary.Write(buf, binary.LittleEndian, uint32(len(t.groups)))
+	for _, g := range t.groups {
+		binary.Write(buf, binary.LittleEndian, uint32(g.rows))
+		if err := writeChunk(g.rowIDs); err != nil {
+			return nil, err
+		}
+		binary.Write(buf, binary.LittleEndian, uint16(len(g.chunks)))
+		for _, chunk := range g.chunks {
+			if err := writeChunk(chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadRowGroups makes the table columnar, with the row groups serialized
+// by EncodeRowGroups.
+func (t *Table) LoadRowGroups(data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	buf := bytes.NewReader(data)
+	readChunk := func() (columnChunk, error) {
+		var header struct {
+			Page   uint32
+			Length uint32
+		}
+		if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+			return columnChunk{}, err
+		}
+		chunk := columnChunk{page: header.Page, length: int(header.Length), zone: columnZone{known: true}}
+		nonNull, err := buf.ReadByte()
+		if err != nil || nonNull == 0 {
+			return chunk, err
+		}
+		chunk.zone.nonNull = true
+		if chunk.zone.min, err = t.deserializeValue(buf); err != nil {
+			return chunk, err
+		}
+		chunk.zone.max, err = t.deserializeValue(buf)
+		return chunk, err
+	}
+
+	var numGroups uint32
+	if err := binary.Read(buf, binary.LittleEndian, &numGroups); err != nil {
+		return fmt.Errorf("failed to read row groups: %w", err)
+	}
+	groups := make([]rowGroup, numGroups)
+	for i := range groups {
+		var rows uint32
+		if err := binary.Read(buf, binary.LittleEndian, &rows); err != nil {
+			return fmt.Errorf("failed to read row group %d: %w", i, err)
+		}
+		groups[i].rows = int(rows)
+		var err error
+		if groups[i].rowIDs, err = readChunk(); err != nil {
+			return fmt.Errorf("failed to read row group %d: %w", i, err)
+		}
+		var numChunks uint16
+		if err := binary.Read(buf, binary.LittleEndian, &numChunks); err != nil {
+			return fmt.Errorf("failed to read row group %d: %w", i, err)
+		}
+		if int(numChunks) != len(t.Schema.Columns) {
+			return fmt.Errorf("row group %d has %d columns, expected %d", i, numChunks, len(t.Schema.Columns))
+		}
+		groups[i].chunks = make([]columnChunk, numChunks)
+		for j := range groups[i].chunks {
+			if groups[i].chunks[j], err = readChunk(); err != nil {
+				return fmt.Errorf("failed to read row group %d: %w", i, err)
+			}
+		}
+	}
+
+	t.columnar, t.groups = true, groups
+	return nil
+}
@@ -0,0 +1,169 @@
+package table
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+func TestColumnarTable(t *testing.T) {
+	pager, err := storage.NewPager(filepath.Join(t.TempDir(), "columnar.db"))
+	if err != nil {
+		t.Fatalf("Failed to create pager: %v", err)
+	}
+	defer pager.Close()
+
+	schema := NewSchema([]parser.ColumnDefinition{
+		{Name: "id", Type: parser.TypeInteger},
+		{Name: "region", Type: parser.TypeText},
+		{Name: "amount", Type: parser.TypeInteger},
+		{Name: "note", Type: parser.TypeText},
+	})
+	tbl, err := NewColumnarTable("sales", schema, pager)
+	if err != nil {
+		t.Fatalf("NewColumnarTable failed: %v", err)
+	}
+	tbl.groupSize = 100
+
+	regions := []string{"east", "north", "south", "west"}
+	for i := 0; i < 250; i++ {
+		note := Value{Type: parser.TypeText, IsNull: true}
+		if i%7 == 0 {
+			note = Value{Type: parser.TypeText, Text: fmt.Sprintf("note %d", i)}
+		}
+		values := []Value{
+			{Type: parser.TypeInteger, Integer: int64(i)},
+			{Type: parser.TypeText, Text: regions[i/25%4]},
+			{Type: parser.TypeInteger, Integer: int64(i % 10)},
+			note,
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Two groups of 100 rows are packed, and 50 rows wait in data pages
+	if groups, buffered := tbl.RowGroups(); groups != 2 || buffered != 50 {
+		t.Fatalf("expected 2 row groups and 50 buffered rows, got %d and %d", groups, buffered)
+	}
+	rows, err := tbl.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(rows) != 250 {
+		t.Fatalf("expected 250 rows, got %d", len(rows))
+	}
+	for i, row := range rows {
+		if row.ID != uint64(i+1) || row.Values[0].Integer != int64(i) || row.Values[1].Text != regions[i/25%4] ||
+			row.Values[2].Integer != int64(i%10) || row.Values[3].IsNull != (i%7 != 0) {
+			t.Fatalf("row %d read back as %v (ID %d)", i, row.Values, row.ID)
+		}
+	}
+
+	// Runs, repeats and sequences compress below the row format
+	if stats := tbl.Stats(); stats.BytesStored >= int64(250*20) {
+		t.Errorf("expected the packed rows to take less room, got %d bytes", stats.BytesStored)
+	}
+
+	// A scan decodes only the columns asked for, and skips the groups
+	// whose range of ids excludes the condition
+	it := tbl.NewScanIterator()
+	it.DecodeColumns([]bool{true, false, true, false})
+	it.SkipPages([]ScanRange{{Column: 0, Lower: &Value{Type: parser.TypeInteger, Integer: 150}, LowerInclusive: true}})
+	count := 0
+	for it.Next() {
+		if !it.Row().Values[1].IsNull {
+			t.Fatalf("expected region not to be decoded, got %v", it.Row().Values)
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if count != 150 || it.PagesSkipped() != 1 {
+		t.Errorf("expected 150 rows read and 1 group skipped, got %d and %d", count, it.PagesSkipped())
+	}
+	it.Close()
+
+	// Statistics and integrity checks see every row
+	if err := tbl.Analyze(); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if stats := tbl.Stats(); stats.RowCount != 250 || stats.Columns["region"].DistinctValues != 4 {
+		t.Errorf("unexpected statistics %+v", stats)
+	}
+	report := storage.NewIntegrityReport()
+	tbl.CheckIntegrity(report)
+	if len(report.Problems) > 0 {
+		t.Errorf("unexpected integrity problems: %v", report.Problems)
+	}
+
+	// The groups survive being saved and loaded, and rows can't be changed
+	data, err := tbl.EncodeRowGroups()
+	if err != nil {
+		t.Fatalf("EncodeRowGroups failed: %v", err)
+	}
+	loaded := LoadTable("sales", schema, pager, tbl.GetRootPage(), tbl.GetNextRowID(), tbl.GetDataPageIDs())
+	if err := loaded.LoadRowGroups(data); err != nil {
+		t.Fatalf("LoadRowGroups failed: %v", err)
+	}
+	if rows, err := loaded.Scan(); err != nil || len(rows) != 250 {
+		t.Errorf("expected 250 rows after loading, got %d (%v)", len(rows), err)
+	}
+	if err := loaded.UpdateRow(rows[0], rows[0].Values); err == nil {
+		t.Error("expected UpdateRow on a columnar table to fail")
+	}
+	if err := loaded.CreateIndex("idx_region", []string{"region"}, false); err == nil {
+		t.Error("expected CreateIndex on a columnar table to fail")
+	}
+}
+
+func TestEncodeChunk(t *testing.T) {
+	tbl := &Table{Schema: NewSchema(nil)}
+	integers := func(values ...int64) []Value {
+		out := make([]Value, len(values))
+		for i, v := range values {
+			out[i] = Value{Type: parser.TypeInteger, Integer: v}
+		}
+		return out
+	}
+	texts := func(values ...string) []Value {
+		out := make([]Value, len(values))
+		for i, v := range values {
+			out[i] = Value{Type: parser.TypeText, Text: v}
+		}
+		return out
+	}
+
+	for _, test := range []struct {
+		values []Value
+		want   byte
+	}{
+		{texts("x", "x", "x", "x", "x", "y"), encodingRLE},
+		{integers(1000, 1001, 1003, 1004, 1010), encodingDelta},
+		{integers(-5, 1<<62, -(1 << 62), 0), encodingDelta},
+		{texts("red", "blue", "red", "blue", "red", "blue"), encodingDictionary},
+		{texts("a", "b"), encodingPlain},
+		{append(integers(1), Value{Type: parser.TypeInteger, IsNull: true}), encodingPlain},
+	} {
+		data, err := tbl.encodeChunk(test.values)
+		if err != nil {
+			t.Fatalf("encodeChunk(%v) failed: %v", test.values, err)
+		}
+		if data[0] != test.want {
+			t.Errorf("%v: expected encoding %d, got %d", test.values, test.want, data[0])
+		}
+		decoded, err := tbl.decodeChunk(data, len(test.values))
+		if err != nil {
+			t.Fatalf("decodeChunk(%v) failed: %v", test.values, err)
+		}
+		for i := range decoded {
+			if decoded[i] != test.values[i] {
+				t.Errorf("value %d: expected %v, got %v", i, test.values[i], decoded[i])
+			}
+		}
+	}
+}
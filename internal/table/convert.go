@@ -0,0 +1,207 @@
+// Package table - Value type conversion
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// CAST(expr AS type) converts a value from one SQL type to another.
+// Every database has to decide what happens at the edges:
+//
+//   CAST(3.9 AS INTEGER)      -> 3      (truncates toward zero)
+//   CAST('42' AS INTEGER)     -> 42
+//   CAST('abc' AS INTEGER)    -> error  (not silently 0, as MySQL does)
+//   CAST('yes' AS BOOLEAN)    -> TRUE
+//   CAST(NULL AS TEXT)        -> NULL   (NULL survives every cast)
+//   CAST('2024-03-01' AS TIMESTAMP) -> 2024-03-01 00:00:00
+//   CAST('19.99' AS DECIMAL)  -> 19.99  (exact; see decimal.go)
+//   CAST('{"a":1}' AS JSON)   -> {"a":1} (validated; see json.go)
+//
+// We follow PostgreSQL's lead: a conversion that would lose the meaning of
+// the value is an error rather than a guess.
+
+package table
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+// Cast converts v to the target type.
+//
+// Supported conversions are every pairing of INTEGER, REAL, TEXT and
+// BOOLEAN, plus TIMESTAMP to and from TEXT and numbers (as Unix seconds)
+// and DECIMAL to and from the other numeric types and TEXT. Anything can
+// become JSON; JSON converts to TEXT.
+// Casting a value to its own type returns it unchanged.
+func Cast(v Value, target parser.DataType) (Value, error) {
+	if v.IsNull {
+		return Value{Type: target, IsNull: true}, nil
+	}
+	if v.Type == target {
+		return v, nil
+	}
+
+	switch target {
+	case parser.TypeInteger:
+		return castToInteger(v)
+	case parser.TypeReal:
+		return castToReal(v)
+	case parser.TypeText:
+		return Value{Type: parser.TypeText, Text: v.String()}, nil
+	case parser.TypeBoolean:
+		return castToBoolean(v)
+	case parser.TypeTimestamp:
+		return castToTimestamp(v)
+	case parser.TypeDecimal:
+		return ToDecimal(v)
+	case parser.TypeJSON:
+		return castToJSON(v)
+	default:
+		return Value{}, fmt.Errorf("cannot cast %s to %s", v.Type, target)
+	}
+}
+
+// castToInteger converts a value to INTEGER.
+func castToInteger(v Value) (Value, error) {
+	switch v.Type {
+	case parser.TypeReal:
+		if math.IsNaN(v.Real) || math.IsInf(v.Real, 0) ||
+			v.Real >= math.MaxInt64 || v.Real < math.MinInt64 {
+			return Value{}, fmt.Errorf("cannot cast %g to INTEGER: out of range", v.Real)
+		}
+		return Value{Type: parser.TypeInteger, Integer: int64(v.Real)}, nil
+	case parser.TypeText:
+		i, err := strconv.ParseInt(strings.TrimSpace(v.Text), 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("cannot cast '%s' to INTEGER", v.Text)
+		}
+		return Value{Type: parser.TypeInteger, Integer: i}, nil
+	case parser.TypeBoolean:
+		if v.Boolean {
+			return Value{Type: parser.TypeInteger, Integer: 1}, nil
+		}
+		return Value{Type: parser.TypeInteger, Integer: 0}, nil
+	case parser.TypeTimestamp:
+		return Value{Type: parser.TypeInteger, Integer: v.Integer / microsPerSecond}, nil
+	case parser.TypeDecimal:
+		// Truncate toward zero, like REAL
+		return Value{Type: parser.TypeInteger, Integer: v.Integer / pow10(int(v.Scale)).Int64()}, nil
+	default:
+		return Value{}, fmt.Errorf("cannot cast %s to INTEGER", v.Type)
+	}
+}
+
+// castToReal converts a value to REAL.
+func castToReal(v Value) (Value, error) {
+	switch v.Type {
+	case parser.TypeInteger:
+		return Value{Type: parser.TypeReal, Real: float64(v.Integer)}, nil
+	case parser.TypeText:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v.Text), 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("cannot cast '%s' to REAL", v.Text)
+		}
+		return Value{Type: parser.TypeReal, Real: f}, nil
+	case parser.TypeBoolean:
+		if v.Boolean {
+			return Value{Type: parser.TypeReal, Real: 1}, nil
+		}
+		return Value{Type: parser.TypeReal, Real: 0}, nil
+	case parser.TypeTimestamp:
+		return Value{Type: parser.TypeReal, Real: float64(v.Integer) / microsPerSecond}, nil
+	case parser.TypeDecimal:
+		return Value{Type: parser.TypeReal, Real: DecimalToFloat(v)}, nil
+	default:
+		return Value{}, fmt.Errorf("cannot cast %s to REAL", v.Type)
+	}
+}
+
+// castToBoolean converts a value to BOOLEAN.
+// Numbers are TRUE when non-zero; text must spell out a truth value.
+func castToBoolean(v Value) (Value, error) {
+	switch v.Type {
+	case parser.TypeInteger, parser.TypeDecimal:
+		return Value{Type: parser.TypeBoolean, Boolean: v.Integer != 0}, nil
+	case parser.TypeReal:
+		return Value{Type: parser.TypeBoolean, Boolean: v.Real != 0}, nil
+	case parser.TypeText:
+		switch strings.ToLower(strings.TrimSpace(v.Text)) {
+		case "true", "t", "yes", "y", "on", "1":
+			return Value{Type: parser.TypeBoolean, Boolean: true}, nil
+		case "false", "f", "no", "n", "off", "0":
+			return Value{Type: parser.TypeBoolean, Boolean: false}, nil
+		}
+		return Value{}, fmt.Errorf("cannot cast '%s' to BOOLEAN", v.Text)
+	default:
+		return Value{}, fmt.Errorf("cannot cast %s to BOOLEAN", v.Type)
+	}
+}
+
+// ============================================================================
+// Timestamps
+// ============================================================================
+
+// EDUCATIONAL NOTE:
+// -----------------
+// A TIMESTAMP is stored as a single int64: microseconds since
+// 1970-01-01 00:00:00 UTC. Storing a number instead of a string means
+// timestamps sort and compare correctly with plain integer comparison, take
+// 8 bytes instead of ~26, and support arithmetic (adding an interval is just
+// addition). Text is only produced when a value is displayed.
+
+const microsPerSecond = 1_000_000
+
+// timestampLayouts are the text formats accepted for TIMESTAMP values,
+// tried in order. Values without a zone are taken to be UTC.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// TimestampValue wraps a time.Time as a TIMESTAMP value.
+func TimestampValue(t time.Time) Value {
+	return Value{Type: parser.TypeTimestamp, Integer: t.UnixMicro()}
+}
+
+// TimeOf returns the time.Time held by a TIMESTAMP value.
+func TimeOf(v Value) time.Time {
+	return time.UnixMicro(v.Integer).UTC()
+}
+
+// FormatTimestamp renders microseconds since the epoch as
+// 'YYYY-MM-DD HH:MM:SS', with a fractional part only when non-zero.
+func FormatTimestamp(micros int64) string {
+	return time.UnixMicro(micros).UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+// ParseTimestamp parses text in one of the accepted timestamp formats.
+func ParseTimestamp(s string) (Value, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return TimestampValue(t), nil
+		}
+	}
+	return Value{}, fmt.Errorf("cannot cast '%s' to TIMESTAMP", s)
+}
+
+// castToTimestamp converts a value to TIMESTAMP.
+// Numbers are interpreted as seconds since the Unix epoch.
+func castToTimestamp(v Value) (Value, error) {
+	switch v.Type {
+	case parser.TypeText:
+		return ParseTimestamp(v.Text)
+	case parser.TypeInteger:
+		return Value{Type: parser.TypeTimestamp, Integer: v.Integer * microsPerSecond}, nil
+	case parser.TypeReal:
+		return Value{Type: parser.TypeTimestamp, Integer: int64(v.Real * microsPerSecond)}, nil
+	default:
+		return Value{}, fmt.Errorf("cannot cast %s to TIMESTAMP", v.Type)
+	}
+}
@@ -0,0 +1,85 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestCast(t *testing.T) {
+	tests := []struct {
+		value    Value
+		target   parser.DataType
+		expected Value
+	}{
+		{Value{Type: parser.TypeInteger, Integer: 42}, parser.TypeReal, Value{Type: parser.TypeReal, Real: 42}},
+		{Value{Type: parser.TypeInteger, Integer: 42}, parser.TypeText, Value{Type: parser.TypeText, Text: "42"}},
+		{Value{Type: parser.TypeInteger, Integer: 0}, parser.TypeBoolean, Value{Type: parser.TypeBoolean, Boolean: false}},
+		{Value{Type: parser.TypeReal, Real: 3.9}, parser.TypeInteger, Value{Type: parser.TypeInteger, Integer: 3}},
+		{Value{Type: parser.TypeReal, Real: -3.9}, parser.TypeInteger, Value{Type: parser.TypeInteger, Integer: -3}},
+		{Value{Type: parser.TypeReal, Real: 2.5}, parser.TypeText, Value{Type: parser.TypeText, Text: "2.5"}},
+		{Value{Type: parser.TypeText, Text: " 17 "}, parser.TypeInteger, Value{Type: parser.TypeInteger, Integer: 17}},
+		{Value{Type: parser.TypeText, Text: "1.5"}, parser.TypeReal, Value{Type: parser.TypeReal, Real: 1.5}},
+		{Value{Type: parser.TypeText, Text: "Yes"}, parser.TypeBoolean, Value{Type: parser.TypeBoolean, Boolean: true}},
+		{Value{Type: parser.TypeBoolean, Boolean: true}, parser.TypeInteger, Value{Type: parser.TypeInteger, Integer: 1}},
+		{Value{Type: parser.TypeBoolean, Boolean: true}, parser.TypeText, Value{Type: parser.TypeText, Text: "TRUE"}},
+		{Value{IsNull: true}, parser.TypeInteger, Value{Type: parser.TypeInteger, IsNull: true}},
+	}
+
+	for _, tt := range tests {
+		result, err := Cast(tt.value, tt.target)
+		if err != nil {
+			t.Errorf("Cast(%v, %s) error: %v", tt.value, tt.target, err)
+			continue
+		}
+		if result.Type != tt.expected.Type || result.IsNull != tt.expected.IsNull || !result.Equals(tt.expected) {
+			t.Errorf("Cast(%v, %s) = %+v, expected %+v", tt.value, tt.target, result, tt.expected)
+		}
+	}
+}
+
+func TestCastErrors(t *testing.T) {
+	tests := []struct {
+		value  Value
+		target parser.DataType
+	}{
+		{Value{Type: parser.TypeText, Text: "abc"}, parser.TypeInteger},
+		{Value{Type: parser.TypeText, Text: "3.5"}, parser.TypeInteger},
+		{Value{Type: parser.TypeText, Text: "abc"}, parser.TypeReal},
+		{Value{Type: parser.TypeText, Text: "maybe"}, parser.TypeBoolean},
+		{Value{Type: parser.TypeReal, Real: 1e300}, parser.TypeInteger},
+	}
+
+	for _, tt := range tests {
+		if _, err := Cast(tt.value, tt.target); err == nil {
+			t.Errorf("Cast(%v, %s) expected error", tt.value, tt.target)
+		}
+	}
+}
+
+func TestCastTimestamp(t *testing.T) {
+	ts, err := Cast(Value{Type: parser.TypeText, Text: "2024-03-01 12:00:00"}, parser.TypeTimestamp)
+	if err != nil {
+		t.Fatalf("Cast to TIMESTAMP error: %v", err)
+	}
+	if ts.String() != "2024-03-01 12:00:00" {
+		t.Errorf("expected round trip to text, got %s", ts.String())
+	}
+
+	secs, err := Cast(ts, parser.TypeInteger)
+	if err != nil {
+		t.Fatalf("Cast to INTEGER error: %v", err)
+	}
+	if secs.Integer != 1709294400 {
+		t.Errorf("expected 1709294400 Unix seconds, got %d", secs.Integer)
+	}
+
+	back, err := Cast(secs, parser.TypeTimestamp)
+	if err != nil || !back.Equals(ts) {
+		t.Errorf("expected INTEGER -> TIMESTAMP round trip, got %v (%v)", back, err)
+	}
+
+	if _, err := Cast(Value{Type: parser.TypeText, Text: "yesterday"}, parser.TypeTimestamp); err == nil {
+		t.Error("expected error for unparseable timestamp")
+	}
+}
@@ -0,0 +1,243 @@
+// Package table - Fixed-point DECIMAL values
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// REAL is a binary floating-point number, and most decimal fractions have
+// no exact binary representation: 0.1 + 0.2 is 0.30000000000000004. That's
+// fine for measurements but unacceptable for money.
+//
+// DECIMAL stores a number as an integer plus a scale (the number of digits
+// after the decimal point):
+//
+//   19.99   -> unscaled 1999,  scale 2
+//   -0.5    -> unscaled -5,    scale 1
+//   100     -> unscaled 100,   scale 0
+//
+// Addition and subtraction align the scales and then use exact integer
+// arithmetic. Multiplication adds the scales (1.5 * 0.25 = 0.375). Division
+// is the one operation that can't be exact (1/3), so the result is rounded
+// to a fixed number of extra digits.
+//
+// The unscaled value lives in Value.Integer, so a DECIMAL can hold up to 18
+// significant digits. Intermediate results use math/big so an overflow is
+// reported instead of silently wrapping around.
+
+package table
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+const (
+	// MaxDecimalDigits is the largest precision a DECIMAL can hold in an int64.
+	MaxDecimalDigits = 18
+
+	// decimalDivisionExtraScale is how many digits beyond the operands'
+	// scale are kept when dividing.
+	decimalDivisionExtraScale = 6
+)
+
+// DecimalValue builds a DECIMAL from an unscaled integer and a scale.
+func DecimalValue(unscaled int64, scale uint8) Value {
+	return Value{Type: parser.TypeDecimal, Integer: unscaled, Scale: scale}
+}
+
+// ParseDecimal parses text such as "-12.345" into an exact DECIMAL.
+func ParseDecimal(s string) (Value, error) {
+	s = strings.TrimSpace(s)
+	text := s
+
+	negative := false
+	if strings.HasPrefix(text, "-") || strings.HasPrefix(text, "+") {
+		negative = text[0] == '-'
+		text = text[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(text, ".")
+	if intPart == "" && fracPart == "" {
+		return Value{}, fmt.Errorf("cannot cast '%s' to DECIMAL", s)
+	}
+	digits := intPart + fracPart
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Value{}, fmt.Errorf("cannot cast '%s' to DECIMAL", s)
+		}
+	}
+	if len(fracPart) > MaxDecimalDigits {
+		return Value{}, fmt.Errorf("cannot cast '%s' to DECIMAL: too many digits", s)
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Value{}, fmt.Errorf("cannot cast '%s' to DECIMAL", s)
+	}
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+	return decimalFromBig(unscaled, uint8(len(fracPart)))
+}
+
+// decimalString formats a DECIMAL, always showing every digit of its scale.
+func decimalString(v Value) string {
+	if v.Scale == 0 {
+		return strconv.FormatInt(v.Integer, 10)
+	}
+
+	digits := new(big.Int).Abs(big.NewInt(v.Integer)).String()
+	scale := int(v.Scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	point := len(digits) - scale
+	s := digits[:point] + "." + digits[point:]
+	if v.Integer < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// ToDecimal converts an INTEGER, REAL, TEXT or DECIMAL value to DECIMAL.
+//
+// REAL values are converted through their shortest decimal representation,
+// so the literal 19.99 becomes exactly 19.99 rather than the nearest binary
+// fraction 19.989999999999998436805981327779591083526611328125.
+func ToDecimal(v Value) (Value, error) {
+	switch v.Type {
+	case parser.TypeDecimal:
+		return v, nil
+	case parser.TypeInteger:
+		return DecimalValue(v.Integer, 0), nil
+	case parser.TypeReal:
+		if math.IsNaN(v.Real) || math.IsInf(v.Real, 0) {
+			return Value{}, fmt.Errorf("cannot cast %g to DECIMAL", v.Real)
+		}
+		return ParseDecimal(strconv.FormatFloat(v.Real, 'f', -1, 64))
+	case parser.TypeText:
+		return ParseDecimal(v.Text)
+	default:
+		return Value{}, fmt.Errorf("cannot cast %s to DECIMAL", v.Type)
+	}
+}
+
+// Rescale changes the scale of a DECIMAL, rounding half away from zero
+// when digits are dropped: 2.345 at scale 2 is 2.35.
+func Rescale(v Value, scale uint8) (Value, error) {
+	if v.Scale == scale {
+		return v, nil
+	}
+	return decimalFromBig(scaleBig(v, scale), scale)
+}
+
+// CheckPrecision reports an error if v has more than precision significant
+// digits, as for a value that doesn't fit a DECIMAL(precision, scale) column.
+func CheckPrecision(v Value, precision int) error {
+	if precision <= 0 {
+		return nil
+	}
+	digits := len(new(big.Int).Abs(big.NewInt(v.Integer)).String())
+	if digits > precision {
+		return fmt.Errorf("numeric field overflow: %s needs more than %d digits", decimalString(v), precision)
+	}
+	return nil
+}
+
+// DecimalToFloat returns the nearest float64 to a DECIMAL.
+func DecimalToFloat(v Value) float64 {
+	f, _ := strconv.ParseFloat(decimalString(v), 64)
+	return f
+}
+
+// DecimalAdd returns a + b.
+func DecimalAdd(a, b Value) (Value, error) {
+	scale := max(a.Scale, b.Scale)
+	sum := new(big.Int).Add(scaleBig(a, scale), scaleBig(b, scale))
+	return decimalFromBig(sum, scale)
+}
+
+// DecimalSub returns a - b.
+func DecimalSub(a, b Value) (Value, error) {
+	scale := max(a.Scale, b.Scale)
+	diff := new(big.Int).Sub(scaleBig(a, scale), scaleBig(b, scale))
+	return decimalFromBig(diff, scale)
+}
+
+// DecimalMul returns a * b. The result scale is the sum of the operand
+// scales, reduced (with rounding) if it would exceed MaxDecimalDigits.
+func DecimalMul(a, b Value) (Value, error) {
+	product := new(big.Int).Mul(big.NewInt(a.Integer), big.NewInt(b.Integer))
+	scale := int(a.Scale) + int(b.Scale)
+	if scale > MaxDecimalDigits {
+		product = roundDiv(product, pow10(scale-MaxDecimalDigits))
+		scale = MaxDecimalDigits
+	}
+	return decimalFromBig(product, uint8(scale))
+}
+
+// DecimalDiv returns a / b, keeping a few extra digits of scale.
+func DecimalDiv(a, b Value) (Value, error) {
+	if b.Integer == 0 {
+		return Value{}, fmt.Errorf("division by zero")
+	}
+
+	scale := min(int(max(a.Scale, b.Scale))+decimalDivisionExtraScale, MaxDecimalDigits)
+
+	// a/b at the target scale is (a.unscaled * 10^(scale - a.scale + b.scale)) / b.unscaled
+	shift := scale - int(a.Scale) + int(b.Scale)
+	num := new(big.Int).Mul(big.NewInt(a.Integer), pow10(shift))
+	return decimalFromBig(roundDiv(num, big.NewInt(b.Integer)), uint8(scale))
+}
+
+// decimalCompare compares two DECIMAL values numerically.
+func decimalCompare(a, b Value) int {
+	scale := max(a.Scale, b.Scale)
+	return scaleBig(a, scale).Cmp(scaleBig(b, scale))
+}
+
+// scaleBig returns the unscaled value of v expressed at the given scale.
+func scaleBig(v Value, scale uint8) *big.Int {
+	n := big.NewInt(v.Integer)
+	switch {
+	case scale > v.Scale:
+		return n.Mul(n, pow10(int(scale-v.Scale)))
+	case scale < v.Scale:
+		return roundDiv(n, pow10(int(v.Scale-scale)))
+	default:
+		return n
+	}
+}
+
+// decimalFromBig builds a DECIMAL, failing if the value overflows int64.
+func decimalFromBig(n *big.Int, scale uint8) (Value, error) {
+	if !n.IsInt64() {
+		return Value{}, fmt.Errorf("numeric value out of range")
+	}
+	return DecimalValue(n.Int64(), scale), nil
+}
+
+// roundDiv divides n by d, rounding half away from zero.
+func roundDiv(n, d *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	// |2r| >= |d| means the remainder is at least half
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	if twice.CmpAbs(d) >= 0 {
+		if (n.Sign() < 0) != (d.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// pow10 returns 10^n as a big.Int.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,169 @@
+package table
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input    string
+		unscaled int64
+		scale    uint8
+		text     string
+	}{
+		{"19.99", 1999, 2, "19.99"},
+		{"-0.5", -5, 1, "-0.5"},
+		{"100", 100, 0, "100"},
+		{".25", 25, 2, "0.25"},
+		{"+3.000", 3000, 3, "3.000"},
+		{"-0.007", -7, 3, "-0.007"},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseDecimal(tt.input)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q) error: %v", tt.input, err)
+			continue
+		}
+		if v.Integer != tt.unscaled || v.Scale != tt.scale {
+			t.Errorf("ParseDecimal(%q) = %d scale %d, expected %d scale %d",
+				tt.input, v.Integer, v.Scale, tt.unscaled, tt.scale)
+		}
+		if v.String() != tt.text {
+			t.Errorf("ParseDecimal(%q).String() = %q, expected %q", tt.input, v.String(), tt.text)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "1.2.3", "1e5", "-", "12345678901234567890"} {
+		if _, err := ParseDecimal(bad); err == nil {
+			t.Errorf("ParseDecimal(%q) expected error", bad)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	d := func(s string) Value {
+		v, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q): %v", s, err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name     string
+		op       func(a, b Value) (Value, error)
+		a, b     string
+		expected string
+	}{
+		{"add", DecimalAdd, "0.1", "0.2", "0.3"},
+		{"add mixed scale", DecimalAdd, "1.5", "0.25", "1.75"},
+		{"sub", DecimalSub, "10.00", "0.01", "9.99"},
+		{"mul", DecimalMul, "1.5", "0.25", "0.375"},
+		{"div", DecimalDiv, "1", "3", "0.333333"},
+		{"div rounds", DecimalDiv, "2", "3", "0.666667"},
+		{"div negative", DecimalDiv, "-10.00", "4", "-2.50000000"},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.op(d(tt.a), d(tt.b))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if result.String() != tt.expected {
+			t.Errorf("%s: %s, %s = %s, expected %s", tt.name, tt.a, tt.b, result, tt.expected)
+		}
+	}
+
+	if _, err := DecimalDiv(d("1"), d("0.00")); err == nil {
+		t.Error("expected division by zero error")
+	}
+	if _, err := DecimalMul(d("999999999999"), d("999999999999")); err == nil {
+		t.Error("expected overflow error")
+	}
+}
+
+func TestDecimalRescale(t *testing.T) {
+	tests := []struct {
+		input    string
+		scale    uint8
+		expected string
+	}{
+		{"2.345", 2, "2.35"},
+		{"-2.345", 2, "-2.35"},
+		{"2.344", 2, "2.34"},
+		{"7", 2, "7.00"},
+		{"0.5", 0, "1"},
+	}
+
+	for _, tt := range tests {
+		v, _ := ParseDecimal(tt.input)
+		result, err := Rescale(v, tt.scale)
+		if err != nil {
+			t.Errorf("Rescale(%s, %d) error: %v", tt.input, tt.scale, err)
+			continue
+		}
+		if result.String() != tt.expected {
+			t.Errorf("Rescale(%s, %d) = %s, expected %s", tt.input, tt.scale, result, tt.expected)
+		}
+	}
+
+	v, _ := ParseDecimal("12345.67")
+	if err := CheckPrecision(v, 7); err != nil {
+		t.Errorf("CheckPrecision(12345.67, 7) unexpected error: %v", err)
+	}
+	if err := CheckPrecision(v, 6); err == nil {
+		t.Error("CheckPrecision(12345.67, 6) expected error")
+	}
+}
+
+func TestDecimalCompareAndConvert(t *testing.T) {
+	a, _ := ParseDecimal("1.50")
+	b, _ := ParseDecimal("1.5")
+	if !a.Equals(b) {
+		t.Error("1.50 should equal 1.5")
+	}
+
+	two, _ := ParseDecimal("2.00")
+	integer := Value{Type: parser.TypeInteger, Integer: 2}
+	if !two.Equals(integer) || integer.Compare(a) <= 0 {
+		t.Error("DECIMAL should compare numerically with INTEGER")
+	}
+
+	// REAL converts through its shortest representation, not the binary value
+	fromReal, err := ToDecimal(Value{Type: parser.TypeReal, Real: 19.99})
+	if err != nil || fromReal.String() != "19.99" {
+		t.Errorf("ToDecimal(19.99) = %s, %v", fromReal, err)
+	}
+
+	neg, _ := ParseDecimal("-3.75")
+	if got, _ := Cast(neg, parser.TypeInteger); got.Integer != -3 {
+		t.Errorf("CAST(-3.75 AS INTEGER) = %d, expected -3", got.Integer)
+	}
+	if got, _ := Cast(neg, parser.TypeReal); got.Real != -3.75 {
+		t.Errorf("CAST(-3.75 AS REAL) = %g, expected -3.75", got.Real)
+	}
+}
+
+func TestDecimalSerialization(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	v, _ := ParseDecimal("-1234.5678")
+	buf := bytes.NewBuffer(nil)
+	if err := tbl.serializeValue(buf, v); err != nil {
+		t.Fatalf("serializeValue error: %v", err)
+	}
+
+	got, err := tbl.deserializeValue(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("deserializeValue error: %v", err)
+	}
+	if got.Type != parser.TypeDecimal || got.Integer != v.Integer || got.Scale != v.Scale {
+		t.Errorf("round trip = %+v, expected %+v", got, v)
+	}
+}
@@ -0,0 +1,145 @@
+// Package table - Integrity checking
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A table is stored three ways at once: the rows in its data pages, the
+// primary key B-tree pointing at them, and one B-tree per secondary
+// index. Every change has to update all of them, and a bug that misses
+// one leaves an index that silently returns the wrong rows. The check
+// here reads every row and makes sure each index has exactly one entry
+// for it, pointing at where the row really is.
+
+package table
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// CheckIntegrity verifies the table's data pages, primary key B-tree and
+// secondary indexes, and that they agree with each other, adding any
+// problems to r.
+func (t *Table) CheckIntegrity(r *storage.IntegrityReport) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	owner := fmt.Sprintf("table %s", t.Name)
+	primaryEntries := t.btree.CheckIntegrity(r, owner+" primary key")
+	indexEntries := make(map[string]int, len(t.indexes))
+	for name, idx := range t.indexes {
+		indexEntries[name] = idx.CheckIntegrity(r)
+	}
+
+	live := 0
+	for _, pageID := range t.dataPageIDs {
+		if !r.Claim(pageID, owner) {
+			continue
+		}
+		page, err := t.pager.GetPage(pageID)
+		if err != nil {
+			r.Errorf("%s: %v", owner, err)
+			continue
+		}
+		if page.Type() != storage.PageTypeData {
+			r.Errorf("%s: page %d has page type %d, expected %d", owner, pageID, page.Type(), storage.PageTypeData)
+			continue
+		}
+		live += t.checkDataPage(r, owner, page)
+	}
+
+	// The rows of a columnar table's row groups have no index entries
+	t.checkRowGroups(r, owner)
+
+	if primaryEntries != live {
+		r.Errorf("%s: primary key has %d entries for %d rows", owner, primaryEntries, live)
+	}
+	for name, entries := range indexEntries {
+		if entries != live {
+			r.Errorf("index %s: has %d entries for %d rows", name, entries, live)
+		}
+	}
+}
+
+// checkDataPage verifies the records in one data page and that every live
+// row is indexed, returning the number of live rows.
+func (t *Table) checkDataPage(r *storage.IntegrityReport, owner string, page *storage.Page) int {
+	data := page.GetData()
+	end := storage.MaxDataSize - int(page.FreeSpace())
+	offset, live := 0, 0
+
+	for slot := 0; slot < int(page.NumSlots()); slot++ {
+		if offset+2 > end {
+			r.Errorf("%s: page %d: record %d starts past the end of the used space", owner, page.ID(), slot)
+			return live
+		}
+		prefix := binary.LittleEndian.Uint16(data[offset:])
+		location := uint64(page.ID())<<32 | uint64(offset)
+		start := offset + 2
+		offset = start + int(prefix&rowLengthMask)
+		if offset > end {
+			r.Errorf("%s: page %d: record %d runs past the end of the used space", owner, page.ID(), slot)
+			return live
+		}
+		if prefix&rowDeletedFlag != 0 {
+			continue
+		}
+
+		record := data[start:offset]
+		if prefix&rowOverflowFlag != 0 && len(record) >= 8 {
+			length := binary.LittleEndian.Uint32(record)
+			pages, err := t.pager.OverflowPages(binary.LittleEndian.Uint32(record[4:]), int(length))
+			if err != nil {
+				r.Errorf("%s: page %d: record %d: %v", owner, page.ID(), slot, err)
+				continue
+			}
+			for _, pageID := range pages {
+				r.Claim(pageID, owner)
+			}
+		}
+		rowData, err := t.decodeRecord(record, prefix)
+		if err != nil {
+			r.Errorf("%s: page %d: record %d: %v", owner, page.ID(), slot, err)
+			continue
+		}
+		row, err := t.deserializeRow(rowData)
+		if err != nil {
+			r.Errorf("%s: page %d: record %d is not a valid row: %v", owner, page.ID(), slot, err)
+			continue
+		}
+
+		live++
+		t.checkRowIndexed(r, owner, row, location)
+	}
+
+	if offset != end {
+		r.Errorf("%s: page %d: records end at %d but free space starts at %d", owner, page.ID(), offset, end)
+	}
+	return live
+}
+
+// checkRowIndexed verifies that the primary key and every secondary index
+// point at the row stored at location.
+func (t *Table) checkRowIndexed(r *storage.IntegrityReport, owner string, row Row, location uint64) {
+	key, err := t.primaryIndexKey(row.Values, row.ID)
+	if err != nil {
+		r.Errorf("%s: row %d: %v", owner, row.ID, err)
+		return
+	}
+	if found, ok, err := t.btree.Search(key); err != nil {
+		r.Errorf("%s: row %d: primary key search failed: %v", owner, row.ID, err)
+	} else if !ok || found != location {
+		r.Errorf("%s: row %d is missing from the primary key", owner, row.ID)
+	}
+
+	for name, idx := range t.indexes {
+		locations, err := idx.Lookup(t.buildIndexKey(row, t.indexColumns(idx)))
+		if err != nil {
+			r.Errorf("index %s: lookup of row %d failed: %v", name, row.ID, err)
+		} else if !slices.Contains(locations, location) {
+			r.Errorf("index %s: row %d of table %s is missing", name, row.ID, t.Name)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// Package table - Row iterators
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Scan returns every row of the table in one slice, so scanning a table of
+// a million rows holds a million rows in memory at once, even to count
+// them or to return the first ten. A RowIterator hands out rows one at a
+// time instead, reading a data page only when the rows of the one before
+// it have been used up:
+//
+//   it := tbl.NewScanIterator()
+//   defer it.Close()
+//   for it.Next() {
+//       row := it.Row()
+//       ...
+//   }
+//   if err := it.Err(); err != nil { ... }
+//
+// At most one page of rows is decoded at a time, and a caller that stops
+// early never reads the rest of the table. This is the shape of every
+// operator in a "volcano" executor (see the executor's operators), and
+// the same as storage.BTreeIterator one level down.
+//
+// There are two orders to read rows in. NewScanIterator follows the data
+// pages, which is the cheapest: each page is read once. Iterator follows
+// the primary key B-tree instead, reading rows in key order (row ID order
+// for a table without a primary key), and SeekPK starts that walk at a
+// key, as a B-tree seek does:
+//
+//   it, err := tbl.SeekPK(table.Value{Type: parser.TypeInteger, Integer: 1000})
+//   ...                             // ids 1000, 1001, ... until Next is false
+//
+// Key order costs a page read per row where rows aren't stored in key
+// order, but it is the order ORDER BY the key and a merge join want, and
+// an embedder can stop after the rows it needs.
+
+package table
+
+import (
+	"fmt"
+
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+// RowIterator returns the rows of a table one at a time: all of them in
+// storage order, or those of a range of the primary key or all of them
+// in the order of an index.
+type RowIterator struct {
+	t *Table
+
+	// For a scan: the row groups of a columnar table and the data pages
+	// not yet read, and the rows of the current group or page not yet
+	// returned
+	groups  []rowGroup
+	pageIDs []uint32
+	pending []Row
+
+	// For a range of the primary key or a read of a secondary index: the
+	// B-tree iterator giving the locations of the rows in key order
+	keys *storage.BTreeIterator
+
+	// The columns to decode, or nil for all of them
+	columns []bool
+
+	// For a scan: the ranges that let it skip pages, and how many it has
+	// skipped
+	ranges  []ScanRange
+	skipped int
+
+	// For a parallel scan: how many workers decode its pages, and those
+	// decoding them once it has started on them (see parallel.go)
+	workers int
+	ahead   *readAhead
+
+	row Row
+	err error
+}
+
+// NewScanIterator returns an iterator over every row of the table, in the
+// order they are stored. Pages added after it is created aren't read.
+func (t *Table) NewScanIterator() *RowIterator {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return &RowIterator{t: t, groups: t.groups, pageIDs: append([]uint32(nil), t.dataPageIDs...)}
+}
+
+// Iterator returns an iterator over every row of the table in primary key
+// order, or in row ID order if the table has no primary key. A columnar
+// table, which has neither, gives its rows in storage order, as
+// NewScanIterator does.
+func (t *Table) Iterator() *RowIterator {
+	if t.columnar {
+		return t.NewScanIterator()
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return &RowIterator{t: t, keys: t.btree.NewIterator()}
+}
+
+// IndexIterator returns an iterator over every row of the table in the
+// order of the named secondary index. Every row has an entry, those with
+// NULLs first, since NULL keys sort before every other value.
+func (t *Table) IndexIterator(name string) (*RowIterator, error) {
+	idx, ok := t.GetIndex(name)
+	if !ok {
+		return nil, fmt.Errorf("index %s does not exist", name)
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return &RowIterator{t: t, keys: idx.Iterator()}, nil
+}
+
+// SeekPK returns an iterator over the rows from the first whose primary
+// key is at or after keyValues, in key order. keyValues may be the first
+// columns of a composite primary key only, to start at the first row
+// whose leading columns are at or after them.
+func (t *Table) SeekPK(keyValues ...Value) (*RowIterator, error) {
+	if len(t.Schema.PrimaryKeyColumns) == 0 {
+		return nil, fmt.Errorf("table has no primary key")
+	}
+	if len(keyValues) == 0 || len(keyValues) > len(t.Schema.PrimaryKeyColumns) {
+		return nil, fmt.Errorf("primary key has %d columns, got %d values",
+			len(t.Schema.PrimaryKeyColumns), len(keyValues))
+	}
+	startKey, err := t.primaryKeyBytes(keyValues)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	keys := t.btree.RangeScan(startKey, nil, storage.RangeScanOptions{StartInclusive: true})
+	return &RowIterator{t: t, keys: keys}, nil
+}
+
+// NewPrimaryKeyRangeIterator returns an iterator over the rows whose
+// primary key lies between lower and upper (nil for no bound), in key
+// order. A composite primary key can only be scanned whole.
+func (t *Table) NewPrimaryKeyRangeIterator(lower, upper *Value, lowerInclusive, upperInclusive bool) (*RowIterator, error) {
+	if len(t.Schema.PrimaryKeyColumns) == 0 {
+		return nil, fmt.Errorf("table has no primary key")
+	}
+	if t.Schema.PrimaryKey < 0 && (lower != nil || upper != nil) {
+		return nil, fmt.Errorf("cannot scan a range of a composite primary key")
+	}
+
+	var startKey, endKey []byte
+	var err error
+	coll := t.Schema.Columns[t.Schema.PrimaryKeyColumns[0]].Collation
+	if lower != nil {
+		if startKey, err = t.valueToBytes(coll.Key(*lower)); err != nil {
+			return nil, fmt.Errorf("failed to serialize range start: %w", err)
+		}
+	}
+	if upper != nil {
+		if endKey, err = t.valueToBytes(coll.Key(*upper)); err != nil {
+			return nil, fmt.Errorf("failed to serialize range end: %w", err)
+		}
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	keys := t.btree.RangeScan(startKey, endKey, storage.RangeScanOptions{
+		StartInclusive: lowerInclusive,
+		EndInclusive:   upperInclusive,
+	})
+	return &RowIterator{t: t, keys: keys}, nil
+}
+
+// DecodeColumns limits the columns the iterator decodes to those set in
+// columns, for a caller that reads only some of them; the others read as
+// NULL. nil decodes every column, as by default.
+func (it *RowIterator) DecodeColumns(columns []bool) {
+	it.columns = columns
+}
+
+// SkipPages lets a scan skip the data pages, and the row groups of a
+// columnar table, that by the range of their values have no row in all of
+// ranges (see zonemap.go). Rows outside the
+// ranges are still returned from the pages that are read. Ranges on
+// columns that ZoneMapColumn rejects are ignored.
+func (it *RowIterator) SkipPages(ranges []ScanRange) {
+	it.ranges = it.ranges[:0]
+	for _, r := range ranges {
+		if r.Column < len(it.t.Schema.Columns) && ZoneMapColumn(it.t.Schema.Columns[r.Column]) {
+			it.ranges = append(it.ranges, r)
+		}
+	}
+}
+
+// PagesSkipped returns how many data pages and row groups the scan has
+// skipped.
+func (it *RowIterator) PagesSkipped() int {
+	return it.skipped
+}
+
+// Next advances to the next row. It returns false when there are no more
+// rows or an error occurred; see Err.
+func (it *RowIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.keys != nil {
+		if !it.keys.Next() {
+			if err := it.keys.Err(); err != nil {
+				it.err = fmt.Errorf("range scan failed: %w", err)
+			}
+			return false
+		}
+		it.t.mu.RLock()
+		row, err := it.t.getRowByLocationLocked(it.keys.Value(), it.columns)
+		it.t.mu.RUnlock()
+		if err != nil {
+			it.err = fmt.Errorf("failed to fetch row: %w", err)
+			return false
+		}
+		it.row = row
+		return true
+	}
+
+	for len(it.pending) == 0 {
+		if len(it.groups) > 0 {
+			group := it.groups[0]
+			it.groups = it.groups[1:]
+			if len(it.ranges) > 0 && group.excludes(it.ranges) {
+				it.skipped++
+				continue
+			}
+			var err error
+			it.t.mu.RLock()
+			it.pending, err = it.t.groupRows(group, it.columns)
+			it.t.mu.RUnlock()
+			if err != nil {
+				it.err = err
+				return false
+			}
+			continue
+		}
+		var page pageRows
+		switch {
+		case it.ahead != nil:
+			result, ok := <-it.ahead.pages
+			if !ok {
+				return false
+			}
+			page = <-result
+		case len(it.pageIDs) == 0:
+			return false
+		case it.workers > 1:
+			it.startReadAhead()
+			continue
+		default:
+			page = it.t.scanPage(it.pageIDs[0], it.ranges, it.columns)
+			it.pageIDs = it.pageIDs[1:]
+		}
+		if page.err != nil {
+			it.err = page.err
+			return false
+		}
+		if page.skipped {
+			it.skipped++
+		}
+		it.pending = page.rows
+	}
+	it.row, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+// scanPage reads the rows of a data page for a scan, decoding columns
+// (nil for all), unless by the range of their values it has none in all
+// of ranges.
+func (t *Table) scanPage(pageID uint32, ranges []ScanRange, columns []bool) pageRows {
+	if len(ranges) > 0 && t.zonesExclude(pageID, ranges) {
+		return pageRows{skipped: true}
+	}
+	page, err := t.pager.GetPage(pageID)
+	if err != nil {
+		return pageRows{err: err}
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	rows, err := t.readColumnsFromPage(page, columns)
+	if err != nil {
+		return pageRows{err: err}
+	}
+	if len(ranges) > 0 {
+		t.buildZones(pageID, rows, ranges, columns)
+	}
+	return pageRows{rows: rows}
+}
+
+// Row returns the current row.
+func (it *RowIterator) Row() Row {
+	return it.row
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// Close releases the iterator. It must be called when the caller is done,
+// whether or not it read every row.
+func (it *RowIterator) Close() error {
+	if it.ahead != nil {
+		it.ahead.stop()
+		it.ahead = nil
+	}
+	it.groups, it.pageIDs, it.pending = nil, nil, nil
+	if it.keys != nil {
+		return it.keys.Close()
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package table
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+	"github.com/cabewaldrop/claude-db/internal/storage"
+)
+
+func TestRowIterator(t *testing.T) {
+	tbl, pager, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	// Enough rows to fill several data pages, inserted out of key order
+	const n = 500
+	for i := 0; i < n; i++ {
+		id := int64((i * 7) % n)
+		values := []Value{
+			{Type: parser.TypeInteger, Integer: id},
+			{Type: parser.TypeText, Text: "A name long enough to take up some room"},
+			{Type: parser.TypeInteger, Integer: id % 50},
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if len(tbl.GetDataPageIDs()) < 3 {
+		t.Fatalf("expected several data pages, got %d", len(tbl.GetDataPageIDs()))
+	}
+
+	it := tbl.NewScanIterator()
+	count := 0
+	for it.Next() {
+		count++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	it.Close()
+	if count != n {
+		t.Errorf("expected %d rows, got %d", n, count)
+	}
+
+	// Stopping early reads only the first page
+	before := pager.IOStats().PageRequests
+	it = tbl.NewScanIterator()
+	if !it.Next() {
+		t.Fatalf("expected a row: %v", it.Err())
+	}
+	it.Close()
+	if reads := pager.IOStats().PageRequests - before; reads != 1 {
+		t.Errorf("expected 1 page read for the first row, got %d", reads)
+	}
+
+	lower := Value{Type: parser.TypeInteger, Integer: 10}
+	upper := Value{Type: parser.TypeInteger, Integer: 15}
+	it, err := tbl.NewPrimaryKeyRangeIterator(&lower, &upper, false, true)
+	if err != nil {
+		t.Fatalf("NewPrimaryKeyRangeIterator failed: %v", err)
+	}
+	defer it.Close()
+	want := int64(11)
+	for it.Next() {
+		if got := it.Row().Values[0].Integer; got != want {
+			t.Errorf("expected id %d, got %d", want, got)
+		}
+		want++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("range scan failed: %v", err)
+	}
+	if want != 16 {
+		t.Errorf("expected ids 11 to 15, stopped before %d", want)
+	}
+
+	// A scan can decode just the columns it needs
+	it = tbl.NewScanIterator()
+	defer it.Close()
+	it.DecodeColumns([]bool{false, false, true})
+	for it.Next() {
+		if row := it.Row(); !row.Values[0].IsNull || !row.Values[1].IsNull || row.Values[2].IsNull {
+			t.Fatalf("expected only the last column, got %v", row.Values)
+		}
+	}
+}
+
+func TestIteratorAndSeekPK(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+	for _, id := range []int64{5, 1, 4, 2, 3} {
+		values := []Value{
+			{Type: parser.TypeInteger, Integer: id},
+			{Type: parser.TypeText, Text: "name"},
+			{Type: parser.TypeInteger, Integer: id},
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// ids returns the ids an iterator gives, and closes it
+	ids := func(it *RowIterator) []int64 {
+		t.Helper()
+		defer it.Close()
+		var ids []int64
+		for it.Next() {
+			ids = append(ids, it.Row().Values[0].Integer)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("iteration failed: %v", err)
+		}
+		return ids
+	}
+
+	if got := ids(tbl.Iterator()); len(got) != 5 || got[0] != 1 || got[4] != 5 {
+		t.Errorf("expected ids 1 to 5 in order, got %v", got)
+	}
+	it, err := tbl.SeekPK(Value{Type: parser.TypeInteger, Integer: 3})
+	if err != nil {
+		t.Fatalf("SeekPK failed: %v", err)
+	}
+	if got := ids(it); len(got) != 3 || got[0] != 3 {
+		t.Errorf("expected ids 3 to 5, got %v", got)
+	}
+	if _, err := tbl.SeekPK(); err == nil {
+		t.Error("expected SeekPK without a key to fail")
+	}
+
+	// A composite key can be sought by its first column
+	pager, err := storage.NewPager(filepath.Join(t.TempDir(), "composite.db"))
+	if err != nil {
+		t.Fatalf("NewPager failed: %v", err)
+	}
+	defer pager.Close()
+	schema := NewSchema([]parser.ColumnDefinition{
+		{Name: "a", Type: parser.TypeInteger},
+		{Name: "b", Type: parser.TypeText},
+	})
+	schema.SetPrimaryKey([]string{"a", "b"})
+	pairs, err := NewTable("pairs", schema, pager)
+	if err != nil {
+		t.Fatalf("NewTable failed: %v", err)
+	}
+	for _, a := range []int64{2, 1, 3} {
+		for _, b := range []string{"y", "x"} {
+			if _, err := pairs.Insert([]Value{{Type: parser.TypeInteger, Integer: a}, {Type: parser.TypeText, Text: b}}); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+	}
+	it, err = pairs.SeekPK(Value{Type: parser.TypeInteger, Integer: 2})
+	if err != nil {
+		t.Fatalf("SeekPK failed: %v", err)
+	}
+	defer it.Close()
+	var got []string
+	for it.Next() {
+		got = append(got, it.Row().Values[0].String()+it.Row().Values[1].Text)
+	}
+	if strings.Join(got, " ") != "2x 2y 3x 3y" {
+		t.Errorf("expected the rows from a = 2 in key order, got %v", got)
+	}
+}
+
+func TestIndexIterator(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+	if err := tbl.CreateIndex("users_age", []string{"age"}, false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	for id, age := range []Value{IntegerValue(30), NullValue(), IntegerValue(10), IntegerValue(20)} {
+		values := []Value{IntegerValue(int64(id + 1)), TextValue("name"), age}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Every row comes in age order, the NULL first
+	it, err := tbl.IndexIterator("users_age")
+	if err != nil {
+		t.Fatalf("IndexIterator failed: %v", err)
+	}
+	defer it.Close()
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Row().Values[0].String())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	if got := strings.Join(ids, " "); got != "2 3 4 1" {
+		t.Errorf("expected ids 2 3 4 1, got %s", got)
+	}
+
+	if _, err := tbl.IndexIterator("missing"); err == nil {
+		t.Error("expected an unknown index to fail")
+	}
+}
+
+func TestDecodeColumns(t *testing.T) {
+	row := Row{ID: 9, Values: []Value{
+		{Type: parser.TypeText, Text: strings.Repeat("x", 70000)}, // a long length
+		{Type: parser.TypeInteger, Integer: 42},
+		{Type: parser.TypeDecimal, Integer: 1999, Scale: 2},
+		{Type: parser.TypeBoolean, Boolean: true},
+		{Type: parser.TypeText, IsNull: true},
+		{Type: parser.TypeReal, Real: 2.5},
+		{Type: parser.TypeJSON, Text: `{"a":1}`},
+	}}
+	data, err := EncodeRow(row)
+	if err != nil {
+		t.Fatalf("EncodeRow failed: %v", err)
+	}
+
+	var tbl *Table
+	columns := []bool{false, true, false, false, false, true, true}
+	got, err := tbl.deserializeColumns(data, columns)
+	if err != nil {
+		t.Fatalf("deserializeColumns failed: %v", err)
+	}
+	for i, val := range got.Values {
+		if columns[i] && val.Compare(row.Values[i]) != 0 {
+			t.Errorf("column %d: expected %v, got %v", i, row.Values[i], val)
+		}
+		if !columns[i] && !val.IsNull {
+			t.Errorf("column %d: expected a skipped column to read as NULL, got %v", i, val)
+		}
+	}
+	if got.ID != 9 {
+		t.Errorf("expected row ID 9, got %d", got.ID)
+	}
+
+	// A truncated row is still an error when the bad value is skipped
+	if _, err := tbl.deserializeColumns(data[:100], columns); err == nil {
+		t.Error("expected an error for a truncated row")
+	}
+}
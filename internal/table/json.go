@@ -0,0 +1,286 @@
+// Package table - JSON values and paths
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A JSON column stores a document as text, but unlike TEXT the text must
+// be valid JSON: it is checked when the value is written, so every stored
+// document can be parsed again later.
+//
+// Individual fields are reached with a path expression, as in SQLite and
+// MySQL:
+//
+//   $                 the whole document
+//   $.name            the "name" member of an object
+//   $.tags[0]         the first element of the "tags" array
+//   $.address.city    nested members
+//
+// json_extract(doc, path) turns the JSON value at a path back into a SQL
+// value (a JSON string becomes TEXT, a number INTEGER or REAL), so it can be
+// compared in a WHERE clause like any column:
+//
+//   SELECT * FROM people WHERE json_extract(data, '$.age') > 30
+//
+// Documents are re-encoded after json_set, which puts object members in
+// sorted order. JSON objects are unordered, so this doesn't change meaning.
+
+package table
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+// JSONValue wraps text as a JSON value after checking that it is valid.
+func JSONValue(text string) (Value, error) {
+	text = strings.TrimSpace(text)
+	if !json.Valid([]byte(text)) {
+		return Value{}, fmt.Errorf("invalid JSON: %s", text)
+	}
+	return Value{Type: parser.TypeJSON, Text: text}, nil
+}
+
+// castToJSON converts a value to JSON. Text must already be a JSON
+// document; other values become the matching JSON scalar.
+func castToJSON(v Value) (Value, error) {
+	if v.Type == parser.TypeText {
+		return JSONValue(v.Text)
+	}
+	encoded, err := encodeJSON(sqlToJSON(v))
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{Type: parser.TypeJSON, Text: encoded}, nil
+}
+
+// jsonPathStep is one component of a path: an object key or array index.
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses a path such as $.a.b[2] into steps.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSON path must start with $: %s", path)
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in JSON path: %s", path)
+			}
+			steps = append(steps, jsonPathStep{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in JSON path: %s", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid array index in JSON path: %s", path)
+			}
+			steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSON path: %s", path)
+		}
+	}
+	return steps, nil
+}
+
+// JSONExtract returns the value at path within doc as a SQL value, or
+// NULL if the path doesn't exist.
+func JSONExtract(doc Value, path string) (Value, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return Value{}, err
+	}
+	root, err := decodeJSON(doc.Text)
+	if err != nil {
+		return Value{}, err
+	}
+
+	current := root
+	for _, step := range steps {
+		var ok bool
+		if current, ok = step.lookup(current); !ok {
+			return Value{IsNull: true}, nil
+		}
+	}
+	return jsonToSQL(current)
+}
+
+// JSONSet returns a copy of doc with the value at path replaced by val.
+// Missing object members are created, as are intermediate objects; an
+// array index may be at most one past the end, which appends.
+func JSONSet(doc Value, path string, val Value) (Value, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return Value{}, err
+	}
+	root, err := decodeJSON(doc.Text)
+	if err != nil {
+		return Value{}, err
+	}
+
+	root, err = setJSONPath(root, steps, sqlToJSON(val))
+	if err != nil {
+		return Value{}, fmt.Errorf("%w: %s", err, path)
+	}
+	encoded, err := encodeJSON(root)
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{Type: parser.TypeJSON, Text: encoded}, nil
+}
+
+// lookup follows one path step from a decoded JSON value.
+func (s jsonPathStep) lookup(node any) (any, bool) {
+	if s.isIndex {
+		arr, ok := node.([]any)
+		if !ok || s.index >= len(arr) {
+			return nil, false
+		}
+		return arr[s.index], true
+	}
+	obj, ok := node.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	child, ok := obj[s.key]
+	return child, ok
+}
+
+// setJSONPath stores val at steps below node and returns the new node.
+func setJSONPath(node any, steps []jsonPathStep, val any) (any, error) {
+	if len(steps) == 0 {
+		return val, nil
+	}
+	step := steps[0]
+
+	if step.isIndex {
+		arr, ok := node.([]any)
+		if !ok {
+			return nil, fmt.Errorf("JSON path indexes a non-array")
+		}
+		if step.index > len(arr) {
+			return nil, fmt.Errorf("JSON array index out of range")
+		}
+		var child any
+		if step.index < len(arr) {
+			child = arr[step.index]
+		}
+		newChild, err := setJSONPath(child, steps[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		if step.index == len(arr) {
+			return append(arr, newChild), nil
+		}
+		arr[step.index] = newChild
+		return arr, nil
+	}
+
+	obj, ok := node.(map[string]any)
+	if node == nil {
+		obj, ok = map[string]any{}, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("JSON path has a member of a non-object")
+	}
+	newChild, err := setJSONPath(obj[step.key], steps[1:], val)
+	if err != nil {
+		return nil, err
+	}
+	obj[step.key] = newChild
+	return obj, nil
+}
+
+// decodeJSON parses a document, keeping numbers as json.Number so that
+// integers and decimals survive without a round trip through float64.
+func decodeJSON(text string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return v, nil
+}
+
+// encodeJSON renders a decoded value as compact JSON.
+func encodeJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", fmt.Errorf("encoding JSON: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// jsonToSQL converts a decoded JSON value to the closest SQL value.
+// Objects and arrays stay JSON.
+func jsonToSQL(v any) (Value, error) {
+	switch x := v.(type) {
+	case nil:
+		return Value{IsNull: true}, nil
+	case string:
+		return Value{Type: parser.TypeText, Text: x}, nil
+	case bool:
+		return Value{Type: parser.TypeBoolean, Boolean: x}, nil
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return Value{Type: parser.TypeInteger, Integer: i}, nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid JSON number %s", x)
+		}
+		return Value{Type: parser.TypeReal, Real: f}, nil
+	default:
+		encoded, err := encodeJSON(x)
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{Type: parser.TypeJSON, Text: encoded}, nil
+	}
+}
+
+// sqlToJSON converts a SQL value to a value encodable as JSON. A JSON
+// value is embedded as a document rather than as a string.
+func sqlToJSON(v Value) any {
+	if v.IsNull {
+		return nil
+	}
+	switch v.Type {
+	case parser.TypeInteger, parser.TypeDecimal:
+		return json.Number(v.String())
+	case parser.TypeReal:
+		return v.Real
+	case parser.TypeBoolean:
+		return v.Boolean
+	case parser.TypeJSON:
+		if decoded, err := decodeJSON(v.Text); err == nil {
+			return decoded
+		}
+		return v.Text
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,103 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestJSONValue(t *testing.T) {
+	v, err := JSONValue(` {"a": [1, 2]} `)
+	if err != nil {
+		t.Fatalf("JSONValue error: %v", err)
+	}
+	if v.Type != parser.TypeJSON || v.Text != `{"a": [1, 2]}` {
+		t.Errorf("JSONValue = %+v", v)
+	}
+
+	for _, bad := range []string{"", "{", "{'a': 1}", "abc"} {
+		if _, err := JSONValue(bad); err == nil {
+			t.Errorf("JSONValue(%q) expected error", bad)
+		}
+	}
+
+	// Non-text values become JSON scalars
+	got, err := Cast(Value{Type: parser.TypeInteger, Integer: 42}, parser.TypeJSON)
+	if err != nil || got.Text != "42" {
+		t.Errorf("CAST(42 AS JSON) = %+v, %v", got, err)
+	}
+}
+
+func TestJSONExtract(t *testing.T) {
+	doc, _ := JSONValue(`{"name": "Ada", "age": 36, "score": 9.5, "admin": true,
+		"tags": ["math", "computing"], "address": {"city": "London"}, "manager": null}`)
+
+	tests := []struct {
+		path     string
+		expected Value
+	}{
+		{"$.name", Value{Type: parser.TypeText, Text: "Ada"}},
+		{"$.age", Value{Type: parser.TypeInteger, Integer: 36}},
+		{"$.score", Value{Type: parser.TypeReal, Real: 9.5}},
+		{"$.admin", Value{Type: parser.TypeBoolean, Boolean: true}},
+		{"$.tags[1]", Value{Type: parser.TypeText, Text: "computing"}},
+		{"$.address.city", Value{Type: parser.TypeText, Text: "London"}},
+		{"$.tags", Value{Type: parser.TypeJSON, Text: `["math","computing"]`}},
+		{"$.manager", Value{IsNull: true}},
+		{"$.missing", Value{IsNull: true}},
+		{"$.tags[5]", Value{IsNull: true}},
+		{"$.name.first", Value{IsNull: true}},
+	}
+
+	for _, tt := range tests {
+		got, err := JSONExtract(doc, tt.path)
+		if err != nil {
+			t.Errorf("JSONExtract(%s) error: %v", tt.path, err)
+			continue
+		}
+		if got.IsNull != tt.expected.IsNull || (!got.IsNull && (got.Type != tt.expected.Type || !got.Equals(tt.expected))) {
+			t.Errorf("JSONExtract(%s) = %+v, expected %+v", tt.path, got, tt.expected)
+		}
+	}
+
+	for _, bad := range []string{"name", "$.", "$[x]", "$.tags[0"} {
+		if _, err := JSONExtract(doc, bad); err == nil {
+			t.Errorf("JSONExtract(%s) expected error", bad)
+		}
+	}
+}
+
+func TestJSONSet(t *testing.T) {
+	doc, _ := JSONValue(`{"name": "Ada", "tags": ["math"]}`)
+
+	tests := []struct {
+		path     string
+		value    Value
+		expected string
+	}{
+		{"$.name", Value{Type: parser.TypeText, Text: "Grace"}, `{"name":"Grace","tags":["math"]}`},
+		{"$.age", Value{Type: parser.TypeInteger, Integer: 36}, `{"age":36,"name":"Ada","tags":["math"]}`},
+		{"$.tags[1]", Value{Type: parser.TypeText, Text: "<code>"}, `{"name":"Ada","tags":["math","<code>"]}`},
+		{"$.address.city", Value{Type: parser.TypeText, Text: "London"}, `{"address":{"city":"London"},"name":"Ada","tags":["math"]}`},
+		{"$.name", Value{IsNull: true}, `{"name":null,"tags":["math"]}`},
+		{"$.tags", Value{Type: parser.TypeJSON, Text: `[1, 2]`}, `{"name":"Ada","tags":[1,2]}`},
+	}
+
+	for _, tt := range tests {
+		got, err := JSONSet(doc, tt.path, tt.value)
+		if err != nil {
+			t.Errorf("JSONSet(%s) error: %v", tt.path, err)
+			continue
+		}
+		if got.Type != parser.TypeJSON || got.Text != tt.expected {
+			t.Errorf("JSONSet(%s) = %s, expected %s", tt.path, got.Text, tt.expected)
+		}
+	}
+
+	if _, err := JSONSet(doc, "$.tags[3]", Value{Type: parser.TypeInteger, Integer: 1}); err == nil {
+		t.Error("expected error setting an index past the end of an array")
+	}
+	if _, err := JSONSet(doc, "$.name[0]", Value{Type: parser.TypeInteger, Integer: 1}); err == nil {
+		t.Error("expected error indexing a string")
+	}
+}
@@ -0,0 +1,146 @@
+// Package table - Order-preserving key encoding
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A B-tree compares keys as plain bytes (bytes.Compare), so for a range
+// scan to return the right rows, comparing two encoded keys must give
+// the same answer as comparing the values they came from. The row format
+// (serializeValue) doesn't: it writes integers little-endian, so 256
+// (00 01 ...) sorts before 1 (01 00 ...), and two's complement puts -1
+// (FF FF ...) after every positive number.
+//
+// Keys get their own encoding instead, designed to sort correctly:
+//
+//   - Integers are written big-endian, most significant byte first, with
+//     the sign bit flipped. That maps -2^63..2^63-1 onto 0..2^64-1 in
+//     order: -1 becomes 7F FF ..., 0 becomes 80 00 ..., 1 becomes 80 ... 01.
+//
+//   - Floats use their IEEE 754 bits. For a positive number the bits
+//     already sort like the number once the sign bit is set; for a
+//     negative one, flipping every bit reverses their order so that -2
+//     sorts before -1.
+//
+//   - Text is written as is, since UTF-8 sorts by code point byte for
+//     byte, and ended with 00 01. A 00 inside the text is escaped as
+//     00 FF. The terminator sorts below any other byte after 00, so "ab"
+//     comes before "abc", and no key is a prefix of another, which keys
+//     of several columns need: ("ab", "c") must not look like ("a", "bc").
+//
+//   - Decimals can't use the integer encoding, since a plain DECIMAL
+//     column mixes scales: 1.5 is 15 at scale 1 and 1.25 is 125 at scale
+//     2. They're written like numbers in scientific notation instead: a
+//     sign, then the exponent, then the digits (see encodeKeyDecimal).
+//
+// Every value starts with its type and a NULL marker, so NULLs sort
+// before every other value of their column, as in SQLite. The same ideas
+// are behind the "memcomparable" formats of CockroachDB and MyRocks.
+
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+const (
+	// keyNull and keyNotNull follow a key value's type: NULL sorts first.
+	keyNull    = 0x00
+	keyNotNull = 0x01
+)
+
+// encodeKey appends val to buf in an encoding whose byte order matches
+// the order of the values, for use in a B-tree key.
+func encodeKey(buf *bytes.Buffer, val Value) error {
+	buf.WriteByte(byte(val.Type))
+	if val.IsNull {
+		buf.WriteByte(keyNull)
+		return nil
+	}
+	buf.WriteByte(keyNotNull)
+
+	switch val.Type {
+	case parser.TypeInteger, parser.TypeTimestamp:
+		encodeKeyInt(buf, val.Integer)
+	case parser.TypeReal:
+		bits := math.Float64bits(val.Real)
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		binary.Write(buf, binary.BigEndian, bits)
+	case parser.TypeText, parser.TypeJSON:
+		for i := 0; i < len(val.Text); i++ {
+			buf.WriteByte(val.Text[i])
+			if val.Text[i] == 0x00 {
+				buf.WriteByte(0xFF)
+			}
+		}
+		buf.Write([]byte{0x00, 0x01})
+	case parser.TypeBoolean:
+		if val.Boolean {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case parser.TypeDecimal:
+		encodeKeyDecimal(buf, val.Integer, int(val.Scale))
+	default:
+		return fmt.Errorf("unsupported type for index key: %v", val.Type)
+	}
+	return nil
+}
+
+// encodeKeyInt appends n big-endian with its sign bit flipped, so that
+// negative numbers sort before positive ones.
+func encodeKeyInt(buf *bytes.Buffer, n int64) {
+	binary.Write(buf, binary.BigEndian, uint64(n)^(1<<63))
+}
+
+// encodeKeyDecimal appends the decimal unscaled * 10^-scale as a sign
+// byte, an exponent and its significant digits:
+//
+//   12.5   -> 02, exponent 2, "125", 00
+//   0.0125 -> 02, exponent -1, "125", 00
+//   0      -> 01
+//
+// The exponent, the number of digits before the decimal point, orders
+// numbers by magnitude; digits of equal magnitude then compare one by
+// one, and the 00 after them makes 1.2 sort before 1.25. A negative
+// number has its exponent and digits inverted, and ends in FF instead,
+// so that larger magnitudes sort first. Trailing zeros are dropped, so
+// 1.5 and 1.50 get the same key.
+func encodeKeyDecimal(buf *bytes.Buffer, unscaled int64, scale int) {
+	if unscaled == 0 {
+		buf.WriteByte(0x01)
+		return
+	}
+
+	negative := unscaled < 0
+	magnitude := uint64(unscaled)
+	if negative {
+		magnitude = uint64(-unscaled)
+	}
+	digits := strings.TrimRight(strconv.FormatUint(magnitude, 10), "0")
+	exponent := uint16(int16(len(strconv.FormatUint(magnitude, 10))-scale)) ^ (1 << 15)
+
+	if negative {
+		buf.WriteByte(0x00)
+		binary.Write(buf, binary.BigEndian, ^exponent)
+		for i := 0; i < len(digits); i++ {
+			buf.WriteByte(^digits[i])
+		}
+		buf.WriteByte(0xFF)
+		return
+	}
+	buf.WriteByte(0x02)
+	binary.Write(buf, binary.BigEndian, exponent)
+	buf.WriteString(digits)
+	buf.WriteByte(0x00)
+}
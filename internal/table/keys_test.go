@@ -0,0 +1,85 @@
+package table
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestEncodeKeyOrder(t *testing.T) {
+	integer := func(n int64) Value { return Value{Type: parser.TypeInteger, Integer: n} }
+	float := func(f float64) Value { return Value{Type: parser.TypeReal, Real: f} }
+	text := func(s string) Value { return Value{Type: parser.TypeText, Text: s} }
+	decimal := func(s string) Value {
+		v, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%s) failed: %v", s, err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name   string
+		values []Value
+	}{
+		{"integers", []Value{
+			{Type: parser.TypeInteger, IsNull: true},
+			integer(math.MinInt64), integer(-256), integer(-1), integer(0),
+			integer(1), integer(255), integer(256), integer(math.MaxInt64),
+		}},
+		{"reals", []Value{
+			float(math.Inf(-1)), float(-1000.5), float(-1), float(-0.001), float(0),
+			float(0.001), float(1), float(1.5), float(1000.5), float(math.Inf(1)),
+		}},
+		{"text", []Value{
+			text(""), text("\x00"), text("\x00a"), text("a"), text("ab"), text("abc"), text("b"),
+		}},
+		{"decimals", []Value{
+			decimal("-100"), decimal("-1.25"), decimal("-1.2"), decimal("-0.05"), decimal("0"),
+			decimal("0.0125"), decimal("0.05"), decimal("1.2"), decimal("1.25"), decimal("12.5"), decimal("100"),
+		}},
+	}
+
+	for _, tt := range tests {
+		var prev []byte
+		for i, val := range tt.values {
+			buf := bytes.NewBuffer(nil)
+			if err := encodeKey(buf, val); err != nil {
+				t.Fatalf("%s: encodeKey(%v) failed: %v", tt.name, val, err)
+			}
+			if i > 0 && bytes.Compare(prev, buf.Bytes()) >= 0 {
+				t.Errorf("%s: key of value %d does not sort after the one before it", tt.name, i)
+			}
+			prev = buf.Bytes()
+		}
+	}
+}
+
+func TestEncodeKeyComposite(t *testing.T) {
+	key := func(values ...string) []byte {
+		buf := bytes.NewBuffer(nil)
+		for _, v := range values {
+			encodeKey(buf, Value{Type: parser.TypeText, Text: v})
+		}
+		return buf.Bytes()
+	}
+
+	// Column boundaries can't be mistaken for each other
+	if bytes.Equal(key("ab", "c"), key("a", "bc")) {
+		t.Error("expected (ab, c) and (a, bc) to have different keys")
+	}
+	// The first column decides before the second is looked at
+	if bytes.Compare(key("a", "z"), key("ab", "a")) >= 0 {
+		t.Error("expected (a, z) to sort before (ab, a)")
+	}
+
+	// Decimals equal in value get equal keys whatever their scale
+	a, b := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	encodeKey(a, Value{Type: parser.TypeDecimal, Integer: 15, Scale: 1})
+	encodeKey(b, Value{Type: parser.TypeDecimal, Integer: 150, Scale: 2})
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("expected 1.5 and 1.50 to have the same key")
+	}
+}
@@ -0,0 +1,207 @@
+// Package table - Parallel scans
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A scan spends most of its time decoding rows once their pages are in
+// the cache, and each page decodes without looking at any other. So a
+// large scan can hand its pages to a pool of workers, one goroutine per
+// CPU, and put their rows back together in page order at the end:
+//
+//   pages:    0   1   2   3   4   5   6   7
+//   worker A: 0       2           5
+//   worker B:     1       3   4       6   7
+//   result:   rows of 0, 1, 2, ... 7
+//
+// Workers take the next page from a shared channel as they finish one, so
+// a worker given a page full of long rows doesn't hold up the others.
+// The pager serializes the page reads themselves behind its lock, so the
+// speedup comes from decoding; PostgreSQL's parallel sequential scan
+// divides the table's blocks among its workers the same way.
+//
+// Small tables aren't worth starting goroutines for, so Scan only goes
+// parallel past parallelScanMinPages pages.
+//
+// Scan holds every row at the end, which suits a join's input but not a
+// SELECT streaming its rows, which may stop at a LIMIT. A scan iterator
+// set Parallel reads ahead instead: the workers decode the next few pages
+// while the caller reads the rows of the current one, and Next still
+// returns them in page order. At most twice as many pages as workers are
+// decoded ahead, so memory stays bounded, and Close stops the workers
+// wherever they are.
+
+package table
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallelScanMinPages is the size, in data pages, from which Scan reads
+// a table with a pool of workers.
+const parallelScanMinPages = 16
+
+// ParallelScan returns all rows in the table, in the same order as Scan,
+// reading and decoding its pages with up to workers goroutines. workers <=
+// 0 means one per CPU (GOMAXPROCS); there are never more than there are
+// CPUs or pages.
+func (t *Table) ParallelScan(workers int) ([]Row, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.parallelScanLocked(workers)
+}
+
+// Parallel lets a scan iterator read and decode its data pages with up to
+// workers goroutines, ahead of Next, which still returns the rows in
+// order. workers <= 0 means one per CPU (GOMAXPROCS). An iterator over a
+// key range, a scan of fewer than parallelScanMinPages pages, or one CPU
+// stays sequential; Parallel reports whether the scan will go parallel.
+func (it *RowIterator) Parallel(workers int) bool {
+	if it.keys != nil || len(it.pageIDs) < parallelScanMinPages {
+		return false
+	}
+	if workers <= 0 || workers > runtime.GOMAXPROCS(0) {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers < 2 {
+		return false
+	}
+	it.workers = workers
+	return true
+}
+
+// readAhead is the pool of workers decoding the pages of a parallel scan
+// iterator ahead of it.
+type readAhead struct {
+	pages chan chan pageRows // Each page's rows, to come, in page order
+	done  chan struct{}      // Closed to stop the workers
+	wg    sync.WaitGroup
+}
+
+// pageRows is what a worker makes of one page.
+type pageRows struct {
+	rows    []Row
+	skipped bool // By the scan's ranges (see SkipPages)
+	err     error
+}
+
+// startReadAhead hands the iterator's remaining pages to its workers.
+func (it *RowIterator) startReadAhead() {
+	pageIDs, ranges, columns := it.pageIDs, it.ranges, it.columns
+	it.pageIDs = nil
+	r := &readAhead{
+		pages: make(chan chan pageRows, 2*it.workers),
+		done:  make(chan struct{}),
+	}
+	type job struct {
+		pageID uint32
+		result chan pageRows
+	}
+	jobs := make(chan job)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(jobs)
+		defer close(r.pages)
+		for _, pageID := range pageIDs {
+			// The room in pages is how far ahead the workers may get
+			result := make(chan pageRows, 1)
+			select {
+			case r.pages <- result:
+			case <-r.done:
+				return
+			}
+			select {
+			case jobs <- job{pageID, result}:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	for w := 0; w < it.workers; w++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for j := range jobs {
+				j.result <- it.t.scanPage(j.pageID, ranges, columns)
+			}
+		}()
+	}
+	it.ahead = r
+}
+
+// stop stops the workers and waits for them to finish.
+func (r *readAhead) stop() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+// parallelScanLocked implements ParallelScan. Caller must hold the read
+// lock, which keeps the pages from changing while the workers read them.
+func (t *Table) parallelScanLocked(workers int) ([]Row, error) {
+	pageIDs := t.dataPageIDs
+	if workers <= 0 || workers > runtime.GOMAXPROCS(0) {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(pageIDs))
+
+	// Each worker puts the rows of page i in pageRows[i]
+	pageRows := make([][]Row, len(pageIDs))
+	next := make(chan int)
+	errs := make(chan error, workers) // room for every worker to fail
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				page, err := t.pager.GetPage(pageIDs[i])
+				if err == nil {
+					pageRows[i], err = t.readRowsFromPage(page)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	// Hand out the pages until they're all taken or a worker fails
+	var err error
+feed:
+	for i := range pageIDs {
+		select {
+		case next <- i:
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+
+	var count int
+	for _, r := range pageRows {
+		count += len(r)
+	}
+	// A columnar table's packed rows come first
+	packed, err := t.packedRows()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(packed)+count)
+	rows = append(rows, packed...)
+	for _, r := range pageRows {
+		rows = append(rows, r...)
+	}
+	return rows, nil
+}
@@ -0,0 +1,116 @@
+package table
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestParallelScan(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	const n = 2000
+	for i := 0; i < n; i++ {
+		values := []Value{
+			{Type: parser.TypeInteger, Integer: int64(i)},
+			{Type: parser.TypeText, Text: fmt.Sprintf("row %d, long enough to take up some room", i)},
+			{Type: parser.TypeInteger, Integer: int64(i % 50)},
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if pages := len(tbl.GetDataPageIDs()); pages < parallelScanMinPages {
+		t.Fatalf("expected at least %d data pages, got %d", parallelScanMinPages, pages)
+	}
+
+	// Rows come back in storage order, whatever the number of workers
+	var want []Row
+	it := tbl.NewScanIterator()
+	for it.Next() {
+		want = append(want, it.Row())
+	}
+	it.Close()
+
+	for _, workers := range []int{0, 1, 3, 64} {
+		rows, err := tbl.ParallelScan(workers)
+		if err != nil {
+			t.Fatalf("ParallelScan(%d) failed: %v", workers, err)
+		}
+		if len(rows) != n {
+			t.Fatalf("ParallelScan(%d): expected %d rows, got %d", workers, n, len(rows))
+		}
+		for i := range rows {
+			if rows[i].ID != want[i].ID || rows[i].Values[1].Text != want[i].Values[1].Text {
+				t.Fatalf("ParallelScan(%d): row %d is %v, expected %v", workers, i, rows[i].Values, want[i].Values)
+			}
+		}
+	}
+}
+
+func TestParallelScanIterator(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	// A small scan isn't worth the workers
+	if tbl.NewScanIterator().Parallel(0) {
+		t.Error("expected a small scan to stay sequential")
+	}
+
+	const n = 2000
+	for i := 0; i < n; i++ {
+		values := []Value{
+			{Type: parser.TypeInteger, Integer: int64(i)},
+			{Type: parser.TypeText, Text: fmt.Sprintf("row %d, long enough to take up some room", i)},
+			{Type: parser.TypeInteger, Integer: int64(i % 50)},
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Rows come back in storage order, as from a sequential scan
+	want, err := tbl.ScanWithFilter(func(Row) bool { return true }, 0)
+	if err != nil {
+		t.Fatalf("ScanWithFilter failed: %v", err)
+	}
+	it := tbl.NewScanIterator()
+	if !it.Parallel(3) {
+		t.Fatal("expected a large scan to go parallel")
+	}
+	var i int
+	for ; it.Next(); i++ {
+		if it.Row().ID != want[i].ID {
+			t.Fatalf("row %d is %d, expected %d", i, it.Row().ID, want[i].ID)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("parallel scan failed: %v", err)
+	}
+	if i != n {
+		t.Errorf("expected %d rows, got %d", n, i)
+	}
+	it.Close()
+
+	// Closing part way stops the workers
+	it = tbl.NewScanIterator()
+	it.Parallel(0)
+	for i := 0; i < 10 && it.Next(); i++ {
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if it.Next() {
+		t.Error("expected no rows after Close")
+	}
+
+	// Nor is one CPU
+	runtime.GOMAXPROCS(1)
+	if tbl.NewScanIterator().Parallel(0) {
+		t.Error("expected a scan on one CPU to stay sequential")
+	}
+}
@@ -0,0 +1,240 @@
+// Package table - Row format versions
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// A row stores its values one after the other, in the order of the
+// table's columns, without their names. That order is the only thing
+// telling which value belongs to which column, so changing the columns
+// seems to mean rewriting every row: a dropped column's value is still in
+// them, and an added column's isn't. For a big table that is a long,
+// locked, write-everything operation.
+//
+// Instead, the schema keeps a version, bumped by every ALTER TABLE, and
+// remembers the "layout" of each version: which columns a row of that
+// version holds, in order. Columns are known by an ID that is never
+// reused, rather than their position, which changes:
+//
+//   CREATE TABLE t (a, b, c)       version 0: [0 1 2]
+//   ALTER TABLE t DROP COLUMN b    version 1: [0 2]
+//   ALTER TABLE t ADD COLUMN d     version 2: [0 2 3]
+//
+// A row written after an ALTER carries the version it was written with,
+// and decoding maps its values through that version's layout: a value
+// whose column has been dropped is skipped, and a column added since
+// reads as NULL. Rows are brought up to date one at a time, whenever an
+// UPDATE writes them again, and all of them by VACUUM. So ALTER TABLE only
+// changes the catalog, however big the table: the same trick as
+// PostgreSQL's "fast" ADD COLUMN (its dropped columns also stay in the
+// rows, marked attisdropped) and MySQL's INSTANT ALTER.
+//
+// Rows of version 0, which is all of them until the table is first
+// altered, are stored without a version, so tables that are never altered
+// keep the original format. A row with a version has the high bit of its
+// value count set, followed by the version:
+//
+//   [row ID: uint64][count | 0x8000: uint16][version: uint16][values...]
+
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+// rowVersionFlag marks, in a row's value count, a row that carries its
+// schema version.
+const rowVersionFlag = 0x8000
+
+// Version returns the schema's version: 0 until the table is first
+// altered, then one more for each change.
+func (s *Schema) Version() int {
+	if len(s.Layouts) == 0 {
+		return 0
+	}
+	return len(s.Layouts) - 1
+}
+
+// layouts returns the layout of every version, making up the layout of
+// version 0 for a table never altered.
+func (s *Schema) layouts() [][]int {
+	if len(s.Layouts) > 0 {
+		return s.Layouts
+	}
+	ids := make([]int, len(s.Columns))
+	for i := range ids {
+		ids[i] = i
+	}
+	return [][]int{ids}
+}
+
+// SetLayouts restores the layouts saved from Layouts when the table was
+// last written. The last is the current version's, with an ID for each
+// column.
+func (s *Schema) SetLayouts(layouts [][]int) error {
+	if len(layouts) == 0 {
+		s.Layouts, s.positions = nil, nil
+		return nil
+	}
+	if current := layouts[len(layouts)-1]; len(current) != len(s.Columns) {
+		return fmt.Errorf("schema version %d has %d columns, expected %d", len(layouts)-1, len(current), len(s.Columns))
+	}
+	s.Layouts = layouts
+	s.positions = make([][]int, len(layouts))
+	columnOf := make(map[int]int, len(s.Columns))
+	for i, id := range layouts[len(layouts)-1] {
+		columnOf[id] = i
+	}
+	for v, layout := range layouts {
+		s.positions[v] = make([]int, len(layout))
+		for p, id := range layout {
+			if col, ok := columnOf[id]; ok {
+				s.positions[v][p] = col
+			} else {
+				s.positions[v][p] = -1
+			}
+		}
+	}
+	return nil
+}
+
+// withLayout returns a copy of the schema with the given columns and a new
+// version whose layout is ids.
+func (s *Schema) withLayout(columns []Column, ids []int) *Schema {
+	altered := &Schema{
+		Columns:      columns,
+		PrimaryKey:   -1,
+		ColumnLookup: make(map[string]int, len(columns)),
+	}
+	for i, col := range columns {
+		altered.ColumnLookup[col.Name] = i
+	}
+	for _, name := range s.PrimaryKeyNames() {
+		altered.PrimaryKeyColumns = append(altered.PrimaryKeyColumns, altered.ColumnLookup[name])
+	}
+	if len(altered.PrimaryKeyColumns) == 1 {
+		altered.PrimaryKey = altered.PrimaryKeyColumns[0]
+	}
+	layouts := slices.Clip(s.layouts())
+	altered.SetLayouts(append(layouts, ids))
+	return altered
+}
+
+// AddColumn returns a copy of the schema with a column added after the
+// others, as a new version. Existing rows read NULL in it, so it can't be
+// NOT NULL or part of the primary key.
+func (s *Schema) AddColumn(def parser.ColumnDefinition) (*Schema, error) {
+	if _, exists := s.ColumnLookup[def.Name]; exists {
+		return nil, fmt.Errorf("column %s already exists", def.Name)
+	}
+	if def.PrimaryKey {
+		return nil, fmt.Errorf("cannot add primary key column %s", def.Name)
+	}
+	if def.NotNull {
+		return nil, fmt.Errorf("cannot add NOT NULL column %s: existing rows have no value for it", def.Name)
+	}
+	if def.Default != nil {
+		return nil, fmt.Errorf("cannot add column %s with a DEFAULT: existing rows would read NULL in it", def.Name)
+	}
+
+	layouts := s.layouts()
+	nextID := 0
+	for _, layout := range layouts {
+		for _, id := range layout {
+			nextID = max(nextID, id+1)
+		}
+	}
+	columns := append(slices.Clone(s.Columns), NewSchema([]parser.ColumnDefinition{def}).Columns[0])
+	ids := append(slices.Clone(layouts[len(layouts)-1]), nextID)
+	return s.withLayout(columns, ids), nil
+}
+
+// DropColumn returns a copy of the schema without the named column, as a
+// new version. The column's values stay in the rows already written, and
+// are skipped when they are read.
+func (s *Schema) DropColumn(name string) (*Schema, error) {
+	idx, ok := s.ColumnLookup[name]
+	if !ok {
+		return nil, fmt.Errorf("column %s does not exist", name)
+	}
+	if s.Columns[idx].PrimaryKey {
+		return nil, fmt.Errorf("cannot drop primary key column %s", name)
+	}
+	if len(s.Columns) == 1 {
+		return nil, fmt.Errorf("cannot drop column %s, the only column of the table", name)
+	}
+
+	layouts := s.layouts()
+	columns := slices.Delete(slices.Clone(s.Columns), idx, idx+1)
+	ids := slices.Delete(slices.Clone(layouts[len(layouts)-1]), idx, idx+1)
+	return s.withLayout(columns, ids), nil
+}
+
+// readRowHeader reads a row's ID and value count, and its schema version
+// if it carries one (0 if not).
+func readRowHeader(buf *bytes.Reader) (rowID uint64, count int, version int, err error) {
+	if err := binary.Read(buf, binary.LittleEndian, &rowID); err != nil {
+		return 0, 0, 0, err
+	}
+	var numValues uint16
+	if err := binary.Read(buf, binary.LittleEndian, &numValues); err != nil {
+		return 0, 0, 0, err
+	}
+	if numValues&rowVersionFlag != 0 {
+		var v uint16
+		if err := binary.Read(buf, binary.LittleEndian, &v); err != nil {
+			return 0, 0, 0, err
+		}
+		version = int(v)
+	}
+	return rowID, int(numValues &^ rowVersionFlag), version, nil
+}
+
+// rowPositions returns the column each value of a row of the given version
+// belongs to, -1 for a column dropped since. It returns nil if the values
+// are those of the current columns, in order.
+func (t *Table) rowPositions(version int) ([]int, error) {
+	if t == nil || version == t.Schema.Version() {
+		return nil, nil
+	}
+	if version >= len(t.Schema.positions) {
+		return nil, fmt.Errorf("row has schema version %d, table %s is at version %d", version, t.Name, t.Schema.Version())
+	}
+	return t.Schema.positions[version], nil
+}
+
+// Altered returns a table with the same storage and a new schema, made
+// from this table's by AddColumn or DropColumn. The table itself keeps the
+// old schema, so a transaction rolling back the change can go back to it.
+func (t *Table) Altered(schema *Schema) (*Table, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, idx := range t.indexes {
+		for _, col := range idx.Columns {
+			if _, ok := schema.ColumnLookup[col]; !ok {
+				return nil, fmt.Errorf("cannot drop column %s: index %s uses it", col, idx.Name)
+			}
+		}
+	}
+
+	altered := LoadTable(t.Name, schema, t.pager, t.btree.RootPage(), t.nextRowID,
+		append([]uint32(nil), t.dataPageIDs...))
+	for name, idx := range t.indexes {
+		altered.indexes[name] = idx
+	}
+	altered.indexStats = t.indexStats
+	altered.stats = t.stats
+	if t.stats.Columns != nil {
+		altered.stats.Columns = make(map[string]ColumnStats, len(t.stats.Columns))
+		for name, stats := range t.stats.Columns {
+			if _, ok := schema.ColumnLookup[name]; ok {
+				altered.stats.Columns[name] = stats
+			}
+		}
+	}
+	return altered, nil
+}
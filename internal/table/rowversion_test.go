@@ -0,0 +1,84 @@
+package table
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestSchemaVersions(t *testing.T) {
+	schema := NewSchema([]parser.ColumnDefinition{
+		{Name: "a", Type: parser.TypeInteger, PrimaryKey: true},
+		{Name: "b", Type: parser.TypeText},
+		{Name: "c", Type: parser.TypeText},
+	})
+	if schema.Version() != 0 || schema.Layouts != nil {
+		t.Fatalf("expected a new schema at version 0 without layouts, got %v", schema.Layouts)
+	}
+
+	v1, err := schema.DropColumn("b")
+	if err != nil {
+		t.Fatalf("DropColumn: %v", err)
+	}
+	v2, err := v1.AddColumn(parser.ColumnDefinition{Name: "b", Type: parser.TypeReal})
+	if err != nil {
+		t.Fatalf("AddColumn: %v", err)
+	}
+
+	// The new b gets a new ID, so the old b's values are never read as it
+	want := [][]int{{0, 1, 2}, {0, 2}, {0, 2, 3}}
+	if v2.Version() != 2 || !slices.EqualFunc(v2.Layouts, want, slices.Equal) {
+		t.Errorf("expected layouts %v, got %v", want, v2.Layouts)
+	}
+	if got := v2.positions[0]; !slices.Equal(got, []int{0, -1, 1}) {
+		t.Errorf("expected version 0 values to go to columns [0 -1 1], got %v", got)
+	}
+	if schema.Version() != 0 || len(schema.Columns) != 3 {
+		t.Error("expected the original schema to be left alone")
+	}
+
+	if err := v2.SetLayouts([][]int{{0, 1}}); err == nil {
+		t.Error("expected an error for a layout that doesn't match the columns")
+	}
+}
+
+func TestAlteredTableReadsOldRows(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	values := []Value{
+		{Type: parser.TypeInteger, Integer: 1},
+		{Type: parser.TypeText, Text: "Alice"},
+		{Type: parser.TypeInteger, Integer: 30},
+	}
+	if _, err := tbl.Insert(values); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	schema, err := tbl.Schema.AddColumn(parser.ColumnDefinition{Name: "email", Type: parser.TypeText})
+	if err != nil {
+		t.Fatalf("AddColumn: %v", err)
+	}
+	altered, err := tbl.Altered(schema)
+	if err != nil {
+		t.Fatalf("Altered: %v", err)
+	}
+	values = []Value{
+		{Type: parser.TypeInteger, Integer: 2},
+		{Type: parser.TypeText, Text: "Bob"},
+		{Type: parser.TypeInteger, Integer: 25},
+		{Type: parser.TypeText, Text: "bob@example.com"},
+	}
+	if _, err := altered.Insert(values); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	rows, err := altered.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(rows) != 2 || len(rows[0].Values) != 4 || !rows[0].Values[3].IsNull || rows[1].Values[3].Text != "bob@example.com" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
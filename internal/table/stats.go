@@ -0,0 +1,262 @@
+// Package table - Column statistics
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// ANALYZE reads every row and summarizes each column, so that the planner
+// can estimate how many rows a condition matches without reading them:
+//
+//   - The number of distinct values (NDV): "col = v" matches about
+//     1/NDV of the rows.
+//
+//   - The minimum and maximum.
+//
+//   - An equi-depth histogram: the sorted values cut into buckets holding
+//     the same number of rows each, keeping only the values at the cuts.
+//     With 4 buckets over 1, 2, 2, 3, 5, 8, 13, 21 the bounds are
+//     1, 2, 5, 13, 21: a quarter of the rows lies between 1 and 2, the
+//     next quarter between 2 and 5, and so on.
+//
+// An equi-width histogram (buckets of equal ranges of values) would spend
+// most of its buckets on empty ranges of skewed data; equi-depth buckets
+// get narrow where the values are dense, exactly where precision matters.
+// A value common enough to fill a whole bucket shows up as the same bound
+// twice, which lets the planner see that "col = v" matches more than
+// 1/NDV of the rows. PostgreSQL keeps such values in a separate list of
+// most common values instead.
+
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// histogramBuckets is how many buckets ANALYZE divides each column's
+// values into. PostgreSQL's default_statistics_target is 100.
+const histogramBuckets = 32
+
+// ColumnStats holds statistics about the values of one column.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// These answer the planner's question "how many rows match?" for a
+// condition on the column: with d distinct values, "col = v" matches about
+// 1/d of the non-NULL rows, and the histogram tells what fraction of them
+// "col > v" covers. PostgreSQL keeps the same numbers in pg_statistic.
+type ColumnStats struct {
+	DistinctValues int64 // Number of distinct non-NULL values
+	NullCount      int64 // Number of NULLs
+	Min            Value // Smallest non-NULL value (NULL if there is none)
+	Max            Value // Largest non-NULL value (NULL if there is none)
+
+	// Histogram holds the bounds of equi-depth buckets: the smallest
+	// value, then the value that ends each bucket, each bucket holding
+	// about the same number of rows. It's empty if all values are NULL.
+	Histogram []Value
+}
+
+// columnStatsBuilder gathers ColumnStats for every column of a schema from
+// the rows passed to add. It keeps every value to sort them for the
+// histograms; a database with big tables would sample the rows instead.
+type columnStatsBuilder struct {
+	schema   *Schema
+	columns  []ColumnStats
+	distinct []map[string]struct{}
+	values   [][]Value
+}
+
+func newColumnStatsBuilder(schema *Schema) *columnStatsBuilder {
+	b := &columnStatsBuilder{
+		schema:   schema,
+		columns:  make([]ColumnStats, len(schema.Columns)),
+		distinct: make([]map[string]struct{}, len(schema.Columns)),
+		values:   make([][]Value, len(schema.Columns)),
+	}
+	for i := range b.columns {
+		b.columns[i].Min = Value{Type: schema.Columns[i].Type, IsNull: true}
+		b.columns[i].Max = Value{Type: schema.Columns[i].Type, IsNull: true}
+		b.distinct[i] = make(map[string]struct{})
+	}
+	return b
+}
+
+// add counts one row's values.
+func (b *columnStatsBuilder) add(row Row) {
+	for i, val := range row.Values {
+		if i >= len(b.columns) {
+			break
+		}
+		col := &b.columns[i]
+		if val.IsNull {
+			col.NullCount++
+			continue
+		}
+		// The key encoding is the same for equal values
+		buf := bytes.NewBuffer(nil)
+		if encodeKey(buf, val) == nil {
+			b.distinct[i][buf.String()] = struct{}{}
+		}
+		if col.Min.IsNull || val.Compare(col.Min) < 0 {
+			col.Min = val
+		}
+		if col.Max.IsNull || val.Compare(col.Max) > 0 {
+			col.Max = val
+		}
+		b.values[i] = append(b.values[i], val)
+	}
+}
+
+// stats returns the statistics gathered, by column name.
+func (b *columnStatsBuilder) stats() map[string]ColumnStats {
+	stats := make(map[string]ColumnStats, len(b.columns))
+	for i, col := range b.columns {
+		col.DistinctValues = int64(len(b.distinct[i]))
+		col.Histogram = buildHistogram(b.values[i], histogramBuckets)
+		stats[b.schema.Columns[i].Name] = col
+	}
+	return stats
+}
+
+// buildHistogram sorts values and returns the bounds of at most buckets
+// equi-depth buckets over them.
+func buildHistogram(values []Value, buckets int) []Value {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Compare(values[j]) < 0
+	})
+
+	n := min(buckets, len(values))
+	bounds := make([]Value, n+1)
+	for i := range bounds {
+		bounds[i] = values[min(i*len(values)/n, len(values)-1)]
+	}
+	return bounds
+}
+
+// SetStats replaces the table's statistics, as when they're loaded from
+// the catalog.
+func (t *Table) SetStats(stats TableStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats = stats
+}
+
+// SetCounts restores the row count and bytes stored saved with the table,
+// leaving the rest of its statistics alone.
+func (t *Table) SetCounts(rowCount, bytesStored int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.RowCount, t.stats.BytesStored = rowCount, bytesStored
+}
+
+// Recount counts the table's rows and their bytes by reading them all,
+// for a table whose counts weren't saved.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Inserts, updates and deletes keep RowCount and BytesStored up to date,
+// so COUNT(*)-like questions ("how big is this table?") are answered
+// without a scan, as by MySQL's information_schema.tables or PostgreSQL's
+// pg_class.reltuples. The counts are only as good as their starting point,
+// though: a database written before they were saved has to be read once
+// to get it.
+func (t *Table) Recount() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rowCount, bytesStored := t.packedRowCount(), t.packedBytes()
+	for _, pageID := range t.dataPageIDs {
+		page, err := t.pager.GetPage(pageID)
+		if err != nil {
+			return err
+		}
+		rows, err := t.readRowsFromPage(page)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			rowCount++
+			bytesStored += int64(row.size)
+		}
+	}
+	t.stats.RowCount, t.stats.BytesStored = rowCount, bytesStored
+	return nil
+}
+
+// EncodeColumnStats serializes per-column statistics for storage.
+//
+// Each column is stored as its name, its distinct and NULL counts, its
+// minimum and maximum, and its histogram bounds, with values in the row
+// format.
+func (t *Table) EncodeColumnStats(columns map[string]ColumnStats) ([]byte, error) {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, binary.LittleEndian, uint16(len(names)))
+	for _, name := range names {
+		col := columns[name]
+		binary.Write(buf, binary.LittleEndian, uint16(len(name)))
+		buf.WriteString(name)
+		binary.Write(buf, binary.LittleEndian, col.DistinctValues)
+		binary.Write(buf, binary.LittleEndian, col.NullCount)
+		binary.Write(buf, binary.LittleEndian, uint16(len(col.Histogram)))
+		for _, val := range append([]Value{col.Min, col.Max}, col.Histogram...) {
+			if err := t.serializeValue(buf, val); err != nil {
+				return nil, fmt.Errorf("column %s: %w", name, err)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeColumnStats reads per-column statistics written by
+// EncodeColumnStats.
+func (t *Table) DecodeColumnStats(data []byte) (map[string]ColumnStats, error) {
+	buf := bytes.NewReader(data)
+
+	var numColumns uint16
+	if err := binary.Read(buf, binary.LittleEndian, &numColumns); err != nil {
+		return nil, fmt.Errorf("failed to read column count: %w", err)
+	}
+	columns := make(map[string]ColumnStats, numColumns)
+	for i := uint16(0); i < numColumns; i++ {
+		var nameLen uint16
+		if err := binary.Read(buf, binary.LittleEndian, &nameLen); err != nil {
+			return nil, err
+		}
+		name := make([]byte, nameLen)
+		if _, err := buf.Read(name); err != nil {
+			return nil, err
+		}
+
+		var col ColumnStats
+		var numBounds uint16
+		for _, field := range []any{&col.DistinctValues, &col.NullCount, &numBounds} {
+			if err := binary.Read(buf, binary.LittleEndian, field); err != nil {
+				return nil, fmt.Errorf("column %s: %w", name, err)
+			}
+		}
+		values := make([]Value, 2+int(numBounds))
+		for j := range values {
+			val, err := t.deserializeValue(buf)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", name, err)
+			}
+			values[j] = val
+		}
+		col.Min, col.Max = values[0], values[1]
+		if numBounds > 0 {
+			col.Histogram = values[2:]
+		}
+		columns[string(name)] = col
+	}
+	return columns, nil
+}
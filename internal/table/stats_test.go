@@ -0,0 +1,134 @@
+package table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestBuildHistogram(t *testing.T) {
+	var values []Value
+	for _, n := range []int64{21, 2, 13, 1, 5, 3, 8, 2} {
+		values = append(values, Value{Type: parser.TypeInteger, Integer: n})
+	}
+
+	bounds := buildHistogram(values, 4)
+	want := []int64{1, 2, 5, 13, 21}
+	if len(bounds) != len(want) {
+		t.Fatalf("expected %d bounds, got %d", len(want), len(bounds))
+	}
+	for i, n := range want {
+		if bounds[i].Integer != n {
+			t.Errorf("bound %d: expected %d, got %d", i, n, bounds[i].Integer)
+		}
+	}
+
+	// Fewer values than buckets: one bucket per value
+	if got := buildHistogram(values[:2], 4); len(got) != 3 {
+		t.Errorf("expected 3 bounds for 2 values, got %d", len(got))
+	}
+	if got := buildHistogram(nil, 4); got != nil {
+		t.Errorf("expected no histogram without values, got %v", got)
+	}
+}
+
+func TestColumnStatsEncoding(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	for i := 1; i <= 100; i++ {
+		name := Value{Type: parser.TypeText, Text: "User"}
+		if i%10 == 0 {
+			name = Value{Type: parser.TypeText, IsNull: true}
+		}
+		values := []Value{
+			{Type: parser.TypeInteger, Integer: int64(i)},
+			name,
+			{Type: parser.TypeInteger, Integer: int64(i % 7)},
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := tbl.Analyze(); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	columns := tbl.Stats().Columns
+	if n := len(columns["id"].Histogram); n != histogramBuckets+1 {
+		t.Errorf("expected %d bounds for id, got %d", histogramBuckets+1, n)
+	}
+
+	data, err := tbl.EncodeColumnStats(columns)
+	if err != nil {
+		t.Fatalf("EncodeColumnStats failed: %v", err)
+	}
+	decoded, err := tbl.DecodeColumnStats(data)
+	if err != nil {
+		t.Fatalf("DecodeColumnStats failed: %v", err)
+	}
+
+	if len(decoded) != len(columns) {
+		t.Fatalf("expected %d columns, got %d", len(columns), len(decoded))
+	}
+	for name, want := range columns {
+		got := decoded[name]
+		if got.DistinctValues != want.DistinctValues || got.NullCount != want.NullCount ||
+			!got.Min.Equals(want.Min) || !got.Max.Equals(want.Max) {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got)
+		}
+		if len(got.Histogram) != len(want.Histogram) {
+			t.Errorf("%s: expected %d bounds, got %d", name, len(want.Histogram), len(got.Histogram))
+			continue
+		}
+		for i := range want.Histogram {
+			if !got.Histogram[i].Equals(want.Histogram[i]) {
+				t.Errorf("%s: bound %d: expected %v, got %v", name, i, want.Histogram[i], got.Histogram[i])
+			}
+		}
+	}
+	if name := decoded["name"]; name.NullCount != 10 || name.DistinctValues != 1 {
+		t.Errorf("unexpected stats for name: %+v", name)
+	}
+}
+
+func TestIncrementalCounts(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	for i, name := range []string{"Alice", "Bob", "Carol"} {
+		tbl.Insert([]Value{
+			{Type: parser.TypeInteger, Integer: int64(i + 1)},
+			{Type: parser.TypeText, Text: name},
+			{Type: parser.TypeInteger, Integer: 30},
+		})
+	}
+	rows, err := tbl.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	// Grow one row enough to move it, and delete another
+	grown := append([]Value(nil), rows[0].Values...)
+	grown[1] = Value{Type: parser.TypeText, Text: strings.Repeat("x", 500)}
+	if err := tbl.UpdateRow(rows[0], grown); err != nil {
+		t.Fatalf("UpdateRow failed: %v", err)
+	}
+	if err := tbl.DeleteRow(rows[1]); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+
+	got := tbl.Stats()
+	if got.RowCount != 2 || got.BytesStored <= 500 {
+		t.Errorf("expected 2 rows of over 500 bytes, got %d rows of %d bytes", got.RowCount, got.BytesStored)
+	}
+
+	// Counting them all again agrees
+	if err := tbl.Recount(); err != nil {
+		t.Fatalf("Recount failed: %v", err)
+	}
+	if want := tbl.Stats(); want.RowCount != got.RowCount || want.BytesStored != got.BytesStored {
+		t.Errorf("kept %d rows of %d bytes, counted %d rows of %d bytes",
+			got.RowCount, got.BytesStored, want.RowCount, want.BytesStored)
+	}
+}
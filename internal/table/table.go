@@ -18,8 +18,14 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
 	"github.com/cabewaldrop/claude-db/internal/storage"
@@ -33,6 +39,7 @@ type Value struct {
 	Real    float64
 	Text    string
 	Boolean bool
+	Scale   uint8 // Digits after the decimal point (DECIMAL only; see decimal.go)
 }
 
 // String returns a string representation of the value.
@@ -45,13 +52,17 @@ func (v Value) String() string {
 		return fmt.Sprintf("%d", v.Integer)
 	case parser.TypeReal:
 		return fmt.Sprintf("%g", v.Real)
-	case parser.TypeText:
+	case parser.TypeText, parser.TypeJSON:
 		return v.Text
 	case parser.TypeBoolean:
 		if v.Boolean {
 			return "TRUE"
 		}
 		return "FALSE"
+	case parser.TypeTimestamp:
+		return FormatTimestamp(v.Integer)
+	case parser.TypeDecimal:
+		return decimalString(v)
 	default:
 		return "?"
 	}
@@ -70,8 +81,15 @@ func (v Value) Compare(other Value) int {
 		return 1
 	}
 
+	// DECIMAL compares numerically with other DECIMALs and INTEGERs
+	if isExactNumeric(v, other) {
+		a, _ := ToDecimal(v)
+		b, _ := ToDecimal(other)
+		return decimalCompare(a, b)
+	}
+
 	switch v.Type {
-	case parser.TypeInteger:
+	case parser.TypeInteger, parser.TypeTimestamp:
 		if v.Integer < other.Integer {
 			return -1
 		} else if v.Integer > other.Integer {
@@ -85,7 +103,7 @@ func (v Value) Compare(other Value) int {
 			return 1
 		}
 		return 0
-	case parser.TypeText:
+	case parser.TypeText, parser.TypeJSON:
 		if v.Text < other.Text {
 			return -1
 		} else if v.Text > other.Text {
@@ -112,11 +130,18 @@ func (v Value) Equals(other Value) bool {
 	if v.IsNull || other.IsNull {
 		return false
 	}
+	if isExactNumeric(v, other) {
+		return v.Compare(other) == 0
+	}
+	if isTextual(v.Type) && isTextual(other.Type) {
+		// A JSON document equals the same text
+		return v.Text == other.Text
+	}
 	if v.Type != other.Type {
 		return false
 	}
 	switch v.Type {
-	case parser.TypeInteger:
+	case parser.TypeInteger, parser.TypeTimestamp:
 		return v.Integer == other.Integer
 	case parser.TypeReal:
 		return v.Real == other.Real
@@ -129,10 +154,36 @@ func (v Value) Equals(other Value) bool {
 	}
 }
 
+// isExactNumeric reports whether a comparison involves a DECIMAL and
+// only exact numeric types (DECIMAL or INTEGER), so 1.50 = 1.5 = ... and
+// 2.00 = 2 all hold.
+func isExactNumeric(a, b Value) bool {
+	if a.Type != parser.TypeDecimal && b.Type != parser.TypeDecimal {
+		return false
+	}
+	exact := func(t parser.DataType) bool {
+		return t == parser.TypeDecimal || t == parser.TypeInteger
+	}
+	return exact(a.Type) && exact(b.Type)
+}
+
+// isTextual reports whether a type stores its value in Value.Text.
+func isTextual(t parser.DataType) bool {
+	return t == parser.TypeText || t == parser.TypeJSON
+}
+
 // Row represents a single row in a table.
 type Row struct {
 	ID     uint64
 	Values []Value
+
+	// location is where the row is stored, set when it is read from a
+	// page; DeleteRow uses it to find the row again.
+	location uint64
+
+	// size is the length of the serialized row read from storage, which
+	// UpdateRow and DeleteRow take off TableStats.BytesStored
+	size int
 }
 
 // Column represents a column definition.
@@ -141,13 +192,32 @@ type Column struct {
 	Type       parser.DataType
 	PrimaryKey bool
 	NotNull    bool
+	Precision  int // DECIMAL(precision, scale); 0 if unspecified
+	Scale      int
+	Length     int               // VARCHAR(length), in characters; 0 if unlimited
+	Collation  Collation         // How TEXT values compare (see collation.go)
+	Default    parser.Expression // Value of a column an INSERT leaves out; nil for NULL
 }
 
 // Schema defines the structure of a table.
 type Schema struct {
 	Columns      []Column
-	PrimaryKey   int // Index of primary key column (-1 if none)
+	PrimaryKey   int // Index of primary key column (-1 if none, or if several)
 	ColumnLookup map[string]int
+
+	// PrimaryKeyColumns holds the indexes of the primary key's columns,
+	// in key order: one for a key declared on a column, several for a
+	// composite PRIMARY KEY (a, b). Empty if the table has no key.
+	PrimaryKeyColumns []int
+
+	// Layouts lists, for each version of the schema, the IDs of the
+	// columns a row of that version holds, in order; the last is the
+	// current columns'. Nil until the table is first altered (see
+	// rowversion.go).
+	Layouts [][]int
+
+	// positions maps each version's values to the current columns
+	positions [][]int
 }
 
 // NewSchema creates a new schema from column definitions.
@@ -164,16 +234,60 @@ func NewSchema(columns []parser.ColumnDefinition) *Schema {
 			Type:       col.Type,
 			PrimaryKey: col.PrimaryKey,
 			NotNull:    col.NotNull,
+			Precision:  col.Precision,
+			Scale:      col.Scale,
+			Length:     col.Length,
+			Collation:  Collation(col.Collation),
+			Default:    col.Default,
 		}
 		schema.ColumnLookup[col.Name] = i
 		if col.PrimaryKey {
-			schema.PrimaryKey = i
+			schema.PrimaryKeyColumns = append(schema.PrimaryKeyColumns, i)
 		}
 	}
+	if len(schema.PrimaryKeyColumns) == 1 {
+		schema.PrimaryKey = schema.PrimaryKeyColumns[0]
+	}
 
 	return schema
 }
 
+// SetPrimaryKey makes the named columns the primary key, in that order,
+// as a PRIMARY KEY (a, b) constraint lists them. NewSchema can only take
+// them in the order of the columns.
+func (s *Schema) SetPrimaryKey(names []string) error {
+	columns := make([]int, len(names))
+	for i, name := range names {
+		idx, ok := s.ColumnLookup[name]
+		if !ok {
+			return fmt.Errorf("primary key column %s does not exist", name)
+		}
+		columns[i] = idx
+	}
+
+	for i := range s.Columns {
+		s.Columns[i].PrimaryKey = false
+	}
+	for _, idx := range columns {
+		s.Columns[idx].PrimaryKey = true
+	}
+	s.PrimaryKeyColumns, s.PrimaryKey = columns, -1
+	if len(columns) == 1 {
+		s.PrimaryKey = columns[0]
+	}
+	return nil
+}
+
+// PrimaryKeyNames returns the names of the primary key's columns, in key
+// order.
+func (s *Schema) PrimaryKeyNames() []string {
+	names := make([]string, len(s.PrimaryKeyColumns))
+	for i, idx := range s.PrimaryKeyColumns {
+		names[i] = s.Columns[idx].Name
+	}
+	return names
+}
+
 // GetColumnIndex returns the index of a column by name.
 func (s *Schema) GetColumnIndex(name string) (int, bool) {
 	idx, ok := s.ColumnLookup[name]
@@ -188,9 +302,22 @@ func (s *Schema) GetColumnIndex(name string) (int, bool) {
 // to estimate how many rows will match a predicate and choose the best access
 // method. Without statistics, the planner must use rough estimates.
 type TableStats struct {
-	RowCount     int64     // Number of rows in the table
-	PageCount    int       // Number of data pages
-	LastAnalyzed time.Time // When ANALYZE was last run
+	RowCount     int64                  // Number of rows in the table
+	BytesStored  int64                  // Bytes of the rows, as serialized
+	PageCount    int                    // Number of data pages
+	LastAnalyzed time.Time              // When ANALYZE was last run
+	Columns      map[string]ColumnStats // Per-column statistics, by column name
+
+	// Modified counts the rows inserted, updated or deleted since ANALYZE
+	// last ran, which tells how stale the rest may be
+	Modified int64
+}
+
+// Analyzed reports whether ANALYZE has gathered the statistics. Until it
+// has, only RowCount and BytesStored, which inserts, updates and deletes
+// keep up to date, are known.
+func (s TableStats) Analyzed() bool {
+	return !s.LastAnalyzed.IsZero()
 }
 
 // IndexStats holds statistics about an index.
@@ -208,11 +335,10 @@ type Table struct {
 	Schema *Schema
 
 	// Storage
-	pager        *storage.Pager
-	btree        *storage.BTree
-	nextRowID    uint64
-	dataPageIDs  []uint32 // List of data page IDs
-	metadataPage uint32   // Page storing table metadata
+	pager       *storage.Pager
+	btree       *storage.BTree
+	nextRowID   uint64
+	dataPageIDs []uint32 // List of data page IDs
 
 	// Secondary indexes
 	indexes map[string]*storage.Index // Indexed by index name
@@ -220,6 +346,18 @@ type Table struct {
 	// Statistics for query planning
 	stats      TableStats
 	indexStats IndexStats
+
+	// The range of each column's values in each data page, by page ID,
+	// for scans to skip pages by (see zonemap.go). zoneMu guards them,
+	// since scans build them while holding only the read lock.
+	zoneMu sync.Mutex
+	zones  map[uint32][]columnZone
+
+	// For a columnar table, the rows packed column by column, and how
+	// many rows make a group, 0 for defaultRowGroupSize (see columnar.go)
+	columnar  bool
+	groups    []rowGroup
+	groupSize int
 }
 
 // TableMetadata stores table information for persistence.
@@ -238,21 +376,16 @@ func NewTable(name string, schema *Schema, pager *storage.Pager) (*Table, error)
 		return nil, fmt.Errorf("failed to create B-tree: %w", err)
 	}
 
-	// Allocate metadata page
-	metaPage, err := pager.AllocatePage(storage.PageTypeData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to allocate metadata page: %w", err)
-	}
-
+	// The table's metadata is kept in the catalog (see catalog.TableInfo),
+	// so the B-tree is all a new table needs
 	table := &Table{
-		Name:         name,
-		Schema:       schema,
-		pager:        pager,
-		btree:        btree,
-		nextRowID:    1,
-		dataPageIDs:  []uint32{},
-		metadataPage: metaPage.ID(),
-		indexes:      make(map[string]*storage.Index),
+		Name:        name,
+		Schema:      schema,
+		pager:       pager,
+		btree:       btree,
+		nextRowID:   1,
+		dataPageIDs: []uint32{},
+		indexes:     make(map[string]*storage.Index),
 	}
 
 	return table, nil
@@ -271,6 +404,10 @@ func LoadTable(name string, schema *Schema, pager *storage.Pager, rootPage uint3
 	}
 }
 
+// ErrDuplicateKey is returned when a row would have the same primary key
+// as another row of the table.
+var ErrDuplicateKey = errors.New("duplicate key value violates primary key")
+
 // Insert adds a new row to the table.
 //
 // EDUCATIONAL NOTE:
@@ -278,32 +415,48 @@ func LoadTable(name string, schema *Schema, pager *storage.Pager, rootPage uint3
 // Inserting a row involves:
 // 1. Validate values against schema
 // 2. Assign a row ID
-// 3. Serialize the row to bytes
-// 4. Store in a data page
-// 5. Add to primary key index (B-tree)
-// 6. Add to all secondary indexes
+// 3. Check that no other row has the same primary key or unique key
+// 4. Serialize the row to bytes
+// 5. Store in a data page
+// 6. Add to primary key index (B-tree)
+// 7. Add to all secondary indexes
+//
+// Step 3 must come before the row is stored: the B-tree holds one
+// location per key, so a second row with the key would replace the
+// first's entry, and the first would then be found by a scan but not by
+// a lookup.
 func (t *Table) Insert(values []Value) (uint64, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Validate column count
-	if len(values) != len(t.Schema.Columns) {
-		return 0, fmt.Errorf("expected %d values, got %d", len(t.Schema.Columns), len(values))
+	if err := t.validateValues(values); err != nil {
+		return 0, err
 	}
 
-	// Validate types
-	for i, val := range values {
-		col := t.Schema.Columns[i]
-		if !val.IsNull && val.Type != col.Type {
-			return 0, fmt.Errorf("column %s expects %s, got %s", col.Name, col.Type, val.Type)
+	// Assign row ID
+	rowID := t.nextRowID
+
+	// Create key for B-tree (use primary key value or row ID)
+	keyBytes, err := t.primaryIndexKey(values, rowID)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.checkKeySizes(keyBytes, values); err != nil {
+		return 0, err
+	}
+	if err := t.checkPrimaryKeyFree(keyBytes, values); err != nil {
+		return 0, err
+	}
+	for _, idx := range t.indexes {
+		if !idx.Unique {
+			continue
 		}
-		if val.IsNull && col.NotNull {
-			return 0, fmt.Errorf("column %s cannot be NULL", col.Name)
+		if locations, err := idx.Lookup(t.buildIndexKey(Row{Values: values}, t.indexColumns(idx))); err != nil {
+			return 0, err
+		} else if len(locations) > 0 {
+			return 0, fmt.Errorf("duplicate key value violates unique constraint %q", idx.Name)
 		}
 	}
-
-	// Assign row ID
-	rowID := t.nextRowID
 	t.nextRowID++
 
 	// Serialize row
@@ -317,18 +470,7 @@ func (t *Table) Insert(values []Value) (uint64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("failed to store row data: %w", err)
 	}
-
-	// Create key for B-tree (use primary key value or row ID)
-	var keyBytes []byte
-	if t.Schema.PrimaryKey >= 0 {
-		keyBytes, err = t.valueToBytes(values[t.Schema.PrimaryKey])
-		if err != nil {
-			return 0, fmt.Errorf("failed to serialize primary key: %w", err)
-		}
-	} else {
-		keyBytes = make([]byte, 8)
-		binary.LittleEndian.PutUint64(keyBytes, rowID)
-	}
+	t.widenZones(pageID, values)
 
 	// Store location in B-tree: encode page ID and offset into uint64
 	location := uint64(pageID)<<32 | uint64(offset)
@@ -339,17 +481,7 @@ func (t *Table) Insert(values []Value) (uint64, error) {
 	// Update secondary indexes
 	for _, idx := range t.indexes {
 		// Build index key from indexed column values
-		columnIndices := make([]int, len(idx.Columns))
-		for i, colName := range idx.Columns {
-			colIdx, _ := t.Schema.GetColumnIndex(colName)
-			columnIndices[i] = colIdx
-		}
-
-		buf := bytes.NewBuffer(nil)
-		for _, colIdx := range columnIndices {
-			t.serializeValue(buf, values[colIdx])
-		}
-		indexKey := buf.Bytes()
+		indexKey := t.buildIndexKey(Row{Values: values}, t.indexColumns(idx))
 
 		if err := idx.Insert(indexKey, location); err != nil {
 			return 0, fmt.Errorf("failed to update secondary index %s: %w", idx.Name, err)
@@ -358,16 +490,67 @@ func (t *Table) Insert(values []Value) (uint64, error) {
 
 	// Update statistics
 	t.stats.RowCount++
+	t.stats.BytesStored += int64(len(rowData))
+	t.stats.Modified++
 
+	if err := t.packIfFull(); err != nil {
+		return 0, err
+	}
 	return rowID, nil
 }
 
-// Scan returns all rows in the table.
+// validateValues checks a full row of values against the schema.
+func (t *Table) validateValues(values []Value) error {
+	// Validate column count
+	if len(values) != len(t.Schema.Columns) {
+		return fmt.Errorf("expected %d values, got %d", len(t.Schema.Columns), len(values))
+	}
+
+	// A primary key identifies its row, and NULL identifies nothing: an
+	// explicit NULL and an omitted column don't even encode to the same
+	// key, so a lookup would never find the rows a scan returns
+	for _, i := range t.Schema.PrimaryKeyColumns {
+		if values[i].IsNull {
+			return fmt.Errorf("primary key column %s cannot be NULL", t.Schema.Columns[i].Name)
+		}
+	}
+
+	// Validate types
+	for i, val := range values {
+		col := t.Schema.Columns[i]
+		if !val.IsNull && val.Type != col.Type {
+			return fmt.Errorf("column %s expects %s, got %s", col.Name, col.Type, val.Type)
+		}
+		if val.IsNull && col.NotNull {
+			return fmt.Errorf("column %s cannot be NULL", col.Name)
+		}
+
+		// A value too long for a VARCHAR(n) is rejected, as in PostgreSQL
+		// and standard SQL, rather than cut short as MySQL's non-strict
+		// mode does: silently losing the end of a value is worse than an
+		// error. The length counts characters, not bytes.
+		if col.Length > 0 && !val.IsNull && utf8.RuneCountInString(val.Text) > col.Length {
+			return fmt.Errorf("value too long for column %s VARCHAR(%d)", col.Name, col.Length)
+		}
+	}
+	return nil
+}
+
+// Scan returns all rows in the table. A large table is read by a pool of
+// workers (see ParallelScan).
 func (t *Table) Scan() ([]Row, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	var rows []Row
+	if len(t.dataPageIDs) >= parallelScanMinPages && runtime.GOMAXPROCS(0) > 1 {
+		return t.parallelScanLocked(0)
+	}
+
+	// A columnar table's packed rows come first
+	rows, err := t.packedRows()
+	if err != nil {
+		return nil, err
+	}
 
 	// Iterate through all data pages
 	for _, pageID := range t.dataPageIDs {
@@ -399,32 +582,27 @@ func (t *Table) Scan() ([]Row, error) {
 // 2. With a LIMIT, we can stop early once satisfied
 // 3. CPU cache is used more efficiently (fewer allocations)
 func (t *Table) ScanWithFilter(filter func(Row) bool, limit int) ([]Row, error) {
-	var rows []Row
-
-	// Iterate through all data pages
-	for _, pageID := range t.dataPageIDs {
-		page, err := t.pager.GetPage(pageID)
-		if err != nil {
-			return nil, err
-		}
-
-		// Read and filter rows from page
-		pageRows, err := t.readRowsFromPage(page)
-		if err != nil {
-			return nil, err
-		}
+	it := t.NewScanIterator()
+	defer it.Close()
+	return collectRows(it, filter, limit)
+}
 
-		for _, row := range pageRows {
-			if filter(row) {
-				rows = append(rows, row)
-				// Early exit if limit is satisfied
-				if limit > 0 && len(rows) >= limit {
-					return rows, nil
-				}
+// collectRows reads the rows of it that match filter, at most limit of
+// them if limit > 0.
+func collectRows(it *RowIterator, filter func(Row) bool, limit int) ([]Row, error) {
+	var rows []Row
+	for it.Next() {
+		if filter(it.Row()) {
+			rows = append(rows, it.Row())
+			// Early exit if limit is satisfied
+			if limit > 0 && len(rows) >= limit {
+				break
 			}
 		}
 	}
-
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
 	return rows, nil
 }
 
@@ -437,9 +615,21 @@ func (t *Table) serializeRow(rowID uint64, values []Value) ([]byte, error) {
 		return nil, fmt.Errorf("writing row ID: %w", err)
 	}
 
-	// Write number of values
-	if err := binary.Write(buf, binary.LittleEndian, uint16(len(values))); err != nil {
-		return nil, fmt.Errorf("writing value count: %w", err)
+	// Write number of values, flagged and followed by the schema version
+	// once the table has been altered (see rowversion.go)
+	version := 0
+	if t != nil {
+		version = t.Schema.Version()
+	}
+	if version == 0 {
+		if err := binary.Write(buf, binary.LittleEndian, uint16(len(values))); err != nil {
+			return nil, fmt.Errorf("writing value count: %w", err)
+		}
+	} else {
+		header := []uint16{uint16(len(values)) | rowVersionFlag, uint16(version)}
+		if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+			return nil, fmt.Errorf("writing value count: %w", err)
+		}
 	}
 
 	// Write each value
@@ -452,6 +642,24 @@ func (t *Table) serializeRow(rowID uint64, values []Value) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// EncodeRow serializes a row the way it is stored in a data page, for
+// rows written outside any table, such as the runs of an external sort.
+// DecodeRow reads it back.
+func EncodeRow(row Row) ([]byte, error) {
+	var t *Table // the encoding doesn't depend on the table
+	return t.serializeRow(row.ID, row.Values)
+}
+
+// DecodeRow reads a row serialized by EncodeRow.
+func DecodeRow(data []byte) (Row, error) {
+	var t *Table
+	return t.deserializeRow(data)
+}
+
+// longTextMarker takes the place of a text value's 16-bit length when the
+// length doesn't fit, and is followed by the length as 32 bits.
+const longTextMarker = 0xFFFF
+
 // serializeValue writes a value to the buffer.
 func (t *Table) serializeValue(buf *bytes.Buffer, val Value) error {
 	// Write type and null flag
@@ -464,7 +672,7 @@ func (t *Table) serializeValue(buf *bytes.Buffer, val Value) error {
 
 	// Write value based on type
 	switch val.Type {
-	case parser.TypeInteger:
+	case parser.TypeInteger, parser.TypeTimestamp:
 		if err := binary.Write(buf, binary.LittleEndian, val.Integer); err != nil {
 			return fmt.Errorf("writing integer value: %w", err)
 		}
@@ -472,8 +680,17 @@ func (t *Table) serializeValue(buf *bytes.Buffer, val Value) error {
 		if err := binary.Write(buf, binary.LittleEndian, val.Real); err != nil {
 			return fmt.Errorf("writing real value: %w", err)
 		}
-	case parser.TypeText:
-		if err := binary.Write(buf, binary.LittleEndian, uint16(len(val.Text))); err != nil {
+	case parser.TypeText, parser.TypeJSON:
+		// Lengths that don't fit in 16 bits are escaped: 0xFFFF, then the
+		// real length in 32 bits
+		var err error
+		if len(val.Text) < longTextMarker {
+			err = binary.Write(buf, binary.LittleEndian, uint16(len(val.Text)))
+		} else {
+			buf.Write([]byte{0xFF, 0xFF})
+			err = binary.Write(buf, binary.LittleEndian, uint32(len(val.Text)))
+		}
+		if err != nil {
 			return fmt.Errorf("writing text length: %w", err)
 		}
 		buf.WriteString(val.Text)
@@ -483,6 +700,11 @@ func (t *Table) serializeValue(buf *bytes.Buffer, val Value) error {
 		} else {
 			buf.WriteByte(0)
 		}
+	case parser.TypeDecimal:
+		if err := binary.Write(buf, binary.LittleEndian, val.Integer); err != nil {
+			return fmt.Errorf("writing decimal value: %w", err)
+		}
+		buf.WriteByte(val.Scale)
 	default:
 		return fmt.Errorf("unsupported type for serialization: %v", val.Type)
 	}
@@ -491,28 +713,69 @@ func (t *Table) serializeValue(buf *bytes.Buffer, val Value) error {
 
 // deserializeRow reads a row from bytes.
 func (t *Table) deserializeRow(data []byte) (Row, error) {
+	return t.deserializeColumns(data, nil)
+}
+
+// deserializeColumns reads a row from bytes, decoding only the values of
+// the columns set in columns; the others are skipped and read as NULL. A nil
+// columns decodes them all.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A query naming one column of a wide table still has to read whole rows,
+// since they're stored together, but it needn't decode them. Skipping a
+// value means reading its type and length and moving past it, without
+// copying its text into a new string. Column stores go further and keep
+// each column apart, so unused ones aren't read at all.
+func (t *Table) deserializeColumns(data []byte, columns []bool) (Row, error) {
 	buf := bytes.NewReader(data)
 	row := Row{}
 
-	// Read row ID
-	if err := binary.Read(buf, binary.LittleEndian, &row.ID); err != nil {
+	// Read row ID, number of values and schema version
+	rowID, numValues, version, err := readRowHeader(buf)
+	if err != nil {
 		return row, err
 	}
+	row.ID = rowID
 
-	// Read number of values
-	var numValues uint16
-	if err := binary.Read(buf, binary.LittleEndian, &numValues); err != nil {
+	// A row written with an older schema has its values mapped to the
+	// current columns; those it has no value for read as NULL
+	positions, err := t.rowPositions(version)
+	if err != nil {
 		return row, err
 	}
+	if positions == nil {
+		row.Values = make([]Value, numValues)
+	} else {
+		row.Values = make([]Value, len(t.Schema.Columns))
+		for i := range row.Values {
+			row.Values[i] = Value{IsNull: true}
+		}
+	}
 
 	// Read each value
-	row.Values = make([]Value, numValues)
-	for i := uint16(0); i < numValues; i++ {
+	for i := 0; i < numValues; i++ {
+		col := i
+		if positions != nil {
+			col = -1
+			if i < len(positions) {
+				col = positions[i]
+			}
+		}
+		if col < 0 || (columns != nil && (col >= len(columns) || !columns[col])) {
+			if err := skipValue(buf); err != nil {
+				return row, err
+			}
+			if col >= 0 {
+				row.Values[col] = Value{IsNull: true}
+			}
+			continue
+		}
 		val, err := t.deserializeValue(buf)
 		if err != nil {
 			return row, err
 		}
-		row.Values[i] = val
+		row.Values[col] = val
 	}
 
 	return row, nil
@@ -541,7 +804,7 @@ func (t *Table) deserializeValue(buf *bytes.Reader) (Value, error) {
 
 	// Read value based on type
 	switch val.Type {
-	case parser.TypeInteger:
+	case parser.TypeInteger, parser.TypeTimestamp:
 		if err := binary.Read(buf, binary.LittleEndian, &val.Integer); err != nil {
 			return val, err
 		}
@@ -549,13 +812,19 @@ func (t *Table) deserializeValue(buf *bytes.Reader) (Value, error) {
 		if err := binary.Read(buf, binary.LittleEndian, &val.Real); err != nil {
 			return val, err
 		}
-	case parser.TypeText:
-		var length uint16
-		if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+	case parser.TypeText, parser.TypeJSON:
+		var length16 uint16
+		if err := binary.Read(buf, binary.LittleEndian, &length16); err != nil {
 			return val, err
 		}
+		length := uint32(length16)
+		if length16 == longTextMarker {
+			if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+				return val, err
+			}
+		}
 		textBytes := make([]byte, length)
-		if _, err := buf.Read(textBytes); err != nil {
+		if _, err := io.ReadFull(buf, textBytes); err != nil {
 			return val, err
 		}
 		val.Text = string(textBytes)
@@ -565,13 +834,131 @@ func (t *Table) deserializeValue(buf *bytes.Reader) (Value, error) {
 			return val, err
 		}
 		val.Boolean = boolByte == 1
+	case parser.TypeDecimal:
+		if err := binary.Read(buf, binary.LittleEndian, &val.Integer); err != nil {
+			return val, err
+		}
+		scale, err := buf.ReadByte()
+		if err != nil {
+			return val, err
+		}
+		val.Scale = scale
 	}
 
 	return val, nil
 }
 
-// storeRowData stores row data in a data page.
+// skipValue moves past a value in the buffer without decoding it.
+func skipValue(buf *bytes.Reader) error {
+	typeByte, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+	nullByte, err := buf.ReadByte()
+	if err != nil || nullByte == 1 {
+		return err
+	}
+
+	var size int64
+	switch parser.DataType(typeByte) {
+	case parser.TypeInteger, parser.TypeTimestamp, parser.TypeReal:
+		size = 8
+	case parser.TypeBoolean:
+		size = 1
+	case parser.TypeDecimal:
+		size = 9
+	case parser.TypeText, parser.TypeJSON:
+		var length16 uint16
+		if err := binary.Read(buf, binary.LittleEndian, &length16); err != nil {
+			return err
+		}
+		size = int64(length16)
+		if length16 == longTextMarker {
+			var length uint32
+			if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
+				return err
+			}
+			size = int64(length)
+		}
+	}
+	if int64(buf.Len()) < size {
+		return io.ErrUnexpectedEOF
+	}
+	_, err = buf.Seek(size, io.SeekCurrent)
+	return err
+}
+
+const (
+	// rowDeletedFlag marks a deleted row in the high bit of its length
+	// prefix (see DeleteRow).
+	rowDeletedFlag = 0x8000
+
+	// rowOverflowFlag marks a record that points to an overflow chain
+	// instead of holding the row itself (see encodeRecord).
+	rowOverflowFlag = 0x4000
+
+	// rowLengthMask extracts the record length from a length prefix.
+	rowLengthMask = 0x3FFF
+
+	// maxInlineRowSize is the largest row stored directly in a data page.
+	maxInlineRowSize = storage.MaxDataSize / 4
+)
+
+// encodeRecord returns the record that stores a serialized row in a data
+// page, and the flags for its length prefix. A row longer than
+// maxInlineRowSize is written to a chain of overflow pages, and its record
+// holds only the row's length and the chain's first page:
+//
+//   [row length: uint32][first overflow page: uint32]
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Why spill at a quarter of a page instead of only when a row can't fit at
+// all? A row that fills most of a page leaves the rest of it too small for
+// its neighbours, and every scan of the table has to read all of it. With
+// large rows moved aside, at least four rows share each data page. SQLite
+// sets its spill threshold for the same reason.
+func (t *Table) encodeRecord(rowData []byte) ([]byte, uint16, error) {
+	if len(rowData) <= maxInlineRowSize {
+		return rowData, 0, nil
+	}
+
+	firstPage, err := t.pager.WriteOverflow(rowData)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to write overflow pages: %w", err)
+	}
+	record := make([]byte, 8)
+	binary.LittleEndian.PutUint32(record, uint32(len(rowData)))
+	binary.LittleEndian.PutUint32(record[4:], firstPage)
+	return record, rowOverflowFlag, nil
+}
+
+// decodeRecord returns the serialized row held by a record with the given
+// length prefix, reading it from its overflow chain if it has one.
+func (t *Table) decodeRecord(record []byte, prefix uint16) ([]byte, error) {
+	if prefix&rowOverflowFlag == 0 {
+		return record, nil
+	}
+	if len(record) < 8 {
+		return nil, fmt.Errorf("invalid overflow record of %d bytes", len(record))
+	}
+	length := binary.LittleEndian.Uint32(record)
+	firstPage := binary.LittleEndian.Uint32(record[4:])
+	return t.pager.ReadOverflow(firstPage, int(length))
+}
+
+// storeRowData stores row data in a data page, moving it to overflow pages
+// first if it is large.
 func (t *Table) storeRowData(data []byte) (uint32, uint16, error) {
+	record, flags, err := t.encodeRecord(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.storeRecord(record, flags)
+}
+
+// storeRecord stores a record in the first data page with room for it.
+func (t *Table) storeRecord(record []byte, flags uint16) (uint32, uint16, error) {
 	// Try to fit in existing pages
 	for _, pageID := range t.dataPageIDs {
 		page, err := t.pager.GetPage(pageID)
@@ -579,8 +966,8 @@ func (t *Table) storeRowData(data []byte) (uint32, uint16, error) {
 			continue
 		}
 
-		if int(page.FreeSpace()) >= len(data)+2 { // +2 for length prefix
-			offset, err := t.writeRowToPage(page, data)
+		if int(page.FreeSpace()) >= len(record)+2 { // +2 for length prefix
+			offset, err := t.writeRecordToPage(page, record, flags)
 			if err == nil {
 				return pageID, offset, nil
 			}
@@ -594,7 +981,7 @@ func (t *Table) storeRowData(data []byte) (uint32, uint16, error) {
 	}
 	t.dataPageIDs = append(t.dataPageIDs, page.ID())
 
-	offset, err := t.writeRowToPage(page, data)
+	offset, err := t.writeRecordToPage(page, record, flags)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -602,18 +989,24 @@ func (t *Table) storeRowData(data []byte) (uint32, uint16, error) {
 	return page.ID(), offset, nil
 }
 
-// writeRowToPage writes a row to a page with length prefix.
-func (t *Table) writeRowToPage(page *storage.Page, data []byte) (uint16, error) {
-	// Prefix with length
-	lengthPrefixed := make([]byte, 2+len(data))
-	binary.LittleEndian.PutUint16(lengthPrefixed, uint16(len(data)))
-	copy(lengthPrefixed[2:], data)
+// writeRecordToPage writes a record to a page with length prefix.
+func (t *Table) writeRecordToPage(page *storage.Page, record []byte, flags uint16) (uint16, error) {
+	// Prefix with length and flags
+	lengthPrefixed := make([]byte, 2+len(record))
+	binary.LittleEndian.PutUint16(lengthPrefixed, uint16(len(record))|flags)
+	copy(lengthPrefixed[2:], record)
 
 	return page.WriteData(lengthPrefixed)
 }
 
 // readRowsFromPage reads all rows from a data page.
 func (t *Table) readRowsFromPage(page *storage.Page) ([]Row, error) {
+	return t.readColumnsFromPage(page, nil)
+}
+
+// readColumnsFromPage reads all rows from a data page, decoding the
+// columns set in columns (see deserializeColumns).
+func (t *Table) readColumnsFromPage(page *storage.Page, columns []bool) ([]Row, error) {
 	var rows []Row
 	data := page.GetData()
 	offset := 0
@@ -621,20 +1014,32 @@ func (t *Table) readRowsFromPage(page *storage.Page) ([]Row, error) {
 	numSlots := int(page.NumSlots())
 	for i := 0; i < numSlots && offset < len(data)-1; i++ {
 		// Read length
-		length := binary.LittleEndian.Uint16(data[offset:])
-		if length == 0 {
+		prefix := binary.LittleEndian.Uint16(data[offset:])
+		if prefix == 0 {
 			break
 		}
+		length := int(prefix & rowLengthMask)
+		location := uint64(page.ID())<<32 | uint64(offset)
 		offset += 2
 
+		// Skip deleted rows
+		if prefix&rowDeletedFlag != 0 {
+			offset += length
+			continue
+		}
+
 		// Read row data
-		rowData := data[offset : offset+int(length)]
-		row, err := t.deserializeRow(rowData)
+		rowData, err := t.decodeRecord(data[offset:offset+length], prefix)
+		if err != nil {
+			return nil, err
+		}
+		row, err := t.deserializeColumns(rowData, columns)
 		if err != nil {
 			return nil, err
 		}
+		row.location, row.size = location, len(rowData)
 		rows = append(rows, row)
-		offset += int(length)
+		offset += length
 	}
 
 	return rows, nil
@@ -643,90 +1048,657 @@ func (t *Table) readRowsFromPage(page *storage.Page) ([]Row, error) {
 // valueToBytes converts a value to bytes for use as B-tree key.
 func (t *Table) valueToBytes(val Value) ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
-	if err := t.serializeValue(buf, val); err != nil {
+	if err := encodeKey(buf, val); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
 // Update modifies rows matching the filter.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// All matching rows are found before any is changed. An updated row can
+// move to a later page, and a scan that was still running would find it
+// again and update it twice: the "Halloween problem", named after the day
+// IBM researchers hit it in 1976.
 func (t *Table) Update(assignments map[string]Value, filter func(Row) bool) (int, error) {
-	// This is a simplified implementation - in a real database,
-	// we'd update in place or use a more sophisticated approach
-	rows, err := t.Scan()
+	rows, err := t.ScanWithFilter(filter, 0)
 	if err != nil {
 		return 0, err
 	}
 
-	count := 0
-	for i := range rows {
-		if filter(rows[i]) {
-			for colName, newVal := range assignments {
-				if colIdx, ok := t.Schema.GetColumnIndex(colName); ok {
-					rows[i].Values[colIdx] = newVal
-				}
+	for i, row := range rows {
+		values := append([]Value(nil), row.Values...)
+		for colName, newVal := range assignments {
+			if colIdx, ok := t.Schema.GetColumnIndex(colName); ok {
+				values[colIdx] = newVal
 			}
-			count++
+		}
+		if err := t.UpdateRow(row, values); err != nil {
+			return i, err
 		}
 	}
 
-	return count, nil
-}
-
-// Delete removes rows matching the filter.
-func (t *Table) Delete(filter func(Row) bool) (int, error) {
-	// Simplified implementation - marks rows as deleted
-	// A real implementation would handle space reclamation
-	return 0, errors.New("delete not fully implemented yet")
-}
-
-// GetRootPage returns the B-tree root page for persistence.
-func (t *Table) GetRootPage() uint32 {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	return t.btree.RootPage()
-}
-
-// GetNextRowID returns the next row ID for persistence.
-func (t *Table) GetNextRowID() uint64 {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	return t.nextRowID
-}
-
-// GetDataPageIDs returns the list of data page IDs for persistence.
-func (t *Table) GetDataPageIDs() []uint32 {
-	t.mu.RLock()
-	defer t.mu.RUnlock()
-	// Return a copy to prevent caller from modifying internal state
-	result := make([]uint32, len(t.dataPageIDs))
-	copy(result, t.dataPageIDs)
-	return result
+	return len(rows), nil
 }
 
-// GetRowByPrimaryKey retrieves a row by its primary key value.
+// UpdateRow replaces the values of a row previously returned by Scan,
+// ScanWithFilter or a lookup. The row keeps its row ID.
 //
 // EDUCATIONAL NOTE:
 // -----------------
-// This method uses the B-tree index for O(log n) lookup instead of
-// scanning the entire table. This is the key optimization that makes
+// If the new version fits in the space the old one used, it is written
+// over it in place and the row keeps its location, so only index entries
+// whose key changed need touching. If it has grown, the old version is
+// deleted (see DeleteRow) and the new one stored wherever there is room;
+// every index entry must then be moved to the new location.
+//
+// A shorter row is padded with zeros up to the old length, since the
+// length prefix is also how scans find the next row in the page.
+func (t *Table) UpdateRow(row Row, values []Value) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.columnar {
+		return fmt.Errorf("rows of columnar table %s cannot be updated", t.Name)
+	}
+	if err := t.validateValues(values); err != nil {
+		return err
+	}
+	if row.location == 0 {
+		return fmt.Errorf("row %d was not read from storage", row.ID)
+	}
+	updated := Row{ID: row.ID, Values: values}
+
+	// Check unique indexes before changing anything
+	for _, idx := range t.indexes {
+		columns := t.indexColumns(idx)
+		newKey := t.buildIndexKey(updated, columns)
+		if !idx.Unique || bytes.Equal(newKey, t.buildIndexKey(row, columns)) {
+			continue
+		}
+		if locations, err := idx.Lookup(newKey); err != nil {
+			return err
+		} else if len(locations) > 0 {
+			return fmt.Errorf("duplicate key value violates unique constraint %q", idx.Name)
+		}
+	}
+
+	oldKey, err := t.primaryIndexKey(row.Values, row.ID)
+	if err != nil {
+		return err
+	}
+	newKey, err := t.primaryIndexKey(values, row.ID)
+	if err != nil {
+		return err
+	}
+	if err := t.checkKeySizes(newKey, values); err != nil {
+		return err
+	}
+	if !bytes.Equal(oldKey, newKey) {
+		if err := t.checkPrimaryKeyFree(newKey, values); err != nil {
+			return err
+		}
+	}
+
+	rowData, err := t.serializeRow(row.ID, values)
+	if err != nil {
+		return fmt.Errorf("failed to serialize row: %w", err)
+	}
+
+	pageID := uint32(row.location >> 32)
+	offset := uint16(row.location & 0xFFFFFFFF)
+	page, err := t.pager.GetPage(pageID)
+	if err != nil {
+		return fmt.Errorf("failed to get page %d: %w", pageID, err)
+	}
+	data := page.GetData()
+	prefix := binary.LittleEndian.Uint16(data[offset:])
+	if prefix&rowDeletedFlag != 0 {
+		return fmt.Errorf("row %d has been deleted", row.ID)
+	}
+	record, flags, err := t.encodeRecord(rowData)
+	if err != nil {
+		return err
+	}
+
+	// A record that still fits is rewritten where it is. Its length stays
+	// the same, so the record after it can still be found.
+	location := row.location
+	length := int(prefix & rowLengthMask)
+	if len(record) <= length {
+		slot := data[int(offset)+2 : int(offset)+2+length]
+		copy(slot, record)
+		clear(slot[len(record):])
+		binary.LittleEndian.PutUint16(data[offset:], uint16(length)|flags)
+		page.MarkDirty()
+	} else {
+		binary.LittleEndian.PutUint16(data[offset:], prefix|rowDeletedFlag)
+		page.MarkDirty()
+
+		newPageID, newOffset, err := t.storeRecord(record, flags)
+		if err != nil {
+			return fmt.Errorf("failed to store row data: %w", err)
+		}
+		location = uint64(newPageID)<<32 | uint64(newOffset)
+	}
+	t.widenZones(uint32(location>>32), values)
+
+	// Move the primary key entry if the key or location changed
+	if location != row.location || !bytes.Equal(oldKey, newKey) {
+		if current, found, err := t.btree.Search(oldKey); err != nil {
+			return fmt.Errorf("index search failed: %w", err)
+		} else if found && current == row.location {
+			if _, err := t.btree.Delete(oldKey); err != nil {
+				return fmt.Errorf("failed to delete from index: %w", err)
+			}
+		}
+		if err := t.btree.Insert(newKey, location); err != nil {
+			return fmt.Errorf("failed to insert into index: %w", err)
+		}
+	}
+
+	for _, idx := range t.indexes {
+		columns := t.indexColumns(idx)
+		oldIndexKey, newIndexKey := t.buildIndexKey(row, columns), t.buildIndexKey(updated, columns)
+		if location == row.location && bytes.Equal(oldIndexKey, newIndexKey) {
+			continue
+		}
+		if err := idx.Delete(oldIndexKey, row.location); err != nil {
+			return fmt.Errorf("failed to update secondary index %s: %w", idx.Name, err)
+		}
+		if err := idx.Insert(newIndexKey, location); err != nil {
+			return fmt.Errorf("failed to update secondary index %s: %w", idx.Name, err)
+		}
+	}
+
+	t.stats.BytesStored += int64(len(rowData) - row.size)
+	t.stats.Modified++
+	return nil
+}
+
+// Delete removes rows matching the filter.
+func (t *Table) Delete(filter func(Row) bool) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.columnar {
+		return 0, fmt.Errorf("rows of columnar table %s cannot be deleted", t.Name)
+	}
+
+	count := 0
+	for _, pageID := range t.dataPageIDs {
+		page, err := t.pager.GetPage(pageID)
+		if err != nil {
+			return count, err
+		}
+		rows, err := t.readRowsFromPage(page)
+		if err != nil {
+			return count, err
+		}
+		for _, row := range rows {
+			if !filter(row) {
+				continue
+			}
+			if err := t.deleteRowLocked(row); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DeleteRow removes a row previously returned by Scan, ScanWithFilter or
+// a lookup.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Deleting a row has three parts:
+// 1. Remove its entry from the primary key B-tree, so lookups miss it
+// 2. Remove its entries from every secondary index
+// 3. Mark its slot in the data page as deleted (a "tombstone")
+//
+// The row's bytes stay where they are: moving the rows after it would
+// change their locations, which every index points at. Scans skip
+// tombstones, and VACUUM can later compact the page and reclaim the space.
+// The mark is the high bit of the row's 2-byte length prefix, which is
+// free because a row can never be longer than a page.
+func (t *Table) DeleteRow(row Row) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.deleteRowLocked(row)
+}
+
+// deleteRowLocked is DeleteRow for callers already holding the lock.
+func (t *Table) deleteRowLocked(row Row) error {
+	if t.columnar {
+		return fmt.Errorf("rows of columnar table %s cannot be deleted", t.Name)
+	}
+	if row.location == 0 {
+		return fmt.Errorf("row %d was not read from storage", row.ID)
+	}
+	pageID := uint32(row.location >> 32)
+	offset := uint16(row.location & 0xFFFFFFFF)
+
+	page, err := t.pager.GetPage(pageID)
+	if err != nil {
+		return fmt.Errorf("failed to get page %d: %w", pageID, err)
+	}
+	data := page.GetData()
+	length := binary.LittleEndian.Uint16(data[offset:])
+	if length&rowDeletedFlag != 0 {
+		return fmt.Errorf("row %d has already been deleted", row.ID)
+	}
+
+	// The primary key may already point at a newer row with the same key
+	key, err := t.primaryIndexKey(row.Values, row.ID)
+	if err != nil {
+		return err
+	}
+	if location, found, err := t.btree.Search(key); err != nil {
+		return fmt.Errorf("index search failed: %w", err)
+	} else if found && location == row.location {
+		if _, err := t.btree.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete from index: %w", err)
+		}
+	}
+
+	for _, idx := range t.indexes {
+		indexKey := t.buildIndexKey(row, t.indexColumns(idx))
+		if err := idx.Delete(indexKey, row.location); err != nil {
+			return fmt.Errorf("failed to update secondary index %s: %w", idx.Name, err)
+		}
+	}
+
+	binary.LittleEndian.PutUint16(data[offset:], length|rowDeletedFlag)
+	page.MarkDirty()
+
+	if t.stats.RowCount > 0 {
+		t.stats.RowCount--
+	}
+	t.stats.BytesStored = max(t.stats.BytesStored-int64(row.size), 0)
+	t.stats.Modified++
+	return nil
+}
+
+// checkKeySizes returns an error if a row with the given primary index key
+// and values would need a key too large for the primary key B-tree or one
+// of the secondary indexes. Checking first means a row is never stored
+// without its index entries.
+func (t *Table) checkKeySizes(primaryKey []byte, values []Value) error {
+	if err := storage.CheckKeySize(primaryKey); err != nil {
+		return fmt.Errorf("primary key: %w", err)
+	}
+	for _, idx := range t.indexes {
+		indexKey := t.buildIndexKey(Row{Values: values}, t.indexColumns(idx))
+		if err := idx.CheckKeySize(indexKey); err != nil {
+			return fmt.Errorf("index %s: %w", idx.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkPrimaryKeyFree returns ErrDuplicateKey if a row already has the
+// primary key key, made from values. Caller must hold the lock.
+func (t *Table) checkPrimaryKeyFree(key []byte, values []Value) error {
+	if len(t.Schema.PrimaryKeyColumns) == 0 {
+		return nil
+	}
+	_, found, err := t.btree.Search(key)
+	if err != nil {
+		return fmt.Errorf("index search failed: %w", err)
+	}
+	if !found {
+		return nil
+	}
+	keyValues := make([]string, len(t.Schema.PrimaryKeyColumns))
+	for i, idx := range t.Schema.PrimaryKeyColumns {
+		keyValues[i] = values[idx].String()
+	}
+	return fmt.Errorf("%w of table %s: (%s)=(%s)", ErrDuplicateKey, t.Name,
+		strings.Join(t.Schema.PrimaryKeyNames(), ", "), strings.Join(keyValues, ", "))
+}
+
+// primaryIndexKey returns a row's key in the primary B-tree: its primary
+// key value, or its row ID if the table has no primary key.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// The key of a composite primary key is its columns' encodings one after
+// the other, as for an index on several columns. Each encoding sorts like
+// its value and ends where it must (a text ends in 00 01), so the keys
+// sort by the first column, then the second:
+//
+//   (1, 'b') -> [int 1]['b' 00 01]
+//   (1, 'c') -> [int 1]['c' 00 01]
+//   (2, 'a') -> [int 2]['a' 00 01]
+func (t *Table) primaryIndexKey(values []Value, rowID uint64) ([]byte, error) {
+	if len(t.Schema.PrimaryKeyColumns) == 0 {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, rowID)
+		return key, nil
+	}
+	keyValues := make([]Value, len(t.Schema.PrimaryKeyColumns))
+	for i, idx := range t.Schema.PrimaryKeyColumns {
+		keyValues[i] = values[idx]
+	}
+	return t.primaryKeyBytes(keyValues)
+}
+
+// primaryKeyBytes encodes the values of the primary key's columns as a
+// key of the primary B-tree.
+func (t *Table) primaryKeyBytes(keyValues []Value) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	for i, val := range keyValues {
+		coll := t.Schema.Columns[t.Schema.PrimaryKeyColumns[i]].Collation
+		if err := encodeKey(buf, coll.Key(val)); err != nil {
+			return nil, fmt.Errorf("failed to serialize primary key: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// indexColumns returns the schema positions of an index's columns.
+func (t *Table) indexColumns(idx *storage.Index) []int {
+	columnIndices := make([]int, len(idx.Columns))
+	for i, colName := range idx.Columns {
+		columnIndices[i], _ = t.Schema.GetColumnIndex(colName)
+	}
+	return columnIndices
+}
+
+// Pager returns the pager the table's pages are in.
+func (t *Table) Pager() *storage.Pager {
+	return t.pager
+}
+
+// GetRootPage returns the B-tree root page for persistence.
+func (t *Table) GetRootPage() uint32 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.btree.RootPage()
+}
+
+// GetNextRowID returns the next row ID for persistence.
+func (t *Table) GetNextRowID() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.nextRowID
+}
+
+// GetDataPageIDs returns the list of data page IDs for persistence.
+func (t *Table) GetDataPageIDs() []uint32 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	// Return a copy to prevent caller from modifying internal state
+	result := make([]uint32, len(t.dataPageIDs))
+	copy(result, t.dataPageIDs)
+	return result
+}
+
+// TableState is a copy of a table's in-memory bookkeeping, taken by
+// Snapshot and put back by Restore.
+type TableState struct {
+	rootPage    uint32
+	nextRowID   uint64
+	dataPageIDs []uint32
+	indexes     map[string]*storage.Index
+	indexRoots  map[string]uint32
+	stats       TableStats
+	indexStats  IndexStats
+	groups      []rowGroup
+}
+
+// Snapshot captures the table's bookkeeping so a transaction can be
+// rolled back.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Rolling back the pager restores the table's pages, but a Table also
+// keeps state in memory: the B-tree root (which moves when the root
+// splits), the next row ID, the list of data pages. Those must be rolled
+// back too, or the table would point at pages that no longer exist.
+func (t *Table) Snapshot() TableState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	state := TableState{
+		rootPage:    t.btree.RootPage(),
+		nextRowID:   t.nextRowID,
+		dataPageIDs: append([]uint32(nil), t.dataPageIDs...),
+		indexes:     make(map[string]*storage.Index, len(t.indexes)),
+		indexRoots:  make(map[string]uint32, len(t.indexes)),
+		stats:       t.stats,
+		indexStats:  t.indexStats,
+		groups:      slices.Clone(t.groups),
+	}
+	for name, idx := range t.indexes {
+		state.indexes[name] = idx
+		state.indexRoots[name] = idx.RootPage()
+	}
+	return state
+}
+
+// Restore puts back bookkeeping captured by Snapshot. The pages
+// themselves must be restored separately (see storage.Pager.Rollback).
+func (t *Table) Restore(state TableState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.btree = storage.LoadBTree(t.pager, state.rootPage)
+	t.nextRowID = state.nextRowID
+	t.dataPageIDs = append([]uint32(nil), state.dataPageIDs...)
+	t.stats = state.stats
+	t.indexStats = state.indexStats
+	t.groups = slices.Clone(state.groups)
+	t.clearZones()
+
+	t.indexes = make(map[string]*storage.Index, len(state.indexes))
+	for name, idx := range state.indexes {
+		t.indexes[name] = storage.LoadIndex(idx.Name, idx.Table, idx.Columns, idx.Unique, t.pager, state.indexRoots[name])
+	}
+}
+
+// CompactInto copies the table into pager as a new table and returns it.
+// Live rows keep their row IDs but are packed into as few data pages as
+// possible, and the primary key B-tree and secondary indexes are rebuilt
+// from the bottom up (see storage.BuildBTree).
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Deleted rows leave tombstones in their pages (see DeleteRow), rows that
+// grew leave them behind when they move (see UpdateRow), and B-tree
+// leaves are never merged. Copying only the live rows into fresh pages
+// gets all of that space back. SQLite's VACUUM works the same way: it
+// rebuilds the whole database in a temporary file, then copies it back.
+func (t *Table) CompactInto(pager *storage.Pager) (*Table, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	compacted := &Table{
+		Name:       t.Name,
+		Schema:     t.Schema,
+		pager:      pager,
+		nextRowID:  t.nextRowID,
+		indexes:    make(map[string]*storage.Index, len(t.indexes)),
+		stats:      t.stats,
+		indexStats: t.indexStats,
+	}
+
+	// Copy the live rows, filling each page before starting the next, and
+	// count them again on the way
+	var rows []Row
+	var page *storage.Page
+	compacted.stats.RowCount, compacted.stats.BytesStored = 0, 0
+	for _, pageID := range t.dataPageIDs {
+		oldPage, err := t.pager.GetPage(pageID)
+		if err != nil {
+			return nil, err
+		}
+		pageRows, err := t.readRowsFromPage(oldPage)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range pageRows {
+			rowData, err := t.serializeRow(row.ID, row.Values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize row: %w", err)
+			}
+			record, flags, err := compacted.encodeRecord(rowData)
+			if err != nil {
+				return nil, err
+			}
+			if page == nil || int(page.FreeSpace()) < len(record)+2 {
+				if page, err = pager.AllocatePage(storage.PageTypeData); err != nil {
+					return nil, err
+				}
+				compacted.dataPageIDs = append(compacted.dataPageIDs, page.ID())
+			}
+			offset, err := t.writeRecordToPage(page, record, flags)
+			if err != nil {
+				return nil, err
+			}
+			row.location = uint64(page.ID())<<32 | uint64(offset)
+			rows = append(rows, row)
+			compacted.stats.RowCount++
+			compacted.stats.BytesStored += int64(len(rowData))
+		}
+	}
+	if err := t.copyGroupsInto(compacted); err != nil {
+		return nil, err
+	}
+
+	// Rebuild the primary key index from the sorted keys
+	btree, err := t.buildPrimaryKey(pager, rows)
+	if err != nil {
+		return nil, err
+	}
+	compacted.btree = btree
+
+	// Rebuild the secondary indexes
+	for name, idx := range t.indexes {
+		columns := t.indexColumns(idx)
+		indexKeys := make([][]byte, len(rows))
+		indexLocations := make([]uint64, len(rows))
+		for i, row := range rows {
+			indexKeys[i] = t.buildIndexKey(row, columns)
+			indexLocations[i] = row.location
+		}
+		rebuilt, err := storage.BuildIndex(idx.Name, t.Name, idx.Columns, idx.Unique, pager, indexKeys, indexLocations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild index %s: %w", name, err)
+		}
+		compacted.indexes[name] = rebuilt
+	}
+
+	return compacted, nil
+}
+
+// buildPrimaryKey builds a primary key B-tree for rows in pager.
+func (t *Table) buildPrimaryKey(pager *storage.Pager, rows []Row) (*storage.BTree, error) {
+	keys := make([][]byte, len(rows))
+	order := make([]int, len(rows))
+	for i, row := range rows {
+		key, err := t.primaryIndexKey(row.Values, row.ID)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return bytes.Compare(keys[order[a]], keys[order[b]]) < 0
+	})
+	sortedKeys := make([][]byte, len(rows))
+	locations := make([]uint64, len(rows))
+	for i, j := range order {
+		sortedKeys[i] = keys[j]
+		locations[i] = rows[j].location
+	}
+	btree, err := storage.BuildBTree(pager, sortedKeys, locations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebuild primary key index: %w", err)
+	}
+	return btree, nil
+}
+
+// RebuildPrimaryKey replaces the primary key B-tree with one built from
+// the table's rows, for databases whose keys were written in an older
+// encoding (see encodeKey). The old tree's pages are left unused until
+// VACUUM.
+func (t *Table) RebuildPrimaryKey() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var rows []Row
+	for _, pageID := range t.dataPageIDs {
+		page, err := t.pager.GetPage(pageID)
+		if err != nil {
+			return err
+		}
+		pageRows, err := t.readRowsFromPage(page)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, pageRows...)
+	}
+
+	btree, err := t.buildPrimaryKey(t.pager, rows)
+	if err != nil {
+		return err
+	}
+	t.btree = btree
+	return nil
+}
+
+// Reopen returns a Table that reads the same pages through another
+// pager, after they have been copied into it (see storage.Pager.CopyFrom).
+func (t *Table) Reopen(pager *storage.Pager) *Table {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	reopened := LoadTable(t.Name, t.Schema, pager, t.btree.RootPage(), t.nextRowID,
+		append([]uint32(nil), t.dataPageIDs...))
+	reopened.stats = t.stats
+	reopened.indexStats = t.indexStats
+	reopened.columnar, reopened.groups, reopened.groupSize = t.columnar, t.groups, t.groupSize
+	for name, idx := range t.indexes {
+		reopened.indexes[name] = storage.LoadIndex(idx.Name, idx.Table, idx.Columns, idx.Unique, pager, idx.RootPage())
+	}
+	return reopened
+}
+
+// GetRowByPrimaryKey retrieves a row by its primary key value, or by the
+// value of each column of a composite key, in key order.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// This method uses the B-tree index for O(log n) lookup instead of
+// scanning the entire table. This is the key optimization that makes
 // indexed lookups fast:
 // 1. Convert the key value to bytes
 // 2. Search the B-tree to find the row's location
 // 3. Fetch the row directly using GetRowByLocation
-func (t *Table) GetRowByPrimaryKey(keyValue Value) (Row, bool, error) {
+func (t *Table) GetRowByPrimaryKey(keyValues ...Value) (Row, bool, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
 	// Check if table has a primary key
-	if t.Schema.PrimaryKey < 0 {
+	if len(t.Schema.PrimaryKeyColumns) == 0 {
 		return Row{}, false, errors.New("table has no primary key")
 	}
+	if len(keyValues) != len(t.Schema.PrimaryKeyColumns) {
+		return Row{}, false, fmt.Errorf("primary key has %d columns, got %d values",
+			len(t.Schema.PrimaryKeyColumns), len(keyValues))
+	}
 
 	// Convert value to bytes for B-tree lookup
-	keyBytes, err := t.valueToBytes(keyValue)
+	keyBytes, err := t.primaryKeyBytes(keyValues)
 	if err != nil {
-		return Row{}, false, fmt.Errorf("failed to serialize key: %w", err)
+		return Row{}, false, err
 	}
 
 	// Search the B-tree index
@@ -739,7 +1711,7 @@ func (t *Table) GetRowByPrimaryKey(keyValue Value) (Row, bool, error) {
 	}
 
 	// Fetch the row by location (already holding lock)
-	row, err := t.getRowByLocationLocked(location)
+	row, err := t.getRowByLocationLocked(location, nil)
 	if err != nil {
 		return Row{}, false, fmt.Errorf("failed to fetch row: %w", err)
 	}
@@ -747,6 +1719,164 @@ func (t *Table) GetRowByPrimaryKey(keyValue Value) (Row, bool, error) {
 	return row, true, nil
 }
 
+// ScanPrimaryKeyRange returns the rows whose primary key lies between
+// lower and upper (nil for no bound) and that match filter, in primary key
+// order. If limit > 0, it returns at most that many rows.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Keys are encoded so that their bytes sort like their values (see
+// keys.go), so "id > 100" is one contiguous stretch of the B-tree: find
+// the leaf holding 100, then follow the leaf links until a key passes the
+// upper bound. Each entry gives the row's location, which is then read
+// from its data page. This costs O(log n + k) for k rows in the range,
+// instead of the O(n) of a full scan.
+func (t *Table) ScanPrimaryKeyRange(lower, upper *Value, lowerInclusive, upperInclusive bool, filter func(Row) bool, limit int) ([]Row, error) {
+	it, err := t.NewPrimaryKeyRangeIterator(lower, upper, lowerInclusive, upperInclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	return collectRows(it, filter, limit)
+}
+
+// LookupIndex returns the rows whose value in the column of the named
+// single-column index equals val and that match filter. If limit > 0, it
+// returns at most that many rows.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A secondary index answers "where are the rows with age = 30?" with a
+// list of row locations, one B-tree search (or a short scan of the
+// entries sharing the key, for a non-unique index) away. Each location
+// then costs a read of the row's data page: unlike the primary key, the
+// index's entries aren't in the order the rows are stored, so a lookup
+// that matches many rows can read as many different pages.
+func (t *Table) LookupIndex(name string, val Value, filter func(Row) bool, limit int) ([]Row, error) {
+	idx, err := t.singleColumnIndex(name)
+	if err != nil {
+		return nil, err
+	}
+	coll := t.Schema.Columns[t.indexColumns(idx)[0]].Collation
+	key, err := t.valueToBytes(coll.Key(val))
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize index key: %w", err)
+	}
+
+	t.mu.RLock()
+	locations, err := idx.Lookup(key)
+	t.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("index lookup failed: %w", err)
+	}
+	return t.rowsAtLocations(locations, filter, limit)
+}
+
+// ScanIndexRange returns the rows whose value in the column of the named
+// single-column index lies between lower and upper (nil for no bound) and
+// that match filter, in index order. If limit > 0, it returns at most that
+// many rows. NULLs are never in a range.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// A non-unique index appends each entry's row location to its key, so the
+// entries for age = 30 all sort after the encoded 30 itself. The scan
+// therefore runs from the lower bound's key to the first key past every
+// entry starting with the upper bound's, and the bounds' exact operators
+// (> or >=, < or <=) are applied to the rows it finds.
+func (t *Table) ScanIndexRange(name string, lower, upper *Value, lowerInclusive, upperInclusive bool, filter func(Row) bool, limit int) ([]Row, error) {
+	idx, err := t.singleColumnIndex(name)
+	if err != nil {
+		return nil, err
+	}
+	colIdx := t.indexColumns(idx)[0]
+	coll := t.Schema.Columns[colIdx].Collation
+
+	var startKey, endKey []byte
+	if lower != nil {
+		if startKey, err = t.valueToBytes(coll.Key(*lower)); err != nil {
+			return nil, fmt.Errorf("failed to serialize range start: %w", err)
+		}
+	}
+	if upper != nil {
+		if endKey, err = t.valueToBytes(coll.Key(*upper)); err != nil {
+			return nil, fmt.Errorf("failed to serialize range end: %w", err)
+		}
+		endKey = keySuccessor(endKey)
+	}
+
+	t.mu.RLock()
+	locations, err := idx.RangeScan(startKey, endKey)
+	t.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("index range scan failed: %w", err)
+	}
+
+	inRange := func(row Row) bool {
+		val := row.Values[colIdx]
+		if val.IsNull {
+			return false
+		}
+		if lower != nil {
+			if cmp := coll.Compare(val, *lower); cmp < 0 || (cmp == 0 && !lowerInclusive) {
+				return false
+			}
+		}
+		if upper != nil {
+			if cmp := coll.Compare(val, *upper); cmp > 0 || (cmp == 0 && !upperInclusive) {
+				return false
+			}
+		}
+		return filter(row)
+	}
+	return t.rowsAtLocations(locations, inRange, limit)
+}
+
+// singleColumnIndex returns the named index, which must cover exactly one
+// column.
+func (t *Table) singleColumnIndex(name string) (*storage.Index, error) {
+	idx, ok := t.GetIndex(name)
+	if !ok {
+		return nil, fmt.Errorf("index %s does not exist", name)
+	}
+	if len(idx.Columns) != 1 {
+		return nil, fmt.Errorf("index %s covers %d columns, expected 1", name, len(idx.Columns))
+	}
+	return idx, nil
+}
+
+// rowsAtLocations reads the rows at locations, keeping those that match
+// filter, up to limit if limit > 0.
+func (t *Table) rowsAtLocations(locations []uint64, filter func(Row) bool, limit int) ([]Row, error) {
+	var rows []Row
+	for _, location := range locations {
+		row, err := t.GetRowByLocation(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch row: %w", err)
+		}
+		if filter(row) {
+			rows = append(rows, row)
+			if limit > 0 && len(rows) >= limit {
+				break
+			}
+		}
+	}
+	return rows, nil
+}
+
+// keySuccessor returns the smallest key that sorts after every key
+// starting with key, or nil if there is none.
+func keySuccessor(key []byte) []byte {
+	next := bytes.Clone(key)
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i] < 0xFF {
+			next[i]++
+			return next[:i+1]
+		}
+	}
+	return nil
+}
+
 // GetRowByLocation retrieves a row by its storage location.
 //
 // EDUCATIONAL NOTE:
@@ -760,11 +1890,12 @@ func (t *Table) GetRowByPrimaryKey(keyValue Value) (Row, bool, error) {
 func (t *Table) GetRowByLocation(location uint64) (Row, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.getRowByLocationLocked(location)
+	return t.getRowByLocationLocked(location, nil)
 }
 
-// getRowByLocationLocked is the internal implementation that assumes the lock is held.
-func (t *Table) getRowByLocationLocked(location uint64) (Row, error) {
+// getRowByLocationLocked is the internal implementation that assumes the
+// lock is held. It decodes the columns set in columns, or all if nil.
+func (t *Table) getRowByLocationLocked(location uint64, columns []bool) (Row, error) {
 	// Extract page ID and offset from location
 	pageID := uint32(location >> 32)
 	offset := uint16(location & 0xFFFFFFFF)
@@ -783,24 +1914,32 @@ func (t *Table) getRowByLocationLocked(location uint64) (Row, error) {
 	}
 
 	// Read the row length (2-byte prefix)
-	length := binary.LittleEndian.Uint16(data[offset:])
-	if length == 0 {
+	prefix := binary.LittleEndian.Uint16(data[offset:])
+	if prefix == 0 {
 		return Row{}, errors.New("invalid row: zero length")
 	}
+	if prefix&rowDeletedFlag != 0 {
+		return Row{}, fmt.Errorf("row at offset %d has been deleted", offset)
+	}
 
 	// Validate we have enough data for the row
+	length := int(prefix & rowLengthMask)
 	rowStart := int(offset) + 2
-	rowEnd := rowStart + int(length)
+	rowEnd := rowStart + length
 	if rowEnd > len(data) {
 		return Row{}, fmt.Errorf("invalid row length %d at offset %d: exceeds page bounds", length, offset)
 	}
 
 	// Read and deserialize the row
-	rowData := data[rowStart:rowEnd]
-	row, err := t.deserializeRow(rowData)
+	rowData, err := t.decodeRecord(data[rowStart:rowEnd], prefix)
+	if err != nil {
+		return Row{}, err
+	}
+	row, err := t.deserializeColumns(rowData, columns)
 	if err != nil {
 		return Row{}, fmt.Errorf("failed to deserialize row: %w", err)
 	}
+	row.location, row.size = location, len(rowData)
 
 	return row, nil
 }
@@ -814,13 +1953,22 @@ func (t *Table) getRowByLocationLocked(location uint64) (Row, error) {
 // EDUCATIONAL NOTE:
 // -----------------
 // Creating an index involves:
-// 1. Creating a new B-tree for the index
-// 2. Populating it with entries for all existing rows
+// 1. Collecting an entry for every existing row
+// 2. Building the index's B-tree from them
 // 3. Registering it so future INSERTs/UPDATEs maintain it
+//
+// Inserting the entries one at a time would split leaf after leaf and
+// leave them half full. Since every entry is known up front, they are
+// sorted and the B-tree is built from the bottom up instead (see
+// storage.BuildBTree): each page is written once and packed full.
 func (t *Table) CreateIndex(name string, columns []string, unique bool) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	if t.columnar {
+		return fmt.Errorf("columnar table %s cannot be indexed", t.Name)
+	}
+
 	// Check if index already exists
 	if _, exists := t.indexes[name]; exists {
 		return fmt.Errorf("index %s already exists", name)
@@ -836,13 +1984,9 @@ func (t *Table) CreateIndex(name string, columns []string, unique bool) error {
 		columnIndices[i] = idx
 	}
 
-	// Create the index
-	idx, err := storage.NewIndex(name, t.Name, columns, unique, t.pager)
-	if err != nil {
-		return fmt.Errorf("failed to create index: %w", err)
-	}
-
-	// Populate index with existing rows
+	// Collect an entry for each existing row
+	var keys [][]byte
+	var locations []uint64
 	for _, pageID := range t.dataPageIDs {
 		page, err := t.pager.GetPage(pageID)
 		if err != nil {
@@ -854,39 +1998,18 @@ func (t *Table) CreateIndex(name string, columns []string, unique bool) error {
 			return err
 		}
 
-		// Find where each row is stored to get its location
 		for _, row := range rows {
-			// Build index key from column values
-			keyBytes := t.buildIndexKey(row, columnIndices)
-
-			// Get the row location from the primary index
-			var pkKeyBytes []byte
-			if t.Schema.PrimaryKey >= 0 {
-				var err error
-				pkKeyBytes, err = t.valueToBytes(row.Values[t.Schema.PrimaryKey])
-				if err != nil {
-					return fmt.Errorf("failed to serialize primary key: %w", err)
-				}
-			} else {
-				pkKeyBytes = make([]byte, 8)
-				binary.LittleEndian.PutUint64(pkKeyBytes, row.ID)
-			}
-
-			location, found, err := t.btree.Search(pkKeyBytes)
-			if err != nil {
-				return fmt.Errorf("failed to get row location: %w", err)
-			}
-			if !found {
-				continue // Row might have been deleted
-			}
-
-			// Add to index
-			if err := idx.Insert(keyBytes, location); err != nil {
-				return fmt.Errorf("failed to add row to index: %w", err)
-			}
+			keys = append(keys, t.buildIndexKey(row, columnIndices))
+			locations = append(locations, row.location)
 		}
 	}
 
+	// Build the index from them
+	idx, err := storage.BuildIndex(name, t.Name, columns, unique, t.pager, keys, locations)
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
 	t.indexes[name] = idx
 	return nil
 }
@@ -896,14 +2019,104 @@ func (t *Table) DropIndex(name string) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if _, exists := t.indexes[name]; !exists {
+	idx, exists := t.indexes[name]
+	if !exists {
 		return fmt.Errorf("index %s does not exist", name)
 	}
 
+	// Give the index's B-tree back to the free list
+	pages, err := idx.Pages()
+	if err != nil {
+		return fmt.Errorf("failed to find pages of index %s: %w", name, err)
+	}
+	if err := t.freePages(pages); err != nil {
+		return err
+	}
+
 	delete(t.indexes, name)
 	return nil
 }
 
+// Drop gives every page of the table back to the pager's free list: its
+// data pages and the overflow chains of their rows, and the pages of its
+// primary key and secondary index B-trees. The table can't be used
+// afterwards.
+func (t *Table) Drop() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pages, err := t.btree.Pages()
+	if err != nil {
+		return fmt.Errorf("failed to find pages of the primary key: %w", err)
+	}
+	for name, idx := range t.indexes {
+		indexPages, err := idx.Pages()
+		if err != nil {
+			return fmt.Errorf("failed to find pages of index %s: %w", name, err)
+		}
+		pages = append(pages, indexPages...)
+	}
+	for _, pageID := range t.dataPageIDs {
+		chains, err := t.overflowPages(pageID)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, pageID)
+		pages = append(pages, chains...)
+	}
+	groupPages, err := t.groupPages()
+	if err != nil {
+		return err
+	}
+	if err := t.freePages(append(pages, groupPages...)); err != nil {
+		return err
+	}
+
+	t.dataPageIDs = nil
+	t.groups = nil
+	t.indexes = make(map[string]*storage.Index)
+	return nil
+}
+
+// overflowPages returns the pages of the overflow chains of the live rows
+// in a data page. Those of deleted rows were abandoned when the rows were
+// deleted, and are left for VACUUM.
+func (t *Table) overflowPages(pageID uint32) ([]uint32, error) {
+	page, err := t.pager.GetPage(pageID)
+	if err != nil {
+		return nil, err
+	}
+	data := page.GetData()
+	offset := 0
+
+	var pages []uint32
+	for i := 0; i < int(page.NumSlots()) && offset < len(data)-1; i++ {
+		prefix := binary.LittleEndian.Uint16(data[offset:])
+		length := int(prefix & rowLengthMask)
+		record := data[offset+2 : offset+2+length]
+		offset += 2 + length
+		if prefix&rowDeletedFlag != 0 || prefix&rowOverflowFlag == 0 || len(record) < 8 {
+			continue
+		}
+		chain, err := t.pager.OverflowPages(binary.LittleEndian.Uint32(record[4:]), int(binary.LittleEndian.Uint32(record)))
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageID, err)
+		}
+		pages = append(pages, chain...)
+	}
+	return pages, nil
+}
+
+// freePages puts pages on the pager's free list.
+func (t *Table) freePages(pages []uint32) error {
+	for _, pageID := range pages {
+		if err := t.pager.FreePage(pageID); err != nil {
+			return fmt.Errorf("failed to free page %d: %w", pageID, err)
+		}
+	}
+	return nil
+}
+
 // GetIndex returns an index by name.
 func (t *Table) GetIndex(name string) (*storage.Index, bool) {
 	t.mu.RLock()
@@ -945,11 +2158,12 @@ func (t *Table) AddIndex(idx *storage.Index) {
 	t.indexes[idx.Name] = idx
 }
 
-// buildIndexKey creates a B-tree key from the specified column values of a row.
+// buildIndexKey creates a B-tree key from the specified column values of
+// a row, each under its column's collation.
 func (t *Table) buildIndexKey(row Row, columnIndices []int) []byte {
 	buf := bytes.NewBuffer(nil)
 	for _, colIdx := range columnIndices {
-		t.serializeValue(buf, row.Values[colIdx])
+		encodeKey(buf, t.Schema.Columns[colIdx].Collation.Key(row.Values[colIdx]))
 	}
 	return buf.Bytes()
 }
@@ -984,8 +2198,18 @@ func (t *Table) Analyze() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Count rows (for large tables, we might sample instead)
-	rowCount := int64(0)
+	// Count rows and gather column statistics (for large tables, we might
+	// sample instead)
+	rowCount, bytesStored := int64(0), t.packedBytes()
+	columns := newColumnStatsBuilder(t.Schema)
+	packed, err := t.packedRows()
+	if err != nil {
+		return err
+	}
+	for _, row := range packed {
+		rowCount++
+		columns.add(row)
+	}
 	for _, pageID := range t.dataPageIDs {
 		page, err := t.pager.GetPage(pageID)
 		if err != nil {
@@ -994,20 +2218,27 @@ func (t *Table) Analyze() error {
 		pageRows, err := t.readRowsFromPage(page)
 		if err == nil {
 			rowCount += int64(len(pageRows))
+			for _, row := range pageRows {
+				columns.add(row)
+				bytesStored += int64(row.size)
+			}
 		}
 	}
 
 	// Update table stats
 	t.stats.RowCount = rowCount
+	t.stats.BytesStored = bytesStored
 	t.stats.PageCount = len(t.dataPageIDs)
 	t.stats.LastAnalyzed = time.Now()
+	t.stats.Columns = columns.stats()
+	t.stats.Modified = 0
 
 	// Update index stats - count distinct keys and estimate tree height
 	keys, _, err := t.btree.Scan()
 	if err == nil {
 		t.indexStats.DistinctKeys = countDistinct(keys)
 		t.indexStats.LeafPages = len(t.dataPageIDs) // Approximate
-		t.indexStats.TreeHeight = estimateTreeHeight(len(keys))
+		t.indexStats.TreeHeight = EstimateTreeHeight(len(keys))
 	}
 
 	return nil
@@ -1022,9 +2253,9 @@ func countDistinct(keys [][]byte) int64 {
 	return int64(len(seen))
 }
 
-// estimateTreeHeight estimates B-tree height based on key count.
+// EstimateTreeHeight estimates B-tree height based on key count.
 // Height = log_branching_factor(n), with branching factor ~100.
-func estimateTreeHeight(keyCount int) int {
+func EstimateTreeHeight(keyCount int) int {
 	if keyCount <= 0 {
 		return 1
 	}
@@ -1,7 +1,9 @@
 package table
 
 import (
+	"errors"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
@@ -708,6 +710,10 @@ func TestAnalyze(t *testing.T) {
 		}
 	}
 
+	if got := tbl.Stats().Modified; got != 5 {
+		t.Errorf("expected 5 modified rows before ANALYZE, got %d", got)
+	}
+
 	// Run analyze
 	err := tbl.Analyze()
 	if err != nil {
@@ -719,6 +725,9 @@ func TestAnalyze(t *testing.T) {
 	if stats.RowCount != 5 {
 		t.Errorf("expected RowCount 5 after ANALYZE, got %d", stats.RowCount)
 	}
+	if stats.Modified != 0 {
+		t.Errorf("expected ANALYZE to reset the modified rows, got %d", stats.Modified)
+	}
 	if stats.LastAnalyzed.IsZero() {
 		t.Error("expected LastAnalyzed to be set after ANALYZE")
 	}
@@ -728,4 +737,349 @@ func TestAnalyze(t *testing.T) {
 	if indexStats.DistinctKeys != 5 {
 		t.Errorf("expected 5 distinct keys, got %d", indexStats.DistinctKeys)
 	}
+
+	// Check column stats
+	name, age := stats.Columns["name"], stats.Columns["age"]
+	if name.DistinctValues != 1 || name.NullCount != 0 || name.Min.Text != "User" {
+		t.Errorf("unexpected stats for name: %+v", name)
+	}
+	if age.DistinctValues != 5 || age.Min.Integer != 21 || age.Max.Integer != 25 {
+		t.Errorf("unexpected stats for age: %+v", age)
+	}
+
+	// Updates and deletes count as modifications too
+	rows, err := tbl.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := tbl.UpdateRow(rows[0], rows[0].Values); err != nil {
+		t.Fatalf("UpdateRow failed: %v", err)
+	}
+	if err := tbl.DeleteRow(rows[1]); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	if got := tbl.Stats().Modified; got != 2 {
+		t.Errorf("expected 2 modified rows, got %d", got)
+	}
+}
+
+func TestTableDelete(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	for i := int64(1); i <= 10; i++ {
+		_, err := tbl.Insert([]Value{
+			{Type: parser.TypeInteger, Integer: i},
+			{Type: parser.TypeText, Text: "user"},
+			{Type: parser.TypeInteger, Integer: 20 + i%3},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := tbl.CreateIndex("idx_age", []string{"age"}, false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	// Delete the rows with age 21 (ids 1, 4, 7, 10)
+	count, err := tbl.Delete(func(row Row) bool { return row.Values[2].Integer == 21 })
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("expected 4 rows deleted, got %d", count)
+	}
+
+	rows, _ := tbl.Scan()
+	if len(rows) != 6 {
+		t.Errorf("expected 6 rows left, got %d", len(rows))
+	}
+	for _, row := range rows {
+		if row.Values[2].Integer == 21 {
+			t.Errorf("deleted row %d still returned by Scan", row.Values[0].Integer)
+		}
+	}
+
+	if _, found, _ := tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 4}); found {
+		t.Error("deleted row still found by primary key")
+	}
+	idx, _ := tbl.GetIndex("idx_age")
+	key := tbl.buildIndexKey(Row{Values: []Value{{}, {}, {Type: parser.TypeInteger, Integer: 21}}}, []int{2})
+	if locations, _ := idx.Lookup(key); len(locations) != 0 {
+		t.Errorf("expected no secondary index entries for age 21, got %d", len(locations))
+	}
+
+	// A deleted key can be used again
+	if _, err := tbl.Insert([]Value{
+		{Type: parser.TypeInteger, Integer: 4},
+		{Type: parser.TypeText, Text: "again"},
+		{Type: parser.TypeInteger, Integer: 40},
+	}); err != nil {
+		t.Fatalf("re-Insert failed: %v", err)
+	}
+	row, found, err := tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 4})
+	if err != nil || !found || row.Values[1].Text != "again" {
+		t.Errorf("expected re-inserted row, got %v (found=%v, err=%v)", row, found, err)
+	}
+}
+
+func TestTableDeleteRow(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	tbl.Insert([]Value{
+		{Type: parser.TypeInteger, Integer: 1},
+		{Type: parser.TypeText, Text: "Alice"},
+		{Type: parser.TypeInteger, Integer: 30},
+	})
+	rows, _ := tbl.Scan()
+
+	if err := tbl.DeleteRow(rows[0]); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	if err := tbl.DeleteRow(rows[0]); err == nil {
+		t.Error("expected error deleting a row twice")
+	}
+	if err := tbl.DeleteRow(Row{ID: 1, Values: rows[0].Values}); err == nil {
+		t.Error("expected error deleting a row that was not read from storage")
+	}
+	if stats := tbl.Stats(); stats.RowCount != 0 {
+		t.Errorf("expected row count 0 after delete, got %d", stats.RowCount)
+	}
+}
+
+func TestTableUpdateRow(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	tbl.Insert([]Value{
+		{Type: parser.TypeInteger, Integer: 1},
+		{Type: parser.TypeText, Text: "Alice"},
+		{Type: parser.TypeInteger, Integer: 30},
+	})
+	tbl.Insert([]Value{
+		{Type: parser.TypeInteger, Integer: 2},
+		{Type: parser.TypeText, Text: "Bob"},
+		{Type: parser.TypeInteger, Integer: 25},
+	})
+	if err := tbl.CreateIndex("idx_name", []string{"name"}, true); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	// A shorter row is rewritten in place
+	rows, _ := tbl.Scan()
+	values := []Value{
+		{Type: parser.TypeInteger, Integer: 1},
+		{Type: parser.TypeText, Text: "Al"},
+		{Type: parser.TypeInteger, Integer: 31},
+	}
+	if err := tbl.UpdateRow(rows[0], values); err != nil {
+		t.Fatalf("UpdateRow failed: %v", err)
+	}
+	row, found, _ := tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 1})
+	if !found || row.Values[1].Text != "Al" || row.location != rows[0].location {
+		t.Errorf("expected row updated in place, got %v at %v", row, row.location)
+	}
+
+	// A longer row with a new key moves, and the primary index follows it
+	rows, _ = tbl.Scan()
+	values = []Value{
+		{Type: parser.TypeInteger, Integer: 10},
+		{Type: parser.TypeText, Text: strings.Repeat("b", 100)},
+		{Type: parser.TypeInteger, Integer: 25},
+	}
+	if err := tbl.UpdateRow(rows[1], values); err != nil {
+		t.Fatalf("UpdateRow failed: %v", err)
+	}
+	if _, found, _ := tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 2}); found {
+		t.Error("old primary key still finds the row")
+	}
+	row, found, _ = tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 10})
+	if !found || row.Values[1].Text != values[1].Text {
+		t.Errorf("new primary key does not find the moved row: %v", row)
+	}
+	idx, _ := tbl.GetIndex("idx_name")
+	oldKey := tbl.buildIndexKey(Row{Values: []Value{{}, {Type: parser.TypeText, Text: "Bob"}}}, []int{1})
+	if locations, _ := idx.Lookup(oldKey); len(locations) != 0 {
+		t.Errorf("expected old name removed from index, got %d entries", len(locations))
+	}
+	if rows, _ := tbl.Scan(); len(rows) != 2 {
+		t.Errorf("expected 2 rows after updates, got %d", len(rows))
+	}
+
+	// Unique index violations leave the row untouched
+	rows, _ = tbl.Scan()
+	values = []Value{
+		{Type: parser.TypeInteger, Integer: 1},
+		{Type: parser.TypeText, Text: strings.Repeat("b", 100)},
+		{Type: parser.TypeInteger, Integer: 31},
+	}
+	if err := tbl.UpdateRow(rows[0], values); err == nil {
+		t.Error("expected unique index violation")
+	}
+	row, _, _ = tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 1})
+	if row.Values[1].Text != "Al" {
+		t.Errorf("expected failed update to leave the row unchanged, got %q", row.Values[1].Text)
+	}
+}
+
+func TestTableLargeRows(t *testing.T) {
+	tbl, pager, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	// One value larger than a page, one too long for a 16-bit length
+	texts := map[int64]string{
+		1: strings.Repeat("a", 3*storage.PageSize),
+		2: strings.Repeat("b", 70000),
+		3: "small",
+	}
+	for id := int64(1); id <= 3; id++ {
+		if _, err := tbl.Insert([]Value{
+			{Type: parser.TypeInteger, Integer: id},
+			{Type: parser.TypeText, Text: texts[id]},
+			{Type: parser.TypeInteger, Integer: 30},
+		}); err != nil {
+			t.Fatalf("Insert of row %d failed: %v", id, err)
+		}
+	}
+	if len(tbl.dataPageIDs) != 1 {
+		t.Errorf("expected large rows to leave one data page, got %d", len(tbl.dataPageIDs))
+	}
+
+	rows, err := tbl.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	for _, row := range rows {
+		if row.Values[1].Text != texts[row.Values[0].Integer] {
+			t.Errorf("row %d: text of %d bytes came back as %d bytes",
+				row.Values[0].Integer, len(texts[row.Values[0].Integer]), len(row.Values[1].Text))
+		}
+	}
+	row, found, err := tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 2})
+	if err != nil || !found || row.Values[1].Text != texts[2] {
+		t.Errorf("lookup of large row failed: found=%v err=%v", found, err)
+	}
+
+	// Shrinking a large row rewrites its record in place; growing a small
+	// one moves it out to overflow pages
+	if err := tbl.UpdateRow(row, []Value{row.Values[0], {Type: parser.TypeText, Text: "now small"}, row.Values[2]}); err != nil {
+		t.Fatalf("UpdateRow shrinking failed: %v", err)
+	}
+	row, _, _ = tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 3})
+	if err := tbl.UpdateRow(row, []Value{row.Values[0], {Type: parser.TypeText, Text: texts[1]}, row.Values[2]}); err != nil {
+		t.Fatalf("UpdateRow growing failed: %v", err)
+	}
+	for id, want := range map[int64]string{2: "now small", 3: texts[1]} {
+		row, _, _ := tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: id})
+		if row.Values[1].Text != want {
+			t.Errorf("row %d: expected %d bytes of text after update, got %d", id, len(want), len(row.Values[1].Text))
+		}
+	}
+
+	row, _, _ = tbl.GetRowByPrimaryKey(Value{Type: parser.TypeInteger, Integer: 1})
+	if err := tbl.DeleteRow(row); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	if rows, _ := tbl.Scan(); len(rows) != 2 {
+		t.Errorf("expected 2 rows after delete, got %d", len(rows))
+	}
+
+	// Keys are never moved to overflow pages, so a huge key is refused
+	// before anything is stored
+	docs, err := NewTable("docs", NewSchema([]parser.ColumnDefinition{
+		{Name: "title", Type: parser.TypeText, PrimaryKey: true},
+	}), pager)
+	if err != nil {
+		t.Fatalf("NewTable failed: %v", err)
+	}
+	_, err = docs.Insert([]Value{{Type: parser.TypeText, Text: texts[1]}})
+	if !errors.Is(err, storage.ErrKeyTooLarge) {
+		t.Errorf("expected ErrKeyTooLarge, got %v", err)
+	}
+	if rows, _ := docs.Scan(); len(rows) != 0 {
+		t.Errorf("expected rejected row not to be stored, got %d rows", len(rows))
+	}
+}
+
+func TestTableCheckIntegrity(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	for i := int64(1); i <= 50; i++ {
+		tbl.Insert([]Value{
+			{Type: parser.TypeInteger, Integer: i},
+			{Type: parser.TypeText, Text: strings.Repeat("n", int(i)*40)},
+			{Type: parser.TypeInteger, Integer: i % 5},
+		})
+	}
+	if err := tbl.CreateIndex("idx_age", []string{"age"}, false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+	tbl.Delete(func(row Row) bool { return row.Values[0].Integer%10 == 0 })
+
+	r := storage.NewIntegrityReport()
+	tbl.CheckIntegrity(r)
+	if len(r.Problems) != 0 {
+		t.Fatalf("expected a healthy table, got %v", r.Problems)
+	}
+
+	// Drop a row from the primary key behind the table's back
+	key, _ := tbl.primaryIndexKey([]Value{{Type: parser.TypeInteger, Integer: 7}}, 0)
+	tbl.btree.Delete(key)
+
+	r = storage.NewIntegrityReport()
+	tbl.CheckIntegrity(r)
+	report := strings.Join(r.Problems, "\n")
+	for _, want := range []string{"row 7 is missing from the primary key", "primary key has 44 entries for 45 rows"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in report:\n%s", want, report)
+		}
+	}
+}
+
+func TestCreateIndexBulkLoads(t *testing.T) {
+	tbl, pager, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	n := 3000
+	for i := 1; i <= n; i++ {
+		tbl.Insert([]Value{
+			{Type: parser.TypeInteger, Integer: int64(i)},
+			{Type: parser.TypeText, Text: "user"},
+			{Type: parser.TypeInteger, Integer: int64(i % 97)},
+		})
+	}
+
+	before := pager.PageCount()
+	if err := tbl.CreateIndex("idx_age", []string{"age"}, false); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	// Entries are a 10-byte value plus an 8-byte location, packed into
+	// full leaves; inserting them one by one would leave leaves half full
+	perLeaf := (storage.MaxDataSize - 13) / (2 + 18 + 8)
+	if pages := int(pager.PageCount() - before); pages > n/perLeaf+2 {
+		t.Errorf("expected about %d pages for a bulk-loaded index, got %d", n/perLeaf+1, pages)
+	}
+
+	idx, _ := tbl.GetIndex("idx_age")
+	key := tbl.buildIndexKey(Row{Values: []Value{{}, {}, {Type: parser.TypeInteger, Integer: 42}}}, []int{2})
+	if locations, err := idx.Lookup(key); err != nil || len(locations) != (n+97-42)/97 {
+		t.Errorf("expected %d rows with age 42, got %d (err=%v)", (n+97-42)/97, len(locations), err)
+	}
+
+	// New rows still go into the index one at a time
+	tbl.Insert([]Value{
+		{Type: parser.TypeInteger, Integer: int64(n + 1)},
+		{Type: parser.TypeText, Text: "user"},
+		{Type: parser.TypeInteger, Integer: 42},
+	})
+	if locations, _ := idx.Lookup(key); len(locations) != (n+97-42)/97+1 {
+		t.Errorf("expected inserted row in the index, got %d rows", len(locations))
+	}
+
+	if err := tbl.CreateIndex("idx_name", []string{"name"}, true); err == nil {
+		t.Error("expected unique index on duplicate values to fail")
+	}
 }
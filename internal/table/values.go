@@ -0,0 +1,136 @@
+// Package table - Building and reading values from Go
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Code outside the SQL engine - a user-defined function, a test, an
+// application binding parameters - needs to move between Go values and
+// table.Value. Writing Value{Type: parser.TypeInteger, Integer: 42} by hand
+// is noisy and easy to get wrong (set Integer but forget Type and the value
+// silently reads as UNKNOWN), so these helpers do it in one place.
+//
+//   ValueOf(42)            -> INTEGER 42
+//   ValueOf("hi")          -> TEXT 'hi'
+//   ValueOf(nil)           -> NULL
+//   v.AsInt64()            -> 42, using the same rules as CAST(v AS INTEGER)
+
+package table
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+// NullValue returns a SQL NULL.
+func NullValue() Value {
+	return Value{IsNull: true}
+}
+
+// IntegerValue wraps an int64 as an INTEGER value.
+func IntegerValue(i int64) Value {
+	return Value{Type: parser.TypeInteger, Integer: i}
+}
+
+// RealValue wraps a float64 as a REAL value.
+func RealValue(f float64) Value {
+	return Value{Type: parser.TypeReal, Real: f}
+}
+
+// TextValue wraps a string as a TEXT value.
+func TextValue(s string) Value {
+	return Value{Type: parser.TypeText, Text: s}
+}
+
+// BooleanValue wraps a bool as a BOOLEAN value.
+func BooleanValue(b bool) Value {
+	return Value{Type: parser.TypeBoolean, Boolean: b}
+}
+
+// ValueOf converts a Go value to a Value. It accepts nil, bool, string,
+// the integer and float kinds, time.Time, and Value itself.
+func ValueOf(x any) (Value, error) {
+	switch v := x.(type) {
+	case nil:
+		return NullValue(), nil
+	case Value:
+		return v, nil
+	case bool:
+		return BooleanValue(v), nil
+	case string:
+		return TextValue(v), nil
+	case int:
+		return IntegerValue(int64(v)), nil
+	case int8:
+		return IntegerValue(int64(v)), nil
+	case int16:
+		return IntegerValue(int64(v)), nil
+	case int32:
+		return IntegerValue(int64(v)), nil
+	case int64:
+		return IntegerValue(v), nil
+	case uint8:
+		return IntegerValue(int64(v)), nil
+	case uint16:
+		return IntegerValue(int64(v)), nil
+	case uint32:
+		return IntegerValue(int64(v)), nil
+	case float32:
+		return RealValue(float64(v)), nil
+	case float64:
+		return RealValue(v), nil
+	case time.Time:
+		return TimestampValue(v), nil
+	default:
+		return Value{}, fmt.Errorf("cannot convert %T to a SQL value", x)
+	}
+}
+
+// Interface returns the value as the natural Go type: nil, int64,
+// float64, string, bool or time.Time. DECIMAL and JSON are returned as
+// their text so no precision is lost.
+func (v Value) Interface() any {
+	if v.IsNull {
+		return nil
+	}
+	switch v.Type {
+	case parser.TypeInteger:
+		return v.Integer
+	case parser.TypeReal:
+		return v.Real
+	case parser.TypeBoolean:
+		return v.Boolean
+	case parser.TypeTimestamp:
+		return TimeOf(v)
+	default:
+		return v.String()
+	}
+}
+
+// AsInt64 converts the value to an int64 following the CAST rules, so
+// TEXT '42' gives 42 and 'abc' is an error. NULL is an error too; check
+// IsNull first when NULL is allowed.
+func (v Value) AsInt64() (int64, error) {
+	converted, err := v.castNonNull(parser.TypeInteger)
+	return converted.Integer, err
+}
+
+// AsFloat64 converts the value to a float64 following the CAST rules.
+func (v Value) AsFloat64() (float64, error) {
+	converted, err := v.castNonNull(parser.TypeReal)
+	return converted.Real, err
+}
+
+// AsBool converts the value to a bool following the CAST rules.
+func (v Value) AsBool() (bool, error) {
+	converted, err := v.castNonNull(parser.TypeBoolean)
+	return converted.Boolean, err
+}
+
+// castNonNull casts v, rejecting NULL.
+func (v Value) castNonNull(target parser.DataType) (Value, error) {
+	if v.IsNull {
+		return Value{}, fmt.Errorf("cannot convert NULL to %s", target)
+	}
+	return Cast(v, target)
+}
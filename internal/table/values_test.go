@@ -0,0 +1,69 @@
+package table
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestValueOf(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input    any
+		expected Value
+	}{
+		{nil, NullValue()},
+		{42, IntegerValue(42)},
+		{int32(-7), IntegerValue(-7)},
+		{2.5, RealValue(2.5)},
+		{"hi", TextValue("hi")},
+		{true, BooleanValue(true)},
+		{when, TimestampValue(when)},
+		{TextValue("x"), TextValue("x")},
+	}
+
+	for _, tt := range tests {
+		got, err := ValueOf(tt.input)
+		if err != nil {
+			t.Errorf("ValueOf(%v) error: %v", tt.input, err)
+			continue
+		}
+		if got.Type != tt.expected.Type || !got.Equals(tt.expected) {
+			t.Errorf("ValueOf(%v) = %+v, expected %+v", tt.input, got, tt.expected)
+		}
+		if tt.input != nil && got.Interface() != tt.expected.Interface() {
+			t.Errorf("ValueOf(%v).Interface() = %v", tt.input, got.Interface())
+		}
+	}
+
+	if _, err := ValueOf([]int{1}); err == nil {
+		t.Error("expected error converting a slice")
+	}
+	if got := IntegerValue(5).Interface(); got != int64(5) {
+		t.Errorf("Interface() = %v (%T), expected int64 5", got, got)
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	if n, err := TextValue(" 42 ").AsInt64(); err != nil || n != 42 {
+		t.Errorf("AsInt64('42') = %d, %v", n, err)
+	}
+	if f, err := IntegerValue(3).AsFloat64(); err != nil || f != 3 {
+		t.Errorf("AsFloat64(3) = %g, %v", f, err)
+	}
+	if b, err := TextValue("yes").AsBool(); err != nil || !b {
+		t.Errorf("AsBool('yes') = %v, %v", b, err)
+	}
+
+	if _, err := TextValue("abc").AsInt64(); err == nil {
+		t.Error("expected error converting 'abc' to int64")
+	}
+	if _, err := NullValue().AsFloat64(); err == nil {
+		t.Error("expected error converting NULL")
+	}
+	if v := RealValue(1); v.Type != parser.TypeReal {
+		t.Errorf("RealValue type = %s", v.Type)
+	}
+}
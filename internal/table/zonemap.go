@@ -0,0 +1,167 @@
+// Package table - Zone maps
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// Without an index on a column, "WHERE created > X" has to read every
+// page of the table. But rows are mostly stored in the order they were
+// inserted, so a column like an id or a timestamp grows from page to
+// page: if the table remembers the smallest and largest value of the
+// column in each page, a scan can skip the pages whose range can't hold
+// a match without reading them:
+//
+//   page:       0        1        2        3
+//   created:  [1..90] [91..170] [171..260] [261..350]
+//
+//   WHERE created > 200   ->  reads pages 2 and 3 only
+//
+// Such a summary is a "zone map" (Netezza's name; Oracle calls them
+// storage indexes, PostgreSQL's BRIN indexes are the same idea stored on
+// disk). It costs two values per column and page, against an index's
+// entry per row, and it can only say "not in this page", never where a
+// row is. It helps a column whose values follow the storage order, and
+// does nothing for one whose values are spread over every page.
+//
+// The zones are kept in memory and built as scans go: the first scan
+// with a range on a column reads every page and notes each one's range,
+// and later scans skip. Inserts and updates widen the range of the page
+// they write to; deletes leave it as it is. A zone may therefore be wider
+// than its page's values, which only means a page is read for nothing,
+// but never narrower, which would skip a page with a matching row.
+
+package table
+
+import (
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+// ScanRange limits a scan to the rows whose column lies between Lower and
+// Upper (nil for no bound). A scan given ranges may skip pages with no row
+// in all of them, but still returns rows outside them from the pages it
+// reads: the caller must check its condition on every row.
+type ScanRange struct {
+	Column         int
+	Lower, Upper   *Value
+	LowerInclusive bool
+	UpperInclusive bool
+}
+
+// columnZone is the range of a column's values in one data page.
+type columnZone struct {
+	known    bool // whether the range has been computed
+	nonNull  bool // whether the page has any non-NULL value in the column
+	min, max Value
+}
+
+// ZoneMapColumn reports whether a scan can skip pages by the values of
+// col: those of a type whose ranges compare the way WHERE compares them,
+// which rules out a column with a collation other than BINARY.
+func ZoneMapColumn(col Column) bool {
+	if col.Collation != CollateBinary {
+		return false
+	}
+	switch col.Type {
+	case parser.TypeInteger, parser.TypeText, parser.TypeTimestamp, parser.TypeDecimal:
+		return true
+	default:
+		return false
+	}
+}
+
+// add widens the zone to include val.
+func (z *columnZone) add(val Value) {
+	switch {
+	case val.IsNull:
+	case !z.nonNull:
+		z.min, z.max, z.nonNull = val, val, true
+	case val.Compare(z.min) < 0:
+		z.min = val
+	case val.Compare(z.max) > 0:
+		z.max = val
+	}
+}
+
+// excludes reports whether no value of the zone can lie in r. A NULL is
+// in no range, so a page without values has nothing for it.
+func (r ScanRange) excludes(z columnZone) bool {
+	if !z.known {
+		return false
+	}
+	if !z.nonNull {
+		return true
+	}
+	if r.Lower != nil {
+		if cmp := z.max.Compare(*r.Lower); cmp < 0 || (cmp == 0 && !r.LowerInclusive) {
+			return true
+		}
+	}
+	if r.Upper != nil {
+		if cmp := z.min.Compare(*r.Upper); cmp > 0 || (cmp == 0 && !r.UpperInclusive) {
+			return true
+		}
+	}
+	return false
+}
+
+// zonesExclude reports whether the zones of a page show it has no row in
+// every one of ranges.
+func (t *Table) zonesExclude(pageID uint32, ranges []ScanRange) bool {
+	t.zoneMu.Lock()
+	defer t.zoneMu.Unlock()
+	zones := t.zones[pageID]
+	for _, r := range ranges {
+		if r.Column < len(zones) && r.excludes(zones[r.Column]) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildZones computes the zones of the ranged columns of a page from its
+// rows, for those not known yet. columns are those decoded, nil for all.
+// Caller must hold the read lock, so that no row is written to the page
+// between reading the rows and recording their range.
+func (t *Table) buildZones(pageID uint32, rows []Row, ranges []ScanRange, columns []bool) {
+	t.zoneMu.Lock()
+	defer t.zoneMu.Unlock()
+	zones := t.zones[pageID]
+	if zones == nil {
+		if t.zones == nil {
+			t.zones = make(map[uint32][]columnZone)
+		}
+		zones = make([]columnZone, len(t.Schema.Columns))
+		t.zones[pageID] = zones
+	}
+
+	for _, r := range ranges {
+		col := r.Column
+		if col >= len(zones) || zones[col].known || (columns != nil && !columns[col]) {
+			continue
+		}
+		z := columnZone{known: true}
+		for _, row := range rows {
+			z.add(row.Values[col])
+		}
+		zones[col] = z
+	}
+}
+
+// widenZones adds the values of a row written to a page to the page's
+// zones. Caller must hold the write lock.
+func (t *Table) widenZones(pageID uint32, values []Value) {
+	t.zoneMu.Lock()
+	defer t.zoneMu.Unlock()
+	zones := t.zones[pageID]
+	for i := range zones {
+		if zones[i].known && i < len(values) {
+			zones[i].add(values[i])
+		}
+	}
+}
+
+// clearZones forgets every zone, when the pages may have changed in ways
+// the zones can't follow. Caller must hold the write lock.
+func (t *Table) clearZones() {
+	t.zoneMu.Lock()
+	defer t.zoneMu.Unlock()
+	t.zones = nil
+}
@@ -0,0 +1,91 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cabewaldrop/claude-db/internal/sql/parser"
+)
+
+func TestZoneMaps(t *testing.T) {
+	tbl, _, cleanup := setupTestTable(t)
+	defer cleanup()
+
+	for i := 0; i < 1000; i++ {
+		values := []Value{
+			{Type: parser.TypeInteger, Integer: int64(i)},
+			{Type: parser.TypeText, Text: fmt.Sprintf("row %d, long enough to take up some room", i)},
+			{Type: parser.TypeInteger, Integer: int64(i % 50)},
+		}
+		if _, err := tbl.Insert(values); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	pages := len(tbl.GetDataPageIDs())
+	if pages < 8 {
+		t.Fatalf("expected at least 8 data pages, got %d", pages)
+	}
+
+	// scan returns the ids of the rows in ranges and the pages skipped
+	scan := func(ranges ...ScanRange) ([]int64, int) {
+		t.Helper()
+		it := tbl.NewScanIterator()
+		defer it.Close()
+		it.SkipPages(ranges)
+		var ids []int64
+		for it.Next() {
+			id := it.Row().Values[0]
+			if ranges[0].Lower.Compare(id) <= 0 {
+				ids = append(ids, id.Integer)
+			}
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		return ids, it.PagesSkipped()
+	}
+	from := func(id int64) ScanRange {
+		return ScanRange{Column: 0, Lower: &Value{Type: parser.TypeInteger, Integer: id}, LowerInclusive: true}
+	}
+
+	// The first scan builds the zones, the next one skips by them
+	if ids, skipped := scan(from(990)); len(ids) != 10 || skipped != 0 {
+		t.Errorf("expected 10 rows and no page skipped, got %d rows and %d", len(ids), skipped)
+	}
+	if ids, skipped := scan(from(990)); len(ids) != 10 || skipped != pages-1 {
+		t.Errorf("expected 10 rows and %d pages skipped, got %d rows and %d", pages-1, len(ids), skipped)
+	}
+
+	// age takes every value on every page, so nothing can be skipped
+	age := ScanRange{Column: 2, Lower: &Value{Type: parser.TypeInteger, Integer: 49}, LowerInclusive: true}
+	scan(age)
+	if _, skipped := scan(age, from(0)); skipped != 0 {
+		t.Errorf("expected no page skipped for age, got %d", skipped)
+	}
+
+	// A row written to the first page, in the room of a deleted one, widens
+	// its zone
+	rows, err := tbl.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if err := tbl.DeleteRow(rows[0]); err != nil {
+		t.Fatalf("DeleteRow failed: %v", err)
+	}
+	if _, err := tbl.Insert([]Value{{Type: parser.TypeInteger, Integer: 5000}, {Type: parser.TypeText, Text: "new"},
+		{Type: parser.TypeInteger, Integer: 1}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if ids, skipped := scan(from(5000)); len(ids) != 1 || skipped != pages-1 {
+		t.Errorf("expected the new row and %d pages skipped, got %v and %d", pages-1, ids, skipped)
+	}
+
+	// So does an update
+	if err := tbl.UpdateRow(rows[500], []Value{{Type: parser.TypeInteger, Integer: 6000}, rows[500].Values[1],
+		rows[500].Values[2]}); err != nil {
+		t.Fatalf("UpdateRow failed: %v", err)
+	}
+	if ids, _ := scan(from(5000)); len(ids) != 2 {
+		t.Errorf("expected the new and the updated row, got %v", ids)
+	}
+}
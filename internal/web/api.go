@@ -5,13 +5,17 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/cabewaldrop/claude-db/internal/sql/executor"
 	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
 	"github.com/cabewaldrop/claude-db/internal/table"
@@ -39,14 +43,17 @@ type ColumnInfo struct {
 	Type       string `json:"type"`
 	PrimaryKey bool   `json:"primary_key"`
 	NotNull    bool   `json:"not_null"`
+	Length     int    `json:"length,omitempty"`    // VARCHAR(length); 0 if unlimited
+	Collation  string `json:"collation,omitempty"` // NOCASE; "" for the default
 }
 
 // TableSchemaResponse describes a table's structure.
 type TableSchemaResponse struct {
-	Name       string       `json:"name"`
-	Columns    []ColumnInfo `json:"columns"`
-	PrimaryKey string       `json:"primary_key,omitempty"`
-	RowCount   int64        `json:"row_count"`
+	Name        string       `json:"name"`
+	Columns     []ColumnInfo `json:"columns"`
+	PrimaryKey  string       `json:"primary_key,omitempty"`
+	RowCount    int64        `json:"row_count"`
+	BytesStored int64        `json:"bytes_stored"`
 }
 
 // RowsResponse contains paginated row data.
@@ -66,8 +73,26 @@ type RowData struct {
 }
 
 // QueryRequest is the body for query execution.
+// Params are bound, in order, to ? placeholders in the SQL. TimeoutMS, if
+// above 0, cancels the query after that many milliseconds, whatever the
+// session's statement_timeout.
 type QueryRequest struct {
-	SQL string `json:"sql"`
+	SQL       string        `json:"sql"`
+	Params    []interface{} `json:"params,omitempty"`
+	TimeoutMS int           `json:"timeout_ms,omitempty"`
+}
+
+// BatchRequest is the body for batch execution: statements run in order,
+// each with its own params. TimeoutMS, if above 0, cancels the batch
+// after that many milliseconds.
+type BatchRequest struct {
+	Statements []QueryRequest `json:"statements"`
+	TimeoutMS  int            `json:"timeout_ms,omitempty"`
+}
+
+// BatchResponse contains the result of each statement of a batch that ran.
+type BatchResponse struct {
+	Results []QueryResponse `json:"results"`
 }
 
 // QueryResponse contains query results.
@@ -76,6 +101,16 @@ type QueryResponse struct {
 	Rows     [][]interface{} `json:"rows,omitempty"`
 	RowCount int             `json:"row_count"`
 	Message  string          `json:"message,omitempty"`
+	Metrics  QueryMetrics    `json:"metrics"`
+}
+
+// QueryMetrics reports how a statement ran.
+type QueryMetrics struct {
+	RowsScanned int     `json:"rows_scanned"`
+	PagesRead   uint64  `json:"pages_read"`
+	IndexUsed   string  `json:"index_used,omitempty"`
+	DurationMs  float64 `json:"duration_ms"`
+	PlanMs      float64 `json:"plan_ms"`
 }
 
 // ============================================================================
@@ -119,11 +154,42 @@ func valueToInterface(v table.Value) interface{} {
 		return v.Text
 	case parser.TypeBoolean:
 		return v.Boolean
+	case parser.TypeJSON:
+		// Embed the document itself rather than a string containing it
+		return json.RawMessage(v.Text)
 	default:
+		// TIMESTAMP and DECIMAL are sent as text; a DECIMAL as a JSON
+		// number would be parsed as a float by most clients
 		return v.String()
 	}
 }
 
+// interfaceToValue converts a decoded JSON value to a table.Value.
+// It is the inverse of valueToInterface and is used to bind query params.
+func interfaceToValue(v interface{}) (table.Value, error) {
+	switch val := v.(type) {
+	case nil:
+		return table.Value{IsNull: true}, nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return table.Value{Type: parser.TypeInteger, Integer: i}, nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return table.Value{}, fmt.Errorf("invalid number %q", val)
+		}
+		return table.Value{Type: parser.TypeReal, Real: f}, nil
+	case float64:
+		return table.Value{Type: parser.TypeReal, Real: val}, nil
+	case string:
+		return table.Value{Type: parser.TypeText, Text: val}, nil
+	case bool:
+		return table.Value{Type: parser.TypeBoolean, Boolean: val}, nil
+	default:
+		return table.Value{}, fmt.Errorf("unsupported parameter type %T", v)
+	}
+}
+
 // dataTypeToString converts a parser.DataType to a human-readable string.
 func dataTypeToString(dt parser.DataType) string {
 	switch dt {
@@ -135,6 +201,12 @@ func dataTypeToString(dt parser.DataType) string {
 		return "TEXT"
 	case parser.TypeBoolean:
 		return "BOOLEAN"
+	case parser.TypeTimestamp:
+		return "TIMESTAMP"
+	case parser.TypeDecimal:
+		return "DECIMAL"
+	case parser.TypeJSON:
+		return "JSON"
 	default:
 		return "UNKNOWN"
 	}
@@ -152,7 +224,9 @@ func (s *Server) handleAPITables(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tables := s.executor.GetTables()
+	exec := s.session()
+	defer exec.Close()
+	tables := exec.GetTables()
 	writeSuccess(w, TableListResponse{Tables: tables})
 }
 
@@ -164,8 +238,10 @@ func (s *Server) handleAPITableSchema(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	exec := s.session()
+	defer exec.Close()
 	tableName := chi.URLParam(r, "name")
-	tbl, exists := s.executor.GetTable(tableName)
+	tbl, exists := exec.GetTable(tableName)
 	if !exists {
 		writeError(w, http.StatusNotFound, fmt.Sprintf("table '%s' not found", tableName))
 		return
@@ -173,27 +249,26 @@ func (s *Server) handleAPITableSchema(w http.ResponseWriter, r *http.Request) {
 
 	// Build column info
 	columns := make([]ColumnInfo, len(tbl.Schema.Columns))
-	var pkName string
 	for i, col := range tbl.Schema.Columns {
 		columns[i] = ColumnInfo{
 			Name:       col.Name,
 			Type:       dataTypeToString(col.Type),
 			PrimaryKey: col.PrimaryKey,
 			NotNull:    col.NotNull,
-		}
-		if col.PrimaryKey {
-			pkName = col.Name
+			Length:     col.Length,
+			Collation:  string(col.Collation),
 		}
 	}
 
-	// Get row count from stats
+	// Get row count and size from stats, which writes keep up to date
 	stats := tbl.Stats()
 
 	writeSuccess(w, TableSchemaResponse{
-		Name:       tableName,
-		Columns:    columns,
-		PrimaryKey: pkName,
-		RowCount:   stats.RowCount,
+		Name:        tableName,
+		Columns:     columns,
+		PrimaryKey:  strings.Join(tbl.Schema.PrimaryKeyNames(), ", "),
+		RowCount:    stats.RowCount,
+		BytesStored: stats.BytesStored,
 	})
 }
 
@@ -206,11 +281,6 @@ func (s *Server) handleAPITableRows(w http.ResponseWriter, r *http.Request) {
 	}
 
 	tableName := chi.URLParam(r, "name")
-	tbl, exists := s.executor.GetTable(tableName)
-	if !exists {
-		writeError(w, http.StatusNotFound, fmt.Sprintf("table '%s' not found", tableName))
-		return
-	}
 
 	// Parse pagination params
 	limit := 50
@@ -226,24 +296,43 @@ func (s *Server) handleAPITableRows(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Get all rows (we'll implement proper pagination later with LIMIT/OFFSET in executor)
-	allRows, err := tbl.Scan()
+	// Read the table only as far as the end of the requested page; the
+	// total comes from the table's stats, which writes keep up to date.
+	// The table is scanned directly, so no other session may change it
+	// meanwhile.
+	exec := s.session()
+	defer exec.Close()
+	var tbl *table.Table
+	var exists bool
+	var pageRows []table.Row
+	var totalCount int64
+	err := exec.Read(r.Context(), func() error {
+		if tbl, exists = exec.GetTable(tableName); !exists {
+			return nil
+		}
+		totalCount = tbl.Stats().RowCount
+		it := tbl.NewScanIterator()
+		defer it.Close()
+		for n := 0; it.Next(); n++ {
+			if n < offset {
+				continue
+			}
+			pageRows = append(pageRows, it.Row())
+			if len(pageRows) == limit {
+				break
+			}
+		}
+		return it.Err()
+	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("scan failed: %v", err))
 		return
 	}
-
-	// Manual pagination
-	totalCount := int64(len(allRows))
-	start := offset
-	end := offset + limit
-	if start > len(allRows) {
-		start = len(allRows)
-	}
-	if end > len(allRows) {
-		end = len(allRows)
+	if !exists {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("table '%s' not found", tableName))
+		return
 	}
-	pageRows := allRows[start:end]
+	end := int64(offset + len(pageRows))
 
 	// Build column names
 	colNames := make([]string, len(tbl.Schema.Columns))
@@ -272,12 +361,15 @@ func (s *Server) handleAPITableRows(w http.ResponseWriter, r *http.Request) {
 		TotalCount: totalCount,
 		Offset:     offset,
 		Limit:      limit,
-		HasMore:    int64(end) < totalCount,
+		HasMore:    end < totalCount,
 	})
 }
 
 // handleAPIQuery executes an arbitrary SQL query.
 // POST /api/query
+//
+// A request with "Accept: text/plain" gets the result as the REPL prints
+// it, written a row at a time, rather than as JSON.
 func (s *Server) handleAPIQuery(w http.ResponseWriter, r *http.Request) {
 	if s.executor == nil {
 		writeError(w, http.StatusServiceUnavailable, "database not initialized")
@@ -285,8 +377,11 @@ func (s *Server) handleAPIQuery(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse request body
+	// UseNumber keeps integer params as integers instead of float64
 	var req QueryRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
 		return
 	}
@@ -304,17 +399,54 @@ func (s *Server) handleAPIQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Bind params
+	args := make([]table.Value, len(req.Params))
+	for i, param := range req.Params {
+		val, err := interfaceToValue(param)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("param %d: %v", i+1, err))
+			return
+		}
+		args[i] = val
+	}
+
 	// Execute
-	result, err := s.executor.Execute(stmt)
+	// The query stops if the client goes away, or its timeout passes
+	ctx := r.Context()
+	if req.TimeoutMS > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMS)*time.Millisecond)
+		defer cancel()
+	}
+	exec := s.session()
+	defer exec.Close()
+	result, err := exec.ExecuteWithParamsContext(ctx, stmt, args)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("execution error: %v", err))
 		return
 	}
 
-	// Convert result to response
+	if strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		result.WriteTo(w)
+		return
+	}
+	writeSuccess(w, queryResponse(result))
+}
+
+// queryResponse converts a statement's result to its JSON response.
+func queryResponse(result *executor.Result) QueryResponse {
 	resp := QueryResponse{
 		RowCount: result.RowCount,
 		Message:  result.Message,
+		Metrics: QueryMetrics{
+			RowsScanned: result.RowsScanned,
+			PagesRead:   result.PagesRead,
+			IndexUsed:   result.IndexUsed,
+			DurationMs:  float64(result.Duration.Microseconds()) / 1000,
+			PlanMs:      float64(result.PlanDuration.Microseconds()) / 1000,
+		},
 	}
 
 	if len(result.Columns) > 0 {
@@ -327,6 +459,75 @@ func (s *Server) handleAPIQuery(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	return resp
+}
+
+// handleAPIBatch executes many statements and then flushes once, which
+// makes bulk loads far faster than a request per statement.
+// POST /api/batch
+//
+// The statements run in order and stop at the first that fails. The
+// response holds the results of the statements that ran; on failure,
+// with the error, so the client knows which took effect.
+func (s *Server) handleAPIBatch(w http.ResponseWriter, r *http.Request) {
+	if s.executor == nil {
+		writeError(w, http.StatusServiceUnavailable, "database not initialized")
+		return
+	}
+
+	var req BatchRequest
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if len(req.Statements) == 0 {
+		writeError(w, http.StatusBadRequest, "statements field is required")
+		return
+	}
+
+	// Parse every statement and bind its params before running any
+	stmts := make([]parser.Statement, len(req.Statements))
+	args := make([][]table.Value, len(req.Statements))
+	for i, query := range req.Statements {
+		stmt, err := parser.New(lexer.New(query.SQL)).Parse()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("statement %d: parse error: %v", i+1, err))
+			return
+		}
+		stmts[i] = stmt
+		for j, param := range query.Params {
+			val, err := interfaceToValue(param)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("statement %d: param %d: %v", i+1, j+1, err))
+				return
+			}
+			args[i] = append(args[i], val)
+		}
+	}
+
+	ctx := r.Context()
+	if req.TimeoutMS > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMS)*time.Millisecond)
+		defer cancel()
+	}
+	exec := s.session()
+	defer exec.Close()
+	results, err := exec.ExecuteBatchContext(ctx, stmts, args)
 
+	resp := BatchResponse{Results: make([]QueryResponse, len(results))}
+	for i, result := range results {
+		resp.Results[i] = queryResponse(result)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{
+			Success: false,
+			Data:    resp,
+			Error:   fmt.Sprintf("execution error: %v", err),
+		})
+		return
+	}
 	writeSuccess(w, resp)
 }
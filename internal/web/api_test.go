@@ -4,14 +4,19 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cabewaldrop/claude-db/internal/sql/executor"
 	"github.com/cabewaldrop/claude-db/internal/sql/lexer"
 	"github.com/cabewaldrop/claude-db/internal/sql/parser"
 	"github.com/cabewaldrop/claude-db/internal/storage"
+	"github.com/cabewaldrop/claude-db/internal/table"
 )
 
 // createTestExecutor creates an executor with an in-memory database.
@@ -162,6 +167,26 @@ func TestAPIQuerySelect(t *testing.T) {
 	if !ok || rowCount != 1 {
 		t.Errorf("Expected row_count=1, got %v", data["row_count"])
 	}
+
+	// The response says how the query ran
+	metrics, ok := data["metrics"].(map[string]interface{})
+	if !ok || metrics["rows_scanned"] != float64(1) || metrics["pages_read"].(float64) < 1 {
+		t.Errorf("Expected metrics with 1 row scanned, got %v", data["metrics"])
+	}
+
+	// Asked for text, it gets the table the REPL prints
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/query", bytes.NewReader(body))
+	req.Header.Set("Accept", "text/plain")
+	textResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to POST /api/query: %v", err)
+	}
+	defer textResp.Body.Close()
+	text, _ := io.ReadAll(textResp.Body)
+	if !strings.HasPrefix(textResp.Header.Get("Content-Type"), "text/plain") ||
+		!strings.Contains(string(text), "| 1  | Alice |") || !strings.HasSuffix(string(text), "(1 rows)\n") {
+		t.Errorf("Expected the result as a text table, got %q", text)
+	}
 }
 
 func TestAPIQueryParseError(t *testing.T) {
@@ -198,7 +223,8 @@ func TestAPITableSchema(t *testing.T) {
 	exec := createTestExecutor(t)
 
 	// Create table directly via executor
-	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER)")
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(40) NOT NULL, age INTEGER)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Alice', 30)")
 
 	srv := NewServer(0, exec)
 	ts := httptest.NewServer(srv.Router())
@@ -236,7 +262,13 @@ func TestAPITableSchema(t *testing.T) {
 
 	columns, ok := data["columns"].([]interface{})
 	if !ok || len(columns) != 3 {
-		t.Errorf("Expected 3 columns, got %v", data["columns"])
+		t.Fatalf("Expected 3 columns, got %v", data["columns"])
+	}
+	if name := columns[1].(map[string]interface{}); name["length"] != 40.0 {
+		t.Errorf("Expected name to have length 40, got %v", name)
+	}
+	if data["row_count"] != 1.0 || data["bytes_stored"].(float64) <= 0 {
+		t.Errorf("Expected 1 row and its size, got %v rows, %v bytes", data["row_count"], data["bytes_stored"])
 	}
 }
 
@@ -341,4 +373,297 @@ func TestAPITableRowsPagination(t *testing.T) {
 	if !hasMore {
 		t.Errorf("Expected has_more=true")
 	}
+
+	// The last page holds what is left
+	resp, err = http.Get(ts.URL + "/api/tables/nums/rows?limit=3&offset=9")
+	if err != nil {
+		t.Fatalf("Failed to GET rows: %v", err)
+	}
+	defer resp.Body.Close()
+	apiResp = APIResponse{}
+	json.NewDecoder(resp.Body).Decode(&apiResp)
+
+	data, _ = apiResp.Data.(map[string]interface{})
+	rows, _ = data["rows"].([]interface{})
+	if len(rows) != 1 || data["has_more"] != false || data["total_count"] != float64(10) {
+		t.Errorf("Expected 1 of 10 rows and no more, got %v", data)
+	}
+}
+
+func TestAPIQueryWithParams(t *testing.T) {
+	exec := createTestExecutor(t)
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+
+	srv := NewServer(0, exec)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	post := func(req QueryRequest) APIResponse {
+		body, _ := json.Marshal(req)
+		resp, err := http.Post(ts.URL+"/api/query", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to POST /api/query: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var apiResp APIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return apiResp
+	}
+
+	insert := post(QueryRequest{
+		SQL:    "INSERT INTO users (id, name) VALUES (?, ?)",
+		Params: []interface{}{7, "O'Brien"},
+	})
+	if !insert.Success {
+		t.Fatalf("Expected insert to succeed: %s", insert.Error)
+	}
+
+	sel := post(QueryRequest{
+		SQL:    "SELECT name FROM users WHERE id = ?",
+		Params: []interface{}{7},
+	})
+	if !sel.Success {
+		t.Fatalf("Expected select to succeed: %s", sel.Error)
+	}
+	data := sel.Data.(map[string]interface{})
+	rows := data["rows"].([]interface{})
+	if len(rows) != 1 || rows[0].([]interface{})[0] != "O'Brien" {
+		t.Errorf("Expected [[O'Brien]], got %v", rows)
+	}
+
+	mismatch := post(QueryRequest{SQL: "SELECT * FROM users WHERE id = ?"})
+	if mismatch.Success {
+		t.Error("Expected failure when params are missing")
+	}
+}
+
+func TestAPIQueryTimeout(t *testing.T) {
+	exec := createTestExecutor(t)
+	executeSQL(t, exec, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQL(t, exec, "INSERT INTO users VALUES (1, 'Ann')")
+	executeSQL(t, exec, "INSERT INTO users VALUES (2, 'Bob')")
+
+	// SLOW(x) takes longer than the timeout below, so a query calling it
+	// times out before its second row
+	err := exec.RegisterFunction("SLOW", executor.ScalarFunction{MinArgs: 1, MaxArgs: 1,
+		Fn: func(args []table.Value) (table.Value, error) {
+			time.Sleep(20 * time.Millisecond)
+			return args[0], nil
+		}})
+	if err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+
+	srv := NewServer(0, exec)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	post := func(req QueryRequest) APIResponse {
+		body, _ := json.Marshal(req)
+		resp, err := http.Post(ts.URL+"/api/query", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to POST /api/query: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var apiResp APIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return apiResp
+	}
+
+	slow := post(QueryRequest{SQL: "SELECT * FROM users WHERE SLOW(id) > 0", TimeoutMS: 5})
+	if slow.Success || !strings.Contains(slow.Error, "statement timed out") {
+		t.Errorf("Expected the query to time out, got %+v", slow)
+	}
+
+	fast := post(QueryRequest{SQL: "SELECT * FROM users", TimeoutMS: 5000})
+	if !fast.Success {
+		t.Errorf("Expected the query to finish within its timeout: %s", fast.Error)
+	}
+}
+
+func TestAPIQuerySessions(t *testing.T) {
+	exec := createTestExecutor(t)
+	executeSQL(t, exec, "CREATE TABLE events (id INTEGER PRIMARY KEY)")
+
+	srv := NewServer(0, exec)
+	ts := httptest.NewServer(srv.Router())
+	defer ts.Close()
+
+	post := func(req QueryRequest) (APIResponse, error) {
+		body, _ := json.Marshal(req)
+		resp, err := http.Post(ts.URL+"/api/query", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return APIResponse{}, err
+		}
+		defer resp.Body.Close()
+
+		var apiResp APIResponse
+		err = json.NewDecoder(resp.Body).Decode(&apiResp)
+		return apiResp, err
+	}
+
+	// A transaction a request leaves open ends with the request
+	if resp, err := post(QueryRequest{SQL: "BEGIN"}); err != nil || !resp.Success {
+		t.Fatalf("Expected BEGIN to succeed: %v %s", err, resp.Error)
+	}
+	if exec.InTransaction() {
+		t.Error("Expected the request's BEGIN to leave the executor outside a transaction")
+	}
+
+	// Requests run alongside each other
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := post(QueryRequest{SQL: "INSERT INTO events VALUES (?)", Params: []interface{}{i}})
+			if err == nil && !resp.Success {
+				err = fmt.Errorf("%s", resp.Error)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Insert failed: %v", err)
+	}
+
+	// The executor's transaction keeps requests out until it ends
+	executeSQL(t, exec, "BEGIN")
+	waiting, err := post(QueryRequest{SQL: "SELECT COUNT(*) FROM events", TimeoutMS: 20})
+	if err != nil || waiting.Success || !strings.Contains(waiting.Error, "statement timed out") {
+		t.Errorf("Expected the query to time out waiting for the transaction, got %+v (%v)", waiting, err)
+	}
+	executeSQL(t, exec, "ROLLBACK")
+	count, err := post(QueryRequest{SQL: "SELECT COUNT(*) FROM events"})
+	if err != nil || !count.Success {
+		t.Fatalf("Expected the count to succeed: %v %s", err, count.Error)
+	}
+	if rows := count.Data.(map[string]interface{})["rows"].([]interface{}); fmt.Sprint(rows[0].([]interface{})[0]) != "20" {
+		t.Errorf("Expected 20 rows, got %v", rows)
+	}
+}
+
+func TestAPIRemoteTable(t *testing.T) {
+	// One server holds the orders, and another reads them through the API
+	remote := createTestExecutor(t)
+	executeSQL(t, remote, "CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT, total REAL, paid BOOLEAN)")
+	executeSQL(t, remote, "INSERT INTO orders VALUES (1, 'shipped', 10.5, TRUE)")
+	executeSQL(t, remote, "INSERT INTO orders VALUES (2, 'open', 4, FALSE)")
+	executeSQL(t, remote, "INSERT INTO orders VALUES (3, 'shipped', 20, TRUE)")
+	ts := httptest.NewServer(NewServer(0, remote).Router())
+	defer ts.Close()
+
+	local := createTestExecutor(t)
+	executeSQL(t, local, fmt.Sprintf("CREATE VIRTUAL TABLE eu_orders USING remote('%s', 'orders')", ts.URL))
+
+	query := func(sql string) string {
+		stmt, err := parser.New(lexer.New(sql)).Parse()
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", sql, err)
+		}
+		result, err := local.Execute(stmt)
+		if err != nil {
+			t.Fatalf("failed to execute %q: %v", sql, err)
+		}
+		var rows []string
+		for _, row := range result.Rows {
+			var values []string
+			for _, v := range row {
+				values = append(values, v.String())
+			}
+			rows = append(rows, strings.Join(values, " "))
+		}
+		return strings.Join(rows, ", ")
+	}
+
+	if got := query("SELECT status, COUNT(*), SUM(total) FROM eu_orders WHERE paid = TRUE GROUP BY status"); got != "shipped 2 30.5" {
+		t.Errorf("unexpected groups %q", got)
+	}
+	if got := query("SELECT id FROM eu_orders ORDER BY total DESC LIMIT 2"); got != "3, 1" {
+		t.Errorf("unexpected order %q", got)
+	}
+
+	// Each query reads the remote table as it is now
+	executeSQL(t, remote, "INSERT INTO orders VALUES (4, 'open', 1, FALSE)")
+	if got := query("SELECT COUNT(*) FROM eu_orders WHERE status = 'open'"); got != "2" {
+		t.Errorf("expected the new order to be read, got %q", got)
+	}
+}
+
+func TestAPIBatch(t *testing.T) {
+	exec := createTestExecutor(t)
+	ts := httptest.NewServer(NewServer(0, exec).Router())
+	defer ts.Close()
+
+	post := func(req BatchRequest) (int, APIResponse) {
+		body, _ := json.Marshal(req)
+		resp, err := http.Post(ts.URL+"/api/batch", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to POST /api/batch: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var apiResp APIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.StatusCode, apiResp
+	}
+
+	status, resp := post(BatchRequest{Statements: []QueryRequest{
+		{SQL: "CREATE TABLE kv (k TEXT PRIMARY KEY, v INTEGER)"},
+		{SQL: "INSERT INTO kv VALUES (?, ?)", Params: []interface{}{"a", 1}},
+		{SQL: "INSERT INTO kv VALUES (?, ?)", Params: []interface{}{"b", 2}},
+		{SQL: "SELECT SUM(v) FROM kv"},
+	}})
+	if status != http.StatusOK || !resp.Success {
+		t.Fatalf("Expected the batch to succeed, got %d: %s", status, resp.Error)
+	}
+	results := resp.Data.(map[string]interface{})["results"].([]interface{})
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	if sum := results[3].(map[string]interface{})["rows"]; fmt.Sprint(sum) != "[[3]]" {
+		t.Errorf("Expected a sum of 3, got %v", sum)
+	}
+
+	// A failing statement stops the batch; the results say what ran
+	status, resp = post(BatchRequest{Statements: []QueryRequest{
+		{SQL: "INSERT INTO kv VALUES ('c', 3)"},
+		{SQL: "INSERT INTO kv VALUES ('a', 4)"},
+		{SQL: "INSERT INTO kv VALUES ('d', 5)"},
+	}})
+	if status != http.StatusBadRequest || !strings.Contains(resp.Error, "statement 2") {
+		t.Errorf("Expected statement 2 to fail, got %d: %s", status, resp.Error)
+	}
+	if results := resp.Data.(map[string]interface{})["results"].([]interface{}); len(results) != 1 {
+		t.Errorf("Expected 1 result before the failure, got %d", len(results))
+	}
+
+	// A statement that doesn't parse stops the batch before any runs
+	status, resp = post(BatchRequest{Statements: []QueryRequest{
+		{SQL: "INSERT INTO kv VALUES ('e', 6)"},
+		{SQL: "INSERT INTO"},
+	}})
+	if status != http.StatusBadRequest || !strings.Contains(resp.Error, "statement 2: parse error") {
+		t.Errorf("Expected a parse error in statement 2, got %d: %s", status, resp.Error)
+	}
+	if status, _ := post(BatchRequest{}); status != http.StatusBadRequest {
+		t.Errorf("Expected an empty batch to be refused, got %d", status)
+	}
+	status, resp = post(BatchRequest{Statements: []QueryRequest{{SQL: "SELECT COUNT(*) FROM kv"}}})
+	if rows := resp.Data.(map[string]interface{})["results"].([]interface{})[0].(map[string]interface{})["rows"]; fmt.Sprint(rows) != "[[3]]" {
+		t.Errorf("Expected 3 rows in kv, got %v", rows)
+	}
 }
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"bufio"
 	"fmt"
 	"html"
 	"net/http"
@@ -71,11 +72,12 @@ func (s *Server) handleQueryExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	exec := GetExecutor(r)
-	if exec == nil {
+	if GetExecutor(r) == nil {
 		renderErrorPartial(w, "Database not available", sql)
 		return
 	}
+	exec := s.session()
+	defer exec.Close()
 
 	// Check if EXPLAIN requested
 	if r.FormValue("explain") == "1" {
@@ -99,7 +101,8 @@ func (s *Server) handleQueryExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := exec.Execute(stmt)
+	// The query stops if the client goes away
+	result, err := exec.ExecuteContext(r.Context(), stmt)
 	duration := time.Since(start)
 
 	if err != nil {
@@ -127,52 +130,54 @@ func renderErrorPartial(w http.ResponseWriter, errMsg, query string) {
 }
 
 // renderResultsPartial renders query results as an HTML table partial.
+// Rows are written to the response as they are formatted, rather than
+// building the whole page in memory first.
 func renderResultsPartial(w http.ResponseWriter, result *executor.Result, query string, duration time.Duration) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 
-	var sb strings.Builder
+	out := bufio.NewWriter(w)
+	defer out.Flush()
 
 	// If there's a message (e.g., "Table created", "1 row inserted")
 	if result.Message != "" {
-		sb.WriteString(fmt.Sprintf(`<div class="result">
+		fmt.Fprintf(out, `<div class="result">
 <p class="message">%s</p>
 <p class="duration">Duration: %v</p>
-</div>`, html.EscapeString(result.Message), duration))
-		w.Write([]byte(sb.String()))
+</div>`, html.EscapeString(result.Message), duration)
 		return
 	}
 
 	// Render table with results
-	sb.WriteString(`<div class="result">`)
-	sb.WriteString(`<table class="results-table">`)
+	out.WriteString(`<div class="result">`)
+	out.WriteString(`<table class="results-table">`)
 
 	// Header row
-	sb.WriteString("<thead><tr>")
+	out.WriteString("<thead><tr>")
 	for _, col := range result.Columns {
-		sb.WriteString(fmt.Sprintf("<th>%s</th>", html.EscapeString(col)))
+		fmt.Fprintf(out, "<th>%s</th>", html.EscapeString(col))
 	}
-	sb.WriteString("</tr></thead>")
+	out.WriteString("</tr></thead>")
 
 	// Data rows
-	sb.WriteString("<tbody>")
+	out.WriteString("<tbody>")
 	for _, row := range result.Rows {
-		sb.WriteString("<tr>")
+		out.WriteString("<tr>")
 		for _, val := range row {
-			sb.WriteString(fmt.Sprintf("<td>%s</td>", html.EscapeString(val.String())))
+			fmt.Fprintf(out, "<td>%s</td>", html.EscapeString(val.String()))
+		}
+		if _, err := out.WriteString("</tr>"); err != nil {
+			return // The client has gone away
 		}
-		sb.WriteString("</tr>")
 	}
-	sb.WriteString("</tbody>")
+	out.WriteString("</tbody>")
 
-	sb.WriteString("</table>")
+	out.WriteString("</table>")
 
 	// Footer with row count and duration
-	sb.WriteString(fmt.Sprintf(`<p class="footer">%d row(s) returned in %v</p>`,
-		len(result.Rows), duration))
-	sb.WriteString("</div>")
-
-	w.Write([]byte(sb.String()))
+	fmt.Fprintf(out, `<p class="footer">%d row(s) returned in %v</p>`,
+		len(result.Rows), duration)
+	out.WriteString("</div>")
 }
 
 // handleQueryPage serves the SQL query input form.
@@ -206,8 +211,11 @@ func (s *Server) handleDeleteRow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	exec := s.session()
+	defer exec.Close()
+
 	// Get the table to check for primary key
-	tbl, ok := s.executor.GetTable(tableName)
+	tbl, ok := exec.GetTable(tableName)
 	if !ok {
 		http.Error(w, "Table not found", http.StatusNotFound)
 		return
@@ -244,7 +252,7 @@ func (s *Server) handleDeleteRow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := s.executor.Execute(stmt)
+	result, err := exec.Execute(stmt)
 	if err != nil {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -278,8 +286,11 @@ func (s *Server) handleInsertRow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	exec := s.session()
+	defer exec.Close()
+
 	// Get the table to verify it exists and get schema
-	tbl, exists := s.executor.GetTable(tableName)
+	tbl, exists := exec.GetTable(tableName)
 	if !exists {
 		http.Error(w, fmt.Sprintf("Table %s does not exist", tableName), http.StatusNotFound)
 		return
@@ -293,6 +304,8 @@ func (s *Server) handleInsertRow(w http.ResponseWriter, r *http.Request) {
 			Type:       col.Type.String(),
 			PrimaryKey: col.PrimaryKey,
 			NotNull:    col.NotNull || col.PrimaryKey,
+			Length:     col.Length,
+			Collation:  string(col.Collation),
 		}
 	}
 
@@ -358,7 +371,7 @@ func (s *Server) handleInsertRow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = s.executor.Execute(stmt)
+	_, err = exec.Execute(stmt)
 	if err != nil {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		RenderTemplate(w, "error.html", map[string]string{
@@ -0,0 +1,65 @@
+// Package web - Metrics endpoint
+//
+// EDUCATIONAL NOTES:
+// ------------------
+// GET /metrics reports the database's buffer pool and disk I/O counters
+// in the Prometheus text format, one "name value" line per metric:
+//
+//   # HELP claudedb_buffer_pool_hits_total Page requests answered from the cache.
+//   # TYPE claudedb_buffer_pool_hits_total counter
+//   claudedb_buffer_pool_hits_total 1234
+//
+// A monitoring system scrapes the endpoint every few seconds. Counters
+// only go up, and the scraper turns them into rates (hits per second);
+// gauges such as the number of cached pages are reported as they are.
+// The format is plain text, so it's easy to read with curl as well.
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// metric is one line of the metrics output.
+type metric struct {
+	name  string
+	kind  string // "counter" or "gauge"
+	help  string
+	value uint64
+}
+
+// handleMetrics reports the buffer pool statistics in the Prometheus text
+// format.
+// GET /metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.executor == nil {
+		http.Error(w, "database not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats := s.executor.PagerStats()
+	metrics := []metric{
+		{"claudedb_buffer_pool_hits_total", "counter", "Page requests answered from the cache.", stats.Hits},
+		{"claudedb_buffer_pool_misses_total", "counter", "Page requests read from disk.", stats.Misses},
+		{"claudedb_buffer_pool_evictions_total", "counter", "Pages dropped from the cache to make room.", stats.Evictions},
+		{"claudedb_buffer_pool_dirty_evictions_total", "counter", "Evicted pages that had to be written first.", stats.DirtyEvictions},
+		{"claudedb_buffer_pool_pages", "gauge", "Pages in the cache.", uint64(stats.CachedPages)},
+		{"claudedb_buffer_pool_dirty_pages", "gauge", "Cached pages with changes not yet written.", uint64(stats.DirtyPages)},
+		{"claudedb_buffer_pool_pinned_pages", "gauge", "Cached pages pinned in use, which can't be evicted.", uint64(stats.PinnedPages)},
+		{"claudedb_buffer_pool_max_pages", "gauge", "Maximum number of pages in the cache.", uint64(stats.MaxCacheSize)},
+		{"claudedb_disk_reads_total", "counter", "Pages read from disk.", stats.DiskReads},
+		{"claudedb_disk_writes_total", "counter", "Pages written to disk.", stats.DiskWrites},
+		{"claudedb_disk_syncs_total", "counter", "Syncs of the database file.", stats.Syncs},
+	}
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	exec, cleanup := setupTestExecutorForDelete(t)
+	defer cleanup()
+	executeSQLForDelete(t, exec, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+	executeSQLForDelete(t, exec, "INSERT INTO items VALUES (1, 'a')")
+	executeSQLForDelete(t, exec, "SELECT * FROM items")
+
+	ts := httptest.NewServer(NewServer(0, exec).Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+
+	for _, want := range []string{
+		"# TYPE claudedb_buffer_pool_hits_total counter\n",
+		"# TYPE claudedb_buffer_pool_pages gauge\n",
+		"claudedb_buffer_pool_max_pages 1000\n",
+		"claudedb_disk_syncs_total ",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(string(body), "claudedb_buffer_pool_hits_total 0\n") {
+		t.Error("expected the queries to have hit the cache")
+	}
+}
+
+func TestMetricsWithoutDatabase(t *testing.T) {
+	ts := httptest.NewServer(NewServer(0, nil).Router())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to GET /metrics: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", resp.StatusCode)
+	}
+}
@@ -37,6 +37,11 @@ type Server struct {
 	router   *chi.Mux
 	port     int
 	executor *executor.Executor
+
+	// sessions is a session of the executor's database that requests open
+	// their own sessions from, so that the executor stays its owner's
+	// (see session).
+	sessions *executor.Executor
 }
 
 // NewServer creates a new HTTP server with the given port and executor.
@@ -61,11 +66,29 @@ func NewServer(port int, exec *executor.Executor) *Server {
 		port:     port,
 		executor: exec,
 	}
+	if exec != nil {
+		s.sessions = exec.NewSession()
+	}
 
 	s.routes()
 	return s
 }
 
+// session opens a session of the database for a request, with its own
+// transaction and settings, which the handler must Close.
+//
+// EDUCATIONAL NOTE:
+// -----------------
+// Requests run on goroutines of their own, at the same time as each
+// other and the CLI. Were they to share the CLI's executor, a BEGIN sent
+// by one would put the others inside its transaction. The sessions take
+// turns at the database instead, any number reading at once or one
+// writing (see executor.NewSession). A transaction a request leaves open
+// is rolled back when its session is closed.
+func (s *Server) session() *executor.Executor {
+	return s.sessions.NewSession()
+}
+
 // routes sets up all HTTP routes for the server.
 func (s *Server) routes() {
 	// Apply executor middleware to inject database access into request context
@@ -76,6 +99,7 @@ func (s *Server) routes() {
 	// Web UI routes
 	s.router.Get("/", s.handleIndex)
 	s.router.Get("/health", s.handleHealth)
+	s.router.Get("/metrics", s.handleMetrics)
 	s.router.Get("/query", s.handleQueryPage)           // Query page form
 	s.router.Post("/query", s.handleQueryExecute)       // HTML form handler for HTMX
 
@@ -88,6 +112,7 @@ func (s *Server) routes() {
 		r.Get("/tables/{name}", s.handleAPITableSchema)
 		r.Get("/tables/{name}/rows", s.handleAPITableRows)
 		r.Post("/query", s.handleAPIQuery)
+		r.Post("/batch", s.handleAPIBatch)
 	})
 
 	// Table data manipulation endpoints
@@ -484,9 +484,6 @@ func TestDeleteRowIdempotent(t *testing.T) {
 	}
 
 	// Second delete should report not found
-	// Note: This test validates the desired behavior. Currently, the DELETE
-	// executor only counts matching rows but doesn't actually remove them,
-	// so this test may pass with 200 until actual deletion is implemented.
 	req, err = http.NewRequest(http.MethodDelete, ts.URL+"/tables/users/1", nil)
 	if err != nil {
 		t.Fatalf("Failed to create request: %v", err)
@@ -499,10 +496,7 @@ func TestDeleteRowIdempotent(t *testing.T) {
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
-	// The second delete should ideally return 404, but with current
-	// implementation (DELETE only counts, doesn't remove), it returns 200.
-	// We accept either behavior until actual deletion is implemented.
-	if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusOK {
-		t.Errorf("Second delete: Expected status 404 or 200, got %d: %s", resp.StatusCode, string(body))
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Second delete: Expected status 404, got %d: %s", resp.StatusCode, string(body))
 	}
 }